@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/madappgang/identifo/v2/config"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/storage/backup"
+)
+
+const backupUsage = `Usage: identifo backup [-config config.yaml] [-out backup.zip] [-allow-without-snapshot]
+
+Writes the apps, management keys, users and invites of the server storages to the ZIP archive.
+Every file of the archive is the JSON array which could be imported to the storage.
+Every storage is exported from the consistent snapshot, the backup fails if some storage could not do it
+(DynamoDB, REST, plugin or MongoDB standalone server), unless -allow-without-snapshot is set.
+Options:
+`
+
+const restoreUsage = `Usage: identifo restore [-config config.yaml] -in backup.zip [-clear]
+
+Imports the backup archive made with "identifo backup" or the admin panel to the server storages.
+Options:
+`
+
+// runBackup runs "identifo backup" subcommand and returns the exit code.
+func runBackup(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, backupUsage)
+		fs.PrintDefaults()
+	}
+	configFlag := fs.String("config", "", "The location of a server configuration file (local file, s3 or etcd)")
+	out := fs.String("out", "", "The archive file, the default is identifo-backup-<time>.zip, - writes to stdout")
+	allowWithoutSnapshot := fs.Bool("allow-without-snapshot", false, "Back up the storages which could not export the consistent snapshot")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	logger := logging.NewDefaultLogger()
+	storages, err := openBackupStorages(logger, *configFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer storages.Close()
+
+	// the storages are checked before the file is created, so the failed backup leaves no empty file.
+	if files := storages.WithoutSnapshot(); len(files) > 0 && !*allowWithoutSnapshot {
+		fmt.Fprintf(stderr, "Unable to write backup: %v could not be exported from the snapshot, use -allow-without-snapshot to back them up anyway\n", files)
+		return 1
+	}
+
+	if *out == "" {
+		*out = fmt.Sprintf("identifo-backup-%s.zip", time.Now().UTC().Format("20060102-150405"))
+	}
+
+	w := stdout
+	if *out != "-" {
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			fmt.Fprintf(stderr, "Unable to create backup file: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	manifest, err := backup.Write(w, storages, *allowWithoutSnapshot)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to write backup: %v\n", err)
+		return 1
+	}
+	if *out != "-" {
+		fmt.Fprintf(stdout, "The backup of %v has been written to %s.\n", manifest.Files, *out)
+	}
+	return 0
+}
+
+// runRestore runs "identifo restore" subcommand and returns the exit code.
+func runRestore(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, restoreUsage)
+		fs.PrintDefaults()
+	}
+	configFlag := fs.String("config", "", "The location of a server configuration file (local file, s3 or etcd)")
+	in := fs.String("in", "", "The archive file")
+	clearOld := fs.Bool("clear", false, "Delete the existing data of the storages before the import")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *in == "" {
+		fs.Usage()
+		return 2
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to open backup file: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to open backup file: %v\n", err)
+		return 1
+	}
+
+	logger := logging.NewDefaultLogger()
+	storages, err := openBackupStorages(logger, *configFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer storages.Close()
+
+	manifest, err := backup.Restore(f, info.Size(), storages, *clearOld)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to restore backup: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "The backup of %v made at %s has been restored.\n", manifest.Files, manifest.StartedAt.Format(time.RFC3339))
+	return 0
+}
+
+func openBackupStorages(logger *slog.Logger, configFlag string) (backup.Storages, error) {
+	configStorage, err := config.InitConfigurationStorageFromFlag(logger, configFlag)
+	if err != nil {
+		return backup.Storages{}, fmt.Errorf("unable to load settings: %w", err)
+	}
+	settings, errs := configStorage.LoadServerSettings(true)
+	if len(errs) > 0 {
+		return backup.Storages{}, fmt.Errorf("unable to load settings: %w", errors.Join(errs...))
+	}
+
+	storages, err := backup.OpenStorages(logger, settings.Storage)
+	if err != nil {
+		return backup.Storages{}, fmt.Errorf("unable to open storages: %w", err)
+	}
+	return storages, nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/madappgang/identifo/v2/server"
 	"github.com/madappgang/identifo/v2/services/mail"
 	"github.com/madappgang/identifo/v2/services/sms"
+	"github.com/madappgang/identifo/v2/services/webhook"
 	"github.com/madappgang/identifo/v2/storage"
 
 	jwt "github.com/madappgang/identifo/v2/jwt/service"
@@ -109,6 +110,48 @@ func NewServer(config model.ConfigurationStorage, restartChan chan<- bool) (mode
 		errs = append(errs, fmt.Errorf("error creating management keys storage: %v", err))
 	}
 
+	webAuthn, err := storage.NewWebAuthnStorage(baseLogger, dbSettings(settings.Storage.WebAuthnStorage))
+	if err != nil {
+		logger.Error("Error on Create New WebAuthn storage", logging.FieldError, err)
+		errs = append(errs, fmt.Errorf("error creating WebAuthn storage: %v", err))
+	}
+
+	var loginAttempts model.LoginAttemptStorage
+	if settings.Login.Lockout.Enabled {
+		loginAttempts, err = storage.NewLoginAttemptStorage(baseLogger, dbSettings(settings.Storage.LoginAttemptStorage))
+		if err != nil {
+			logger.Error("Error on Create New login attempt storage", logging.FieldError, err)
+			errs = append(errs, fmt.Errorf("error creating login attempt storage: %v", err))
+		}
+	}
+
+	audit, err := storage.NewAuditStorage(baseLogger, dbSettings(settings.Storage.AuditStorage))
+	if err != nil {
+		logger.Error("Error on Create New audit storage", logging.FieldError, err)
+		errs = append(errs, fmt.Errorf("error creating audit storage: %v", err))
+	}
+
+	webhookDeliveries, err := storage.NewWebhookDeliveryStorage(baseLogger, dbSettings(settings.Storage.WebhookStorage))
+	if err != nil {
+		logger.Error("Error on Create New webhook delivery storage", logging.FieldError, err)
+		errs = append(errs, fmt.Errorf("error creating webhook delivery storage: %v", err))
+	}
+
+	userSessions, err := storage.NewUserSessionStorage(baseLogger, dbSettings(settings.Storage.UserSessionStorage))
+	if err != nil {
+		logger.Error("Error on Create New user session storage", logging.FieldError, err)
+		errs = append(errs, fmt.Errorf("error creating user session storage: %v", err))
+	}
+
+	var adminAccounts model.AdminAccountStorage
+	if settings.AdminPanel.Enabled {
+		adminAccounts, err = storage.NewAdminAccountStorage(baseLogger, dbSettings(settings.Storage.AdminAccountStorage))
+		if err != nil {
+			logger.Error("Error on Create New admin account storage", logging.FieldError, err)
+			errs = append(errs, fmt.Errorf("error creating admin account storage: %v", err))
+		}
+	}
+
 	session, err := storage.NewSessionStorage(baseLogger, settings.SessionStorage)
 	if err != nil {
 		logger.Error("Error on Create New session storage", logging.FieldError, err)
@@ -154,6 +197,12 @@ func NewServer(config model.ConfigurationStorage, restartChan chan<- bool) (mode
 		Config:        config,
 		Key:           key,
 		ManagementKey: managementKeys,
+		WebAuthn:      webAuthn,
+		LoginAttempt:  loginAttempts,
+		Audit:         audit,
+		Webhook:       webhookDeliveries,
+		UserSession:   userSessions,
+		AdminAccount:  adminAccounts,
 		LoginAppFS:    loginFS,
 		AdminPanelFS:  adminPanelFS,
 	}
@@ -192,6 +241,15 @@ func NewServer(config model.ConfigurationStorage, restartChan chan<- bool) (mode
 		errs = append(errs, fmt.Errorf("error creating token service: %v", err))
 	}
 
+	var keyRotation model.KeyRotationService
+	if tokenS != nil && key != nil {
+		keyRotation, err = NewKeyRotationService(baseLogger, settings.KeyRotation, key, tokenS)
+		if err != nil {
+			logger.Error("Error creating key rotation service", logging.FieldError, err)
+			errs = append(errs, fmt.Errorf("error creating key rotation service: %v", err))
+		}
+	}
+
 	sessionS := model.NewSessionManager(settings.SessionStorage.SessionDuration, session)
 
 	impS, err := NewImpersonationProvider(logger, settings.Impersonation)
@@ -200,12 +258,19 @@ func NewServer(config model.ConfigurationStorage, restartChan chan<- bool) (mode
 		errs = append(errs, fmt.Errorf("error creating impersonation provider: %v", err))
 	}
 
+	var webhookS model.WebhookService
+	if webhookDeliveries != nil && app != nil {
+		webhookS = webhook.NewService(baseLogger, settings.Webhooks, webhookDeliveries, app)
+	}
+
 	srvs := model.ServerServices{
 		SMS:           sms,
 		Email:         email,
 		Token:         tokenS,
 		Session:       sessionS,
 		Impersonation: impS,
+		KeyRotation:   keyRotation,
+		Webhook:       webhookS,
 	}
 
 	server, err := server.NewServer(sc, srvs, errs, restartChan)
@@ -237,6 +302,20 @@ func NewTokenService(
 	return tokenService, err
 }
 
+// NewKeyRotationService loads signing keys ring into the token service and starts scheduled rotation if enabled.
+func NewKeyRotationService(
+	logger *slog.Logger,
+	settings model.KeyRotationSettings,
+	key model.KeyStorage,
+	tokenService model.TokenService,
+) (model.KeyRotationService, error) {
+	kr, err := jwt.NewKeyRotator(logger, key, tokenService, settings)
+	if err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
 func NewImpersonationProvider(
 	logger *slog.Logger,
 	settings model.ImpersonationSettings,
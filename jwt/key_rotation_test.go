@@ -0,0 +1,203 @@
+package jwt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ijwt "github.com/madappgang/identifo/v2/jwt"
+	jwt "github.com/madappgang/identifo/v2/jwt/service"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createKeyRotation(t *testing.T, settings model.KeyRotationSettings) (model.KeyStorage, model.TokenService, *jwt.KeyRotator) {
+	keyData, err := os.ReadFile(keyPath)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), model.PrivateKeyName)
+	require.NoError(t, os.WriteFile(path, keyData, 0o600))
+
+	keyStorage, err := storage.NewKeyStorage(
+		logging.DefaultLogger,
+		model.FileStorageSettings{
+			Type:  model.FileStorageTypeLocal,
+			Local: model.FileStorageLocal{Path: path},
+		})
+	require.NoError(t, err)
+
+	privateKey, err := keyStorage.LoadPrivateKey()
+	require.NoError(t, err)
+
+	us, _ := mem.NewUserStorage()
+	tstor, _ := mem.NewTokenStorage()
+	as, _ := mem.NewAppStorage(logging.DefaultLogger)
+
+	tokenService, err := jwt.NewJWTokenService(logging.DefaultLogger, privateKey, testIssuer, tstor, as, us)
+	require.NoError(t, err)
+
+	rotator, err := jwt.NewKeyRotator(logging.DefaultLogger, keyStorage, tokenService, settings)
+	require.NoError(t, err)
+	t.Cleanup(rotator.Close)
+
+	return keyStorage, tokenService, rotator
+}
+
+func newTestToken(t *testing.T, ts model.TokenService) string {
+	app := model.AppData{ID: "123456", Active: true, Type: model.Web}
+	user := model.User{ID: "12345566", Username: "username", Active: true}
+
+	token, err := ts.NewAccessToken(user, model.AllowedScopes(nil, nil, false), app, false, nil)
+	require.NoError(t, err)
+
+	str, err := ts.String(token)
+	require.NoError(t, err)
+	return str
+}
+
+func TestKeyRotation(t *testing.T) {
+	keyStorage, ts, rotator := createKeyRotation(t, model.KeyRotationSettings{
+		Enabled:            true,
+		Interval:           3600,
+		RetiredKeyLifespan: 3600,
+	})
+
+	// the next key is published in advance
+	ring := rotator.KeyRing()
+	active, ok := ring.Active()
+	require.True(t, ok)
+	next, ok := ring.Next()
+	require.True(t, ok)
+	assert.Equal(t, active.ID, ts.KeyID())
+	require.Len(t, ts.PublicKeys(), 2)
+	assert.Equal(t, active.ID, ts.PublicKeys()[0].ID)
+
+	oldToken := newTestToken(t, ts)
+
+	ring, err := rotator.Rotate()
+	require.NoError(t, err)
+
+	rotated, _ := ring.Active()
+	assert.Equal(t, next.ID, rotated.ID)
+	assert.Equal(t, next.ID, ts.KeyID())
+	assert.Len(t, ts.PublicKeys(), 3)
+
+	// the token signed with the retired key is still valid
+	_, err = ts.Parse(oldToken)
+	require.NoError(t, err)
+
+	newToken := newTestToken(t, ts)
+	parsed, err := ts.Parse(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, next.ID, parsed.(*model.JWToken).JWT.Header["kid"])
+
+	// the ring is saved, and the private key is replaced with the active one
+	saved, err := keyStorage.LoadKeyRing()
+	require.NoError(t, err)
+	require.Len(t, saved.Keys, len(ring.Keys))
+	for i, k := range ring.Keys {
+		assert.Equal(t, k.ID, saved.Keys[i].ID)
+		assert.Equal(t, k.Status, saved.Keys[i].Status)
+	}
+
+	privateKey, err := keyStorage.LoadPrivateKey()
+	require.NoError(t, err)
+	ts.SetPrivateKey(privateKey)
+	assert.Equal(t, next.ID, ts.KeyID())
+}
+
+func TestKeyRotationReplaceActiveKey(t *testing.T) {
+	_, ts, rotator := createKeyRotation(t, model.KeyRotationSettings{
+		RetiredKeyLifespan: 3600,
+	})
+
+	// with scheduled rotation disabled there is no next key
+	require.Len(t, ts.PublicKeys(), 1)
+	oldKeyID := ts.KeyID()
+	oldToken := newTestToken(t, ts)
+
+	key, err := ijwt.GenerateNewPrivateKey(model.TokenSignatureAlgorithmRS256)
+	require.NoError(t, err)
+	keyPEM, err := ijwt.MarshalPrivateKeyToPEM(key)
+	require.NoError(t, err)
+
+	ring, err := rotator.ReplaceActiveKey([]byte(keyPEM))
+	require.NoError(t, err)
+	require.Len(t, ring.Keys, 2)
+	assert.Equal(t, "RS256", ts.Algorithm())
+	assert.NotEqual(t, oldKeyID, ts.KeyID())
+
+	_, err = ts.Parse(oldToken)
+	require.NoError(t, err)
+
+	parsed, err := ts.Parse(newTestToken(t, ts))
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", parsed.(*model.JWToken).JWT.Method.Alg())
+}
+
+func TestKeyRotationSyncDisabled(t *testing.T) {
+	interval := jwt.KeyRingSyncInterval
+	jwt.KeyRingSyncInterval = 10 * time.Millisecond
+	t.Cleanup(func() { jwt.KeyRingSyncInterval = interval })
+
+	keyStorage, ts, rotator := createKeyRotation(t, model.KeyRotationSettings{
+		Interval:           1,
+		RetiredKeyLifespan: 3600,
+	})
+
+	// the key replaced by another instance is picked up with scheduled rotation disabled
+	key, err := ijwt.GenerateNewPrivateKey(model.TokenSignatureAlgorithmES256)
+	require.NoError(t, err)
+	newKey, err := ijwt.NewKeyRingKey(key, time.Now())
+	require.NoError(t, err)
+	ring := rotator.KeyRing().ReplaceActive(newKey, time.Hour, time.Now())
+	require.NoError(t, keyStorage.SaveKeyRing(ring))
+
+	require.Eventually(t, func() bool {
+		return ts.KeyID() == newKey.ID
+	}, time.Second, 10*time.Millisecond)
+
+	// the keys are not rotated on schedule
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, newKey.ID, ts.KeyID())
+	assert.Len(t, rotator.KeyRing().Keys, 2)
+}
+
+func TestKeyRingRotate(t *testing.T) {
+	now := time.Now()
+	ring := model.KeyRing{Keys: []model.KeyRingKey{
+		{ID: "expired", Status: model.KeyStatusRetired, ExpiresAt: now.Add(-time.Minute)},
+		{ID: "retired", Status: model.KeyStatusRetired, ExpiresAt: now.Add(time.Hour)},
+		{ID: "active", Status: model.KeyStatusActive},
+		{ID: "next", Status: model.KeyStatusNext},
+	}}
+
+	published := ring.Published(now)
+	assert.Len(t, published, 3)
+
+	ring = ring.Rotate(model.KeyRingKey{ID: "new"}, time.Hour, now)
+	statuses := map[string]model.KeyStatus{}
+	for _, k := range ring.Keys {
+		statuses[k.ID] = k.Status
+	}
+	assert.Equal(t, map[string]model.KeyStatus{
+		"retired": model.KeyStatusRetired,
+		"active":  model.KeyStatusRetired,
+		"next":    model.KeyStatusActive,
+		"new":     model.KeyStatusNext,
+	}, statuses)
+	assert.Equal(t, now, ring.RotatedAt)
+
+	// without the next key the new key becomes active immediately
+	ring = model.KeyRing{Keys: []model.KeyRingKey{{ID: "active", Status: model.KeyStatusActive}}}
+	ring = ring.Rotate(model.KeyRingKey{ID: "new"}, time.Hour, now)
+	active, _ := ring.Active()
+	assert.Equal(t, "new", active.ID)
+	_, hasNext := ring.Next()
+	assert.False(t, hasNext)
+}
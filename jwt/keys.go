@@ -0,0 +1,70 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// PublicKeyFromPrivate returns public part of the private key.
+func PublicKeyFromPrivate(key interface{}) interface{} {
+	switch pk := key.(type) {
+	case *rsa.PrivateKey:
+		return pk.Public()
+	case *ecdsa.PrivateKey:
+		return pk.Public()
+	default:
+		return nil
+	}
+}
+
+// KeyAlgorithm returns JWT signature algorithm name for the private key.
+func KeyAlgorithm(key interface{}) string {
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256"
+	case *ecdsa.PrivateKey:
+		return "ES256"
+	default:
+		return ""
+	}
+}
+
+// KeyID returns public key ID, using SHA-1 fingerprint.
+func KeyID(publicKey interface{}) string {
+	if publicKey == nil {
+		return ""
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	s := sha1.Sum(der)
+	return base64.RawURLEncoding.EncodeToString(s[:]) // slice from [20]byte
+}
+
+// NewKeyRingKey creates key ring entry for the private key.
+func NewKeyRingKey(key interface{}, createdAt time.Time) (model.KeyRingKey, error) {
+	alg := KeyAlgorithm(key)
+	if len(alg) == 0 {
+		return model.KeyRingKey{}, fmt.Errorf("unsupported private key type: %T", key)
+	}
+
+	keyPEM, err := MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return model.KeyRingKey{}, err
+	}
+
+	return model.KeyRingKey{
+		ID:         KeyID(PublicKeyFromPrivate(key)),
+		Algorithm:  alg,
+		PrivateKey: keyPEM,
+		CreatedAt:  createdAt,
+	}, nil
+}
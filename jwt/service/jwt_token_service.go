@@ -1,20 +1,20 @@
 package service
 
 import (
-	"crypto/ecdsa"
-	"crypto/rsa"
-	"crypto/sha1"
-	"crypto/x509"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
 	jwt "github.com/golang-jwt/jwt/v4"
 	ijwt "github.com/madappgang/identifo/v2/jwt"
 	jwtValidator "github.com/madappgang/identifo/v2/jwt/validator"
 	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
 )
 
 var (
@@ -35,11 +35,19 @@ var (
 	InviteTokenLifespan = int64(3600) // int64(1*60*60)
 	// RefreshTokenLifespan is a default expiration time for refresh tokens, one year.
 	RefreshTokenLifespan = int64(31536000) // int(365*24*60*60)
+	// AuthorizationCodeLifespan is an OAuth 2.0 authorization code expiration time, ten minutes.
+	AuthorizationCodeLifespan = int64(600) // int64(10*60)
+	// EmailVerificationTokenLifespan is an email verification token expiration time, three days.
+	EmailVerificationTokenLifespan = int64(259200) // int64(3*24*60*60)
 )
 
 const (
 	// PayloadName is a JWT token payload "name".
 	PayloadName = "name"
+	// PayloadEmailVerified is a JWT token payload "email_verified", whether the user email is verified.
+	PayloadEmailVerified = "email_verified"
+	// PayloadPhoneVerified is a JWT token payload "phone_verified", whether the user phone is verified.
+	PayloadPhoneVerified = "phone_verified"
 )
 
 // NewJWTokenService returns new JWT token service.
@@ -67,8 +75,8 @@ func NewJWTokenService(
 		userStorage:            userStorage,
 		resetTokenLifespan:     int64(2 * 60 * 60),      // 2 hours is a default expiration time for refresh tokens.
 		webCookieTokenLifespan: int64(2 * 24 * 60 * 60), // 2 days is a default default expiration time for access tokens.
-		privateKey:             privateKey,
 	}
+	t.setPrivateKey(privateKey)
 
 	// Apply options.
 	for _, option := range options {
@@ -84,7 +92,6 @@ func NewJWTokenService(
 type JWTokenService struct {
 	logger *slog.Logger
 
-	privateKey             interface{} // *ecdsa.PrivateKey, or *rsa.PrivateKey
 	tokenStorage           model.TokenStorage
 	appStorage             model.AppStorage
 	userStorage            model.UserStorage
@@ -92,8 +99,14 @@ type JWTokenService struct {
 	resetTokenLifespan     int64
 	webCookieTokenLifespan int64
 
-	cachedAlgorithm string
-	cachedPublicKey interface{} // *ecdsa.PublicKey, or *rsa.PublicKey
+	// keys could be rotated while the service is in use.
+	keysLock   sync.RWMutex
+	privateKey interface{} // *ecdsa.PrivateKey, or *rsa.PrivateKey
+	publicKey  interface{} // *ecdsa.PublicKey, or *rsa.PublicKey
+	algorithm  string
+	keyID      string
+	// verificationKeys are all published keys from the key ring, including the active one.
+	verificationKeys []model.PublicSigningKey
 }
 
 // Issuer returns token issuer name.
@@ -103,24 +116,14 @@ func (ts *JWTokenService) Issuer() string {
 
 // Algorithm  returns signature algorithm.
 func (ts *JWTokenService) Algorithm() string {
-	if len(ts.cachedAlgorithm) > 0 {
-		return ts.cachedAlgorithm
-	}
+	ts.keysLock.RLock()
+	defer ts.keysLock.RUnlock()
 
-	switch ts.privateKey.(type) {
-	case *rsa.PrivateKey:
-		ts.cachedAlgorithm = "RS256"
-		return "RS256"
-	case *ecdsa.PrivateKey:
-		ts.cachedAlgorithm = "ES256"
-		return "ES256"
-	default:
-		return ""
-	}
+	return ts.algorithm
 }
 
-func (ts *JWTokenService) jwtMethod() jwt.SigningMethod {
-	switch ts.Algorithm() {
+func jwtMethod(alg string) jwt.SigningMethod {
+	switch alg {
 	case "ES256":
 		return jwt.SigningMethodES256
 	case "RS256":
@@ -130,49 +133,109 @@ func (ts *JWTokenService) jwtMethod() jwt.SigningMethod {
 	}
 }
 
+func (ts *JWTokenService) jwtMethod() jwt.SigningMethod {
+	return jwtMethod(ts.Algorithm())
+}
+
 // PublicKey returns public key.
 func (ts *JWTokenService) PublicKey() interface{} {
-	if ts.cachedPublicKey != nil {
-		return ts.cachedPublicKey
-	}
+	ts.keysLock.RLock()
+	defer ts.keysLock.RUnlock()
 
-	switch t := ts.privateKey.(type) {
-	case *rsa.PrivateKey:
-		pk := ts.privateKey.(*rsa.PrivateKey)
-		ts.cachedPublicKey = pk.Public()
-	case *ecdsa.PrivateKey:
-		pk := ts.privateKey.(*ecdsa.PrivateKey)
-		ts.cachedPublicKey = pk.Public()
-	default:
-		ts.logger.Error("unable to get public key from private key",
-			"type", t)
-		return nil
-	}
-	return ts.cachedPublicKey
+	return ts.publicKey
 }
 
+// SetPrivateKey replaces the signing key, the tokens signed with the old key could not be verified anymore.
+// Use SetKeyRing to rotate keys without invalidating issued tokens.
 func (ts *JWTokenService) SetPrivateKey(key interface{}) {
 	ts.logger.Info("Changing private key for Token service, all new tokens will be signed with a new key!!!")
 
+	ts.keysLock.Lock()
+	defer ts.keysLock.Unlock()
+
+	ts.setPrivateKey(key)
+}
+
+func (ts *JWTokenService) setPrivateKey(key interface{}) {
 	ts.privateKey = key
-	ts.cachedPublicKey = nil
-	ts.cachedAlgorithm = ""
+	ts.publicKey = ijwt.PublicKeyFromPrivate(key)
+	ts.algorithm = ijwt.KeyAlgorithm(key)
+	ts.keyID = ijwt.KeyID(ts.publicKey)
+	ts.verificationKeys = []model.PublicSigningKey{{
+		ID:        ts.keyID,
+		Algorithm: ts.algorithm,
+		Key:       ts.publicKey,
+	}}
+
+	if ts.publicKey == nil {
+		ts.logger.Error("unable to get public key from private key",
+			"type", fmt.Sprintf("%T", key))
+	}
+}
+
+// SetKeyRing sets the active key of the ring as a signing key,
+// and all published keys of the ring are used to verify tokens by kid.
+func (ts *JWTokenService) SetKeyRing(ring model.KeyRing) error {
+	active, ok := ring.Active()
+	if !ok {
+		return fmt.Errorf("key ring has no active key")
+	}
+
+	privateKey, _, err := ijwt.LoadPrivateKeyFromPEMString(active.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("cannot load active key %s: %w", active.ID, err)
+	}
+
+	keys := []model.PublicSigningKey{}
+	for _, k := range ring.Published(time.Now()) {
+		if k.Status == model.KeyStatusActive {
+			continue
+		}
+		pk, _, err := ijwt.LoadPrivateKeyFromPEMString(k.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("cannot load key %s: %w", k.ID, err)
+		}
+		keys = append(keys, model.PublicSigningKey{
+			ID:        k.ID,
+			Algorithm: ijwt.KeyAlgorithm(pk),
+			Key:       ijwt.PublicKeyFromPrivate(pk),
+		})
+	}
+
+	ts.keysLock.Lock()
+	defer ts.keysLock.Unlock()
+
+	if ts.keyID != active.ID {
+		ts.logger.Info("Changing private key for Token service from the key ring",
+			"kid", active.ID)
+	}
+	ts.setPrivateKey(privateKey)
+	ts.verificationKeys = append(ts.verificationKeys, keys...)
+	return nil
+}
+
+// PublicKeys returns all keys which could be used to verify tokens,
+// the first one is the active key.
+func (ts *JWTokenService) PublicKeys() []model.PublicSigningKey {
+	ts.keysLock.RLock()
+	defer ts.keysLock.RUnlock()
+
+	return append([]model.PublicSigningKey{}, ts.verificationKeys...)
 }
 
 func (ts *JWTokenService) PrivateKey() interface{} {
+	ts.keysLock.RLock()
+	defer ts.keysLock.RUnlock()
+
 	return ts.privateKey
 }
 
 // KeyID returns public key ID, using SHA-1 fingerprint.
 func (ts *JWTokenService) KeyID() string {
-	pk := ts.PublicKey()
-	if pk != nil {
-		if der, err := x509.MarshalPKIXPublicKey(pk); err == nil {
-			s := sha1.Sum(der)
-			return base64.RawURLEncoding.EncodeToString(s[:]) // slice from [20]byte
-		}
-	}
-	return ""
+	ts.keysLock.RLock()
+	defer ts.keysLock.RUnlock()
+
+	return ts.keyID
 }
 
 // WebCookieTokenLifespan return auth token lifespan
@@ -180,15 +243,33 @@ func (ts *JWTokenService) WebCookieTokenLifespan() int64 {
 	return ts.webCookieTokenLifespan
 }
 
+// verificationKey returns the public key to verify the token, the key is selected by kid header.
+// Tokens without kid are verified with the active key.
+func (ts *JWTokenService) verificationKey(token *jwt.Token) (interface{}, error) {
+	ts.keysLock.RLock()
+	defer ts.keysLock.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	if len(kid) == 0 {
+		return ts.publicKey, nil
+	}
+
+	for _, k := range ts.verificationKeys {
+		if k.ID == kid {
+			if token.Method.Alg() != k.Algorithm {
+				return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+			}
+			return k.Key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key: %s", kid)
+}
+
 // Parse parses token data from the string representation.
 func (ts *JWTokenService) Parse(s string) (model.Token, error) {
 	tokenString := strings.TrimSpace(s)
 
-	token, err := jwt.ParseWithClaims(tokenString, &model.Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// since we only use the one private key to sign the tokens,
-		// we also only use its public counterpart to verify them.
-		return ts.PublicKey(), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &model.Claims{}, ts.verificationKey)
 	if err != nil {
 		return nil, err
 	}
@@ -234,6 +315,12 @@ func (ts *JWTokenService) NewAccessToken(
 	if model.SliceContains(app.TokenPayload, PayloadName) {
 		payload[PayloadName] = user.Username
 	}
+	if model.SliceContains(app.TokenPayload, PayloadEmailVerified) {
+		payload[PayloadEmailVerified] = user.EmailVerified
+	}
+	if model.SliceContains(app.TokenPayload, PayloadPhoneVerified) {
+		payload[PayloadPhoneVerified] = user.PhoneVerified
+	}
 
 	scopesStr := scopes.String()
 	if requireTFA {
@@ -258,6 +345,7 @@ func (ts *JWTokenService) NewAccessToken(
 		Payload: payload,
 		Type:    model.TokenTypeAccess,
 		StandardClaims: jwt.StandardClaims{
+			Id:        xid.New().String(),
 			ExpiresAt: (now + lifespan),
 			Issuer:    ts.issuer,
 			Subject:   user.ID,
@@ -278,11 +366,35 @@ func (ts *JWTokenService) NewAccessToken(
 	return &model.JWToken{JWT: token, New: true}, nil
 }
 
-// NewRefreshToken creates new refresh token.
+// NewRefreshToken creates new refresh token, which starts new token family.
 func (ts *JWTokenService) NewRefreshToken(
 	user model.User,
 	scopes model.AllowedScopesSet,
 	app model.AppData,
+) (model.Token, error) {
+	return ts.newRefreshToken(user, scopes, app, xid.New().String())
+}
+
+// RotateRefreshToken creates new refresh token in the family of the rotated token.
+// Tokens issued before the families were introduced get the new family.
+func (ts *JWTokenService) RotateRefreshToken(
+	token model.Token,
+	user model.User,
+	scopes model.AllowedScopesSet,
+	app model.AppData,
+) (model.Token, error) {
+	familyID := token.FamilyID()
+	if len(familyID) == 0 {
+		familyID = xid.New().String()
+	}
+	return ts.newRefreshToken(user, scopes, app, familyID)
+}
+
+func (ts *JWTokenService) newRefreshToken(
+	user model.User,
+	scopes model.AllowedScopesSet,
+	app model.AppData,
+	familyID string,
 ) (model.Token, error) {
 	if !app.Active || !app.Offline {
 		return nil, ErrInvalidApp
@@ -309,10 +421,12 @@ func (ts *JWTokenService) NewRefreshToken(
 	}
 
 	claims := &model.Claims{
-		Scopes:  scopes.String(),
-		Payload: payload,
-		Type:    model.TokenTypeRefresh,
+		Scopes:   scopes.String(),
+		Payload:  payload,
+		Type:     model.TokenTypeRefresh,
+		FamilyID: familyID,
 		StandardClaims: jwt.StandardClaims{
+			Id:        xid.New().String(), // the session registry tracks refresh tokens by jti
 			ExpiresAt: (now + lifespan),
 			Issuer:    ts.issuer,
 			Subject:   user.ID,
@@ -421,6 +535,7 @@ func (ts *JWTokenService) NewInviteToken(email, role, audience string, data map[
 		Payload: data,
 		Type:    model.TokenTypeInvite,
 		StandardClaims: jwt.StandardClaims{
+			Id:        xid.New().String(),
 			ExpiresAt: now + lifespan,
 			Issuer:    ts.issuer,
 			// Subject:   u.ID(), //we are suppressing user ID, because there is not user crated yet.
@@ -450,6 +565,7 @@ func (ts *JWTokenService) NewResetToken(userID string) (model.Token, error) {
 	claims := &model.Claims{
 		Type: model.TokenTypeReset,
 		StandardClaims: jwt.StandardClaims{
+			Id:        xid.New().String(),
 			ExpiresAt: (now + lifespan),
 			Issuer:    ts.issuer,
 			Subject:   userID,
@@ -471,6 +587,37 @@ func (ts *JWTokenService) NewResetToken(userID string) (model.Token, error) {
 	return &model.JWToken{JWT: token, New: true}, nil
 }
 
+// NewEmailVerificationToken creates new token for email verification.
+// The token keeps the email, so it is not valid after the user changes the email.
+func (ts *JWTokenService) NewEmailVerificationToken(userID, email string) (model.Token, error) {
+	now := ijwt.TimeFunc().Unix()
+
+	claims := &model.Claims{
+		Payload: map[string]interface{}{"email": email},
+		Type:    model.TokenTypeVerifyEmail,
+		StandardClaims: jwt.StandardClaims{
+			Id:        xid.New().String(),
+			ExpiresAt: now + EmailVerificationTokenLifespan,
+			Issuer:    ts.issuer,
+			Subject:   userID,
+			Audience:  "identifo",
+			IssuedAt:  now,
+		},
+	}
+
+	sm := ts.jwtMethod()
+	if sm == nil {
+		return nil, errors.New("unable to creating signing method")
+	}
+
+	token := model.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+
+	return &model.JWToken{JWT: token, New: true}, nil
+}
+
 // NewWebCookieToken creates new web cookie token.
 func (ts *JWTokenService) NewWebCookieToken(u model.User) (model.Token, error) {
 	if !u.Active {
@@ -482,6 +629,7 @@ func (ts *JWTokenService) NewWebCookieToken(u model.User) (model.Token, error) {
 	claims := &model.Claims{
 		Type: model.TokenTypeWebCookie,
 		StandardClaims: jwt.StandardClaims{
+			Id:        xid.New().String(),
 			ExpiresAt: (now + lifespan),
 			Issuer:    ts.issuer,
 			Subject:   u.ID,
@@ -503,6 +651,158 @@ func (ts *JWTokenService) NewWebCookieToken(u model.User) (model.Token, error) {
 	return &model.JWToken{JWT: token, New: true}, nil
 }
 
+// NewAuthorizationCodeToken creates new short-lived OAuth 2.0 authorization code.
+// Payload keeps the authorization request data, which has to be checked on code exchange.
+func (ts *JWTokenService) NewAuthorizationCodeToken(
+	user model.User,
+	scopes model.AllowedScopesSet,
+	app model.AppData,
+	payload map[string]interface{},
+) (model.Token, error) {
+	if !app.Active {
+		return nil, ErrInvalidApp
+	}
+
+	if !user.Active {
+		return nil, ErrInvalidUser
+	}
+
+	now := ijwt.TimeFunc().Unix()
+
+	claims := &model.Claims{
+		Scopes:  scopes.String(),
+		Payload: payload,
+		Type:    model.TokenTypeAuthorizationCode,
+		StandardClaims: jwt.StandardClaims{
+			Id:        xid.New().String(),
+			ExpiresAt: (now + AuthorizationCodeLifespan),
+			Issuer:    ts.issuer,
+			Subject:   user.ID,
+			Audience:  app.ID,
+			IssuedAt:  now,
+		},
+	}
+
+	sm := ts.jwtMethod()
+	if sm == nil {
+		return nil, errors.New("unable to creating signing method")
+	}
+
+	token := model.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+
+	return &model.JWToken{JWT: token, New: true}, nil
+}
+
+// NewServiceToken creates new access token for service app, the token has no subject user.
+func (ts *JWTokenService) NewServiceToken(app model.AppData, scopes model.AllowedScopesSet) (model.Token, error) {
+	if !app.Active || app.Type != model.Service {
+		return nil, ErrInvalidApp
+	}
+
+	now := ijwt.TimeFunc().Unix()
+
+	lifespan := app.TokenLifespan
+	if lifespan == 0 {
+		lifespan = TokenLifespan
+	}
+
+	claims := &model.Claims{
+		Scopes: scopes.String(),
+		Type:   model.TokenTypeService,
+		StandardClaims: jwt.StandardClaims{
+			Id:        xid.New().String(),
+			ExpiresAt: (now + lifespan),
+			Issuer:    ts.issuer,
+			Audience:  app.ID,
+			IssuedAt:  now,
+		},
+	}
+
+	sm := ts.jwtMethod()
+	if sm == nil {
+		return nil, errors.New("unable to creating signing method")
+	}
+
+	token := model.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+
+	return &model.JWToken{JWT: token, New: true}, nil
+}
+
+// NewIDToken creates new OpenID Connect ID token.
+// User claims are included according to the granted scopes,
+// accessToken is used to calculate at_hash claim, it could be empty.
+func (ts *JWTokenService) NewIDToken(
+	user model.User,
+	scopes model.AllowedScopesSet,
+	app model.AppData,
+	nonce string,
+	authTime int64,
+	accessToken string,
+) (model.Token, error) {
+	if !app.Active {
+		return nil, ErrInvalidApp
+	}
+
+	if !user.Active {
+		return nil, ErrInvalidUser
+	}
+
+	now := ijwt.TimeFunc().Unix()
+
+	lifespan := app.TokenLifespan
+	if lifespan == 0 {
+		lifespan = TokenLifespan
+	}
+
+	claims := &model.IDTokenClaims{
+		Nonce:          nonce,
+		AuthTime:       authTime,
+		UserInfoClaims: model.NewUserInfoClaims(user, scopes.Scopes()),
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: (now + lifespan),
+			Issuer:    ts.issuer,
+			Subject:   user.ID,
+			Audience:  app.ID,
+			IssuedAt:  now,
+		},
+	}
+
+	if len(accessToken) > 0 {
+		claims.AtHash = accessTokenHash(accessToken)
+	}
+
+	sm := ts.jwtMethod()
+	if sm == nil {
+		return nil, errors.New("unable to creating signing method")
+	}
+
+	token := &jwt.Token{
+		Header: map[string]interface{}{
+			"typ": "JWT",
+			"alg": sm.Alg(),
+			"kid": ts.KeyID(),
+		},
+		Claims: claims,
+		Method: sm,
+	}
+
+	return &model.JWToken{JWT: token, New: true}, nil
+}
+
+// accessTokenHash returns at_hash value, it is the left-most half of the SHA-256 hash of the token,
+// as both RS256 and ES256 use SHA-256.
+// https://openid.net/specs/openid-connect-core-1_0.html#CodeIDToken
+func accessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
 // String returns string representation of a token.
 func (ts *JWTokenService) String(t model.Token) (string, error) {
 	token, ok := t.(*model.JWToken)
@@ -517,10 +817,27 @@ func (ts *JWTokenService) String(t model.Token) (string, error) {
 		return "", model.ErrTokenInvalid
 	}
 
+	// ES256 signature is not deterministic, so the token signed once keeps its string representation,
+	// otherwise the string saved to the token storage will not match the one returned to the client.
+	if len(token.JWT.Raw) > 0 {
+		return token.JWT.Raw, nil
+	}
+
+	ts.keysLock.RLock()
+	defer ts.keysLock.RUnlock()
+
+	// the key could be rotated after the token has been created, sign it with the current active key.
+	if kid, _ := token.JWT.Header["kid"].(string); kid != ts.keyID {
+		token.JWT.Method = jwtMethod(ts.algorithm)
+		token.JWT.Header["alg"] = ts.algorithm
+		token.JWT.Header["kid"] = ts.keyID
+	}
+
 	str, err := token.JWT.SignedString(ts.privateKey)
 	if err != nil {
 		return "", err
 	}
+	token.JWT.Raw = str
 	return str, nil
 }
 
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	ijwt "github.com/madappgang/identifo/v2/jwt"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// KeyRingSyncInterval is how often the key ring is reloaded from the key storage,
+// so the keys rotated by other instances are picked up, and scheduled rotation is checked.
+var KeyRingSyncInterval = time.Minute
+
+// KeyRotator keeps the signing keys ring in the key storage and the token service in sync,
+// and rotates the keys on schedule if the scheduled rotation is enabled.
+type KeyRotator struct {
+	logger       *slog.Logger
+	storage      model.KeyStorage
+	tokenService model.TokenService
+	settings     model.KeyRotationSettings
+
+	lock sync.Mutex
+	ring model.KeyRing
+	done chan struct{}
+	once sync.Once
+}
+
+// NewKeyRotator loads the key ring from the key storage and applies it to the token service.
+// If the key ring has not been saved yet, it is created from the private key.
+// The key ring is synced with the key storage in the background, so the keys rotated or replaced by other instances
+// are picked up. With scheduled rotation enabled the next key is generated in advance and the keys are rotated on schedule.
+func NewKeyRotator(
+	logger *slog.Logger,
+	storage model.KeyStorage,
+	tokenService model.TokenService,
+	settings model.KeyRotationSettings,
+) (*KeyRotator, error) {
+	kr := &KeyRotator{
+		logger:       logger,
+		storage:      storage,
+		tokenService: tokenService,
+		settings:     settings,
+		done:         make(chan struct{}),
+	}
+
+	ring, err := storage.LoadKeyRing()
+	if errors.Is(err, model.ErrorNotFound) {
+		ring, err = kr.initialKeyRing()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ring.Next(); !ok && settings.Enabled {
+		next, err := kr.generateKey(ring)
+		if err != nil {
+			return nil, err
+		}
+		next.Status = model.KeyStatusNext
+		ring.Keys = append(ring.Keys, next)
+		if ring.RotatedAt.IsZero() {
+			ring.RotatedAt = time.Now()
+		}
+
+		if err := storage.SaveKeyRing(ring); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := kr.apply(ring); err != nil {
+		return nil, err
+	}
+
+	go kr.run()
+
+	return kr, nil
+}
+
+// initialKeyRing creates the key ring with the private key as the only active key.
+func (kr *KeyRotator) initialKeyRing() (model.KeyRing, error) {
+	key, err := kr.storage.LoadPrivateKey()
+	if err != nil {
+		return model.KeyRing{}, err
+	}
+
+	active, err := ijwt.NewKeyRingKey(key, time.Now())
+	if err != nil {
+		return model.KeyRing{}, err
+	}
+	active.Status = model.KeyStatusActive
+
+	return model.KeyRing{Keys: []model.KeyRingKey{active}}, nil
+}
+
+// generateKey generates the new key with the same algorithm as the active key.
+func (kr *KeyRotator) generateKey(ring model.KeyRing) (model.KeyRingKey, error) {
+	alg := model.TokenSignatureAlgorithmES256
+	if active, ok := ring.Active(); ok && active.Algorithm == "RS256" {
+		alg = model.TokenSignatureAlgorithmRS256
+	}
+
+	key, err := ijwt.GenerateNewPrivateKey(alg)
+	if err != nil {
+		return model.KeyRingKey{}, err
+	}
+
+	return ijwt.NewKeyRingKey(key, time.Now())
+}
+
+func (kr *KeyRotator) apply(ring model.KeyRing) error {
+	if err := kr.tokenService.SetKeyRing(ring); err != nil {
+		return err
+	}
+	kr.ring = ring
+	return nil
+}
+
+func (kr *KeyRotator) retiredKeyLifespan() time.Duration {
+	return time.Duration(kr.settings.RetiredKeyLifespan) * time.Second
+}
+
+// KeyRing returns the current key ring.
+func (kr *KeyRotator) KeyRing() model.KeyRing {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+
+	return kr.ring
+}
+
+// Rotate promotes the next key to active, retires the active key and generates the new next key.
+func (kr *KeyRotator) Rotate() (model.KeyRing, error) {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+
+	return kr.rotate()
+}
+
+func (kr *KeyRotator) rotate() (model.KeyRing, error) {
+	newKey, err := kr.generateKey(kr.ring)
+	if err != nil {
+		return kr.ring, err
+	}
+
+	ring := kr.ring.Rotate(newKey, kr.retiredKeyLifespan(), time.Now())
+	if err := kr.storage.SaveKeyRing(ring); err != nil {
+		return kr.ring, fmt.Errorf("cannot save key ring: %w", err)
+	}
+	if err := kr.apply(ring); err != nil {
+		return kr.ring, err
+	}
+
+	active, _ := ring.Active()
+	kr.logger.Info("Signing keys have been rotated",
+		"kid", active.ID)
+	return ring, nil
+}
+
+// ReplaceActiveKey makes the key active immediately, the former active key is retired,
+// so the tokens signed with it are still valid.
+func (kr *KeyRotator) ReplaceActiveKey(keyPEM []byte) (model.KeyRing, error) {
+	key, _, err := ijwt.LoadPrivateKeyFromPEMString(string(keyPEM))
+	if err != nil {
+		return model.KeyRing{}, err
+	}
+
+	newKey, err := ijwt.NewKeyRingKey(key, time.Now())
+	if err != nil {
+		return model.KeyRing{}, err
+	}
+
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+
+	ring := kr.ring.ReplaceActive(newKey, kr.retiredKeyLifespan(), time.Now())
+	if err := kr.storage.SaveKeyRing(ring); err != nil {
+		return kr.ring, fmt.Errorf("cannot save key ring: %w", err)
+	}
+	if err := kr.apply(ring); err != nil {
+		return kr.ring, err
+	}
+
+	kr.logger.Info("Active signing key has been replaced",
+		"kid", newKey.ID)
+	return ring, nil
+}
+
+// Close stops the key ring sync and scheduled rotation.
+func (kr *KeyRotator) Close() {
+	kr.once.Do(func() {
+		close(kr.done)
+	})
+}
+
+func (kr *KeyRotator) run() {
+	ticker := time.NewTicker(KeyRingSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-kr.done:
+			return
+		case <-ticker.C:
+			if err := kr.sync(time.Now()); err != nil {
+				kr.logger.Error("Error syncing signing keys ring", logging.FieldError, err)
+			}
+		}
+	}
+}
+
+// sync reloads the key ring, which could be rotated by another instance,
+// and rotates the keys if the scheduled rotation is enabled and the rotation interval has passed.
+func (kr *KeyRotator) sync(now time.Time) error {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+
+	ring, err := kr.storage.LoadKeyRing()
+	if err != nil && !errors.Is(err, model.ErrorNotFound) {
+		return err
+	}
+	// the ring is applied even if it has not been rotated, so the expired keys are not used anymore
+	if err == nil && !ring.RotatedAt.Before(kr.ring.RotatedAt) {
+		if err := kr.apply(ring); err != nil {
+			return err
+		}
+	}
+
+	if !kr.settings.Enabled {
+		return nil
+	}
+
+	interval := time.Duration(kr.settings.Interval) * time.Second
+	if now.Sub(kr.ring.RotatedAt) < interval {
+		return nil
+	}
+
+	_, err = kr.rotate()
+	return err
+}
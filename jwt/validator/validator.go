@@ -70,6 +70,16 @@ func NewConfig() Config {
 	}
 }
 
+// NewServiceConfig creates and returns config to validate service app tokens,
+// issued with client credentials grant. Such tokens have no subject user.
+func NewServiceConfig() Config {
+	return Config{
+		TokenType:          []string{model.TokenTypeService},
+		IsAudienceRequired: true,
+		IsIssuerRequired:   true,
+	}
+}
+
 // NewValidator creates new JWT tokens validator.
 // Arguments:
 // - appID - application ID which have made the request, should be in audience field of JWT token.
@@ -75,6 +75,20 @@ const (
 	ErrorAPILoginCodeInvalid LocalizedString = "error.api.login.code.invalid"
 	// ErrorAPILoginAnonymousForbidden -> Anonymous login is forbidden for this app.
 	ErrorAPILoginAnonymousForbidden LocalizedString = "error.api.login.anonymous.forbidden"
+	// ErrorAPILoginLocked -> Too many failed login attempts. Please try again in %v.
+	ErrorAPILoginLocked LocalizedString = "error.api.login.locked"
+	// ErrorAPILoginCodeInvalidAttempts -> The code you entered is incorrect. Attempts left: %v.
+	ErrorAPILoginCodeInvalidAttempts LocalizedString = "error.api.login.code.invalid_attempts"
+	// ErrorAPIVerificationCodeCooldown -> The code has been sent recently. Please try again in %v.
+	ErrorAPIVerificationCodeCooldown LocalizedString = "error.api.verification_code.cooldown"
+	// ErrorAPIVerificationCodeAttemptsExceeded -> Too many incorrect codes entered. Please get a new one.
+	ErrorAPIVerificationCodeAttemptsExceeded LocalizedString = "error.api.verification_code.attempts_exceeded"
+	// ErrorStorageLoginAttemptsError -> Unable to access login attempts with error: %v.
+	ErrorStorageLoginAttemptsError LocalizedString = "error.storage.login_attempts.error"
+	// ErrorAPILoginEmailNotVerified -> Please verify your email address before logging in.
+	ErrorAPILoginEmailNotVerified LocalizedString = "error.api.login.email_not_verified"
+	// ErrorAPIVerifyEmailMismatch -> Email verification link is not valid for the current user email.
+	ErrorAPIVerifyEmailMismatch LocalizedString = "error.api.verify_email.mismatch"
 	// ErrorAPIInviteEmailMismatch -> Invite email and user email are not equal.
 	ErrorAPIInviteEmailMismatch LocalizedString = "error.api.invite.email.mismatch"
 	// ErrorAPIInviteRoleMissing -> No role in invite token found.
@@ -93,6 +107,8 @@ const (
 	ErrorAPIRequest2FAPleaseEnable LocalizedString = "error.api.request.2fa.please_enable"
 	// ErrorAPIRequest2FAPleaseDisable -> Please disable two-factor authentication.
 	ErrorAPIRequest2FAPleaseDisable LocalizedString = "error.api.request.2fa.please_disable"
+	// ErrorAPIRequest2FARequired -> Please pass two-factor authentication first.
+	ErrorAPIRequest2FARequired LocalizedString = "error.api.request.2fa.required"
 	// ErrorAPIRequest2FAMandatory -> Two-factor authentication is required for this app.
 	ErrorAPIRequest2FAMandatory LocalizedString = "error.api.request.2fa.mandatory"
 	// ErrorAPIRequest2FADisabled -> Two-factor authentication is disabled for this app.
@@ -107,8 +123,16 @@ const (
 	ErrorAPIRequest2FAUnableToSendOtpError LocalizedString = "error.api.request.2fa.unable_to_send_OTP.error"
 	// ErrorAPIRequest2FAUnableToGenerateQrError -> Unable to create QR code with error: %v.
 	ErrorAPIRequest2FAUnableToGenerateQrError LocalizedString = "error.api.request.2fa.unable_to_generate_QR.error"
+	// ErrorAPIRequest2FAUnableToGenerateRecoveryCodesError -> Unable to create recovery codes with error: %v.
+	ErrorAPIRequest2FAUnableToGenerateRecoveryCodesError LocalizedString = "error.api.request.2fa.unable_to_generate_recovery_codes.error"
 	// ErrorAPIRequest2FAUnknownType -> Unknown TFA type: %s.
 	ErrorAPIRequest2FAUnknownType LocalizedString = "error.api.request.2fa.unknown_type"
+	// ErrorAPIRequest2FATypeNotAccepted -> Two-factor authentication with %s is not accepted by this app.
+	ErrorAPIRequest2FATypeNotAccepted LocalizedString = "error.api.request.2fa.type_not_accepted"
+	// ErrorAPIRequest2FAFactorNotEnrolled -> Two-factor authentication with %s is not enrolled.
+	ErrorAPIRequest2FAFactorNotEnrolled LocalizedString = "error.api.request.2fa.factor_not_enrolled"
+	// ErrorAPIRequest2FARecoveryCodesNotSupported -> Two-factor authentication recovery codes are not supported by the user storage.
+	ErrorAPIRequest2FARecoveryCodesNotSupported LocalizedString = "error.api.request.2fa.recovery_codes_not_supported"
 	// Error2FAResendTimeout -> Please wait before new code resend.
 	Error2FAResendTimeout LocalizedString = "error.2fa.resend.timeout"
 	// Error2FAVerifyFailError -> OTP code is invalid: %v
@@ -214,6 +238,46 @@ const (
 	// ErrorFederatedOidcDisabled -> Federated OIDC login disabled
 	ErrorFederatedOidcDisabled LocalizedString = "error.federated.oidc.disabled"
 
+	//===========================================================================
+	//  OAuth 2.0
+	//===========================================================================
+	// ErrorOauthClientInvalid -> Invalid OAuth client %s: %v.
+	ErrorOauthClientInvalid LocalizedString = "error.oauth.client.invalid"
+	// ErrorOauthRedirectUriInvalid -> Redirect URI %s is not registered for the app.
+	ErrorOauthRedirectUriInvalid LocalizedString = "error.oauth.redirect_uri.invalid"
+	// ErrorOauthRequestInvalid -> Invalid OAuth authorization request: %v.
+	ErrorOauthRequestInvalid LocalizedString = "error.oauth.request.invalid"
+	// ErrorOauthSessionError -> Error processing OAuth authorization session: %v.
+	ErrorOauthSessionError LocalizedString = "error.oauth.session.error"
+
+	//===========================================================================
+	//  WebAuthn
+	//===========================================================================
+	// ErrorWebauthnDisabled -> WebAuthn is not supported.
+	ErrorWebauthnDisabled LocalizedString = "error.webauthn.disabled"
+	// ErrorWebauthnSessionError -> Error processing WebAuthn session: %v.
+	ErrorWebauthnSessionError LocalizedString = "error.webauthn.session.error"
+	// ErrorWebauthnRegistrationError -> Unable to register WebAuthn credential: %v.
+	ErrorWebauthnRegistrationError LocalizedString = "error.webauthn.registration.error"
+	// ErrorWebauthnLoginError -> WebAuthn authentication failed: %v.
+	ErrorWebauthnLoginError LocalizedString = "error.webauthn.login.error"
+	// ErrorWebauthnCredentialNotFound -> WebAuthn credential not found.
+	ErrorWebauthnCredentialNotFound LocalizedString = "error.webauthn.credential.not_found"
+	// ErrorWebauthnNoCredentials -> Please register a security key or passkey first.
+	ErrorWebauthnNoCredentials LocalizedString = "error.webauthn.no_credentials"
+	// ErrorStorageWebauthnError -> WebAuthn credentials storage error: %v.
+	ErrorStorageWebauthnError LocalizedString = "error.storage.webauthn.error"
+
+	//===========================================================================
+	//  User sessions
+	//===========================================================================
+	// ErrorSessionNotFound -> Session not found.
+	ErrorSessionNotFound LocalizedString = "error.session.not_found"
+	// ErrorSessionRevoked -> The session of the token has been ended.
+	ErrorSessionRevoked LocalizedString = "error.session.revoked"
+	// ErrorStorageUserSessionError -> User sessions storage error: %v.
+	ErrorStorageUserSessionError LocalizedString = "error.storage.user_session.error"
+
 	//===========================================================================
 	//  Storages
 	//===========================================================================
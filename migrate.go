@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/migration"
+	"gopkg.in/yaml.v2"
+)
+
+const migrateUsage = `Usage: identifo migrate -from source.yaml -to destination.yaml [options]
+
+Copies apps, management keys, users and invites from one database to another.
+The source and destination files hold the database settings, the same as the storage settings of the server config:
+
+  type: boltdb
+  boltdb:
+    path: ./db.db
+
+The progress is saved in the state file after each batch, run the same command to continue the interrupted migration.
+Options:
+`
+
+// runMigrate runs "identifo migrate" subcommand and returns the exit code.
+func runMigrate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, migrateUsage)
+		fs.PrintDefaults()
+	}
+	from := fs.String("from", "", "The file with the source database settings")
+	to := fs.String("to", "", "The file with the destination database settings")
+	batch := fs.Int("batch", migration.DefaultBatchSize, "The number of records read from the source at once")
+	statePath := fs.String("state", "identifo-migration-state.json", "The file to keep the progress, empty to disable resuming")
+	report := fs.String("report", "", "The file to write the JSON report")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return 2
+	}
+
+	logger := logging.NewDefaultLogger()
+
+	fromSettings, err := loadDatabaseSettings(*from)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to load source database settings: %v\n", err)
+		return 1
+	}
+	toSettings, err := loadDatabaseSettings(*to)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to load destination database settings: %v\n", err)
+		return 1
+	}
+
+	if reflect.DeepEqual(fromSettings, toSettings) {
+		fmt.Fprintln(stderr, "The source and destination databases are the same.")
+		return 1
+	}
+
+	state, err := migration.LoadState(*statePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to load migration state: %v\n", err)
+		return 1
+	}
+	if state.Completed {
+		fmt.Fprintf(stdout, "The migration has been completed already, verifying the data only. Remove the %s file to migrate again.\n", *statePath)
+	}
+
+	source, err := migration.OpenStorages(logger, fromSettings)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to open source database: %v\n", err)
+		return 1
+	}
+	defer source.Close()
+
+	destination, err := migration.OpenStorages(logger, toSettings)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to open destination database: %v\n", err)
+		return 1
+	}
+	defer destination.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	r, err := migration.Migrate(ctx, source, destination, migration.Options{
+		BatchSize: *batch,
+		StatePath: *statePath,
+		Logger:    logger,
+	})
+	r.Print(stdout)
+
+	if *report != "" {
+		if werr := writeMigrationReport(*report, r); werr != nil {
+			fmt.Fprintf(stderr, "Unable to write report: %v\n", werr)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "The migration has been interrupted: %v\nRun the same command to continue.\n", err)
+		return 1
+	}
+	if !r.OK() {
+		fmt.Fprintln(stderr, "The migration has finished with errors, see the report.")
+		return 3
+	}
+	fmt.Fprintln(stdout, "The migration has finished successfully.")
+	return 0
+}
+
+func loadDatabaseSettings(path string) (model.DatabaseSettings, error) {
+	var settings model.DatabaseSettings
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return settings, err
+	}
+	err = yaml.Unmarshal(data, &settings)
+	return settings, err
+}
+
+func writeMigrationReport(path string, r migration.Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrorAdminAccountExists is when the admin account with the same login exists.
+var ErrorAdminAccountExists = errors.New("admin account already exists")
+
+// AdminRole is a role of the admin panel account, which grants the set of permissions.
+type AdminRole string
+
+const (
+	// AdminRoleViewer could read everything but the server settings, keys and backups.
+	AdminRoleViewer AdminRole = "viewer"
+	// AdminRoleUserManager could also manage the users, their sessions and invites.
+	AdminRoleUserManager AdminRole = "user-manager"
+	// AdminRoleAppManager could also manage the apps.
+	AdminRoleAppManager AdminRole = "app-manager"
+	// AdminRoleSuperadmin could do everything, including the management of the admin accounts.
+	AdminRoleSuperadmin AdminRole = "superadmin"
+)
+
+// AdminPermission is a permission required by the admin panel route.
+type AdminPermission string
+
+const (
+	AdminPermissionRead         AdminPermission = "read"
+	AdminPermissionManageUsers  AdminPermission = "manage_users"
+	AdminPermissionManageApps   AdminPermission = "manage_apps"
+	AdminPermissionManageServer AdminPermission = "manage_server"
+)
+
+var adminRolePermissions = map[AdminRole][]AdminPermission{
+	AdminRoleViewer:      {AdminPermissionRead},
+	AdminRoleUserManager: {AdminPermissionRead, AdminPermissionManageUsers},
+	AdminRoleAppManager:  {AdminPermissionRead, AdminPermissionManageApps},
+	AdminRoleSuperadmin:  {AdminPermissionRead, AdminPermissionManageUsers, AdminPermissionManageApps, AdminPermissionManageServer},
+}
+
+// IsValid returns true if the role is known.
+func (r AdminRole) IsValid() bool {
+	_, ok := adminRolePermissions[r]
+	return ok
+}
+
+// Permissions returns the permissions granted by the role, the unknown role grants nothing.
+func (r AdminRole) Permissions() []AdminPermission {
+	return adminRolePermissions[r]
+}
+
+// HasPermission returns true if the role grants the permission.
+func (r AdminRole) HasPermission(p AdminPermission) bool {
+	return HasAdminPermission(adminRolePermissions[r], p)
+}
+
+// AdminRolesPermissions returns the permissions granted by any of the roles, in the order of the superadmin permissions.
+func AdminRolesPermissions(roles ...AdminRole) []AdminPermission {
+	result := []AdminPermission{}
+	for _, p := range adminRolePermissions[AdminRoleSuperadmin] {
+		for _, r := range roles {
+			if r.HasPermission(p) {
+				result = append(result, p)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// HasAdminPermission returns true if the permission is in the list.
+func HasAdminPermission(permissions []AdminPermission, p AdminPermission) bool {
+	for _, ap := range permissions {
+		if ap == p {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminAccount is an account of the admin panel.
+type AdminAccount struct {
+	ID           string    `json:"id" bson:"_id"`
+	Login        string    `json:"login" bson:"login"`
+	Name         string    `json:"name,omitempty" bson:"name,omitempty"`
+	Role         AdminRole `json:"role" bson:"role"`
+	Active       bool      `json:"active" bson:"active"`
+	PasswordHash string    `json:"password_hash,omitempty" bson:"password_hash,omitempty"`
+	TOTP         AdminTOTP `json:"totp" bson:"totp"`
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// AdminTOTP is the time-based one-time password of the admin account.
+// The secret is set when the TOTP is being enabled, it is enabled after the first code is verified.
+type AdminTOTP struct {
+	Enabled bool   `json:"enabled" bson:"enabled"`
+	Secret  string `json:"secret,omitempty" bson:"secret,omitempty"`
+}
+
+// Sanitized returns the account without the password hash and TOTP secret, to be returned to the clients.
+func (a AdminAccount) Sanitized() AdminAccount {
+	a.PasswordHash = ""
+	a.TOTP.Secret = ""
+	return a
+}
+
+// NormalizeAdminLogin returns the login the admin accounts are stored and searched by, the logins are case insensitive.
+func NormalizeAdminLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+// AdminAccountStorage stores the admin panel accounts.
+// The accounts are stored as is, the password is hashed and the login is normalized by the caller.
+// ErrorNotFound is returned if there is no such account.
+type AdminAccountStorage interface {
+	AdminAccounts() ([]AdminAccount, error)
+	AdminAccountByID(id string) (AdminAccount, error)
+	AdminAccountByLogin(login string) (AdminAccount, error)
+	// AddAdminAccount saves the new account with the generated ID,
+	// ErrorAdminAccountExists is returned if the login is taken.
+	AddAdminAccount(account AdminAccount) (AdminAccount, error)
+	// UpdateAdminAccount replaces the account, the login could not be changed.
+	UpdateAdminAccount(account AdminAccount) error
+	DeleteAdminAccount(id string) error
+	Close()
+}
+
+// CheckPassword returns true if the password matches the password hash of the account.
+func (a AdminAccount) CheckPassword(password string) bool {
+	return len(a.PasswordHash) > 0 && bcrypt.CompareHashAndPassword([]byte(a.PasswordHash), []byte(password)) == nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminRolePermissions(t *testing.T) {
+	assert.True(t, AdminRoleViewer.HasPermission(AdminPermissionRead))
+	assert.False(t, AdminRoleViewer.HasPermission(AdminPermissionManageUsers))
+
+	assert.True(t, AdminRoleUserManager.HasPermission(AdminPermissionManageUsers))
+	assert.False(t, AdminRoleUserManager.HasPermission(AdminPermissionManageApps))
+
+	assert.True(t, AdminRoleAppManager.HasPermission(AdminPermissionManageApps))
+	assert.False(t, AdminRoleAppManager.HasPermission(AdminPermissionManageServer))
+
+	for _, p := range []AdminPermission{AdminPermissionRead, AdminPermissionManageUsers, AdminPermissionManageApps, AdminPermissionManageServer} {
+		assert.True(t, AdminRoleSuperadmin.HasPermission(p))
+	}
+
+	assert.False(t, AdminRole("root").IsValid())
+	assert.False(t, AdminRole("root").HasPermission(AdminPermissionRead))
+}
+
+func TestAdminAccountPassword(t *testing.T) {
+	a := AdminAccount{PasswordHash: PasswordHash("Secret-123"), TOTP: AdminTOTP{Enabled: true, Secret: "secret"}}
+	assert.True(t, a.CheckPassword("Secret-123"))
+	assert.False(t, a.CheckPassword("secret-123"))
+
+	s := a.Sanitized()
+	assert.Empty(t, s.PasswordHash)
+	assert.Empty(t, s.TOTP.Secret)
+	assert.True(t, s.TOTP.Enabled)
+}
+
+func TestAdminPanelOIDCPermissions(t *testing.T) {
+	s := AdminPanelOIDCSettings{
+		GroupRoles: map[string]AdminRole{
+			"support": AdminRoleUserManager,
+			"devs":    AdminRoleAppManager,
+		},
+	}
+
+	assert.Empty(t, s.Permissions(nil))
+	assert.Empty(t, s.Permissions([]string{"sales"}))
+	assert.Equal(t, []AdminPermission{AdminPermissionRead, AdminPermissionManageUsers}, s.Permissions([]string{"support"}))
+	assert.Equal(t,
+		[]AdminPermission{AdminPermissionRead, AdminPermissionManageUsers, AdminPermissionManageApps},
+		s.Permissions([]string{"devs", "support"}))
+
+	s.DefaultRole = AdminRoleViewer
+	assert.Equal(t, []AdminPermission{AdminPermissionRead}, s.Permissions([]string{"sales"}))
+}
+
+func TestAdminPanelOIDCValidate(t *testing.T) {
+	s := AdminPanelOIDCSettings{}
+	assert.NoError(t, s.Validate())
+
+	s.Enabled = true
+	assert.Error(t, s.Validate())
+
+	s.ProviderURL = "https://idp.example.com"
+	s.ClientID = "identifo"
+	assert.NoError(t, s.Validate())
+
+	s.DefaultRole = AdminRoleViewer
+	assert.Error(t, s.Validate())
+	s.AllowedDomains = []string{"example.com"}
+	assert.NoError(t, s.Validate())
+
+	s.GroupRoles = map[string]AdminRole{"admins": "root"}
+	assert.Error(t, s.Validate())
+}
+
+func TestAdminPanelOIDCIsAllowed(t *testing.T) {
+	s := AdminPanelOIDCSettings{}
+	assert.True(t, s.IsAllowed("anyone@gmail.com", nil))
+
+	s.AllowedDomains = []string{"example.com"}
+	assert.True(t, s.IsAllowed("admin@Example.com", nil))
+	assert.False(t, s.IsAllowed("admin@gmail.com", nil))
+	assert.False(t, s.IsAllowed("admin@example.com@gmail.com", nil))
+	assert.False(t, s.IsAllowed("admin", nil))
+
+	s.RequiredGroups = []string{"staff"}
+	assert.False(t, s.IsAllowed("admin@example.com", []string{"sales"}))
+	assert.True(t, s.IsAllowed("admin@example.com", []string{"sales", "staff"}))
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"io"
 	"time"
 )
 
@@ -15,6 +16,7 @@ type AppStorage interface {
 	FetchApps(filter string) ([]AppData, error)
 	DeleteApp(id string) error
 	ImportJSON(data []byte, cleanOldData bool) error
+	ExportJSON(w io.Writer) error
 	TestDatabaseConnection() error
 	Close()
 }
@@ -33,6 +35,7 @@ type AppData struct {
 	LoginAppSettings     *LoginWebAppSettings `bson:"login_app_settings" json:"login_app_settings"` // Rewrite login app settings for custom login, reset password and other settings
 	TFAStatus            TFAStatus            `bson:"tfa_status" json:"tfa_status"`
 	DebugTFACode         string               `bson:"debug_tfa_code" json:"debug_tfa_code"`
+	TFATypes             []TFAType            `bson:"tfa_types" json:"tfa_types"` // TFATypes is the list of second factors the app accepts. If it's empty, all of them are accepted.
 	CustomEmailTemplates bool                 `bson:"customEmailTemplates" json:"customEmailTemplates"`
 
 	// Authorization
@@ -58,6 +61,10 @@ type AppData struct {
 	AnonymousRegistrationAllowed bool     `bson:"anonymous_registration_allowed" json:"anonymous_registration_allowed"`
 	NewUserDefaultRole           string   `bson:"new_user_default_role" json:"new_user_default_role"`
 	NewUserDefaultScopes         []string `bson:"new_user_default_scopes" json:"new_user_default_scopes"`
+	RequireVerifiedEmail         bool     `bson:"require_verified_email" json:"require_verified_email"` // RequireVerifiedEmail blocks login until the user verifies the email address.
+
+	// Webhooks are the app subscriptions to the events of the app users, in addition to the server-wide ones.
+	Webhooks []WebhookSubscription `bson:"webhooks" json:"webhooks"`
 }
 
 // AppType is a type of application.
@@ -68,8 +75,15 @@ const (
 	Android AppType = "android" // Android is an Android app.
 	IOS     AppType = "ios"     // IOS is an iOS app.
 	Desktop AppType = "desktop" // Desktop is a desktop app.
+	Service AppType = "service" // Service is a machine-to-machine app, which uses client credentials grant.
 )
 
+// IsPublicClient returns true if the app is an OAuth 2.0 public client, which does not authenticate with the secret
+// and is protected with PKCE only. The app with the secret and the service app are confidential clients.
+func (a AppData) IsPublicClient() bool {
+	return a.Type != Service && len(a.Secret) == 0
+}
+
 // AuthorizationWay is a way of authorization supported by the application.
 type AuthorizationWay string
 
@@ -90,6 +104,19 @@ const (
 	TFAStatusDisabled  = "disabled"  // TFAStatusDisabled is when the app does not support TFA.
 )
 
+// AcceptsTFAType returns true if the users can pass two-factor authentication with the factor of the type.
+func (a AppData) AcceptsTFAType(t TFAType) bool {
+	if len(a.TFATypes) == 0 {
+		return true
+	}
+	for _, at := range a.TFATypes {
+		if at == t {
+			return true
+		}
+	}
+	return false
+}
+
 // TokenPayloadServiceType service to allow fetch additional data to include to access token
 type TokenPayloadServiceType string
 
@@ -130,6 +157,7 @@ func (a AppData) Sanitized() AppData {
 	a.AuthzPolicy = ""
 	a.TokenPayloadServiceHttpSettings = TokenPayloadServiceHttpSettings{}
 	a.TokenPayloadServicePluginSettings = TokenPayloadServicePluginSettings{}
+	a.Webhooks = nil
 	return a
 }
 
@@ -0,0 +1,140 @@
+package model
+
+import "time"
+
+// AuditOperation is an operation recorded to the audit log.
+type AuditOperation string
+
+const (
+	AuditOperationLoginWithPassword AuditOperation = "login_with_password"
+	AuditOperationLoginWithPhone    AuditOperation = "login_with_phone"
+	AuditOperationLoginWith2FA      AuditOperation = "login_with_2fa"
+	AuditOperationLoginWithRecovery AuditOperation = "login_with_recovery_code"
+	AuditOperationLoginWithWebAuthn AuditOperation = "login_with_webauthn"
+	AuditOperationRefreshToken      AuditOperation = "refresh_token"
+	AuditOperationRefreshTokenReuse AuditOperation = "refresh_token_reuse"
+	AuditOperationOIDCLogin         AuditOperation = "oidc_login"
+	AuditOperationFederatedLogin    AuditOperation = "federated_login"
+	AuditOperationRegistration      AuditOperation = "registration"
+	AuditOperationLogout            AuditOperation = "logout"
+	AuditOperationImpersonatedAs    AuditOperation = "impersonated_as"
+	AuditOperationRevokeSession     AuditOperation = "revoke_session"
+	AuditOperationLogoutAll         AuditOperation = "logout_all"
+	AuditOperationRecoveryCodes     AuditOperation = "regenerate_recovery_codes"
+
+	AuditOperationOAuthAuthorizationCode AuditOperation = "oauth_authorization_code"
+	AuditOperationClientCredentials      AuditOperation = "client_credentials"
+	AuditOperationRevokeToken            AuditOperation = "revoke_token"
+
+	AuditOperationAdminLogin              AuditOperation = "admin_login"
+	AuditOperationAdminLogout             AuditOperation = "admin_logout"
+	AuditOperationAdminOIDCLogin          AuditOperation = "admin_oidc_login"
+	AuditOperationAdminCreateApp          AuditOperation = "admin_create_app"
+	AuditOperationAdminUpdateApp          AuditOperation = "admin_update_app"
+	AuditOperationAdminDeleteApp          AuditOperation = "admin_delete_app"
+	AuditOperationAdminDeleteAllApps      AuditOperation = "admin_delete_all_apps"
+	AuditOperationAdminGenerateSecret     AuditOperation = "admin_generate_secret"
+	AuditOperationAdminCreateUser         AuditOperation = "admin_create_user"
+	AuditOperationAdminUpdateUser         AuditOperation = "admin_update_user"
+	AuditOperationAdminDeleteUser         AuditOperation = "admin_delete_user"
+	AuditOperationAdminResetUserLockout   AuditOperation = "admin_reset_user_lockout"
+	AuditOperationAdminGenerateResetToken AuditOperation = "admin_generate_reset_token"
+	AuditOperationAdminUpdateSettings     AuditOperation = "admin_update_settings"
+	AuditOperationAdminAddInvite          AuditOperation = "admin_add_invite"
+	AuditOperationAdminArchiveInvite      AuditOperation = "admin_archive_invite"
+	AuditOperationAdminUploadKeys         AuditOperation = "admin_upload_keys"
+	AuditOperationAdminRotateKeys         AuditOperation = "admin_rotate_keys"
+	AuditOperationAdminReplayWebhook      AuditOperation = "admin_replay_webhook"
+	AuditOperationAdminRevokeUserSession  AuditOperation = "admin_revoke_user_session"
+	AuditOperationAdminRevokeUserSessions AuditOperation = "admin_revoke_user_sessions"
+	AuditOperationAdminBackup             AuditOperation = "admin_backup"
+	AuditOperationAdminCreateAdmin        AuditOperation = "admin_create_admin"
+	AuditOperationAdminUpdateAdmin        AuditOperation = "admin_update_admin"
+	AuditOperationAdminDeleteAdmin        AuditOperation = "admin_delete_admin"
+	AuditOperationAdminChangePassword     AuditOperation = "admin_change_password"
+	AuditOperationAdminEnableTOTP         AuditOperation = "admin_enable_totp"
+	AuditOperationAdminDisableTOTP        AuditOperation = "admin_disable_totp"
+
+	AuditOperationManagementInviteToken        AuditOperation = "management_invite_token"
+	AuditOperationManagementResetPasswordToken AuditOperation = "management_reset_password_token"
+)
+
+// AuditSource is a part of the server which has recorded the operation.
+type AuditSource string
+
+const (
+	AuditSourceAPI        AuditSource = "api"
+	AuditSourceAdmin      AuditSource = "admin"
+	AuditSourceManagement AuditSource = "management"
+)
+
+// AuditResult is a result of the recorded operation.
+type AuditResult string
+
+const (
+	AuditResultSuccess AuditResult = "success"
+	AuditResultFailure AuditResult = "failure"
+)
+
+// AuditRecord is a record of the audit log.
+type AuditRecord struct {
+	ID         string         `json:"id" bson:"_id"`
+	Time       time.Time      `json:"time" bson:"time"`
+	Source     AuditSource    `json:"source" bson:"source"`
+	Operation  AuditOperation `json:"operation" bson:"operation"`
+	Result     AuditResult    `json:"result" bson:"result"`
+	Status     int            `json:"status,omitempty" bson:"status,omitempty"`
+	Error      string         `json:"error,omitempty" bson:"error,omitempty"`
+	UserID     string         `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	AppID      string         `json:"app_id,omitempty" bson:"app_id,omitempty"`
+	Resource   string         `json:"resource,omitempty" bson:"resource,omitempty"`
+	Actor      string         `json:"actor,omitempty" bson:"actor,omitempty"` // Actor is an admin or a management key which has made the change.
+	IP         string         `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent  string         `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	Issuer     string         `json:"issuer,omitempty" bson:"issuer,omitempty"`
+	AccessRole string         `json:"access_role,omitempty" bson:"access_role,omitempty"`
+	Scopes     []string       `json:"scopes,omitempty" bson:"scopes,omitempty"`
+	// tokens are masked according to the audit token recording settings
+	AccessToken  string `json:"access_token,omitempty" bson:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty" bson:"refresh_token,omitempty"`
+}
+
+// AuditFilter selects audit records, empty fields match all records.
+type AuditFilter struct {
+	UserID    string
+	AppID     string
+	Operation AuditOperation
+	From      time.Time // From is inclusive.
+	To        time.Time // To is exclusive.
+	Skip      int
+	Limit     int
+}
+
+// Matches returns true if the record matches the filter, skip and limit are not checked.
+func (f AuditFilter) Matches(r AuditRecord) bool {
+	if len(f.UserID) > 0 && r.UserID != f.UserID {
+		return false
+	}
+	if len(f.AppID) > 0 && r.AppID != f.AppID {
+		return false
+	}
+	if len(f.Operation) > 0 && r.Operation != f.Operation {
+		return false
+	}
+	if !f.From.IsZero() && r.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !r.Time.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// AuditStorage is a persistent audit log.
+type AuditStorage interface {
+	// AddRecord appends the record to the log, the ID is assigned by the storage.
+	AddRecord(record AuditRecord) error
+	// FetchRecords returns the records matching the filter, newest first, and the total number of matching records.
+	FetchRecords(filter AuditFilter) ([]AuditRecord, int, error)
+	Close()
+}
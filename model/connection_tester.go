@@ -5,10 +5,11 @@ type ConnectionTester interface {
 }
 
 type TestConnection struct {
-	Type        TestType             `json:"type"`
-	Database    *DatabaseSettings    `json:"database,omitempty"`
-	KeyStorage  *FileStorageSettings `json:"key_storage,omitempty"`
-	FileStorage *FileStorageSettings `json:"file_storage,omitempty"`
+	Type        TestType              `json:"type"`
+	Database    *DatabaseSettings     `json:"database,omitempty"`
+	KeyStorage  *FileStorageSettings  `json:"key_storage,omitempty"`
+	FileStorage *FileStorageSettings  `json:"file_storage,omitempty"`
+	Email       *EmailServiceSettings `json:"email_service,omitempty"`
 }
 
 // TestType is a test type
@@ -19,4 +20,5 @@ const (
 	TTKeyStorage           TestType = "key_storage"
 	TTSPAFileStorage       TestType = "spa_file_storage"
 	TTEmailTemplateStorage TestType = "email_template_file_storage"
+	TTEmailService         TestType = "email_service"
 )
@@ -7,6 +7,7 @@ const (
 	EmailTemplateTypeResetPassword EmailTemplateType = "reset-password-email"
 	EmailTemplateTypeTFAWithCode   EmailTemplateType = "tfa-code-email"
 	EmailTemplateTypeVerifyEmail   EmailTemplateType = "verify-email"
+	EmailTemplateTypeTokenReuse    EmailTemplateType = "token-reuse-email"
 	// EmailTemplateTypeWelcome       EmailTemplateType = "welcome-email"
 
 	DefaultTemplateExtension = "html"
@@ -26,6 +27,7 @@ func AllEmailTemplatesFileNames() []string {
 		EmailTemplateTypeResetPassword.FileName(),
 		EmailTemplateTypeTFAWithCode.FileName(),
 		EmailTemplateTypeVerifyEmail.FileName(),
+		EmailTemplateTypeTokenReuse.FileName(),
 		// EmailTemplateTypeWelcome.FileName(),
 	}
 }
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONArrayWriter streams the items to the writer as JSON array, one by one,
+// so the exported data is not kept in memory.
+type JSONArrayWriter struct {
+	w     io.Writer
+	count int
+}
+
+// NewJSONArrayWriter creates the writer, Close should be called to finish the array.
+func NewJSONArrayWriter(w io.Writer) *JSONArrayWriter {
+	return &JSONArrayWriter{w: w}
+}
+
+// Write writes the item as the next element of the array.
+func (aw *JSONArrayWriter) Write(item any) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if aw.count == 0 {
+		sep = "[\n"
+	}
+	if _, err := io.WriteString(aw.w, sep); err != nil {
+		return err
+	}
+	aw.count++
+	_, err = aw.w.Write(data)
+	return err
+}
+
+// Close finishes the array, the empty array is written if there were no items.
+func (aw *JSONArrayWriter) Close() error {
+	end := "\n]\n"
+	if aw.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(aw.w, end)
+	return err
+}
+
+// SnapshotExporter is implemented by the storages, which are able to export the consistent snapshot of their data.
+// The storages without it export the records changed during the export in either state.
+type SnapshotExporter interface {
+	// ExportsSnapshot reports whether ExportJSON reads the consistent snapshot of the data.
+	ExportsSnapshot() bool
+}
+
+// ExportUsersByPages writes all the users of the storage as JSON array, reading them page by page with FetchUsers.
+// It is used by the storages which could not read all the users at once, so the export is not a snapshot.
+func ExportUsersByPages(s UserStorage, w io.Writer, pageSize int) error {
+	aw := NewJSONArrayWriter(w)
+	for skip := 0; ; {
+		users, _, err := s.FetchUsers("", skip, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			if err := aw.Write(u); err != nil {
+				return err
+			}
+		}
+		// the storage which ignores the page size returns all the users at once.
+		if len(users) < pageSize || len(users) > pageSize {
+			return aw.Close()
+		}
+		skip += len(users)
+	}
+}
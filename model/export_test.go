@@ -0,0 +1,34 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONArrayWriter(t *testing.T) {
+	var buf bytes.Buffer
+	aw := NewJSONArrayWriter(&buf)
+	require.NoError(t, aw.Close())
+	assert.JSONEq(t, `[]`, buf.String())
+
+	buf.Reset()
+	aw = NewJSONArrayWriter(&buf)
+	require.NoError(t, aw.Write(Invite{ID: "1", Email: "a@example.com"}))
+	require.NoError(t, aw.Write(Invite{ID: "2", Email: "b@example.com"}))
+	require.NoError(t, aw.Close())
+
+	invites := []Invite{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &invites))
+	require.Len(t, invites, 2)
+	assert.Equal(t, "2", invites[1].ID)
+}
+
+func TestIsPasswordHash(t *testing.T) {
+	assert.True(t, IsPasswordHash(PasswordHash("password")))
+	assert.False(t, IsPasswordHash("password"))
+	assert.False(t, IsPasswordHash(""))
+}
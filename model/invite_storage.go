@@ -1,6 +1,7 @@
 package model
 
 import (
+	"io"
 	"time"
 )
 
@@ -12,5 +13,8 @@ type InviteStorage interface {
 	GetAll(withArchived bool, skip, limit int) ([]Invite, int, error)
 	ArchiveAllByEmail(email string) error
 	ArchiveByID(id string) error
+	// ImportJSON imports the invites as is, with their IDs, unlike Save.
+	ImportJSON(data []byte, clearOldData bool) error
+	ExportJSON(w io.Writer) error
 	Close()
 }
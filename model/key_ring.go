@@ -0,0 +1,145 @@
+package model
+
+import "time"
+
+// KeyRingName is a key ring file name, the key ring is stored next to the private key.
+const KeyRingName = "keyring.json"
+
+// KeyStatus is a status of the signing key in the key ring.
+type KeyStatus string
+
+const (
+	// KeyStatusActive is a key used to sign new tokens.
+	KeyStatusActive KeyStatus = "active"
+	// KeyStatusNext is a key which will become active on the next rotation.
+	// It is published in advance, so relying parties could cache it before the rotation.
+	KeyStatusNext KeyStatus = "next"
+	// KeyStatusRetired is a former active key, it is still published to verify tokens signed with it.
+	KeyStatusRetired KeyStatus = "retired"
+)
+
+// KeyRingKey is a signing key in the key ring.
+type KeyRingKey struct {
+	ID         string    `json:"kid"`
+	Status     KeyStatus `json:"status"`
+	Algorithm  string    `json:"alg"`
+	PrivateKey string    `json:"private_key"` // PEM encoded private key
+	CreatedAt  time.Time `json:"created_at"`
+	RetiredAt  time.Time `json:"retired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Expired returns true if the retired key should not be published anymore.
+func (k KeyRingKey) Expired(now time.Time) bool {
+	return k.Status == KeyStatusRetired && !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// KeyRing is a set of signing keys with active, next and retired keys.
+type KeyRing struct {
+	Keys      []KeyRingKey `json:"keys"`
+	RotatedAt time.Time    `json:"rotated_at"`
+}
+
+// Active returns the key used to sign new tokens.
+func (kr KeyRing) Active() (KeyRingKey, bool) {
+	return kr.withStatus(KeyStatusActive)
+}
+
+// Next returns the key which will become active on the next rotation.
+func (kr KeyRing) Next() (KeyRingKey, bool) {
+	return kr.withStatus(KeyStatusNext)
+}
+
+func (kr KeyRing) withStatus(status KeyStatus) (KeyRingKey, bool) {
+	for _, k := range kr.Keys {
+		if k.Status == status {
+			return k, true
+		}
+	}
+	return KeyRingKey{}, false
+}
+
+// Published returns all non-expired keys, their public parts should be published in JWKS.
+func (kr KeyRing) Published(now time.Time) []KeyRingKey {
+	result := []KeyRingKey{}
+	for _, k := range kr.Keys {
+		if !k.Expired(now) {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// Rotate retires the active key, promotes the next key to active and adds the new key as the next one.
+// If the ring has no next key, the new key becomes active immediately.
+// Retired keys are published for retiredLifespan, expired keys are removed from the ring.
+func (kr KeyRing) Rotate(newKey KeyRingKey, retiredLifespan time.Duration, now time.Time) KeyRing {
+	_, hasNext := kr.Next()
+
+	keys := make([]KeyRingKey, 0, len(kr.Keys)+1)
+	for _, k := range kr.Keys {
+		switch k.Status {
+		case KeyStatusActive:
+			k = retireKey(k, retiredLifespan, now)
+		case KeyStatusNext:
+			k.Status = KeyStatusActive
+		}
+		if !k.Expired(now) {
+			keys = append(keys, k)
+		}
+	}
+
+	newKey.Status = KeyStatusNext
+	if !hasNext {
+		newKey.Status = KeyStatusActive
+	}
+	keys = append(keys, newKey)
+
+	return KeyRing{Keys: keys, RotatedAt: now}
+}
+
+// ReplaceActive retires the active key and makes the new key active immediately, the next key is kept.
+func (kr KeyRing) ReplaceActive(newKey KeyRingKey, retiredLifespan time.Duration, now time.Time) KeyRing {
+	keys := make([]KeyRingKey, 0, len(kr.Keys)+1)
+	for _, k := range kr.Keys {
+		if k.ID == newKey.ID {
+			// the same key is uploaded again
+			continue
+		}
+		if k.Status == KeyStatusActive {
+			k = retireKey(k, retiredLifespan, now)
+		}
+		if !k.Expired(now) {
+			keys = append(keys, k)
+		}
+	}
+
+	newKey.Status = KeyStatusActive
+	keys = append(keys, newKey)
+
+	return KeyRing{Keys: keys, RotatedAt: now}
+}
+
+func retireKey(k KeyRingKey, retiredLifespan time.Duration, now time.Time) KeyRingKey {
+	k.Status = KeyStatusRetired
+	k.RetiredAt = now
+	k.ExpiresAt = now.Add(retiredLifespan)
+	return k
+}
+
+// PublicSigningKey is a public key used to verify token signatures.
+type PublicSigningKey struct {
+	ID        string
+	Algorithm string
+	Key       interface{} // *ecdsa.PublicKey, or *rsa.PublicKey
+}
+
+// KeyRotationService manages the signing keys ring and rotates the keys.
+type KeyRotationService interface {
+	KeyRing() KeyRing
+	// Rotate promotes the next key to active and generates the new next key.
+	Rotate() (KeyRing, error)
+	// ReplaceActiveKey makes the key active immediately, the former active key is retired.
+	ReplaceActiveKey(keyPEM []byte) (KeyRing, error)
+	Close()
+}
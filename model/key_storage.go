@@ -7,4 +7,8 @@ const PrivateKeyName = "private.pem"
 type KeyStorage interface {
 	ReplaceKey(keyPEM []byte) error
 	LoadPrivateKey() (interface{}, error)
+	// LoadKeyRing returns ErrorNotFound if the key ring has not been saved yet.
+	LoadKeyRing() (KeyRing, error)
+	// SaveKeyRing saves the key ring and replaces the private key with the active one.
+	SaveKeyRing(ring KeyRing) error
 }
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strings"
+	"time"
+)
+
+// LoginAttempts are failed login attempts counted for the account or the client IP address.
+type LoginAttempts struct {
+	Key          string    `json:"key" bson:"_id"`
+	Failures     int       `json:"failures" bson:"failures"`
+	LastFailedAt time.Time `json:"last_failed_at" bson:"last_failed_at"`
+	ExpiresAt    time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// LoginAttemptStorage keeps failed login attempts counters.
+// The counter is forgotten when it expires, the expiration is prolonged with every failed attempt.
+type LoginAttemptStorage interface {
+	// LoginAttempts returns the counter for the key, or the empty counter if there are no failed attempts.
+	LoginAttempts(key string) (LoginAttempts, error)
+	// AddFailedAttempt atomically increments the counter and returns it.
+	AddFailedAttempt(key string, expiresIn time.Duration) (LoginAttempts, error)
+	// ResetLoginAttempts deletes the counter.
+	ResetLoginAttempts(key string) error
+	Close()
+}
+
+// AccountLoginAttemptsKey returns login attempts counter key for the user account.
+func AccountLoginAttemptsKey(userID string) string {
+	return "account:" + userID
+}
+
+// IdentifierLoginAttemptsKey returns login attempts counter key for the email, phone or username of the unknown user.
+// The identifier is hashed, so the storage does not keep the identifiers typed by the clients.
+func IdentifierLoginAttemptsKey(identifier string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(identifier))))
+	return "login:" + hex.EncodeToString(sum[:])
+}
+
+// IPLoginAttemptsKey returns login attempts counter key for the client IP address.
+func IPLoginAttemptsKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LockedUntil returns the time the login is locked until after the failed attempts,
+// or zero time if the failures are below the threshold.
+// Every failure above the threshold doubles the lockout duration, up to the max lockout duration.
+func (s LoginLockoutSettings) LockedUntil(a LoginAttempts, threshold int) time.Time {
+	if threshold <= 0 || a.Failures < threshold {
+		return time.Time{}
+	}
+
+	lockout := time.Duration(s.LockoutDuration) * time.Second
+	maxLockout := time.Duration(s.MaxLockoutDuration) * time.Second
+
+	// 2^62 seconds overflows the duration anyway
+	exp := math.Min(float64(a.Failures-threshold), 62)
+	duration := time.Duration(float64(lockout) * math.Pow(2, exp))
+	if duration <= 0 || duration > maxLockout {
+		duration = maxLockout
+	}
+	return a.LastFailedAt.Add(duration)
+}
+
+// AccountLockedUntil returns the time the account is locked until.
+func (s LoginLockoutSettings) AccountLockedUntil(a LoginAttempts) time.Time {
+	return s.LockedUntil(a, s.MaxAccountFailures)
+}
+
+// IPLockedUntil returns the time the client IP address is locked until.
+func (s LoginLockoutSettings) IPLockedUntil(a LoginAttempts) time.Time {
+	return s.LockedUntil(a, s.MaxIPFailures)
+}
+
+// FailureWindowDuration returns how long failed attempts are remembered.
+func (s LoginLockoutSettings) FailureWindowDuration() time.Duration {
+	return time.Duration(s.FailureWindow) * time.Second
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLockoutSettingsLockedUntil(t *testing.T) {
+	s := LoginLockoutSettings{
+		MaxAccountFailures: 3,
+		MaxIPFailures:      10,
+		LockoutDuration:    60,
+		MaxLockoutDuration: 300,
+	}
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Minute},
+		{failures: 4, want: 2 * time.Minute},
+		{failures: 5, want: 4 * time.Minute},
+		{failures: 6, want: 5 * time.Minute},
+		{failures: 100, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		until := s.AccountLockedUntil(LoginAttempts{Failures: tt.failures, LastFailedAt: last})
+		if tt.want == 0 {
+			assert.True(t, until.IsZero(), "failures %d", tt.failures)
+			continue
+		}
+		assert.Equal(t, last.Add(tt.want), until, "failures %d", tt.failures)
+	}
+
+	assert.True(t, s.IPLockedUntil(LoginAttempts{Failures: 9, LastFailedAt: last}).IsZero())
+	assert.Equal(t, last.Add(time.Minute), s.IPLockedUntil(LoginAttempts{Failures: 10, LastFailedAt: last}))
+}
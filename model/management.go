@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
@@ -14,6 +15,7 @@ type ManagementKeysStorage interface {
 	ChangeScopesForKey(ctx context.Context, id string, scopes []string) (ManagementKey, error)
 	UseKey(ctx context.Context, id string) (ManagementKey, error)
 	ImportJSON(data []byte, clearOldData bool) error
+	ExportJSON(w io.Writer) error
 
 	GeyAllKeys(ctx context.Context) ([]ManagementKey, error)
 }
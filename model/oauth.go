@@ -0,0 +1,48 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// OAuth 2.0 grant types supported by the token endpoint.
+const (
+	OAuthGrantTypeAuthorizationCode = "authorization_code"
+	OAuthGrantTypeClientCredentials = "client_credentials"
+)
+
+// OAuth 2.0 response types supported by the authorization endpoint.
+const (
+	OAuthResponseTypeCode = "code"
+)
+
+// PKCE code challenge methods, as referenced at
+// https://datatracker.ietf.org/doc/html/rfc7636#section-4.3
+const (
+	CodeChallengeMethodS256 = "S256"
+)
+
+// OAuthAuthorizationRequest keeps the authorization request data
+// while the user is logging in with the login web app.
+type OAuthAuthorizationRequest struct {
+	AppID               string   `json:"app_id"`
+	RedirectURI         string   `json:"redirect_uri"`
+	State               string   `json:"state,omitempty"`
+	Nonce               string   `json:"nonce,omitempty"`
+	Scopes              []string `json:"scopes,omitempty"`
+	CodeChallenge       string   `json:"code_challenge"`
+	CodeChallengeMethod string   `json:"code_challenge_method"`
+}
+
+// Marshal the authorization request into a string.
+func (r OAuthAuthorizationRequest) Marshal() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// UnmarshalOAuthAuthorizationRequest will unmarshal a JSON string into an authorization request.
+func UnmarshalOAuthAuthorizationRequest(data string) (*OAuthAuthorizationRequest, error) {
+	req := &OAuthAuthorizationRequest{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(req)
+	return req, err
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// OpenID Connect standard scopes, as referenced at
+// https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims
+const (
+	OIDCScopeOpenID  = "openid"
+	OIDCScopeProfile = "profile"
+	OIDCScopeEmail   = "email"
+	OIDCScopePhone   = "phone"
+)
+
+// OIDCScopes is a list of all OpenID Connect standard scopes supported by Identifo.
+var OIDCScopes = []string{OIDCScopeOpenID, OIDCScopeProfile, OIDCScopeEmail, OIDCScopePhone}
+
+// OIDCClaimsSupported is a list of claims Identifo is able to supply in id_token and userinfo.
+var OIDCClaimsSupported = []string{
+	"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash",
+	"name", "preferred_username", "email", "email_verified", "phone_number", "phone_number_verified",
+}
+
+// UserInfoClaims are standard OpenID Connect claims about the user.
+type UserInfoClaims struct {
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	// verified flags are pointers to keep false values, they are omitted if the user has no email or phone.
+	EmailVerified       *bool  `json:"email_verified,omitempty"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool  `json:"phone_number_verified,omitempty"`
+}
+
+// NewUserInfoClaims returns user claims allowed by the granted scopes.
+func NewUserInfoClaims(u User, scopes []string) UserInfoClaims {
+	c := UserInfoClaims{}
+
+	if SliceContains(scopes, OIDCScopeProfile) {
+		c.Name = u.FullName
+		c.PreferredUsername = u.Username
+	}
+
+	if SliceContains(scopes, OIDCScopeEmail) {
+		c.Email = u.Email
+		if len(u.Email) > 0 {
+			verified := u.EmailVerified
+			c.EmailVerified = &verified
+		}
+	}
+
+	if SliceContains(scopes, OIDCScopePhone) {
+		c.PhoneNumber = u.Phone
+		if len(u.Phone) > 0 {
+			verified := u.PhoneVerified
+			c.PhoneNumberVerified = &verified
+		}
+	}
+
+	return c
+}
+
+// IDTokenClaims is a claims set of OpenID Connect ID token.
+type IDTokenClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	AtHash   string `json:"at_hash,omitempty"`
+	UserInfoClaims
+	jwt.StandardClaims
+}
+
+// AllowedScopesWithOIDC works as AllowedScopes, and also grants requested OpenID Connect standard scopes,
+// as they are not the part of the user scopes.
+func AllowedScopesWithOIDC(requestedScopes, userScopes []string, isOffline bool) AllowedScopesSet {
+	scopes := AllowedScopes(requestedScopes, userScopes, isOffline)
+
+	for _, s := range OIDCScopes {
+		if SliceContains(requestedScopes, s) && !scopes.Contains(s) {
+			scopes.scopes = append(scopes.scopes, s)
+		}
+	}
+
+	return scopes
+}
@@ -33,6 +33,12 @@ type ServerStorageCollection struct {
 	Session       SessionStorage
 	Key           KeyStorage
 	ManagementKey ManagementKeysStorage
+	WebAuthn      WebAuthnStorage
+	LoginAttempt  LoginAttemptStorage
+	Audit         AuditStorage
+	Webhook       WebhookDeliveryStorage
+	UserSession   UserSessionStorage
+	AdminAccount  AdminAccountStorage
 	LoginAppFS    fs.FS
 	AdminPanelFS  fs.FS
 }
@@ -43,4 +49,6 @@ type ServerServices struct {
 	Token         TokenService
 	Session       SessionService
 	Impersonation ImpersonationProvider
+	KeyRotation   KeyRotationService
+	Webhook       WebhookService
 }
@@ -18,6 +18,12 @@ var DefaultServerSettings = ServerSettings{
 		VerificationCodeStorage: DatabaseSettings{Type: DBTypeDefault},
 		InviteStorage:           DatabaseSettings{Type: DBTypeDefault},
 		ManagementKeysStorage:   DatabaseSettings{Type: DBTypeDefault},
+		WebAuthnStorage:         DatabaseSettings{Type: DBTypeDefault},
+		LoginAttemptStorage:     DatabaseSettings{Type: DBTypeDefault},
+		AuditStorage:            DatabaseSettings{Type: DBTypeDefault},
+		WebhookStorage:          DatabaseSettings{Type: DBTypeDefault},
+		UserSessionStorage:      DatabaseSettings{Type: DBTypeDefault},
+		AdminAccountStorage:     DatabaseSettings{Type: DBTypeDefault},
 	},
 	SessionStorage: SessionStorageSettings{
 		Type:            SessionStorageMem,
@@ -29,6 +35,18 @@ var DefaultServerSettings = ServerSettings{
 			Path: "./jwt/test_artifacts/private.pem",
 		},
 	},
+	KeyRotation: KeyRotationSettings{
+		Enabled:            false,
+		Interval:           int64(30 * 24 * 60 * 60),  // rotate keys every month
+		RetiredKeyLifespan: int64(365 * 24 * 60 * 60), // same as the default refresh token lifespan
+	},
+	Webhooks: WebhookSettings{
+		MaxAttempts:    10,
+		InitialBackoff: 30,          // 30 seconds, 30 * 2^8 is about two hours for the last retry
+		MaxBackoff:     6 * 60 * 60, // 6 hours
+		Timeout:        10,
+		Retention:      7 * 24 * 60 * 60, // a week
+	},
 	Login: LoginSettings{
 		LoginWith: LoginWith{
 			Phone:         true,
@@ -38,6 +56,19 @@ var DefaultServerSettings = ServerSettings{
 		},
 		TFAType:          TFATypeApp,
 		TFAResendTimeout: 30,
+		Lockout: LoginLockoutSettings{
+			Enabled:            false,
+			MaxAccountFailures: 5,
+			MaxIPFailures:      20,
+			LockoutDuration:    60,
+			MaxLockoutDuration: 60 * 60,
+			FailureWindow:      24 * 60 * 60,
+		},
+		PhoneCode: PhoneCodeSettings{
+			TTL:            5 * 60,
+			MaxAttempts:    5,
+			ResendCooldown: 30,
+		},
 	},
 	Services: ServicesSettings{
 		Email: EmailServiceSettings{
@@ -92,8 +123,78 @@ func (ss *ServerSettings) RewriteDefaults() {
 	if len(ss.Storage.ManagementKeysStorage.Type) == 0 {
 		ss.Storage.ManagementKeysStorage.Type = DBTypeDefault
 	}
+	if len(ss.Storage.WebAuthnStorage.Type) == 0 {
+		ss.Storage.WebAuthnStorage.Type = DBTypeDefault
+	}
+	if len(ss.Storage.LoginAttemptStorage.Type) == 0 {
+		ss.Storage.LoginAttemptStorage.Type = DBTypeDefault
+	}
+	if len(ss.Storage.AuditStorage.Type) == 0 {
+		ss.Storage.AuditStorage.Type = DBTypeDefault
+	}
+	if len(ss.Storage.WebhookStorage.Type) == 0 {
+		ss.Storage.WebhookStorage.Type = DBTypeDefault
+	}
+	if len(ss.Storage.UserSessionStorage.Type) == 0 {
+		ss.Storage.UserSessionStorage.Type = DBTypeDefault
+	}
+	if len(ss.Storage.AdminAccountStorage.Type) == 0 {
+		ss.Storage.AdminAccountStorage.Type = DBTypeDefault
+	}
 
 	if len(ss.Storage.TokenBlacklist.Type) == 0 {
 		ss.Storage.TokenBlacklist.Type = DBTypeDefault
 	}
+
+	if ss.KeyRotation.Interval == 0 {
+		ss.KeyRotation.Interval = DefaultServerSettings.KeyRotation.Interval
+	}
+	if ss.KeyRotation.RetiredKeyLifespan == 0 {
+		ss.KeyRotation.RetiredKeyLifespan = DefaultServerSettings.KeyRotation.RetiredKeyLifespan
+	}
+
+	webhooks := &ss.Webhooks
+	if webhooks.MaxAttempts == 0 {
+		webhooks.MaxAttempts = DefaultServerSettings.Webhooks.MaxAttempts
+	}
+	if webhooks.InitialBackoff == 0 {
+		webhooks.InitialBackoff = DefaultServerSettings.Webhooks.InitialBackoff
+	}
+	if webhooks.MaxBackoff == 0 {
+		webhooks.MaxBackoff = DefaultServerSettings.Webhooks.MaxBackoff
+	}
+	if webhooks.Timeout == 0 {
+		webhooks.Timeout = DefaultServerSettings.Webhooks.Timeout
+	}
+	if webhooks.Retention == 0 {
+		webhooks.Retention = DefaultServerSettings.Webhooks.Retention
+	}
+
+	lockout := &ss.Login.Lockout
+	if lockout.MaxAccountFailures == 0 {
+		lockout.MaxAccountFailures = DefaultServerSettings.Login.Lockout.MaxAccountFailures
+	}
+	if lockout.MaxIPFailures == 0 {
+		lockout.MaxIPFailures = DefaultServerSettings.Login.Lockout.MaxIPFailures
+	}
+	if lockout.LockoutDuration == 0 {
+		lockout.LockoutDuration = DefaultServerSettings.Login.Lockout.LockoutDuration
+	}
+	if lockout.MaxLockoutDuration == 0 {
+		lockout.MaxLockoutDuration = DefaultServerSettings.Login.Lockout.MaxLockoutDuration
+	}
+	if lockout.FailureWindow == 0 {
+		lockout.FailureWindow = DefaultServerSettings.Login.Lockout.FailureWindow
+	}
+
+	phoneCode := &ss.Login.PhoneCode
+	if phoneCode.TTL == 0 {
+		phoneCode.TTL = DefaultServerSettings.Login.PhoneCode.TTL
+	}
+	if phoneCode.MaxAttempts == 0 {
+		phoneCode.MaxAttempts = DefaultServerSettings.Login.PhoneCode.MaxAttempts
+	}
+	if phoneCode.ResendCooldown == 0 {
+		phoneCode.ResendCooldown = DefaultServerSettings.Login.PhoneCode.ResendCooldown
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strings"
 )
 
 const (
@@ -35,6 +36,18 @@ func (ss *ServerSettings) Validate(rewriteDefaults bool) []error {
 	if err := ss.AdminPanel.Validate(); err != nil {
 		result = append(result, err)
 	}
+	if err := ss.KeyRotation.Validate(); err != nil {
+		result = append(result, err)
+	}
+	if err := ss.Login.Lockout.Validate(); err != nil {
+		result = append(result, err)
+	}
+	if err := ss.Login.PhoneCode.Validate(); err != nil {
+		result = append(result, err)
+	}
+	if err := ss.Webhooks.Validate(); len(err) > 0 {
+		result = append(result, err...)
+	}
 	if err := ss.EmailTemplates.Validate(); len(err) > 0 {
 		result = append(result, err...)
 	}
@@ -84,13 +97,37 @@ func (ss *StorageSettings) Validate() []error {
 	if err := ss.InviteStorage.Validate(); err != nil {
 		result = append(result, fmt.Errorf("InviteStorage settings: %s", err))
 	}
+	if err := ss.WebAuthnStorage.Validate(); err != nil {
+		result = append(result, fmt.Errorf("WebAuthnStorage settings: %s", err))
+	}
+	if err := ss.LoginAttemptStorage.Validate(); err != nil {
+		result = append(result, fmt.Errorf("LoginAttemptStorage settings: %s", err))
+	}
+	if err := ss.AuditStorage.Validate(); err != nil {
+		result = append(result, fmt.Errorf("AuditStorage settings: %s", err))
+	}
+	if err := ss.WebhookStorage.Validate(); err != nil {
+		result = append(result, fmt.Errorf("WebhookStorage settings: %s", err))
+	}
+	if err := ss.UserSessionStorage.Validate(); err != nil {
+		result = append(result, fmt.Errorf("UserSessionStorage settings: %s", err))
+	}
+	if err := ss.AdminAccountStorage.Validate(); err != nil {
+		result = append(result, fmt.Errorf("AdminAccountStorage settings: %s", err))
+	}
 	if ss.AppStorage.Type == DBTypeDefault ||
 		ss.UserStorage.Type == DBTypeDefault ||
 		ss.TokenStorage.Type == DBTypeDefault ||
 		ss.TokenBlacklist.Type == DBTypeDefault ||
 		ss.VerificationCodeStorage.Type == DBTypeDefault ||
 		ss.ManagementKeysStorage.Type == DBTypeDefault ||
-		ss.InviteStorage.Type == DBTypeDefault {
+		ss.InviteStorage.Type == DBTypeDefault ||
+		ss.WebAuthnStorage.Type == DBTypeDefault ||
+		ss.LoginAttemptStorage.Type == DBTypeDefault ||
+		ss.AuditStorage.Type == DBTypeDefault ||
+		ss.WebhookStorage.Type == DBTypeDefault ||
+		ss.UserSessionStorage.Type == DBTypeDefault ||
+		ss.AdminAccountStorage.Type == DBTypeDefault {
 		// if one of the storages is reference default storage, let' validate default storage
 		if err := ss.DefaultStorage.Validate(); err != nil {
 			result = append(result, fmt.Errorf("DefaultStorage settings: %s", err))
@@ -132,6 +169,28 @@ func (dbs *DatabaseSettings) Validate() error {
 			return fmt.Errorf("empty CMD for grpc")
 		}
 	case DBTypeGRPC:
+	case DBTypeRedis:
+		if len(dbs.Redis.Address) == 0 {
+			return fmt.Errorf("empty Redis address")
+		}
+	case DBTypeFile:
+		if len(dbs.File.Path) == 0 {
+			return fmt.Errorf("empty file path")
+		}
+	case DBTypeREST:
+		if u, err := url.ParseRequestURI(dbs.REST.URL); err != nil || !u.IsAbs() {
+			return fmt.Errorf("invalid REST user storage URL '%s'", dbs.REST.URL)
+		}
+		if len(dbs.REST.Secret) < 5 {
+			return fmt.Errorf("the REST user storage secret should be at least 5 chars long")
+		}
+		if dbs.REST.Timeout < 0 || dbs.REST.Retries < 0 {
+			return fmt.Errorf("negative REST user storage timeout or retries")
+		}
+	case DBTypePostgres, DBTypeSQLite:
+		if len(dbs.SQL.ConnectionString) == 0 {
+			return fmt.Errorf("empty SQL connection string")
+		}
 
 	default:
 		return fmt.Errorf("unsupported database type '%s'", dbs.Type)
@@ -203,7 +262,28 @@ func (sfs *FileStorageSettings) Validate() []error {
 }
 
 func (kss *AdminPanelSettings) Validate() error {
-	// not it has just one bool field which is always valid
+	return kss.OIDC.Validate()
+}
+
+// Validate validates the admin panel single sign-on settings.
+func (s *AdminPanelOIDCSettings) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if err := s.OIDCSettings().IsValid(); err != nil {
+		return fmt.Errorf("admin panel OIDC: %w", err)
+	}
+	if len(s.DefaultRole) > 0 && !s.DefaultRole.IsValid() {
+		return fmt.Errorf("admin panel OIDC: unknown default role %s", s.DefaultRole)
+	}
+	if len(s.DefaultRole) > 0 && len(s.AllowedDomains) == 0 && len(s.RequiredGroups) == 0 {
+		return fmt.Errorf("admin panel OIDC: default role requires allowed domains or required groups")
+	}
+	for g, r := range s.GroupRoles {
+		if !r.IsValid() {
+			return fmt.Errorf("admin panel OIDC: unknown role %s of group %s", r, g)
+		}
+	}
 	return nil
 }
 
@@ -261,6 +341,20 @@ func (sss *SMSServiceSettings) Validate() []error {
 		if sss.Routemobile.Region != RouteMobileRegionUAE {
 			result = append(result, fmt.Errorf("%s. Error creating RouteMobile SMS service, region %s is not supported", subject, sss.Routemobile.Region))
 		}
+	case SMSServiceHTTP:
+		if len(sss.HTTP.URL) == 0 {
+			result = append(result, fmt.Errorf("%s. Error creating HTTP SMS service, missing url", subject))
+		}
+		switch sss.HTTP.Format {
+		case "", HTTPSMSFormatJSON, HTTPSMSFormatForm:
+		default:
+			result = append(result, fmt.Errorf("%s. Error creating HTTP SMS service, unknown format %s", subject, sss.HTTP.Format))
+		}
+		if len(sss.HTTP.Fields) == 0 && len(sss.HTTP.Template) == 0 {
+			result = append(result, fmt.Errorf("%s. Error creating HTTP SMS service, missing fields or template", subject))
+		}
+	case SMSServiceFailover:
+		result = append(result, sss.Failover.Validate(subject)...)
 	default:
 		result = append(result, fmt.Errorf("%s. Unknown type", subject))
 	}
@@ -279,6 +373,11 @@ const (
 	awsSESSenderKey = "AWS_SES_SENDER"
 	// awsSESRegionKey is a name of env variable that contains AWS SWS region value.
 	awsSESRegionKey = "AWS_SES_REGION"
+
+	// smtpUsernameKey is a name of env variable that contains SMTP username.
+	smtpUsernameKey = "SMTP_USERNAME"
+	// smtpPasswordKey is a name of env variable that contains SMTP password.
+	smtpPasswordKey = "SMTP_PASSWORD"
 )
 
 // Validate validates email service settings.
@@ -326,8 +425,170 @@ func (ess *EmailServiceSettings) Validate() []error {
 		if len(ess.Mailgun.Sender) == 0 {
 			result = append(result, fmt.Errorf("%s. Empty Mailgun sender", subject))
 		}
+	case EmailServiceSMTP:
+		if username := os.Getenv(smtpUsernameKey); len(username) != 0 {
+			ess.SMTP.Username = username
+		}
+		if password := os.Getenv(smtpPasswordKey); len(password) != 0 {
+			ess.SMTP.Password = password
+		}
+		result = append(result, ess.SMTP.Validate(subject)...)
 	default:
 		result = append(result, fmt.Errorf("%s. Unknown type", subject))
 	}
 	return result
 }
+
+// Validate validates the failover list of SMS services.
+func (fs *FailoverSMSServiceSettings) Validate(subject string) []error {
+	result := []error{}
+	if len(fs.Providers) == 0 {
+		result = append(result, fmt.Errorf("%s. Error creating failover SMS service, no providers", subject))
+	}
+
+	names := map[string]bool{}
+	for _, p := range fs.Providers {
+		if len(p.Name) == 0 {
+			result = append(result, fmt.Errorf("%s. Error creating failover SMS service, provider without name", subject))
+		}
+		if names[p.Name] {
+			result = append(result, fmt.Errorf("%s. Error creating failover SMS service, duplicated provider %s", subject, p.Name))
+		}
+		names[p.Name] = true
+
+		if p.Type == SMSServiceFailover {
+			result = append(result, fmt.Errorf("%s. Error creating failover SMS service, provider %s could not be failover", subject, p.Name))
+			continue
+		}
+		for _, err := range p.Validate() {
+			result = append(result, fmt.Errorf("%s. Provider %s: %w", subject, p.Name, err))
+		}
+	}
+
+	for _, r := range fs.Routes {
+		if len(r.Prefix) < 2 || r.Prefix[0] != '+' || strings.Trim(r.Prefix[1:], "0123456789") != "" {
+			result = append(result, fmt.Errorf("%s. Error creating failover SMS service, invalid E.164 prefix %s", subject, r.Prefix))
+		}
+		if len(r.Providers) == 0 {
+			result = append(result, fmt.Errorf("%s. Error creating failover SMS service, no providers for prefix %s", subject, r.Prefix))
+		}
+		for _, name := range r.Providers {
+			if !names[name] {
+				result = append(result, fmt.Errorf("%s. Error creating failover SMS service, unknown provider %s for prefix %s", subject, name, r.Prefix))
+			}
+		}
+	}
+	return result
+}
+
+// Validate validates SMTP relay settings.
+func (s *SMTPEmailServiceSettings) Validate(subject string) []error {
+	result := []error{}
+
+	if len(s.Host) == 0 {
+		result = append(result, fmt.Errorf("%s. Empty SMTP host", subject))
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		result = append(result, fmt.Errorf("%s. Invalid SMTP port %d", subject, s.Port))
+	}
+	if len(s.From) == 0 {
+		result = append(result, fmt.Errorf("%s. Empty SMTP from address", subject))
+	}
+	switch s.Security {
+	case "", SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		result = append(result, fmt.Errorf("%s. Unknown SMTP security %s", subject, s.Security))
+	}
+	switch s.Auth {
+	case "", SMTPAuthNone:
+	case SMTPAuthPlain, SMTPAuthLogin, SMTPAuthCRAMMD5:
+		if len(s.Username) == 0 {
+			result = append(result, fmt.Errorf("%s. Empty SMTP username for %s auth", subject, s.Auth))
+		}
+	default:
+		result = append(result, fmt.Errorf("%s. Unknown SMTP auth %s", subject, s.Auth))
+	}
+	return result
+}
+
+// Validate validates key rotation settings.
+func (krs *KeyRotationSettings) Validate() error {
+	if !krs.Enabled {
+		return nil
+	}
+	if krs.Interval <= 0 {
+		return fmt.Errorf("KeyRotationSettings. Interval should be positive")
+	}
+	if krs.RetiredKeyLifespan <= 0 {
+		return fmt.Errorf("KeyRotationSettings. RetiredKeyLifespan should be positive")
+	}
+	return nil
+}
+
+// Validate validates webhook settings.
+func (ws *WebhookSettings) Validate() []error {
+	subject := "WebhookSettings"
+	result := []error{}
+
+	if ws.MaxAttempts <= 0 {
+		result = append(result, fmt.Errorf("%s. MaxAttempts should be positive", subject))
+	}
+	if ws.InitialBackoff <= 0 || ws.MaxBackoff < ws.InitialBackoff {
+		result = append(result, fmt.Errorf("%s. InitialBackoff should be positive and not greater than MaxBackoff", subject))
+	}
+	if ws.Timeout <= 0 {
+		result = append(result, fmt.Errorf("%s. Timeout should be positive", subject))
+	}
+	for i, s := range ws.Subscriptions {
+		if err := s.Validate(); err != nil {
+			result = append(result, fmt.Errorf("%s. Subscription %d: %s", subject, i, err))
+		}
+	}
+	return result
+}
+
+// Validate validates webhook subscription.
+func (s WebhookSubscription) Validate() error {
+	u, err := url.ParseRequestURI(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("URL should be absolute http or https url")
+	}
+	if len(s.Secret) < 5 {
+		return fmt.Errorf("secret should be at least 5 characters long")
+	}
+	return nil
+}
+
+// Validate validates login lockout settings.
+func (lls *LoginLockoutSettings) Validate() error {
+	if !lls.Enabled {
+		return nil
+	}
+	if lls.MaxAccountFailures < 0 || lls.MaxIPFailures < 0 {
+		return fmt.Errorf("LoginLockoutSettings. Max failures could not be negative")
+	}
+	if lls.LockoutDuration <= 0 {
+		return fmt.Errorf("LoginLockoutSettings. LockoutDuration should be positive")
+	}
+	if lls.MaxLockoutDuration < lls.LockoutDuration {
+		return fmt.Errorf("LoginLockoutSettings. MaxLockoutDuration should not be less than LockoutDuration")
+	}
+	if lls.FailureWindow <= 0 {
+		return fmt.Errorf("LoginLockoutSettings. FailureWindow should be positive")
+	}
+	return nil
+}
+
+// Validate validates phone verification code settings.
+func (pcs *PhoneCodeSettings) Validate() error {
+	if pcs.TTL <= 0 {
+		return fmt.Errorf("PhoneCodeSettings. TTL should be positive")
+	}
+	if pcs.MaxAttempts <= 0 {
+		return fmt.Errorf("PhoneCodeSettings. MaxAttempts should be positive")
+	}
+	if pcs.ResendCooldown < 0 {
+		return fmt.Errorf("PhoneCodeSettings. ResendCooldown could not be negative")
+	}
+	return nil
+}
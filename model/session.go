@@ -13,6 +13,12 @@ var ErrSessionNotFound = errors.New("Session not found. ")
 type Session struct {
 	ID             string `json:"id"`
 	ExpirationTime int64  `json:"expiration_time"`
+	AdminID        string `json:"admin_id,omitempty"` // the admin account the session belongs to
+
+	// The single sign-on admin has no account, the session keeps the login
+	// and the permissions mapped from the identity provider groups at the login.
+	AdminLogin       string            `json:"admin_login,omitempty"`
+	AdminPermissions []AdminPermission `json:"admin_permissions,omitempty"`
 }
 
 // SessionDuration wraps time.Duration to implement custom yaml and json encoding and decoding.
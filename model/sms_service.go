@@ -1,6 +1,32 @@
 package model
 
+import "net/http"
+
 // SMSService is an SMS sending service.
 type SMSService interface {
 	SendSMS(recipient, message string) error
 }
+
+// SMSRecipientError is the error of the recipient or the message, e.g. the invalid phone number.
+// The provider has handled the request, so it is not the failure of the provider.
+type SMSRecipientError struct {
+	Err error
+}
+
+func (e *SMSRecipientError) Error() string { return e.Err.Error() }
+
+func (e *SMSRecipientError) Unwrap() error { return e.Err }
+
+// SMSStatusError returns the error of the provider response with the HTTP status.
+// The client errors are the recipient errors, except the authorization, timeout and throttling ones,
+// which are the failures of the provider.
+func SMSStatusError(status int, err error) error {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return err
+	}
+	if status >= http.StatusBadRequest && status < http.StatusInternalServerError {
+		return &SMSRecipientError{Err: err}
+	}
+	return err
+}
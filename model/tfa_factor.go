@@ -0,0 +1,140 @@
+package model
+
+import "time"
+
+// TFAFactor is the second factor enrolled by the user.
+// The factor is enabled once the user has proved to own it, the SMS and email factors are enabled with the first sent code.
+type TFAFactor struct {
+	Type          TFAType   `json:"type" bson:"type"`
+	IsEnabled     bool      `json:"is_enabled" bson:"is_enabled"`
+	Secret        string    `json:"secret,omitempty" bson:"secret,omitempty"`
+	Phone         string    `json:"phone,omitempty" bson:"phone,omitempty"`
+	Email         string    `json:"email,omitempty" bson:"email,omitempty"`
+	HOTPCounter   int       `json:"hotp_counter,omitempty" bson:"hotp_counter,omitempty"`
+	HOTPExpiredAt time.Time `json:"hotp_expired_at" bson:"hotp_expired_at"`
+}
+
+// WithFactors returns the info with the single factor fields moved to the factor of the legacy type.
+// The users enrolled before the factors were introduced have used the server-wide TFA type.
+func (ti TFAInfo) WithFactors(legacyType TFAType) TFAInfo {
+	if len(ti.Factors) > 0 {
+		return ti
+	}
+	// WebAuthn has no secret, the enabled info is all that is left of it.
+	hasLegacy := ti.Secret != "" || ti.Phone != "" || ti.Email != "" ||
+		(legacyType == TFATypeWebAuthn && ti.IsEnabled)
+	if !hasLegacy || legacyType == "" {
+		return ti
+	}
+
+	f := TFAFactor{
+		Type:          legacyType,
+		IsEnabled:     ti.IsEnabled,
+		Secret:        ti.Secret,
+		Phone:         ti.Phone,
+		Email:         ti.Email,
+		HOTPCounter:   ti.HOTPCounter,
+		HOTPExpiredAt: ti.HOTPExpiredAt,
+	}
+	return TFAInfo{
+		IsEnabled:     ti.IsEnabled,
+		Factors:       []TFAFactor{f},
+		DefaultFactor: legacyType,
+	}
+}
+
+// Factor returns the enrolled factor of the type.
+func (ti TFAInfo) Factor(t TFAType) (TFAFactor, bool) {
+	for _, f := range ti.Factors {
+		if f.Type == t {
+			return f, true
+		}
+	}
+	return TFAFactor{}, false
+}
+
+// EnabledFactors returns the factors the user can be challenged with, the default one is the first.
+func (ti TFAInfo) EnabledFactors() []TFAFactor {
+	factors := []TFAFactor{}
+	for _, f := range ti.Factors {
+		if !f.IsEnabled {
+			continue
+		}
+		if f.Type == ti.DefaultFactor {
+			factors = append([]TFAFactor{f}, factors...)
+		} else {
+			factors = append(factors, f)
+		}
+	}
+	return factors
+}
+
+// SetFactor enrolls the factor, replacing the factor of the same type.
+// Two-factor authentication is enabled with the first enabled factor, which becomes the default one.
+func (ti *TFAInfo) SetFactor(f TFAFactor) {
+	factors := make([]TFAFactor, 0, len(ti.Factors)+1)
+	for _, ef := range ti.Factors {
+		if ef.Type != f.Type {
+			factors = append(factors, ef)
+		}
+	}
+	ti.Factors = append(factors, f)
+
+	if !f.IsEnabled {
+		return
+	}
+	ti.IsEnabled = true
+	if dt, ok := ti.Factor(ti.DefaultFactor); !ok || !dt.IsEnabled {
+		ti.DefaultFactor = f.Type
+	}
+}
+
+// WithSecretsFrom returns the info with the empty secrets taken from the stored info.
+// The sanitized user loses the secrets and must not reset them on update.
+// The recovery codes are taken if they are missing, the empty list is left when all of them are used.
+func (ti TFAInfo) WithSecretsFrom(stored TFAInfo) TFAInfo {
+	if ti.Secret == "" {
+		ti.Secret = stored.Secret
+	}
+	if ti.RecoveryCodes == nil {
+		ti.RecoveryCodes = stored.RecoveryCodes
+	}
+	ti.RecoveryCodesLeft = 0
+
+	factors := make([]TFAFactor, len(ti.Factors))
+	for i, f := range ti.Factors {
+		if sf, ok := stored.Factor(f.Type); ok && f.Secret == "" {
+			f.Secret = sf.Secret
+		}
+		factors[i] = f
+	}
+	if ti.Factors != nil {
+		ti.Factors = factors
+	}
+	return ti
+}
+
+// Sanitized returns the info without the secrets and one-time code counters.
+// The recovery codes are replaced with their number.
+func (ti TFAInfo) Sanitized() TFAInfo {
+	ti.Secret = ""
+	ti.HOTPCounter = 0
+	ti.HOTPExpiredAt = time.Time{}
+	if ti.RecoveryCodes != nil {
+		ti.RecoveryCodesLeft = len(ti.RecoveryCodes)
+		ti.RecoveryCodes = nil
+	}
+
+	if ti.Factors == nil {
+		return ti
+	}
+	factors := make([]TFAFactor, len(ti.Factors))
+	for i, f := range ti.Factors {
+		f.Secret = ""
+		f.HOTPCounter = 0
+		f.HOTPExpiredAt = time.Time{}
+		factors[i] = f
+	}
+	ti.Factors = factors
+	return ti
+}
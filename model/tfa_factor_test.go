@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTFAInfoWithFactors(t *testing.T) {
+	legacy := TFAInfo{IsEnabled: true, Secret: "secret"}
+
+	tfa := legacy.WithFactors(TFATypeApp)
+	require.Len(t, tfa.Factors, 1)
+	assert.Equal(t, TFAFactor{Type: TFATypeApp, IsEnabled: true, Secret: "secret"}, tfa.Factors[0])
+	assert.Equal(t, TFATypeApp, tfa.DefaultFactor)
+	assert.Empty(t, tfa.Secret)
+
+	// the info with the factors is not migrated again
+	assert.Equal(t, tfa, tfa.WithFactors(TFATypeSMS))
+
+	// enabled by admin without the enrolled factor
+	assert.Empty(t, TFAInfo{IsEnabled: true}.WithFactors(TFATypeApp).Factors)
+	assert.Len(t, TFAInfo{IsEnabled: true}.WithFactors(TFATypeWebAuthn).Factors, 1)
+}
+
+func TestTFAInfoSetFactor(t *testing.T) {
+	tfa := TFAInfo{}
+
+	tfa.SetFactor(TFAFactor{Type: TFATypeSMS, Phone: "+1555"})
+	assert.False(t, tfa.IsEnabled)
+	assert.Empty(t, tfa.DefaultFactor)
+	assert.Empty(t, tfa.EnabledFactors())
+
+	tfa.SetFactor(TFAFactor{Type: TFATypeApp, IsEnabled: true, Secret: "secret"})
+	tfa.SetFactor(TFAFactor{Type: TFATypeSMS, IsEnabled: true, Phone: "+1555"})
+	assert.True(t, tfa.IsEnabled)
+	assert.Equal(t, TFATypeApp, tfa.DefaultFactor)
+	require.Len(t, tfa.Factors, 2)
+
+	tfa.DefaultFactor = TFATypeSMS
+	enabled := tfa.EnabledFactors()
+	require.Len(t, enabled, 2)
+	assert.Equal(t, TFATypeSMS, enabled[0].Type)
+}
+
+func TestTFAInfoSecrets(t *testing.T) {
+	stored := TFAInfo{IsEnabled: true, DefaultFactor: TFATypeApp}
+	stored.SetFactor(TFAFactor{Type: TFATypeApp, IsEnabled: true, Secret: "secret", HOTPCounter: 2})
+
+	sanitized := stored.Sanitized()
+	assert.Empty(t, sanitized.Factors[0].Secret)
+	assert.Zero(t, sanitized.Factors[0].HOTPCounter)
+	assert.Equal(t, "secret", stored.Factors[0].Secret, "the stored info is not changed")
+
+	restored := sanitized.WithSecretsFrom(stored)
+	assert.Equal(t, "secret", restored.Factors[0].Secret)
+}
+
+func TestTFAInfoRecoveryCodes(t *testing.T) {
+	codes, err := NewTFARecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, TFARecoveryCodesCount)
+	assert.Regexp(t, `^[a-z2-9]{5}-[a-z2-9]{5}$`, codes[0])
+
+	tfa := TFAInfo{IsEnabled: true}
+	tfa.SetRecoveryCodes(codes)
+	assert.NotContains(t, tfa.RecoveryCodes, codes[0])
+
+	assert.False(t, tfa.UseRecoveryCode("aaaaa-aaaaa"))
+	assert.False(t, tfa.UseRecoveryCode(""))
+	assert.True(t, tfa.UseRecoveryCode(" "+strings.ToUpper(strings.Replace(codes[0], "-", " ", 1))))
+	assert.False(t, tfa.UseRecoveryCode(codes[0]), "the code is used once")
+	assert.Len(t, tfa.RecoveryCodes, TFARecoveryCodesCount-1)
+
+	assert.Equal(t, TFARecoveryCodesCount-1, tfa.Sanitized().RecoveryCodesLeft)
+
+	// the used up codes are not restored from the stored ones
+	stored := tfa
+	for _, c := range codes[1:] {
+		require.True(t, tfa.UseRecoveryCode(c))
+	}
+	assert.Empty(t, tfa.WithSecretsFrom(stored).RecoveryCodes)
+	assert.Len(t, tfa.Sanitized().WithSecretsFrom(stored).RecoveryCodes, TFARecoveryCodesCount-1)
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+const (
+	// TFARecoveryCodesCount is the number of the recovery codes generated at once.
+	TFARecoveryCodesCount = 10
+	// tfaRecoveryCodeLength is the number of the code characters, the code is shown in two groups.
+	tfaRecoveryCodeLength = 10
+)
+
+// tfaRecoveryCodeLetters have no similar looking characters, the code is typed in by the user.
+var tfaRecoveryCodeLetters = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// NewTFARecoveryCodes returns the new set of the recovery codes, like "x7kq2-m9fpa".
+func NewTFARecoveryCodes() ([]string, error) {
+	letters := big.NewInt(int64(len(tfaRecoveryCodeLetters)))
+	codes := make([]string, TFARecoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, tfaRecoveryCodeLength)
+		for j := range b {
+			n, err := rand.Int(rand.Reader, letters)
+			if err != nil {
+				return nil, err
+			}
+			b[j] = tfaRecoveryCodeLetters[n.Int64()]
+		}
+		codes[i] = string(b[:tfaRecoveryCodeLength/2]) + "-" + string(b[tfaRecoveryCodeLength/2:])
+	}
+	return codes, nil
+}
+
+// hashTFARecoveryCode returns the hash of the code, the separators and the case typed by the user are ignored.
+// The codes are random, so the hash needs no salt.
+func hashTFARecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
+
+// SetRecoveryCodes replaces the recovery codes of the user, only the hashes are kept.
+func (ti *TFAInfo) SetRecoveryCodes(codes []string) {
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = hashTFARecoveryCode(c)
+	}
+	ti.RecoveryCodes = hashes
+}
+
+// UseRecoveryCode removes the matching recovery code and returns true, the code is used once.
+// The last used code leaves the empty list, which is not replaced with the stored codes on update.
+func (ti *TFAInfo) UseRecoveryCode(code string) bool {
+	if strings.TrimSpace(code) == "" {
+		return false
+	}
+
+	hash := hashTFARecoveryCode(code)
+	for i, h := range ti.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			codes := make([]string, 0, len(ti.RecoveryCodes)-1)
+			codes = append(codes, ti.RecoveryCodes[:i]...)
+			ti.RecoveryCodes = append(codes, ti.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ConsumeRecoveryCode uses the recovery code of the user in the storage and returns the user without the code,
+// false means the code does not match.
+// The codes are replaced with compare-and-swap, so the concurrent requests could not use the same code twice.
+func ConsumeRecoveryCode(s UserStorage, userID, code string) (User, bool, error) {
+	for {
+		user, err := s.UserByID(userID)
+		if err != nil {
+			return User{}, false, err
+		}
+		old := user.TFAInfo.RecoveryCodes
+		if !user.TFAInfo.UseRecoveryCode(code) {
+			return user, false, nil
+		}
+		replaced, err := s.ReplaceRecoveryCodes(userID, old, user.TFAInfo.RecoveryCodes)
+		if err != nil || replaced {
+			return user, replaced, err
+		}
+		// the other code has been used meanwhile, the code is checked with the changed codes.
+	}
+}
@@ -8,12 +8,16 @@ import (
 )
 
 const (
-	TokenTypeInvite     = "invite"      // TokenTypeInvite is an invite token type value.
-	TokenTypeReset      = "reset"       // TokenTypeReset is an reset token type value.
-	TokenTypeWebCookie  = "web-cookie"  // TokenTypeWebCookie is a web-cookie token type value.
-	TokenTypeAccess     = "access"      // TokenTypeAccess is an access token type.
-	TokenTypeRefresh    = "refresh"     // TokenTypeRefresh is a refresh token type.
-	TokenTypeTFAPreauth = "2fa-preauth" // TokenTypeTFAPreauth is an 2fa preauth token type.
+	TokenTypeInvite      = "invite"       // TokenTypeInvite is an invite token type value.
+	TokenTypeReset       = "reset"        // TokenTypeReset is an reset token type value.
+	TokenTypeVerifyEmail = "verify-email" // TokenTypeVerifyEmail is an email verification token type value.
+	TokenTypeWebCookie   = "web-cookie"   // TokenTypeWebCookie is a web-cookie token type value.
+	TokenTypeAccess      = "access"       // TokenTypeAccess is an access token type.
+	TokenTypeRefresh     = "refresh"      // TokenTypeRefresh is a refresh token type.
+	TokenTypeTFAPreauth  = "2fa-preauth"  // TokenTypeTFAPreauth is an 2fa preauth token type.
+
+	TokenTypeAuthorizationCode = "authorization_code" // TokenTypeAuthorizationCode is an OAuth 2.0 authorization code token type.
+	TokenTypeService           = "service"            // TokenTypeService is a service app access token type, it has no subject user.
 )
 
 // StandardTokenClaims structured version of Claims Section, as referenced at
@@ -36,6 +40,7 @@ type Token interface {
 	Type() string
 	Scopes() string
 	Payload() map[string]interface{}
+	FamilyID() string
 }
 
 // NewTokenWithClaims generates new JWT token with claims and keyID.
@@ -171,12 +176,22 @@ func (t *JWToken) Scopes() string {
 	return claims.Scopes
 }
 
+// FamilyID returns the refresh token family.
+func (t *JWToken) FamilyID() string {
+	claims, ok := t.JWT.Claims.(*Claims)
+	if !ok {
+		return ""
+	}
+	return claims.FamilyID
+}
+
 // Claims is an extended claims structure.
 type Claims struct {
-	Payload map[string]interface{} `json:"payload,omitempty"`
-	Scopes  string                 `json:"scopes,omitempty"`
-	Type    string                 `json:"type,omitempty"`
-	KeyID   string                 `json:"kid,omitempty"` // optional keyID
+	Payload  map[string]interface{} `json:"payload,omitempty"`
+	Scopes   string                 `json:"scopes,omitempty"`
+	Type     string                 `json:"type,omitempty"`
+	KeyID    string                 `json:"kid,omitempty"` // optional keyID
+	FamilyID string                 `json:"fid,omitempty"` // refresh token family, kept by the rotation
 	jwt.StandardClaims
 }
 
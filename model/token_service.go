@@ -9,10 +9,16 @@ const (
 type TokenService interface {
 	NewAccessToken(u User, scopes AllowedScopesSet, app AppData, requireTFA bool, tokenPayload map[string]interface{}) (Token, error)
 	NewRefreshToken(u User, scopes AllowedScopesSet, app AppData) (Token, error)
+	// RotateRefreshToken issues new refresh token in the family of the rotated one.
+	RotateRefreshToken(token Token, u User, scopes AllowedScopesSet, app AppData) (Token, error)
 	RefreshAccessToken(token Token, tokenPayload map[string]interface{}) (Token, error)
 	NewInviteToken(email, role, audience string, data map[string]interface{}) (Token, error)
 	NewResetToken(userID string) (Token, error)
+	NewEmailVerificationToken(userID, email string) (Token, error)
 	NewWebCookieToken(u User) (Token, error)
+	NewAuthorizationCodeToken(u User, scopes AllowedScopesSet, app AppData, payload map[string]interface{}) (Token, error)
+	NewServiceToken(app AppData, scopes AllowedScopesSet) (Token, error)
+	NewIDToken(u User, scopes AllowedScopesSet, app AppData, nonce string, authTime int64, accessToken string) (Token, error)
 	Parse(string) (Token, error)
 	String(Token) (string, error)
 	Issuer() string
@@ -26,4 +32,8 @@ type TokenService interface {
 	// not using crypto.PublicKey here to avoid dependencies
 	PublicKey() interface{}
 	KeyID() string
+	// set the active key of the ring for signing, all published keys are used for verification
+	SetKeyRing(ring KeyRing) error
+	// all keys to verify tokens, the active key goes first
+	PublicKeys() []PublicSigningKey
 }
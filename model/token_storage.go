@@ -1,5 +1,14 @@
 package model
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
 // TokenStorage is a storage for issued refresh tokens.
 type TokenStorage interface {
 	SaveToken(token string) error
@@ -9,12 +18,46 @@ type TokenStorage interface {
 }
 
 // TokenBlacklist is a storage for blacklisted tokens.
+// The tokens are blacklisted by ID, see BlacklistEntry, and are kept until they expire,
+// as the expired token is rejected anyway.
 type TokenBlacklist interface {
-	IsBlacklisted(token string) bool
-	Add(token string) error
+	IsBlacklisted(id string) bool
+	// Add blacklists the token ID until expiresAt, the zero time means the token never expires.
+	// The token which has already expired is not added.
+	Add(id string, expiresAt time.Time) error
 	Close()
 }
 
+// BlacklistEntry returns the ID the token is blacklisted by and the time the token expires.
+// The ID is the jti claim, the token without jti, such as the token issued by the older version,
+// is blacklisted by the SHA-256 hash of the token.
+// The signature is not verified, the token must be verified before it is blacklisted.
+func BlacklistEntry(token string) (id string, expiresAt time.Time) {
+	claims := &Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err == nil {
+		id = claims.Id
+		if claims.ExpiresAt != 0 {
+			expiresAt = time.Unix(claims.ExpiresAt, 0)
+		}
+	}
+	if len(id) == 0 {
+		hash := sha256.Sum256([]byte(token))
+		id = hex.EncodeToString(hash[:])
+	}
+	return id, expiresAt
+}
+
+// IsLegacyBlacklistEntry returns true if the blacklist entry is the whole token, as stored by the older versions.
+// The JWT always has dots, while the token ID and hash have none.
+func IsLegacyBlacklistEntry(id string) bool {
+	return strings.Contains(id, ".")
+}
+
+// IsBlacklistEntryExpired returns true if the blacklisted token has expired and the entry could be removed.
+func IsBlacklistEntryExpired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !expiresAt.After(time.Now())
+}
+
 // JWTKeys are keys used for signing and verifying JSON web tokens.
 type JWTKeys struct {
 	Public  interface{}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlacklistEntry(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		StandardClaims: jwt.StandardClaims{Id: "jti", ExpiresAt: exp.Unix()},
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	id, expiresAt := BlacklistEntry(token)
+	assert.Equal(t, "jti", id)
+	assert.True(t, exp.Equal(expiresAt))
+	assert.True(t, IsLegacyBlacklistEntry(token))
+	assert.False(t, IsLegacyBlacklistEntry(id))
+
+	// the token without jti is blacklisted by its hash.
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	id, expiresAt = BlacklistEntry(token)
+	assert.Len(t, id, 64)
+	assert.False(t, IsLegacyBlacklistEntry(id))
+	assert.True(t, expiresAt.IsZero())
+
+	assert.False(t, IsBlacklistEntryExpired(time.Time{}))
+	assert.False(t, IsBlacklistEntryExpired(exp))
+	assert.True(t, IsBlacklistEntryExpired(time.Now().Add(-time.Second)))
+}
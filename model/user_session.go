@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// UserSession is a login session of the user on the device.
+// The session starts when the refresh token is issued on login and lasts while the refresh token is rotated,
+// RefreshTokenID is the jti of the latest refresh token of the session.
+// The session ID is the family ID of its refresh tokens.
+type UserSession struct {
+	ID             string    `json:"id" bson:"_id"`
+	UserID         string    `json:"user_id" bson:"user_id"`
+	AppID          string    `json:"app_id" bson:"app_id"`
+	UserAgent      string    `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	IP             string    `json:"ip,omitempty" bson:"ip,omitempty"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	LastRefreshAt  time.Time `json:"last_refresh_at" bson:"last_refresh_at"`
+	RefreshTokenID string    `json:"refresh_token_id" bson:"refresh_token_id"`
+	ExpiresAt      time.Time `json:"expires_at" bson:"expires_at"` // ExpiresAt is the expiration time of the latest refresh token.
+}
+
+// UserSessionStorage is a registry of user login sessions.
+// Expired sessions are never returned.
+type UserSessionStorage interface {
+	// AddSession saves the new session, the ID is assigned by the storage if it is empty.
+	AddSession(session UserSession) (UserSession, error)
+	// UpdateSession replaces the session, it is used to save the rotated refresh token.
+	UpdateSession(session UserSession) error
+	SessionByID(id string) (UserSession, error)
+	SessionByRefreshTokenID(tokenID string) (UserSession, error)
+	// UserSessions returns the sessions of the user, the latest refreshed first.
+	UserSessions(userID string) ([]UserSession, error)
+	DeleteSession(id string) error
+	// DeleteUserSessions deletes all sessions of the user and returns the number of deleted sessions.
+	DeleteUserSessions(userID string) (int, error)
+	Close()
+}
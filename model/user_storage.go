@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"io"
 	"regexp"
 	"strings"
 	"time"
@@ -37,14 +38,19 @@ type UserStorage interface {
 	DeleteUser(id string) error
 	FetchUsers(search string, skip, limit int) ([]User, int, error)
 	UpdateLoginMetadata(operation, app, userID string, scopes []string, payload map[string]any)
+	// ReplaceRecoveryCodes replaces the recovery codes of the user only if they are still the old ones,
+	// false means the codes have been changed meanwhile or there is no such user.
+	ReplaceRecoveryCodes(userID string, old, codes []string) (bool, error)
 
 	// push device tokens
 	AttachDeviceToken(userID, token string) error
 	DetachDeviceToken(token string) error
 	AllDeviceTokens(userID string) ([]string, error)
 
-	// import data
+	// import data, the password could be in plain text or the bcrypt hash, as in the exported data.
 	ImportJSON(data []byte, clearOldData bool) error
+	// ExportJSON writes all the users as JSON array, which could be imported with ImportJSON.
+	ExportJSON(w io.Writer) error
 
 	Close()
 }
@@ -57,6 +63,8 @@ type User struct {
 	Email           string   `json:"email" bson:"email"`
 	FullName        string   `json:"full_name" bson:"full_name"`
 	Phone           string   `json:"phone" bson:"phone"`
+	EmailVerified   bool     `json:"email_verified" bson:"email_verified"`
+	PhoneVerified   bool     `json:"phone_verified" bson:"phone_verified"`
 	Pswd            string   `json:"pswd" bson:"pswd"`
 	Active          bool     `json:"active" bson:"active"`
 	TFAInfo         TFAInfo  `json:"tfa_info" bson:"tfa_info"`
@@ -79,9 +87,7 @@ func maskLeft(s string, hideFraction int) string {
 // Sanitized returns data structure without sensitive information
 func (u User) Sanitized() User {
 	u.Pswd = ""
-	u.TFAInfo.Secret = ""
-	u.TFAInfo.HOTPCounter = 0
-	u.TFAInfo.HOTPExpiredAt = time.Time{}
+	u.TFAInfo = u.TFAInfo.Sanitized()
 	return u
 }
 
@@ -98,7 +104,7 @@ func (u *User) AddFederatedId(provider string, id string) string {
 
 // SanitizedTFA returns data structure with masked sensitive data
 func (u User) SanitizedTFA() User {
-	u.Sanitized()
+	u = u.Sanitized()
 	if len(u.Email) > 0 {
 		emailParts := strings.Split(u.Email, "@")
 		u.Email = maskLeft(emailParts[0], 2) + "@" + maskLeft(emailParts[1], 2)
@@ -117,6 +123,7 @@ func (u User) Deanonimized() User {
 }
 
 // TFAInfo encapsulates two-factor authentication user info.
+// The single factor fields are left from the server-wide TFA type, see WithFactors.
 type TFAInfo struct {
 	IsEnabled     bool      `json:"is_enabled" bson:"is_enabled"`
 	HOTPCounter   int       `json:"hotp_counter" bson:"hotp_counter"`
@@ -124,6 +131,14 @@ type TFAInfo struct {
 	Email         string    `json:"email" bson:"email"`
 	Phone         string    `json:"phone" bson:"phone"`
 	Secret        string    `json:"secret" bson:"secret"`
+	// Factors are the second factors enrolled by the user, one of each type.
+	Factors []TFAFactor `json:"factors,omitempty" bson:"factors,omitempty"`
+	// DefaultFactor is the type of the factor challenged when the client does not choose one.
+	DefaultFactor TFAType `json:"default_factor,omitempty" bson:"default_factor,omitempty"`
+	// RecoveryCodes are the hashes of the single-use codes passing 2FA when the factors are lost.
+	RecoveryCodes []string `json:"recovery_codes,omitempty" bson:"recovery_codes,omitempty"`
+	// RecoveryCodesLeft is the number of recovery codes of the sanitized info, it is not stored.
+	RecoveryCodesLeft int `json:"recovery_codes_left,omitempty" bson:"-"`
 }
 
 // UserFromJSON deserialize user data from JSON.
@@ -142,6 +157,12 @@ func PasswordHash(pwd string) string {
 	return string(hash)
 }
 
+// IsPasswordHash returns true if the password is already hashed with PasswordHash.
+func IsPasswordHash(pwd string) bool {
+	_, err := bcrypt.Cost([]byte(pwd))
+	return err == nil
+}
+
 // RandomPassword creates random password
 func RandomPassword(length int) string {
 	return randSeq(length)
@@ -1,8 +1,98 @@
 package model
 
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrorVerificationCodeCooldown is when the new code of the phone is requested before the resend cooldown has passed.
+	ErrorVerificationCodeCooldown = errors.New("verification code could not be resent yet")
+	// ErrorVerificationCodeInvalid is when the code does not match, the attempt is used.
+	ErrorVerificationCodeInvalid = errors.New("verification code is invalid")
+	// ErrorVerificationCodeAttemptsExceeded is when all the attempts to verify the code are used.
+	ErrorVerificationCodeAttemptsExceeded = errors.New("verification code attempts exceeded")
+	// ErrorVerificationCodeExpired is when the phone has no code, or it has expired, or it has been used.
+	ErrorVerificationCodeExpired = errors.New("verification code is expired or not found")
+)
+
+// VerificationCodePolicy are the limits of the verification code.
+type VerificationCodePolicy struct {
+	TTL            time.Duration
+	MaxAttempts    int
+	ResendCooldown time.Duration
+}
+
+// VerificationCode is the code sent to the phone.
+type VerificationCode struct {
+	Phone        string    `json:"phone" bson:"phone"`
+	Code         string    `json:"code" bson:"code"`
+	ExpiresAt    time.Time `json:"expires_at" bson:"expiresAt"`
+	ResendAt     time.Time `json:"resend_at" bson:"resendAt"`
+	AttemptsLeft int       `json:"attempts_left" bson:"attemptsLeft"`
+}
+
+// VerificationCodeStatus is what the client is told about the code of the phone.
+type VerificationCodeStatus struct {
+	ExpiresAt    time.Time
+	ResendAt     time.Time
+	AttemptsLeft int
+}
+
+// NewVerificationCode returns the code of the phone limited by the policy.
+func NewVerificationCode(phone, code string, policy VerificationCodePolicy, now time.Time) VerificationCode {
+	return VerificationCode{
+		Phone:        phone,
+		Code:         code,
+		ExpiresAt:    now.Add(policy.TTL),
+		ResendAt:     now.Add(policy.ResendCooldown),
+		AttemptsLeft: policy.MaxAttempts,
+	}
+}
+
+// Status returns the status of the code.
+func (vc VerificationCode) Status() VerificationCodeStatus {
+	return VerificationCodeStatus{
+		ExpiresAt:    vc.ExpiresAt,
+		ResendAt:     vc.ResendAt,
+		AttemptsLeft: vc.AttemptsLeft,
+	}
+}
+
+// DeleteAt returns the time the code is not needed anymore, neither to verify, nor to hold the resend cooldown.
+func (vc VerificationCode) DeleteAt() time.Time {
+	if vc.ResendAt.After(vc.ExpiresAt) {
+		return vc.ResendAt
+	}
+	return vc.ExpiresAt
+}
+
+// Check checks the code and returns the code with the attempt used if the code does not match.
+// The matching code should be deleted by the caller, so it is used once.
+func (vc VerificationCode) Check(code string, now time.Time) (VerificationCode, error) {
+	if !now.Before(vc.ExpiresAt) {
+		return vc, ErrorVerificationCodeExpired
+	}
+	if vc.AttemptsLeft <= 0 {
+		return vc, ErrorVerificationCodeAttemptsExceeded
+	}
+	if subtle.ConstantTimeCompare([]byte(vc.Code), []byte(code)) == 1 {
+		return vc, nil
+	}
+	vc.AttemptsLeft--
+	return vc, ErrorVerificationCodeInvalid
+}
+
 // VerificationCodeStorage stores verification codes linked to the phone number.
 type VerificationCodeStorage interface {
-	IsVerificationCodeFound(phone, code string) (bool, error)
-	CreateVerificationCode(phone, code string) error
+	// CreateVerificationCode saves the new code of the phone, replacing the previous one.
+	// ErrorVerificationCodeCooldown is returned with the status of the previous code if the cooldown has not passed.
+	CreateVerificationCode(phone, code string, policy VerificationCodePolicy) (VerificationCodeStatus, error)
+	// VerifyCode checks the code of the phone, the matching code is deleted so it could be used only once.
+	// The mismatch uses the attempt and ErrorVerificationCodeInvalid is returned with the attempts left,
+	// ErrorVerificationCodeAttemptsExceeded is returned if there are no attempts left,
+	// and ErrorVerificationCodeExpired if there is no code.
+	VerifyCode(phone, code string) (VerificationCodeStatus, error)
 	Close()
 }
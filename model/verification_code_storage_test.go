@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationCodeCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	policy := VerificationCodePolicy{TTL: 5 * time.Minute, MaxAttempts: 2, ResendCooldown: 10 * time.Minute}
+
+	vc := NewVerificationCode("+15550000001", "123456", policy, now)
+	assert.Equal(t, now.Add(5*time.Minute), vc.ExpiresAt)
+	assert.Equal(t, now.Add(10*time.Minute), vc.ResendAt)
+	// the code is kept for the cooldown after it has expired.
+	assert.Equal(t, vc.ResendAt, vc.DeleteAt())
+
+	checked, err := vc.Check("123456", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, checked.AttemptsLeft)
+
+	checked, err = vc.Check("000000", now)
+	assert.Equal(t, ErrorVerificationCodeInvalid, err)
+	assert.Equal(t, 1, checked.AttemptsLeft)
+
+	checked, err = checked.Check("000000", now)
+	assert.Equal(t, ErrorVerificationCodeInvalid, err)
+	assert.Equal(t, 0, checked.AttemptsLeft)
+
+	// the correct code could not be used when there are no attempts left.
+	_, err = checked.Check("123456", now)
+	assert.Equal(t, ErrorVerificationCodeAttemptsExceeded, err)
+
+	_, err = vc.Check("123456", vc.ExpiresAt)
+	assert.Equal(t, ErrorVerificationCodeExpired, err)
+}
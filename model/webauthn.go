@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// WebAuthnCredential is a WebAuthn credential (passkey or security key) registered by the user.
+type WebAuthnCredential struct {
+	ID         string   `json:"id" bson:"_id"` // base64url encoded credential id.
+	UserID     string   `json:"user_id" bson:"user_id"`
+	Name       string   `json:"name,omitempty" bson:"name,omitempty"`
+	PublicKey  []byte   `json:"public_key" bson:"public_key"` // COSE encoded public key.
+	Algorithm  int64    `json:"algorithm" bson:"algorithm"`
+	SignCount  uint32   `json:"sign_count" bson:"sign_count"`
+	AAGUID     string   `json:"aaguid,omitempty" bson:"aaguid,omitempty"`
+	Transports []string `json:"transports,omitempty" bson:"transports,omitempty"`
+	// BackupEligible is set if the credential can be synced to other devices, it never changes.
+	// It is nil for the credentials registered before it was stored, it is taken from the next assertion then.
+	BackupEligible *bool     `json:"backup_eligible,omitempty" bson:"backup_eligible,omitempty"`
+	BackupState    bool      `json:"backup_state,omitempty" bson:"backup_state,omitempty"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	LastUsedAt     time.Time `json:"last_used_at" bson:"last_used_at"`
+}
+
+// Sanitized returns the credential without the public key, to be returned to the clients.
+func (c WebAuthnCredential) Sanitized() WebAuthnCredential {
+	c.PublicKey = nil
+	return c
+}
+
+// WebAuthnSession keeps the ceremony state, the challenge first of all, between its begin and finish steps.
+// The client knows the session id only.
+type WebAuthnSession struct {
+	ID        string    `json:"id" bson:"_id"`
+	Data      string    `json:"data" bson:"data"` // JSON encoded ceremony state.
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// WebAuthnStorage stores WebAuthn credentials of the users and the sessions of the ceremonies in progress.
+type WebAuthnStorage interface {
+	AddCredential(credential WebAuthnCredential) error
+	CredentialByID(id string) (WebAuthnCredential, error)
+	CredentialsByUserID(userID string) ([]WebAuthnCredential, error)
+	UpdateCredential(credential WebAuthnCredential) error
+	DeleteCredential(id string) error
+	// SaveSession saves the session of the ceremony.
+	SaveSession(session WebAuthnSession) error
+	// TakeSession atomically returns and deletes the session, so its challenge is used once.
+	// It returns ErrorNotFound if the session does not exist or has expired.
+	TakeSession(id string) (WebAuthnSession, error)
+	Close()
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// WebhookEvent is a user lifecycle event delivered to webhook subscribers.
+type WebhookEvent string
+
+const (
+	WebhookEventUserRegistered   WebhookEvent = "user.registered"
+	WebhookEventUserLogin        WebhookEvent = "user.login"
+	WebhookEventUserEmailChanged WebhookEvent = "user.email_changed"
+	WebhookEventUserTFAEnabled   WebhookEvent = "user.tfa_enabled"
+	WebhookEventUserDeleted      WebhookEvent = "user.deleted"
+	WebhookEventInviteAccepted   WebhookEvent = "invite.accepted"
+)
+
+// WebhookSubscription is an endpoint subscribed to the events.
+// Requests are signed with the secret the same way as the HTTP token payload provider requests.
+type WebhookSubscription struct {
+	URL    string         `yaml:"url" json:"url" bson:"url"`
+	Secret string         `yaml:"secret" json:"secret" bson:"secret"`
+	Events []WebhookEvent `yaml:"events" json:"events" bson:"events"` // Events is a list of subscribed events, empty list subscribes to all events.
+}
+
+// Subscribed returns true if the subscription receives the event.
+func (s WebhookSubscription) Subscribed(event WebhookEvent) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookSettings are server-wide webhook subscriptions and delivery settings.
+type WebhookSettings struct {
+	Subscriptions []WebhookSubscription `yaml:"subscriptions" json:"subscriptions"`
+	// MaxAttempts is a number of attempts before the delivery goes to the dead letter list.
+	MaxAttempts int `yaml:"maxAttempts" json:"max_attempts"`
+	// InitialBackoff is a delay before the first retry in seconds, it is doubled with every next retry.
+	InitialBackoff int64 `yaml:"initialBackoff" json:"initial_backoff"`
+	// MaxBackoff is the max delay between retries in seconds.
+	MaxBackoff int64 `yaml:"maxBackoff" json:"max_backoff"`
+	// Timeout is a request timeout in seconds.
+	Timeout int64 `yaml:"timeout" json:"timeout"`
+	// Retention is how long the delivered and dead deliveries are kept in seconds, they are deleted after it.
+	Retention int64 `yaml:"retention" json:"retention"`
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed attempts.
+func (ws WebhookSettings) Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := float64(ws.InitialBackoff) * math.Pow(2, float64(attempts-1))
+	if delay > float64(ws.MaxBackoff) {
+		delay = float64(ws.MaxBackoff)
+	}
+	return time.Duration(delay) * time.Second
+}
+
+// WebhookPayload is a body of the webhook request.
+// ID is the same for all deliveries of the event, so receivers could use it to deduplicate events.
+type WebhookPayload struct {
+	ID     string                 `json:"id"`
+	Event  WebhookEvent           `json:"event"`
+	Time   time.Time              `json:"time"`
+	AppID  string                 `json:"app_id,omitempty"`
+	UserID string                 `json:"user_id,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// WebhookDeliveryStatus is a status of the webhook delivery.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead" // WebhookDeliveryDead is for deliveries which have run out of attempts.
+)
+
+// WebhookDelivery is a queued request to the subscribed endpoint.
+// The secret is not stored, it is taken from the subscription with the same URL at the time of sending,
+// AppID is empty for server-wide subscriptions.
+// NextAttemptAt of the delivered and dead deliveries is the time they are finished at, the retention is counted from it.
+type WebhookDelivery struct {
+	ID             string                `json:"id" bson:"_id"`
+	Event          WebhookEvent          `json:"event" bson:"event"`
+	AppID          string                `json:"app_id,omitempty" bson:"app_id,omitempty"`
+	URL            string                `json:"url" bson:"url"`
+	Payload        string                `json:"payload" bson:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" bson:"status"`
+	Attempts       int                   `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" bson:"next_attempt_at"`
+	LastAttemptAt  time.Time             `json:"last_attempt_at,omitempty" bson:"last_attempt_at,omitempty"`
+	LastStatusCode int                   `json:"last_status_code,omitempty" bson:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at" bson:"created_at"`
+}
+
+// FinishedBefore returns true if the delivery is delivered or dead and it has been finished before the time.
+func (d WebhookDelivery) FinishedBefore(t time.Time) bool {
+	return d.Status != WebhookDeliveryPending && d.NextAttemptAt.Before(t)
+}
+
+// WebhookDeliveryStorage is a persistent webhook delivery queue.
+type WebhookDeliveryStorage interface {
+	// AddDelivery queues the delivery, the ID is assigned by the storage.
+	AddDelivery(delivery WebhookDelivery) (WebhookDelivery, error)
+	// ClaimDueDeliveries returns pending deliveries due at now and postpones them for the lease duration,
+	// so they are not sent twice by the other server instances.
+	ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+	UpdateDelivery(delivery WebhookDelivery) error
+	DeliveryByID(id string) (WebhookDelivery, error)
+	// FetchDeliveries returns deliveries with the status, newest first, and the total number of them.
+	// Empty status matches all deliveries.
+	FetchDeliveries(status WebhookDeliveryStatus, skip, limit int) ([]WebhookDelivery, int, error)
+	// DeleteFinishedDeliveries deletes the delivered and dead deliveries finished before the time
+	// and returns the number of them.
+	DeleteFinishedDeliveries(before time.Time) (int, error)
+	Close()
+}
+
+// WebhookService delivers events to the subscribed endpoints.
+type WebhookService interface {
+	// Emit queues the event for the server-wide subscriptions and the app subscriptions.
+	Emit(event WebhookEvent, app AppData, userID string, data map[string]interface{})
+	// Replay queues the delivery again with the attempts counter reset.
+	Replay(id string) (WebhookDelivery, error)
+	Close()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/madappgang/identifo/v2/storage/rest"
+)
+
+// The reference user service of the REST user storage contract, it keeps the users in BoltDB.
+func main() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(
+		os.Stderr,
+		&slog.HandlerOptions{
+			Level: slog.LevelDebug,
+		})))
+
+	path := flag.String("path", "", "path to database")
+	address := flag.String("address", ":8090", "address to listen on")
+	flag.Parse()
+
+	secret := os.Getenv("IDENTIFO_REST_USER_STORAGE_SECRET")
+	if len(secret) < 5 {
+		logging.DefaultLogger.Error("IDENTIFO_REST_USER_STORAGE_SECRET should be at least 5 chars long")
+		os.Exit(1)
+	}
+
+	s, err := boltdb.NewUserStorage(
+		logging.DefaultLogger,
+		model.BoltDBDatabaseSettings{
+			Path: *path,
+		})
+	if err != nil {
+		panic(err)
+	}
+
+	defer s.Close()
+
+	srv := &http.Server{Addr: *address, Handler: rest.NewServer(s, secret)}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.DefaultLogger.Error("REST user storage server failed", logging.FieldError, err)
+			os.Exit(1)
+		}
+	}()
+
+	osch := make(chan os.Signal, 1)
+	signal.Notify(osch, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	<-osch
+	srv.Close()
+	logging.DefaultLogger.Info("REST user storage is terminated.")
+}
@@ -116,7 +116,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.MainRouter.ServeHTTP(w, r)
 }
 
-// Close closes all database connections.
+// Close closes all database connections and stops background services.
 func (s *Server) Close() {
 	maybeClose := func(c interface{ Close() }) {
 		if c != nil {
@@ -124,6 +124,9 @@ func (s *Server) Close() {
 		}
 	}
 
+	// the webhook worker uses the storages, stop it first
+	maybeClose(s.services.Webhook)
+
 	maybeClose(s.storages.App)
 	maybeClose(s.storages.User)
 	maybeClose(s.storages.Token)
@@ -131,6 +134,20 @@ func (s *Server) Close() {
 	maybeClose(s.storages.Invite)
 	maybeClose(s.storages.Verification)
 	maybeClose(s.storages.Session)
+	maybeClose(s.storages.WebAuthn)
+	maybeClose(s.storages.LoginAttempt)
+	maybeClose(s.storages.Audit)
+	maybeClose(s.storages.Webhook)
+	maybeClose(s.storages.UserSession)
+	maybeClose(s.storages.AdminAccount)
+	maybeClose(s.services.KeyRotation)
+
+	// the SMTP transport keeps the idle connections open
+	if s.services.Email != nil {
+		if c, ok := s.services.Email.Transport().(interface{ Close() }); ok {
+			c.Close()
+		}
+	}
 }
 
 func (s *Server) Errors() []error {
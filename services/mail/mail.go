@@ -14,6 +14,7 @@ import (
 	"github.com/madappgang/identifo/v2/services/mail/mailgun"
 	"github.com/madappgang/identifo/v2/services/mail/mock"
 	"github.com/madappgang/identifo/v2/services/mail/ses"
+	"github.com/madappgang/identifo/v2/services/mail/smtp"
 	"github.com/madappgang/identifo/v2/storage"
 )
 
@@ -39,6 +40,12 @@ func NewService(
 			return nil, err
 		}
 		t = tt
+	case model.EmailServiceSMTP:
+		tt, err := smtp.NewTransport(logger, ess.SMTP)
+		if err != nil {
+			return nil, err
+		}
+		t = tt
 	case model.EmailServiceMock:
 		t = mock.NewTransport(logger)
 	default:
@@ -119,6 +126,27 @@ func (es *EmailService) Stop() {
 	es.watcher.Stop()
 }
 
+// NewConnectionTester creates the tester of the email service settings.
+func NewConnectionTester(logger *slog.Logger, ess model.EmailServiceSettings) (model.ConnectionTester, error) {
+	switch ess.Type {
+	case model.EmailServiceSMTP:
+		t, err := smtp.NewTransport(logger, ess.SMTP)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	case model.EmailServiceMock:
+		return mockConnectionTester{}, nil
+	}
+	return nil, fmt.Errorf("connection test is not supported for email service of type '%s'", ess.Type)
+}
+
+type mockConnectionTester struct{}
+
+func (mockConnectionTester) Connect() error {
+	return nil
+}
+
 func (es *EmailService) Transport() model.EmailTransport {
 	return es.transport
 }
@@ -0,0 +1,312 @@
+package smtp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	defaultPort        = 587
+	defaultTLSPort     = 465
+	defaultLocalName   = "localhost"
+	defaultPoolSize    = 2
+	defaultIdleTimeout = 30 * time.Second
+	defaultTimeout     = 10 * time.Second
+)
+
+// ErrNoStartTLS is when the server does not support STARTTLS, which is required by the settings.
+var ErrNoStartTLS = errors.New("smtp server does not support STARTTLS")
+
+// NewTransport creates SMTP relay transport.
+// The connections are kept open in the pool and reused by the next messages.
+func NewTransport(logger *slog.Logger, ess model.SMTPEmailServiceSettings) (*Transport, error) {
+	if errs := ess.Validate("SMTP"); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if _, err := parseAddress(ess.From); err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	if len(ess.ReplyTo) > 0 {
+		if _, err := parseAddress(ess.ReplyTo); err != nil {
+			return nil, fmt.Errorf("invalid reply-to address: %w", err)
+		}
+	}
+
+	poolSize := ess.PoolSize
+	if poolSize <= 0 {
+		poolSize = defaultPoolSize
+	}
+
+	return &Transport{
+		logger:   logger,
+		settings: ess,
+		poolSize: poolSize,
+	}, nil
+}
+
+// Transport sends emails with SMTP relay.
+type Transport struct {
+	logger   *slog.Logger
+	settings model.SMTPEmailServiceSettings
+	poolSize int
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// conn is the connection with the time it was used last.
+type conn struct {
+	client   *smtp.Client
+	raw      net.Conn
+	lastUsed time.Time
+}
+
+// SendMessage sends email with plain text.
+func (t *Transport) SendMessage(subject, body, recipient string) error {
+	msg, err := t.message(subject, body, "", recipient)
+	if err != nil {
+		return err
+	}
+	return t.send(recipient, msg)
+}
+
+// SendHTML sends email with html, and the plain text alternative made of the html.
+func (t *Transport) SendHTML(subject, html, recipient string) error {
+	msg, err := t.message(subject, htmlToText(html), html, recipient)
+	if err != nil {
+		return err
+	}
+	return t.send(recipient, msg)
+}
+
+// Connect connects to the server and authenticates, it tests the settings.
+func (t *Transport) Connect() error {
+	c, err := t.dial()
+	if err != nil {
+		return err
+	}
+	return c.client.Quit()
+}
+
+// Close closes the idle connections, the connections in use are closed once the message is sent.
+func (t *Transport) Close() {
+	t.mu.Lock()
+	idle := t.idle
+	t.idle = nil
+	t.closed = true
+	t.mu.Unlock()
+
+	for _, c := range idle {
+		c.quit()
+	}
+}
+
+func (t *Transport) send(recipient string, msg []byte) error {
+	to, err := parseAddress(recipient)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	from, _ := parseAddress(t.settings.From)
+
+	c, err := t.get()
+	if err != nil {
+		t.logger.Error("Unable to connect to smtp server", logging.FieldError, err)
+		return err
+	}
+
+	if err := c.transaction(t.timeout(), from.Address, to.Address, msg); err != nil {
+		// the connection state is unknown after the error.
+		c.close()
+		t.logger.Error("Unable to send email with smtp", logging.FieldError, err)
+		return err
+	}
+
+	t.put(c)
+	return nil
+}
+
+// get returns the idle connection from the pool, or the new connection.
+func (t *Transport) get() (*conn, error) {
+	for {
+		t.mu.Lock()
+		if len(t.idle) == 0 {
+			t.mu.Unlock()
+			return t.dial()
+		}
+		// the last used connection is the most likely alive.
+		c := t.idle[len(t.idle)-1]
+		t.idle = t.idle[:len(t.idle)-1]
+		t.mu.Unlock()
+
+		if time.Since(c.lastUsed) > t.idleTimeout() {
+			c.quit()
+			continue
+		}
+		// the server could have closed the idle connection.
+		c.raw.SetDeadline(time.Now().Add(t.timeout()))
+		if err := c.client.Reset(); err != nil {
+			c.close()
+			continue
+		}
+		return c, nil
+	}
+}
+
+// put returns the connection to the pool, the connection is closed if the pool is full.
+func (t *Transport) put(c *conn) {
+	c.lastUsed = time.Now()
+
+	t.mu.Lock()
+	if t.closed || len(t.idle) >= t.poolSize {
+		t.mu.Unlock()
+		c.quit()
+		return
+	}
+	t.idle = append(t.idle, c)
+	t.mu.Unlock()
+}
+
+func (t *Transport) dial() (*conn, error) {
+	s := t.settings
+	timeout := t.timeout()
+
+	port := s.Port
+	if port == 0 {
+		port = defaultPort
+		if s.Security == model.SMTPSecurityTLS {
+			port = defaultTLSPort
+		}
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{
+		ServerName:         s.Host,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var raw net.Conn
+	var err error
+	if s.Security == model.SMTPSecurityTLS {
+		raw, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		raw, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to smtp server %s: %w", addr, err)
+	}
+	raw.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(raw, s.Host)
+	if err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("unable to start smtp session: %w", err)
+	}
+	c := &conn{client: client, raw: raw}
+
+	localName := s.LocalName
+	if len(localName) == 0 {
+		localName = defaultLocalName
+	}
+	if err := client.Hello(localName); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	if s.Security == "" || s.Security == model.SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			c.close()
+			return nil, ErrNoStartTLS
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			c.close()
+			return nil, fmt.Errorf("unable to start tls: %w", err)
+		}
+	}
+
+	if auth := t.auth(); auth != nil {
+		if err := client.Auth(auth); err != nil {
+			c.close()
+			return nil, fmt.Errorf("smtp authentication failed: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+func (t *Transport) auth() smtp.Auth {
+	s := t.settings
+	switch s.Auth {
+	case model.SMTPAuthNone:
+		return nil
+	case model.SMTPAuthLogin:
+		return LoginAuth(s.Username, s.Password, s.Host)
+	case model.SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(s.Username, s.Password)
+	case model.SMTPAuthPlain:
+		return smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	// the default is plain, if the username is set.
+	if len(s.Username) > 0 {
+		return smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	return nil
+}
+
+func (t *Transport) timeout() time.Duration {
+	if t.settings.Timeout > 0 {
+		return time.Duration(t.settings.Timeout) * time.Second
+	}
+	return defaultTimeout
+}
+
+func (t *Transport) idleTimeout() time.Duration {
+	if t.settings.IdleTimeout > 0 {
+		return time.Duration(t.settings.IdleTimeout) * time.Second
+	}
+	return defaultIdleTimeout
+}
+
+// transaction sends one message over the connection.
+func (c *conn) transaction(timeout time.Duration, from, to string, msg []byte) error {
+	c.raw.SetDeadline(time.Now().Add(timeout))
+
+	if err := c.client.Mail(from); err != nil {
+		return err
+	}
+	if err := c.client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// quit closes the connection gracefully.
+func (c *conn) quit() {
+	c.raw.SetDeadline(time.Now().Add(time.Second))
+	if err := c.client.Quit(); err != nil {
+		c.close()
+	}
+}
+
+func (c *conn) close() {
+	c.client.Close()
+}
@@ -0,0 +1,393 @@
+package smtp_test
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/services/mail/smtp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUsername = "relay-user"
+	testPassword = "relay-password"
+)
+
+type testMessage struct {
+	from      string
+	to        string
+	data      string
+	tls       bool
+	mechanism string
+}
+
+// testServer is an in-process SMTP server, which supports STARTTLS, implicit TLS and PLAIN, LOGIN and CRAM-MD5 auth.
+type testServer struct {
+	ln          net.Listener
+	tlsConfig   *tls.Config
+	implicitTLS bool
+	startTLS    bool
+
+	mu          sync.Mutex
+	connections int
+	conns       []net.Conn
+	messages    []testMessage
+}
+
+func newTestServer(t *testing.T, implicitTLS, startTLS bool) *testServer {
+	// borrow the self-signed certificate of httptest
+	hs := httptest.NewUnstartedServer(nil)
+	hs.StartTLS()
+	tlsConfig := &tls.Config{Certificates: hs.TLS.Certificates}
+	hs.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if implicitTLS {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	s := &testServer{ln: ln, tlsConfig: tlsConfig, implicitTLS: implicitTLS, startTLS: startTLS}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *testServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testServer) settings(security model.SMTPSecurity, auth model.SMTPAuth) model.SMTPEmailServiceSettings {
+	return model.SMTPEmailServiceSettings{
+		Host:               "127.0.0.1",
+		Port:               s.port(),
+		Security:           security,
+		InsecureSkipVerify: true,
+		Auth:               auth,
+		Username:           testUsername,
+		Password:           testPassword,
+		From:               "Identifo <noreply@example.com>",
+		ReplyTo:            "support@example.com",
+	}
+}
+
+func (s *testServer) stats() (int, []testMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections, append([]testMessage{}, s.messages...)
+}
+
+// dropConnections closes all open connections, as the server does with the idle connections.
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.connections++
+		s.conns = append(s.conns, c)
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *testServer) handle(c net.Conn) {
+	defer c.Close()
+
+	tp := textproto.NewConn(c)
+	isTLS := s.implicitTLS
+	mechanism := ""
+	msg := testMessage{}
+
+	tp.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			lines := []string{"localhost"}
+			if s.startTLS && !isTLS {
+				lines = append(lines, "STARTTLS")
+			}
+			lines = append(lines, "AUTH PLAIN LOGIN CRAM-MD5", "8BITMIME")
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			tc := tls.Server(c, s.tlsConfig)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			c = tc
+			tp = textproto.NewConn(c)
+			isTLS = true
+		case "AUTH":
+			ok := false
+			mech, initial, _ := strings.Cut(arg, " ")
+			switch mech {
+			case "PLAIN":
+				b, _ := base64.StdEncoding.DecodeString(initial)
+				ok = string(b) == "\x00"+testUsername+"\x00"+testPassword
+			case "LOGIN":
+				tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				user := readBase64(tp)
+				tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				pswd := readBase64(tp)
+				ok = user == testUsername && pswd == testPassword
+			case "CRAM-MD5":
+				challenge := "<1896.697170952@localhost>"
+				tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+				user, digest, _ := strings.Cut(readBase64(tp), " ")
+				d := hmac.New(md5.New, []byte(testPassword))
+				d.Write([]byte(challenge))
+				ok = user == testUsername && digest == hex.EncodeToString(d.Sum(nil))
+			}
+			if !ok {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			mechanism = mech
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			from, _, _ := strings.Cut(strings.TrimPrefix(arg, "FROM:<"), ">")
+			msg = testMessage{from: from, tls: isTLS, mechanism: mechanism}
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			msg.to, _, _ = strings.Cut(strings.TrimPrefix(arg, "TO:<"), ">")
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK queued")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+func readBase64(tp *textproto.Conn) string {
+	line, _ := tp.ReadLine()
+	b, _ := base64.StdEncoding.DecodeString(line)
+	return string(b)
+}
+
+func newTransport(t *testing.T, settings model.SMTPEmailServiceSettings) *smtp.Transport {
+	tr, err := smtp.NewTransport(logging.DefaultLogger, settings)
+	require.NoError(t, err)
+	t.Cleanup(tr.Close)
+	return tr
+}
+
+func TestSMTPStartTLSPlainAuth(t *testing.T) {
+	srv := newTestServer(t, false, true)
+	tr := newTransport(t, srv.settings(model.SMTPSecurityStartTLS, model.SMTPAuthPlain))
+
+	require.NoError(t, tr.SendMessage("Привет", "Hello, user!", "user@example.com"))
+
+	_, messages := srv.stats()
+	require.Len(t, messages, 1)
+	m := messages[0]
+	assert.True(t, m.tls)
+	assert.Equal(t, "PLAIN", m.mechanism)
+	assert.Equal(t, "noreply@example.com", m.from)
+	assert.Equal(t, "user@example.com", m.to)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(m.data))
+	require.NoError(t, err)
+	assert.Equal(t, `"Identifo" <noreply@example.com>`, parsed.Header.Get("From"))
+	assert.Equal(t, "<support@example.com>", parsed.Header.Get("Reply-To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Привет", subject)
+	assert.Contains(t, parsed.Header.Get("Content-Type"), "text/plain")
+	assert.NotEmpty(t, parsed.Header.Get("Message-ID"))
+}
+
+func TestSMTPImplicitTLSLoginAuthMultipart(t *testing.T) {
+	srv := newTestServer(t, true, false)
+	tr := newTransport(t, srv.settings(model.SMTPSecurityTLS, model.SMTPAuthLogin))
+
+	html := `<html><head><style>p {color: red}</style></head><body><p>Hello &amp; welcome</p><a href="https://example.com">Verify</a></body></html>`
+	require.NoError(t, tr.SendHTML("Welcome", html, "user@example.com"))
+
+	_, messages := srv.stats()
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].tls)
+	assert.Equal(t, "LOGIN", messages[0].mechanism)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(messages[0].data))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	parts := map[string]string{}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		// the reader decodes quoted-printable
+		body, err := io.ReadAll(p)
+		require.NoError(t, err)
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts[ct] = string(body)
+	}
+	assert.Equal(t, html, parts["text/html"])
+	assert.Equal(t, "Hello & welcome\nVerify", parts["text/plain"])
+}
+
+func TestSMTPCRAMMD5WithoutTLS(t *testing.T) {
+	srv := newTestServer(t, false, false)
+	tr := newTransport(t, srv.settings(model.SMTPSecurityNone, model.SMTPAuthCRAMMD5))
+
+	require.NoError(t, tr.SendMessage("Subject", "Body", "user@example.com"))
+
+	_, messages := srv.stats()
+	require.Len(t, messages, 1)
+	assert.False(t, messages[0].tls)
+	assert.Equal(t, "CRAM-MD5", messages[0].mechanism)
+}
+
+func TestSMTPConnectionPool(t *testing.T) {
+	srv := newTestServer(t, false, true)
+	tr := newTransport(t, srv.settings(model.SMTPSecurityStartTLS, model.SMTPAuthPlain))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, tr.SendMessage("Subject "+strconv.Itoa(i), "Body", "user@example.com"))
+	}
+	connections, messages := srv.stats()
+	assert.Len(t, messages, 3)
+	assert.Equal(t, 1, connections)
+
+	// the pooled connection closed by the server is replaced
+	srv.dropConnections()
+	require.NoError(t, tr.SendMessage("Subject", "Body", "user@example.com"))
+	connections, messages = srv.stats()
+	assert.Len(t, messages, 4)
+	assert.Equal(t, 2, connections)
+}
+
+func TestSMTPConcurrentSend(t *testing.T) {
+	srv := newTestServer(t, false, true)
+	settings := srv.settings(model.SMTPSecurityStartTLS, model.SMTPAuthPlain)
+	settings.PoolSize = 2
+	tr := newTransport(t, settings)
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- tr.SendMessage(fmt.Sprintf("Subject %d", i), "Body", "user@example.com")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	_, messages := srv.stats()
+	assert.Len(t, messages, 10)
+}
+
+func TestSMTPErrors(t *testing.T) {
+	srv := newTestServer(t, false, false)
+
+	// STARTTLS is required by default
+	tr := newTransport(t, srv.settings("", model.SMTPAuthNone))
+	assert.ErrorIs(t, tr.SendMessage("Subject", "Body", "user@example.com"), smtp.ErrNoStartTLS)
+
+	settings := srv.settings(model.SMTPSecurityNone, model.SMTPAuthCRAMMD5)
+	settings.Password = "wrong"
+	tr = newTransport(t, settings)
+	assert.Error(t, tr.Connect())
+	assert.Error(t, tr.SendMessage("Subject", "Body", "user@example.com"))
+
+	tr = newTransport(t, srv.settings(model.SMTPSecurityNone, model.SMTPAuthCRAMMD5))
+	assert.NoError(t, tr.Connect())
+	assert.Error(t, tr.SendMessage("Subject", "Body", "not an address"))
+
+	_, messages := srv.stats()
+	assert.Empty(t, messages)
+
+	settings.From = ""
+	_, err := smtp.NewTransport(logging.DefaultLogger, settings)
+	assert.Error(t, err)
+}
+
+func TestSMTPConnectNotSMTPServer(t *testing.T) {
+	// the server which is not SMTP
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		c.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+		c.Close()
+	}()
+
+	tr := newTransport(t, model.SMTPEmailServiceSettings{
+		Host:     "127.0.0.1",
+		Port:     ln.Addr().(*net.TCPAddr).Port,
+		Security: model.SMTPSecurityNone,
+		From:     "noreply@example.com",
+	})
+	assert.Error(t, tr.Connect())
+}
@@ -0,0 +1,196 @@
+package sms
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	defaultFailureThreshold = 3
+	defaultOpenTimeout      = 60 * time.Second
+)
+
+// FailoverService sends SMS with the first provider which succeeds.
+// The provider which fails several times in a row is skipped until the open timeout passes,
+// then one trial message is sent with it, and it is used again if the trial succeeds.
+// Only the failures of the provider, e.g. network errors and 5xx responses, are counted,
+// the recipient errors, e.g. the invalid phone number, are not.
+type FailoverService struct {
+	logger    *slog.Logger
+	providers []*failoverProvider
+	byName    map[string]*failoverProvider
+	routes    []model.SMSRoute
+	threshold int
+	timeout   time.Duration
+	now       func() time.Time
+}
+
+type failoverProvider struct {
+	name    string
+	service model.SMSService
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// NewFailoverService creates the failover service of the providers,
+// the services are made of the provider settings by the NewService.
+func NewFailoverService(logger *slog.Logger, settings model.FailoverSMSServiceSettings) (*FailoverService, error) {
+	if len(settings.Providers) == 0 {
+		return nil, errors.New("failover SMS service has no providers")
+	}
+
+	fs := &FailoverService{
+		logger:    logger,
+		byName:    map[string]*failoverProvider{},
+		routes:    settings.Routes,
+		threshold: settings.FailureThreshold,
+		timeout:   time.Duration(settings.OpenTimeout) * time.Second,
+		now:       time.Now,
+	}
+	if fs.threshold <= 0 {
+		fs.threshold = defaultFailureThreshold
+	}
+	if fs.timeout <= 0 {
+		fs.timeout = defaultOpenTimeout
+	}
+
+	for _, ps := range settings.Providers {
+		if ps.Type == model.SMSServiceFailover {
+			return nil, fmt.Errorf("failover SMS provider %s could not be failover", ps.Name)
+		}
+		if _, ok := fs.byName[ps.Name]; ok {
+			return nil, fmt.Errorf("failover SMS provider %s is duplicated", ps.Name)
+		}
+		service, err := NewService(logger, ps.SMSServiceSettings)
+		if err != nil {
+			return nil, fmt.Errorf("creating failover SMS provider %s: %w", ps.Name, err)
+		}
+		p := &failoverProvider{name: ps.Name, service: service}
+		fs.providers = append(fs.providers, p)
+		fs.byName[ps.Name] = p
+	}
+
+	for _, r := range settings.Routes {
+		for _, name := range r.Providers {
+			if _, ok := fs.byName[name]; !ok {
+				return nil, fmt.Errorf("failover SMS route %s has unknown provider %s", r.Prefix, name)
+			}
+		}
+	}
+	return fs, nil
+}
+
+// SendSMS sends the message with the providers of the recipient route, one by one until one succeeds.
+// The providers with the open circuit are skipped, if all of them are open they are tried anyway.
+// The circuit is checked just before the provider is tried, so the trial is not taken by the provider,
+// which is not reached because the previous one has succeeded.
+func (fs *FailoverService) SendSMS(recipient, message string) error {
+	providers := fs.route(recipient)
+
+	errs := make([]error, 0, len(providers))
+	tried := false
+	for _, p := range providers {
+		allowed, trial := p.allow(fs.now(), fs.threshold)
+		if !allowed {
+			continue
+		}
+		tried = true
+		if fs.send(p, trial, recipient, message, &errs) {
+			return nil
+		}
+	}
+
+	if !tried {
+		fs.logger.Warn("All SMS providers are unavailable, trying them anyway")
+		for _, p := range providers {
+			if fs.send(p, false, recipient, message, &errs) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("all SMS providers have failed: %w", errors.Join(errs...))
+}
+
+// send sends the message with the provider and records the result, the error is appended to errs.
+// The trial is true if the message is the trial one of the open circuit.
+func (fs *FailoverService) send(p *failoverProvider, trial bool, recipient, message string, errs *[]error) bool {
+	err := p.service.SendSMS(recipient, message)
+	p.done(err, trial, fs.now(), fs.threshold, fs.timeout)
+	if err == nil {
+		return true
+	}
+
+	fs.logger.Warn("Error sending SMS, trying the next provider",
+		"provider", p.name,
+		logging.FieldError, err)
+	*errs = append(*errs, fmt.Errorf("%s: %w", p.name, err))
+	return false
+}
+
+// route returns the providers of the longest route prefix the recipient starts with,
+// all the providers are returned if there is no such route.
+func (fs *FailoverService) route(recipient string) []*failoverProvider {
+	var match *model.SMSRoute
+	for i, r := range fs.routes {
+		if strings.HasPrefix(recipient, r.Prefix) && (match == nil || len(r.Prefix) > len(match.Prefix)) {
+			match = &fs.routes[i]
+		}
+	}
+	if match == nil {
+		return fs.providers
+	}
+
+	result := make([]*failoverProvider, 0, len(match.Providers))
+	for _, name := range match.Providers {
+		result = append(result, fs.byName[name])
+	}
+	return result
+}
+
+// allow returns true if the circuit is closed, or it is time for the trial message, which is reported with trial.
+// Only one trial message is sent at a time.
+func (p *failoverProvider) allow(now time.Time, threshold int) (allowed, trial bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failures < threshold {
+		return true, false
+	}
+	if now.Before(p.openUntil) || p.trial {
+		return false, false
+	}
+	p.trial = true
+	return true, true
+}
+
+// done records the result, the success closes the circuit, the failure over the threshold opens it.
+// Only the trial message ends the trial, the recipient error is not the failure of the provider.
+func (p *failoverProvider) done(err error, trial bool, now time.Time, threshold int, timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if trial {
+		p.trial = false
+	}
+	if err == nil {
+		p.failures = 0
+		return
+	}
+	if recipientErr := (*model.SMSRecipientError)(nil); errors.As(err, &recipientErr) {
+		return
+	}
+	p.failures++
+	if p.failures >= threshold {
+		p.openUntil = now.Add(timeout)
+	}
+}
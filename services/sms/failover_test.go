@@ -0,0 +1,224 @@
+package sms
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sent []string
+	err  error
+}
+
+func (p *testProvider) SendSMS(recipient, message string) error {
+	p.sent = append(p.sent, recipient)
+	return p.err
+}
+
+func testFailoverService(t *testing.T, routes []model.SMSRoute, providers map[string]*testProvider, names ...string) *FailoverService {
+	settings := model.FailoverSMSServiceSettings{Routes: routes, FailureThreshold: 2, OpenTimeout: 60}
+	for _, name := range names {
+		settings.Providers = append(settings.Providers, model.SMSProviderSettings{
+			Name:               name,
+			SMSServiceSettings: model.SMSServiceSettings{Type: model.SMSServiceMock},
+		})
+	}
+
+	fs, err := NewFailoverService(logging.DefaultLogger, settings)
+	require.NoError(t, err)
+	for _, p := range fs.providers {
+		p.service = providers[p.name]
+	}
+	return fs
+}
+
+func TestFailoverOrder(t *testing.T) {
+	providers := map[string]*testProvider{
+		"primary":   {err: errors.New("primary is down")},
+		"secondary": {},
+	}
+	fs := testFailoverService(t, nil, providers, "primary", "secondary")
+
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	assert.Len(t, providers["primary"].sent, 1)
+	assert.Len(t, providers["secondary"].sent, 1)
+
+	providers["secondary"].err = errors.New("secondary is down")
+	err := fs.SendSMS("+61450396664", "code")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "primary is down")
+	assert.Contains(t, err.Error(), "secondary is down")
+}
+
+func TestFailoverRoutes(t *testing.T) {
+	providers := map[string]*testProvider{
+		"global": {},
+		"gulf":   {},
+		"uae":    {},
+	}
+	routes := []model.SMSRoute{
+		{Prefix: "+9", Providers: []string{"gulf", "global"}},
+		{Prefix: "+971", Providers: []string{"uae"}},
+	}
+	fs := testFailoverService(t, routes, providers, "global", "gulf", "uae")
+
+	require.NoError(t, fs.SendSMS("+971501234567", "code"))
+	require.NoError(t, fs.SendSMS("+966501234567", "code"))
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+
+	assert.Equal(t, []string{"+971501234567"}, providers["uae"].sent)
+	assert.Equal(t, []string{"+966501234567"}, providers["gulf"].sent)
+	assert.Equal(t, []string{"+61450396664"}, providers["global"].sent)
+}
+
+func TestFailoverCircuitBreaker(t *testing.T) {
+	providers := map[string]*testProvider{
+		"primary":   {err: errors.New("primary is down")},
+		"secondary": {},
+	}
+	fs := testFailoverService(t, nil, providers, "primary", "secondary")
+	now := time.Now()
+	fs.now = func() time.Time { return now }
+
+	// the circuit opens after two failures, and the primary is skipped.
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	assert.Len(t, providers["primary"].sent, 2)
+	assert.Len(t, providers["secondary"].sent, 3)
+
+	// the trial message fails and the circuit opens again.
+	now = now.Add(61 * time.Second)
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	assert.Len(t, providers["primary"].sent, 3)
+
+	// the trial message succeeds and the circuit closes.
+	now = now.Add(61 * time.Second)
+	providers["primary"].err = nil
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	assert.Len(t, providers["primary"].sent, 5)
+	assert.Len(t, providers["secondary"].sent, 5)
+
+	// all the circuits are open, the providers are tried anyway.
+	providers["primary"].err = errors.New("primary is down")
+	providers["secondary"].err = errors.New("secondary is down")
+	for i := 0; i < 2; i++ {
+		require.Error(t, fs.SendSMS("+61450396664", "code"))
+	}
+	providers["secondary"].err = nil
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	assert.Len(t, providers["primary"].sent, 8)
+}
+
+func TestFailoverTrialNotReached(t *testing.T) {
+	providers := map[string]*testProvider{
+		"primary":   {},
+		"secondary": {err: errors.New("secondary is down")},
+	}
+	routes := []model.SMSRoute{{Prefix: "+1", Providers: []string{"secondary", "primary"}}}
+	fs := testFailoverService(t, routes, providers, "primary", "secondary")
+	now := time.Now()
+	fs.now = func() time.Time { return now }
+
+	// the secondary circuit opens on the route, where it is tried first.
+	require.NoError(t, fs.SendSMS("+15550000001", "code"))
+	require.NoError(t, fs.SendSMS("+15550000001", "code"))
+	assert.Len(t, providers["secondary"].sent, 2)
+
+	// the primary succeeds, so the half-open secondary is not reached and keeps its trial.
+	now = now.Add(61 * time.Second)
+	require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	assert.Len(t, providers["secondary"].sent, 2)
+
+	providers["secondary"].err = nil
+	require.NoError(t, fs.SendSMS("+15550000001", "code"))
+	assert.Len(t, providers["secondary"].sent, 3)
+	assert.Len(t, providers["primary"].sent, 3)
+}
+
+func TestFailoverRecipientErrors(t *testing.T) {
+	invalid := &model.SMSRecipientError{Err: errors.New("invalid number")}
+	providers := map[string]*testProvider{
+		"primary":   {err: model.SMSStatusError(400, invalid.Err)},
+		"secondary": {err: invalid},
+	}
+	fs := testFailoverService(t, nil, providers, "primary", "secondary")
+
+	// the recipient errors do not open the circuits.
+	for i := 0; i < 3; i++ {
+		err := fs.SendSMS("+1", "code")
+		require.Error(t, err)
+		assert.ErrorIs(t, err, invalid.Err)
+	}
+	assert.Len(t, providers["primary"].sent, 3)
+	assert.Len(t, providers["secondary"].sent, 3)
+
+	// the throttling is the failure of the provider.
+	providers["primary"].err = model.SMSStatusError(429, errors.New("too many requests"))
+	providers["secondary"].err = nil
+	for i := 0; i < 3; i++ {
+		require.NoError(t, fs.SendSMS("+61450396664", "code"))
+	}
+	assert.Len(t, providers["primary"].sent, 5)
+}
+
+func TestFailoverTrialEndedByTrialOnly(t *testing.T) {
+	fs := testFailoverService(t, nil, map[string]*testProvider{"primary": {}}, "primary")
+	p := fs.providers[0]
+	now := time.Now()
+	down := errors.New("primary is down")
+
+	p.done(down, false, now, 2, time.Minute)
+	p.done(down, false, now, 2, time.Minute)
+	now = now.Add(61 * time.Second)
+
+	allowed, trial := p.allow(now, 2)
+	require.True(t, allowed)
+	require.True(t, trial)
+
+	// the message sent anyway, while the trial is in flight, does not end the trial.
+	p.done(down, false, now, 2, time.Minute)
+	now = now.Add(61 * time.Second)
+	allowed, _ = p.allow(now, 2)
+	assert.False(t, allowed)
+
+	p.done(nil, true, now, 2, time.Minute)
+	allowed, trial = p.allow(now, 2)
+	assert.True(t, allowed)
+	assert.False(t, trial)
+}
+
+func TestFailoverSettingsValidation(t *testing.T) {
+	settings := model.SMSServiceSettings{
+		Type: model.SMSServiceFailover,
+		Failover: model.FailoverSMSServiceSettings{
+			Providers: []model.SMSProviderSettings{
+				{Name: "mock", SMSServiceSettings: model.SMSServiceSettings{Type: model.SMSServiceMock}},
+				{Name: "http", SMSServiceSettings: model.SMSServiceSettings{Type: model.SMSServiceHTTP}},
+			},
+			Routes: []model.SMSRoute{
+				{Prefix: "971", Providers: []string{"mock"}},
+				{Prefix: "+1", Providers: []string{"unknown"}},
+			},
+		},
+	}
+	errs := settings.Validate()
+	// the http provider has no url and fields, the routes have invalid prefix and unknown provider.
+	assert.Len(t, errs, 4)
+
+	settings.Failover.Providers = settings.Failover.Providers[:1]
+	settings.Failover.Routes = []model.SMSRoute{{Prefix: "+971", Providers: []string{"mock"}}}
+	assert.Empty(t, settings.Validate())
+
+	service, err := NewService(logging.DefaultLogger, settings)
+	require.NoError(t, err)
+	assert.IsType(t, &FailoverService{}, service)
+}
@@ -0,0 +1,238 @@
+package httpsms
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	defaultAuthHeader = "Authorization"
+	defaultHMACHeader = "Digest"
+	defaultTimeout    = 30 * time.Second
+	maxResponseSize   = 64 * 1024
+)
+
+// templateData is the data of the URL, the fields and the body templates.
+type templateData struct {
+	To       string // To is the recipient in E.164 format, i.e. +61450396664.
+	ToDigits string // ToDigits is the recipient without the plus sign.
+	Message  string
+	From     string
+}
+
+var templateFuncs = template.FuncMap{
+	// json quotes the value as JSON string, to be used in the body template.
+	"json": func(v string) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// SMSService sends SMS with the HTTP request to the gateway.
+type SMSService struct {
+	settings   model.HTTPSMSServiceSettings
+	method     string
+	url        *template.Template
+	body       *template.Template
+	fields     map[string]*template.Template
+	success    *regexp.Regexp
+	httpClient *http.Client
+}
+
+// NewSMSService creates, inits and returns the HTTP gateway SMS service.
+func NewSMSService(settings model.HTTPSMSServiceSettings) (*SMSService, error) {
+	s := &SMSService{
+		settings: settings,
+		method:   strings.ToUpper(settings.Method),
+		fields:   map[string]*template.Template{},
+		httpClient: &http.Client{
+			Timeout: defaultTimeout,
+		},
+	}
+	if settings.Timeout > 0 {
+		s.httpClient.Timeout = time.Duration(settings.Timeout) * time.Second
+	}
+
+	switch s.method {
+	case "":
+		s.method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodGet:
+	default:
+		return nil, fmt.Errorf("HTTP SMS service does not support method %s", settings.Method)
+	}
+
+	switch settings.Format {
+	case "", model.HTTPSMSFormatJSON, model.HTTPSMSFormatForm:
+	default:
+		return nil, fmt.Errorf("HTTP SMS service does not support format %s", settings.Format)
+	}
+
+	var err error
+	if s.url, err = parseTemplate("url", settings.URL); err != nil {
+		return nil, err
+	}
+	if len(settings.Template) > 0 {
+		if s.body, err = parseTemplate("template", settings.Template); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range settings.Fields {
+		if s.fields[k], err = parseTemplate("field "+k, v); err != nil {
+			return nil, err
+		}
+	}
+	if len(settings.SuccessPattern) > 0 {
+		if s.success, err = regexp.Compile(settings.SuccessPattern); err != nil {
+			return nil, fmt.Errorf("HTTP SMS service has invalid success pattern: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP SMS service has invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+func execute(t *template.Template, data templateData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("executing %s template: %w", t.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// SendSMS sends SMS message with the HTTP request, any 2xx response matching the success pattern is successful.
+func (ss *SMSService) SendSMS(recipient, message string) error {
+	data := templateData{
+		To:       recipient,
+		ToDigits: strings.TrimPrefix(recipient, "+"),
+		Message:  message,
+		From:     ss.settings.From,
+	}
+
+	requestURL, err := execute(ss.url, data)
+	if err != nil {
+		return err
+	}
+	body, contentType, err := ss.requestBody(data)
+	if err != nil {
+		return err
+	}
+
+	// the fields of GET request are sent in the query, the body template is ignored.
+	if ss.method == http.MethodGet {
+		if len(body) > 0 && contentType == "application/x-www-form-urlencoded" {
+			sep := "?"
+			if strings.Contains(requestURL, "?") {
+				sep = "&"
+			}
+			requestURL += sep + string(body)
+		}
+		body = nil
+	}
+
+	request, err := http.NewRequest(ss.method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating HTTP SMS request: %w", err)
+	}
+	if len(body) > 0 {
+		request.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range ss.settings.Headers {
+		request.Header.Set(k, v)
+	}
+	if len(ss.settings.AuthValue) > 0 {
+		header := ss.settings.AuthHeader
+		if len(header) == 0 {
+			header = defaultAuthHeader
+		}
+		request.Header.Set(header, ss.settings.AuthValue)
+	}
+	if len(ss.settings.HMACSecret) > 0 {
+		header := ss.settings.HMACHeader
+		if len(header) == 0 {
+			header = defaultHMACHeader
+		}
+		request.Header.Set(header, "SHA-256="+Sign(ss.settings.HMACSecret, body))
+	}
+
+	resp, err := ss.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return model.SMSStatusError(resp.StatusCode,
+			fmt.Errorf("HTTP SMS gateway responded with status %d: %s", resp.StatusCode, string(respBytes)))
+	}
+	if ss.success != nil && !ss.success.Match(respBytes) {
+		return fmt.Errorf("HTTP SMS gateway responded with unexpected body: %s", string(respBytes))
+	}
+	return nil
+}
+
+// requestBody returns the body made of the template, or the fields encoded in the format.
+func (ss *SMSService) requestBody(data templateData) ([]byte, string, error) {
+	contentType := "application/json"
+	if ss.settings.Format == model.HTTPSMSFormatForm {
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	if ss.body != nil && ss.method != http.MethodGet {
+		body, err := execute(ss.body, data)
+		return []byte(body), contentType, err
+	}
+
+	fields := map[string]string{}
+	for k, t := range ss.fields {
+		v, err := execute(t, data)
+		if err != nil {
+			return nil, "", err
+		}
+		fields[k] = v
+	}
+
+	if ss.settings.Format == model.HTTPSMSFormatForm || ss.method == http.MethodGet {
+		values := url.Values{}
+		for k, v := range fields {
+			values.Set(k, v)
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, contentType, nil
+}
+
+// Sign returns hex encoded HMAC SHA-256 of the body, the same way the webhooks are signed.
+func Sign(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,155 @@
+package httpsms_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/services/sms/httpsms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	method  string
+	path    string
+	query   url.Values
+	headers http.Header
+	body    []byte
+}
+
+func gateway(t *testing.T, status int, response string) (*httptest.Server, chan request) {
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- request{method: r.Method, path: r.URL.Path, query: r.URL.Query(), headers: r.Header, body: body}
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func TestHTTPSMSJSONFields(t *testing.T) {
+	srv, requests := gateway(t, http.StatusOK, `{"status":"queued"}`)
+
+	s, err := httpsms.NewSMSService(model.HTTPSMSServiceSettings{
+		URL:  srv.URL + "/send/{{.ToDigits}}",
+		From: "Identifo",
+		Fields: map[string]string{
+			"to":   "{{.To}}",
+			"text": "{{.Message}}",
+			"from": "{{.From}}",
+		},
+		AuthValue:      "Bearer secret",
+		HMACSecret:     "hmac-secret",
+		Headers:        map[string]string{"X-Tenant": "identifo"},
+		SuccessPattern: `"queued"`,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SendSMS("+61450396664", `Your code is "1234"`))
+
+	r := <-requests
+	assert.Equal(t, http.MethodPost, r.method)
+	assert.Equal(t, "/send/61450396664", r.path)
+	assert.Equal(t, "application/json", r.headers.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", r.headers.Get("Authorization"))
+	assert.Equal(t, "identifo", r.headers.Get("X-Tenant"))
+	assert.Equal(t, "SHA-256="+httpsms.Sign("hmac-secret", r.body), r.headers.Get("Digest"))
+
+	fields := map[string]string{}
+	require.NoError(t, json.Unmarshal(r.body, &fields))
+	assert.Equal(t, map[string]string{
+		"to":   "+61450396664",
+		"text": `Your code is "1234"`,
+		"from": "Identifo",
+	}, fields)
+}
+
+func TestHTTPSMSFormAndTemplate(t *testing.T) {
+	srv, requests := gateway(t, http.StatusOK, "OK")
+
+	s, err := httpsms.NewSMSService(model.HTTPSMSServiceSettings{
+		URL:        srv.URL,
+		Format:     model.HTTPSMSFormatForm,
+		Fields:     map[string]string{"to": "{{.ToDigits}}", "text": "{{.Message}}"},
+		AuthHeader: "X-API-Key",
+		AuthValue:  "key",
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.SendSMS("+61450396664", "code 1234"))
+
+	r := <-requests
+	assert.Equal(t, "application/x-www-form-urlencoded", r.headers.Get("Content-Type"))
+	assert.Equal(t, "key", r.headers.Get("X-API-Key"))
+	values, err := url.ParseQuery(string(r.body))
+	require.NoError(t, err)
+	assert.Equal(t, "61450396664", values.Get("to"))
+	assert.Equal(t, "code 1234", values.Get("text"))
+
+	s, err = httpsms.NewSMSService(model.HTTPSMSServiceSettings{
+		URL:      srv.URL,
+		Template: `{"messages":[{"to":{{json .To}},"body":{{json .Message}}}]}`,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.SendSMS("+61450396664", `say "hi"`))
+
+	r = <-requests
+	assert.JSONEq(t, `{"messages":[{"to":"+61450396664","body":"say \"hi\""}]}`, string(r.body))
+
+	s, err = httpsms.NewSMSService(model.HTTPSMSServiceSettings{
+		URL:    srv.URL + "/send?key=1",
+		Method: "get",
+		Fields: map[string]string{"to": "{{.ToDigits}}"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.SendSMS("+61450396664", "code"))
+
+	r = <-requests
+	assert.Equal(t, http.MethodGet, r.method)
+	assert.Equal(t, "1", r.query.Get("key"))
+	assert.Equal(t, "61450396664", r.query.Get("to"))
+	assert.Empty(t, r.body)
+}
+
+func TestHTTPSMSErrors(t *testing.T) {
+	srv, requests := gateway(t, http.StatusBadRequest, "invalid number")
+
+	s, err := httpsms.NewSMSService(model.HTTPSMSServiceSettings{
+		URL:    srv.URL,
+		Fields: map[string]string{"to": "{{.To}}"},
+	})
+	require.NoError(t, err)
+	err = s.SendSMS("+1", "code")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid number")
+	<-requests
+
+	srv, requests = gateway(t, http.StatusOK, `{"status":"error"}`)
+	s, err = httpsms.NewSMSService(model.HTTPSMSServiceSettings{
+		URL:            srv.URL,
+		Fields:         map[string]string{"to": "{{.To}}"},
+		SuccessPattern: `"status":"ok"`,
+	})
+	require.NoError(t, err)
+	require.Error(t, s.SendSMS("+1", "code"))
+	<-requests
+
+	_, err = httpsms.NewSMSService(model.HTTPSMSServiceSettings{
+		URL:    srv.URL,
+		Fields: map[string]string{"to": "{{.To"},
+	})
+	require.Error(t, err)
+
+	_, err = httpsms.NewSMSService(model.HTTPSMSServiceSettings{
+		URL:    srv.URL,
+		Method: http.MethodDelete,
+	})
+	require.Error(t, err)
+}
@@ -41,7 +41,12 @@ func (ss *SMSService) SendSMS(recipient, message string) error {
 		return err
 	}
 	for _, messageReport := range resp.Messages {
-		if messageReport.Status != nexmo.ResponseSuccess {
+		switch messageReport.Status {
+		case nexmo.ResponseSuccess:
+		case nexmo.ResponseInvalidParams, nexmo.ResponseInvalidMessage, nexmo.ResponseNumberBarred, nexmo.ResponseMessageTooLong:
+			// Nexmo has rejected the recipient or the message.
+			return &model.SMSRecipientError{Err: errors.New(messageReport.ErrorText)}
+		default:
 			return errors.New(messageReport.ErrorText)
 		}
 	}
@@ -59,7 +59,7 @@ func (ss *SMSService) SendSMS(recipient, message string) error {
 	respString := string(respBytes)
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("%s. %d", respString, resp.StatusCode)
+		return model.SMSStatusError(resp.StatusCode, fmt.Errorf("%s. %d", respString, resp.StatusCode))
 	}
 	if !strings.HasPrefix(respString, "1701") {
 		return fmt.Errorf("Error from RouteMobile API: '%s'. Please refer to the RouteMobile documentation", respString)
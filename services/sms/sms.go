@@ -5,6 +5,7 @@ import (
 	"log/slog"
 
 	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/services/sms/httpsms"
 	"github.com/madappgang/identifo/v2/services/sms/mock"
 	"github.com/madappgang/identifo/v2/services/sms/nexmo"
 	"github.com/madappgang/identifo/v2/services/sms/routemobile"
@@ -22,6 +23,10 @@ func NewService(
 		return nexmo.NewSMSService(settings.Nexmo)
 	case model.SMSServiceRouteMobile:
 		return routemobile.NewSMSService(settings.Routemobile)
+	case model.SMSServiceHTTP:
+		return httpsms.NewSMSService(settings.HTTP)
+	case model.SMSServiceFailover:
+		return NewFailoverService(logger, settings.Failover)
 	case model.SMSServiceMock:
 		return mock.NewSMSService(logger)
 	}
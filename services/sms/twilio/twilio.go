@@ -8,6 +8,7 @@ import (
 
 	"github.com/madappgang/identifo/v2/model"
 	"github.com/twilio/twilio-go"
+	twilioClient "github.com/twilio/twilio-go/client"
 	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
@@ -86,6 +87,9 @@ func (ss *SMSService) SendSMS(recipient, message string) error {
 		return errors.New("twilio SMS service has no sendFrom nor messagingServiceSid for sending the message configured")
 	}
 	resp, err := ss.client.Api.CreateMessage(params)
+	if restErr := (*twilioClient.TwilioRestError)(nil); errors.As(err, &restErr) {
+		return model.SMSStatusError(restErr.Status, err)
+	}
 	if err != nil {
 		return err
 	}
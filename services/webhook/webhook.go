@@ -0,0 +1,337 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+const (
+	// EventHeader is a header with the event name.
+	EventHeader = "X-Identifo-Event"
+	// DeliveryHeader is a header with the delivery ID, it is the same for all attempts of the delivery.
+	DeliveryHeader = "X-Identifo-Delivery"
+
+	// claimBatchSize is the max number of deliveries sent at once.
+	claimBatchSize = 50
+	// maxErrorBodySize is how much of the failed response body is kept in the delivery.
+	maxErrorBodySize = 512
+)
+
+// PollInterval is how often the queue is checked for due retries.
+// New events are sent right away, without waiting for the next poll.
+var PollInterval = 10 * time.Second
+
+// PurgeInterval is how often the finished deliveries older than the retention are deleted.
+var PurgeInterval = time.Hour
+
+// Service queues the events to the delivery storage and sends them to the subscribed endpoints.
+// The queue is persistent, so the deliveries are retried after restart,
+// and it is shared by all server instances.
+type Service struct {
+	logger   *slog.Logger
+	settings model.WebhookSettings
+	storage  model.WebhookDeliveryStorage
+	apps     model.AppStorage
+	client   *http.Client
+
+	nudge chan struct{}
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// NewService creates the webhook service and starts the delivery worker.
+func NewService(
+	logger *slog.Logger,
+	settings model.WebhookSettings,
+	storage model.WebhookDeliveryStorage,
+	apps model.AppStorage,
+) *Service {
+	s := &Service{
+		logger:   logger,
+		settings: settings,
+		storage:  storage,
+		apps:     apps,
+		client:   &http.Client{Timeout: time.Duration(settings.Timeout) * time.Second},
+		nudge:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+// Emit queues the event for the server-wide subscriptions and the app subscriptions.
+// The errors are logged, the event never fails the operation which has emitted it.
+func (s *Service) Emit(event model.WebhookEvent, app model.AppData, userID string, data map[string]interface{}) {
+	type target struct {
+		appID string
+		url   string
+	}
+	targets := []target{}
+	for _, sub := range s.settings.Subscriptions {
+		if sub.Subscribed(event) {
+			targets = append(targets, target{url: sub.URL})
+		}
+	}
+	for _, sub := range app.Webhooks {
+		if sub.Subscribed(event) {
+			targets = append(targets, target{appID: app.ID, url: sub.URL})
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(model.WebhookPayload{
+		ID:     xid.New().String(),
+		Event:  event,
+		Time:   now,
+		AppID:  app.ID,
+		UserID: userID,
+		Data:   data,
+	})
+	if err != nil {
+		s.logger.Error("Unable to marshal webhook payload",
+			"event", string(event),
+			logging.FieldError, err)
+		return
+	}
+
+	for _, t := range targets {
+		_, err := s.storage.AddDelivery(model.WebhookDelivery{
+			Event:         event,
+			AppID:         t.appID,
+			URL:           t.url,
+			Payload:       string(payload),
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			s.logger.Error("Unable to queue webhook delivery",
+				"event", string(event),
+				"url", t.url,
+				logging.FieldError, err)
+		}
+	}
+	s.wakeUp()
+}
+
+// Replay queues the delivery again with the attempts counter reset.
+func (s *Service) Replay(id string) (model.WebhookDelivery, error) {
+	d, err := s.storage.DeliveryByID(id)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	d.Status = model.WebhookDeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+	d.LastError = ""
+	d.LastStatusCode = 0
+
+	if err := s.storage.UpdateDelivery(d); err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	s.wakeUp()
+	return d, nil
+}
+
+// Close stops the worker and waits for the deliveries in flight.
+func (s *Service) Close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+}
+
+func (s *Service) wakeUp() {
+	select {
+	case s.nudge <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	purgeTicker := time.NewTicker(PurgeInterval)
+	defer purgeTicker.Stop()
+
+	s.purge()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-purgeTicker.C:
+			s.purge()
+			continue
+		case <-ticker.C:
+		case <-s.nudge:
+		}
+		s.sendDue()
+	}
+}
+
+// purge deletes the delivered and dead deliveries older than the retention.
+func (s *Service) purge() {
+	n, err := s.storage.DeleteFinishedDeliveries(time.Now().Add(-time.Duration(s.settings.Retention) * time.Second))
+	if err != nil {
+		s.logger.Error("Unable to delete finished webhook deliveries", logging.FieldError, err)
+		return
+	}
+	if n > 0 {
+		s.logger.Info("Finished webhook deliveries deleted", "count", n)
+	}
+}
+
+// sendDue sends all due deliveries batch by batch.
+func (s *Service) sendDue() {
+	// the claim lease has to outlive the request, otherwise the delivery could be claimed again while in flight
+	lease := s.client.Timeout + time.Minute
+
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		due, err := s.storage.ClaimDueDeliveries(time.Now(), lease, claimBatchSize)
+		if err != nil {
+			s.logger.Error("Unable to claim webhook deliveries", logging.FieldError, err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, d := range due {
+			wg.Add(1)
+			go func(d model.WebhookDelivery) {
+				defer wg.Done()
+				s.deliver(d)
+			}(d)
+		}
+		wg.Wait()
+
+		if len(due) < claimBatchSize {
+			return
+		}
+	}
+}
+
+// deliver makes one delivery attempt and schedules the retry or moves the delivery to the dead letter list.
+func (s *Service) deliver(d model.WebhookDelivery) {
+	now := time.Now()
+	d.Attempts++
+	d.LastAttemptAt = now
+
+	secret, ok := s.secret(d)
+	if !ok {
+		// the subscription has been removed, there is nothing to sign the request with
+		d.Status = model.WebhookDeliveryDead
+		d.NextAttemptAt = now
+		d.LastStatusCode = 0
+		d.LastError = "subscription not found"
+		s.update(d)
+		return
+	}
+
+	status, err := s.send(d, secret)
+	d.LastStatusCode = status
+	switch {
+	case err == nil:
+		d.Status = model.WebhookDeliveryDelivered
+		d.NextAttemptAt = now
+		d.LastError = ""
+	case d.Attempts >= s.settings.MaxAttempts:
+		d.Status = model.WebhookDeliveryDead
+		d.NextAttemptAt = now
+		d.LastError = err.Error()
+		s.logger.Warn("Webhook delivery moved to dead letter list",
+			"delivery", d.ID,
+			"event", string(d.Event),
+			"url", d.URL,
+			logging.FieldError, err)
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = now.Add(s.settings.Backoff(d.Attempts))
+	}
+	s.update(d)
+}
+
+func (s *Service) update(d model.WebhookDelivery) {
+	if err := s.storage.UpdateDelivery(d); err != nil {
+		s.logger.Error("Unable to update webhook delivery",
+			"delivery", d.ID,
+			logging.FieldError, err)
+	}
+}
+
+// secret returns the secret of the subscription the delivery has been queued for.
+func (s *Service) secret(d model.WebhookDelivery) (string, bool) {
+	subscriptions := s.settings.Subscriptions
+	if len(d.AppID) > 0 {
+		app, err := s.apps.AppByID(d.AppID)
+		if err != nil {
+			return "", false
+		}
+		subscriptions = app.Webhooks
+	}
+
+	for _, sub := range subscriptions {
+		if sub.URL == d.URL {
+			return sub.Secret, true
+		}
+	}
+	return "", false
+}
+
+// send posts the payload signed with HMAC SHA-256 of the body in the Digest header,
+// any 2xx response is a successful delivery.
+func (s *Service) send(d model.WebhookDelivery, secret string) (int, error) {
+	body := []byte(d.Payload)
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	sha := hex.EncodeToString(h.Sum(nil))
+
+	request, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating webhook request: %w", err)
+	}
+	request.Header.Set("Digest", "SHA-256="+sha)
+	request.Header.Set("Content-type", "application/json")
+	request.Header.Set(EventHeader, string(d.Event))
+	request.Header.Set(DeliveryHeader, d.ID)
+
+	resp, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(b))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,206 @@
+package webhook_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/services/webhook"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "webhook-secret"
+
+func init() {
+	webhook.PollInterval = 50 * time.Millisecond
+}
+
+func testService(t *testing.T, settings model.WebhookSettings) (*webhook.Service, model.WebhookDeliveryStorage, model.AppStorage) {
+	t.Helper()
+
+	storage, err := mem.NewWebhookDeliveryStorage()
+	require.NoError(t, err)
+	apps, err := mem.NewAppStorage(logging.DefaultLogger)
+	require.NoError(t, err)
+
+	if settings.MaxAttempts == 0 {
+		settings.MaxAttempts = 3
+	}
+	if settings.Retention == 0 {
+		settings.Retention = 60 * 60
+	}
+	settings.InitialBackoff = 1
+	settings.MaxBackoff = 1
+	settings.Timeout = 5
+
+	s := webhook.NewService(logging.DefaultLogger, settings, storage, apps)
+	t.Cleanup(s.Close)
+	return s, storage, apps
+}
+
+func waitForStatus(t *testing.T, storage model.WebhookDeliveryStorage, status model.WebhookDeliveryStatus, count int) []model.WebhookDelivery {
+	t.Helper()
+
+	var deliveries []model.WebhookDelivery
+	require.Eventually(t, func() bool {
+		var err error
+		deliveries, _, err = storage.FetchDeliveries(status, 0, 0)
+		return err == nil && len(deliveries) == count
+	}, 5*time.Second, 20*time.Millisecond)
+	return deliveries
+}
+
+func TestWebhookSignedDelivery(t *testing.T) {
+	received := make(chan model.WebhookPayload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		h := hmac.New(sha256.New, []byte(testSecret))
+		h.Write(body)
+		if r.Header.Get("Digest") != "SHA-256="+hex.EncodeToString(h.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var p model.WebhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, string(p.Event), r.Header.Get(webhook.EventHeader))
+		assert.NotEmpty(t, r.Header.Get(webhook.DeliveryHeader))
+		received <- p
+	}))
+	defer srv.Close()
+
+	s, storage, apps := testService(t, model.WebhookSettings{
+		Subscriptions: []model.WebhookSubscription{
+			{URL: srv.URL + "/server", Secret: testSecret, Events: []model.WebhookEvent{model.WebhookEventUserRegistered}},
+			{URL: srv.URL + "/deleted", Secret: testSecret, Events: []model.WebhookEvent{model.WebhookEventUserDeleted}},
+		},
+	})
+
+	s.Emit(model.WebhookEventUserRegistered, model.AppData{}, "user1", map[string]interface{}{"email": "user1@example.com"})
+
+	select {
+	case p := <-received:
+		assert.Equal(t, model.WebhookEventUserRegistered, p.Event)
+		assert.Equal(t, "user1", p.UserID)
+		assert.Equal(t, "user1@example.com", p.Data["email"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook has not been delivered")
+	}
+	delivered := waitForStatus(t, storage, model.WebhookDeliveryDelivered, 1)
+	assert.Equal(t, 1, delivered[0].Attempts)
+	assert.Equal(t, http.StatusOK, delivered[0].LastStatusCode)
+
+	// the app subscription secret is looked up in the app storage
+	app, err := apps.CreateApp(model.AppData{
+		Active: true,
+		Webhooks: []model.WebhookSubscription{
+			{URL: srv.URL + "/app", Secret: testSecret, Events: []model.WebhookEvent{model.WebhookEventUserLogin}},
+		},
+	})
+	require.NoError(t, err)
+
+	s.Emit(model.WebhookEventUserLogin, app, "user1", nil)
+	select {
+	case p := <-received:
+		assert.Equal(t, model.WebhookEventUserLogin, p.Event)
+		assert.Equal(t, app.ID, p.AppID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("app webhook has not been delivered")
+	}
+	waitForStatus(t, storage, model.WebhookDeliveryDelivered, 2)
+
+	// the subscription of unknown app can't be signed
+	unknown := app
+	unknown.ID = "unknown"
+	s.Emit(model.WebhookEventUserLogin, unknown, "user1", nil)
+	dead := waitForStatus(t, storage, model.WebhookDeliveryDead, 1)
+	assert.Equal(t, "unknown", dead[0].AppID)
+	assert.Equal(t, "subscription not found", dead[0].LastError)
+}
+
+func TestWebhookRetryAndReplay(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	s, storage, _ := testService(t, model.WebhookSettings{
+		MaxAttempts: 2,
+		Subscriptions: []model.WebhookSubscription{
+			{URL: srv.URL, Secret: testSecret},
+		},
+	})
+
+	s.Emit(model.WebhookEventUserDeleted, model.AppData{}, "user1", nil)
+
+	dead := waitForStatus(t, storage, model.WebhookDeliveryDead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, dead[0].LastStatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+
+	fail.Store(false)
+	replayed, err := s.Replay(dead[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.WebhookDeliveryPending, replayed.Status)
+	assert.Zero(t, replayed.Attempts)
+
+	delivered := waitForStatus(t, storage, model.WebhookDeliveryDelivered, 1)
+	assert.Equal(t, dead[0].ID, delivered[0].ID)
+	assert.Equal(t, 1, delivered[0].Attempts)
+
+	_, err = s.Replay("missing")
+	assert.ErrorIs(t, err, model.ErrorNotFound)
+}
+
+func TestWebhookPurge(t *testing.T) {
+	storage, err := mem.NewWebhookDeliveryStorage()
+	require.NoError(t, err)
+	apps, err := mem.NewAppStorage(logging.DefaultLogger)
+	require.NoError(t, err)
+
+	now := time.Now()
+	for _, d := range []model.WebhookDelivery{
+		{Status: model.WebhookDeliveryDelivered, NextAttemptAt: now.Add(-2 * time.Minute)},
+		{Status: model.WebhookDeliveryDead, NextAttemptAt: now},
+		{Status: model.WebhookDeliveryPending, NextAttemptAt: now.Add(time.Hour)},
+	} {
+		_, err := storage.AddDelivery(d)
+		require.NoError(t, err)
+	}
+
+	// the finished deliveries older than the retention are deleted on start
+	s := webhook.NewService(logging.DefaultLogger, model.WebhookSettings{Retention: 60, Timeout: 5}, storage, apps)
+	defer s.Close()
+
+	waitForStatus(t, storage, model.WebhookDeliveryDelivered, 0)
+	waitForStatus(t, storage, model.WebhookDeliveryDead, 1)
+	waitForStatus(t, storage, model.WebhookDeliveryPending, 1)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	ws := model.WebhookSettings{InitialBackoff: 30, MaxBackoff: 100}
+	assert.Equal(t, 30*time.Second, ws.Backoff(1))
+	assert.Equal(t, 60*time.Second, ws.Backoff(2))
+	assert.Equal(t, 100*time.Second, ws.Backoff(3))
+}
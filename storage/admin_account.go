@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/madappgang/identifo/v2/storage/dynamodb"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+)
+
+// NewAdminAccountStorage creates new admin account storage from settings
+func NewAdminAccountStorage(
+	logger *slog.Logger,
+	settings model.DatabaseSettings) (model.AdminAccountStorage, error) {
+	switch settings.Type {
+	case model.DBTypeBoltDB:
+		return boltdb.NewAdminAccountStorage(logger, settings.BoltDB)
+	case model.DBTypeMongoDB:
+		return mongo.NewAdminAccountStorage(logger, settings.Mongo)
+	case model.DBTypeDynamoDB:
+		return dynamodb.NewAdminAccountStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewAdminAccountStorage(logger, settings.Type, settings.SQL)
+	case model.DBTypeFake:
+		fallthrough
+	case model.DBTypeMem:
+		return mem.NewAdminAccountStorage()
+	default:
+		return nil, fmt.Errorf("admin account storage type is not supported %s ", settings.Type)
+	}
+}
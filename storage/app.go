@@ -9,6 +9,7 @@ import (
 	"github.com/madappgang/identifo/v2/storage/dynamodb"
 	"github.com/madappgang/identifo/v2/storage/mem"
 	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
 )
 
 // NewAppStorage creates new app storage from settings
@@ -22,6 +23,8 @@ func NewAppStorage(
 		return mongo.NewAppStorage(logger, settings.Mongo)
 	case model.DBTypeDynamoDB:
 		return dynamodb.NewAppStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewAppStorage(logger, settings.Type, settings.SQL)
 	case model.DBTypeFake:
 		fallthrough
 	case model.DBTypeMem:
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/madappgang/identifo/v2/storage/dynamodb"
+	"github.com/madappgang/identifo/v2/storage/file"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+)
+
+// NewAuditStorage creates new audit storage from settings
+func NewAuditStorage(
+	logger *slog.Logger,
+	settings model.DatabaseSettings) (model.AuditStorage, error) {
+	switch settings.Type {
+	case model.DBTypeBoltDB:
+		return boltdb.NewAuditStorage(logger, settings.BoltDB)
+	case model.DBTypeMongoDB:
+		return mongo.NewAuditStorage(logger, settings.Mongo)
+	case model.DBTypeDynamoDB:
+		return dynamodb.NewAuditStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewAuditStorage(logger, settings.Type, settings.SQL)
+	case model.DBTypeFile:
+		return file.NewAuditStorage(logger, settings.File)
+	case model.DBTypeFake:
+		fallthrough
+	case model.DBTypeMem:
+		return mem.NewAuditStorage()
+	default:
+		return nil, fmt.Errorf("audit storage type is not supported %s ", settings.Type)
+	}
+}
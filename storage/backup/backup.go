@@ -0,0 +1,189 @@
+// Package backup writes the apps, management keys, users and invites to the ZIP archive and restores them from it.
+// Every file of the archive is the JSON array produced by ExportJSON of the storage, which could be passed to ImportJSON.
+package backup
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// Version is the version of the archive format.
+const Version = 1
+
+// ManifestFile is the name of the archive file with the manifest.
+const ManifestFile = "manifest.json"
+
+// Names of the archive files with the exported data.
+const (
+	AppsFile           = "apps.json"
+	ManagementKeysFile = "management_keys.json"
+	UsersFile          = "users.json"
+	InvitesFile        = "invites.json"
+)
+
+var (
+	// ErrorNoManifest means the archive is not the backup.
+	ErrorNoManifest = errors.New("backup manifest not found")
+	// ErrorUnsupportedVersion means the archive is written by the newer version.
+	ErrorUnsupportedVersion = errors.New("unsupported backup version")
+	// ErrorNoSnapshot means some storages could not export the consistent snapshot of their data.
+	ErrorNoSnapshot = errors.New("storages could not export the consistent snapshot")
+)
+
+// Storages are the storages included in the backup, nil storages are skipped.
+type Storages struct {
+	App            model.AppStorage
+	User           model.UserStorage
+	Invite         model.InviteStorage
+	ManagementKeys model.ManagementKeysStorage
+}
+
+// Manifest describes the backup, it is the last file of the archive.
+type Manifest struct {
+	Version int `json:"version"`
+	// StartedAt and FinishedAt are the time range of the backup.
+	// Every storage is exported from its own snapshot taken within the range,
+	// the snapshots of the different storages are not taken at the same time.
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Files      []string  `json:"files"`
+	// WithoutSnapshot are the files exported without the snapshot, when it is allowed,
+	// the records changed during their export could be in either state.
+	WithoutSnapshot []string `json:"without_snapshot,omitempty"`
+}
+
+// jsonStorage is the part of the storage interfaces used by the backup.
+type jsonStorage interface {
+	ImportJSON(data []byte, clearOldData bool) error
+	ExportJSON(w io.Writer) error
+}
+
+type storageFile struct {
+	name    string
+	storage jsonStorage
+}
+
+// files returns the files of the storages which are set, in the order of the import:
+// the apps go first, as the users and invites refer to them.
+func (s Storages) files() []storageFile {
+	var files []storageFile
+	if s.App != nil {
+		files = append(files, storageFile{AppsFile, s.App})
+	}
+	if s.ManagementKeys != nil {
+		files = append(files, storageFile{ManagementKeysFile, s.ManagementKeys})
+	}
+	if s.User != nil {
+		files = append(files, storageFile{UsersFile, s.User})
+	}
+	if s.Invite != nil {
+		files = append(files, storageFile{InvitesFile, s.Invite})
+	}
+	return files
+}
+
+// WithoutSnapshot returns the files of the storages, which could not export the consistent snapshot:
+// DynamoDB, REST and plugin storages, and MongoDB standalone server.
+func (s Storages) WithoutSnapshot() []string {
+	var files []string
+	for _, f := range s.files() {
+		if se, ok := f.storage.(model.SnapshotExporter); !ok || !se.ExportsSnapshot() {
+			files = append(files, f.name)
+		}
+	}
+	return files
+}
+
+// Write streams the backup archive of the storages to the writer.
+// The data is not kept in memory, so the writer could be the HTTP response.
+// The storages are exported one by one, so the archive is consistent within every storage only,
+// the writes made to the other storages during the export are not synchronized with it.
+// ErrorNoSnapshot is returned before anything is written if some storages could not export the snapshot,
+// unless allowWithoutSnapshot is set.
+func Write(w io.Writer, s Storages, allowWithoutSnapshot bool) (Manifest, error) {
+	manifest := Manifest{Version: Version, StartedAt: time.Now().UTC(), WithoutSnapshot: s.WithoutSnapshot()}
+	if len(manifest.WithoutSnapshot) > 0 && !allowWithoutSnapshot {
+		return manifest, fmt.Errorf("%w: %v", ErrorNoSnapshot, manifest.WithoutSnapshot)
+	}
+	zw := zip.NewWriter(w)
+
+	for _, f := range s.files() {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return manifest, err
+		}
+		if err := f.storage.ExportJSON(fw); err != nil {
+			return manifest, fmt.Errorf("unable to export %s: %w", f.name, err)
+		}
+		manifest.Files = append(manifest.Files, f.name)
+	}
+
+	manifest.FinishedAt = time.Now().UTC()
+	fw, err := zw.Create(ManifestFile)
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.NewEncoder(fw).Encode(manifest); err != nil {
+		return manifest, err
+	}
+	return manifest, zw.Close()
+}
+
+// Restore imports the backup archive to the storages.
+// If clearOldData is set, the existing data of the restored storages is deleted.
+// The files missing in the archive are not restored, the storages which are not set are skipped.
+func Restore(r io.ReaderAt, size int64, s Storages, clearOldData bool) (Manifest, error) {
+	var manifest Manifest
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return manifest, err
+	}
+
+	data, err := readFile(zr, ManifestFile)
+	if err != nil {
+		return manifest, err
+	}
+	if data == nil {
+		return manifest, ErrorNoManifest
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("unable to read backup manifest: %w", err)
+	}
+	if manifest.Version > Version {
+		return manifest, fmt.Errorf("%w: %d", ErrorUnsupportedVersion, manifest.Version)
+	}
+
+	for _, f := range s.files() {
+		data, err := readFile(zr, f.name)
+		if err != nil {
+			return manifest, err
+		}
+		if data == nil {
+			continue
+		}
+		if err := f.storage.ImportJSON(data, clearOldData); err != nil {
+			return manifest, fmt.Errorf("unable to import %s: %w", f.name, err)
+		}
+	}
+	return manifest, nil
+}
+
+// readFile returns the content of the archive file, or nil if there is no such file.
+func readFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,110 @@
+package backup_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/backup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openStorages(t *testing.T, settings model.DatabaseSettings) backup.Storages {
+	t.Helper()
+	s, err := backup.OpenStorages(logging.DefaultLogger, model.StorageSettings{
+		AppStorage:            settings,
+		UserStorage:           settings,
+		InviteStorage:         settings,
+		ManagementKeysStorage: settings,
+	})
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestBackupRestore(t *testing.T) {
+	from := openStorages(t, model.DatabaseSettings{
+		Type:   model.DBTypeBoltDB,
+		BoltDB: model.BoltDBDatabaseSettings{Path: filepath.Join(t.TempDir(), "identifo.db")},
+	})
+
+	_, err := from.App.CreateApp(model.AppData{ID: "app1", Name: "App 1", Active: true})
+	require.NoError(t, err)
+	require.NoError(t, from.ManagementKeys.ImportJSON([]byte(`[{"id":"key1","secret":"secret","active":true}]`), false))
+	user, err := from.User.AddUserWithPassword(model.User{Username: "user", Email: "user@example.com"}, "password", "user", false)
+	require.NoError(t, err)
+	require.NoError(t, from.Invite.Save("invite@example.com", "token", "user", "app1", "admin", time.Now().Add(time.Hour)))
+	invites, _, err := from.Invite.GetAll(true, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, invites, 1)
+
+	var archive bytes.Buffer
+	manifest, err := backup.Write(&archive, from, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{backup.AppsFile, backup.ManagementKeysFile, backup.UsersFile, backup.InvitesFile}, manifest.Files)
+	assert.Empty(t, manifest.WithoutSnapshot)
+
+	for name, settings := range map[string]model.DatabaseSettings{
+		"sqlite": {Type: model.DBTypeSQLite, SQL: model.SQLDatabaseSettings{ConnectionString: filepath.Join(t.TempDir(), "identifo.db")}},
+		"mem":    {Type: model.DBTypeMem},
+	} {
+		t.Run(name, func(t *testing.T) {
+			to := openStorages(t, settings)
+
+			_, err := backup.Restore(bytes.NewReader(archive.Bytes()), int64(archive.Len()), to, true)
+			require.NoError(t, err)
+
+			_, err = to.App.AppByID("app1")
+			require.NoError(t, err)
+			_, err = to.ManagementKeys.GetKey(context.Background(), "key1")
+			require.NoError(t, err)
+
+			// the password hash is restored as is, so the user could log in with the old password.
+			restored, err := to.User.UserByUsername("user")
+			require.NoError(t, err)
+			assert.Equal(t, user.ID, restored.ID)
+			require.NoError(t, to.User.CheckPassword(user.ID, "password"))
+
+			restoredInvites, _, err := to.Invite.GetAll(true, 0, 0)
+			require.NoError(t, err)
+			require.Len(t, restoredInvites, 1)
+			assert.Equal(t, invites[0].ID, restoredInvites[0].ID)
+			assert.Equal(t, invites[0].Token, restoredInvites[0].Token)
+			assert.True(t, invites[0].CreatedAt.Equal(restoredInvites[0].CreatedAt))
+
+			// the restored data could be backed up again, the memory storages have no snapshot.
+			var again bytes.Buffer
+			allow := name == "mem"
+			assert.Equal(t, allow, len(to.WithoutSnapshot()) > 0)
+			if allow {
+				_, err = backup.Write(&again, to, false)
+				require.ErrorIs(t, err, backup.ErrorNoSnapshot)
+				assert.Zero(t, again.Len())
+			}
+			manifest, err := backup.Write(&again, to, allow)
+			require.NoError(t, err)
+			assert.Equal(t, to.WithoutSnapshot(), manifest.WithoutSnapshot)
+			copied := openStorages(t, model.DatabaseSettings{Type: model.DBTypeMem})
+			_, err = backup.Restore(bytes.NewReader(again.Bytes()), int64(again.Len()), copied, false)
+			require.NoError(t, err)
+			require.NoError(t, copied.User.CheckPassword(user.ID, "password"))
+		})
+	}
+}
+
+func TestRestoreWithoutManifest(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	_, err := zw.Create(backup.UsersFile)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	_, err = backup.Restore(bytes.NewReader(archive.Bytes()), int64(archive.Len()), backup.Storages{}, false)
+	assert.ErrorIs(t, err, backup.ErrorNoManifest)
+}
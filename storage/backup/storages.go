@@ -0,0 +1,55 @@
+package backup
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage"
+)
+
+// OpenStorages creates the backed up storages from the server storage settings,
+// the storages without the type use the default storage, as in the server.
+func OpenStorages(logger *slog.Logger, settings model.StorageSettings) (Storages, error) {
+	dbSettings := func(s model.DatabaseSettings) model.DatabaseSettings {
+		if s.Type == model.DBTypeDefault {
+			return settings.DefaultStorage
+		}
+		return s
+	}
+
+	var (
+		s   Storages
+		err error
+	)
+	if s.App, err = storage.NewAppStorage(logger, dbSettings(settings.AppStorage)); err != nil {
+		return Storages{}, fmt.Errorf("unable to create app storage: %w", err)
+	}
+	if s.User, err = storage.NewUserStorage(logger, dbSettings(settings.UserStorage)); err != nil {
+		s.Close()
+		return Storages{}, fmt.Errorf("unable to create user storage: %w", err)
+	}
+	if s.Invite, err = storage.NewInviteStorage(logger, dbSettings(settings.InviteStorage)); err != nil {
+		s.Close()
+		return Storages{}, fmt.Errorf("unable to create invite storage: %w", err)
+	}
+	if s.ManagementKeys, err = storage.NewManagementKeys(logger, dbSettings(settings.ManagementKeysStorage)); err != nil {
+		s.Close()
+		return Storages{}, fmt.Errorf("unable to create management keys storage: %w", err)
+	}
+	return s, nil
+}
+
+// Close closes the opened storages.
+func (s Storages) Close() {
+	if s.App != nil {
+		s.App.Close()
+	}
+	if s.User != nil {
+		s.User.Close()
+	}
+	if s.Invite != nil {
+		s.Invite.Close()
+	}
+	// management keys storage has no Close method.
+}
@@ -0,0 +1,192 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+const (
+	// AdminAccountBucket is a name for bucket with admin accounts.
+	AdminAccountBucket = "AdminAccounts"
+)
+
+// AdminAccountStorage is a BoltDB admin account storage.
+type AdminAccountStorage struct {
+	logger *slog.Logger
+	db     *bolt.DB
+}
+
+// NewAdminAccountStorage creates a BoltDB admin account storage.
+func NewAdminAccountStorage(
+	logger *slog.Logger,
+	settings model.BoltDBDatabaseSettings,
+) (model.AdminAccountStorage, error) {
+	if len(settings.Path) == 0 {
+		return nil, ErrorEmptyDatabasePath
+	}
+
+	// init database
+	db, err := InitDB(settings.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	as := &AdminAccountStorage{
+		logger: logger,
+		db:     db,
+	}
+	// Ensure that we have needed bucket in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(AdminAccountBucket)); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return as, nil
+}
+
+// AdminAccounts returns all accounts ordered by login.
+func (as *AdminAccountStorage) AdminAccounts() ([]model.AdminAccount, error) {
+	accounts := []model.AdminAccount{}
+
+	err := as.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(AdminAccountBucket)).ForEach(func(k, v []byte) error {
+			var account model.AdminAccount
+			if err := json.Unmarshal(v, &account); err != nil {
+				return err
+			}
+			accounts = append(accounts, account)
+			return nil
+		})
+	})
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Login < accounts[j].Login
+	})
+	return accounts, err
+}
+
+// AdminAccountByID returns the account by its id.
+func (as *AdminAccountStorage) AdminAccountByID(id string) (model.AdminAccount, error) {
+	var account model.AdminAccount
+
+	err := as.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(AdminAccountBucket)).Get([]byte(id))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+		return json.Unmarshal(data, &account)
+	})
+	return account, err
+}
+
+// AdminAccountByLogin returns the account by its login.
+func (as *AdminAccountStorage) AdminAccountByLogin(login string) (model.AdminAccount, error) {
+	var account model.AdminAccount
+
+	err := as.db.View(func(tx *bolt.Tx) error {
+		var err error
+		account, err = byLogin(tx.Bucket([]byte(AdminAccountBucket)), login)
+		return err
+	})
+	return account, err
+}
+
+// AddAdminAccount adds new account.
+func (as *AdminAccountStorage) AddAdminAccount(account model.AdminAccount) (model.AdminAccount, error) {
+	err := as.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(AdminAccountBucket))
+		if _, err := byLogin(b, account.Login); err == nil {
+			return model.ErrorAdminAccountExists
+		} else if err != model.ErrorNotFound {
+			return err
+		}
+
+		account.ID = xid.New().String()
+		return as.put(b, account)
+	})
+	if err != nil {
+		return model.AdminAccount{}, err
+	}
+	return account, nil
+}
+
+// UpdateAdminAccount updates existing account.
+func (as *AdminAccountStorage) UpdateAdminAccount(account model.AdminAccount) error {
+	return as.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(AdminAccountBucket))
+		data := b.Get([]byte(account.ID))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+
+		var old model.AdminAccount
+		if err := json.Unmarshal(data, &old); err != nil {
+			return err
+		}
+		account.Login = old.Login
+		return as.put(b, account)
+	})
+}
+
+// DeleteAdminAccount deletes account by its id.
+func (as *AdminAccountStorage) DeleteAdminAccount(id string) error {
+	return as.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(AdminAccountBucket))
+		if b.Get([]byte(id)) == nil {
+			return model.ErrorNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+func (as *AdminAccountStorage) put(b *bolt.Bucket, account model.AdminAccount) error {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(account.ID), data)
+}
+
+// byLogin looks for the account by login, there are a few admin accounts, so the bucket is scanned.
+func byLogin(b *bolt.Bucket, login string) (model.AdminAccount, error) {
+	var account model.AdminAccount
+	found := false
+
+	err := b.ForEach(func(k, v []byte) error {
+		if found {
+			return nil
+		}
+		var a model.AdminAccount
+		if err := json.Unmarshal(v, &a); err != nil {
+			return err
+		}
+		if a.Login == login {
+			account, found = a, true
+		}
+		return nil
+	})
+	if err != nil {
+		return model.AdminAccount{}, err
+	}
+	if !found {
+		return model.AdminAccount{}, model.ErrorNotFound
+	}
+	return account, nil
+}
+
+// Close closes underlying database.
+func (as *AdminAccountStorage) Close() {
+	if err := CloseDB(as.db); err != nil {
+		as.logger.Error("Error closing admin account storage", logging.FieldError, err)
+	}
+}
@@ -0,0 +1,50 @@
+package boltdb_test
+
+import (
+	"testing"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltDBAdminAccounts(t *testing.T) {
+	sts := model.BoltDBDatabaseSettings{
+		Path: dbpath,
+	}
+	storage, err := boltdb.NewAdminAccountStorage(logging.DefaultLogger, sts)
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	login := "admin-" + xid.New().String()
+	account, err := storage.AddAdminAccount(model.AdminAccount{
+		Login:  login,
+		Role:   model.AdminRoleViewer,
+		Active: true,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, account.ID)
+
+	_, err = storage.AddAdminAccount(model.AdminAccount{Login: login})
+	assert.Equal(t, model.ErrorAdminAccountExists, err)
+
+	found, err := storage.AdminAccountByLogin(login)
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, found.ID)
+
+	found.Role = model.AdminRoleSuperadmin
+	require.NoError(t, storage.UpdateAdminAccount(found))
+
+	found, err = storage.AdminAccountByID(account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.AdminRoleSuperadmin, found.Role)
+
+	require.NoError(t, storage.DeleteAdminAccount(account.ID))
+	_, err = storage.AdminAccountByID(account.ID)
+	assert.Equal(t, model.ErrorNotFound, err)
+	assert.Equal(t, model.ErrorNotFound, storage.DeleteAdminAccount(account.ID))
+}
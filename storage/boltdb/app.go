@@ -3,6 +3,7 @@ package boltdb
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"strings"
 
@@ -218,6 +219,16 @@ func (as *AppStorage) ImportJSON(data []byte, cleanOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports all apps to JSON.
+func (as *AppStorage) ExportJSON(w io.Writer) error {
+	return exportBucket[model.AppData](as.db, AppBucket, w)
+}
+
+// ExportsSnapshot reports that the export is read in a single transaction.
+func (as *AppStorage) ExportsSnapshot() bool {
+	return true
+}
+
 // Close closes underlying database.
 func (as *AppStorage) Close() {
 	if err := CloseDB(as.db); err != nil {
@@ -0,0 +1,107 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rs/xid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	// AuditBucket is a name for bucket with audit records.
+	AuditBucket = "Audit"
+)
+
+// AuditStorage is a BoltDB audit storage.
+// Records are keyed by xid, which is sorted by creation time.
+type AuditStorage struct {
+	logger *slog.Logger
+	db     *bolt.DB
+}
+
+// NewAuditStorage creates a BoltDB audit storage.
+func NewAuditStorage(
+	logger *slog.Logger,
+	settings model.BoltDBDatabaseSettings,
+) (model.AuditStorage, error) {
+	if len(settings.Path) == 0 {
+		return nil, ErrorEmptyDatabasePath
+	}
+
+	// init database
+	db, err := InitDB(settings.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	as := &AuditStorage{
+		logger: logger,
+		db:     db,
+	}
+	// Ensure that we have needed bucket in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(AuditBucket)); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return as, nil
+}
+
+// AddRecord appends the record to the log.
+func (as *AuditStorage) AddRecord(record model.AuditRecord) error {
+	record.ID = xid.New().String()
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return as.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(AuditBucket)).Put([]byte(record.ID), data)
+	})
+}
+
+// FetchRecords returns the records matching the filter, newest first.
+func (as *AuditStorage) FetchRecords(filter model.AuditFilter) ([]model.AuditRecord, int, error) {
+	result := []model.AuditRecord{}
+	total := 0
+
+	err := as.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(AuditBucket)).Cursor()
+
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var record model.AuditRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if !filter.Matches(record) {
+				continue
+			}
+			total++
+			if total > filter.Skip && (filter.Limit <= 0 || len(result) < filter.Limit) {
+				result = append(result, record)
+			}
+		}
+		return nil
+	})
+	return result, total, err
+}
+
+// Close closes underlying database.
+func (as *AuditStorage) Close() {
+	if err := CloseDB(as.db); err != nil {
+		as.logger.Error("Error closing audit storage", logging.FieldError, err)
+	}
+}
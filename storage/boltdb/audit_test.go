@@ -0,0 +1,61 @@
+package boltdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltDBAudit(t *testing.T) {
+	sts := model.BoltDBDatabaseSettings{
+		Path: dbpath,
+	}
+	storage, err := boltdb.NewAuditStorage(logging.DefaultLogger, sts)
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	start := time.Now().Add(-time.Hour)
+	records := []model.AuditRecord{
+		{Time: start, Operation: model.AuditOperationLoginWithPassword, UserID: "audit_user1", AppID: "audit_app1"},
+		{Time: start.Add(time.Minute), Operation: model.AuditOperationLogout, UserID: "audit_user1", AppID: "audit_app1"},
+		{Time: start.Add(2 * time.Minute), Operation: model.AuditOperationLoginWithPassword, UserID: "audit_user2", AppID: "audit_app1"},
+	}
+	for _, r := range records {
+		require.NoError(t, storage.AddRecord(r))
+	}
+
+	result, total, err := storage.FetchRecords(model.AuditFilter{AppID: "audit_app1"})
+	require.NoError(t, err)
+	require.Equal(t, 3, total)
+	require.Len(t, result, 3)
+	// newest first
+	assert.Equal(t, "audit_user2", result[0].UserID)
+	assert.NotEmpty(t, result[0].ID)
+
+	result, total, err = storage.FetchRecords(model.AuditFilter{UserID: "audit_user1", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, result, 1)
+	assert.Equal(t, model.AuditOperationLogout, result[0].Operation)
+
+	result, _, err = storage.FetchRecords(model.AuditFilter{UserID: "audit_user1", Skip: 1})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, model.AuditOperationLoginWithPassword, result[0].Operation)
+
+	result, total, err = storage.FetchRecords(model.AuditFilter{
+		Operation: model.AuditOperationLoginWithPassword,
+		From:      start.Add(time.Minute),
+		To:        start.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, result, 1)
+	assert.Equal(t, "audit_user2", result[0].UserID)
+}
@@ -1,10 +1,13 @@
 package boltdb
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
 	"sync"
 	"time"
 
+	"github.com/madappgang/identifo/v2/model"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -70,3 +73,26 @@ func CloseDB(db *bolt.DB) error {
 		return ErrorClosingNonExistentDatabase
 	}
 }
+
+// exportBucket writes all the items of the bucket as JSON array.
+// The items are read in a single transaction, so the export is a consistent snapshot of the bucket.
+func exportBucket[T any](db *bolt.DB, bucket string, w io.Writer) error {
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return model.NewJSONArrayWriter(w).Close()
+		}
+
+		aw := model.NewJSONArrayWriter(w)
+		if err := b.ForEach(func(k, v []byte) error {
+			var item T
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			return aw.Write(item)
+		}); err != nil {
+			return err
+		}
+		return aw.Close()
+	})
+}
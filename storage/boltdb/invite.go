@@ -2,7 +2,9 @@ package boltdb
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"time"
 
@@ -230,6 +232,50 @@ func (is *InviteStorage) ArchiveByID(id string) error {
 	})
 }
 
+// ImportJSON imports invites from JSON.
+func (is *InviteStorage) ImportJSON(data []byte, clearOldData bool) error {
+	invites := []model.Invite{}
+	if err := json.Unmarshal(data, &invites); err != nil {
+		return err
+	}
+
+	return is.db.Update(func(tx *bolt.Tx) error {
+		if clearOldData {
+			if err := tx.DeleteBucket([]byte(InviteBucket)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+				return err
+			}
+		}
+		ib, err := tx.CreateBucketIfNotExists([]byte(InviteBucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+
+		for _, invite := range invites {
+			if len(invite.ID) == 0 {
+				invite.ID = xid.New().String()
+			}
+			data, err := json.Marshal(invite)
+			if err != nil {
+				return err
+			}
+			if err := ib.Put([]byte(invite.ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExportJSON exports all invites to JSON.
+func (is *InviteStorage) ExportJSON(w io.Writer) error {
+	return exportBucket[model.Invite](is.db, InviteBucket, w)
+}
+
+// ExportsSnapshot reports that the export is read in a single transaction.
+func (is *InviteStorage) ExportsSnapshot() bool {
+	return true
+}
+
 // Close closes underlying database.
 func (is *InviteStorage) Close() {
 	if err := CloseDB(is.db); err != nil {
@@ -0,0 +1,124 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	// LoginAttemptBucket is a name for bucket with failed login attempts.
+	LoginAttemptBucket = "LoginAttempts"
+)
+
+// LoginAttemptStorage is a BoltDB failed login attempts storage.
+// Expired counters are dropped when they are accessed.
+type LoginAttemptStorage struct {
+	logger *slog.Logger
+	db     *bolt.DB
+}
+
+// NewLoginAttemptStorage creates a BoltDB failed login attempts storage.
+func NewLoginAttemptStorage(
+	logger *slog.Logger,
+	settings model.BoltDBDatabaseSettings,
+) (model.LoginAttemptStorage, error) {
+	if len(settings.Path) == 0 {
+		return nil, ErrorEmptyDatabasePath
+	}
+
+	// init database
+	db, err := InitDB(settings.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := &LoginAttemptStorage{
+		logger: logger,
+		db:     db,
+	}
+	// Ensure that we have needed bucket in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(LoginAttemptBucket)); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+// LoginAttempts returns failed login attempts for the key.
+func (ls *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	var attempts model.LoginAttempts
+
+	err := ls.db.View(func(tx *bolt.Tx) error {
+		var err error
+		attempts, err = ls.get(tx.Bucket([]byte(LoginAttemptBucket)), key, time.Now())
+		return err
+	})
+	return attempts, err
+}
+
+// AddFailedAttempt increments failed login attempts for the key.
+func (ls *LoginAttemptStorage) AddFailedAttempt(key string, expiresIn time.Duration) (model.LoginAttempts, error) {
+	var attempts model.LoginAttempts
+
+	err := ls.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(LoginAttemptBucket))
+
+		now := time.Now()
+		var err error
+		attempts, err = ls.get(b, key, now)
+		if err != nil {
+			return err
+		}
+		attempts.Failures++
+		attempts.LastFailedAt = now
+		attempts.ExpiresAt = now.Add(expiresIn)
+
+		data, err := json.Marshal(attempts)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+	return attempts, err
+}
+
+// ResetLoginAttempts deletes failed login attempts for the key.
+func (ls *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	return ls.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(LoginAttemptBucket)).Delete([]byte(key))
+	})
+}
+
+func (ls *LoginAttemptStorage) get(b *bolt.Bucket, key string, now time.Time) (model.LoginAttempts, error) {
+	attempts := model.LoginAttempts{Key: key}
+
+	data := b.Get([]byte(key))
+	if data == nil {
+		return attempts, nil
+	}
+	if err := json.Unmarshal(data, &attempts); err != nil {
+		return attempts, err
+	}
+	if !attempts.ExpiresAt.After(now) {
+		return model.LoginAttempts{Key: key}, nil
+	}
+	return attempts, nil
+}
+
+// Close closes underlying database.
+func (ls *LoginAttemptStorage) Close() {
+	if err := CloseDB(ls.db); err != nil {
+		ls.logger.Error("Error closing login attempt storage", logging.FieldError, err)
+	}
+}
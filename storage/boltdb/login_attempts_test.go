@@ -0,0 +1,49 @@
+package boltdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltDBLoginAttempts(t *testing.T) {
+	sts := model.BoltDBDatabaseSettings{
+		Path: dbpath,
+	}
+	storage, err := boltdb.NewLoginAttemptStorage(logging.DefaultLogger, sts)
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	attempts, err := storage.LoginAttempts("account:user1")
+	require.NoError(t, err)
+	assert.Zero(t, attempts.Failures)
+
+	for i := 1; i <= 3; i++ {
+		attempts, err = storage.AddFailedAttempt("account:user1", time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, i, attempts.Failures)
+	}
+
+	attempts, err = storage.LoginAttempts("account:user1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts.Failures)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), attempts.ExpiresAt, time.Minute)
+
+	require.NoError(t, storage.ResetLoginAttempts("account:user1"))
+	attempts, err = storage.LoginAttempts("account:user1")
+	require.NoError(t, err)
+	assert.Zero(t, attempts.Failures)
+
+	// expired failures are started from scratch
+	_, err = storage.AddFailedAttempt("ip:127.0.0.1", -time.Second)
+	require.NoError(t, err)
+	attempts, err = storage.AddFailedAttempt("ip:127.0.0.1", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -230,3 +231,12 @@ func (ms *ManagementKeysStorage) ImportJSON(data []byte, cleanOldData bool) erro
 	}
 	return nil
 }
+
+func (ms *ManagementKeysStorage) ExportJSON(w io.Writer) error {
+	return exportBucket[model.ManagementKey](ms.db, ManagementKeysBucket, w)
+}
+
+// ExportsSnapshot reports that the export is read in a single transaction.
+func (ms *ManagementKeysStorage) ExportsSnapshot() bool {
+	return true
+}
@@ -1,8 +1,10 @@
 package boltdb
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/madappgang/identifo/v2/logging"
 	"github.com/madappgang/identifo/v2/model"
@@ -12,9 +14,13 @@ import (
 const (
 	// BlacklistedTokenBucket is a name for bucket with tokens blacklist.
 	BlacklistedTokenBucket = "BlacklistedTokens"
+
+	// blacklistSweepInterval is how often the expired tokens are removed from the blacklist.
+	blacklistSweepInterval = time.Hour
 )
 
 // NewTokenBlacklist creates a token blacklist in BoltDB.
+// The expired tokens are removed right away and then periodically, until the blacklist is closed.
 func NewTokenBlacklist(
 	logger *slog.Logger,
 	settings model.BoltDBDatabaseSettings) (model.TokenBlacklist, error) {
@@ -31,6 +37,8 @@ func NewTokenBlacklist(
 	tb := &TokenBlacklist{
 		logger: logger,
 		db:     db,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
 	}
 	if err := tb.db.Update(func(tx *bolt.Tx) error {
 		if _, err := tx.CreateBucketIfNotExists([]byte(BlacklistedTokenBucket)); err != nil {
@@ -40,6 +48,11 @@ func NewTokenBlacklist(
 	}); err != nil {
 		return nil, err
 	}
+
+	if err := tb.sweep(); err != nil {
+		return nil, err
+	}
+	go tb.sweepPeriodically()
 	return tb, nil
 }
 
@@ -47,24 +60,61 @@ func NewTokenBlacklist(
 type TokenBlacklist struct {
 	logger *slog.Logger
 	db     *bolt.DB
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// blacklistedToken is the value stored by the token ID.
+type blacklistedToken struct {
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+func (t blacklistedToken) expiresAt() time.Time {
+	if t.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(t.ExpiresAt, 0)
+}
+
+func newBlacklistedToken(expiresAt time.Time) blacklistedToken {
+	if expiresAt.IsZero() {
+		return blacklistedToken{}
+	}
+	return blacklistedToken{ExpiresAt: expiresAt.Unix()}
 }
 
 // Add adds token in the blacklist.
-func (tb *TokenBlacklist) Add(token string) error {
+func (tb *TokenBlacklist) Add(id string, expiresAt time.Time) error {
+	if model.IsBlacklistEntryExpired(expiresAt) {
+		return nil
+	}
+
+	data, err := json.Marshal(newBlacklistedToken(expiresAt))
+	if err != nil {
+		return err
+	}
 	return tb.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(BlacklistedTokenBucket))
-		// We use token as key and value.
-		return b.Put([]byte(token), []byte(token))
+		return b.Put([]byte(id), data)
 	})
 }
 
 // IsBlacklisted returns true if the token is blacklisted.
-func (tb *TokenBlacklist) IsBlacklisted(token string) bool {
+func (tb *TokenBlacklist) IsBlacklisted(id string) bool {
 	var res bool
 	if err := tb.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(BlacklistedTokenBucket))
-		// We use token as key and value.
-		res = b.Get([]byte(token)) != nil
+		data := b.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+
+		var t blacklistedToken
+		if err := json.Unmarshal(data, &t); err != nil {
+			return err
+		}
+		// the token could expire before the next sweep.
+		res = !model.IsBlacklistEntryExpired(t.expiresAt())
 		return nil
 	}); err != nil {
 		return false
@@ -72,8 +122,80 @@ func (tb *TokenBlacklist) IsBlacklisted(token string) bool {
 	return res
 }
 
-// Close closes underlying database.
+// sweep removes the expired tokens from the blacklist.
+// The entries of the older versions, keyed by the whole token, are replaced by the token ID.
+func (tb *TokenBlacklist) sweep() error {
+	return tb.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BlacklistedTokenBucket))
+
+		legacy := map[string]blacklistedToken{}
+		var expired [][]byte
+		if err := b.ForEach(func(k, v []byte) error {
+			if model.IsLegacyBlacklistEntry(string(k)) {
+				id, exp := model.BlacklistEntry(string(k))
+				legacy[id] = newBlacklistedToken(exp)
+				expired = append(expired, append([]byte{}, k...))
+				return nil
+			}
+
+			var t blacklistedToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			if model.IsBlacklistEntryExpired(t.expiresAt()) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// the bucket could not be changed while iterating.
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		for id, t := range legacy {
+			if model.IsBlacklistEntryExpired(t.expiresAt()) {
+				continue
+			}
+			data, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (tb *TokenBlacklist) sweepPeriodically() {
+	defer close(tb.done)
+
+	ticker := time.NewTicker(blacklistSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tb.stop:
+			return
+		case <-ticker.C:
+			if err := tb.sweep(); err != nil {
+				tb.logger.Error("Error removing expired tokens from blacklist", logging.FieldError, err)
+			}
+		}
+	}
+}
+
+// Close stops the sweeper and closes underlying database.
 func (tb *TokenBlacklist) Close() {
+	close(tb.stop)
+	<-tb.done
+
 	if err := CloseDB(tb.db); err != nil {
 		tb.logger.Error("error closing token blacklist storage", logging.FieldError, err)
 	}
@@ -0,0 +1,81 @@
+package boltdb_test
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func blacklistedTokens(t *testing.T) int {
+	t.Helper()
+	db, err := boltdb.InitDB(dbpath)
+	require.NoError(t, err)
+	defer boltdb.CloseDB(db)
+
+	var n int
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket([]byte(boltdb.BlacklistedTokenBucket)).Stats().KeyN
+		return nil
+	}))
+	return n
+}
+
+func TestBoltDBTokenBlacklist(t *testing.T) {
+	sts := model.BoltDBDatabaseSettings{
+		Path: dbpath,
+	}
+
+	// the entries of the older versions are keyed by the whole token.
+	legacy := func(jti string, exp time.Time) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, model.Claims{
+			StandardClaims: jwt.StandardClaims{Id: jti, ExpiresAt: exp.Unix()},
+		}).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		return token
+	}
+	active, expired := legacy("active", time.Now().Add(time.Hour)), legacy("expired", time.Now().Add(-time.Hour))
+
+	db, err := boltdb.InitDB(dbpath)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(boltdb.BlacklistedTokenBucket))
+		if err != nil {
+			return err
+		}
+		for _, token := range []string{active, expired} {
+			if err := b.Put([]byte(token), []byte(token)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, boltdb.CloseDB(db))
+
+	blacklist, err := boltdb.NewTokenBlacklist(logging.DefaultLogger, sts)
+	require.NoError(t, err)
+	defer blacklist.Close()
+
+	// the legacy entries are converted on start, the expired one is removed.
+	assert.True(t, blacklist.IsBlacklisted("active"))
+	assert.False(t, blacklist.IsBlacklisted("expired"))
+	assert.Equal(t, 1, blacklistedTokens(t))
+
+	require.NoError(t, blacklist.Add("jti", time.Now().Add(time.Hour)))
+	assert.True(t, blacklist.IsBlacklisted("jti"))
+	require.NoError(t, blacklist.Add("soon", time.Now().Add(2*time.Second)))
+	assert.True(t, blacklist.IsBlacklisted("soon"))
+	require.NoError(t, blacklist.Add("expired", time.Now().Add(-time.Minute)))
+	assert.False(t, blacklist.IsBlacklisted("expired"))
+	assert.Equal(t, 3, blacklistedTokens(t))
+
+	// the token which has expired after it has been added is not blacklisted before the sweep.
+	time.Sleep(2100 * time.Millisecond)
+	assert.False(t, blacklist.IsBlacklisted("soon"))
+}
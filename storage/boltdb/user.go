@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -299,7 +301,9 @@ func (us *UserStorage) UserByUsername(username string) (model.User, error) {
 
 // AddNewUser adds new user to the storage.
 func (us *UserStorage) AddNewUser(user model.User, password string) (model.User, error) {
-	user.Pswd = model.PasswordHash(password)
+	if len(password) > 0 {
+		user.Pswd = model.PasswordHash(password)
+	}
 
 	err := us.db.Update(func(tx *bolt.Tx) error {
 		data, err := json.Marshal(user)
@@ -349,16 +353,18 @@ func (us *UserStorage) AddUserWithPassword(user model.User, password, role strin
 	}
 
 	u := model.User{
-		ID:         xid.New().String(),
-		Active:     true,
-		Username:   user.Username,
-		FullName:   user.FullName,
-		Scopes:     user.Scopes,
-		Phone:      user.Phone,
-		Email:      user.Email,
-		AccessRole: role,
-		Anonymous:  isAnonymous,
-		TFAInfo:    user.TFAInfo,
+		ID:            xid.New().String(),
+		Active:        true,
+		Username:      user.Username,
+		FullName:      user.FullName,
+		Scopes:        user.Scopes,
+		Phone:         user.Phone,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		PhoneVerified: user.PhoneVerified,
+		AccessRole:    role,
+		Anonymous:     isAnonymous,
+		TFAInfo:       user.TFAInfo,
 	}
 
 	return us.AddNewUser(u, password)
@@ -383,9 +389,7 @@ func (us *UserStorage) UpdateUser(userID string, user model.User) (model.User, e
 			if user.Pswd == "" {
 				user.Pswd = oldUser.Pswd
 			}
-			if user.TFAInfo.Secret == "" {
-				user.TFAInfo.Secret = oldUser.TFAInfo.Secret
-			}
+			user.TFAInfo = user.TFAInfo.WithSecretsFrom(oldUser.TFAInfo)
 		}
 
 		data, err := json.Marshal(user)
@@ -452,35 +456,35 @@ func (us *UserStorage) ResetUsername(id, username string) error {
 }
 
 // FetchUsers fetches users which name satisfies provided filterString.
-// Supports pagination.
+// Supports pagination, zero limit means no limit.
 func (us *UserStorage) FetchUsers(filterString string, skip, limit int) ([]model.User, int, error) {
 	users := []model.User{}
 	var total int
+	filterString = strings.ToLower(filterString)
 
 	err := us.db.View(func(tx *bolt.Tx) error {
 		ub := tx.Bucket([]byte(UserBucket))
 
-		if iterErr := ub.ForEach(func(k, u []byte) error {
+		return ub.ForEach(func(k, u []byte) error {
 			user, err := model.UserFromJSON(u)
 			if err != nil {
 				return err
 			}
 
-			if filterString == "" {
-				users = append(users, user)
-			} else if strings.Contains(strings.ToLower(string(user.Email)), strings.ToLower(filterString)) {
-				users = append(users, user)
-			} else if strings.Contains(strings.ToLower(string(user.Phone)), strings.ToLower(filterString)) {
-				users = append(users, user)
-			} else if strings.Contains(strings.ToLower(string(user.Username)), strings.ToLower(filterString)) {
-				users = append(users, user)
+			if filterString != "" &&
+				!strings.Contains(strings.ToLower(user.Email), filterString) &&
+				!strings.Contains(strings.ToLower(user.Phone), filterString) &&
+				!strings.Contains(strings.ToLower(user.Username), filterString) {
+				return nil
 			}
-			return nil
-		}); iterErr != nil {
-			return iterErr
-		}
 
-		return nil
+			total++
+			if total <= skip || (limit > 0 && len(users) == limit) {
+				return nil
+			}
+			users = append(users, user)
+			return nil
+		})
 	})
 	if err != nil {
 		return []model.User{}, 0, err
@@ -511,6 +515,10 @@ func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
 	for _, u := range ud {
 		pswd := u.Pswd
 		u.Pswd = ""
+		// the exported users have the password hash, which is kept as is.
+		if model.IsPasswordHash(pswd) {
+			u.Pswd, pswd = pswd, ""
+		}
 		if _, err := us.AddNewUser(u, pswd); err != nil {
 			return err
 		}
@@ -518,6 +526,16 @@ func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports all users to JSON.
+func (us *UserStorage) ExportJSON(w io.Writer) error {
+	return exportBucket[model.User](us.db, UserBucket, w)
+}
+
+// ExportsSnapshot reports that the export is read in a single transaction.
+func (us *UserStorage) ExportsSnapshot() bool {
+	return true
+}
+
 // UpdateLoginMetadata updates user's login metadata.
 func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes []string, payload map[string]any) {
 	user, err := us.UserByID(userID)
@@ -536,6 +554,34 @@ func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes
 	}
 }
 
+// ReplaceRecoveryCodes replaces the recovery codes of the user if they are still the old ones,
+// the codes are compared and replaced in the same transaction.
+func (us *UserStorage) ReplaceRecoveryCodes(userID string, old, codes []string) (bool, error) {
+	replaced := false
+	err := us.db.Update(func(tx *bolt.Tx) error {
+		ub := tx.Bucket([]byte(UserBucket))
+		data := ub.Get([]byte(userID))
+		if len(data) == 0 {
+			return nil
+		}
+		user, err := model.UserFromJSON(data)
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(user.TFAInfo.RecoveryCodes, old) {
+			return nil
+		}
+
+		user.TFAInfo.RecoveryCodes = codes
+		if data, err = json.Marshal(user); err != nil {
+			return err
+		}
+		replaced = true
+		return ub.Put([]byte(userID), data)
+	})
+	return replaced && err == nil, err
+}
+
 // Close closes underlying database.
 func (us *UserStorage) Close() {
 	if err := CloseDB(us.db); err != nil {
@@ -0,0 +1,253 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/rs/xid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	// UserSessionBucket is a name for bucket with user sessions.
+	UserSessionBucket = "UserSessions"
+	// UserSessionTokenBucket is a name for bucket with session IDs by refresh token ID.
+	UserSessionTokenBucket = "UserSessionsByToken"
+)
+
+// UserSessionStorage is a BoltDB user session storage.
+type UserSessionStorage struct {
+	logger *slog.Logger
+	db     *bolt.DB
+}
+
+// NewUserSessionStorage creates a BoltDB user session storage.
+func NewUserSessionStorage(
+	logger *slog.Logger,
+	settings model.BoltDBDatabaseSettings,
+) (model.UserSessionStorage, error) {
+	if len(settings.Path) == 0 {
+		return nil, ErrorEmptyDatabasePath
+	}
+
+	// init database
+	db, err := InitDB(settings.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	us := &UserSessionStorage{
+		logger: logger,
+		db:     db,
+	}
+	// Ensure that we have needed buckets in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(UserSessionBucket)); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(UserSessionTokenBucket)); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return us, nil
+}
+
+// AddSession saves the new session.
+func (us *UserSessionStorage) AddSession(session model.UserSession) (model.UserSession, error) {
+	if len(session.ID) == 0 {
+		session.ID = xid.New().String()
+	}
+
+	err := us.db.Update(func(tx *bolt.Tx) error {
+		return putUserSession(tx, session, "")
+	})
+	return session, err
+}
+
+// UpdateSession replaces the session.
+func (us *UserSessionStorage) UpdateSession(session model.UserSession) error {
+	return us.db.Update(func(tx *bolt.Tx) error {
+		existing, err := getUserSession(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		return putUserSession(tx, session, existing.RefreshTokenID)
+	})
+}
+
+// SessionByID returns the session by its ID.
+func (us *UserSessionStorage) SessionByID(id string) (model.UserSession, error) {
+	var s model.UserSession
+
+	err := us.db.View(func(tx *bolt.Tx) error {
+		var err error
+		s, err = getUserSession(tx, id)
+		return err
+	})
+	if err == nil && !s.ExpiresAt.After(time.Now()) {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	return s, err
+}
+
+// SessionByRefreshTokenID returns the session by the jti of its refresh token.
+func (us *UserSessionStorage) SessionByRefreshTokenID(tokenID string) (model.UserSession, error) {
+	var id string
+
+	err := us.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(UserSessionTokenBucket)).Get([]byte(tokenID))
+		if v == nil {
+			return model.ErrorNotFound
+		}
+		id = string(v)
+		return nil
+	})
+	if err != nil {
+		return model.UserSession{}, err
+	}
+	return us.SessionByID(id)
+}
+
+// UserSessions returns the sessions of the user, the latest refreshed first.
+// The expired sessions of the user are deleted.
+func (us *UserSessionStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	result := []model.UserSession{}
+	now := time.Now()
+
+	err := us.db.Update(func(tx *bolt.Tx) error {
+		expired := []model.UserSession{}
+		err := tx.Bucket([]byte(UserSessionBucket)).ForEach(func(k, v []byte) error {
+			var s model.UserSession
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			if s.UserID != userID {
+				return nil
+			}
+			if s.ExpiresAt.After(now) {
+				result = append(result, s)
+			} else {
+				expired = append(expired, s)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, s := range expired {
+			if err := deleteUserSession(tx, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []model.UserSession{}, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastRefreshAt.After(result[j].LastRefreshAt)
+	})
+	return result, nil
+}
+
+// DeleteSession deletes the session.
+func (us *UserSessionStorage) DeleteSession(id string) error {
+	return us.db.Update(func(tx *bolt.Tx) error {
+		s, err := getUserSession(tx, id)
+		if err != nil {
+			return err
+		}
+		return deleteUserSession(tx, s)
+	})
+}
+
+// DeleteUserSessions deletes all sessions of the user.
+func (us *UserSessionStorage) DeleteUserSessions(userID string) (int, error) {
+	deleted := 0
+
+	err := us.db.Update(func(tx *bolt.Tx) error {
+		sessions := []model.UserSession{}
+		err := tx.Bucket([]byte(UserSessionBucket)).ForEach(func(k, v []byte) error {
+			var s model.UserSession
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			if s.UserID == userID {
+				sessions = append(sessions, s)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, s := range sessions {
+			if err := deleteUserSession(tx, s); err != nil {
+				return err
+			}
+		}
+		deleted = len(sessions)
+		return nil
+	})
+	return deleted, err
+}
+
+// Close closes underlying database.
+func (us *UserSessionStorage) Close() {
+	if err := CloseDB(us.db); err != nil {
+		us.logger.Error("Error closing user session storage", logging.FieldError, err)
+	}
+}
+
+func getUserSession(tx *bolt.Tx, id string) (model.UserSession, error) {
+	var s model.UserSession
+
+	v := tx.Bucket([]byte(UserSessionBucket)).Get([]byte(id))
+	if v == nil {
+		return s, model.ErrorNotFound
+	}
+	err := json.Unmarshal(v, &s)
+	return s, err
+}
+
+// putUserSession saves the session and moves the refresh token index from the previous token.
+func putUserSession(tx *bolt.Tx, s model.UserSession, prevTokenID string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket([]byte(UserSessionBucket)).Put([]byte(s.ID), data); err != nil {
+		return err
+	}
+
+	tokens := tx.Bucket([]byte(UserSessionTokenBucket))
+	if len(prevTokenID) > 0 && prevTokenID != s.RefreshTokenID {
+		if err := tokens.Delete([]byte(prevTokenID)); err != nil {
+			return err
+		}
+	}
+	if len(s.RefreshTokenID) == 0 {
+		return nil
+	}
+	return tokens.Put([]byte(s.RefreshTokenID), []byte(s.ID))
+}
+
+func deleteUserSession(tx *bolt.Tx, s model.UserSession) error {
+	if len(s.RefreshTokenID) > 0 {
+		if err := tx.Bucket([]byte(UserSessionTokenBucket)).Delete([]byte(s.RefreshTokenID)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket([]byte(UserSessionBucket)).Delete([]byte(s.ID))
+}
@@ -0,0 +1,74 @@
+package boltdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltDBUserSessions(t *testing.T) {
+	sts := model.BoltDBDatabaseSettings{
+		Path: dbpath,
+	}
+	storage, err := boltdb.NewUserSessionStorage(logging.DefaultLogger, sts)
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	now := time.Now()
+	first, err := storage.AddSession(model.UserSession{
+		UserID:         "session_user",
+		AppID:          "app",
+		LastRefreshAt:  now.Add(-time.Minute),
+		RefreshTokenID: "token1",
+		ExpiresAt:      now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.ID)
+
+	second, err := storage.AddSession(model.UserSession{
+		UserID:         "session_user",
+		AppID:          "app",
+		LastRefreshAt:  now,
+		RefreshTokenID: "token2",
+		ExpiresAt:      now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	_, err = storage.AddSession(model.UserSession{
+		UserID:         "session_user",
+		RefreshTokenID: "expired",
+		ExpiresAt:      now.Add(-time.Second),
+	})
+	require.NoError(t, err)
+
+	sessions, err := storage.UserSessions("session_user")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, second.ID, sessions[0].ID)
+
+	// rotation moves the refresh token index
+	first.RefreshTokenID = "token3"
+	require.NoError(t, storage.UpdateSession(first))
+
+	_, err = storage.SessionByRefreshTokenID("token1")
+	assert.ErrorIs(t, err, model.ErrorNotFound)
+	s, err := storage.SessionByRefreshTokenID("token3")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, s.ID)
+
+	require.NoError(t, storage.DeleteSession(second.ID))
+	assert.ErrorIs(t, storage.DeleteSession(second.ID), model.ErrorNotFound)
+
+	deleted, err := storage.DeleteUserSessions("session_user")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = storage.SessionByRefreshTokenID("token3")
+	assert.ErrorIs(t, err, model.ErrorNotFound)
+}
@@ -1,8 +1,10 @@
 package boltdb
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/madappgang/identifo/v2/logging"
 	"github.com/madappgang/identifo/v2/model"
@@ -47,41 +49,76 @@ func NewVerificationCodeStorage(
 }
 
 // VerificationCodeStorage implements verification code storage interface.
+// The code is stored by the phone, the codes saved before the attempts were limited are treated as missing.
 type VerificationCodeStorage struct {
 	logger *slog.Logger
 	db     *bolt.DB
 }
 
-// IsVerificationCodeFound checks whether verification code can be found.
-func (vcs *VerificationCodeStorage) IsVerificationCodeFound(phone, code string) (bool, error) {
-	err := vcs.db.View(func(tx *bolt.Tx) error {
+// CreateVerificationCode saves the new code of the phone, replacing the previous one.
+func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string, policy model.VerificationCodePolicy) (model.VerificationCodeStatus, error) {
+	var status model.VerificationCodeStatus
+	err := vcs.db.Update(func(tx *bolt.Tx) error {
 		vcb := tx.Bucket([]byte(VerificationCodesBucket))
-		foundCode := vcb.Get([]byte(phone))
-		if foundCode == nil {
-			return model.ErrorNotFound
-		}
-		if string(foundCode) != code {
-			return model.ErrorNotFound
-		}
-		if err := vcb.Delete([]byte(phone)); err != nil {
-			return err
+
+		now := time.Now()
+		if vc, ok := getVerificationCode(vcb, phone); ok && now.Before(vc.ResendAt) {
+			status = vc.Status()
+			return model.ErrorVerificationCodeCooldown
 		}
-		return nil
+
+		vc := model.NewVerificationCode(phone, code, policy, now)
+		status = vc.Status()
+		return putVerificationCode(vcb, vc)
 	})
-	return err == nil, nil
+	return status, err
 }
 
-// CreateVerificationCode inserts new verification code to the database.
-func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string) error {
+// VerifyCode checks the code of the phone, the matching code is deleted.
+func (vcs *VerificationCodeStorage) VerifyCode(phone, code string) (model.VerificationCodeStatus, error) {
+	var status model.VerificationCodeStatus
+	var result error
 	err := vcs.db.Update(func(tx *bolt.Tx) error {
 		vcb := tx.Bucket([]byte(VerificationCodesBucket))
-		if err := vcb.Delete([]byte(phone)); err != nil {
-			return err
+
+		now := time.Now()
+		vc, ok := getVerificationCode(vcb, phone)
+		if !ok || !now.Before(vc.DeleteAt()) {
+			result = model.ErrorVerificationCodeExpired
+			return vcb.Delete([]byte(phone))
 		}
 
-		return vcb.Put([]byte(phone), []byte(code))
+		vc, result = vc.Check(code, now)
+		status = vc.Status()
+		switch result {
+		case nil:
+			return vcb.Delete([]byte(phone))
+		case model.ErrorVerificationCodeInvalid:
+			return putVerificationCode(vcb, vc)
+		}
+		return nil
 	})
-	return err
+	if err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+	return status, result
+}
+
+func getVerificationCode(vcb *bolt.Bucket, phone string) (model.VerificationCode, bool) {
+	var vc model.VerificationCode
+	data := vcb.Get([]byte(phone))
+	if data == nil || json.Unmarshal(data, &vc) != nil {
+		return vc, false
+	}
+	return vc, true
+}
+
+func putVerificationCode(vcb *bolt.Bucket, vc model.VerificationCode) error {
+	data, err := json.Marshal(vc)
+	if err != nil {
+		return err
+	}
+	return vcb.Put([]byte(vc.Phone), data)
 }
 
 // Close closes underlying database.
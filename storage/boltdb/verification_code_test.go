@@ -0,0 +1,66 @@
+package boltdb_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltDBVerificationCodes(t *testing.T) {
+	sts := model.BoltDBDatabaseSettings{
+		Path: dbpath,
+	}
+	storage, err := boltdb.NewVerificationCodeStorage(logging.DefaultLogger, sts)
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	// the database is kept between the runs, so the phones are unique.
+	phone := fmt.Sprintf("+1555%07d", time.Now().UnixNano()%10000000)
+	policy := model.VerificationCodePolicy{TTL: time.Minute, MaxAttempts: 2}
+
+	status, err := storage.CreateVerificationCode(phone, "111111", policy)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.AttemptsLeft)
+
+	// the new code replaces the previous one.
+	_, err = storage.CreateVerificationCode(phone, "222222", policy)
+	require.NoError(t, err)
+
+	status, err = storage.VerifyCode(phone, "111111")
+	assert.Equal(t, model.ErrorVerificationCodeInvalid, err)
+	assert.Equal(t, 1, status.AttemptsLeft)
+
+	_, err = storage.VerifyCode(phone, "222222")
+	require.NoError(t, err)
+
+	// the code could be used only once.
+	_, err = storage.VerifyCode(phone, "222222")
+	assert.Equal(t, model.ErrorVerificationCodeExpired, err)
+
+	// the code is not accepted after all the attempts are used.
+	policy.ResendCooldown = time.Minute
+	_, err = storage.CreateVerificationCode(phone, "333333", policy)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = storage.VerifyCode(phone, "000000")
+		assert.Equal(t, model.ErrorVerificationCodeInvalid, err)
+	}
+	status, err = storage.VerifyCode(phone, "333333")
+	assert.Equal(t, model.ErrorVerificationCodeAttemptsExceeded, err)
+	assert.Equal(t, 0, status.AttemptsLeft)
+
+	// the new code could not be sent until the cooldown passes.
+	status, err = storage.CreateVerificationCode(phone, "444444", policy)
+	assert.Equal(t, model.ErrorVerificationCodeCooldown, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), status.ResendAt, 5*time.Second)
+
+	_, err = storage.VerifyCode("+15559999999", "333333")
+	assert.Equal(t, model.ErrorVerificationCodeExpired, err)
+}
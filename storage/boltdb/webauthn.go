@@ -0,0 +1,201 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	// WebAuthnCredentialBucket is a name for bucket with WebAuthn credentials.
+	WebAuthnCredentialBucket = "WebAuthnCredentials"
+	// WebAuthnSessionBucket is a name for bucket with WebAuthn ceremony sessions.
+	WebAuthnSessionBucket = "WebAuthnSessions"
+)
+
+// WebAuthnStorage is a BoltDB WebAuthn credentials storage.
+type WebAuthnStorage struct {
+	logger *slog.Logger
+	db     *bolt.DB
+}
+
+// NewWebAuthnStorage creates a BoltDB WebAuthn credentials storage.
+func NewWebAuthnStorage(
+	logger *slog.Logger,
+	settings model.BoltDBDatabaseSettings,
+) (model.WebAuthnStorage, error) {
+	if len(settings.Path) == 0 {
+		return nil, ErrorEmptyDatabasePath
+	}
+
+	// init database
+	db, err := InitDB(settings.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &WebAuthnStorage{
+		logger: logger,
+		db:     db,
+	}
+	// Ensure that we have needed buckets in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(WebAuthnCredentialBucket)); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(WebAuthnSessionBucket)); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// AddCredential adds new credential.
+func (ws *WebAuthnStorage) AddCredential(credential model.WebAuthnCredential) error {
+	return ws.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebAuthnCredentialBucket))
+		if b.Get([]byte(credential.ID)) != nil {
+			return model.ErrorWrongDataFormat
+		}
+		return ws.put(b, credential)
+	})
+}
+
+// CredentialByID returns credential by its id.
+func (ws *WebAuthnStorage) CredentialByID(id string) (model.WebAuthnCredential, error) {
+	var credential model.WebAuthnCredential
+
+	err := ws.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(WebAuthnCredentialBucket)).Get([]byte(id))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+		return json.Unmarshal(data, &credential)
+	})
+	return credential, err
+}
+
+// CredentialsByUserID returns all credentials of the user, ordered by creation time.
+func (ws *WebAuthnStorage) CredentialsByUserID(userID string) ([]model.WebAuthnCredential, error) {
+	credentials := []model.WebAuthnCredential{}
+
+	err := ws.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(WebAuthnCredentialBucket)).ForEach(func(k, v []byte) error {
+			var credential model.WebAuthnCredential
+			if err := json.Unmarshal(v, &credential); err != nil {
+				return err
+			}
+			if credential.UserID == userID {
+				credentials = append(credentials, credential)
+			}
+			return nil
+		})
+	})
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, err
+}
+
+// UpdateCredential updates existing credential.
+func (ws *WebAuthnStorage) UpdateCredential(credential model.WebAuthnCredential) error {
+	return ws.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebAuthnCredentialBucket))
+		if b.Get([]byte(credential.ID)) == nil {
+			return model.ErrorNotFound
+		}
+		return ws.put(b, credential)
+	})
+}
+
+// DeleteCredential deletes credential by its id.
+func (ws *WebAuthnStorage) DeleteCredential(id string) error {
+	return ws.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebAuthnCredentialBucket))
+		if b.Get([]byte(id)) == nil {
+			return model.ErrorNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// SaveSession saves the session of the ceremony, the expired sessions are deleted with it.
+func (ws *WebAuthnStorage) SaveSession(session model.WebAuthnSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return ws.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebAuthnSessionBucket))
+
+		now := time.Now()
+		expired := [][]byte{}
+		if err := b.ForEach(func(k, v []byte) error {
+			var s model.WebAuthnSession
+			if err := json.Unmarshal(v, &s); err != nil || !s.ExpiresAt.After(now) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return b.Put([]byte(session.ID), data)
+	})
+}
+
+// TakeSession returns and deletes the session.
+func (ws *WebAuthnStorage) TakeSession(id string) (model.WebAuthnSession, error) {
+	var session model.WebAuthnSession
+
+	err := ws.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebAuthnSessionBucket))
+		data := b.Get([]byte(id))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+		if err := json.Unmarshal(data, &session); err != nil {
+			return err
+		}
+		if !session.ExpiresAt.After(time.Now()) {
+			// the expired session is deleted by the next save
+			return model.ErrorNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+	if err != nil {
+		return model.WebAuthnSession{}, err
+	}
+	return session, nil
+}
+
+func (ws *WebAuthnStorage) put(b *bolt.Bucket, credential model.WebAuthnCredential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(credential.ID), data)
+}
+
+// Close closes underlying database.
+func (ws *WebAuthnStorage) Close() {
+	if err := CloseDB(ws.db); err != nil {
+		ws.logger.Error("Error closing WebAuthn storage", logging.FieldError, err)
+	}
+}
@@ -0,0 +1,37 @@
+package boltdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltDBWebAuthnSessions(t *testing.T) {
+	sts := model.BoltDBDatabaseSettings{
+		Path: dbpath,
+	}
+	storage, err := boltdb.NewWebAuthnStorage(logging.DefaultLogger, sts)
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	now := time.Now()
+	require.NoError(t, storage.SaveSession(model.WebAuthnSession{ID: "expired", Data: "{}", ExpiresAt: now.Add(-time.Second)}))
+	require.NoError(t, storage.SaveSession(model.WebAuthnSession{ID: "session", Data: "{}", ExpiresAt: now.Add(time.Minute)}))
+
+	session, err := storage.TakeSession("session")
+	require.NoError(t, err)
+	assert.Equal(t, "{}", session.Data)
+
+	// the session is taken once
+	_, err = storage.TakeSession("session")
+	assert.ErrorIs(t, err, model.ErrorNotFound)
+
+	_, err = storage.TakeSession("expired")
+	assert.ErrorIs(t, err, model.ErrorNotFound)
+}
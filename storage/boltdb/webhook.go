@@ -0,0 +1,209 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/rs/xid"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	// WebhookDeliveryBucket is a name for bucket with webhook deliveries.
+	WebhookDeliveryBucket = "WebhookDeliveries"
+)
+
+// WebhookDeliveryStorage is a BoltDB webhook delivery storage.
+// Deliveries are keyed by xid, which is sorted by creation time.
+type WebhookDeliveryStorage struct {
+	logger *slog.Logger
+	db     *bolt.DB
+}
+
+// NewWebhookDeliveryStorage creates a BoltDB webhook delivery storage.
+func NewWebhookDeliveryStorage(
+	logger *slog.Logger,
+	settings model.BoltDBDatabaseSettings,
+) (model.WebhookDeliveryStorage, error) {
+	if len(settings.Path) == 0 {
+		return nil, ErrorEmptyDatabasePath
+	}
+
+	// init database
+	db, err := InitDB(settings.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &WebhookDeliveryStorage{
+		logger: logger,
+		db:     db,
+	}
+	// Ensure that we have needed bucket in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(WebhookDeliveryBucket)); err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// AddDelivery queues the delivery.
+func (ws *WebhookDeliveryStorage) AddDelivery(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	delivery.ID = xid.New().String()
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+
+	err := ws.db.Update(func(tx *bolt.Tx) error {
+		return putWebhookDelivery(tx, delivery)
+	})
+	return delivery, err
+}
+
+// ClaimDueDeliveries returns pending deliveries due at now and postpones them for the lease duration.
+// The bucket is scanned in the single update transaction, so the claim is atomic.
+func (ws *WebhookDeliveryStorage) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	due := []model.WebhookDelivery{}
+
+	err := ws.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket([]byte(WebhookDeliveryBucket)).ForEach(func(k, v []byte) error {
+			var d model.WebhookDelivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			if d.Status == model.WebhookDeliveryPending && !d.NextAttemptAt.After(now) {
+				due = append(due, d)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(due, func(i, j int) bool {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		})
+		if limit > 0 && len(due) > limit {
+			due = due[:limit]
+		}
+
+		for _, d := range due {
+			d.NextAttemptAt = now.Add(lease)
+			if err := putWebhookDelivery(tx, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []model.WebhookDelivery{}, err
+	}
+	return due, nil
+}
+
+// UpdateDelivery replaces the delivery.
+func (ws *WebhookDeliveryStorage) UpdateDelivery(delivery model.WebhookDelivery) error {
+	return ws.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(WebhookDeliveryBucket)).Get([]byte(delivery.ID)) == nil {
+			return model.ErrorNotFound
+		}
+		return putWebhookDelivery(tx, delivery)
+	})
+}
+
+// DeliveryByID returns the delivery by its ID.
+func (ws *WebhookDeliveryStorage) DeliveryByID(id string) (model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+
+	err := ws.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(WebhookDeliveryBucket)).Get([]byte(id))
+		if v == nil {
+			return model.ErrorNotFound
+		}
+		return json.Unmarshal(v, &d)
+	})
+	return d, err
+}
+
+// FetchDeliveries returns deliveries with the status, newest first.
+func (ws *WebhookDeliveryStorage) FetchDeliveries(status model.WebhookDeliveryStatus, skip, limit int) ([]model.WebhookDelivery, int, error) {
+	result := []model.WebhookDelivery{}
+	total := 0
+
+	err := ws.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(WebhookDeliveryBucket)).Cursor()
+
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var d model.WebhookDelivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			if len(status) > 0 && d.Status != status {
+				continue
+			}
+			total++
+			if total > skip && (limit <= 0 || len(result) < limit) {
+				result = append(result, d)
+			}
+		}
+		return nil
+	})
+	return result, total, err
+}
+
+// DeleteFinishedDeliveries deletes the delivered and dead deliveries finished before the time.
+func (ws *WebhookDeliveryStorage) DeleteFinishedDeliveries(before time.Time) (int, error) {
+	deleted := 0
+	err := ws.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebhookDeliveryBucket))
+
+		// the keys could not be deleted while the bucket is iterated
+		var finished [][]byte
+		if err := b.ForEach(func(k, v []byte) error {
+			var d model.WebhookDelivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			if d.FinishedBefore(before) {
+				finished = append(finished, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range finished {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		deleted = len(finished)
+		return nil
+	})
+	return deleted, err
+}
+
+// Close closes underlying database.
+func (ws *WebhookDeliveryStorage) Close() {
+	if err := CloseDB(ws.db); err != nil {
+		ws.logger.Error("Error closing webhook delivery storage", logging.FieldError, err)
+	}
+}
+
+func putWebhookDelivery(tx *bolt.Tx, d model.WebhookDelivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(WebhookDeliveryBucket)).Put([]byte(d.ID), data)
+}
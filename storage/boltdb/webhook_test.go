@@ -0,0 +1,81 @@
+package boltdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltDBWebhookDeliveries(t *testing.T) {
+	sts := model.BoltDBDatabaseSettings{
+		Path: dbpath,
+	}
+	storage, err := boltdb.NewWebhookDeliveryStorage(logging.DefaultLogger, sts)
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	now := time.Now()
+	due, err := storage.AddDelivery(model.WebhookDelivery{
+		Event:         model.WebhookEventUserLogin,
+		URL:           "http://localhost/hook",
+		Status:        model.WebhookDeliveryPending,
+		NextAttemptAt: now.Add(-time.Second),
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, due.ID)
+
+	_, err = storage.AddDelivery(model.WebhookDelivery{
+		Event:         model.WebhookEventUserDeleted,
+		URL:           "http://localhost/hook",
+		Status:        model.WebhookDeliveryPending,
+		NextAttemptAt: now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	claimed, err := storage.ClaimDueDeliveries(now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, due.ID, claimed[0].ID)
+
+	// the claimed delivery is leased
+	claimed, err = storage.ClaimDueDeliveries(now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	due.Status = model.WebhookDeliveryDead
+	due.Attempts = 3
+	require.NoError(t, storage.UpdateDelivery(due))
+
+	dead, total, err := storage.FetchDeliveries(model.WebhookDeliveryDead, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 3, dead[0].Attempts)
+
+	all, total, err := storage.FetchDeliveries("", 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, all, 1)
+	// newest first
+	assert.Equal(t, model.WebhookEventUserDeleted, all[0].Event)
+
+	// the dead delivery is deleted after the retention, the pending one is kept
+	deleted, err := storage.DeleteFinishedDeliveries(now.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	deleted, err = storage.DeleteFinishedDeliveries(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	_, total, err = storage.FetchDeliveries("", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	_, err = storage.DeliveryByID("missing")
+	assert.ErrorIs(t, err, model.ErrorNotFound)
+}
@@ -0,0 +1,252 @@
+package dynamodb
+
+import (
+	"log/slog"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+const (
+	adminAccountsTableName     = "AdminAccounts"
+	adminAccountLoginIndexName = "admin-account-login"
+)
+
+// AdminAccountStorage is a DynamoDB admin account storage.
+// The login uniqueness is checked with the index query before the account is added,
+// which is enough for a few accounts added by hand.
+type AdminAccountStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// NewAdminAccountStorage creates new DynamoDB admin account storage.
+func NewAdminAccountStorage(
+	logger *slog.Logger,
+	settings model.DynamoDatabaseSettings,
+) (model.AdminAccountStorage, error) {
+	if len(settings.Endpoint) == 0 || len(settings.Region) == 0 {
+		return nil, ErrorEmptyEndpointRegion
+	}
+
+	// create database
+	db, err := NewDB(settings.Endpoint, settings.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	as := &AdminAccountStorage{
+		logger: logger,
+		db:     db,
+	}
+	err = as.ensureTable()
+	return as, err
+}
+
+// ensureTable ensures that admin accounts table exists in the database.
+func (as *AdminAccountStorage) ensureTable() error {
+	exists, err := as.db.IsTableExists(adminAccountsTableName)
+	if err != nil {
+		as.logger.Error("Error checking admin accounts table existence", logging.FieldError, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("login"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(adminAccountLoginIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{
+						AttributeName: aws.String("login"),
+						KeyType:       aws.String("HASH"),
+					},
+				},
+				Projection: &dynamodb.Projection{
+					ProjectionType: aws.String("ALL"),
+				},
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(adminAccountsTableName),
+	}
+
+	_, err = as.db.C.CreateTable(input)
+	return err
+}
+
+// AdminAccounts returns all accounts ordered by login.
+func (as *AdminAccountStorage) AdminAccounts() ([]model.AdminAccount, error) {
+	accounts := []model.AdminAccount{}
+	var unmarshalErr error
+
+	err := as.db.C.ScanPages(&dynamodb.ScanInput{
+		TableName:      aws.String(adminAccountsTableName),
+		ConsistentRead: aws.Bool(true),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var items []model.AdminAccount
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		accounts = append(accounts, items...)
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		as.logger.Error("Error scanning admin accounts", logging.FieldError, err)
+		return nil, ErrorInternalError
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Login < accounts[j].Login
+	})
+	return accounts, nil
+}
+
+// AdminAccountByID returns the account by its id.
+func (as *AdminAccountStorage) AdminAccountByID(id string) (model.AdminAccount, error) {
+	if len(id) == 0 {
+		return model.AdminAccount{}, model.ErrorNotFound
+	}
+
+	result, err := as.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(adminAccountsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		as.logger.Error("Error getting admin account", logging.FieldError, err)
+		return model.AdminAccount{}, ErrorInternalError
+	}
+	if result.Item == nil {
+		return model.AdminAccount{}, model.ErrorNotFound
+	}
+
+	account := model.AdminAccount{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &account); err != nil {
+		as.logger.Error("Error unmarshalling admin account", logging.FieldError, err)
+		return model.AdminAccount{}, ErrorInternalError
+	}
+	return account, nil
+}
+
+// AdminAccountByLogin returns the account by its login.
+func (as *AdminAccountStorage) AdminAccountByLogin(login string) (model.AdminAccount, error) {
+	result, err := as.db.C.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(adminAccountsTableName),
+		IndexName:              aws.String(adminAccountLoginIndexName),
+		KeyConditionExpression: aws.String("login = :l"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":l": {S: aws.String(login)},
+		},
+	})
+	if err != nil {
+		as.logger.Error("Error querying for admin account by login", logging.FieldError, err)
+		return model.AdminAccount{}, ErrorInternalError
+	}
+	if len(result.Items) == 0 {
+		return model.AdminAccount{}, model.ErrorNotFound
+	}
+
+	account := model.AdminAccount{}
+	if err = dynamodbattribute.UnmarshalMap(result.Items[0], &account); err != nil {
+		as.logger.Error("Error unmarshalling admin account", logging.FieldError, err)
+		return model.AdminAccount{}, ErrorInternalError
+	}
+	return account, nil
+}
+
+// AddAdminAccount adds new account.
+func (as *AdminAccountStorage) AddAdminAccount(account model.AdminAccount) (model.AdminAccount, error) {
+	if _, err := as.AdminAccountByLogin(account.Login); err == nil {
+		return model.AdminAccount{}, model.ErrorAdminAccountExists
+	} else if err != model.ErrorNotFound {
+		return model.AdminAccount{}, err
+	}
+
+	account.ID = xid.New().String()
+	if err := as.put(account, "attribute_not_exists(id)"); err != nil {
+		return model.AdminAccount{}, err
+	}
+	return account, nil
+}
+
+// UpdateAdminAccount updates existing account.
+func (as *AdminAccountStorage) UpdateAdminAccount(account model.AdminAccount) error {
+	old, err := as.AdminAccountByID(account.ID)
+	if err != nil {
+		return err
+	}
+	account.Login = old.Login
+	return as.put(account, "attribute_exists(id)")
+}
+
+func (as *AdminAccountStorage) put(account model.AdminAccount, condition string) error {
+	item, err := dynamodbattribute.MarshalMap(account)
+	if err != nil {
+		as.logger.Error("Error marshalling admin account", logging.FieldError, err)
+		return ErrorInternalError
+	}
+
+	if _, err = as.db.C.PutItem(&dynamodb.PutItemInput{
+		Item:                item,
+		TableName:           aws.String(adminAccountsTableName),
+		ConditionExpression: aws.String(condition),
+	}); err != nil {
+		if isConditionalCheckFailed(err) {
+			return model.ErrorNotFound
+		}
+		as.logger.Error("Error putting admin account to storage", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// DeleteAdminAccount deletes account by its id.
+func (as *AdminAccountStorage) DeleteAdminAccount(id string) error {
+	_, err := as.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(adminAccountsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return model.ErrorNotFound
+		}
+		as.logger.Error("Error deleting admin account", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// Close does nothing here.
+func (as *AdminAccountStorage) Close() {}
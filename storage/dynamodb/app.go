@@ -2,6 +2,7 @@ package dynamodb
 
 import (
 	"encoding/json"
+	"io"
 	"log/slog"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -274,5 +275,10 @@ func (as *AppStorage) ImportJSON(data []byte, cleanOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports all apps to JSON.
+func (as *AppStorage) ExportJSON(w io.Writer) error {
+	return exportTable[model.AppData](as.db, appsTableName, w)
+}
+
 // Close does nothing here.
 func (as *AppStorage) Close() {}
@@ -0,0 +1,190 @@
+package dynamodb
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+const auditTableName = "Audit"
+
+// auditRecord is a DynamoDB item of the audit record.
+// Time is in unix nanoseconds to filter records by time range.
+type auditRecord struct {
+	model.AuditRecord
+	Time int64 `json:"time"`
+}
+
+// AuditStorage is a DynamoDB audit storage.
+// Records are keyed by xid, which is sorted by creation time.
+type AuditStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// NewAuditStorage creates new DynamoDB audit storage.
+func NewAuditStorage(
+	logger *slog.Logger,
+	settings model.DynamoDatabaseSettings,
+) (model.AuditStorage, error) {
+	if len(settings.Endpoint) == 0 || len(settings.Region) == 0 {
+		return nil, ErrorEmptyEndpointRegion
+	}
+
+	// create database
+	db, err := NewDB(settings.Endpoint, settings.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	as := &AuditStorage{
+		logger: logger,
+		db:     db,
+	}
+	err = as.ensureTable()
+	return as, err
+}
+
+// ensureTable ensures that audit table exists in the database.
+func (as *AuditStorage) ensureTable() error {
+	exists, err := as.db.IsTableExists(auditTableName)
+	if err != nil {
+		as.logger.Error("Error checking audit table existence", logging.FieldError, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(auditTableName),
+	}
+
+	_, err = as.db.C.CreateTable(input)
+	return err
+}
+
+// AddRecord appends the record to the log.
+func (as *AuditStorage) AddRecord(record model.AuditRecord) error {
+	record.ID = xid.New().String()
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	item, err := dynamodbattribute.MarshalMap(auditRecord{AuditRecord: record, Time: record.Time.UnixNano()})
+	if err != nil {
+		as.logger.Error("Error marshalling audit record", logging.FieldError, err)
+		return ErrorInternalError
+	}
+
+	if _, err = as.db.C.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(auditTableName),
+		Item:      item,
+	}); err != nil {
+		as.logger.Error("Error putting audit record", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// FetchRecords returns the records matching the filter, newest first.
+// DynamoDB has no ordered scan, so all matching records are read and sorted in memory.
+func (as *AuditStorage) FetchRecords(filter model.AuditFilter) ([]model.AuditRecord, int, error) {
+	conditions := []string{}
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{}
+
+	addCondition := func(name, cond, value string, attr *dynamodb.AttributeValue) {
+		names["#"+name] = aws.String(name)
+		values[value] = attr
+		conditions = append(conditions, "#"+name+" "+cond+" "+value)
+	}
+
+	if len(filter.UserID) > 0 {
+		addCondition("user_id", "=", ":user_id", &dynamodb.AttributeValue{S: aws.String(filter.UserID)})
+	}
+	if len(filter.AppID) > 0 {
+		addCondition("app_id", "=", ":app_id", &dynamodb.AttributeValue{S: aws.String(filter.AppID)})
+	}
+	if len(filter.Operation) > 0 {
+		addCondition("operation", "=", ":operation", &dynamodb.AttributeValue{S: aws.String(string(filter.Operation))})
+	}
+	if !filter.From.IsZero() {
+		addCondition("time", ">=", ":from", &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(filter.From.UnixNano(), 10))})
+	}
+	if !filter.To.IsZero() {
+		addCondition("time", "<", ":to", &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(filter.To.UnixNano(), 10))})
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(auditTableName),
+	}
+	if len(conditions) > 0 {
+		expr := conditions[0]
+		for _, c := range conditions[1:] {
+			expr += " AND " + c
+		}
+		scanInput.FilterExpression = aws.String(expr)
+		scanInput.ExpressionAttributeNames = names
+		scanInput.ExpressionAttributeValues = values
+	}
+
+	records := []model.AuditRecord{}
+	var unmarshalErr error
+	err := as.db.C.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			r := auditRecord{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(item, &r); unmarshalErr != nil {
+				return false
+			}
+			r.AuditRecord.Time = time.Unix(0, r.Time)
+			records = append(records, r.AuditRecord)
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		as.logger.Error("Error querying for audit records", logging.FieldError, err)
+		return []model.AuditRecord{}, 0, ErrorInternalError
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID > records[j].ID
+	})
+
+	total := len(records)
+	if filter.Skip >= total {
+		return []model.AuditRecord{}, total, nil
+	}
+	records = records[filter.Skip:]
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, total, nil
+}
+
+// Close does nothing here.
+func (as *AuditStorage) Close() {}
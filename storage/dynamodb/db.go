@@ -2,12 +2,15 @@ package dynamodb
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/v2/model"
 )
 
 // NewDB creates new database connection.
@@ -77,3 +80,33 @@ func AwsErrorErrorNotFound(err error) bool {
 	}
 	return false
 }
+
+// exportTable writes all the items of the table as JSON array, page by page.
+// The pages are read with the strongly consistent reads, but DynamoDB has no snapshot reads,
+// so the items changed during the export could be exported in either state.
+func exportTable[T any](db *DB, table string, w io.Writer) error {
+	aw := model.NewJSONArrayWriter(w)
+	var itemErr error
+	err := db.C.ScanPages(&dynamodb.ScanInput{
+		TableName:      aws.String(table),
+		ConsistentRead: aws.Bool(true),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, av := range page.Items {
+			var item T
+			if itemErr = dynamodbattribute.UnmarshalMap(av, &item); itemErr != nil {
+				return false
+			}
+			if itemErr = aw.Write(item); itemErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = itemErr
+	}
+	if err != nil {
+		return err
+	}
+	return aw.Close()
+}
@@ -1,6 +1,8 @@
 package dynamodb
 
 import (
+	"encoding/json"
+	"io"
 	"log/slog"
 	"time"
 
@@ -212,6 +214,7 @@ func (is *InviteStorage) GetByID(id string) (model.Invite, error) {
 
 // GetAll returns all active invites by default.
 // To get an archived invites need to set withArchived argument to true.
+// The scan stops after the page, the total counts one invite after the page if there is the next page.
 func (is *InviteStorage) GetAll(withArchived bool, skip, limit int) ([]model.Invite, int, error) {
 	if limit == 0 || limit > maxInvitesLimit {
 		limit = maxInvitesLimit
@@ -219,7 +222,6 @@ func (is *InviteStorage) GetAll(withArchived bool, skip, limit int) ([]model.Inv
 
 	scanInput := &dynamodb.ScanInput{
 		TableName: aws.String(invitesTableName),
-		Limit:     aws.Int64(int64(limit)),
 	}
 
 	if !withArchived {
@@ -232,25 +234,34 @@ func (is *InviteStorage) GetAll(withArchived bool, skip, limit int) ([]model.Inv
 		}
 	}
 
-	result, err := is.db.C.Scan(scanInput)
+	invites := []model.Invite{}
+	total := 0
+	var unmarshalErr error
+	err := is.db.C.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			total++
+			if total <= skip {
+				continue
+			}
+			if len(invites) == limit {
+				return false
+			}
+			invite := model.Invite{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(item, &invite); unmarshalErr != nil {
+				return false
+			}
+			invites = append(invites, invite)
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
 	if err != nil {
 		is.logger.Error("Error querying for invites", logging.FieldError, err)
 		return []model.Invite{}, 0, ErrorInternalError
 	}
-
-	invites := make([]model.Invite, len(result.Items))
-	for i := 0; i < len(result.Items); i++ {
-		if i < skip {
-			continue
-		}
-		invite := model.Invite{}
-		if err = dynamodbattribute.UnmarshalMap(result.Items[i], &invite); err != nil {
-			is.logger.Error("error while unmarshal invite", logging.FieldError, err)
-			return []model.Invite{}, 0, ErrorInternalError
-		}
-		invites[i] = invite
-	}
-	return invites, len(result.Items), nil
+	return invites, total, nil
 }
 
 // ArchiveAllByEmail archived all invites by email.
@@ -317,5 +328,43 @@ func (is *InviteStorage) ArchiveByID(id string) error {
 	return nil
 }
 
+// ImportJSON imports invites from JSON.
+func (is *InviteStorage) ImportJSON(data []byte, clearOldData bool) error {
+	if clearOldData {
+		is.db.DeleteTable(invitesTableName)
+		if err := is.ensureTable(); err != nil {
+			return err
+		}
+	}
+
+	invites := []model.Invite{}
+	if err := json.Unmarshal(data, &invites); err != nil {
+		return err
+	}
+	for _, invite := range invites {
+		if len(invite.ID) == 0 {
+			invite.ID = xid.New().String()
+		}
+		iv, err := dynamodbattribute.MarshalMap(invite)
+		if err != nil {
+			is.logger.Error("Error marshalling invite", logging.FieldError, err)
+			return ErrorInternalError
+		}
+		if _, err := is.db.C.PutItem(&dynamodb.PutItemInput{
+			Item:      iv,
+			TableName: aws.String(invitesTableName),
+		}); err != nil {
+			is.logger.Error("Error putting invite to storage", logging.FieldError, err)
+			return ErrorInternalError
+		}
+	}
+	return nil
+}
+
+// ExportJSON exports all invites to JSON.
+func (is *InviteStorage) ExportJSON(w io.Writer) error {
+	return exportTable[model.Invite](is.db, invitesTableName, w)
+}
+
 // Close does nothing here.
 func (is *InviteStorage) Close() {}
@@ -0,0 +1,201 @@
+package dynamodb
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const loginAttemptsTableName = "LoginAttempts"
+
+// loginAttempts is a DynamoDB item of failed login attempts.
+// Expiration time is in unix seconds, as required by DynamoDB TTL.
+type loginAttempts struct {
+	ID           string `json:"id"`
+	Failures     int    `json:"failures"`
+	LastFailedAt int64  `json:"last_failed_at"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+func (a loginAttempts) model() model.LoginAttempts {
+	return model.LoginAttempts{
+		Key:          a.ID,
+		Failures:     a.Failures,
+		LastFailedAt: time.Unix(0, a.LastFailedAt),
+		ExpiresAt:    time.Unix(a.ExpiresAt, 0),
+	}
+}
+
+// LoginAttemptStorage is a DynamoDB failed login attempts storage.
+// Expired items are deleted by DynamoDB TTL with some delay, so the expiration is checked on every access too.
+type LoginAttemptStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// NewLoginAttemptStorage creates new DynamoDB failed login attempts storage.
+func NewLoginAttemptStorage(
+	logger *slog.Logger,
+	settings model.DynamoDatabaseSettings,
+) (model.LoginAttemptStorage, error) {
+	if len(settings.Endpoint) == 0 || len(settings.Region) == 0 {
+		return nil, ErrorEmptyEndpointRegion
+	}
+
+	// create database
+	db, err := NewDB(settings.Endpoint, settings.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := &LoginAttemptStorage{
+		logger: logger,
+		db:     db,
+	}
+	err = ls.ensureTable()
+	return ls, err
+}
+
+// ensureTable ensures that login attempts table exists in the database and has TTL enabled.
+func (ls *LoginAttemptStorage) ensureTable() error {
+	exists, err := ls.db.IsTableExists(loginAttemptsTableName)
+	if err != nil {
+		ls.logger.Error("Error checking login attempts table existence", logging.FieldError, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(loginAttemptsTableName),
+	}
+
+	if _, err = ls.db.C.CreateTable(input); err != nil {
+		return err
+	}
+
+	// TTL could be enabled for the active table only
+	if err = ls.db.C.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(loginAttemptsTableName),
+	}); err != nil {
+		return err
+	}
+
+	_, err = ls.db.C.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(loginAttemptsTableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String("expires_at"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
+
+// LoginAttempts returns failed login attempts for the key.
+func (ls *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	result, err := ls.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(loginAttemptsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(key)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		ls.logger.Error("Error getting login attempts", logging.FieldError, err)
+		return model.LoginAttempts{Key: key}, ErrorInternalError
+	}
+	if result.Item == nil {
+		return model.LoginAttempts{Key: key}, nil
+	}
+
+	attempts := loginAttempts{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &attempts); err != nil {
+		ls.logger.Error("Error unmarshalling login attempts", logging.FieldError, err)
+		return model.LoginAttempts{Key: key}, ErrorInternalError
+	}
+	if attempts.ExpiresAt <= time.Now().Unix() {
+		return model.LoginAttempts{Key: key}, nil
+	}
+	return attempts.model(), nil
+}
+
+// AddFailedAttempt increments failed login attempts for the key and prolongs their expiration.
+// The expired counter is started from scratch.
+func (ls *LoginAttemptStorage) AddFailedAttempt(key string, expiresIn time.Duration) (model.LoginAttempts, error) {
+	now := time.Now()
+	values := map[string]*dynamodb.AttributeValue{
+		":one":  {N: aws.String("1")},
+		":last": {N: aws.String(strconv.FormatInt(now.UnixNano(), 10))},
+		":exp":  {N: aws.String(strconv.FormatInt(now.Add(expiresIn).Unix(), 10))},
+		":now":  {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(loginAttemptsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(key)},
+		},
+		UpdateExpression:          aws.String("ADD failures :one SET last_failed_at = :last, expires_at = :exp"),
+		ConditionExpression:       aws.String("attribute_not_exists(id) OR expires_at > :now"),
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	}
+
+	result, err := ls.db.C.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		// the counter has expired, but has not been deleted by TTL yet
+		input.UpdateExpression = aws.String("SET failures = :one, last_failed_at = :last, expires_at = :exp")
+		input.ConditionExpression = nil
+		delete(values, ":now")
+		result, err = ls.db.C.UpdateItem(input)
+	}
+	if err != nil {
+		ls.logger.Error("Error updating login attempts", logging.FieldError, err)
+		return model.LoginAttempts{Key: key}, ErrorInternalError
+	}
+
+	attempts := loginAttempts{}
+	if err = dynamodbattribute.UnmarshalMap(result.Attributes, &attempts); err != nil {
+		ls.logger.Error("Error unmarshalling login attempts", logging.FieldError, err)
+		return model.LoginAttempts{Key: key}, ErrorInternalError
+	}
+	return attempts.model(), nil
+}
+
+// ResetLoginAttempts deletes failed login attempts for the key.
+func (ls *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	_, err := ls.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(loginAttemptsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(key)},
+		},
+	})
+	if err != nil {
+		ls.logger.Error("Error deleting login attempts", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// Close does nothing here.
+func (ls *LoginAttemptStorage) Close() {}
@@ -3,6 +3,7 @@ package dynamodb
 import (
 	"context"
 	"errors"
+	"io"
 	"log/slog"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -118,3 +119,7 @@ func (ms *ManagementKeysStorage) ensureTable() error {
 func (ms *ManagementKeysStorage) ImportJSON(data []byte, cleanOldData bool) error {
 	return errors.New("not implemented")
 }
+
+func (ms *ManagementKeysStorage) ExportJSON(w io.Writer) error {
+	return exportTable[model.ManagementKey](ms.db, managementKeyTableName, w)
+}
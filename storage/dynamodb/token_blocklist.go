@@ -3,6 +3,7 @@ package dynamodb
 import (
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -11,7 +12,11 @@ import (
 	"github.com/madappgang/identifo/v2/model"
 )
 
-const blacklistedTokensTableName = "BlacklistedTokens"
+const (
+	blacklistedTokensTableName = "BlacklistedTokens"
+	// blacklistedTokensTTLAttribute is the TTL attribute, the expired tokens are removed by DynamoDB.
+	blacklistedTokensTTLAttribute = "expires_at"
+)
 
 // NewTokenBlacklist creates new DynamoDB token storage.
 func NewTokenBlacklist(
@@ -32,16 +37,31 @@ func NewTokenBlacklist(
 		logger: logger,
 		db:     db,
 	}
-	err = ts.ensureTable()
-	return ts, err
+	if err := ts.ensureTable(); err != nil {
+		return nil, err
+	}
+	if err := ts.ensureTTL(); err != nil {
+		return nil, err
+	}
+	if err := ts.convertLegacyEntries(); err != nil {
+		return nil, fmt.Errorf("error while converting %s: %w", blacklistedTokensTableName, err)
+	}
+	return ts, nil
 }
 
 // TokenBlacklist is a DynamoDB storage for blacklisted tokens.
+// The token ID is stored in the token attribute, which is the hash key of the table.
 type TokenBlacklist struct {
 	logger *slog.Logger
 	db     *DB
 }
 
+// blacklistedToken is the blacklist item, the items without expires_at are never removed.
+type blacklistedToken struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
 // ensureTable ensures that token blacklist exists.
 func (tb *TokenBlacklist) ensureTable() error {
 	exists, err := tb.db.IsTableExists(blacklistedTokensTableName)
@@ -76,16 +96,90 @@ func (tb *TokenBlacklist) ensureTable() error {
 	return nil
 }
 
+// ensureTTL enables TTL of the table, the tables created by the older versions have no TTL.
+func (tb *TokenBlacklist) ensureTTL() error {
+	// TTL could be enabled for the active table only
+	if err := tb.db.C.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(blacklistedTokensTableName),
+	}); err != nil {
+		return err
+	}
+
+	ttl, err := tb.db.C.DescribeTimeToLive(&dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(blacklistedTokensTableName),
+	})
+	if err != nil {
+		return fmt.Errorf("error while describing %s TTL: %w", blacklistedTokensTableName, err)
+	}
+	if d := ttl.TimeToLiveDescription; d != nil && aws.StringValue(d.TimeToLiveStatus) != dynamodb.TimeToLiveStatusDisabled {
+		return nil
+	}
+
+	_, err = tb.db.C.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(blacklistedTokensTableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(blacklistedTokensTTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error while enabling %s TTL: %w", blacklistedTokensTableName, err)
+	}
+	return nil
+}
+
+// convertLegacyEntries replaces the items of the older versions, keyed by the whole token, by the token ID.
+func (tb *TokenBlacklist) convertLegacyEntries() error {
+	var legacy []string
+	err := tb.db.C.ScanPages(&dynamodb.ScanInput{
+		TableName:                aws.String(blacklistedTokensTableName),
+		FilterExpression:         aws.String("contains(#token, :dot)"),
+		ExpressionAttributeNames: map[string]*string{"#token": aws.String("token")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":dot": {S: aws.String(".")},
+		},
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if t := item["token"]; t != nil && model.IsLegacyBlacklistEntry(aws.StringValue(t.S)) {
+				legacy = append(legacy, aws.StringValue(t.S))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, token := range legacy {
+		if err := tb.Add(model.BlacklistEntry(token)); err != nil {
+			return err
+		}
+		if _, err := tb.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(blacklistedTokensTableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"token": {S: aws.String(token)},
+			},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Add adds token to the blacklist.
-func (tb *TokenBlacklist) Add(token string) error {
-	if len(token) == 0 {
+func (tb *TokenBlacklist) Add(id string, expiresAt time.Time) error {
+	if len(id) == 0 {
 		return model.ErrorWrongDataFormat
 	}
-	if tb.IsBlacklisted(token) {
+	if model.IsBlacklistEntryExpired(expiresAt) {
 		return nil
 	}
 
-	t, err := dynamodbattribute.MarshalMap(Token{Token: token})
+	bt := blacklistedToken{Token: id}
+	if !expiresAt.IsZero() {
+		bt.ExpiresAt = expiresAt.Unix()
+	}
+	t, err := dynamodbattribute.MarshalMap(bt)
 	if err != nil {
 		tb.logger.Error("Error while marshaling token", logging.FieldError, err)
 		return ErrorInternalError
@@ -104,8 +198,8 @@ func (tb *TokenBlacklist) Add(token string) error {
 }
 
 // IsBlacklisted returns true if token is blacklisted.
-func (tb *TokenBlacklist) IsBlacklisted(token string) bool {
-	if len(token) == 0 {
+func (tb *TokenBlacklist) IsBlacklisted(id string) bool {
+	if len(id) == 0 {
 		return false
 	}
 
@@ -113,7 +207,7 @@ func (tb *TokenBlacklist) IsBlacklisted(token string) bool {
 		TableName: aws.String(blacklistedTokensTableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"token": {
-				S: aws.String(token),
+				S: aws.String(id),
 			},
 		},
 	})
@@ -126,7 +220,14 @@ func (tb *TokenBlacklist) IsBlacklisted(token string) bool {
 	if result.Item == nil {
 		return false
 	}
-	return true
+
+	var bt blacklistedToken
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &bt); err != nil {
+		tb.logger.Error("Error while unmarshaling blacklisted token", logging.FieldError, err)
+		return false
+	}
+	// DynamoDB removes the expired items within a few days.
+	return bt.ExpiresAt == 0 || !model.IsBlacklistEntryExpired(time.Unix(bt.ExpiresAt, 0))
 }
 
 // Close does nothing here.
@@ -3,6 +3,7 @@ package dynamodb
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"strconv"
 	"strings"
@@ -73,8 +74,8 @@ type UserStorage struct {
 
 // UserByID returns user by its ID.
 func (us *UserStorage) UserByID(id string) (model.User, error) {
-	idx, err := xid.FromString(id)
-	if err != nil {
+	// the IDs are not checked to be xid, the users imported from other storages keep their IDs.
+	if len(id) == 0 {
 		us.logger.Error("Incorrect user ID", logging.FieldError, id)
 		return model.User{}, model.ErrorWrongDataFormat
 	}
@@ -83,7 +84,7 @@ func (us *UserStorage) UserByID(id string) (model.User, error) {
 		TableName: aws.String(usersTableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
-				S: aws.String(idx.String()),
+				S: aws.String(id),
 			},
 		},
 	})
@@ -295,8 +296,8 @@ func (us *UserStorage) AddNewUser(usr model.User, password string) (model.User,
 }
 
 func (us *UserStorage) prepareUserForSaving(usr model.User) (model.User, error) {
-	// Generate new ID if it's not set.
-	if _, err := xid.FromString(usr.ID); err != nil {
+	// Generate new ID if it's not set, the ID of the imported user is kept as is.
+	if len(usr.ID) == 0 {
 		usr.ID = xid.New().String()
 	}
 	usr.Username = strings.ToLower(usr.Username)
@@ -348,16 +349,18 @@ func (us *UserStorage) AddUserWithPassword(user model.User, password, role strin
 	}
 
 	u := model.User{
-		ID:         xid.New().String(),
-		Active:     true,
-		Username:   user.Username,
-		Phone:      user.Phone,
-		FullName:   user.FullName,
-		Scopes:     user.Scopes,
-		Email:      user.Email,
-		AccessRole: role,
-		Anonymous:  isAnonymous,
-		TFAInfo:    user.TFAInfo,
+		ID:            xid.New().String(),
+		Active:        true,
+		Username:      user.Username,
+		Phone:         user.Phone,
+		FullName:      user.FullName,
+		Scopes:        user.Scopes,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		PhoneVerified: user.PhoneVerified,
+		AccessRole:    role,
+		Anonymous:     isAnonymous,
+		TFAInfo:       user.TFAInfo,
 	}
 
 	return us.AddNewUser(u, password)
@@ -405,7 +408,7 @@ func (us *UserStorage) AddUserWithFederatedID(user model.User, provider string,
 
 // UpdateUser updates user in DynamoDB storage.
 func (us *UserStorage) UpdateUser(userID string, user model.User) (model.User, error) {
-	if _, err := xid.FromString(userID); err != nil {
+	if len(userID) == 0 {
 		us.logger.Error("incorrect userID", logging.FieldUserID, userID)
 		return model.User{}, model.ErrorWrongDataFormat
 	}
@@ -431,19 +434,17 @@ func (us *UserStorage) UpdateUser(userID string, user model.User) (model.User, e
 
 // ResetPassword sets new user password.
 func (us *UserStorage) ResetPassword(id, password string) error {
-	idx, err := xid.FromString(id)
-	if err != nil {
+	if len(id) == 0 {
 		us.logger.Error("Incorrect user ID",
-			logging.FieldError, id,
-			logging.FieldError, err)
+			logging.FieldError, id)
 		return model.ErrorWrongDataFormat
 	}
 
 	hash := model.PasswordHash(password)
-	_, err = us.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+	_, err := us.db.C.UpdateItem(&dynamodb.UpdateItemInput{
 		TableName: aws.String(usersTableName),
 		Key: map[string]*dynamodb.AttributeValue{
-			"id": {S: aws.String(idx.String())},
+			"id": {S: aws.String(id)},
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":p": {S: aws.String(hash)},
@@ -471,18 +472,16 @@ func (us *UserStorage) CheckPassword(id, password string) error {
 
 // ResetUsername sets user username.
 func (us *UserStorage) ResetUsername(id, username string) error {
-	idx, err := xid.FromString(id)
-	if err != nil {
+	if len(id) == 0 {
 		us.logger.Error("Incorrect user ID",
-			logging.FieldUserID, id,
-			logging.FieldError, err)
+			logging.FieldUserID, id)
 		return model.ErrorWrongDataFormat
 	}
 
-	_, err = us.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+	_, err := us.db.C.UpdateItem(&dynamodb.UpdateItemInput{
 		TableName: aws.String(usersTableName),
 		Key: map[string]*dynamodb.AttributeValue{
-			"id": {S: aws.String(idx.String())},
+			"id": {S: aws.String(id)},
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":u": {S: aws.String(username)},
@@ -495,11 +494,13 @@ func (us *UserStorage) ResetUsername(id, username string) error {
 }
 
 // FetchUsers fetches users which name satisfies provided filterString.
-// Supports pagination. Search is case-sensitive for now.
+// Supports pagination, zero limit means no limit. Search is case-sensitive for now.
+// The scan stops after the page, so the table is not read to the end for every page. The total is exact
+// on the last page only, otherwise it counts one user after the page, which tells there is the next page.
+// The scan order is stable while the table is not changed.
 func (us *UserStorage) FetchUsers(filterString string, skip, limit int) ([]model.User, int, error) {
 	scanInput := &dynamodb.ScanInput{
 		TableName: aws.String(usersTableName),
-		Limit:     aws.Int64(int64(limit)),
 	}
 
 	if len(filterString) != 0 {
@@ -509,25 +510,34 @@ func (us *UserStorage) FetchUsers(filterString string, skip, limit int) ([]model
 		}
 	}
 
-	result, err := us.db.C.Scan(scanInput)
+	users := []model.User{}
+	total := 0
+	var unmarshalErr error
+	err := us.db.C.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			total++
+			if total <= skip {
+				continue
+			}
+			if limit > 0 && len(users) == limit {
+				return false
+			}
+			user := model.User{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(item, &user); unmarshalErr != nil {
+				return false
+			}
+			users = append(users, user)
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
 	if err != nil {
 		us.logger.Error("Error querying for users", logging.FieldError, err)
 		return []model.User{}, 0, ErrorInternalError
 	}
-
-	users := make([]model.User, len(result.Items))
-	for i := 0; i < len(result.Items); i++ {
-		if i < skip {
-			continue // TODO: use internal pagination mechanism
-		}
-		user := model.User{}
-		if err = dynamodbattribute.UnmarshalMap(result.Items[i], &user); err != nil {
-			us.logger.Error("Error while unmarshal user", logging.FieldError, err)
-			return []model.User{}, 0, ErrorInternalError
-		}
-		users[i] = user
-	}
-	return users, len(result.Items), nil
+	return users, total, nil
 }
 
 // ImportJSON imports data from JSON.
@@ -545,6 +555,10 @@ func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
 	for _, u := range ud {
 		pswd := u.Pswd
 		u.Pswd = ""
+		// the exported users have the password hash, which is kept as is.
+		if model.IsPasswordHash(pswd) {
+			u.Pswd, pswd = pswd, ""
+		}
 		if _, err := us.AddNewUser(u, pswd); err != nil {
 			return err
 		}
@@ -552,12 +566,16 @@ func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports all users to JSON.
+func (us *UserStorage) ExportJSON(w io.Writer) error {
+	return exportTable[model.User](us.db, usersTableName, w)
+}
+
 // UpdateLoginMetadata updates user's login metadata.
 func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes []string, payload map[string]any) {
-	if _, err := xid.FromString(userID); err != nil {
+	if len(userID) == 0 {
 		us.logger.Error("Incorrect userID",
-			logging.FieldUserID, userID,
-			logging.FieldError, err)
+			logging.FieldUserID, userID)
 		return
 	}
 
@@ -588,6 +606,44 @@ func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes
 	}
 }
 
+// ReplaceRecoveryCodes replaces the recovery codes of the user if they are still the old ones,
+// the codes are compared in the update condition.
+func (us *UserStorage) ReplaceRecoveryCodes(userID string, old, codes []string) (bool, error) {
+	oldValue, err := dynamodbattribute.Marshal(old)
+	if err != nil {
+		return false, err
+	}
+	codesValue, err := dynamodbattribute.Marshal(codes)
+	if err != nil {
+		return false, err
+	}
+
+	condition := "attribute_exists(id) AND tfa_info.recovery_codes = :old"
+	values := map[string]*dynamodb.AttributeValue{":codes": codesValue}
+	if len(old) == 0 {
+		condition = "attribute_exists(id) AND (attribute_not_exists(tfa_info.recovery_codes) OR size(tfa_info.recovery_codes) = :zero)"
+		values[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	} else {
+		values[":old"] = oldValue
+	}
+
+	_, err = us.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(usersTableName),
+		Key:                       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(userID)}},
+		ConditionExpression:       aws.String(condition),
+		UpdateExpression:          aws.String("set tfa_info.recovery_codes = :codes"),
+		ExpressionAttributeValues: values,
+	})
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		us.logger.Error("Error replacing recovery codes", logging.FieldError, err)
+		return false, ErrorInternalError
+	}
+	return true, nil
+}
+
 // ensureTable ensures that user storage table exists in the database.
 // I'm hiding it in the end of the file, because AWS devs, you are killing me with this API.
 func (us *UserStorage) ensureTable() error {
@@ -0,0 +1,316 @@
+package dynamodb
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+const (
+	userSessionsTableName          = "UserSessions"
+	userSessionUserIndexName       = "user-session-user-id"
+	userSessionRefreshIndexName    = "user-session-refresh-token-id"
+	userSessionExpiresAtAttribute  = "expires_at"
+	userSessionRefreshTokenIDField = "refresh_token_id"
+)
+
+// userSession is a DynamoDB item of the user session.
+// Expiration time is in unix seconds, as required by DynamoDB TTL.
+type userSession struct {
+	model.UserSession
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+func (s userSession) model() model.UserSession {
+	us := s.UserSession
+	us.ExpiresAt = time.Unix(s.ExpiresAt, 0)
+	return us
+}
+
+// UserSessionStorage is a DynamoDB user session storage.
+// Expired sessions are deleted by DynamoDB TTL with some delay, so the expiration is checked on every access too.
+type UserSessionStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// NewUserSessionStorage creates new DynamoDB user session storage.
+func NewUserSessionStorage(
+	logger *slog.Logger,
+	settings model.DynamoDatabaseSettings,
+) (model.UserSessionStorage, error) {
+	if len(settings.Endpoint) == 0 || len(settings.Region) == 0 {
+		return nil, ErrorEmptyEndpointRegion
+	}
+
+	// create database
+	db, err := NewDB(settings.Endpoint, settings.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	us := &UserSessionStorage{
+		logger: logger,
+		db:     db,
+	}
+	err = us.ensureTable()
+	return us, err
+}
+
+// ensureTable ensures that user sessions table exists in the database and has TTL enabled.
+func (us *UserSessionStorage) ensureTable() error {
+	exists, err := us.db.IsTableExists(userSessionsTableName)
+	if err != nil {
+		us.logger.Error("Error checking user sessions table existence", logging.FieldError, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("user_id"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String(userSessionRefreshTokenIDField),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(userSessionUserIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{
+						AttributeName: aws.String("user_id"),
+						KeyType:       aws.String("HASH"),
+					},
+				},
+				Projection: &dynamodb.Projection{
+					ProjectionType: aws.String("ALL"),
+				},
+			},
+			{
+				IndexName: aws.String(userSessionRefreshIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{
+						AttributeName: aws.String(userSessionRefreshTokenIDField),
+						KeyType:       aws.String("HASH"),
+					},
+				},
+				Projection: &dynamodb.Projection{
+					ProjectionType: aws.String("ALL"),
+				},
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(userSessionsTableName),
+	}
+
+	if _, err = us.db.C.CreateTable(input); err != nil {
+		return err
+	}
+
+	// TTL could be enabled for the active table only
+	if err = us.db.C.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(userSessionsTableName),
+	}); err != nil {
+		return err
+	}
+
+	_, err = us.db.C.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(userSessionsTableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(userSessionExpiresAtAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
+
+// AddSession saves the new session.
+func (us *UserSessionStorage) AddSession(session model.UserSession) (model.UserSession, error) {
+	if len(session.ID) == 0 {
+		session.ID = xid.New().String()
+	}
+	if err := us.put(session, nil); err != nil {
+		return model.UserSession{}, err
+	}
+	return session, nil
+}
+
+// UpdateSession replaces the session.
+func (us *UserSessionStorage) UpdateSession(session model.UserSession) error {
+	return us.put(session, aws.String("attribute_exists(id)"))
+}
+
+func (us *UserSessionStorage) put(session model.UserSession, condition *string) error {
+	item, err := dynamodbattribute.MarshalMap(userSession{UserSession: session, ExpiresAt: session.ExpiresAt.Unix()})
+	if err != nil {
+		us.logger.Error("Error marshalling user session", logging.FieldError, err)
+		return ErrorInternalError
+	}
+
+	if _, err = us.db.C.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(userSessionsTableName),
+		Item:                item,
+		ConditionExpression: condition,
+	}); err != nil {
+		if isConditionalCheckFailed(err) {
+			return model.ErrorNotFound
+		}
+		us.logger.Error("Error putting user session", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// SessionByID returns the session by its ID.
+func (us *UserSessionStorage) SessionByID(id string) (model.UserSession, error) {
+	result, err := us.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(userSessionsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		us.logger.Error("Error getting user session", logging.FieldError, err)
+		return model.UserSession{}, ErrorInternalError
+	}
+	if result.Item == nil {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+
+	s := userSession{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &s); err != nil {
+		us.logger.Error("Error unmarshalling user session", logging.FieldError, err)
+		return model.UserSession{}, ErrorInternalError
+	}
+	if s.ExpiresAt <= time.Now().Unix() {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	return s.model(), nil
+}
+
+// SessionByRefreshTokenID returns the session by the jti of its refresh token.
+func (us *UserSessionStorage) SessionByRefreshTokenID(tokenID string) (model.UserSession, error) {
+	sessions, err := us.query(userSessionRefreshIndexName, userSessionRefreshTokenIDField, tokenID)
+	if err != nil {
+		return model.UserSession{}, err
+	}
+	if len(sessions) == 0 {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	return sessions[0], nil
+}
+
+// UserSessions returns the sessions of the user, the latest refreshed first.
+func (us *UserSessionStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	sessions, err := us.query(userSessionUserIndexName, "user_id", userID)
+	if err != nil {
+		return []model.UserSession{}, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastRefreshAt.After(sessions[j].LastRefreshAt)
+	})
+	return sessions, nil
+}
+
+// query returns not expired sessions from the index.
+func (us *UserSessionStorage) query(index, attribute, value string) ([]model.UserSession, error) {
+	sessions := []model.UserSession{}
+	now := time.Now().Unix()
+
+	var unmarshalErr error
+	err := us.db.C.QueryPages(&dynamodb.QueryInput{
+		TableName:              aws.String(userSessionsTableName),
+		IndexName:              aws.String(index),
+		KeyConditionExpression: aws.String(attribute + " = :v"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":v": {S: aws.String(value)},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			s := userSession{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(item, &s); unmarshalErr != nil {
+				return false
+			}
+			if s.ExpiresAt > now {
+				sessions = append(sessions, s.model())
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		us.logger.Error("Error querying for user sessions", logging.FieldError, err)
+		return []model.UserSession{}, ErrorInternalError
+	}
+	return sessions, nil
+}
+
+// DeleteSession deletes the session.
+func (us *UserSessionStorage) DeleteSession(id string) error {
+	_, err := us.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(userSessionsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if isConditionalCheckFailed(err) {
+		return model.ErrorNotFound
+	}
+	if err != nil {
+		us.logger.Error("Error deleting user session", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// DeleteUserSessions deletes all sessions of the user.
+func (us *UserSessionStorage) DeleteUserSessions(userID string) (int, error) {
+	sessions, err := us.query(userSessionUserIndexName, "user_id", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, s := range sessions {
+		err := us.DeleteSession(s.ID)
+		if err == model.ErrorNotFound {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Close does nothing here.
+func (us *UserSessionStorage) Close() {}
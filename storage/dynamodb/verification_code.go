@@ -2,6 +2,7 @@ package dynamodb
 
 import (
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -15,14 +16,32 @@ const (
 	// verificationCodesTableName is a table name for verification codes.
 	verificationCodesTableName = "VerificationCodes"
 
-	// verificationCodesExpirationTime specifies time before deleting records.
-	verificationCodesExpirationTime = 5 * time.Minute
-
-	phoneField     = "phone"
-	codeField      = "code"
+	phoneField = "phone"
+	// expiresAtField is the TTL attribute, the item is deleted when the code is not needed anymore.
 	expiresAtField = "expiresAt"
 )
 
+// verificationCode is the code item, the times are in Unix seconds.
+// The items saved before the attempts were limited have no code expiration, so they are expired.
+type verificationCode struct {
+	Phone         string `json:"phone"`
+	Code          string `json:"code"`
+	CodeExpiresAt int64  `json:"codeExpiresAt"`
+	ResendAt      int64  `json:"resendAt"`
+	AttemptsLeft  int    `json:"attemptsLeft"`
+	ExpiresAt     int64  `json:"expiresAt"`
+}
+
+func (c verificationCode) model() model.VerificationCode {
+	return model.VerificationCode{
+		Phone:        c.Phone,
+		Code:         c.Code,
+		ExpiresAt:    time.Unix(c.CodeExpiresAt, 0),
+		ResendAt:     time.Unix(c.ResendAt, 0),
+		AttemptsLeft: c.AttemptsLeft,
+	}
+}
+
 // NewVerificationCodeStorage creates and provisions new DynamoDB verification code storage.
 func NewVerificationCodeStorage(
 	logger *slog.Logger,
@@ -52,61 +71,136 @@ type VerificationCodeStorage struct {
 	db     *DB
 }
 
-// IsVerificationCodeFound checks whether verification code can be found.
-func (vcs *VerificationCodeStorage) IsVerificationCodeFound(phone, code string) (bool, error) {
-	result, err := vcs.db.C.Query(&dynamodb.QueryInput{
-		TableName:              aws.String(verificationCodesTableName),
-		KeyConditionExpression: aws.String("phone = :phone"),
+// CreateVerificationCode saves the new code of the phone, replacing the previous one if the resend cooldown has passed.
+func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string, policy model.VerificationCodePolicy) (model.VerificationCodeStatus, error) {
+	now := time.Now()
+	vc := model.NewVerificationCode(phone, code, policy, now)
+
+	item, err := dynamodbattribute.MarshalMap(verificationCode{
+		Phone:         vc.Phone,
+		Code:          vc.Code,
+		CodeExpiresAt: vc.ExpiresAt.Unix(),
+		ResendAt:      vc.ResendAt.Unix(),
+		AttemptsLeft:  vc.AttemptsLeft,
+		ExpiresAt:     vc.DeleteAt().Unix(),
+	})
+	if err != nil {
+		vcs.logger.Error("Error marshalling verification code", logging.FieldError, err)
+		return model.VerificationCodeStatus{}, ErrorInternalError
+	}
+
+	_, err = vcs.db.C.PutItem(&dynamodb.PutItemInput{
+		Item:                item,
+		TableName:           aws.String(verificationCodesTableName),
+		ConditionExpression: aws.String("attribute_not_exists(resendAt) OR resendAt <= :now"),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":phone": {S: aws.String(phone)},
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
 		},
 	})
-	if err != nil {
-		vcs.logger.Error("Error querying for verification code", logging.FieldError, err)
-		return false, ErrorInternalError
+	if isConditionalCheckFailed(err) {
+		prev, ok, err := vcs.get(phone)
+		if err != nil || !ok {
+			return model.VerificationCodeStatus{}, ErrorInternalError
+		}
+		return prev.Status(), model.ErrorVerificationCodeCooldown
 	}
-	if len(result.Items) == 0 {
-		return false, nil
+	if err != nil {
+		vcs.logger.Error("Error putting verification code to database", logging.FieldError, err)
+		return model.VerificationCodeStatus{}, ErrorInternalError
 	}
-	return true, nil
+	return vc.Status(), nil
 }
 
-// CreateVerificationCode inserts new verification code to the database.
-func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string) error {
-	// Remove old item first.
-	delInput := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			phoneField: {S: aws.String(phone)},
-		},
-		TableName: aws.String(verificationCodesTableName),
+// VerifyCode checks the code of the phone, the matching code is deleted, the mismatch uses the attempt.
+// The conditional writes make the check atomic.
+func (vcs *VerificationCodeStorage) VerifyCode(phone, code string) (model.VerificationCodeStatus, error) {
+	now := time.Now()
+	key := map[string]*dynamodb.AttributeValue{
+		phoneField: {S: aws.String(phone)},
 	}
+	usable := "codeExpiresAt > :now AND attemptsLeft > :zero"
 
-	if _, err := vcs.db.C.DeleteItem(delInput); err != nil {
-		vcs.logger.Error("Error deleting old verification code", logging.FieldError, err)
-		return ErrorInternalError
+	deleted, err := vcs.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:           aws.String(verificationCodesTableName),
+		Key:                 key,
+		ConditionExpression: aws.String(usable + " AND code = :code"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":  {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+			":zero": {N: aws.String("0")},
+			":code": {S: aws.String(code)},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err == nil {
+		vc := verificationCode{}
+		if err = dynamodbattribute.UnmarshalMap(deleted.Attributes, &vc); err != nil {
+			vcs.logger.Error("Error unmarshalling verification code", logging.FieldError, err)
+		}
+		return vc.model().Status(), nil
+	}
+	if !isConditionalCheckFailed(err) {
+		vcs.logger.Error("Error deleting verification code", logging.FieldError, err)
+		return model.VerificationCodeStatus{}, ErrorInternalError
 	}
 
-	// Then put a new one.
-	item, err := dynamodbattribute.MarshalMap(map[string]interface{}{
-		phoneField:     phone,
-		codeField:      code,
-		expiresAtField: time.Now().Add(verificationCodesExpirationTime),
+	updated, err := vcs.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(verificationCodesTableName),
+		Key:                 key,
+		UpdateExpression:    aws.String("ADD attemptsLeft :minusOne"),
+		ConditionExpression: aws.String("attribute_exists(phone) AND " + usable),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":      {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+			":zero":     {N: aws.String("0")},
+			":minusOne": {N: aws.String("-1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
 	})
+	if err == nil {
+		vc := verificationCode{}
+		if err = dynamodbattribute.UnmarshalMap(updated.Attributes, &vc); err != nil {
+			vcs.logger.Error("Error unmarshalling verification code", logging.FieldError, err)
+			return model.VerificationCodeStatus{}, ErrorInternalError
+		}
+		return vc.model().Status(), model.ErrorVerificationCodeInvalid
+	}
+	if !isConditionalCheckFailed(err) {
+		vcs.logger.Error("Error updating verification code", logging.FieldError, err)
+		return model.VerificationCodeStatus{}, ErrorInternalError
+	}
+
+	// the code has no attempts left or has expired.
+	vc, ok, err := vcs.get(phone)
 	if err != nil {
-		vcs.logger.Error("Error marshalling verification code", logging.FieldError, err)
-		return ErrorInternalError
+		return model.VerificationCodeStatus{}, ErrorInternalError
 	}
+	if ok && now.Before(vc.ExpiresAt) {
+		return vc.Status(), model.ErrorVerificationCodeAttemptsExceeded
+	}
+	return vc.Status(), model.ErrorVerificationCodeExpired
+}
 
-	putInput := &dynamodb.PutItemInput{
-		Item:      item,
+func (vcs *VerificationCodeStorage) get(phone string) (model.VerificationCode, bool, error) {
+	result, err := vcs.db.C.GetItem(&dynamodb.GetItemInput{
 		TableName: aws.String(verificationCodesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			phoneField: {S: aws.String(phone)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		vcs.logger.Error("Error getting verification code", logging.FieldError, err)
+		return model.VerificationCode{}, false, err
+	}
+	if result.Item == nil {
+		return model.VerificationCode{}, false, nil
 	}
 
-	if _, err := vcs.db.C.PutItem(putInput); err != nil {
-		vcs.logger.Error("Error putting verification code to database", logging.FieldError, err)
-		return ErrorInternalError
+	vc := verificationCode{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &vc); err != nil {
+		// the items saved before the attempts were limited have the string expiration.
+		return model.VerificationCode{Phone: phone}, true, nil
 	}
-	return err
+	return vc.model(), true, nil
 }
 
 // ensureTable ensures that verification code storage table exists in the database.
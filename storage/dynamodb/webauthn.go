@@ -0,0 +1,336 @@
+package dynamodb
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	webAuthnCredentialsTableName    = "WebAuthnCredentials"
+	webAuthnCredentialUserIndexName = "webauthn-credential-user-id"
+	webAuthnSessionsTableName       = "WebAuthnSessions"
+)
+
+// webAuthnSession is a DynamoDB item of the ceremony session.
+// Expiration time is in unix seconds, as required by DynamoDB TTL.
+type webAuthnSession struct {
+	ID        string `json:"id"`
+	Data      string `json:"data"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// WebAuthnStorage is a DynamoDB WebAuthn credentials storage.
+// Expired sessions are deleted by DynamoDB TTL with some delay, so the expiration is checked on every access too.
+type WebAuthnStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// NewWebAuthnStorage creates new DynamoDB WebAuthn credentials storage.
+func NewWebAuthnStorage(
+	logger *slog.Logger,
+	settings model.DynamoDatabaseSettings,
+) (model.WebAuthnStorage, error) {
+	if len(settings.Endpoint) == 0 || len(settings.Region) == 0 {
+		return nil, ErrorEmptyEndpointRegion
+	}
+
+	// create database
+	db, err := NewDB(settings.Endpoint, settings.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &WebAuthnStorage{
+		logger: logger,
+		db:     db,
+	}
+	if err = ws.ensureTable(); err != nil {
+		return ws, err
+	}
+	err = ws.ensureSessionsTable()
+	return ws, err
+}
+
+// ensureTable ensures that WebAuthn credentials table exists in the database.
+func (ws *WebAuthnStorage) ensureTable() error {
+	exists, err := ws.db.IsTableExists(webAuthnCredentialsTableName)
+	if err != nil {
+		ws.logger.Error("Error checking WebAuthn credentials table existence", logging.FieldError, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("user_id"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(webAuthnCredentialUserIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{
+						AttributeName: aws.String("user_id"),
+						KeyType:       aws.String("HASH"),
+					},
+				},
+				Projection: &dynamodb.Projection{
+					ProjectionType: aws.String("ALL"),
+				},
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(webAuthnCredentialsTableName),
+	}
+
+	_, err = ws.db.C.CreateTable(input)
+	return err
+}
+
+// ensureSessionsTable ensures that WebAuthn sessions table exists in the database and has TTL enabled.
+func (ws *WebAuthnStorage) ensureSessionsTable() error {
+	exists, err := ws.db.IsTableExists(webAuthnSessionsTableName)
+	if err != nil {
+		ws.logger.Error("Error checking WebAuthn sessions table existence", logging.FieldError, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(webAuthnSessionsTableName),
+	}
+
+	if _, err = ws.db.C.CreateTable(input); err != nil {
+		return err
+	}
+
+	// TTL could be enabled for the active table only
+	if err = ws.db.C.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(webAuthnSessionsTableName),
+	}); err != nil {
+		return err
+	}
+
+	_, err = ws.db.C.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(webAuthnSessionsTableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String("expires_at"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
+
+// AddCredential adds new credential.
+func (ws *WebAuthnStorage) AddCredential(credential model.WebAuthnCredential) error {
+	return ws.put(credential, "attribute_not_exists(id)", model.ErrorWrongDataFormat)
+}
+
+// UpdateCredential updates existing credential.
+func (ws *WebAuthnStorage) UpdateCredential(credential model.WebAuthnCredential) error {
+	return ws.put(credential, "attribute_exists(id)", model.ErrorNotFound)
+}
+
+func (ws *WebAuthnStorage) put(credential model.WebAuthnCredential, condition string, conditionErr error) error {
+	item, err := dynamodbattribute.MarshalMap(credential)
+	if err != nil {
+		ws.logger.Error("Error marshalling WebAuthn credential", logging.FieldError, err)
+		return ErrorInternalError
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                item,
+		TableName:           aws.String(webAuthnCredentialsTableName),
+		ConditionExpression: aws.String(condition),
+	}
+
+	if _, err = ws.db.C.PutItem(input); err != nil {
+		if isConditionalCheckFailed(err) {
+			return conditionErr
+		}
+		ws.logger.Error("Error putting WebAuthn credential to storage", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// CredentialByID returns credential by its id.
+func (ws *WebAuthnStorage) CredentialByID(id string) (model.WebAuthnCredential, error) {
+	if len(id) == 0 {
+		return model.WebAuthnCredential{}, model.ErrorWrongDataFormat
+	}
+
+	result, err := ws.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(webAuthnCredentialsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		ws.logger.Error("Error getting WebAuthn credential", logging.FieldError, err)
+		return model.WebAuthnCredential{}, ErrorInternalError
+	}
+
+	if result.Item == nil {
+		return model.WebAuthnCredential{}, model.ErrorNotFound
+	}
+
+	credential := model.WebAuthnCredential{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &credential); err != nil {
+		ws.logger.Error("Error unmarshalling WebAuthn credential", logging.FieldError, err)
+		return model.WebAuthnCredential{}, ErrorInternalError
+	}
+	return credential, nil
+}
+
+// CredentialsByUserID returns all credentials of the user, ordered by creation time.
+func (ws *WebAuthnStorage) CredentialsByUserID(userID string) ([]model.WebAuthnCredential, error) {
+	result, err := ws.db.C.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(webAuthnCredentialsTableName),
+		IndexName:              aws.String(webAuthnCredentialUserIndexName),
+		KeyConditionExpression: aws.String("user_id = :u"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":u": {S: aws.String(userID)},
+		},
+	})
+	if err != nil {
+		ws.logger.Error("Error querying for WebAuthn credentials by user id", logging.FieldError, err)
+		return nil, ErrorInternalError
+	}
+
+	credentials := []model.WebAuthnCredential{}
+	if err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &credentials); err != nil {
+		ws.logger.Error("Error unmarshalling WebAuthn credentials", logging.FieldError, err)
+		return nil, ErrorInternalError
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, nil
+}
+
+// DeleteCredential deletes credential by its id.
+func (ws *WebAuthnStorage) DeleteCredential(id string) error {
+	_, err := ws.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(webAuthnCredentialsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return model.ErrorNotFound
+		}
+		ws.logger.Error("Error deleting WebAuthn credential", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// SaveSession saves the session of the ceremony.
+func (ws *WebAuthnStorage) SaveSession(session model.WebAuthnSession) error {
+	item, err := dynamodbattribute.MarshalMap(webAuthnSession{
+		ID:        session.ID,
+		Data:      session.Data,
+		ExpiresAt: session.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		ws.logger.Error("Error marshalling WebAuthn session", logging.FieldError, err)
+		return ErrorInternalError
+	}
+
+	if _, err = ws.db.C.PutItem(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(webAuthnSessionsTableName),
+	}); err != nil {
+		ws.logger.Error("Error putting WebAuthn session to storage", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// TakeSession returns and deletes the session with the conditional delete, so it could not be taken twice.
+func (ws *WebAuthnStorage) TakeSession(id string) (model.WebAuthnSession, error) {
+	if len(id) == 0 {
+		return model.WebAuthnSession{}, model.ErrorNotFound
+	}
+
+	result, err := ws.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(webAuthnSessionsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConditionExpression: aws.String("attribute_exists(id) AND expires_at > :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return model.WebAuthnSession{}, model.ErrorNotFound
+		}
+		ws.logger.Error("Error deleting WebAuthn session", logging.FieldError, err)
+		return model.WebAuthnSession{}, ErrorInternalError
+	}
+
+	session := webAuthnSession{}
+	if err = dynamodbattribute.UnmarshalMap(result.Attributes, &session); err != nil {
+		ws.logger.Error("Error unmarshalling WebAuthn session", logging.FieldError, err)
+		return model.WebAuthnSession{}, ErrorInternalError
+	}
+	return model.WebAuthnSession{
+		ID:        session.ID,
+		Data:      session.Data,
+		ExpiresAt: time.Unix(session.ExpiresAt, 0),
+	}, nil
+}
+
+// Close does nothing here.
+func (ws *WebAuthnStorage) Close() {}
+
+func isConditionalCheckFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
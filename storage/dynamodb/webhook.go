@@ -0,0 +1,420 @@
+package dynamodb
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+const (
+	webhookDeliveriesTableName = "WebhookDeliveries"
+	// webhookDeliveryStatusIndexName is the index of the deliveries by the status, sorted by the next attempt time,
+	// it is queried for the due and the finished deliveries.
+	webhookDeliveryStatusIndexName = "webhook-delivery-status"
+)
+
+// webhookDelivery is a DynamoDB item of the webhook delivery.
+// Times are in unix nanoseconds to compare them in expressions.
+type webhookDelivery struct {
+	model.WebhookDelivery
+	NextAttemptAt int64 `json:"next_attempt_at"`
+	LastAttemptAt int64 `json:"last_attempt_at,omitempty"`
+	CreatedAt     int64 `json:"created_at"`
+}
+
+func toWebhookDeliveryItem(d model.WebhookDelivery) webhookDelivery {
+	item := webhookDelivery{
+		WebhookDelivery: d,
+		NextAttemptAt:   d.NextAttemptAt.UnixNano(),
+		CreatedAt:       d.CreatedAt.UnixNano(),
+	}
+	if !d.LastAttemptAt.IsZero() {
+		item.LastAttemptAt = d.LastAttemptAt.UnixNano()
+	}
+	return item
+}
+
+func (item webhookDelivery) delivery() model.WebhookDelivery {
+	d := item.WebhookDelivery
+	d.NextAttemptAt = time.Unix(0, item.NextAttemptAt)
+	d.CreatedAt = time.Unix(0, item.CreatedAt)
+	if item.LastAttemptAt > 0 {
+		d.LastAttemptAt = time.Unix(0, item.LastAttemptAt)
+	}
+	return d
+}
+
+// WebhookDeliveryStorage is a DynamoDB webhook delivery storage.
+// Deliveries are keyed by xid, which is sorted by creation time.
+type WebhookDeliveryStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// NewWebhookDeliveryStorage creates new DynamoDB webhook delivery storage.
+func NewWebhookDeliveryStorage(
+	logger *slog.Logger,
+	settings model.DynamoDatabaseSettings,
+) (model.WebhookDeliveryStorage, error) {
+	if len(settings.Endpoint) == 0 || len(settings.Region) == 0 {
+		return nil, ErrorEmptyEndpointRegion
+	}
+
+	// create database
+	db, err := NewDB(settings.Endpoint, settings.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &WebhookDeliveryStorage{
+		logger: logger,
+		db:     db,
+	}
+	err = ws.ensureTable()
+	return ws, err
+}
+
+// webhookDeliveryStatusIndex is the status index, sorted by the next attempt time.
+var webhookDeliveryStatusIndex = &dynamodb.GlobalSecondaryIndex{
+	IndexName: aws.String(webhookDeliveryStatusIndexName),
+	KeySchema: []*dynamodb.KeySchemaElement{
+		{
+			AttributeName: aws.String("status"),
+			KeyType:       aws.String("HASH"),
+		},
+		{
+			AttributeName: aws.String("next_attempt_at"),
+			KeyType:       aws.String("RANGE"),
+		},
+	},
+	Projection: &dynamodb.Projection{
+		ProjectionType: aws.String("ALL"),
+	},
+}
+
+// ensureTable ensures that webhook deliveries table exists in the database and has the status index.
+func (ws *WebhookDeliveryStorage) ensureTable() error {
+	exists, err := ws.db.IsTableExists(webhookDeliveriesTableName)
+	if err != nil {
+		ws.logger.Error("Error checking webhook deliveries table existence", logging.FieldError, err)
+		return err
+	}
+
+	attributes := []*dynamodb.AttributeDefinition{
+		{
+			AttributeName: aws.String("id"),
+			AttributeType: aws.String("S"),
+		},
+		{
+			AttributeName: aws.String("status"),
+			AttributeType: aws.String("S"),
+		},
+		{
+			AttributeName: aws.String("next_attempt_at"),
+			AttributeType: aws.String("N"),
+		},
+	}
+	if exists {
+		return ws.ensureStatusIndex(attributes)
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: attributes,
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{webhookDeliveryStatusIndex},
+		BillingMode:            aws.String("PAY_PER_REQUEST"),
+		TableName:              aws.String(webhookDeliveriesTableName),
+	}
+
+	_, err = ws.db.C.CreateTable(input)
+	return err
+}
+
+// ensureStatusIndex adds the status index to the table created by the older versions.
+// The deliveries could not be claimed until the index is backfilled.
+func (ws *WebhookDeliveryStorage) ensureStatusIndex(attributes []*dynamodb.AttributeDefinition) error {
+	table, err := ws.db.C.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(webhookDeliveriesTableName),
+	})
+	if err != nil {
+		return err
+	}
+	for _, index := range table.Table.GlobalSecondaryIndexes {
+		if aws.StringValue(index.IndexName) == webhookDeliveryStatusIndexName {
+			return nil
+		}
+	}
+
+	_, err = ws.db.C.UpdateTable(&dynamodb.UpdateTableInput{
+		TableName:            aws.String(webhookDeliveriesTableName),
+		AttributeDefinitions: attributes,
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+			{
+				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName:  webhookDeliveryStatusIndex.IndexName,
+					KeySchema:  webhookDeliveryStatusIndex.KeySchema,
+					Projection: webhookDeliveryStatusIndex.Projection,
+				},
+			},
+		},
+	})
+	return err
+}
+
+// AddDelivery queues the delivery.
+func (ws *WebhookDeliveryStorage) AddDelivery(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	delivery.ID = xid.New().String()
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+
+	if err := ws.put(delivery, nil); err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// ClaimDueDeliveries returns pending deliveries due at now and postpones them for the lease duration.
+// The due deliveries are queried from the status index in the order of the next attempt time.
+// Every delivery is claimed with the conditional update, so concurrent servers never claim the same delivery.
+func (ws *WebhookDeliveryStorage) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	due := []model.WebhookDelivery{}
+	err := ws.query(model.WebhookDeliveryPending, "next_attempt_at <= :t", now, func(d model.WebhookDelivery) bool {
+		due = append(due, d)
+		return limit <= 0 || len(due) < limit
+	})
+	if err != nil {
+		return []model.WebhookDelivery{}, err
+	}
+
+	claimed := []model.WebhookDelivery{}
+	for _, d := range due {
+		if limit > 0 && len(claimed) >= limit {
+			break
+		}
+
+		_, err := ws.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+			TableName: aws.String(webhookDeliveriesTableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"id": {S: aws.String(d.ID)},
+			},
+			UpdateExpression:         aws.String("SET next_attempt_at = :lease"),
+			ConditionExpression:      aws.String("#status = :status AND next_attempt_at = :prev"),
+			ExpressionAttributeNames: map[string]*string{"#status": aws.String("status")},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":lease":  {N: aws.String(strconv.FormatInt(now.Add(lease).UnixNano(), 10))},
+				":status": {S: aws.String(string(model.WebhookDeliveryPending))},
+				":prev":   {N: aws.String(strconv.FormatInt(d.NextAttemptAt.UnixNano(), 10))},
+			},
+		})
+		if isConditionalCheckFailed(err) {
+			// claimed by the other server
+			continue
+		}
+		if err != nil {
+			ws.logger.Error("Error claiming webhook delivery", logging.FieldError, err)
+			return claimed, ErrorInternalError
+		}
+		claimed = append(claimed, d)
+	}
+	return claimed, nil
+}
+
+// UpdateDelivery replaces the delivery.
+func (ws *WebhookDeliveryStorage) UpdateDelivery(delivery model.WebhookDelivery) error {
+	return ws.put(delivery, aws.String("attribute_exists(id)"))
+}
+
+// DeliveryByID returns the delivery by its ID.
+func (ws *WebhookDeliveryStorage) DeliveryByID(id string) (model.WebhookDelivery, error) {
+	result, err := ws.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(webhookDeliveriesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		ws.logger.Error("Error getting webhook delivery", logging.FieldError, err)
+		return model.WebhookDelivery{}, ErrorInternalError
+	}
+	if result.Item == nil {
+		return model.WebhookDelivery{}, model.ErrorNotFound
+	}
+
+	item := webhookDelivery{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		ws.logger.Error("Error unmarshalling webhook delivery", logging.FieldError, err)
+		return model.WebhookDelivery{}, ErrorInternalError
+	}
+	return item.delivery(), nil
+}
+
+// FetchDeliveries returns deliveries with the status, newest first.
+// The index is sorted by the next attempt time, so all matching deliveries are read and sorted in memory.
+func (ws *WebhookDeliveryStorage) FetchDeliveries(status model.WebhookDeliveryStatus, skip, limit int) ([]model.WebhookDelivery, int, error) {
+	var (
+		deliveries = []model.WebhookDelivery{}
+		err        error
+	)
+	if len(status) > 0 {
+		err = ws.query(status, "", time.Time{}, func(d model.WebhookDelivery) bool {
+			deliveries = append(deliveries, d)
+			return true
+		})
+	} else {
+		deliveries, err = ws.scan()
+	}
+	if err != nil {
+		return []model.WebhookDelivery{}, 0, err
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID > deliveries[j].ID
+	})
+
+	total := len(deliveries)
+	if skip >= total {
+		return []model.WebhookDelivery{}, total, nil
+	}
+	deliveries = deliveries[skip:]
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, total, nil
+}
+
+// DeleteFinishedDeliveries deletes the delivered and dead deliveries finished before the time,
+// they are queried from the status index.
+func (ws *WebhookDeliveryStorage) DeleteFinishedDeliveries(before time.Time) (int, error) {
+	deleted := 0
+	for _, status := range []model.WebhookDeliveryStatus{model.WebhookDeliveryDelivered, model.WebhookDeliveryDead} {
+		var deleteErr error
+		err := ws.query(status, "next_attempt_at < :t", before, func(d model.WebhookDelivery) bool {
+			_, deleteErr = ws.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+				TableName: aws.String(webhookDeliveriesTableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"id": {S: aws.String(d.ID)},
+				},
+			})
+			if deleteErr != nil {
+				return false
+			}
+			deleted++
+			return true
+		})
+		if err == nil && deleteErr != nil {
+			ws.logger.Error("Error deleting webhook delivery", logging.FieldError, deleteErr)
+			err = ErrorInternalError
+		}
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// Close does nothing here.
+func (ws *WebhookDeliveryStorage) Close() {}
+
+func (ws *WebhookDeliveryStorage) put(delivery model.WebhookDelivery, condition *string) error {
+	item, err := dynamodbattribute.MarshalMap(toWebhookDeliveryItem(delivery))
+	if err != nil {
+		ws.logger.Error("Error marshalling webhook delivery", logging.FieldError, err)
+		return ErrorInternalError
+	}
+
+	if _, err = ws.db.C.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(webhookDeliveriesTableName),
+		Item:                item,
+		ConditionExpression: condition,
+	}); err != nil {
+		if isConditionalCheckFailed(err) {
+			return model.ErrorNotFound
+		}
+		ws.logger.Error("Error putting webhook delivery", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// query reads the deliveries with the status from the status index, in the order of the next attempt time,
+// and passes them to f until it returns false. The condition on next_attempt_at compares it with :t, it is optional.
+func (ws *WebhookDeliveryStorage) query(status model.WebhookDeliveryStatus, condition string, t time.Time, f func(d model.WebhookDelivery) bool) error {
+	keyCondition := "#status = :status"
+	values := map[string]*dynamodb.AttributeValue{
+		":status": {S: aws.String(string(status))},
+	}
+	if len(condition) > 0 {
+		keyCondition += " AND " + condition
+		values[":t"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(t.UnixNano(), 10))}
+	}
+
+	var unmarshalErr error
+	err := ws.db.C.QueryPages(&dynamodb.QueryInput{
+		TableName:                 aws.String(webhookDeliveriesTableName),
+		IndexName:                 aws.String(webhookDeliveryStatusIndexName),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeNames:  map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: values,
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, i := range page.Items {
+			item := webhookDelivery{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(i, &item); unmarshalErr != nil {
+				return false
+			}
+			if !f(item.delivery()) {
+				return false
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		ws.logger.Error("Error querying webhook deliveries", logging.FieldError, err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// scan reads all deliveries.
+func (ws *WebhookDeliveryStorage) scan() ([]model.WebhookDelivery, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(webhookDeliveriesTableName),
+	}
+
+	deliveries := []model.WebhookDelivery{}
+	var unmarshalErr error
+	err := ws.db.C.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, i := range page.Items {
+			item := webhookDelivery{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(i, &item); unmarshalErr != nil {
+				return false
+			}
+			deliveries = append(deliveries, item.delivery())
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		ws.logger.Error("Error scanning webhook deliveries", logging.FieldError, err)
+		return []model.WebhookDelivery{}, ErrorInternalError
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,160 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// ErrorEmptyFilePath is when file path is empty.
+var ErrorEmptyFilePath = errors.New("empty file path")
+
+// maxAuditLineSize is the max size of one JSON line, the records with long tokens are the largest.
+const maxAuditLineSize = 1024 * 1024
+
+// AuditStorage is an append-only JSON lines audit storage.
+// It is convenient to ship the file to the log collector, but every query reads the whole file.
+type AuditStorage struct {
+	logger *slog.Logger
+	lock   sync.Mutex
+	path   string
+	file   *os.File
+}
+
+// NewAuditStorage creates a JSON lines audit storage.
+func NewAuditStorage(
+	logger *slog.Logger,
+	settings model.FileDatabaseSettings,
+) (model.AuditStorage, error) {
+	if len(settings.Path) == 0 {
+		return nil, ErrorEmptyFilePath
+	}
+
+	if err := os.MkdirAll(filepath.Dir(settings.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create audit log directory: %w", err)
+	}
+
+	f, err := os.OpenFile(settings.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log file: %w", err)
+	}
+	if err := terminateLastLine(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to open audit log file: %w", err)
+	}
+
+	return &AuditStorage{
+		logger: logger,
+		path:   settings.Path,
+		file:   f,
+	}, nil
+}
+
+// terminateLastLine appends the line break if the last line has been truncated by the crash,
+// so the next record is not glued to it.
+func terminateLastLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	r, err := os.Open(f.Name())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	last := make([]byte, 1)
+	if _, err := r.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
+// AddRecord appends the record to the file.
+func (as *AuditStorage) AddRecord(record model.AuditRecord) error {
+	record.ID = xid.New().String()
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	_, err = as.file.Write(data)
+	return err
+}
+
+// FetchRecords returns the records matching the filter, newest first.
+func (as *AuditStorage) FetchRecords(filter model.AuditFilter) ([]model.AuditRecord, int, error) {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	f, err := os.Open(as.path)
+	if err != nil {
+		return []model.AuditRecord{}, 0, err
+	}
+	defer f.Close()
+
+	matched := []model.AuditRecord{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLineSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record model.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// the line could be truncated by the crash, skip it
+			as.logger.Warn("Skipping invalid audit record", logging.FieldError, err)
+			continue
+		}
+		if filter.Matches(record) {
+			matched = append(matched, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return []model.AuditRecord{}, 0, err
+	}
+
+	total := len(matched)
+	result := []model.AuditRecord{}
+	for i := total - 1 - filter.Skip; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		result = append(result, matched[i])
+	}
+	return result, total, nil
+}
+
+// Close closes the file.
+func (as *AuditStorage) Close() {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	if err := as.file.Close(); err != nil {
+		as.logger.Error("Error closing audit log file", logging.FieldError, err)
+	}
+}
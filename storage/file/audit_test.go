@@ -0,0 +1,56 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	storage, err := file.NewAuditStorage(logging.DefaultLogger, model.FileDatabaseSettings{Path: path})
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Hour)
+	require.NoError(t, storage.AddRecord(model.AuditRecord{Time: start, Operation: model.AuditOperationLoginWithPassword, UserID: "user1"}))
+	require.NoError(t, storage.AddRecord(model.AuditRecord{Time: start.Add(time.Minute), Operation: model.AuditOperationLogout, UserID: "user1"}))
+	require.NoError(t, storage.AddRecord(model.AuditRecord{Time: start.Add(2 * time.Minute), Operation: model.AuditOperationLogout, UserID: "user2"}))
+	storage.Close()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// a line truncated by the crash is skipped
+	require.NoError(t, os.WriteFile(path, append(data, []byte(`{"id":"broken`)...), 0o600))
+
+	// records are kept after reopening
+	storage, err = file.NewAuditStorage(logging.DefaultLogger, model.FileDatabaseSettings{Path: path})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	require.NoError(t, storage.AddRecord(model.AuditRecord{Time: start.Add(3 * time.Minute), Operation: model.AuditOperationLogout, UserID: "user3"}))
+
+	result, total, err := storage.FetchRecords(model.AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	require.Len(t, result, 4)
+	assert.Equal(t, "user3", result[0].UserID)
+	assert.Equal(t, "user2", result[1].UserID)
+
+	result, total, err = storage.FetchRecords(model.AuditFilter{Operation: model.AuditOperationLogout, Skip: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, result, 1)
+	assert.Equal(t, "user2", result[0].UserID)
+
+	result, _, err = storage.FetchRecords(model.AuditFilter{To: start.Add(time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, model.AuditOperationLoginWithPassword, result[0].Operation)
+}
@@ -1,8 +1,10 @@
 package fs
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/madappgang/identifo/v2/jwt"
 	"github.com/madappgang/identifo/v2/model"
@@ -56,3 +58,44 @@ func (ks *KeyStorage) LoadPrivateKey() (interface{}, error) {
 	}
 	return privateKey, nil
 }
+
+func (ks *KeyStorage) keyRingPath() string {
+	return filepath.Join(filepath.Dir(ks.PrivateKeyPath), model.KeyRingName)
+}
+
+// LoadKeyRing loads the signing keys ring, which is stored next to the private key.
+func (ks *KeyStorage) LoadKeyRing() (model.KeyRing, error) {
+	var ring model.KeyRing
+
+	data, err := os.ReadFile(ks.keyRingPath())
+	if os.IsNotExist(err) {
+		return ring, model.ErrorNotFound
+	}
+	if err != nil {
+		return ring, fmt.Errorf("cannot read key ring: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &ring); err != nil {
+		return ring, fmt.Errorf("cannot decode key ring: %w", err)
+	}
+	return ring, nil
+}
+
+// SaveKeyRing saves the signing keys ring and replaces the private key with the active one.
+func (ks *KeyStorage) SaveKeyRing(ring model.KeyRing) error {
+	active, ok := ring.Active()
+	if !ok {
+		return fmt.Errorf("key ring has no active key")
+	}
+
+	data, err := json.MarshalIndent(ring, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(ks.keyRingPath(), data, 0o600); err != nil {
+		return fmt.Errorf("%s cannot written: %v", ks.keyRingPath(), err)
+	}
+
+	return ks.ReplaceKey([]byte(active.PrivateKey))
+}
@@ -2,6 +2,7 @@ package shared
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 
 	"github.com/madappgang/identifo/v2/model"
@@ -12,6 +13,10 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// ErrorTFAFactorsNotSupported means the user has more than one second factor or recovery codes,
+// which the plugin protocol could not keep.
+var ErrorTFAFactorsNotSupported = errors.New("plugin user storage keeps a single two-factor authentication factor without recovery codes")
+
 // GRPCClient is an implementation of KV that talks over RPC.
 type GRPCClient struct {
 	Client   proto.UserStorageClient
@@ -118,6 +123,11 @@ func (m GRPCClient) AddUserWithFederatedID(user model.User, provider string, id,
 }
 
 func (m GRPCClient) UpdateUser(userID string, newUser model.User) (model.User, error) {
+	// the factors and recovery codes would be lost, as they are not in the plugin protocol.
+	if tfa := newUser.TFAInfo; len(tfa.Factors) > 1 || len(tfa.RecoveryCodes) > 0 {
+		return model.User{}, ErrorTFAFactorsNotSupported
+	}
+
 	u, err := m.Client.UpdateUser(context.Background(), &proto.UpdateUserRequest{
 		User: toProto(newUser),
 		Id:   userID,
@@ -192,6 +202,11 @@ func (m GRPCClient) UpdateLoginMetadata(operation, app, userID string, scopes []
 	})
 }
 
+// ReplaceRecoveryCodes is not supported, the plugin keeps no recovery codes.
+func (m GRPCClient) ReplaceRecoveryCodes(userID string, old, codes []string) (bool, error) {
+	return false, ErrorTFAFactorsNotSupported
+}
+
 // push device tokens
 func (m GRPCClient) AttachDeviceToken(userID, token string) error {
 	_, err := m.Client.AttachDeviceToken(context.Background(), &proto.AttachDeviceTokenRequest{
@@ -230,6 +245,11 @@ func (m GRPCClient) ImportJSON(data []byte, clearOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports the users of the plugin to JSON, the users are fetched page by page.
+func (m GRPCClient) ExportJSON(w io.Writer) error {
+	return model.ExportUsersByPages(m, w, 100)
+}
+
 func (m GRPCClient) Close() {
 	m.Client.Close(context.Background(), &proto.CloseRequest{})
 	if m.Closable != nil {
@@ -262,6 +282,12 @@ func toModel(u *proto.User) model.User {
 }
 
 func toProto(u model.User) *proto.User {
+	// the plugin protocol has the single factor of the server-wide type, the default factor is passed in it.
+	tfa := u.TFAInfo
+	if f, ok := tfa.Factor(tfa.DefaultFactor); ok {
+		tfa.HOTPCounter, tfa.HOTPExpiredAt, tfa.Secret = f.HOTPCounter, f.HOTPExpiredAt, f.Secret
+	}
+
 	return &proto.User{
 		Id:       u.ID,
 		Username: u.Username,
@@ -271,10 +297,10 @@ func toProto(u model.User) *proto.User {
 		Pswd:     u.Pswd,
 		Active:   u.Active,
 		TfaInfo: &proto.User_TFAInfo{
-			IsEnabled:     u.TFAInfo.IsEnabled,
-			HotpCounter:   int32(u.TFAInfo.HOTPCounter),
-			HotpExpiredAt: timestamppb.New(u.TFAInfo.HOTPExpiredAt),
-			Secret:        u.TFAInfo.Secret,
+			IsEnabled:     tfa.IsEnabled,
+			HotpCounter:   int32(tfa.HOTPCounter),
+			HotpExpiredAt: timestamppb.New(tfa.HOTPExpiredAt),
+			Secret:        tfa.Secret,
 		},
 		NumOfLogins:     int32(u.NumOfLogins),
 		LatestLoginTime: u.LatestLoginTime,
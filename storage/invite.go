@@ -9,6 +9,7 @@ import (
 	"github.com/madappgang/identifo/v2/storage/dynamodb"
 	"github.com/madappgang/identifo/v2/storage/mem"
 	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
 )
 
 // NewInviteStorage creates new invite storage from settings
@@ -22,6 +23,8 @@ func NewInviteStorage(
 		return mongo.NewInviteStorage(logger, settings.Mongo)
 	case model.DBTypeDynamoDB:
 		return dynamodb.NewInviteStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewInviteStorage(logger, settings.Type, settings.SQL)
 	case model.DBTypeFake:
 		fallthrough
 	case model.DBTypeMem:
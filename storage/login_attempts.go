@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/madappgang/identifo/v2/storage/dynamodb"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/redis"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+)
+
+// NewLoginAttemptStorage creates new failed login attempts storage from settings
+func NewLoginAttemptStorage(
+	logger *slog.Logger,
+	settings model.DatabaseSettings) (model.LoginAttemptStorage, error) {
+	switch settings.Type {
+	case model.DBTypeBoltDB:
+		return boltdb.NewLoginAttemptStorage(logger, settings.BoltDB)
+	case model.DBTypeMongoDB:
+		return mongo.NewLoginAttemptStorage(logger, settings.Mongo)
+	case model.DBTypeDynamoDB:
+		return dynamodb.NewLoginAttemptStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewLoginAttemptStorage(logger, settings.Type, settings.SQL)
+	case model.DBTypeRedis:
+		return redis.NewLoginAttemptStorage(settings.Redis)
+	case model.DBTypeFake:
+		fallthrough
+	case model.DBTypeMem:
+		return mem.NewLoginAttemptStorage()
+	default:
+		return nil, fmt.Errorf("login attempt storage type is not supported %s ", settings.Type)
+	}
+}
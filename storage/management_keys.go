@@ -9,6 +9,7 @@ import (
 	"github.com/madappgang/identifo/v2/storage/dynamodb"
 	"github.com/madappgang/identifo/v2/storage/mem"
 	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
 )
 
 // NewManagementKeys creates new management keys storage from settings.
@@ -23,6 +24,8 @@ func NewManagementKeys(
 		return mongo.NewManagementKeysStorage(logger, settings.Mongo)
 	case model.DBTypeDynamoDB:
 		return dynamodb.NewManagementKeysStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewManagementKeysStorage(logger, settings.Type, settings.SQL)
 	case model.DBTypeFake:
 		fallthrough
 	case model.DBTypeMem:
@@ -0,0 +1,112 @@
+package mem
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewAdminAccountStorage creates an in-memory admin account storage.
+func NewAdminAccountStorage() (model.AdminAccountStorage, error) {
+	return &AdminAccountStorage{storage: make(map[string]model.AdminAccount)}, nil
+}
+
+// AdminAccountStorage is an in-memory admin account storage.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type AdminAccountStorage struct {
+	lock    sync.RWMutex
+	storage map[string]model.AdminAccount
+}
+
+// AdminAccounts returns all accounts ordered by login.
+func (as *AdminAccountStorage) AdminAccounts() ([]model.AdminAccount, error) {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	accounts := []model.AdminAccount{}
+	for _, a := range as.storage {
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Login < accounts[j].Login
+	})
+	return accounts, nil
+}
+
+// AdminAccountByID returns the account by its id.
+func (as *AdminAccountStorage) AdminAccountByID(id string) (model.AdminAccount, error) {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	account, ok := as.storage[id]
+	if !ok {
+		return model.AdminAccount{}, model.ErrorNotFound
+	}
+	return account, nil
+}
+
+// AdminAccountByLogin returns the account by its login.
+func (as *AdminAccountStorage) AdminAccountByLogin(login string) (model.AdminAccount, error) {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	for _, a := range as.storage {
+		if a.Login == login {
+			return a, nil
+		}
+	}
+	return model.AdminAccount{}, model.ErrorNotFound
+}
+
+// AddAdminAccount adds new account.
+func (as *AdminAccountStorage) AddAdminAccount(account model.AdminAccount) (model.AdminAccount, error) {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	for _, a := range as.storage {
+		if a.Login == account.Login {
+			return model.AdminAccount{}, model.ErrorAdminAccountExists
+		}
+	}
+	account.ID = xid.New().String()
+	as.storage[account.ID] = account
+	return account, nil
+}
+
+// UpdateAdminAccount updates existing account.
+func (as *AdminAccountStorage) UpdateAdminAccount(account model.AdminAccount) error {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	old, ok := as.storage[account.ID]
+	if !ok {
+		return model.ErrorNotFound
+	}
+	account.Login = old.Login
+	as.storage[account.ID] = account
+	return nil
+}
+
+// DeleteAdminAccount deletes account by its id.
+func (as *AdminAccountStorage) DeleteAdminAccount(id string) error {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	if _, ok := as.storage[id]; !ok {
+		return model.ErrorNotFound
+	}
+	delete(as.storage, id)
+	return nil
+}
+
+// Close clears storage.
+func (as *AdminAccountStorage) Close() {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	for k := range as.storage {
+		delete(as.storage, k)
+	}
+}
@@ -2,6 +2,7 @@ package mem
 
 import (
 	"encoding/json"
+	"io"
 	"log/slog"
 	"strings"
 
@@ -118,6 +119,17 @@ func (as *AppStorage) ImportJSON(data []byte, cleanOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports all apps to JSON.
+func (as *AppStorage) ExportJSON(w io.Writer) error {
+	aw := model.NewJSONArrayWriter(w)
+	for _, app := range as.storage {
+		if err := aw.Write(app); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
 // Close clears storage.
 func (as *AppStorage) Close() {
 	for k := range as.storage {
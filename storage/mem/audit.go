@@ -0,0 +1,68 @@
+package mem
+
+import (
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// maxAuditRecords is a number of the latest records kept by in-memory audit storage.
+const maxAuditRecords = 10000
+
+// NewAuditStorage creates an in-memory audit storage.
+func NewAuditStorage() (model.AuditStorage, error) {
+	return &AuditStorage{}, nil
+}
+
+// AuditStorage is an in-memory audit storage, it keeps only the latest records.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type AuditStorage struct {
+	lock    sync.RWMutex
+	records []model.AuditRecord
+}
+
+// AddRecord appends the record to the log.
+func (as *AuditStorage) AddRecord(record model.AuditRecord) error {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	record.ID = xid.New().String()
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	as.records = append(as.records, record)
+	if len(as.records) > maxAuditRecords {
+		as.records = as.records[len(as.records)-maxAuditRecords:]
+	}
+	return nil
+}
+
+// FetchRecords returns the records matching the filter, newest first.
+func (as *AuditStorage) FetchRecords(filter model.AuditFilter) ([]model.AuditRecord, int, error) {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	result := []model.AuditRecord{}
+	total := 0
+	for i := len(as.records) - 1; i >= 0; i-- {
+		if !filter.Matches(as.records[i]) {
+			continue
+		}
+		total++
+		if total > filter.Skip && (filter.Limit <= 0 || len(result) < filter.Limit) {
+			result = append(result, as.records[i])
+		}
+	}
+	return result, total, nil
+}
+
+// Close clears storage.
+func (as *AuditStorage) Close() {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	as.records = nil
+}
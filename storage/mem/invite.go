@@ -1,6 +1,8 @@
 package mem
 
 import (
+	"encoding/json"
+	"io"
 	"time"
 
 	"github.com/madappgang/identifo/v2/model"
@@ -69,7 +71,7 @@ func (is *InviteStorage) GetAll(withArchived bool, skip, limit int) ([]model.Inv
 		total++
 		skip--
 		if skip > -1 || (limit != 0 && len(invites) == limit) {
-			break
+			continue
 		}
 		invites = append(invites, invite)
 	}
@@ -100,6 +102,36 @@ func (is *InviteStorage) ArchiveByID(id string) error {
 	return nil
 }
 
+// ImportJSON imports invites from JSON.
+func (is *InviteStorage) ImportJSON(data []byte, clearOldData bool) error {
+	if clearOldData {
+		is.storage = make(map[string]model.Invite)
+	}
+
+	invites := []model.Invite{}
+	if err := json.Unmarshal(data, &invites); err != nil {
+		return err
+	}
+	for _, invite := range invites {
+		if len(invite.ID) == 0 {
+			invite.ID = xid.New().String()
+		}
+		is.storage[invite.ID] = invite
+	}
+	return nil
+}
+
+// ExportJSON exports all invites to JSON.
+func (is *InviteStorage) ExportJSON(w io.Writer) error {
+	aw := model.NewJSONArrayWriter(w)
+	for _, invite := range is.storage {
+		if err := aw.Write(invite); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
 // Close clears storage.
 func (is *InviteStorage) Close() {
 	for k := range is.storage {
@@ -0,0 +1,68 @@
+package mem
+
+import (
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// NewLoginAttemptStorage creates an in-memory login attempts storage.
+func NewLoginAttemptStorage() (model.LoginAttemptStorage, error) {
+	return &LoginAttemptStorage{storage: make(map[string]model.LoginAttempts)}, nil
+}
+
+// LoginAttemptStorage is an in-memory login attempts storage.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type LoginAttemptStorage struct {
+	lock    sync.Mutex
+	storage map[string]model.LoginAttempts
+}
+
+// LoginAttempts returns failed login attempts for the key.
+func (ls *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	return ls.get(key, time.Now()), nil
+}
+
+// AddFailedAttempt increments failed login attempts for the key.
+func (ls *LoginAttemptStorage) AddFailedAttempt(key string, expiresIn time.Duration) (model.LoginAttempts, error) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	now := time.Now()
+	attempts := ls.get(key, now)
+	attempts.Failures++
+	attempts.LastFailedAt = now
+	attempts.ExpiresAt = now.Add(expiresIn)
+	ls.storage[key] = attempts
+	return attempts, nil
+}
+
+// ResetLoginAttempts deletes failed login attempts for the key.
+func (ls *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	delete(ls.storage, key)
+	return nil
+}
+
+func (ls *LoginAttemptStorage) get(key string, now time.Time) model.LoginAttempts {
+	attempts, ok := ls.storage[key]
+	if !ok || !attempts.ExpiresAt.After(now) {
+		delete(ls.storage, key)
+		return model.LoginAttempts{Key: key}
+	}
+	return attempts
+}
+
+// Close clears storage.
+func (ls *LoginAttemptStorage) Close() {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	ls.storage = make(map[string]model.LoginAttempts)
+}
@@ -2,7 +2,9 @@ package mem
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -91,5 +93,29 @@ func (ms *ManagementKeysStorage) GeyAllKeys(ctx context.Context) ([]model.Manage
 }
 
 func (ms *ManagementKeysStorage) ImportJSON(data []byte, cleanOldData bool) error {
-	return errors.New("not implemented")
+	if cleanOldData {
+		ms.storage = make(map[string]model.ManagementKey)
+	}
+
+	keys := []model.ManagementKey{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if len(k.ID) == 0 {
+			k.ID = uuid.New().String()
+		}
+		ms.storage[k.ID] = k
+	}
+	return nil
+}
+
+func (ms *ManagementKeysStorage) ExportJSON(w io.Writer) error {
+	aw := model.NewJSONArrayWriter(w)
+	for _, k := range ms.storage {
+		if err := aw.Write(k); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
 }
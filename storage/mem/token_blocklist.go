@@ -1,34 +1,63 @@
 package mem
 
 import (
+	"sync"
+	"time"
+
 	"github.com/madappgang/identifo/v2/model"
 )
 
+// blacklistSweepInterval is how often the expired tokens are removed from the blacklist.
+const blacklistSweepInterval = time.Minute
+
 // NewTokenBlacklist creates an in-memory token storage.
 func NewTokenBlacklist() (model.TokenBlacklist, error) {
-	return &TokenBlacklist{storage: make(map[string]bool)}, nil
+	return &TokenBlacklist{storage: make(map[string]time.Time)}, nil
 }
 
 // TokenBlacklist is an in-memory token storage.
 // Please do not use it in production, it has no disk swap or persistent cache support.
 type TokenBlacklist struct {
-	storage map[string]bool
+	lock      sync.Mutex
+	storage   map[string]time.Time
+	lastSweep time.Time
 }
 
-// Add blacklists token.
-func (tb *TokenBlacklist) Add(token string) error {
-	tb.storage[token] = true
+// Add blacklists token until it expires, the expired tokens are removed on the way.
+func (tb *TokenBlacklist) Add(id string, expiresAt time.Time) error {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	if time.Since(tb.lastSweep) > blacklistSweepInterval {
+		for k, exp := range tb.storage {
+			if model.IsBlacklistEntryExpired(exp) {
+				delete(tb.storage, k)
+			}
+		}
+		tb.lastSweep = time.Now()
+	}
+
+	if model.IsBlacklistEntryExpired(expiresAt) {
+		return nil
+	}
+	tb.storage[id] = expiresAt
 	return nil
 }
 
 // IsBlacklisted returns true if the token is blacklisted.
-func (tb *TokenBlacklist) IsBlacklisted(token string) bool {
-	has := tb.storage[token]
-	return has
+func (tb *TokenBlacklist) IsBlacklisted(id string) bool {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	exp, has := tb.storage[id]
+	return has && !model.IsBlacklistEntryExpired(exp)
 }
 
 // Close clears storage.
 func (tb *TokenBlacklist) Close() {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
 	for k := range tb.storage {
 		delete(tb.storage, k)
 	}
@@ -2,11 +2,12 @@ package mem
 
 import (
 	"encoding/json"
+	"io"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/madappgang/identifo/v2/model"
-	"github.com/pallinder/go-randomdata"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -116,7 +117,7 @@ func (us *UserStorage) AddUserWithPassword(user model.User, password, role strin
 	user.Active = true
 	user.AccessRole = role
 	user.Anonymous = isAnonymous
-	user.ID = randomdata.StringNumber(2, "-")
+	user.ID = primitive.NewObjectID().Hex()
 
 	return us.AddNewUser(user, password)
 }
@@ -125,7 +126,9 @@ func (us *UserStorage) AddUserWithPassword(user model.User, password, role strin
 func (us *UserStorage) AddNewUser(user model.User, password string) (model.User, error) {
 	user.Email = strings.ToLower(user.Email)
 
-	user.ID = primitive.NewObjectID().Hex()
+	if len(user.ID) == 0 {
+		user.ID = primitive.NewObjectID().Hex()
+	}
 	if len(password) > 0 {
 		user.Pswd = model.PasswordHash(password)
 	}
@@ -228,10 +231,31 @@ func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes
 	}
 }
 
-// FetchUsers returns randomly generated user enclosed in slice.
+// ReplaceRecoveryCodes replaces the recovery codes of the user if they are still the old ones.
+func (us *UserStorage) ReplaceRecoveryCodes(userID string, old, codes []string) (bool, error) {
+	for i, u := range us.users {
+		if strings.EqualFold(userID, u.ID) {
+			if !slices.Equal(u.TFAInfo.RecoveryCodes, old) {
+				return false, nil
+			}
+			us.users[i].TFAInfo.RecoveryCodes = codes
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FetchUsers returns users page, the filter is ignored.
 func (us *UserStorage) FetchUsers(filterString string, skip, limit int) ([]model.User, int, error) {
-	// no skip, no filters
-	return us.users, len(us.users), nil
+	total := len(us.users)
+	if skip >= total {
+		return []model.User{}, total, nil
+	}
+	end := total
+	if limit > 0 && skip+limit < total {
+		end = skip + limit
+	}
+	return us.users[skip:end], total, nil
 }
 
 // ImportJSON imports data from JSON.
@@ -246,6 +270,10 @@ func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
 	for _, u := range ud {
 		pswd := u.Pswd
 		u.Pswd = ""
+		// the exported users have the password hash, which is kept as is.
+		if model.IsPasswordHash(pswd) {
+			u.Pswd, pswd = pswd, ""
+		}
 		if _, err := us.AddNewUser(u, pswd); err != nil {
 			return err
 		}
@@ -253,5 +281,16 @@ func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports all users to JSON.
+func (us *UserStorage) ExportJSON(w io.Writer) error {
+	aw := model.NewJSONArrayWriter(w)
+	for _, u := range us.users {
+		if err := aw.Write(u); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
 // Close does nothing here.
 func (us *UserStorage) Close() {}
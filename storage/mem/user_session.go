@@ -0,0 +1,129 @@
+package mem
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewUserSessionStorage creates an in-memory user session storage.
+func NewUserSessionStorage() (model.UserSessionStorage, error) {
+	return &UserSessionStorage{sessions: make(map[string]model.UserSession)}, nil
+}
+
+// UserSessionStorage is an in-memory user session storage.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type UserSessionStorage struct {
+	lock     sync.RWMutex
+	sessions map[string]model.UserSession
+}
+
+// AddSession saves the new session.
+func (us *UserSessionStorage) AddSession(session model.UserSession) (model.UserSession, error) {
+	us.lock.Lock()
+	defer us.lock.Unlock()
+
+	if len(session.ID) == 0 {
+		session.ID = xid.New().String()
+	}
+	us.sessions[session.ID] = session
+	return session, nil
+}
+
+// UpdateSession replaces the session.
+func (us *UserSessionStorage) UpdateSession(session model.UserSession) error {
+	us.lock.Lock()
+	defer us.lock.Unlock()
+
+	if _, ok := us.sessions[session.ID]; !ok {
+		return model.ErrorNotFound
+	}
+	us.sessions[session.ID] = session
+	return nil
+}
+
+// SessionByID returns the session by its ID.
+func (us *UserSessionStorage) SessionByID(id string) (model.UserSession, error) {
+	us.lock.RLock()
+	defer us.lock.RUnlock()
+
+	s, ok := us.sessions[id]
+	if !ok || !s.ExpiresAt.After(time.Now()) {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	return s, nil
+}
+
+// SessionByRefreshTokenID returns the session by the jti of its refresh token.
+func (us *UserSessionStorage) SessionByRefreshTokenID(tokenID string) (model.UserSession, error) {
+	us.lock.RLock()
+	defer us.lock.RUnlock()
+
+	now := time.Now()
+	for _, s := range us.sessions {
+		if s.RefreshTokenID == tokenID && s.ExpiresAt.After(now) {
+			return s, nil
+		}
+	}
+	return model.UserSession{}, model.ErrorNotFound
+}
+
+// UserSessions returns the sessions of the user, the latest refreshed first.
+func (us *UserSessionStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	us.lock.Lock()
+	defer us.lock.Unlock()
+
+	now := time.Now()
+	result := []model.UserSession{}
+	for id, s := range us.sessions {
+		if !s.ExpiresAt.After(now) {
+			delete(us.sessions, id)
+			continue
+		}
+		if s.UserID == userID {
+			result = append(result, s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastRefreshAt.After(result[j].LastRefreshAt)
+	})
+	return result, nil
+}
+
+// DeleteSession deletes the session.
+func (us *UserSessionStorage) DeleteSession(id string) error {
+	us.lock.Lock()
+	defer us.lock.Unlock()
+
+	if _, ok := us.sessions[id]; !ok {
+		return model.ErrorNotFound
+	}
+	delete(us.sessions, id)
+	return nil
+}
+
+// DeleteUserSessions deletes all sessions of the user.
+func (us *UserSessionStorage) DeleteUserSessions(userID string) (int, error) {
+	us.lock.Lock()
+	defer us.lock.Unlock()
+
+	deleted := 0
+	for id, s := range us.sessions {
+		if s.UserID == userID {
+			delete(us.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Close clears storage.
+func (us *UserSessionStorage) Close() {
+	us.lock.Lock()
+	defer us.lock.Unlock()
+
+	us.sessions = make(map[string]model.UserSession)
+}
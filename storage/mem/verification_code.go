@@ -1,25 +1,61 @@
 package mem
 
 import (
+	"sync"
+	"time"
+
 	"github.com/madappgang/identifo/v2/model"
 )
 
 // NewVerificationCodeStorage creates and inits in-memory verification code storage.
 func NewVerificationCodeStorage() (model.VerificationCodeStorage, error) {
-	return &VerificationCodeStorage{}, nil
+	return &VerificationCodeStorage{codes: map[string]model.VerificationCode{}}, nil
 }
 
 // VerificationCodeStorage implements verification code storage interface.
-type VerificationCodeStorage struct{}
+type VerificationCodeStorage struct {
+	mu    sync.Mutex
+	codes map[string]model.VerificationCode
+}
+
+// CreateVerificationCode saves the new code of the phone, replacing the previous one.
+func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string, policy model.VerificationCodePolicy) (model.VerificationCodeStatus, error) {
+	vcs.mu.Lock()
+	defer vcs.mu.Unlock()
 
-// IsVerificationCodeFound is always optimistic.
-func (vcs *VerificationCodeStorage) IsVerificationCodeFound(phone, code string) (bool, error) {
-	return true, nil
+	now := time.Now()
+	if vc, ok := vcs.codes[phone]; ok && now.Before(vc.ResendAt) {
+		return vc.Status(), model.ErrorVerificationCodeCooldown
+	}
+
+	vc := model.NewVerificationCode(phone, code, policy, now)
+	vcs.codes[phone] = vc
+	return vc.Status(), nil
 }
 
-// CreateVerificationCode is always optimistic.
-func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string) error {
-	return nil
+// VerifyCode checks the code of the phone, the matching code is deleted.
+func (vcs *VerificationCodeStorage) VerifyCode(phone, code string) (model.VerificationCodeStatus, error) {
+	vcs.mu.Lock()
+	defer vcs.mu.Unlock()
+
+	now := time.Now()
+	vc, ok := vcs.codes[phone]
+	if !ok {
+		return model.VerificationCodeStatus{}, model.ErrorVerificationCodeExpired
+	}
+	if !now.Before(vc.DeleteAt()) {
+		delete(vcs.codes, phone)
+		return model.VerificationCodeStatus{}, model.ErrorVerificationCodeExpired
+	}
+
+	vc, err := vc.Check(code, now)
+	switch err {
+	case nil:
+		delete(vcs.codes, phone)
+	case model.ErrorVerificationCodeInvalid:
+		vcs.codes[phone] = vc
+	}
+	return vc.Status(), err
 }
 
 // Close does nothing here.
@@ -0,0 +1,129 @@
+package mem
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// NewWebAuthnStorage creates an in-memory WebAuthn credentials storage.
+func NewWebAuthnStorage() (model.WebAuthnStorage, error) {
+	return &WebAuthnStorage{
+		storage:  make(map[string]model.WebAuthnCredential),
+		sessions: make(map[string]model.WebAuthnSession),
+	}, nil
+}
+
+// WebAuthnStorage is an in-memory WebAuthn credentials storage.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type WebAuthnStorage struct {
+	lock     sync.RWMutex
+	storage  map[string]model.WebAuthnCredential
+	sessions map[string]model.WebAuthnSession
+}
+
+// AddCredential adds new credential.
+func (ws *WebAuthnStorage) AddCredential(credential model.WebAuthnCredential) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if _, ok := ws.storage[credential.ID]; ok {
+		return model.ErrorWrongDataFormat
+	}
+	ws.storage[credential.ID] = credential
+	return nil
+}
+
+// CredentialByID returns credential by its id.
+func (ws *WebAuthnStorage) CredentialByID(id string) (model.WebAuthnCredential, error) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+
+	credential, ok := ws.storage[id]
+	if !ok {
+		return model.WebAuthnCredential{}, model.ErrorNotFound
+	}
+	return credential, nil
+}
+
+// CredentialsByUserID returns all credentials of the user, ordered by creation time.
+func (ws *WebAuthnStorage) CredentialsByUserID(userID string) ([]model.WebAuthnCredential, error) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+
+	credentials := []model.WebAuthnCredential{}
+	for _, c := range ws.storage {
+		if c.UserID == userID {
+			credentials = append(credentials, c)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, nil
+}
+
+// UpdateCredential updates existing credential.
+func (ws *WebAuthnStorage) UpdateCredential(credential model.WebAuthnCredential) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if _, ok := ws.storage[credential.ID]; !ok {
+		return model.ErrorNotFound
+	}
+	ws.storage[credential.ID] = credential
+	return nil
+}
+
+// DeleteCredential deletes credential by its id.
+func (ws *WebAuthnStorage) DeleteCredential(id string) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if _, ok := ws.storage[id]; !ok {
+		return model.ErrorNotFound
+	}
+	delete(ws.storage, id)
+	return nil
+}
+
+// SaveSession saves the session of the ceremony.
+func (ws *WebAuthnStorage) SaveSession(session model.WebAuthnSession) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	ws.sessions[session.ID] = session
+	return nil
+}
+
+// TakeSession returns and deletes the session.
+func (ws *WebAuthnStorage) TakeSession(id string) (model.WebAuthnSession, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	session, ok := ws.sessions[id]
+	if !ok {
+		return model.WebAuthnSession{}, model.ErrorNotFound
+	}
+	delete(ws.sessions, id)
+
+	if !session.ExpiresAt.After(time.Now()) {
+		return model.WebAuthnSession{}, model.ErrorNotFound
+	}
+	return session, nil
+}
+
+// Close clears storage.
+func (ws *WebAuthnStorage) Close() {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	for k := range ws.storage {
+		delete(ws.storage, k)
+	}
+	for k := range ws.sessions {
+		delete(ws.sessions, k)
+	}
+}
@@ -0,0 +1,134 @@
+package mem
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewWebhookDeliveryStorage creates an in-memory webhook delivery storage.
+func NewWebhookDeliveryStorage() (model.WebhookDeliveryStorage, error) {
+	return &WebhookDeliveryStorage{deliveries: make(map[string]model.WebhookDelivery)}, nil
+}
+
+// WebhookDeliveryStorage is an in-memory webhook delivery storage.
+// Please do not use it in production, the queue is lost on restart.
+type WebhookDeliveryStorage struct {
+	lock       sync.RWMutex
+	deliveries map[string]model.WebhookDelivery
+}
+
+// AddDelivery queues the delivery.
+func (ws *WebhookDeliveryStorage) AddDelivery(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	delivery.ID = xid.New().String()
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+	ws.deliveries[delivery.ID] = delivery
+	return delivery, nil
+}
+
+// ClaimDueDeliveries returns pending deliveries due at now and postpones them for the lease duration.
+func (ws *WebhookDeliveryStorage) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	due := []model.WebhookDelivery{}
+	for _, d := range ws.deliveries {
+		if d.Status == model.WebhookDeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i := range due {
+		claimed := due[i]
+		claimed.NextAttemptAt = now.Add(lease)
+		ws.deliveries[claimed.ID] = claimed
+	}
+	return due, nil
+}
+
+// UpdateDelivery replaces the delivery.
+func (ws *WebhookDeliveryStorage) UpdateDelivery(delivery model.WebhookDelivery) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if _, ok := ws.deliveries[delivery.ID]; !ok {
+		return model.ErrorNotFound
+	}
+	ws.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+// DeliveryByID returns the delivery by its ID.
+func (ws *WebhookDeliveryStorage) DeliveryByID(id string) (model.WebhookDelivery, error) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+
+	d, ok := ws.deliveries[id]
+	if !ok {
+		return model.WebhookDelivery{}, model.ErrorNotFound
+	}
+	return d, nil
+}
+
+// FetchDeliveries returns deliveries with the status, newest first.
+func (ws *WebhookDeliveryStorage) FetchDeliveries(status model.WebhookDeliveryStatus, skip, limit int) ([]model.WebhookDelivery, int, error) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+
+	matched := []model.WebhookDelivery{}
+	for _, d := range ws.deliveries {
+		if len(status) == 0 || d.Status == status {
+			matched = append(matched, d)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].ID > matched[j].ID
+	})
+
+	total := len(matched)
+	if skip >= total {
+		return []model.WebhookDelivery{}, total, nil
+	}
+	matched = matched[skip:]
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, total, nil
+}
+
+// DeleteFinishedDeliveries deletes the delivered and dead deliveries finished before the time.
+func (ws *WebhookDeliveryStorage) DeleteFinishedDeliveries(before time.Time) (int, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	deleted := 0
+	for id, d := range ws.deliveries {
+		if d.FinishedBefore(before) {
+			delete(ws.deliveries, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Close clears storage.
+func (ws *WebhookDeliveryStorage) Close() {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	ws.deliveries = make(map[string]model.WebhookDelivery)
+}
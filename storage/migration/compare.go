@@ -0,0 +1,93 @@
+package migration
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// sameJSON compares the records by their JSON, as the storages could return nil or empty slices and maps.
+func sameJSON(a, b any) bool {
+	ja, erra := json.Marshal(a)
+	jb, errb := json.Marshal(b)
+	return erra == nil && errb == nil && bytes.Equal(normalizeJSON(ja), normalizeJSON(jb))
+}
+
+// normalizeJSON drops the empty fields of the JSON object.
+func normalizeJSON(data []byte) []byte {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return data
+	}
+	for k, v := range m {
+		switch vv := v.(type) {
+		case nil:
+			delete(m, k)
+		case []any:
+			if len(vv) == 0 {
+				delete(m, k)
+			}
+		case map[string]any:
+			if len(vv) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+	res, err := json.Marshal(m)
+	if err != nil {
+		return data
+	}
+	return res
+}
+
+func jsonArray(v any) ([]byte, error) {
+	return json.Marshal([]any{v})
+}
+
+// sameKey compares the management keys, the times are compared with the millisecond precision of the databases.
+func sameKey(a, b model.ManagementKey) bool {
+	return a.ID == b.ID &&
+		a.Secret == b.Secret &&
+		a.Name == b.Name &&
+		a.Active == b.Active &&
+		slices.Equal(a.Scopes, b.Scopes) &&
+		a.CreatedAt.UnixMilli() == b.CreatedAt.UnixMilli() &&
+		a.LastUsed.UnixMilli() == b.LastUsed.UnixMilli() &&
+		sameTimePtr(a, b)
+}
+
+func sameTimePtr(a, b model.ManagementKey) bool {
+	if a.ValidTill == nil || b.ValidTill == nil {
+		return a.ValidTill == b.ValidTill
+	}
+	return a.ValidTill.UnixMilli() == b.ValidTill.UnixMilli()
+}
+
+// sameUser compares the user fields which are needed to log in: the identifiers, the password hash,
+// the 2FA settings, the federated IDs and the access settings. Usernames and emails are compared case insensitively,
+// as some storages keep them in lower case. The login statistics are not compared.
+func sameUser(a, b model.User) bool {
+	return a.ID == b.ID &&
+		strings.EqualFold(a.Username, b.Username) &&
+		strings.EqualFold(a.Email, b.Email) &&
+		a.Phone == b.Phone &&
+		a.Pswd == b.Pswd &&
+		sameJSON(a.TFAInfo, b.TFAInfo) &&
+		sameSet(a.FederatedIDs, b.FederatedIDs) &&
+		sameSet(a.Scopes, b.Scopes) &&
+		a.Active == b.Active &&
+		a.AccessRole == b.AccessRole &&
+		a.Anonymous == b.Anonymous &&
+		a.EmailVerified == b.EmailVerified &&
+		a.PhoneVerified == b.PhoneVerified
+}
+
+func sameSet(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,339 @@
+// Package migration copies apps, management keys, users and invites from one storage backend to another.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// DefaultBatchSize is the number of the records read from the source at once.
+const DefaultBatchSize = 100
+
+// ErrorNoPagination means the source storage ignores the page size, so the records could not be read in batches.
+var ErrorNoPagination = errors.New("the source storage does not support pagination")
+
+// Options are the options of the migration.
+type Options struct {
+	BatchSize int
+	// StatePath is the file to keep the progress, the migration continues from it if the file exists.
+	// The progress is not saved if the path is empty.
+	StatePath string
+	Logger    *slog.Logger
+}
+
+type migrator struct {
+	from, to Storages
+	opts     Options
+	logger   *slog.Logger
+	state    State
+	report   Report
+}
+
+// Migrate copies the records from one storage to another in batches and verifies the result.
+// The records which already exist in the destination are compared and overwritten if they differ,
+// so the migration could be safely repeated. The returned error means the migration has been interrupted,
+// it could be continued with the same state file.
+func Migrate(ctx context.Context, from, to Storages, opts Options) (Report, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Logger == nil {
+		opts.Logger = logging.DefaultLogger
+	}
+
+	state, err := LoadState(opts.StatePath)
+	if err != nil {
+		return Report{}, fmt.Errorf("unable to load migration state: %w", err)
+	}
+
+	m := &migrator{
+		from:   from,
+		to:     to,
+		opts:   opts,
+		logger: opts.Logger,
+		state:  state,
+	}
+	m.report.Resumed = state != State{}
+
+	if !state.Completed {
+		steps := []func(context.Context) error{m.apps, m.managementKeys, m.users, m.invites}
+		for _, step := range steps {
+			if err := step(ctx); err != nil {
+				return m.report, err
+			}
+		}
+		m.state.Completed = true
+		if err := SaveState(opts.StatePath, m.state); err != nil {
+			return m.report, fmt.Errorf("unable to save migration state: %w", err)
+		}
+	}
+
+	if err := verify(ctx, from, to, opts.BatchSize, &m.report); err != nil {
+		return m.report, err
+	}
+	return m.report, nil
+}
+
+func (m *migrator) saveBatch(kind string, processed int) error {
+	m.logger.Info("Migrated batch", "kind", kind, "processed", processed)
+	if err := SaveState(m.opts.StatePath, m.state); err != nil {
+		return fmt.Errorf("unable to save migration state: %w", err)
+	}
+	return nil
+}
+
+func (m *migrator) apps(ctx context.Context) error {
+	apps, err := m.from.App.FetchApps("")
+	if err != nil {
+		return fmt.Errorf("unable to read apps: %w", err)
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].ID < apps[j].ID })
+
+	for m.state.Apps < len(apps) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(m.state.Apps+m.opts.BatchSize, len(apps))
+		for _, app := range apps[m.state.Apps:end] {
+			m.writeApp(app)
+		}
+		m.report.Apps.Read += end - m.state.Apps
+		m.state.Apps = end
+		if err := m.saveBatch("apps", m.state.Apps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *migrator) writeApp(app model.AppData) {
+	r := &m.report.Apps
+	existing, err := m.to.App.AppByID(app.ID)
+	if err == nil {
+		if sameJSON(existing, app) {
+			r.Unchanged++
+			return
+		}
+		if _, err := m.to.App.UpdateApp(app.ID, app); err != nil {
+			r.fail(app.ID, err)
+			return
+		}
+		r.Updated++
+		return
+	}
+
+	// not all app storages return model.ErrorNotFound, so any error means the app is missing.
+	if _, err := m.to.App.CreateApp(app); err != nil {
+		r.fail(app.ID, err)
+		return
+	}
+	r.Created++
+}
+
+func (m *migrator) managementKeys(ctx context.Context) error {
+	keys, err := m.from.ManagementKeys.GeyAllKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to read management keys: %w", err)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	for m.state.ManagementKeys < len(keys) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(m.state.ManagementKeys+m.opts.BatchSize, len(keys))
+		for _, key := range keys[m.state.ManagementKeys:end] {
+			m.writeKey(ctx, key)
+		}
+		m.report.ManagementKeys.Read += end - m.state.ManagementKeys
+		m.state.ManagementKeys = end
+		if err := m.saveBatch("management keys", m.state.ManagementKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *migrator) writeKey(ctx context.Context, key model.ManagementKey) {
+	r := &m.report.ManagementKeys
+	existing, err := m.to.ManagementKeys.GetKey(ctx, key.ID)
+	if err == nil && sameKey(existing, key) {
+		r.Unchanged++
+		return
+	}
+
+	// the storage interface has no method to add the key as is, import it instead.
+	data, jerr := jsonArray(key)
+	if jerr == nil {
+		jerr = m.to.ManagementKeys.ImportJSON(data, false)
+	}
+	switch {
+	case jerr != nil:
+		r.fail(key.ID, jerr)
+	case err == nil:
+		r.Updated++
+	default:
+		r.Created++
+	}
+}
+
+func (m *migrator) users(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		users, _, err := m.from.User.FetchUsers("", m.state.Users, m.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("unable to read users: %w", err)
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		if len(users) > m.opts.BatchSize {
+			return ErrorNoPagination
+		}
+
+		for _, u := range users {
+			m.writeUser(u)
+		}
+		m.report.Users.Read += len(users)
+		m.state.Users += len(users)
+		if err := m.saveBatch("users", m.state.Users); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *migrator) writeUser(u model.User) {
+	r := &m.report.Users
+	existing, err := m.to.User.UserByID(u.ID)
+	if err == nil {
+		if sameUser(existing, u) {
+			r.Unchanged++
+			return
+		}
+		if _, err := m.to.User.UpdateUser(u.ID, u); err != nil {
+			r.fail(u.ID, err)
+			return
+		}
+		r.Updated++
+		return
+	}
+	if !errors.Is(err, model.ErrUserNotFound) {
+		r.fail(u.ID, err)
+		return
+	}
+
+	// the storage interface has no method to add the user as is, import it instead.
+	// The imported password is hashed by the storage, so the hash is set by the update.
+	imported := u
+	imported.Pswd = ""
+	data, err := jsonArray(imported)
+	if err == nil {
+		err = m.to.User.ImportJSON(data, false)
+	}
+	if err != nil {
+		r.fail(u.ID, err)
+		return
+	}
+	if _, err := m.to.User.UserByID(u.ID); err != nil {
+		r.fail(u.ID, fmt.Errorf("the user is not found by ID after import, the destination storage could have changed the ID: %w", err))
+		return
+	}
+	if len(u.Pswd) > 0 {
+		if _, err := m.to.User.UpdateUser(u.ID, u); err != nil {
+			r.fail(u.ID, err)
+			return
+		}
+	}
+	r.Created++
+}
+
+func (m *migrator) invites(ctx context.Context) error {
+	existing, err := activeInvites(m.to.Invite, m.opts.BatchSize)
+	if err != nil {
+		return fmt.Errorf("unable to read destination invites: %w", err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// archived invites are read as well, to keep the offsets stable.
+		invites, _, err := m.from.Invite.GetAll(true, m.state.Invites, m.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("unable to read invites: %w", err)
+		}
+		if len(invites) == 0 {
+			return nil
+		}
+		if len(invites) > m.opts.BatchSize {
+			return ErrorNoPagination
+		}
+
+		for _, invite := range invites {
+			m.writeInvite(invite, existing)
+		}
+		m.report.Invites.Read += len(invites)
+		m.state.Invites += len(invites)
+		if err := m.saveBatch("invites", m.state.Invites); err != nil {
+			return err
+		}
+	}
+}
+
+// writeInvite saves the active invite, the archived and expired invites could not be used and are skipped.
+// The destination storage assigns the new ID to the invite, so the invites are matched by the token.
+func (m *migrator) writeInvite(invite model.Invite, existing map[string]model.Invite) {
+	r := &m.report.Invites
+	if !isActiveInvite(invite) {
+		r.Skipped++
+		return
+	}
+	if _, ok := existing[invite.Token]; ok {
+		r.Unchanged++
+		return
+	}
+
+	err := m.to.Invite.Save(invite.Email, invite.Token, invite.Role, invite.AppID, invite.CreatedBy, invite.ExpiresAt)
+	if err != nil {
+		r.fail(invite.ID, err)
+		return
+	}
+	existing[invite.Token] = invite
+	r.Created++
+}
+
+func isActiveInvite(invite model.Invite) bool {
+	return !invite.Archived && invite.ExpiresAt.After(time.Now())
+}
+
+// activeInvites returns the active invites of the storage by the token.
+func activeInvites(s model.InviteStorage, batch int) (map[string]model.Invite, error) {
+	res := map[string]model.Invite{}
+	for skip := 0; ; {
+		invites, _, err := s.GetAll(false, skip, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(invites) == 0 {
+			return res, nil
+		}
+		if len(invites) > batch {
+			return nil, ErrorNoPagination
+		}
+		for _, invite := range invites {
+			if isActiveInvite(invite) {
+				res[invite.Token] = invite
+			}
+		}
+		skip += len(invites)
+	}
+}
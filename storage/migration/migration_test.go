@@ -0,0 +1,208 @@
+package migration_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openStorages(t *testing.T, settings model.DatabaseSettings) migration.Storages {
+	t.Helper()
+	s, err := migration.OpenStorages(logging.DefaultLogger, settings)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func boltStorages(t *testing.T) migration.Storages {
+	return openStorages(t, model.DatabaseSettings{
+		Type:   model.DBTypeBoltDB,
+		BoltDB: model.BoltDBDatabaseSettings{Path: filepath.Join(t.TempDir(), "identifo.db")},
+	})
+}
+
+func sqliteStorages(t *testing.T) migration.Storages {
+	return openStorages(t, model.DatabaseSettings{
+		Type: model.DBTypeSQLite,
+		SQL:  model.SQLDatabaseSettings{ConnectionString: filepath.Join(t.TempDir(), "identifo.db")},
+	})
+}
+
+func fillSource(t *testing.T, s migration.Storages) []model.User {
+	t.Helper()
+
+	for i := 0; i < 3; i++ {
+		_, err := s.App.CreateApp(model.AppData{
+			ID:     fmt.Sprintf("app%d", i),
+			Name:   fmt.Sprintf("App %d", i),
+			Active: true,
+			Type:   model.Web,
+		})
+		require.NoError(t, err)
+	}
+
+	validTill := time.Now().Add(time.Hour).UTC()
+	keys := fmt.Sprintf(`[{"id":"key1","secret":"secret1","name":"Key 1","active":true,"scopes":["user:read"],"valid_till":%q}]`,
+		validTill.Format(time.RFC3339Nano))
+	require.NoError(t, s.ManagementKeys.ImportJSON([]byte(keys), false))
+
+	var users []model.User
+	for i := 0; i < 5; i++ {
+		u, err := s.User.AddUserWithPassword(model.User{
+			Username: fmt.Sprintf("user%d", i),
+			Email:    fmt.Sprintf("user%d@example.com", i),
+		}, "password", "user", false)
+		require.NoError(t, err)
+		users = append(users, u)
+	}
+
+	fed, err := s.User.AddUserWithFederatedID(model.User{Username: "federated"}, "google", "g-1", "user")
+	require.NoError(t, err)
+	users = append(users, fed)
+
+	// the password hash and TFA secret should be moved as is.
+	tfa := users[0]
+	tfa.TFAInfo = model.TFAInfo{IsEnabled: true, Secret: "tfa-secret"}
+	_, err = s.User.UpdateUser(tfa.ID, tfa)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Invite.Save("invite@example.com", "active-token", "user", "app0", "admin", time.Now().Add(time.Hour)))
+	require.NoError(t, s.Invite.Save("expired@example.com", "expired-token", "user", "app0", "admin", time.Now().Add(-time.Hour)))
+
+	return users
+}
+
+func TestMigrate(t *testing.T) {
+	from := boltStorages(t)
+	to := sqliteStorages(t)
+	users := fillSource(t, from)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	report, err := migration.Migrate(context.Background(), from, to, migration.Options{BatchSize: 2, StatePath: statePath})
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+
+	assert.Equal(t, 3, report.Apps.Created)
+	assert.Equal(t, 1, report.ManagementKeys.Created)
+	assert.Equal(t, len(users), report.Users.Created)
+	assert.Equal(t, 1, report.Invites.Created)
+	assert.Equal(t, 1, report.Invites.Skipped)
+	assert.Equal(t, len(users), report.Users.Verification.Matched)
+
+	// the migrated user could log in with the old password.
+	require.NoError(t, to.User.CheckPassword(users[1].ID, "password"))
+	migrated, err := to.User.UserByID(users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "tfa-secret", migrated.TFAInfo.Secret)
+	_, err = to.User.UserByFederatedID("google", "g-1")
+	require.NoError(t, err)
+
+	state, err := migration.LoadState(statePath)
+	require.NoError(t, err)
+	assert.True(t, state.Completed)
+
+	// the completed migration only verifies the data.
+	report, err = migration.Migrate(context.Background(), from, to, migration.Options{BatchSize: 2, StatePath: statePath})
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.True(t, report.Resumed)
+	assert.Zero(t, report.Users.Read)
+	assert.Equal(t, len(users), report.Users.Verification.Checked)
+}
+
+// interruptedUsers cancels the migration after the first batch of the users has been read.
+type interruptedUsers struct {
+	model.UserStorage
+	cancel context.CancelFunc
+}
+
+func (s interruptedUsers) FetchUsers(search string, skip, limit int) ([]model.User, int, error) {
+	s.cancel()
+	return s.UserStorage.FetchUsers(search, skip, limit)
+}
+
+func TestMigrateResume(t *testing.T) {
+	from := boltStorages(t)
+	to := sqliteStorages(t)
+	users := fillSource(t, from)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := from
+	interrupted.User = interruptedUsers{UserStorage: from.User, cancel: cancel}
+
+	_, err := migration.Migrate(ctx, interrupted, to, migration.Options{BatchSize: 2, StatePath: statePath})
+	require.ErrorIs(t, err, context.Canceled)
+
+	state, err := migration.LoadState(statePath)
+	require.NoError(t, err)
+	assert.Equal(t, 3, state.Apps)
+	assert.Equal(t, 2, state.Users)
+	assert.False(t, state.Completed)
+
+	report, err := migration.Migrate(context.Background(), from, to, migration.Options{BatchSize: 2, StatePath: statePath})
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+	assert.True(t, report.Resumed)
+	assert.Zero(t, report.Apps.Read)
+	assert.Equal(t, len(users)-2, report.Users.Created)
+	assert.Equal(t, len(users), report.Users.Verification.Matched)
+}
+
+func TestMigrateRepeated(t *testing.T) {
+	from := boltStorages(t)
+	to := sqliteStorages(t)
+	users := fillSource(t, from)
+
+	_, err := migration.Migrate(context.Background(), from, to, migration.Options{})
+	require.NoError(t, err)
+
+	// the changed source user overwrites the destination one, the rest is left as is.
+	changed := users[2]
+	changed.Phone = "+61400000000"
+	_, err = from.User.UpdateUser(changed.ID, changed)
+	require.NoError(t, err)
+
+	report, err := migration.Migrate(context.Background(), from, to, migration.Options{})
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+	assert.Equal(t, 1, report.Users.Updated)
+	assert.Equal(t, len(users)-1, report.Users.Unchanged)
+	assert.Equal(t, 3, report.Apps.Unchanged)
+	assert.Equal(t, 1, report.Invites.Unchanged)
+
+	migrated, err := to.User.UserByID(changed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, changed.Phone, migrated.Phone)
+}
+
+func TestVerificationReportsMissing(t *testing.T) {
+	from := boltStorages(t)
+	to := sqliteStorages(t)
+	users := fillSource(t, from)
+
+	_, err := migration.Migrate(context.Background(), from, to, migration.Options{})
+	require.NoError(t, err)
+
+	extra, err := from.User.AddUserWithPassword(model.User{Username: "late"}, "password", "user", false)
+	require.NoError(t, err)
+
+	// the completed state skips the migration, so the new user is reported as missing.
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, migration.SaveState(statePath, migration.State{Completed: true}))
+
+	report, err := migration.Migrate(context.Background(), from, to, migration.Options{StatePath: statePath})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, len(users)+1, report.Users.Verification.Checked)
+	assert.Equal(t, 1, report.Users.Verification.MissingCount)
+	assert.Equal(t, []string{extra.ID}, report.Users.Verification.Missing)
+}
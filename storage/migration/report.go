@@ -0,0 +1,127 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+)
+
+// maxReportedIDs limits the number of the IDs and errors listed in the report for each kind of the records.
+const maxReportedIDs = 100
+
+// Report is the result of the migration and of its verification.
+type Report struct {
+	// Resumed is set when the migration continued the interrupted one, the counters cover this run only.
+	Resumed        bool         `json:"resumed"`
+	Apps           EntityReport `json:"apps"`
+	ManagementKeys EntityReport `json:"management_keys"`
+	Users          EntityReport `json:"users"`
+	Invites        EntityReport `json:"invites"`
+}
+
+// EntityReport is the report for the records of one kind.
+type EntityReport struct {
+	Read      int      `json:"read"`      // Read is the number of the source records read by this run.
+	Created   int      `json:"created"`   // Created is the number of the records created in the destination.
+	Updated   int      `json:"updated"`   // Updated is the number of the destination records overwritten by the source ones.
+	Unchanged int      `json:"unchanged"` // Unchanged is the number of the records which are already in the destination.
+	Skipped   int      `json:"skipped"`   // Skipped is the number of the source records which are not migrated on purpose, e.g. archived invites.
+	Failed    int      `json:"failed"`    // Failed is the number of the records which could not be written.
+	Errors    []string `json:"errors,omitempty"`
+
+	Verification Verification `json:"verification"`
+}
+
+// Verification is the comparison of all the source records with the destination ones, made after the migration.
+type Verification struct {
+	Checked    int      `json:"checked"`
+	Matched    int      `json:"matched"`
+	Missing    []string `json:"missing,omitempty"`
+	Mismatched []string `json:"mismatched,omitempty"`
+	// MissingCount and MismatchedCount are the total numbers, the lists are limited.
+	MissingCount    int    `json:"missing_count"`
+	MismatchedCount int    `json:"mismatched_count"`
+	Error           string `json:"error,omitempty"`
+}
+
+// OK returns true if all the records are migrated and verified.
+func (r Report) OK() bool {
+	for _, e := range r.entities() {
+		if e.report.Failed > 0 || !e.report.Verification.ok() {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes the human readable summary of the report.
+func (r Report) Print(w io.Writer) {
+	if r.Resumed {
+		fmt.Fprintln(w, "The migration has been resumed, the counters cover this run only.")
+	}
+	fmt.Fprintf(w, "%-16s %8s %8s %8s %9s %8s %8s %10s\n",
+		"", "read", "created", "updated", "unchanged", "skipped", "failed", "verified")
+	for _, e := range r.entities() {
+		v := e.report.Verification
+		fmt.Fprintf(w, "%-16s %8d %8d %8d %9d %8d %8d %4d/%-5d\n",
+			e.name, e.report.Read, e.report.Created, e.report.Updated, e.report.Unchanged,
+			e.report.Skipped, e.report.Failed, v.Matched, v.Checked)
+	}
+	for _, e := range r.entities() {
+		v := e.report.Verification
+		for _, err := range e.report.Errors {
+			fmt.Fprintf(w, "%s: %s\n", e.name, err)
+		}
+		if v.Error != "" {
+			fmt.Fprintf(w, "%s: verification failed: %s\n", e.name, v.Error)
+		}
+		if v.MissingCount > 0 {
+			fmt.Fprintf(w, "%s: %d missing in the destination: %v\n", e.name, v.MissingCount, v.Missing)
+		}
+		if v.MismatchedCount > 0 {
+			fmt.Fprintf(w, "%s: %d differ in the destination: %v\n", e.name, v.MismatchedCount, v.Mismatched)
+		}
+	}
+}
+
+type namedReport struct {
+	name   string
+	report EntityReport
+}
+
+func (r Report) entities() []namedReport {
+	return []namedReport{
+		{"apps", r.Apps},
+		{"management keys", r.ManagementKeys},
+		{"users", r.Users},
+		{"invites", r.Invites},
+	}
+}
+
+func (e *EntityReport) fail(id string, err error) {
+	e.Failed++
+	if len(e.Errors) < maxReportedIDs {
+		e.Errors = append(e.Errors, fmt.Sprintf("%s: %s", id, err))
+	}
+}
+
+func (v Verification) ok() bool {
+	return v.Error == "" && v.MissingCount == 0 && v.MismatchedCount == 0
+}
+
+func (v *Verification) check(id string, found, matched bool) {
+	v.Checked++
+	switch {
+	case !found:
+		v.MissingCount++
+		if len(v.Missing) < maxReportedIDs {
+			v.Missing = append(v.Missing, id)
+		}
+	case !matched:
+		v.MismatchedCount++
+		if len(v.Mismatched) < maxReportedIDs {
+			v.Mismatched = append(v.Mismatched, id)
+		}
+	default:
+		v.Matched++
+	}
+}
@@ -0,0 +1,53 @@
+package migration
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// State is the progress of the migration, the number of the source records of each kind which have been processed.
+// It is saved after each batch, so the interrupted migration continues from the last saved batch.
+type State struct {
+	Apps           int  `json:"apps"`
+	ManagementKeys int  `json:"management_keys"`
+	Users          int  `json:"users"`
+	Invites        int  `json:"invites"`
+	Completed      bool `json:"completed"`
+}
+
+// LoadState reads the state from the file, the missing file means the migration has not been started.
+func LoadState(path string) (State, error) {
+	var s State
+	if len(path) == 0 {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+// SaveState writes the state to the file, replacing it atomically.
+func SaveState(path string, s State) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage"
+)
+
+// Storages are the storages of the data which is migrated.
+type Storages struct {
+	App            model.AppStorage
+	User           model.UserStorage
+	Invite         model.InviteStorage
+	ManagementKeys model.ManagementKeysStorage
+}
+
+// OpenStorages creates all the migrated storages from the same database settings.
+func OpenStorages(logger *slog.Logger, settings model.DatabaseSettings) (Storages, error) {
+	if err := settings.Validate(); err != nil {
+		return Storages{}, err
+	}
+	if settings.Type == model.DBTypeDefault {
+		return Storages{}, fmt.Errorf("the database type should be set explicitly")
+	}
+
+	var (
+		s   Storages
+		err error
+	)
+	if s.App, err = storage.NewAppStorage(logger, settings); err != nil {
+		return Storages{}, fmt.Errorf("unable to create app storage: %w", err)
+	}
+	if s.User, err = storage.NewUserStorage(logger, settings); err != nil {
+		s.Close()
+		return Storages{}, fmt.Errorf("unable to create user storage: %w", err)
+	}
+	if s.Invite, err = storage.NewInviteStorage(logger, settings); err != nil {
+		s.Close()
+		return Storages{}, fmt.Errorf("unable to create invite storage: %w", err)
+	}
+	if s.ManagementKeys, err = storage.NewManagementKeys(logger, settings); err != nil {
+		s.Close()
+		return Storages{}, fmt.Errorf("unable to create management keys storage: %w", err)
+	}
+	return s, nil
+}
+
+// Close closes the opened storages.
+func (s Storages) Close() {
+	if s.App != nil {
+		s.App.Close()
+	}
+	if s.User != nil {
+		s.User.Close()
+	}
+	if s.Invite != nil {
+		s.Invite.Close()
+	}
+	// management keys storage has no Close method.
+}
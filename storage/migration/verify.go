@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+)
+
+// verify checks that every source record is in the destination and has the same data.
+// The errors of the storages are recorded in the report, only the cancellation interrupts the verification.
+func verify(ctx context.Context, from, to Storages, batch int, r *Report) error {
+	checks := []func(context.Context, Storages, Storages, int, *Verification) error{
+		verifyApps, verifyManagementKeys, verifyUsers, verifyInvites,
+	}
+	reports := []*Verification{
+		&r.Apps.Verification, &r.ManagementKeys.Verification, &r.Users.Verification, &r.Invites.Verification,
+	}
+
+	for i, check := range checks {
+		if err := check(ctx, from, to, batch, reports[i]); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			reports[i].Error = err.Error()
+		}
+	}
+	return nil
+}
+
+func verifyApps(ctx context.Context, from, to Storages, batch int, v *Verification) error {
+	apps, err := from.App.FetchApps("")
+	if err != nil {
+		return fmt.Errorf("unable to read source apps: %w", err)
+	}
+	for _, app := range apps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		found, err := to.App.AppByID(app.ID)
+		v.check(app.ID, err == nil, sameJSON(found, app))
+	}
+	return nil
+}
+
+func verifyManagementKeys(ctx context.Context, from, to Storages, batch int, v *Verification) error {
+	keys, err := from.ManagementKeys.GeyAllKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to read source management keys: %w", err)
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		found, err := to.ManagementKeys.GetKey(ctx, key.ID)
+		v.check(key.ID, err == nil, sameKey(found, key))
+	}
+	return nil
+}
+
+func verifyUsers(ctx context.Context, from, to Storages, batch int, v *Verification) error {
+	for skip := 0; ; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		users, _, err := from.User.FetchUsers("", skip, batch)
+		if err != nil {
+			return fmt.Errorf("unable to read source users: %w", err)
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		if len(users) > batch {
+			return ErrorNoPagination
+		}
+		for _, u := range users {
+			found, err := to.User.UserByID(u.ID)
+			v.check(u.ID, err == nil, sameUser(found, u))
+		}
+		skip += len(users)
+	}
+}
+
+// verifyInvites checks the active invites only, the others are not migrated.
+func verifyInvites(ctx context.Context, from, to Storages, batch int, v *Verification) error {
+	existing, err := activeInvites(to.Invite, batch)
+	if err != nil {
+		return fmt.Errorf("unable to read destination invites: %w", err)
+	}
+
+	for skip := 0; ; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		invites, _, err := from.Invite.GetAll(false, skip, batch)
+		if err != nil {
+			return fmt.Errorf("unable to read source invites: %w", err)
+		}
+		if len(invites) == 0 {
+			return nil
+		}
+		if len(invites) > batch {
+			return ErrorNoPagination
+		}
+		for _, invite := range invites {
+			if !isActiveInvite(invite) {
+				continue
+			}
+			found, ok := existing[invite.Token]
+			v.check(invite.ID, ok, ok &&
+				found.Email == invite.Email &&
+				found.Role == invite.Role &&
+				found.AppID == invite.AppID &&
+				found.ExpiresAt.Unix() == invite.ExpiresAt.Unix())
+		}
+		skip += len(invites)
+	}
+}
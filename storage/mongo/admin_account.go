@@ -0,0 +1,145 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const adminAccountsCollectionName = "AdminAccounts"
+
+// AdminAccountStorage is a MongoDB admin account storage.
+type AdminAccountStorage struct {
+	coll    *mongo.Collection
+	timeout time.Duration
+}
+
+// NewAdminAccountStorage creates a MongoDB admin account storage.
+func NewAdminAccountStorage(
+	logger *slog.Logger,
+	settings model.MongoDatabaseSettings,
+) (model.AdminAccountStorage, error) {
+	if len(settings.ConnectionString) == 0 || len(settings.DatabaseName) == 0 {
+		return nil, ErrorEmptyConnectionStringDatabase
+	}
+
+	// create database
+	db, err := NewDB(logger, settings.ConnectionString, settings.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := db.database.Collection(adminAccountsCollectionName)
+	as := &AdminAccountStorage{coll: coll, timeout: 30 * time.Second}
+
+	loginIndex := &mongo.IndexModel{
+		Keys:    bson.D{{Key: "login", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	err = db.EnsureCollectionIndices(adminAccountsCollectionName, []mongo.IndexModel{*loginIndex})
+	return as, err
+}
+
+// AdminAccounts returns all accounts ordered by login.
+func (as *AdminAccountStorage) AdminAccounts() ([]model.AdminAccount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), as.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "login", Value: 1}})
+	cursor, err := as.coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	accounts := []model.AdminAccount{}
+	if err = cursor.All(ctx, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// AdminAccountByID returns the account by its id.
+func (as *AdminAccountStorage) AdminAccountByID(id string) (model.AdminAccount, error) {
+	return as.findOne(bson.M{"_id": id})
+}
+
+// AdminAccountByLogin returns the account by its login.
+func (as *AdminAccountStorage) AdminAccountByLogin(login string) (model.AdminAccount, error) {
+	return as.findOne(bson.M{"login": login})
+}
+
+func (as *AdminAccountStorage) findOne(filter bson.M) (model.AdminAccount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), as.timeout)
+	defer cancel()
+
+	var account model.AdminAccount
+	if err := as.coll.FindOne(ctx, filter).Decode(&account); err != nil {
+		if isErrNotFound(err) {
+			return model.AdminAccount{}, model.ErrorNotFound
+		}
+		return model.AdminAccount{}, err
+	}
+	return account, nil
+}
+
+// AddAdminAccount adds new account.
+func (as *AdminAccountStorage) AddAdminAccount(account model.AdminAccount) (model.AdminAccount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), as.timeout)
+	defer cancel()
+
+	account.ID = xid.New().String()
+	if _, err := as.coll.InsertOne(ctx, account); err != nil {
+		if isErrDuplication(err) {
+			return model.AdminAccount{}, model.ErrorAdminAccountExists
+		}
+		return model.AdminAccount{}, err
+	}
+	return account, nil
+}
+
+// UpdateAdminAccount updates existing account.
+func (as *AdminAccountStorage) UpdateAdminAccount(account model.AdminAccount) error {
+	old, err := as.AdminAccountByID(account.ID)
+	if err != nil {
+		return err
+	}
+	account.Login = old.Login
+
+	ctx, cancel := context.WithTimeout(context.Background(), as.timeout)
+	defer cancel()
+
+	res, err := as.coll.ReplaceOne(ctx, bson.M{"_id": account.ID}, account)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return model.ErrorNotFound
+	}
+	return nil
+}
+
+// DeleteAdminAccount deletes account by its id.
+func (as *AdminAccountStorage) DeleteAdminAccount(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), as.timeout)
+	defer cancel()
+
+	res, err := as.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return model.ErrorNotFound
+	}
+	return nil
+}
+
+// Close is a no-op.
+func (as *AdminAccountStorage) Close() {}
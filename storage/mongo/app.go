@@ -3,6 +3,7 @@ package mongo
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"log/slog"
 	"time"
 
@@ -204,5 +205,15 @@ func (as *AppStorage) ImportJSON(data []byte, cleanOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports all apps to JSON.
+func (as *AppStorage) ExportJSON(w io.Writer) error {
+	return exportCollection[model.AppData](as.coll, w)
+}
+
+// ExportsSnapshot reports whether the export is read from the snapshot, it needs a replica set or a sharded cluster.
+func (as *AppStorage) ExportsSnapshot() bool {
+	return snapshotReads(as.coll)
+}
+
 // Close is a no-op.
 func (as *AppStorage) Close() {}
@@ -0,0 +1,118 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const auditCollectionName = "Audit"
+
+// AuditStorage is a MongoDB audit storage.
+type AuditStorage struct {
+	coll    *mongo.Collection
+	timeout time.Duration
+}
+
+// NewAuditStorage creates a MongoDB audit storage.
+func NewAuditStorage(
+	logger *slog.Logger,
+	settings model.MongoDatabaseSettings,
+) (model.AuditStorage, error) {
+	if len(settings.ConnectionString) == 0 || len(settings.DatabaseName) == 0 {
+		return nil, ErrorEmptyConnectionStringDatabase
+	}
+
+	// create database
+	db, err := NewDB(logger, settings.ConnectionString, settings.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := db.database.Collection(auditCollectionName)
+	as := &AuditStorage{coll: coll, timeout: 30 * time.Second}
+
+	// records are always fetched newest first, optionally filtered by user, app or operation
+	indices := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "app_id", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "operation", Value: 1}, {Key: "time", Value: -1}}},
+	}
+
+	err = db.EnsureCollectionIndices(auditCollectionName, indices)
+	return as, err
+}
+
+// AddRecord appends the record to the log.
+func (as *AuditStorage) AddRecord(record model.AuditRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), as.timeout)
+	defer cancel()
+
+	record.ID = primitive.NewObjectID().Hex()
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	_, err := as.coll.InsertOne(ctx, record)
+	return err
+}
+
+// FetchRecords returns the records matching the filter, newest first.
+func (as *AuditStorage) FetchRecords(filter model.AuditFilter) ([]model.AuditRecord, int, error) {
+	q := bson.M{}
+	if len(filter.UserID) > 0 {
+		q["user_id"] = filter.UserID
+	}
+	if len(filter.AppID) > 0 {
+		q["app_id"] = filter.AppID
+	}
+	if len(filter.Operation) > 0 {
+		q["operation"] = filter.Operation
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		tq := bson.M{}
+		if !filter.From.IsZero() {
+			tq["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			tq["$lt"] = filter.To
+		}
+		q["time"] = tq
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*as.timeout)
+	defer cancel()
+
+	total, err := as.coll.CountDocuments(ctx, q)
+	if err != nil {
+		return []model.AuditRecord{}, 0, err
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}})
+	findOptions.SetSkip(int64(filter.Skip))
+	if filter.Limit > 0 {
+		findOptions.SetLimit(int64(filter.Limit))
+	}
+
+	curr, err := as.coll.Find(ctx, q, findOptions)
+	if err != nil {
+		return []model.AuditRecord{}, 0, err
+	}
+
+	records := []model.AuditRecord{}
+	if err = curr.All(ctx, &records); err != nil {
+		return []model.AuditRecord{}, 0, err
+	}
+	return records, int(total), nil
+}
+
+// Close is a no-op.
+func (as *AuditStorage) Close() {}
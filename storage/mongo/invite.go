@@ -2,7 +2,9 @@ package mongo
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"time"
 
@@ -160,5 +162,42 @@ func (is *InviteStorage) ArchiveByID(id string) error {
 	return err
 }
 
+// ImportJSON imports invites from JSON.
+func (is *InviteStorage) ImportJSON(data []byte, clearOldData bool) error {
+	invites := []model.Invite{}
+	if err := json.Unmarshal(data, &invites); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), is.timeout)
+	defer cancel()
+
+	if clearOldData {
+		if _, err := is.coll.DeleteMany(ctx, bson.M{}); err != nil {
+			return err
+		}
+	}
+	for _, invite := range invites {
+		if len(invite.ID) == 0 {
+			invite.ID = primitive.NewObjectID().Hex()
+		}
+		opts := options.Replace().SetUpsert(true)
+		if _, err := is.coll.ReplaceOne(ctx, bson.M{"_id": invite.ID}, invite, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportJSON exports all invites to JSON.
+func (is *InviteStorage) ExportJSON(w io.Writer) error {
+	return exportCollection[model.Invite](is.coll, w)
+}
+
+// ExportsSnapshot reports whether the export is read from the snapshot, it needs a replica set or a sharded cluster.
+func (is *InviteStorage) ExportsSnapshot() bool {
+	return snapshotReads(is.coll)
+}
+
 // Close is a no-op.
 func (is *InviteStorage) Close() {}
@@ -0,0 +1,110 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const loginAttemptsCollectionName = "LoginAttempts"
+
+// LoginAttemptStorage is a MongoDB failed login attempts storage.
+// Expired counters are removed by TTL index, which runs periodically,
+// so the expiration is checked on every access too.
+type LoginAttemptStorage struct {
+	coll    *mongo.Collection
+	timeout time.Duration
+}
+
+// NewLoginAttemptStorage creates a MongoDB failed login attempts storage.
+func NewLoginAttemptStorage(
+	logger *slog.Logger,
+	settings model.MongoDatabaseSettings,
+) (model.LoginAttemptStorage, error) {
+	if len(settings.ConnectionString) == 0 || len(settings.DatabaseName) == 0 {
+		return nil, ErrorEmptyConnectionStringDatabase
+	}
+
+	// create database
+	db, err := NewDB(logger, settings.ConnectionString, settings.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := db.database.Collection(loginAttemptsCollectionName)
+	ls := &LoginAttemptStorage{coll: coll, timeout: 30 * time.Second}
+
+	expiresAtOptions := &options.IndexOptions{}
+	expiresAtOptions.SetExpireAfterSeconds(0)
+
+	expiresAtIndex := &mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: expiresAtOptions,
+	}
+
+	err = db.EnsureCollectionIndices(loginAttemptsCollectionName, []mongo.IndexModel{*expiresAtIndex})
+	return ls, err
+}
+
+// LoginAttempts returns failed login attempts for the key.
+func (ls *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ls.timeout)
+	defer cancel()
+
+	var attempts model.LoginAttempts
+	filter := bson.M{"_id": key, "expires_at": bson.M{"$gt": time.Now()}}
+	if err := ls.coll.FindOne(ctx, filter).Decode(&attempts); err != nil {
+		if isErrNotFound(err) {
+			return model.LoginAttempts{Key: key}, nil
+		}
+		return model.LoginAttempts{Key: key}, err
+	}
+	return attempts, nil
+}
+
+// AddFailedAttempt increments failed login attempts for the key and prolongs their expiration.
+func (ls *LoginAttemptStorage) AddFailedAttempt(key string, expiresIn time.Duration) (model.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ls.timeout)
+	defer cancel()
+
+	now := time.Now()
+
+	// drop the expired counter, which has not been removed by TTL index yet
+	if _, err := ls.coll.DeleteOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$lte": now}}); err != nil {
+		return model.LoginAttempts{Key: key}, err
+	}
+
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"last_failed_at": now, "expires_at": now.Add(expiresIn)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var attempts model.LoginAttempts
+	err := ls.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempts)
+	if err != nil && isErrDuplication(err) {
+		// concurrent upsert has created the counter, just increment it
+		err = ls.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempts)
+	}
+	if err != nil {
+		return model.LoginAttempts{Key: key}, err
+	}
+	return attempts, nil
+}
+
+// ResetLoginAttempts deletes failed login attempts for the key.
+func (ls *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ls.timeout)
+	defer cancel()
+
+	_, err := ls.coll.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+// Close is a no-op.
+func (ls *LoginAttemptStorage) Close() {}
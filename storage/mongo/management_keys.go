@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"time"
 
@@ -168,3 +169,12 @@ func (ms *ManagementKeysStorage) ImportJSON(data []byte, cleanOldData bool) erro
 	}
 	return nil
 }
+
+func (ms *ManagementKeysStorage) ExportJSON(w io.Writer) error {
+	return exportCollection[model.ManagementKey](ms.coll, w)
+}
+
+// ExportsSnapshot reports whether the export is read from the snapshot, it needs a replica set or a sharded cluster.
+func (ms *ManagementKeysStorage) ExportsSnapshot() bool {
+	return snapshotReads(ms.coll)
+}
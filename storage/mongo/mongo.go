@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -73,6 +75,64 @@ func (db *DB) EnsureCollectionIndices(collectionName string, newIndices []mongo.
 	return nil
 }
 
+// snapshotReads checks if the deployment of the collection supports snapshot reads,
+// which are supported by replica sets and sharded clusters only.
+func snapshotReads(coll *mongo.Collection) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := coll.Database().Client().Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false
+	}
+	return len(hello.SetName) > 0 || hello.Msg == "isdbgrid"
+}
+
+// exportCollection writes all the documents of the collection as JSON array.
+// The documents are read from the snapshot, so the export is consistent, the export fails if the snapshot could not be read.
+// Snapshot reads are supported by replica sets and sharded clusters only,
+// the standalone server is read with the regular cursor, see snapshotReads.
+func exportCollection[T any](coll *mongo.Collection, w io.Writer) error {
+	ctx := context.Background()
+
+	var (
+		curr *mongo.Cursor
+		err  error
+	)
+	if snapshotReads(coll) {
+		sess, serr := coll.Database().Client().StartSession(options.Session().SetSnapshot(true))
+		if serr != nil {
+			return serr
+		}
+		defer sess.EndSession(ctx)
+		ctx = mongo.NewSessionContext(ctx, sess)
+		if curr, err = coll.Find(ctx, bson.M{}); err != nil {
+			return fmt.Errorf("unable to read the snapshot of %s: %w", coll.Name(), err)
+		}
+	} else if curr, err = coll.Find(ctx, bson.M{}); err != nil {
+		return err
+	}
+	defer curr.Close(ctx)
+
+	aw := model.NewJSONArrayWriter(w)
+	for curr.Next(ctx) {
+		var item T
+		if err := curr.Decode(&item); err != nil {
+			return err
+		}
+		if err := aw.Write(item); err != nil {
+			return err
+		}
+	}
+	if err := curr.Err(); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
 func generateIndexName(index mongo.IndexModel) (string, error) {
 	if index.Options != nil && index.Options.Name != nil {
 		return *index.Options.Name, nil
@@ -8,13 +8,14 @@ import (
 
 	"github.com/madappgang/identifo/v2/model"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const blacklistedTokensCollectionName = "BlacklistedTokens"
 
 // NewTokenBlacklist creates new MongoDB-backed token blacklist.
+// The expired tokens are removed by the TTL index.
 func NewTokenBlacklist(
 	logger *slog.Logger,
 	settings model.MongoDatabaseSettings,
@@ -31,11 +32,11 @@ func NewTokenBlacklist(
 
 	coll := db.database.Collection(blacklistedTokensCollectionName)
 
-	// TODO: check that index exists for other DB's
+	expiresAtOptions := &options.IndexOptions{}
+	expiresAtOptions.SetExpireAfterSeconds(0)
+
 	err = db.EnsureCollectionIndices(blacklistedTokensCollectionName, []mongo.IndexModel{
-		{
-			Keys: bson.D{{Key: "token", Value: 1}},
-		},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: expiresAtOptions},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create indexes for %s: %w",
@@ -43,7 +44,11 @@ func NewTokenBlacklist(
 			err)
 	}
 
-	return &TokenBlacklist{coll: coll, timeout: 30 * time.Second}, nil
+	tb := &TokenBlacklist{coll: coll, timeout: 30 * time.Second}
+	if err := tb.convertLegacyEntries(); err != nil {
+		return nil, fmt.Errorf("failed to convert %s: %w", blacklistedTokensCollectionName, err)
+	}
+	return tb, nil
 }
 
 // TokenBlacklist is a MongoDB-backed token blacklist.
@@ -52,33 +57,77 @@ type TokenBlacklist struct {
 	timeout time.Duration
 }
 
+// blacklistedToken is the blacklist document, the documents without expires_at are never removed.
+type blacklistedToken struct {
+	ID        string     `bson:"_id"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty"`
+}
+
 // Add adds token to the blacklist.
-func (tb *TokenBlacklist) Add(token string) error {
-	if len(token) == 0 {
+func (tb *TokenBlacklist) Add(id string, expiresAt time.Time) error {
+	if len(id) == 0 {
 		return model.ErrorWrongDataFormat
 	}
+	if model.IsBlacklistEntryExpired(expiresAt) {
+		return nil
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), tb.timeout)
 	defer cancel()
 
-	t := Token{Token: token, ID: primitive.NewObjectID().Hex()}
-	_, err := tb.coll.InsertOne(ctx, t)
+	return tb.put(ctx, id, expiresAt)
+}
+
+func (tb *TokenBlacklist) put(ctx context.Context, id string, expiresAt time.Time) error {
+	t := blacklistedToken{ID: id}
+	if !expiresAt.IsZero() {
+		t.ExpiresAt = &expiresAt
+	}
+	_, err := tb.coll.ReplaceOne(ctx, bson.M{"_id": id}, t, options.Replace().SetUpsert(true))
 	return err
 }
 
 // IsBlacklisted returns true if the token is present in the blacklist.
-func (tb *TokenBlacklist) IsBlacklisted(token string) bool {
+func (tb *TokenBlacklist) IsBlacklisted(id string) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), tb.timeout)
 	defer cancel()
 
-	var t Token
-	if err := tb.coll.FindOne(ctx, bson.M{"token": token}).Decode(&t); err != nil {
+	var t blacklistedToken
+	if err := tb.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&t); err != nil {
 		return false
 	}
-	if t.Token == token {
-		return true
+	// the TTL monitor removes the expired documents once a minute.
+	return t.ExpiresAt == nil || !model.IsBlacklistEntryExpired(*t.ExpiresAt)
+}
+
+// convertLegacyEntries replaces the documents of the older versions,
+// which keep the whole token with the random ID, by the documents with the token ID.
+func (tb *TokenBlacklist) convertLegacyEntries() error {
+	ctx := context.Background()
+
+	curr, err := tb.coll.Find(ctx, bson.M{"token": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer curr.Close(ctx)
+
+	for curr.Next(ctx) {
+		var legacy Token
+		if err := curr.Decode(&legacy); err != nil {
+			return err
+		}
+
+		id, expiresAt := model.BlacklistEntry(legacy.Token)
+		if !model.IsBlacklistEntryExpired(expiresAt) {
+			if err := tb.put(ctx, id, expiresAt); err != nil {
+				return err
+			}
+		}
+		if _, err := tb.coll.DeleteOne(ctx, bson.M{"_id": legacy.ID}); err != nil {
+			return err
+		}
 	}
-	return false
+	return curr.Err()
 }
 
 // Close is a no-op.
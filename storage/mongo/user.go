@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"strings"
 	"time"
@@ -80,12 +81,8 @@ type UserStorage struct {
 }
 
 // UserByID returns user by its ID.
+// The ID is not checked to be ObjectID hex, as the users migrated from other storages keep their IDs.
 func (us *UserStorage) UserByID(id string) (model.User, error) {
-	_, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return model.User{}, err
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
 	defer cancel()
 
@@ -256,15 +253,17 @@ func (us *UserStorage) AddUserWithPassword(user model.User, password, role strin
 	}
 
 	u := model.User{
-		ID:         primitive.NewObjectID().Hex(),
-		Active:     true,
-		Username:   user.Username,
-		Phone:      user.Phone,
-		FullName:   user.FullName,
-		Scopes:     user.Scopes,
-		Email:      user.Email,
-		AccessRole: role,
-		Anonymous:  isAnonymous,
+		ID:            primitive.NewObjectID().Hex(),
+		Active:        true,
+		Username:      user.Username,
+		Phone:         user.Phone,
+		FullName:      user.FullName,
+		Scopes:        user.Scopes,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		PhoneVerified: user.PhoneVerified,
+		AccessRole:    role,
+		Anonymous:     isAnonymous,
 	}
 
 	return us.AddNewUser(u, password)
@@ -293,10 +292,6 @@ func (us *UserStorage) AddUserWithFederatedID(user model.User, provider string,
 
 // UpdateUser updates user in MongoDB storage.
 func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User, error) {
-	hexID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		return model.User{}, err
-	}
 	oldUser, err := us.UserByID(userID)
 	if err != nil {
 		return model.User{}, err
@@ -311,9 +306,7 @@ func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User
 		newUser.Pswd = oldUser.Pswd
 	}
 
-	if newUser.TFAInfo.Secret == "" {
-		newUser.TFAInfo.Secret = oldUser.TFAInfo.Secret
-	}
+	newUser.TFAInfo = newUser.TFAInfo.WithSecretsFrom(oldUser.TFAInfo)
 
 	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
 	defer cancel()
@@ -322,7 +315,7 @@ func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
 	var ud model.User
-	if err := us.coll.FindOneAndUpdate(ctx, bson.M{"_id": hexID.Hex()}, update, opts).Decode(&ud); err != nil {
+	if err := us.coll.FindOneAndUpdate(ctx, bson.M{"_id": userID}, update, opts).Decode(&ud); err != nil {
 		return model.User{}, err
 	}
 	return ud, nil
@@ -330,11 +323,6 @@ func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User
 
 // ResetPassword sets new user's password.
 func (us *UserStorage) ResetPassword(id, password string) error {
-	_, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return err
-	}
-
 	update := bson.M{"$set": bson.M{"pswd": model.PasswordHash(password)}}
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
@@ -342,7 +330,7 @@ func (us *UserStorage) ResetPassword(id, password string) error {
 	defer cancel()
 
 	var ud model.User
-	err = us.coll.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: id}}, update, opts).Decode(&ud)
+	err := us.coll.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: id}}, update, opts).Decode(&ud)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return model.ErrUserNotFound
@@ -370,11 +358,6 @@ func (us *UserStorage) CheckPassword(id, password string) error {
 
 // ResetUsername sets new user's username.
 func (us *UserStorage) ResetUsername(id, username string) error {
-	_, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return err
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
 	defer cancel()
 
@@ -382,21 +365,15 @@ func (us *UserStorage) ResetUsername(id, username string) error {
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
 	var ud model.User
-	err = us.coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&ud)
-	return err
+	return us.coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&ud)
 }
 
 // DeleteUser deletes user by id.
 func (us *UserStorage) DeleteUser(id string) error {
-	_, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return err
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
 	defer cancel()
 
-	_, err = us.coll.DeleteOne(ctx, bson.M{"_id": id})
+	_, err := us.coll.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
 
@@ -452,6 +429,10 @@ func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
 	for _, u := range ud {
 		pswd := u.Pswd
 		u.Pswd = ""
+		// the exported users have the password hash, which is kept as is.
+		if model.IsPasswordHash(pswd) {
+			u.Pswd, pswd = pswd, ""
+		}
 		if _, err := us.AddNewUser(u, pswd); err != nil {
 			return err
 		}
@@ -459,16 +440,18 @@ func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
 	return nil
 }
 
+// ExportJSON exports all users to JSON.
+func (us *UserStorage) ExportJSON(w io.Writer) error {
+	return exportCollection[model.User](us.coll, w)
+}
+
+// ExportsSnapshot reports whether the export is read from the snapshot, it needs a replica set or a sharded cluster.
+func (us *UserStorage) ExportsSnapshot() bool {
+	return snapshotReads(us.coll)
+}
+
 // UpdateLoginMetadata updates user's login metadata.
 func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes []string, payload map[string]any) {
-	hexID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		us.logger.Error("Cannot update login metadata of user",
-			logging.FieldUserID, userID,
-			logging.FieldError, err)
-		return
-	}
-
 	update := bson.M{
 		"$set": bson.M{"latest_login_time": time.Now().Unix()},
 		"$inc": bson.M{"num_of_logins": 1},
@@ -478,13 +461,35 @@ func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes
 	defer cancel()
 
 	var ud model.User
-	if err := us.coll.FindOneAndUpdate(ctx, bson.M{"_id": hexID.Hex()}, update).Decode(&ud); err != nil {
+	if err := us.coll.FindOneAndUpdate(ctx, bson.M{"_id": userID}, update).Decode(&ud); err != nil {
 		us.logger.Error("Cannot update login metadata of user",
 			logging.FieldUserID, userID,
 			logging.FieldError, err)
 	}
 }
 
+// ReplaceRecoveryCodes replaces the recovery codes of the user if they are still the old ones,
+// the codes are compared in the update filter.
+func (us *UserStorage) ReplaceRecoveryCodes(userID string, old, codes []string) (bool, error) {
+	if codes == nil {
+		// the used last code leaves the empty list, not null.
+		codes = []string{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": userID, "tfa_info.recovery_codes": old}
+	if len(old) == 0 {
+		filter["tfa_info.recovery_codes"] = bson.M{"$in": bson.A{nil, bson.A{}}}
+	}
+	res, err := us.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"tfa_info.recovery_codes": codes}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
 // Close is a no-op.
 func (us *UserStorage) Close() {}
 
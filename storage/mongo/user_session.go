@@ -0,0 +1,157 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const userSessionsCollectionName = "UserSessions"
+
+// UserSessionStorage is a MongoDB user session storage.
+// Expired sessions are removed by TTL index, which runs periodically,
+// so the expiration is checked on every access too.
+type UserSessionStorage struct {
+	coll    *mongo.Collection
+	timeout time.Duration
+}
+
+// NewUserSessionStorage creates a MongoDB user session storage.
+func NewUserSessionStorage(
+	logger *slog.Logger,
+	settings model.MongoDatabaseSettings,
+) (model.UserSessionStorage, error) {
+	if len(settings.ConnectionString) == 0 || len(settings.DatabaseName) == 0 {
+		return nil, ErrorEmptyConnectionStringDatabase
+	}
+
+	// create database
+	db, err := NewDB(logger, settings.ConnectionString, settings.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := db.database.Collection(userSessionsCollectionName)
+	us := &UserSessionStorage{coll: coll, timeout: 30 * time.Second}
+
+	expiresAtOptions := &options.IndexOptions{}
+	expiresAtOptions.SetExpireAfterSeconds(0)
+
+	indices := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: expiresAtOptions},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_refresh_at", Value: -1}}},
+		{Keys: bson.D{{Key: "refresh_token_id", Value: 1}}},
+	}
+
+	err = db.EnsureCollectionIndices(userSessionsCollectionName, indices)
+	return us, err
+}
+
+// AddSession saves the new session.
+func (us *UserSessionStorage) AddSession(session model.UserSession) (model.UserSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
+	defer cancel()
+
+	if len(session.ID) == 0 {
+		session.ID = primitive.NewObjectID().Hex()
+	}
+	_, err := us.coll.InsertOne(ctx, session)
+	return session, err
+}
+
+// UpdateSession replaces the session.
+func (us *UserSessionStorage) UpdateSession(session model.UserSession) error {
+	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
+	defer cancel()
+
+	res, err := us.coll.ReplaceOne(ctx, bson.M{"_id": session.ID}, session)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return model.ErrorNotFound
+	}
+	return nil
+}
+
+// SessionByID returns the session by its ID.
+func (us *UserSessionStorage) SessionByID(id string) (model.UserSession, error) {
+	return us.findOne(bson.M{"_id": id})
+}
+
+// SessionByRefreshTokenID returns the session by the jti of its refresh token.
+func (us *UserSessionStorage) SessionByRefreshTokenID(tokenID string) (model.UserSession, error) {
+	return us.findOne(bson.M{"refresh_token_id": tokenID})
+}
+
+func (us *UserSessionStorage) findOne(filter bson.M) (model.UserSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
+	defer cancel()
+
+	filter["expires_at"] = bson.M{"$gt": time.Now()}
+
+	var s model.UserSession
+	if err := us.coll.FindOne(ctx, filter).Decode(&s); err != nil {
+		if isErrNotFound(err) {
+			return model.UserSession{}, model.ErrorNotFound
+		}
+		return model.UserSession{}, err
+	}
+	return s, nil
+}
+
+// UserSessions returns the sessions of the user, the latest refreshed first.
+func (us *UserSessionStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "expires_at": bson.M{"$gt": time.Now()}}
+	findOptions := options.Find().SetSort(bson.D{{Key: "last_refresh_at", Value: -1}})
+
+	curr, err := us.coll.Find(ctx, filter, findOptions)
+	if err != nil {
+		return []model.UserSession{}, err
+	}
+
+	sessions := []model.UserSession{}
+	if err = curr.All(ctx, &sessions); err != nil {
+		return []model.UserSession{}, err
+	}
+	return sessions, nil
+}
+
+// DeleteSession deletes the session.
+func (us *UserSessionStorage) DeleteSession(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
+	defer cancel()
+
+	res, err := us.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return model.ErrorNotFound
+	}
+	return nil
+}
+
+// DeleteUserSessions deletes all sessions of the user.
+func (us *UserSessionStorage) DeleteUserSessions(userID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
+	defer cancel()
+
+	res, err := us.coll.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+// Close is a no-op.
+func (us *UserSessionStorage) Close() {}
@@ -11,11 +11,16 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const (
-	verificationCodesCollectionName = "VerificationCodes"
-	// verificationCodesExpirationTime specifies time before deleting records.
-	verificationCodesExpirationTime = 5 * time.Minute
-)
+const verificationCodesCollectionName = "VerificationCodes"
+
+// legacyVerificationCodesIndices are the indices of the codes without the attempts, the unique code index breaks the same codes of different phones.
+var legacyVerificationCodesIndices = []string{"code_1", "createdAt_1"}
+
+// verificationCode is the code document, it is deleted by the TTL index when it is not needed anymore.
+type verificationCode struct {
+	model.VerificationCode `bson:",inline"`
+	DeleteAt               time.Time `bson:"deleteAt"`
+}
 
 // NewVerificationCodeStorage creates and inits MongoDB verification code storage.
 func NewVerificationCodeStorage(
@@ -35,6 +40,13 @@ func NewVerificationCodeStorage(
 	coll := db.database.Collection(verificationCodesCollectionName)
 	vcs := &VerificationCodeStorage{coll: coll, timeout: 30 * time.Second}
 
+	ctx, cancel := context.WithTimeout(context.Background(), vcs.timeout)
+	defer cancel()
+	for _, name := range legacyVerificationCodesIndices {
+		// the index is missing in the new databases.
+		_, _ = coll.Indexes().DropOne(ctx, name)
+	}
+
 	phoneIndexOptions := &options.IndexOptions{}
 	phoneIndexOptions.SetUnique(true)
 
@@ -43,24 +55,15 @@ func NewVerificationCodeStorage(
 		Options: phoneIndexOptions,
 	}
 
-	codeIndexOptions := &options.IndexOptions{}
-	codeIndexOptions.SetUnique(true)
+	deleteAtOptions := &options.IndexOptions{}
+	deleteAtOptions.SetExpireAfterSeconds(0)
 
-	codeIndex := &mongo.IndexModel{
-		Keys:    bson.D{{Key: "code", Value: 1}},
-		Options: codeIndexOptions,
+	deleteAtIndex := &mongo.IndexModel{
+		Keys:    bson.D{{Key: "deleteAt", Value: 1}},
+		Options: deleteAtOptions,
 	}
 
-	createdAtOptions := &options.IndexOptions{}
-	createdAtOptions.SetUnique(true)
-	createdAtOptions.SetExpireAfterSeconds(int32(verificationCodesExpirationTime.Seconds()))
-
-	createdAtIndex := &mongo.IndexModel{
-		Keys:    bson.D{{Key: "createdAt", Value: 1}},
-		Options: createdAtOptions,
-	}
-
-	err = db.EnsureCollectionIndices(verificationCodesCollectionName, []mongo.IndexModel{*phoneIndex, *codeIndex, *createdAtIndex})
+	err = db.EnsureCollectionIndices(verificationCodesCollectionName, []mongo.IndexModel{*phoneIndex, *deleteAtIndex})
 	return vcs, err
 }
 
@@ -70,32 +73,83 @@ type VerificationCodeStorage struct {
 	timeout time.Duration
 }
 
-// IsVerificationCodeFound checks whether verification code can be found.
-func (vcs *VerificationCodeStorage) IsVerificationCodeFound(phone, code string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// CreateVerificationCode saves the new code of the phone, replacing the previous one.
+// The code is replaced only if the resend cooldown has passed, otherwise the unique phone index rejects the upsert.
+func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string, policy model.VerificationCodePolicy) (model.VerificationCodeStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), vcs.timeout)
 	defer cancel()
 
-	var c interface{}
-	if err := vcs.coll.FindOneAndDelete(ctx, bson.M{"phone": phone, "code": code}).Decode(&c); err != nil {
-		if isErrNotFound(err) {
-			return false, nil
+	now := time.Now()
+	vc := model.NewVerificationCode(phone, code, policy, now)
+	filter := bson.M{
+		"phone": phone,
+		"$or": bson.A{
+			bson.M{"resendAt": bson.M{"$lte": now}},
+			bson.M{"resendAt": bson.M{"$exists": false}},
+		},
+	}
+	doc := verificationCode{VerificationCode: vc, DeleteAt: vc.DeleteAt()}
+
+	_, err := vcs.coll.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		var prev verificationCode
+		if err := vcs.coll.FindOne(ctx, bson.M{"phone": phone}).Decode(&prev); err != nil {
+			return model.VerificationCodeStatus{}, err
 		}
-		return false, err
+		return prev.Status(), model.ErrorVerificationCodeCooldown
 	}
-	return true, nil
+	if err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+	return vc.Status(), nil
 }
 
-// CreateVerificationCode inserts new verification code to the database.
-func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// VerifyCode checks the code of the phone, the matching code is deleted, the mismatch uses the attempt.
+func (vcs *VerificationCodeStorage) VerifyCode(phone, code string) (model.VerificationCodeStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), vcs.timeout)
 	defer cancel()
 
-	if _, err := vcs.coll.DeleteMany(ctx, bson.M{"phone": phone}); err != nil {
-		return err
+	now := time.Now()
+	usable := bson.M{
+		"phone":        phone,
+		"expiresAt":    bson.M{"$gt": now},
+		"attemptsLeft": bson.M{"$gt": 0},
+	}
+
+	var vc verificationCode
+	matching := bson.M{"code": code}
+	for k, v := range usable {
+		matching[k] = v
+	}
+	err := vcs.coll.FindOneAndDelete(ctx, matching).Decode(&vc)
+	if err == nil {
+		return vc.Status(), nil
+	}
+	if !isErrNotFound(err) {
+		return model.VerificationCodeStatus{}, err
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = vcs.coll.FindOneAndUpdate(ctx, usable, bson.M{"$inc": bson.M{"attemptsLeft": -1}}, opts).Decode(&vc)
+	if err == nil {
+		return vc.Status(), model.ErrorVerificationCodeInvalid
+	}
+	if !isErrNotFound(err) {
+		return model.VerificationCodeStatus{}, err
 	}
 
-	_, err := vcs.coll.InsertOne(ctx, bson.M{"phone": phone, "code": code, "createdAt": time.Now()})
-	return err
+	// the code has no attempts left or has expired.
+	err = vcs.coll.FindOne(ctx, bson.M{"phone": phone}).Decode(&vc)
+	if isErrNotFound(err) {
+		return model.VerificationCodeStatus{}, model.ErrorVerificationCodeExpired
+	}
+	if err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+	if now.Before(vc.ExpiresAt) {
+		return vc.Status(), model.ErrorVerificationCodeAttemptsExceeded
+	}
+	return vc.Status(), model.ErrorVerificationCodeExpired
 }
 
 // Close is a no-op here.
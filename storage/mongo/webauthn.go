@@ -0,0 +1,173 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	webAuthnCredentialsCollectionName = "WebAuthnCredentials"
+	webAuthnSessionsCollectionName    = "WebAuthnSessions"
+)
+
+// WebAuthnStorage is a MongoDB WebAuthn credentials storage.
+// Expired sessions are removed by TTL index, which runs periodically,
+// so the expiration is checked on every access too.
+type WebAuthnStorage struct {
+	coll     *mongo.Collection
+	sessions *mongo.Collection
+	timeout  time.Duration
+}
+
+// NewWebAuthnStorage creates a MongoDB WebAuthn credentials storage.
+func NewWebAuthnStorage(
+	logger *slog.Logger,
+	settings model.MongoDatabaseSettings,
+) (model.WebAuthnStorage, error) {
+	if len(settings.ConnectionString) == 0 || len(settings.DatabaseName) == 0 {
+		return nil, ErrorEmptyConnectionStringDatabase
+	}
+
+	// create database
+	db, err := NewDB(logger, settings.ConnectionString, settings.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &WebAuthnStorage{
+		coll:     db.database.Collection(webAuthnCredentialsCollectionName),
+		sessions: db.database.Collection(webAuthnSessionsCollectionName),
+		timeout:  30 * time.Second,
+	}
+
+	userIDIndex := &mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	}
+
+	if err = db.EnsureCollectionIndices(webAuthnCredentialsCollectionName, []mongo.IndexModel{*userIDIndex}); err != nil {
+		return ws, err
+	}
+
+	expiresAtOptions := &options.IndexOptions{}
+	expiresAtOptions.SetExpireAfterSeconds(0)
+
+	expiresAtIndex := &mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: expiresAtOptions,
+	}
+
+	err = db.EnsureCollectionIndices(webAuthnSessionsCollectionName, []mongo.IndexModel{*expiresAtIndex})
+	return ws, err
+}
+
+// AddCredential adds new credential.
+func (ws *WebAuthnStorage) AddCredential(credential model.WebAuthnCredential) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	if _, err := ws.coll.InsertOne(ctx, credential); err != nil {
+		if isErrDuplication(err) {
+			return model.ErrorWrongDataFormat
+		}
+		return err
+	}
+	return nil
+}
+
+// CredentialByID returns credential by its id.
+func (ws *WebAuthnStorage) CredentialByID(id string) (model.WebAuthnCredential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	var credential model.WebAuthnCredential
+	if err := ws.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&credential); err != nil {
+		if isErrNotFound(err) {
+			return model.WebAuthnCredential{}, model.ErrorNotFound
+		}
+		return model.WebAuthnCredential{}, err
+	}
+	return credential, nil
+}
+
+// CredentialsByUserID returns all credentials of the user, ordered by creation time.
+func (ws *WebAuthnStorage) CredentialsByUserID(userID string) ([]model.WebAuthnCredential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := ws.coll.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	credentials := []model.WebAuthnCredential{}
+	if err = cursor.All(ctx, &credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// UpdateCredential updates existing credential.
+func (ws *WebAuthnStorage) UpdateCredential(credential model.WebAuthnCredential) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	res, err := ws.coll.ReplaceOne(ctx, bson.M{"_id": credential.ID}, credential)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return model.ErrorNotFound
+	}
+	return nil
+}
+
+// DeleteCredential deletes credential by its id.
+func (ws *WebAuthnStorage) DeleteCredential(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	res, err := ws.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return model.ErrorNotFound
+	}
+	return nil
+}
+
+// SaveSession saves the session of the ceremony.
+func (ws *WebAuthnStorage) SaveSession(session model.WebAuthnSession) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	_, err := ws.sessions.InsertOne(ctx, session)
+	return err
+}
+
+// TakeSession returns and deletes the session.
+func (ws *WebAuthnStorage) TakeSession(id string) (model.WebAuthnSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	var session model.WebAuthnSession
+	filter := bson.M{"_id": id, "expires_at": bson.M{"$gt": time.Now()}}
+	if err := ws.sessions.FindOneAndDelete(ctx, filter).Decode(&session); err != nil {
+		if isErrNotFound(err) {
+			return model.WebAuthnSession{}, model.ErrorNotFound
+		}
+		return model.WebAuthnSession{}, err
+	}
+	return session, nil
+}
+
+// Close is a no-op.
+func (ws *WebAuthnStorage) Close() {}
@@ -0,0 +1,171 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const webhookDeliveriesCollectionName = "WebhookDeliveries"
+
+// WebhookDeliveryStorage is a MongoDB webhook delivery storage.
+type WebhookDeliveryStorage struct {
+	coll    *mongo.Collection
+	timeout time.Duration
+}
+
+// NewWebhookDeliveryStorage creates a MongoDB webhook delivery storage.
+func NewWebhookDeliveryStorage(
+	logger *slog.Logger,
+	settings model.MongoDatabaseSettings,
+) (model.WebhookDeliveryStorage, error) {
+	if len(settings.ConnectionString) == 0 || len(settings.DatabaseName) == 0 {
+		return nil, ErrorEmptyConnectionStringDatabase
+	}
+
+	// create database
+	db, err := NewDB(logger, settings.ConnectionString, settings.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := db.database.Collection(webhookDeliveriesCollectionName)
+	ws := &WebhookDeliveryStorage{coll: coll, timeout: 30 * time.Second}
+
+	indices := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+
+	err = db.EnsureCollectionIndices(webhookDeliveriesCollectionName, indices)
+	return ws, err
+}
+
+// AddDelivery queues the delivery.
+func (ws *WebhookDeliveryStorage) AddDelivery(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	delivery.ID = primitive.NewObjectID().Hex()
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+
+	_, err := ws.coll.InsertOne(ctx, delivery)
+	return delivery, err
+}
+
+// ClaimDueDeliveries returns pending deliveries due at now and postpones them for the lease duration.
+// Every delivery is claimed with the atomic find and update, so concurrent servers never claim the same delivery.
+func (ws *WebhookDeliveryStorage) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*ws.timeout)
+	defer cancel()
+
+	q := bson.M{
+		"status":          model.WebhookDeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}})
+
+	due := []model.WebhookDelivery{}
+	for limit <= 0 || len(due) < limit {
+		var d model.WebhookDelivery
+		err := ws.coll.FindOneAndUpdate(ctx, q, update, opts).Decode(&d)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return due, err
+		}
+		due = append(due, d)
+	}
+	return due, nil
+}
+
+// UpdateDelivery replaces the delivery.
+func (ws *WebhookDeliveryStorage) UpdateDelivery(delivery model.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	res, err := ws.coll.ReplaceOne(ctx, bson.M{"_id": delivery.ID}, delivery)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return model.ErrorNotFound
+	}
+	return nil
+}
+
+// DeliveryByID returns the delivery by its ID.
+func (ws *WebhookDeliveryStorage) DeliveryByID(id string) (model.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	var d model.WebhookDelivery
+	err := ws.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.WebhookDelivery{}, model.ErrorNotFound
+	}
+	return d, err
+}
+
+// FetchDeliveries returns deliveries with the status, newest first.
+func (ws *WebhookDeliveryStorage) FetchDeliveries(status model.WebhookDeliveryStatus, skip, limit int) ([]model.WebhookDelivery, int, error) {
+	q := bson.M{}
+	if len(status) > 0 {
+		q["status"] = status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*ws.timeout)
+	defer cancel()
+
+	total, err := ws.coll.CountDocuments(ctx, q)
+	if err != nil {
+		return []model.WebhookDelivery{}, 0, err
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	findOptions.SetSkip(int64(skip))
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+
+	curr, err := ws.coll.Find(ctx, q, findOptions)
+	if err != nil {
+		return []model.WebhookDelivery{}, 0, err
+	}
+
+	deliveries := []model.WebhookDelivery{}
+	if err = curr.All(ctx, &deliveries); err != nil {
+		return []model.WebhookDelivery{}, 0, err
+	}
+	return deliveries, int(total), nil
+}
+
+// DeleteFinishedDeliveries deletes the delivered and dead deliveries finished before the time.
+func (ws *WebhookDeliveryStorage) DeleteFinishedDeliveries(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ws.timeout)
+	defer cancel()
+
+	res, err := ws.coll.DeleteMany(ctx, bson.M{
+		"status":          bson.M{"$in": []model.WebhookDeliveryStatus{model.WebhookDeliveryDelivered, model.WebhookDeliveryDead}},
+		"next_attempt_at": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+// Close is a no-op.
+func (ws *WebhookDeliveryStorage) Close() {}
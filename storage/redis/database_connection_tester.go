@@ -0,0 +1,28 @@
+package redis
+
+import (
+	"io"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+type ConnectionTester struct {
+	settings model.RedisDatabaseSettings
+}
+
+// NewConnectionTester creates a Redis connection tester.
+func NewConnectionTester(settings model.RedisDatabaseSettings) model.ConnectionTester {
+	return &ConnectionTester{settings: settings}
+}
+
+// Connect connects to Redis and pings it.
+func (ct *ConnectionTester) Connect() error {
+	client, _, err := newClient(ct.settings)
+	if err != nil {
+		return err
+	}
+	if c, ok := client.(io.Closer); ok {
+		c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	loginAttemptsKeyPrefix = "login_attempts:"
+
+	failuresField     = "failures"
+	lastFailedAtField = "last_failed_at"
+	expiresAtField    = "expires_at"
+)
+
+// LoginAttemptStorage is a Redis failed login attempts storage.
+// Every counter is a hash, which is expired by Redis.
+type LoginAttemptStorage struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewLoginAttemptStorage creates new Redis failed login attempts storage.
+func NewLoginAttemptStorage(settings model.RedisDatabaseSettings) (model.LoginAttemptStorage, error) {
+	client, p, err := newClient(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginAttemptStorage{
+		client: client,
+		prefix: p + loginAttemptsKeyPrefix,
+	}, nil
+}
+
+// LoginAttempts returns failed login attempts for the key.
+func (ls *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	attempts := model.LoginAttempts{Key: key}
+
+	fields, err := ls.client.HGetAll(ls.prefix + key).Result()
+	if err != nil {
+		return attempts, err
+	}
+	if len(fields) == 0 {
+		return attempts, nil
+	}
+
+	attempts.Failures, _ = strconv.Atoi(fields[failuresField])
+	attempts.LastFailedAt = parseUnixNano(fields[lastFailedAtField])
+	attempts.ExpiresAt = parseUnixNano(fields[expiresAtField])
+	return attempts, nil
+}
+
+// AddFailedAttempt increments failed login attempts for the key and prolongs their expiration.
+func (ls *LoginAttemptStorage) AddFailedAttempt(key string, expiresIn time.Duration) (model.LoginAttempts, error) {
+	now := time.Now()
+	attempts := model.LoginAttempts{
+		Key:          key,
+		LastFailedAt: now,
+		ExpiresAt:    now.Add(expiresIn),
+	}
+
+	var failures *redis.IntCmd
+	_, err := ls.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		failures = pipe.HIncrBy(ls.prefix+key, failuresField, 1)
+		pipe.HMSet(ls.prefix+key, map[string]interface{}{
+			lastFailedAtField: now.UnixNano(),
+			expiresAtField:    attempts.ExpiresAt.UnixNano(),
+		})
+		pipe.Expire(ls.prefix+key, expiresIn)
+		return nil
+	})
+	if err != nil {
+		return attempts, err
+	}
+
+	attempts.Failures = int(failures.Val())
+	return attempts, nil
+}
+
+// ResetLoginAttempts deletes failed login attempts for the key.
+func (ls *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	return ls.client.Del(ls.prefix + key).Err()
+}
+
+// Close closes connection to Redis.
+func (ls *LoginAttemptStorage) Close() {
+	if c, ok := ls.client.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func parseUnixNano(s string) time.Time {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -1,7 +1,56 @@
 package redis
 
+import (
+	"strings"
+
+	"github.com/go-redis/redis"
+	"github.com/madappgang/identifo/v2/model"
+)
+
 const (
 	defaultRedisAddress  = "localhost:6379"
 	defaultRedisPassword = ""
 	defaultRedisDB       = 0
 )
+
+// newClient connects to Redis server or cluster and returns the client with the keys prefix.
+func newClient(settings model.RedisDatabaseSettings) (redis.Cmdable, string, error) {
+	var addr, password string
+
+	if settings.Address == "" {
+		addr = defaultRedisAddress
+	} else {
+		addr = settings.Address
+	}
+
+	if settings.Password == "" {
+		password = defaultRedisPassword
+	} else {
+		password = settings.Password
+	}
+
+	var client redis.Cmdable
+
+	if settings.Cluster {
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    strings.Split(addr, ","),
+			Password: password,
+		})
+	} else {
+		client = redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       settings.DB,
+		})
+	}
+
+	if _, err := client.Ping().Result(); err != nil {
+		return nil, "", err
+	}
+
+	p := strings.TrimSpace(settings.Prefix)
+	if p != "" && !strings.HasSuffix(p, ":") {
+		p = p + ":"
+	}
+	return client, p, nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"io"
 	"log/slog"
-	"strings"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -24,45 +23,11 @@ func NewSessionStorage(
 	logger *slog.Logger,
 	settings model.RedisDatabaseSettings,
 ) (model.SessionStorage, error) {
-	var addr, password string
-	var db int
-
-	if settings.Address == "" {
-		addr = defaultRedisAddress
-	} else {
-		addr = settings.Address
-	}
-
-	if settings.Password == "" {
-		password = defaultRedisPassword
-	} else {
-		password = settings.Password
-	}
-
-	var client redis.Cmdable
-
-	if settings.Cluster {
-		client = redis.NewClusterClient(&redis.ClusterOptions{
-			Addrs:    strings.Split(settings.Address, ","),
-			Password: password,
-		})
-	} else {
-		client = redis.NewClient(&redis.Options{
-			Addr:     addr,
-			Password: password,
-			DB:       db,
-		})
-	}
-
-	if _, err := client.Ping().Result(); err != nil {
+	client, p, err := newClient(settings)
+	if err != nil {
 		return nil, err
 	}
 
-	p := strings.TrimSpace(settings.Prefix)
-	if p != "" && !strings.HasSuffix(settings.Prefix, ":") {
-		p = p + ":"
-	}
-
 	return &RedisSessionStorage{
 		logger: logger,
 		client: client,
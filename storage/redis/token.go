@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"io"
+	"time"
+
+	"github.com/go-redis/redis"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const tokensKeyPrefix = "tokens:"
+
+// TokenStorage is a Redis refresh token storage.
+// Every token is a key, which is expired by Redis when the token expires.
+type TokenStorage struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewTokenStorage creates new Redis token storage.
+func NewTokenStorage(settings model.RedisDatabaseSettings) (model.TokenStorage, error) {
+	client, p, err := newClient(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenStorage{
+		client: client,
+		prefix: p + tokensKeyPrefix,
+	}, nil
+}
+
+// SaveToken saves the token until it expires, the expired token is not saved.
+func (ts *TokenStorage) SaveToken(token string) error {
+	ttl, expired := tokenTTL(token)
+	if expired {
+		return nil
+	}
+	return ts.client.Set(ts.prefix+token, 1, ttl).Err()
+}
+
+// HasToken returns true if the token is in the storage.
+func (ts *TokenStorage) HasToken(token string) bool {
+	n, err := ts.client.Exists(ts.prefix + token).Result()
+	return err == nil && n > 0
+}
+
+// DeleteToken removes the token from the storage.
+func (ts *TokenStorage) DeleteToken(token string) error {
+	return ts.client.Del(ts.prefix + token).Err()
+}
+
+// Close closes connection to Redis.
+func (ts *TokenStorage) Close() {
+	if c, ok := ts.client.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// tokenTTL returns the time left until the token expires, zero means the token never expires.
+// The signature is not verified, the tokens come from the token service.
+// The token which is not JWT or has no expiration time is kept forever, as in the other storages.
+func tokenTTL(token string) (ttl time.Duration, expired bool) {
+	claims := &model.Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil || claims.ExpiresAt == 0 {
+		return 0, false
+	}
+
+	// Redis expiration has millisecond precision.
+	ttl = time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl < time.Millisecond {
+		return 0, true
+	}
+	return ttl, false
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"io"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const blacklistKeyPrefix = "blacklist:"
+
+// TokenBlacklist is a Redis token blacklist.
+// Every blacklisted token ID is a key, which is expired by Redis when the token expires.
+type TokenBlacklist struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewTokenBlacklist creates new Redis token blacklist.
+func NewTokenBlacklist(settings model.RedisDatabaseSettings) (model.TokenBlacklist, error) {
+	client, p, err := newClient(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenBlacklist{
+		client: client,
+		prefix: p + blacklistKeyPrefix,
+	}, nil
+}
+
+// IsBlacklisted returns true if the token is blacklisted.
+func (tb *TokenBlacklist) IsBlacklisted(id string) bool {
+	n, err := tb.client.Exists(tb.prefix + id).Result()
+	return err == nil && n > 0
+}
+
+// Add blacklists the token until it expires, the expired token is not added.
+func (tb *TokenBlacklist) Add(id string, expiresAt time.Time) error {
+	var ttl time.Duration
+	if !expiresAt.IsZero() {
+		// Redis expiration has millisecond precision.
+		if ttl = time.Until(expiresAt); ttl < time.Millisecond {
+			return nil
+		}
+	}
+	return tb.client.Set(tb.prefix+id, 1, ttl).Err()
+}
+
+// Close closes connection to Redis.
+func (tb *TokenBlacklist) Close() {
+	if c, ok := tb.client.(io.Closer); ok {
+		c.Close()
+	}
+}
@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testToken(t *testing.T, exp time.Time) string {
+	t.Helper()
+	claims := model.Claims{StandardClaims: jwt.StandardClaims{Subject: "user", ExpiresAt: exp.Unix()}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	return token
+}
+
+func TestTokenTTL(t *testing.T) {
+	ttl, expired := tokenTTL(testToken(t, time.Now().Add(time.Hour)))
+	assert.False(t, expired)
+	assert.InDelta(t, time.Hour.Seconds(), ttl.Seconds(), 2)
+
+	_, expired = tokenTTL(testToken(t, time.Now().Add(-time.Minute)))
+	assert.True(t, expired)
+
+	ttl, expired = tokenTTL("not a jwt")
+	assert.False(t, expired)
+	assert.Zero(t, ttl)
+}
+
+// testSettings returns the settings of Redis set by IDENTIFO_TEST_REDIS, the test is skipped if it is not set.
+func testSettings(t *testing.T) model.RedisDatabaseSettings {
+	addr := os.Getenv("IDENTIFO_TEST_REDIS")
+	if len(addr) == 0 {
+		t.Skip("IDENTIFO_TEST_REDIS is not set")
+	}
+	return model.RedisDatabaseSettings{Address: addr, Prefix: "identifo_test_" + time.Now().Format("150405.000")}
+}
+
+func TestTokenStorage(t *testing.T) {
+	ts, err := NewTokenStorage(testSettings(t))
+	require.NoError(t, err)
+	defer ts.Close()
+
+	token := testToken(t, time.Now().Add(time.Hour))
+	require.NoError(t, ts.SaveToken(token))
+	assert.True(t, ts.HasToken(token))
+	require.NoError(t, ts.DeleteToken(token))
+	assert.False(t, ts.HasToken(token))
+
+	expired := testToken(t, time.Now().Add(-time.Minute))
+	require.NoError(t, ts.SaveToken(expired))
+	assert.False(t, ts.HasToken(expired))
+}
+
+func TestTokenBlacklist(t *testing.T) {
+	tb, err := NewTokenBlacklist(testSettings(t))
+	require.NoError(t, err)
+	defer tb.Close()
+
+	require.NoError(t, tb.Add("jti", time.Now().Add(2*time.Second)))
+	assert.True(t, tb.IsBlacklisted("jti"))
+
+	// the entry is removed by Redis when the token expires.
+	time.Sleep(3 * time.Second)
+	assert.False(t, tb.IsBlacklisted("jti"))
+
+	require.NoError(t, tb.Add("expired", time.Now().Add(-time.Minute)))
+	assert.False(t, tb.IsBlacklisted("expired"))
+}
+
+func TestVerificationCodeStorage(t *testing.T) {
+	vcs, err := NewVerificationCodeStorage(testSettings(t))
+	require.NoError(t, err)
+	defer vcs.Close()
+
+	policy := model.VerificationCodePolicy{TTL: time.Minute, MaxAttempts: 2, ResendCooldown: time.Minute}
+	status, err := vcs.CreateVerificationCode("+61400000000", "123456", policy)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.AttemptsLeft)
+
+	status, err = vcs.VerifyCode("+61400000000", "000000")
+	assert.Equal(t, model.ErrorVerificationCodeInvalid, err)
+	assert.Equal(t, 1, status.AttemptsLeft)
+
+	_, err = vcs.VerifyCode("+61400000000", "123456")
+	require.NoError(t, err)
+
+	// the code is used once.
+	_, err = vcs.VerifyCode("+61400000000", "123456")
+	assert.Equal(t, model.ErrorVerificationCodeExpired, err)
+
+	// the code is used, but the new one could not be sent until the cooldown passes.
+	_, err = vcs.CreateVerificationCode("+61400000001", "123456", policy)
+	require.NoError(t, err)
+	status, err = vcs.CreateVerificationCode("+61400000001", "654321", policy)
+	assert.Equal(t, model.ErrorVerificationCodeCooldown, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), status.ResendAt, 5*time.Second)
+
+	for i := 0; i < 2; i++ {
+		_, err = vcs.VerifyCode("+61400000001", "000000")
+		assert.Equal(t, model.ErrorVerificationCodeInvalid, err)
+	}
+	_, err = vcs.VerifyCode("+61400000001", "123456")
+	assert.Equal(t, model.ErrorVerificationCodeAttemptsExceeded, err)
+}
@@ -0,0 +1,199 @@
+package redis
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+const (
+	userSessionKeyPrefix      = "user_session:"
+	userSessionsKeyPrefix     = "user_sessions:"
+	userSessionTokenKeyPrefix = "user_session_token:"
+)
+
+// UserSessionStorage is a Redis user session storage.
+// Every session is a JSON value expired by Redis, with the refresh token index key expired at the same time.
+// Session IDs of the user are kept in the set, the IDs of expired sessions are removed from it on read.
+type UserSessionStorage struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewUserSessionStorage creates new Redis user session storage.
+func NewUserSessionStorage(settings model.RedisDatabaseSettings) (model.UserSessionStorage, error) {
+	client, p, err := newClient(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserSessionStorage{
+		client: client,
+		prefix: p,
+	}, nil
+}
+
+func (us *UserSessionStorage) sessionKey(id string) string {
+	return us.prefix + userSessionKeyPrefix + id
+}
+
+func (us *UserSessionStorage) userKey(userID string) string {
+	return us.prefix + userSessionsKeyPrefix + userID
+}
+
+func (us *UserSessionStorage) tokenKey(tokenID string) string {
+	return us.prefix + userSessionTokenKeyPrefix + tokenID
+}
+
+// AddSession saves the new session.
+func (us *UserSessionStorage) AddSession(session model.UserSession) (model.UserSession, error) {
+	if len(session.ID) == 0 {
+		session.ID = xid.New().String()
+	}
+	if err := us.save(session, ""); err != nil {
+		return model.UserSession{}, err
+	}
+	return session, nil
+}
+
+// UpdateSession replaces the session.
+func (us *UserSessionStorage) UpdateSession(session model.UserSession) error {
+	existing, err := us.SessionByID(session.ID)
+	if err != nil {
+		return err
+	}
+	return us.save(session, existing.RefreshTokenID)
+}
+
+func (us *UserSessionStorage) save(session model.UserSession, prevTokenID string) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	_, err = us.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(us.sessionKey(session.ID), data, ttl)
+		if len(prevTokenID) > 0 && prevTokenID != session.RefreshTokenID {
+			pipe.Del(us.tokenKey(prevTokenID))
+		}
+		if len(session.RefreshTokenID) > 0 {
+			pipe.Set(us.tokenKey(session.RefreshTokenID), session.ID, ttl)
+		}
+		pipe.SAdd(us.userKey(session.UserID), session.ID)
+		return nil
+	})
+	return err
+}
+
+// SessionByID returns the session by its ID.
+func (us *UserSessionStorage) SessionByID(id string) (model.UserSession, error) {
+	data, err := us.client.Get(us.sessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	if err != nil {
+		return model.UserSession{}, err
+	}
+
+	var s model.UserSession
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+// SessionByRefreshTokenID returns the session by the jti of its refresh token.
+func (us *UserSessionStorage) SessionByRefreshTokenID(tokenID string) (model.UserSession, error) {
+	id, err := us.client.Get(us.tokenKey(tokenID)).Result()
+	if err == redis.Nil {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	if err != nil {
+		return model.UserSession{}, err
+	}
+	return us.SessionByID(id)
+}
+
+// UserSessions returns the sessions of the user, the latest refreshed first.
+func (us *UserSessionStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	ids, err := us.client.SMembers(us.userKey(userID)).Result()
+	if err != nil {
+		return []model.UserSession{}, err
+	}
+
+	sessions := []model.UserSession{}
+	expired := []interface{}{}
+	for _, id := range ids {
+		s, err := us.SessionByID(id)
+		if err == model.ErrorNotFound {
+			expired = append(expired, id)
+			continue
+		}
+		if err != nil {
+			return []model.UserSession{}, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if len(expired) > 0 {
+		if err := us.client.SRem(us.userKey(userID), expired...).Err(); err != nil {
+			return []model.UserSession{}, err
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastRefreshAt.After(sessions[j].LastRefreshAt)
+	})
+	return sessions, nil
+}
+
+// DeleteSession deletes the session.
+func (us *UserSessionStorage) DeleteSession(id string) error {
+	s, err := us.SessionByID(id)
+	if err != nil {
+		return err
+	}
+	return us.delete(s)
+}
+
+func (us *UserSessionStorage) delete(s model.UserSession) error {
+	_, err := us.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(us.sessionKey(s.ID))
+		if len(s.RefreshTokenID) > 0 {
+			pipe.Del(us.tokenKey(s.RefreshTokenID))
+		}
+		pipe.SRem(us.userKey(s.UserID), s.ID)
+		return nil
+	})
+	return err
+}
+
+// DeleteUserSessions deletes all sessions of the user.
+func (us *UserSessionStorage) DeleteUserSessions(userID string) (int, error) {
+	sessions, err := us.UserSessions(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, s := range sessions {
+		if err := us.delete(s); err != nil {
+			return i, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// Close closes connection to Redis.
+func (us *UserSessionStorage) Close() {
+	if c, ok := us.client.(io.Closer); ok {
+		c.Close()
+	}
+}
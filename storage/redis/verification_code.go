@@ -0,0 +1,136 @@
+package redis
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const verificationCodesKeyPrefix = "verification_codes:"
+
+// createCodeScript saves the code of the phone as the hash, unless the resend cooldown of the previous code has not passed.
+// The codes saved before the attempts were limited are strings, they are replaced.
+// It returns the status, expiration, resend time and the attempts left of the saved or the previous code.
+var createCodeScript = redis.NewScript(`
+if redis.call("TYPE", KEYS[1]).ok == "hash" then
+	local prev = redis.call("HMGET", KEYS[1], "expiresAt", "resendAt", "attemptsLeft")
+	if tonumber(prev[2]) > tonumber(ARGV[5]) then
+		return {"cooldown", prev[1], prev[2], prev[3]}
+	end
+end
+redis.call("DEL", KEYS[1])
+redis.call("HMSET", KEYS[1], "code", ARGV[1], "expiresAt", ARGV[2], "resendAt", ARGV[3], "attemptsLeft", ARGV[4])
+redis.call("PEXPIREAT", KEYS[1], ARGV[6])
+return {"ok", ARGV[2], ARGV[3], ARGV[4]}
+`)
+
+// verifyCodeScript deletes the code of the phone if it matches, so the code is used once, the mismatch uses the attempt.
+var verifyCodeScript = redis.NewScript(`
+if redis.call("TYPE", KEYS[1]).ok ~= "hash" then
+	return {"expired", "0", "0", "0"}
+end
+local c = redis.call("HMGET", KEYS[1], "code", "expiresAt", "resendAt", "attemptsLeft")
+if tonumber(c[2]) <= tonumber(ARGV[2]) then
+	return {"expired", c[2], c[3], c[4]}
+end
+if tonumber(c[4]) <= 0 then
+	return {"exceeded", c[2], c[3], c[4]}
+end
+if c[1] == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+	return {"ok", c[2], c[3], c[4]}
+end
+local left = redis.call("HINCRBY", KEYS[1], "attemptsLeft", -1)
+return {"invalid", c[2], c[3], tostring(left)}
+`)
+
+var verifyCodeResults = map[string]error{
+	"ok":       nil,
+	"invalid":  model.ErrorVerificationCodeInvalid,
+	"exceeded": model.ErrorVerificationCodeAttemptsExceeded,
+	"expired":  model.ErrorVerificationCodeExpired,
+	"cooldown": model.ErrorVerificationCodeCooldown,
+}
+
+// VerificationCodeStorage is a Redis verification code storage.
+// The code of the phone is a hash, which is expired by Redis when it is not needed anymore.
+// The times are stored in Unix milliseconds.
+type VerificationCodeStorage struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewVerificationCodeStorage creates new Redis verification code storage.
+func NewVerificationCodeStorage(settings model.RedisDatabaseSettings) (model.VerificationCodeStorage, error) {
+	client, p, err := newClient(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerificationCodeStorage{
+		client: client,
+		prefix: p + verificationCodesKeyPrefix,
+	}, nil
+}
+
+// CreateVerificationCode saves the new code of the phone, replacing the previous one.
+func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string, policy model.VerificationCodePolicy) (model.VerificationCodeStatus, error) {
+	now := time.Now()
+	vc := model.NewVerificationCode(phone, code, policy, now)
+
+	return runCodeScript(createCodeScript, vcs.client, vcs.prefix+phone,
+		code,
+		vc.ExpiresAt.UnixMilli(),
+		vc.ResendAt.UnixMilli(),
+		vc.AttemptsLeft,
+		now.UnixMilli(),
+		vc.DeleteAt().UnixMilli(),
+	)
+}
+
+// VerifyCode checks the code of the phone, the matching code is deleted.
+func (vcs *VerificationCodeStorage) VerifyCode(phone, code string) (model.VerificationCodeStatus, error) {
+	return runCodeScript(verifyCodeScript, vcs.client, vcs.prefix+phone, code, time.Now().UnixMilli())
+}
+
+// runCodeScript runs the script, which returns the result and the status fields of the code.
+func runCodeScript(script *redis.Script, client redis.Cmdable, key string, args ...interface{}) (model.VerificationCodeStatus, error) {
+	reply, err := script.Run(client, []string{key}, args...).Result()
+	if err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+
+	fields, ok := reply.([]interface{})
+	if !ok || len(fields) != 4 {
+		return model.VerificationCodeStatus{}, fmt.Errorf("unexpected verification code script reply %v", reply)
+	}
+	values := make([]int64, 3)
+	for i := range values {
+		s, _ := fields[i+1].(string)
+		if values[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+			return model.VerificationCodeStatus{}, fmt.Errorf("unexpected verification code script reply %v", reply)
+		}
+	}
+	result, _ := fields[0].(string)
+	resultErr, ok := verifyCodeResults[result]
+	if !ok {
+		return model.VerificationCodeStatus{}, fmt.Errorf("unexpected verification code script result %s", result)
+	}
+
+	return model.VerificationCodeStatus{
+		ExpiresAt:    time.UnixMilli(values[0]),
+		ResendAt:     time.UnixMilli(values[1]),
+		AttemptsLeft: int(values[2]),
+	}, resultErr
+}
+
+// Close closes connection to Redis.
+func (vcs *VerificationCodeStorage) Close() {
+	if c, ok := vcs.client.(io.Closer); ok {
+		c.Close()
+	}
+}
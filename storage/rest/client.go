@@ -1,20 +1,162 @@
 package rest
 
-// Error - domain level error type
-type Error string
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
-// Error - implementation of std.Error protocol
-func (e Error) Error() string { return string(e) }
+	"github.com/madappgang/identifo/v2/model"
+)
 
 const (
-	// ErrorInternalError internal error
-	ErrorInternalError = Error("internal error")
-	// ErrorInactiveUser means user is inactive
-	ErrorInactiveUser = Error("user is inactive")
-	// ErrorEmptyAppID means appID params is empty
-	ErrorEmptyAppID = Error("empty appID param")
-	// ErrorInactiveApp means app is inactive
-	ErrorInactiveApp = Error("app is inactive")
-	// ErrorEmptyEndpointRegion endpoint or region are settings
-	ErrorEmptyEndpointRegion = Error("endpoint and region required for dynamodb user storage")
+	// DigestHeader is the header with HMAC SHA-256 signature of the request timestamp and body.
+	DigestHeader = "Digest"
+	digestPrefix = "SHA-256="
+	// TimestampHeader is the header with the request time in Unix seconds, it is signed with the body,
+	// so the captured request could not be replayed later.
+	TimestampHeader = "X-Identifo-Timestamp"
+
+	defaultTimeout = 10 * time.Second
+	// exportPageSize is the number of the users fetched at once by the export.
+	exportPageSize = 100
 )
+
+var (
+	// RetryBackoff is the delay before the first retry, it doubles with every next retry.
+	RetryBackoff = 200 * time.Millisecond
+	// MaxClockSkew is how far the request timestamp could be from the server time.
+	MaxClockSkew = 5 * time.Minute
+)
+
+// errorResponse is the body of the user service error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// client calls the operations of the user service.
+// Every operation is a POST request with JSON body to the path of the operation, signed with the secret.
+type client struct {
+	baseURL string
+	secret  string
+	retries int
+	http    *http.Client
+}
+
+func newClient(settings model.RESTDatabaseSettings) (*client, error) {
+	if len(settings.URL) == 0 {
+		return nil, ErrorEmptyURL
+	}
+	if _, err := url.ParseRequestURI(settings.URL); err != nil {
+		return nil, fmt.Errorf("invalid REST user storage URL: %w", err)
+	}
+	if len(settings.Secret) < 5 {
+		return nil, ErrorShortSecret
+	}
+
+	timeout := defaultTimeout
+	if settings.Timeout > 0 {
+		timeout = time.Duration(settings.Timeout) * time.Second
+	}
+
+	return &client{
+		baseURL: strings.TrimSuffix(settings.URL, "/"),
+		secret:  settings.Secret,
+		retries: settings.Retries,
+		http:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Sign returns the signature of the timestamp and the body, the HMAC of "{timestamp}.{body}".
+func Sign(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "."))
+	h.Write(body)
+	return digestPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// call performs the operation and decodes the response into result, if it is not nil.
+// Idempotent operations are retried on network errors and on 429, 502, 503 and 504 responses.
+func (c *client) call(op string, idempotent bool, request, result any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	attempts := 1
+	if idempotent {
+		attempts += c.retries
+	}
+
+	backoff := RetryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := c.do(op, body, result)
+		if err == nil || !retry || attempt >= attempts {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// do sends the request once, it returns true if the request could be retried.
+func (c *client) do(op string, body []byte, result any) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+op, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// every attempt is signed with its own time.
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(DigestHeader, Sign(c.secret, timestamp, body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("REST user storage %s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("REST user storage %s: %w", op, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// 404 and 409 are the storage errors only with the error body of the contract,
+		// e.g. 404 of the wrong URL or the proxy is not the missing user.
+		e := errorResponse{}
+		contract := json.Unmarshal(data, &e) == nil && len(e.Error) > 0
+		switch {
+		case contract && resp.StatusCode == http.StatusNotFound:
+			return false, model.ErrUserNotFound
+		case contract && resp.StatusCode == http.StatusConflict:
+			return false, model.ErrorUserExists
+		}
+		if len(e.Error) == 0 {
+			e.Error = http.StatusText(resp.StatusCode)
+		}
+		retry := resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode == http.StatusBadGateway ||
+			resp.StatusCode == http.StatusServiceUnavailable ||
+			resp.StatusCode == http.StatusGatewayTimeout
+		return retry, fmt.Errorf("REST user storage %s, status %d: %w", op, resp.StatusCode, errors.New(e.Error))
+	}
+
+	if result == nil || len(data) == 0 {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return false, fmt.Errorf("REST user storage %s, invalid response: %w", op, err)
+	}
+	return false, nil
+}
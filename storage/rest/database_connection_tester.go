@@ -5,5 +5,19 @@ import (
 )
 
 type ConnectionTester struct {
-	settings model.DynamoDatabaseSettings
+	settings model.RESTDatabaseSettings
+}
+
+// NewConnectionTester creates a REST user storage connection tester.
+func NewConnectionTester(settings model.RESTDatabaseSettings) model.ConnectionTester {
+	return &ConnectionTester{settings: settings}
+}
+
+// Connect calls the ping operation of the user service, which checks the URL and the signature.
+func (ct *ConnectionTester) Connect() error {
+	c, err := newClient(ct.settings)
+	if err != nil {
+		return err
+	}
+	return c.call(OpPing, true, struct{}{}, nil)
 }
@@ -1 +1,18 @@
-package rest
\ No newline at end of file
+package rest
+
+// Error - domain level error type
+type Error string
+
+// Error - implementation of std.Error protocol
+func (e Error) Error() string { return string(e) }
+
+const (
+	// ErrorEmptyURL means the user service URL is not set
+	ErrorEmptyURL = Error("URL is required for REST user storage")
+	// ErrorShortSecret means the signing secret is empty or too short
+	ErrorShortSecret = Error("the secret for REST user storage should be at least 5 chars long")
+	// ErrorInvalidSignature means the request signature does not match the body
+	ErrorInvalidSignature = Error("invalid request signature")
+	// ErrorStaleTimestamp means the request timestamp is missing or too far from the server time
+	ErrorStaleTimestamp = Error("stale request timestamp")
+)
@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// Server is the reference implementation of the REST user storage contract over any user storage.
+// It could be used as a starting point of the user service, or to expose the existing storage to Identifo.
+type Server struct {
+	storage model.UserStorage
+	secret  string
+	mux     *http.ServeMux
+}
+
+// NewServer creates the REST user storage server, the requests are verified with the secret.
+func NewServer(storage model.UserStorage, secret string) *Server {
+	s := &Server{
+		storage: storage,
+		secret:  secret,
+		mux:     http.NewServeMux(),
+	}
+
+	handle(s, OpPing, func(struct{}) (any, error) {
+		return nil, nil
+	})
+	handle(s, OpUserByID, func(r userByIDRequest) (any, error) {
+		return s.storage.UserByID(r.ID)
+	})
+	handle(s, OpUserByEmail, func(r userByEmailRequest) (any, error) {
+		return s.storage.UserByEmail(r.Email)
+	})
+	handle(s, OpUserByPhone, func(r userByPhoneRequest) (any, error) {
+		return s.storage.UserByPhone(r.Phone)
+	})
+	handle(s, OpUserByUsername, func(r userByUsernameRequest) (any, error) {
+		return s.storage.UserByUsername(r.Username)
+	})
+	handle(s, OpUserByFederatedID, func(r federatedIDRequest) (any, error) {
+		return s.storage.UserByFederatedID(r.Provider, r.ID)
+	})
+	handle(s, OpAddUserWithPassword, func(r addUserWithPasswordRequest) (any, error) {
+		return s.storage.AddUserWithPassword(r.User, r.Password, r.Role, r.Anonymous)
+	})
+	handle(s, OpAddUserWithFederatedID, func(r addUserWithFederatedIDRequest) (any, error) {
+		return s.storage.AddUserWithFederatedID(r.User, r.Provider, r.ID, r.Role)
+	})
+	handle(s, OpUpdateUser, func(r updateUserRequest) (any, error) {
+		return s.storage.UpdateUser(r.ID, r.User)
+	})
+	handle(s, OpResetPassword, func(r passwordRequest) (any, error) {
+		return nil, s.storage.ResetPassword(r.ID, r.Password)
+	})
+	handle(s, OpCheckPassword, func(r passwordRequest) (any, error) {
+		return nil, s.storage.CheckPassword(r.ID, r.Password)
+	})
+	handle(s, OpDeleteUser, func(r userByIDRequest) (any, error) {
+		return nil, s.storage.DeleteUser(r.ID)
+	})
+	handle(s, OpFetchUsers, func(r fetchUsersRequest) (any, error) {
+		users, total, err := s.storage.FetchUsers(r.Search, r.Skip, r.Limit)
+		return fetchUsersResponse{Users: users, Total: total}, err
+	})
+	handle(s, OpUpdateLoginMetadata, func(r loginMetadataRequest) (any, error) {
+		s.storage.UpdateLoginMetadata(r.Operation, r.AppID, r.UserID, r.Scopes, r.Payload)
+		return nil, nil
+	})
+	handle(s, OpReplaceRecoveryCodes, func(r recoveryCodesRequest) (any, error) {
+		replaced, err := s.storage.ReplaceRecoveryCodes(r.ID, r.Old, r.Codes)
+		return recoveryCodesResponse{Replaced: replaced}, err
+	})
+	handle(s, OpAttachDeviceToken, func(r deviceTokenRequest) (any, error) {
+		return nil, s.storage.AttachDeviceToken(r.UserID, r.Token)
+	})
+	handle(s, OpDetachDeviceToken, func(r deviceTokenRequest) (any, error) {
+		return nil, s.storage.DetachDeviceToken(r.Token)
+	})
+	handle(s, OpAllDeviceTokens, func(r deviceTokenRequest) (any, error) {
+		tokens, err := s.storage.AllDeviceTokens(r.UserID)
+		return deviceTokensResponse{Tokens: tokens}, err
+	})
+	handle(s, OpImportJSON, func(r importRequest) (any, error) {
+		return nil, s.storage.ImportJSON(r.Data, r.ClearOldData)
+	})
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle registers the operation handler, which gets the decoded request and returns the response body.
+func handle[T any](s *Server, op string, h func(T) (any, error)) {
+	s.mux.HandleFunc(op, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		timestamp := r.Header.Get(TimestampHeader)
+		if !hmac.Equal([]byte(r.Header.Get(DigestHeader)), []byte(Sign(s.secret, timestamp, body))) {
+			writeError(w, http.StatusUnauthorized, ErrorInvalidSignature)
+			return
+		}
+		if !freshTimestamp(timestamp) {
+			writeError(w, http.StatusUnauthorized, ErrorStaleTimestamp)
+			return
+		}
+
+		var request T
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := h(request)
+		switch {
+		case errors.Is(err, model.ErrUserNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, model.ErrorUserExists):
+			writeError(w, http.StatusConflict, err)
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+		case result == nil:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(result)
+		}
+	})
+}
+
+// freshTimestamp returns true if the timestamp in Unix seconds is within MaxClockSkew of the server time.
+func freshTimestamp(timestamp string) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	d := time.Since(time.Unix(sec, 0))
+	return d < MaxClockSkew && d > -MaxClockSkew
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+}
@@ -1 +1,287 @@
-package rest
\ No newline at end of file
+package rest
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// Operations of the REST user storage contract, every operation is the path of the POST request.
+const (
+	OpPing                   = "/ping"
+	OpUserByID               = "/users/by_id"
+	OpUserByEmail            = "/users/by_email"
+	OpUserByPhone            = "/users/by_phone"
+	OpUserByUsername         = "/users/by_username"
+	OpUserByFederatedID      = "/users/by_federated_id"
+	OpAddUserWithPassword    = "/users/add_with_password"
+	OpAddUserWithFederatedID = "/users/add_with_federated_id"
+	OpUpdateUser             = "/users/update"
+	OpResetPassword          = "/users/reset_password"
+	OpCheckPassword          = "/users/check_password"
+	OpDeleteUser             = "/users/delete"
+	OpFetchUsers             = "/users/fetch"
+	OpUpdateLoginMetadata    = "/users/login_metadata"
+	OpReplaceRecoveryCodes   = "/users/replace_recovery_codes"
+	OpAttachDeviceToken      = "/devices/attach"
+	OpDetachDeviceToken      = "/devices/detach"
+	OpAllDeviceTokens        = "/devices/list"
+	OpImportJSON             = "/users/import"
+)
+
+type userByIDRequest struct {
+	ID string `json:"id"`
+}
+
+type userByEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type userByPhoneRequest struct {
+	Phone string `json:"phone"`
+}
+
+type userByUsernameRequest struct {
+	Username string `json:"username"`
+}
+
+type federatedIDRequest struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+type addUserWithPasswordRequest struct {
+	User      model.User `json:"user"`
+	Password  string     `json:"password"`
+	Role      string     `json:"role"`
+	Anonymous bool       `json:"anonymous"`
+}
+
+type addUserWithFederatedIDRequest struct {
+	User     model.User `json:"user"`
+	Provider string     `json:"provider"`
+	ID       string     `json:"id"`
+	Role     string     `json:"role"`
+}
+
+type updateUserRequest struct {
+	ID   string     `json:"id"`
+	User model.User `json:"user"`
+}
+
+type passwordRequest struct {
+	ID       string `json:"id"`
+	Password string `json:"password"`
+}
+
+type fetchUsersRequest struct {
+	Search string `json:"search"`
+	Skip   int    `json:"skip"`
+	Limit  int    `json:"limit"`
+}
+
+type fetchUsersResponse struct {
+	Users []model.User `json:"users"`
+	Total int          `json:"total"`
+}
+
+type loginMetadataRequest struct {
+	Operation string         `json:"operation"`
+	AppID     string         `json:"app_id"`
+	UserID    string         `json:"user_id"`
+	Scopes    []string       `json:"scopes"`
+	Payload   map[string]any `json:"payload"`
+}
+
+type recoveryCodesRequest struct {
+	ID    string   `json:"id"`
+	Old   []string `json:"old"`
+	Codes []string `json:"codes"`
+}
+
+type recoveryCodesResponse struct {
+	Replaced bool `json:"replaced"`
+}
+
+type deviceTokenRequest struct {
+	UserID string `json:"user_id,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+type deviceTokensResponse struct {
+	Tokens []string `json:"tokens"`
+}
+
+type importRequest struct {
+	Data         json.RawMessage `json:"data"`
+	ClearOldData bool            `json:"clear_old_data"`
+}
+
+// UserStorage is a user storage backed by the user service over HTTP/JSON.
+// The user service keeps the users and checks the passwords, so the passwords are sent in plain text,
+// please use HTTPS to reach the service.
+type UserStorage struct {
+	logger *slog.Logger
+	client *client
+}
+
+// NewUserStorage creates the REST user storage.
+func NewUserStorage(logger *slog.Logger, settings model.RESTDatabaseSettings) (model.UserStorage, error) {
+	c, err := newClient(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &UserStorage{logger: logger, client: c}, nil
+}
+
+func (us *UserStorage) user(op string, request any) (model.User, error) {
+	var user model.User
+	if err := us.client.call(op, true, request, &user); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// UserByID returns user by its ID.
+func (us *UserStorage) UserByID(id string) (model.User, error) {
+	return us.user(OpUserByID, userByIDRequest{ID: id})
+}
+
+// UserByEmail returns user by the email.
+func (us *UserStorage) UserByEmail(email string) (model.User, error) {
+	return us.user(OpUserByEmail, userByEmailRequest{Email: email})
+}
+
+// UserByPhone returns user by the phone number.
+func (us *UserStorage) UserByPhone(phone string) (model.User, error) {
+	return us.user(OpUserByPhone, userByPhoneRequest{Phone: phone})
+}
+
+// UserByUsername returns user by the username.
+func (us *UserStorage) UserByUsername(username string) (model.User, error) {
+	return us.user(OpUserByUsername, userByUsernameRequest{Username: username})
+}
+
+// UserByFederatedID returns user by federated ID.
+func (us *UserStorage) UserByFederatedID(provider string, id string) (model.User, error) {
+	return us.user(OpUserByFederatedID, federatedIDRequest{Provider: provider, ID: id})
+}
+
+// AddUserWithPassword creates new user with the password.
+func (us *UserStorage) AddUserWithPassword(user model.User, password, role string, isAnonymous bool) (model.User, error) {
+	var result model.User
+	err := us.client.call(OpAddUserWithPassword, false, addUserWithPasswordRequest{
+		User:      user,
+		Password:  password,
+		Role:      role,
+		Anonymous: isAnonymous,
+	}, &result)
+	return result, err
+}
+
+// AddUserWithFederatedID creates new user with the federated ID.
+func (us *UserStorage) AddUserWithFederatedID(user model.User, provider string, id, role string) (model.User, error) {
+	var result model.User
+	err := us.client.call(OpAddUserWithFederatedID, false, addUserWithFederatedIDRequest{
+		User:     user,
+		Provider: provider,
+		ID:       id,
+		Role:     role,
+	}, &result)
+	return result, err
+}
+
+// UpdateUser updates the user.
+func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User, error) {
+	var result model.User
+	err := us.client.call(OpUpdateUser, true, updateUserRequest{ID: userID, User: newUser}, &result)
+	return result, err
+}
+
+// ResetPassword sets new password of the user.
+func (us *UserStorage) ResetPassword(id, password string) error {
+	return us.client.call(OpResetPassword, true, passwordRequest{ID: id, Password: password}, nil)
+}
+
+// CheckPassword checks the password of the user, the user service responds with 404 for the wrong password.
+func (us *UserStorage) CheckPassword(id, password string) error {
+	return us.client.call(OpCheckPassword, true, passwordRequest{ID: id, Password: password}, nil)
+}
+
+// DeleteUser deletes the user.
+func (us *UserStorage) DeleteUser(id string) error {
+	return us.client.call(OpDeleteUser, true, userByIDRequest{ID: id}, nil)
+}
+
+// FetchUsers returns the users matching the search string and the total number of them.
+func (us *UserStorage) FetchUsers(search string, skip, limit int) ([]model.User, int, error) {
+	result := fetchUsersResponse{}
+	err := us.client.call(OpFetchUsers, true, fetchUsersRequest{Search: search, Skip: skip, Limit: limit}, &result)
+	if err != nil {
+		return []model.User{}, 0, err
+	}
+	if result.Users == nil {
+		result.Users = []model.User{}
+	}
+	return result.Users, result.Total, nil
+}
+
+// UpdateLoginMetadata sends the login metadata to the user service, the errors are logged only.
+func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes []string, payload map[string]any) {
+	err := us.client.call(OpUpdateLoginMetadata, true, loginMetadataRequest{
+		Operation: operation,
+		AppID:     app,
+		UserID:    userID,
+		Scopes:    scopes,
+		Payload:   payload,
+	}, nil)
+	if err != nil {
+		us.logger.Error("Unable to update login metadata",
+			logging.FieldUserID, userID,
+			logging.FieldError, err)
+	}
+}
+
+// ReplaceRecoveryCodes asks the user service to replace the recovery codes of the user if they are still the old ones.
+// It is not retried, the retry of the replaced codes would report them as changed.
+func (us *UserStorage) ReplaceRecoveryCodes(userID string, old, codes []string) (bool, error) {
+	result := recoveryCodesResponse{}
+	err := us.client.call(OpReplaceRecoveryCodes, false, recoveryCodesRequest{ID: userID, Old: old, Codes: codes}, &result)
+	return result.Replaced, err
+}
+
+// AttachDeviceToken attaches the push device token to the user.
+func (us *UserStorage) AttachDeviceToken(userID, token string) error {
+	return us.client.call(OpAttachDeviceToken, true, deviceTokenRequest{UserID: userID, Token: token}, nil)
+}
+
+// DetachDeviceToken detaches the push device token.
+func (us *UserStorage) DetachDeviceToken(token string) error {
+	return us.client.call(OpDetachDeviceToken, true, deviceTokenRequest{Token: token}, nil)
+}
+
+// AllDeviceTokens returns all push device tokens of the user.
+func (us *UserStorage) AllDeviceTokens(userID string) ([]string, error) {
+	result := deviceTokensResponse{}
+	if err := us.client.call(OpAllDeviceTokens, true, deviceTokenRequest{UserID: userID}, &result); err != nil {
+		return nil, err
+	}
+	return result.Tokens, nil
+}
+
+// ImportJSON imports users to the user service.
+func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
+	return us.client.call(OpImportJSON, false, importRequest{Data: data, ClearOldData: clearOldData}, nil)
+}
+
+// ExportJSON exports the users of the user service to JSON.
+// The users are fetched page by page, the password hashes are exported if the service returns them.
+func (us *UserStorage) ExportJSON(w io.Writer) error {
+	return model.ExportUsersByPages(us, w, exportPageSize)
+}
+
+// Close does nothing here.
+func (us *UserStorage) Close() {}
@@ -0,0 +1,207 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/madappgang/identifo/v2/storage/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "rest_secret"
+
+func testStorage(t *testing.T, url string, retries int) model.UserStorage {
+	storage, err := rest.NewUserStorage(logging.DefaultLogger, model.RESTDatabaseSettings{
+		URL:     url,
+		Secret:  testSecret,
+		Timeout: 1,
+		Retries: retries,
+	})
+	require.NoError(t, err)
+	return storage
+}
+
+func TestRESTUserStorageContract(t *testing.T) {
+	backend, err := mem.NewUserStorage()
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(rest.NewServer(backend, testSecret))
+	defer ts.Close()
+
+	storage := testStorage(t, ts.URL, 0)
+
+	user, err := storage.AddUserWithPassword(model.User{
+		Username: "rest_user",
+		Email:    "rest_user@example.com",
+		Phone:    "+15550000100",
+		Scopes:   []string{"chat"},
+	}, "qwerty", "user", false)
+	require.NoError(t, err)
+	require.NotEmpty(t, user.ID)
+
+	_, err = storage.AddUserWithPassword(model.User{Username: "rest_user"}, "qwerty", "user", false)
+	assert.ErrorIs(t, err, model.ErrorUserExists)
+
+	found, err := storage.UserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "rest_user", found.Username)
+
+	found, err = storage.UserByEmail("rest_user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	found, err = storage.UserByPhone("+15550000100")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	found, err = storage.UserByUsername("rest_user")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	_, err = storage.UserByID("missing")
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+
+	require.NoError(t, storage.CheckPassword(user.ID, "qwerty"))
+	assert.ErrorIs(t, storage.CheckPassword(user.ID, "wrong"), model.ErrUserNotFound)
+
+	require.NoError(t, storage.ResetPassword(user.ID, "new_password"))
+	require.NoError(t, storage.CheckPassword(user.ID, "new_password"))
+
+	user.FullName = "REST User"
+	updated, err := storage.UpdateUser(user.ID, user)
+	require.NoError(t, err)
+	assert.Equal(t, "REST User", updated.FullName)
+
+	federated, err := storage.AddUserWithFederatedID(model.User{Username: "rest_federated"}, "google", "123", "user")
+	require.NoError(t, err)
+	found, err = storage.UserByFederatedID("google", "123")
+	require.NoError(t, err)
+	assert.Equal(t, federated.ID, found.ID)
+
+	users, total, err := storage.FetchUsers("rest", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, users, 2)
+
+	storage.UpdateLoginMetadata("login_with_password", "app", user.ID, []string{"chat"}, nil)
+
+	replaced, err := storage.ReplaceRecoveryCodes(user.ID, []string{"other"}, []string{"code"})
+	require.NoError(t, err)
+	assert.False(t, replaced)
+	replaced, err = storage.ReplaceRecoveryCodes(user.ID, nil, []string{"code"})
+	require.NoError(t, err)
+	assert.True(t, replaced)
+
+	require.NoError(t, storage.AttachDeviceToken(user.ID, "device"))
+	require.NoError(t, storage.DetachDeviceToken("device"))
+	_, err = storage.AllDeviceTokens(user.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.ImportJSON([]byte(`[{"username":"rest_imported","pswd":"qwerty"}]`), false))
+	_, err = storage.UserByUsername("rest_imported")
+	require.NoError(t, err)
+
+	require.NoError(t, storage.DeleteUser(federated.ID))
+	_, err = storage.UserByID(federated.ID)
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+}
+
+func TestRESTUserStorageSignature(t *testing.T) {
+	backend, err := mem.NewUserStorage()
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(rest.NewServer(backend, "other_secret"))
+	defer ts.Close()
+
+	_, err = testStorage(t, ts.URL, 0).UserByID("id")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+
+	// the request is signed with the HMAC of the timestamp and the body
+	ping := func(timestamp time.Time) int {
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		r := httptest.NewRequest(http.MethodPost, rest.OpPing, strings.NewReader(`{}`))
+		r.Header.Set(rest.TimestampHeader, ts)
+		r.Header.Set(rest.DigestHeader, rest.Sign("other_secret", ts, []byte(`{}`)))
+		rw := httptest.NewRecorder()
+		rest.NewServer(backend, "other_secret").ServeHTTP(rw, r)
+		return rw.Code
+	}
+	assert.Equal(t, http.StatusNoContent, ping(time.Now()))
+	// the replayed request is rejected
+	assert.Equal(t, http.StatusUnauthorized, ping(time.Now().Add(-time.Hour)))
+	assert.Equal(t, http.StatusUnauthorized, ping(time.Now().Add(time.Hour)))
+}
+
+func TestRESTUserStorageNotFound(t *testing.T) {
+	// 404 without the error body of the contract, e.g. of the wrong URL, is not the missing user
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	_, err := testStorage(t, ts.URL, 0).UserByID("id")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, model.ErrUserNotFound)
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestRESTUserStorageRetries(t *testing.T) {
+	rest.RetryBackoff = time.Millisecond
+
+	backend, err := mem.NewUserStorage()
+	require.NoError(t, err)
+	server := rest.NewServer(backend, testSecret)
+
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	// idempotent requests are retried
+	_, err = testStorage(t, ts.URL, 2).UserByID("missing")
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// user creation is not retried
+	calls.Store(0)
+	_, err = testStorage(t, ts.URL, 2).AddUserWithPassword(model.User{Username: "retried"}, "qwerty", "user", false)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRESTUserStorageTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	_, err := testStorage(t, ts.URL, 0).UserByID("id")
+	require.Error(t, err)
+}
+
+func TestRESTConnectionTester(t *testing.T) {
+	backend, err := mem.NewUserStorage()
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(rest.NewServer(backend, testSecret))
+	defer ts.Close()
+
+	settings := model.RESTDatabaseSettings{URL: ts.URL, Secret: testSecret}
+	require.NoError(t, rest.NewConnectionTester(settings).Connect())
+
+	settings.Secret = "wrong_secret"
+	require.Error(t, rest.NewConnectionTester(settings).Connect())
+}
@@ -2,11 +2,15 @@ package s3
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"path"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/madappgang/identifo/v2/jwt"
 	"github.com/madappgang/identifo/v2/model"
@@ -87,3 +91,57 @@ func (ks *KeyStorage) LoadPrivateKey() (interface{}, error) {
 
 	return privateKey, nil
 }
+
+func (ks *KeyStorage) keyRingPath() string {
+	return path.Join(path.Dir(ks.privateKeyPath), model.KeyRingName)
+}
+
+// LoadKeyRing loads the signing keys ring, which is stored next to the private key.
+func (ks *KeyStorage) LoadKeyRing() (model.KeyRing, error) {
+	var ring model.KeyRing
+
+	resp, err := ks.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(ks.bucket),
+		Key:    aws.String(ks.keyRingPath()),
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return ring, model.ErrorNotFound
+		}
+		return ring, fmt.Errorf("cannot get %s from S3: %w", ks.keyRingPath(), err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&ring); err != nil {
+		return ring, fmt.Errorf("cannot decode key ring: %w", err)
+	}
+	return ring, nil
+}
+
+// SaveKeyRing saves the signing keys ring and replaces the private key with the active one.
+func (ks *KeyStorage) SaveKeyRing(ring model.KeyRing) error {
+	active, ok := ring.Active()
+	if !ok {
+		return fmt.Errorf("key ring has no active key")
+	}
+
+	data, err := json.Marshal(ring)
+	if err != nil {
+		return err
+	}
+
+	_, err = ks.client.PutObject(&s3.PutObjectInput{
+		Bucket:       aws.String(ks.bucket),
+		Key:          aws.String(ks.keyRingPath()),
+		ACL:          aws.String("private"),
+		StorageClass: aws.String(s3.ObjectStorageClassStandard),
+		Body:         bytes.NewReader(data),
+		ContentType:  aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot put %s to S3: %w", ks.keyRingPath(), err)
+	}
+
+	return ks.ReplaceKey([]byte(active.PrivateKey))
+}
@@ -0,0 +1,134 @@
+package sqldb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewAdminAccountStorage creates a SQL admin account storage.
+func NewAdminAccountStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.AdminAccountStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminAccountStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// AdminAccountStorage is a SQL admin account storage.
+// The login column is unique, it is never changed after the account is added.
+type AdminAccountStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// AdminAccounts returns all accounts ordered by login.
+func (as *AdminAccountStorage) AdminAccounts() ([]model.AdminAccount, error) {
+	rows, err := as.db.Query(`SELECT data FROM admin_accounts ORDER BY login`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []model.AdminAccount{}
+	for rows.Next() {
+		account, err := scanJSON[model.AdminAccount](rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// AdminAccountByID returns the account by its id.
+func (as *AdminAccountStorage) AdminAccountByID(id string) (model.AdminAccount, error) {
+	return as.accountWhere(`id = $1`, id)
+}
+
+// AdminAccountByLogin returns the account by its login.
+func (as *AdminAccountStorage) AdminAccountByLogin(login string) (model.AdminAccount, error) {
+	return as.accountWhere(`login = $1`, login)
+}
+
+// AddAdminAccount adds new account.
+func (as *AdminAccountStorage) AddAdminAccount(account model.AdminAccount) (model.AdminAccount, error) {
+	account.ID = xid.New().String()
+	data, err := json.Marshal(account)
+	if err != nil {
+		return model.AdminAccount{}, err
+	}
+
+	err = inTx(as.db, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM admin_accounts WHERE login = $1`, account.Login).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return model.ErrorAdminAccountExists
+		}
+
+		_, err := tx.Exec(`INSERT INTO admin_accounts (id, login, data) VALUES ($1, $2, $3)`, account.ID, account.Login, string(data))
+		return err
+	})
+	if err != nil {
+		return model.AdminAccount{}, err
+	}
+	return account, nil
+}
+
+// UpdateAdminAccount updates existing account, the stored login is kept.
+func (as *AdminAccountStorage) UpdateAdminAccount(account model.AdminAccount) error {
+	return inTx(as.db, func(tx *sql.Tx) error {
+		var login string
+		err := tx.QueryRow(`SELECT login FROM admin_accounts WHERE id = $1`, account.ID).Scan(&login)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrorNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		account.Login = login
+		data, err := json.Marshal(account)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE admin_accounts SET data = $1 WHERE id = $2`, string(data), account.ID)
+		return err
+	})
+}
+
+// DeleteAdminAccount deletes account by its id.
+func (as *AdminAccountStorage) DeleteAdminAccount(id string) error {
+	res, err := as.db.Exec(`DELETE FROM admin_accounts WHERE id = $1`, id)
+	return notFoundIfNoRows(res, err)
+}
+
+// Close closes underlying database.
+func (as *AdminAccountStorage) Close() {
+	if err := CloseDB(as.db); err != nil {
+		as.logger.Error("Error closing admin account storage", logging.FieldError, err)
+	}
+}
+
+func (as *AdminAccountStorage) accountWhere(where string, args ...any) (model.AdminAccount, error) {
+	account, err := scanJSON[model.AdminAccount](as.db.QueryRow(`SELECT data FROM admin_accounts WHERE `+where, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.AdminAccount{}, model.ErrorNotFound
+	}
+	return account, err
+}
@@ -0,0 +1,195 @@
+package sqldb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewAppStorage creates new SQL AppStorage implementation.
+func NewAppStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.AppStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AppStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// AppStorage is a SQL app storage.
+type AppStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// AppByID returns app by ID.
+func (as *AppStorage) AppByID(id string) (model.AppData, error) {
+	var data []byte
+	err := as.db.QueryRow(`SELECT data FROM apps WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.AppData{}, model.ErrorNotFound
+	}
+	if err != nil {
+		return model.AppData{}, err
+	}
+	return model.AppDataFromJSON(data)
+}
+
+// ActiveAppByID returns app by id only if it's active.
+func (as *AppStorage) ActiveAppByID(appID string) (model.AppData, error) {
+	if appID == "" {
+		return model.AppData{}, ErrorEmptyAppID
+	}
+
+	app, err := as.AppByID(appID)
+	if err != nil {
+		return model.AppData{}, err
+	}
+
+	if !app.Active {
+		return model.AppData{}, ErrorInactiveApp
+	}
+
+	return app, nil
+}
+
+// CreateApp creates new app, the app with the same ID is replaced.
+func (as *AppStorage) CreateApp(app model.AppData) (model.AppData, error) {
+	if len(app.ID) == 0 {
+		app.ID = xid.New().String()
+	}
+	return app, putApp(as.db, app)
+}
+
+// DisableApp disables app in the storage.
+func (as *AppStorage) DisableApp(app model.AppData) error {
+	app.Active = false
+	_, err := as.CreateApp(app)
+	return err
+}
+
+// UpdateApp updates app in the storage.
+func (as *AppStorage) UpdateApp(appID string, newApp model.AppData) (model.AppData, error) {
+	// use ID from the request if it's not set
+	if len(newApp.ID) == 0 {
+		newApp.ID = appID
+	}
+
+	err := inTx(as.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM apps WHERE id = $1`, appID); err != nil {
+			return err
+		}
+		return putApp(tx, newApp)
+	})
+	if err != nil {
+		return model.AppData{}, err
+	}
+
+	return as.AppByID(newApp.ID)
+}
+
+// FetchApps fetches apps which ID contains provided filterString.
+func (as *AppStorage) FetchApps(filterString string) ([]model.AppData, error) {
+	rows, err := as.db.Query(`SELECT data FROM apps WHERE LOWER(id) LIKE $1 ESCAPE '\' ORDER BY id`,
+		containsPattern(filterString))
+	if err != nil {
+		return []model.AppData{}, err
+	}
+	defer rows.Close()
+
+	apps := []model.AppData{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return []model.AppData{}, err
+		}
+		app, err := model.AppDataFromJSON(data)
+		if err != nil {
+			return []model.AppData{}, err
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return []model.AppData{}, err
+	}
+	return apps, nil
+}
+
+// DeleteApp deletes app by ID.
+func (as *AppStorage) DeleteApp(id string) error {
+	_, err := as.db.Exec(`DELETE FROM apps WHERE id = $1`, id)
+	return err
+}
+
+// TestDatabaseConnection checks whether we can query the apps table.
+func (as *AppStorage) TestDatabaseConnection() error {
+	var count int
+	return as.db.QueryRow(`SELECT COUNT(*) FROM apps`).Scan(&count)
+}
+
+// ImportJSON imports data from JSON.
+func (as *AppStorage) ImportJSON(data []byte, cleanOldData bool) error {
+	apd := []model.AppData{}
+	if err := json.Unmarshal(data, &apd); err != nil {
+		as.logger.Error("unmarshalling app data",
+			logging.FieldError, err)
+		return err
+	}
+
+	return inTx(as.db, func(tx *sql.Tx) error {
+		if cleanOldData {
+			if _, err := tx.Exec(`DELETE FROM apps`); err != nil {
+				return err
+			}
+		}
+		for _, a := range apd {
+			if len(a.ID) == 0 {
+				a.ID = xid.New().String()
+			}
+			if err := putApp(tx, a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExportJSON exports all apps to JSON.
+func (as *AppStorage) ExportJSON(w io.Writer) error {
+	return exportRows(as.db, w, `SELECT data FROM apps ORDER BY id`, scanJSON[model.AppData])
+}
+
+// ExportsSnapshot reports that the export is read in a single read-only transaction.
+func (as *AppStorage) ExportsSnapshot() bool {
+	return true
+}
+
+// Close closes underlying database.
+func (as *AppStorage) Close() {
+	if err := CloseDB(as.db); err != nil {
+		as.logger.Error("Error closing app storage", logging.FieldError, err)
+	}
+}
+
+func putApp(e execer, app model.AppData) error {
+	data, err := json.Marshal(app)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(`INSERT INTO apps (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, app.ID, string(data))
+	return err
+}
@@ -0,0 +1,74 @@
+package sqldb_test
+
+import (
+	"testing"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			apps, err := sqldb.NewAppStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer apps.Close()
+
+			require.NoError(t, apps.ImportJSON([]byte(`[]`), true))
+
+			a, err := apps.CreateApp(model.AppData{ID: "test_app", Name: "Test App", Active: true})
+			require.NoError(t, err)
+			b, err := apps.CreateApp(model.AppData{Name: "Other App"})
+			require.NoError(t, err)
+			assert.NotEmpty(t, b.ID)
+
+			found, err := apps.ActiveAppByID(a.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "Test App", found.Name)
+
+			_, err = apps.ActiveAppByID(b.ID)
+			assert.ErrorIs(t, err, sqldb.ErrorInactiveApp)
+			_, err = apps.AppByID("unknown")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+
+			// the filter is case insensitive and escapes the LIKE wildcards.
+			filtered, err := apps.FetchApps("T_APP")
+			require.NoError(t, err)
+			require.Len(t, filtered, 1)
+			assert.Equal(t, a.ID, filtered[0].ID)
+			filtered, err = apps.FetchApps("%")
+			require.NoError(t, err)
+			assert.Empty(t, filtered)
+			all, err := apps.FetchApps("")
+			require.NoError(t, err)
+			assert.Len(t, all, 2)
+
+			a.Name = "Renamed App"
+			a.ID = "renamed_app"
+			updated, err := apps.UpdateApp("test_app", a)
+			require.NoError(t, err)
+			assert.Equal(t, "Renamed App", updated.Name)
+			_, err = apps.AppByID("test_app")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+
+			require.NoError(t, apps.DisableApp(updated))
+			_, err = apps.ActiveAppByID(updated.ID)
+			assert.ErrorIs(t, err, sqldb.ErrorInactiveApp)
+
+			require.NoError(t, apps.DeleteApp(b.ID))
+			all, err = apps.FetchApps("")
+			require.NoError(t, err)
+			assert.Len(t, all, 1)
+
+			require.NoError(t, apps.ImportJSON([]byte(`[{"id":"imported","active":true}]`), true))
+			all, err = apps.FetchApps("")
+			require.NoError(t, err)
+			require.Len(t, all, 1)
+			assert.Equal(t, "imported", all[0].ID)
+			require.NoError(t, apps.TestDatabaseConnection())
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package sqldb
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewAuditStorage creates a SQL audit storage.
+func NewAuditStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.AuditStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuditStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// AuditStorage is a SQL audit storage.
+// The columns used by the filter are copied out of the stored record.
+type AuditStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// AddRecord appends the record to the log.
+func (as *AuditStorage) AddRecord(record model.AuditRecord) error {
+	record.ID = xid.New().String()
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = as.db.Exec(`INSERT INTO audit_records (id, recorded_at, user_id, app_id, operation, data) VALUES ($1, $2, $3, $4, $5, $6)`,
+		record.ID, unixNano(record.Time), record.UserID, record.AppID, string(record.Operation), string(data))
+	return err
+}
+
+// FetchRecords returns the records matching the filter, newest first.
+func (as *AuditStorage) FetchRecords(filter model.AuditFilter) ([]model.AuditRecord, int, error) {
+	conditions := []string{}
+	args := []any{}
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, placeholders(condition, len(args)-1))
+	}
+	if len(filter.UserID) > 0 {
+		add(`user_id = $?`, filter.UserID)
+	}
+	if len(filter.AppID) > 0 {
+		add(`app_id = $?`, filter.AppID)
+	}
+	if len(filter.Operation) > 0 {
+		add(`operation = $?`, string(filter.Operation))
+	}
+	if !filter.From.IsZero() {
+		add(`recorded_at >= $?`, unixNano(filter.From))
+	}
+	if !filter.To.IsZero() {
+		add(`recorded_at < $?`, unixNano(filter.To))
+	}
+
+	where := ``
+	if len(conditions) > 0 {
+		where = ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+
+	var total int
+	if err := as.db.QueryRow(`SELECT COUNT(*) FROM audit_records`+where, args...).Scan(&total); err != nil {
+		return []model.AuditRecord{}, 0, err
+	}
+
+	page, args := limitOffset(args, filter.Skip, filter.Limit)
+	rows, err := as.db.Query(`SELECT data FROM audit_records`+where+` ORDER BY recorded_at DESC, id DESC`+page, args...)
+	if err != nil {
+		return []model.AuditRecord{}, 0, err
+	}
+	defer rows.Close()
+
+	records := []model.AuditRecord{}
+	for rows.Next() {
+		record, err := scanJSON[model.AuditRecord](rows)
+		if err != nil {
+			return []model.AuditRecord{}, 0, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return []model.AuditRecord{}, 0, err
+	}
+	return records, total, nil
+}
+
+// Close closes underlying database.
+func (as *AuditStorage) Close() {
+	if err := CloseDB(as.db); err != nil {
+		as.logger.Error("Error closing audit storage", logging.FieldError, err)
+	}
+}
@@ -0,0 +1,26 @@
+package sqldb
+
+import (
+	"github.com/madappgang/identifo/v2/model"
+)
+
+type ConnectionTester struct {
+	dbType   model.DatabaseType
+	settings model.SQLDatabaseSettings
+}
+
+// NewConnectionTester creates a SQL database connection tester.
+func NewConnectionTester(dbType model.DatabaseType, settings model.SQLDatabaseSettings) model.ConnectionTester {
+	return &ConnectionTester{dbType: dbType, settings: settings}
+}
+
+// Connect opens the database, applies the schema migrations and pings it.
+func (ct *ConnectionTester) Connect() error {
+	db, err := InitDB(ct.dbType, ct.settings)
+	if err != nil {
+		return err
+	}
+	defer CloseDB(db)
+
+	return db.Ping()
+}
@@ -0,0 +1,89 @@
+package sqldb
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/madappgang/identifo/v2/model"
+
+	// Database drivers registered in database/sql.
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// driverNames maps the database type to the database/sql driver name.
+var driverNames = map[model.DatabaseType]string{
+	model.DBTypePostgres: "pgx",
+	model.DBTypeSQLite:   "sqlite",
+}
+
+// pool is a pool of shared database connections.
+// All storages with the same connection string share the same *sql.DB and schema migrations run once, when it is opened.
+var (
+	poolMu sync.Mutex
+	pool   = map[string]*sharedDB{}
+)
+
+type sharedDB struct {
+	db      *sql.DB
+	key     string
+	counter int
+}
+
+// DB is a shared database connection.
+type DB struct {
+	*sql.DB
+	shared *sharedDB
+}
+
+// InitDB opens the database and migrates its schema to the latest version.
+func InitDB(dbType model.DatabaseType, settings model.SQLDatabaseSettings) (*DB, error) {
+	if len(settings.ConnectionString) == 0 {
+		return nil, ErrorEmptyConnectionString
+	}
+	driver, ok := driverNames[dbType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrorUnsupportedDatabase, dbType)
+	}
+
+	poolMu.Lock()
+	defer poolMu.Unlock()
+
+	key := driver + ":" + settings.ConnectionString
+	if s, ok := pool[key]; ok {
+		s.counter++
+		return &DB{DB: s.db, shared: s}, nil
+	}
+
+	db, err := sql.Open(driver, settings.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+	if dbType == model.DBTypeSQLite {
+		// SQLite allows a single writer, serialize access to avoid "database is locked" errors.
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &sharedDB{db: db, key: key, counter: 1}
+	pool[key] = s
+	return &DB{DB: db, shared: s}, nil
+}
+
+// CloseDB releases the database, the connection is closed when the last storage releases it.
+func CloseDB(db *DB) error {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+
+	db.shared.counter--
+	if db.shared.counter > 0 {
+		return nil
+	}
+	delete(pool, db.shared.key)
+	return db.shared.db.Close()
+}
@@ -0,0 +1,14 @@
+package sqldb
+
+// Error - domain level error type
+type Error string
+
+// Error - implementation of std.Error protocol
+func (e Error) Error() string { return string(e) }
+
+const (
+	ErrorEmptyConnectionString = Error("unable to init SQL storage with empty connection string")
+	ErrorUnsupportedDatabase   = Error("unsupported SQL database type")
+	ErrorEmptyAppID            = Error("empty appID param") // ErrorEmptyAppID means appID params is empty
+	ErrorInactiveApp           = Error("app is inactive")   // ErrorInactiveApp means app is inactive
+)
@@ -0,0 +1,206 @@
+package sqldb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewInviteStorage creates a SQL invites storage.
+func NewInviteStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.InviteStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InviteStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// InviteStorage is a SQL invite storage.
+// The archived column is the source of truth for the archived flag of the stored invite document.
+type InviteStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// Save creates and saves new invite to a database.
+func (is *InviteStorage) Save(email, inviteToken, role, appID, createdBy string, expiresAt time.Time) error {
+	invite := model.Invite{
+		ID:        xid.New().String(),
+		AppID:     appID,
+		Token:     inviteToken,
+		Archived:  false,
+		Email:     email,
+		Role:      role,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := invite.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(invite)
+	if err != nil {
+		return err
+	}
+
+	_, err = is.db.Exec(`INSERT INTO invites (id, email, archived, created_at, expires_at, data) VALUES ($1, $2, $3, $4, $5, $6)`,
+		invite.ID, invite.Email, invite.Archived, invite.CreatedAt.Unix(), invite.ExpiresAt.Unix(), string(data))
+	return err
+}
+
+// GetByEmail returns valid and not expired invite by email.
+func (is *InviteStorage) GetByEmail(email string) (model.Invite, error) {
+	return is.inviteWhere(`email = $1 AND archived = $2 AND expires_at > $3 ORDER BY created_at DESC`,
+		email, false, time.Now().Unix())
+}
+
+// GetByID returns invite by its ID.
+func (is *InviteStorage) GetByID(id string) (model.Invite, error) {
+	return is.inviteWhere(`id = $1`, id)
+}
+
+// GetAll returns all active invites by default.
+// To get an invalid invites need to set withInvalid argument to true.
+func (is *InviteStorage) GetAll(withArchived bool, skip, limit int) ([]model.Invite, int, error) {
+	where := ``
+	args := []any{}
+	if !withArchived {
+		where = ` WHERE archived = $1`
+		args = append(args, false)
+	}
+
+	var total int
+	if err := is.db.QueryRow(`SELECT COUNT(*) FROM invites`+where, args...).Scan(&total); err != nil {
+		return []model.Invite{}, 0, err
+	}
+
+	page, args := limitOffset(args, skip, limit)
+	rows, err := is.db.Query(`SELECT archived, data FROM invites`+where+` ORDER BY created_at`+page, args...)
+	if err != nil {
+		return []model.Invite{}, 0, err
+	}
+	defer rows.Close()
+
+	invites := []model.Invite{}
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return []model.Invite{}, 0, err
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return []model.Invite{}, 0, err
+	}
+	return invites, total, nil
+}
+
+// ArchiveAllByEmail invalidates all invites by email.
+func (is *InviteStorage) ArchiveAllByEmail(email string) error {
+	_, err := is.db.Exec(`UPDATE invites SET archived = $1 WHERE email = $2`, true, email)
+	return err
+}
+
+// ArchiveByID invalidates specific invite by its ID.
+func (is *InviteStorage) ArchiveByID(id string) error {
+	_, err := is.db.Exec(`UPDATE invites SET archived = $1 WHERE id = $2`, true, id)
+	return err
+}
+
+// ImportJSON imports invites from JSON.
+func (is *InviteStorage) ImportJSON(data []byte, clearOldData bool) error {
+	invites := []model.Invite{}
+	if err := json.Unmarshal(data, &invites); err != nil {
+		return err
+	}
+
+	return inTx(is.db, func(tx *sql.Tx) error {
+		if clearOldData {
+			if _, err := tx.Exec(`DELETE FROM invites`); err != nil {
+				return err
+			}
+		}
+		for _, invite := range invites {
+			if len(invite.ID) == 0 {
+				invite.ID = xid.New().String()
+			}
+			if err := putInvite(tx, invite); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExportJSON exports all invites to JSON.
+func (is *InviteStorage) ExportJSON(w io.Writer) error {
+	return exportRows(is.db, w, `SELECT archived, data FROM invites ORDER BY created_at`, scanInvite)
+}
+
+// ExportsSnapshot reports that the export is read in a single read-only transaction.
+func (is *InviteStorage) ExportsSnapshot() bool {
+	return true
+}
+
+// Close closes underlying database.
+func (is *InviteStorage) Close() {
+	if err := CloseDB(is.db); err != nil {
+		is.logger.Error("Error closing invite storage", logging.FieldError, err)
+	}
+}
+
+func (is *InviteStorage) inviteWhere(where string, args ...any) (model.Invite, error) {
+	invite, err := scanInvite(is.db.QueryRow(`SELECT archived, data FROM invites WHERE `+where+` LIMIT 1`, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Invite{}, model.ErrorNotFound
+	}
+	return invite, err
+}
+
+// putInvite upserts the invite.
+func putInvite(db execer, invite model.Invite) error {
+	data, err := json.Marshal(invite)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT INTO invites (id, email, archived, created_at, expires_at, data) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET email = excluded.email, archived = excluded.archived,
+		created_at = excluded.created_at, expires_at = excluded.expires_at, data = excluded.data`,
+		invite.ID, invite.Email, invite.Archived, invite.CreatedAt.Unix(), invite.ExpiresAt.Unix(), string(data))
+	return err
+}
+
+func scanInvite(row scanner) (model.Invite, error) {
+	var (
+		archived bool
+		data     []byte
+	)
+	if err := row.Scan(&archived, &data); err != nil {
+		return model.Invite{}, err
+	}
+
+	var invite model.Invite
+	if err := json.Unmarshal(data, &invite); err != nil {
+		return model.Invite{}, err
+	}
+	invite.Archived = archived
+	return invite, nil
+}
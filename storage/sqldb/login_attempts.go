@@ -0,0 +1,83 @@
+package sqldb
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// NewLoginAttemptStorage creates a SQL login attempts storage.
+func NewLoginAttemptStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.LoginAttemptStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginAttemptStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// LoginAttemptStorage is a SQL login attempts storage.
+// The expired counter is kept until the next failed attempt starts it over, or it is reset.
+type LoginAttemptStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// LoginAttempts returns failed login attempts for the key.
+func (ls *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	attempts, err := scanLoginAttempts(ls.db.QueryRow(`SELECT attempt_key, failures, last_failed_at, expires_at FROM login_attempts
+		WHERE attempt_key = $1 AND expires_at > $2`, key, time.Now().UnixNano()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.LoginAttempts{Key: key}, nil
+	}
+	return attempts, err
+}
+
+// AddFailedAttempt increments failed login attempts for the key in one statement, so concurrent failures are all counted.
+func (ls *LoginAttemptStorage) AddFailedAttempt(key string, expiresIn time.Duration) (model.LoginAttempts, error) {
+	now := time.Now()
+	return scanLoginAttempts(ls.db.QueryRow(`INSERT INTO login_attempts (attempt_key, failures, last_failed_at, expires_at)
+		VALUES ($1, 1, $2, $3)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+		failures = CASE WHEN login_attempts.expires_at > excluded.last_failed_at THEN login_attempts.failures + 1 ELSE 1 END,
+		last_failed_at = excluded.last_failed_at, expires_at = excluded.expires_at
+		RETURNING attempt_key, failures, last_failed_at, expires_at`,
+		key, now.UnixNano(), now.Add(expiresIn).UnixNano()))
+}
+
+// ResetLoginAttempts deletes failed login attempts for the key.
+func (ls *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	_, err := ls.db.Exec(`DELETE FROM login_attempts WHERE attempt_key = $1`, key)
+	return err
+}
+
+// Close closes underlying database.
+func (ls *LoginAttemptStorage) Close() {
+	if err := CloseDB(ls.db); err != nil {
+		ls.logger.Error("Error closing login attempts storage", logging.FieldError, err)
+	}
+}
+
+func scanLoginAttempts(row scanner) (model.LoginAttempts, error) {
+	var (
+		attempts                model.LoginAttempts
+		lastFailedAt, expiresAt int64
+	)
+	if err := row.Scan(&attempts.Key, &attempts.Failures, &lastFailedAt, &expiresAt); err != nil {
+		return model.LoginAttempts{}, err
+	}
+	attempts.LastFailedAt = time.Unix(0, lastFailedAt)
+	attempts.ExpiresAt = time.Unix(0, expiresAt)
+	return attempts, nil
+}
@@ -0,0 +1,171 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// NewManagementKeysStorage creates a SQL management keys storage.
+func NewManagementKeysStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.ManagementKeysStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ManagementKeysStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// ManagementKeysStorage is a SQL management keys storage.
+type ManagementKeysStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+func (ms *ManagementKeysStorage) GetKey(ctx context.Context, id string) (model.ManagementKey, error) {
+	var data []byte
+	err := ms.db.QueryRowContext(ctx, `SELECT data FROM management_keys WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ManagementKey{}, model.ErrorNotFound
+	}
+	if err != nil {
+		return model.ManagementKey{}, err
+	}
+
+	var key model.ManagementKey
+	err = json.Unmarshal(data, &key)
+	return key, err
+}
+
+func (ms *ManagementKeysStorage) CreateKey(ctx context.Context, name string, scopes []string) (model.ManagementKey, error) {
+	key := model.ManagementKey{
+		Name:      name,
+		Scopes:    scopes,
+		ID:        uuid.New().String(),
+		Active:    true,
+		CreatedAt: time.Now(),
+		LastUsed:  time.Now(),
+	}
+	return key, putKey(ms.db, key)
+}
+
+func (ms *ManagementKeysStorage) DisableKey(ctx context.Context, id string) (model.ManagementKey, error) {
+	return ms.updateKey(ctx, id, func(key *model.ManagementKey) {
+		key.Active = false
+	})
+}
+
+func (ms *ManagementKeysStorage) RenameKey(ctx context.Context, id, name string) (model.ManagementKey, error) {
+	return ms.updateKey(ctx, id, func(key *model.ManagementKey) {
+		key.Name = name
+	})
+}
+
+func (ms *ManagementKeysStorage) ChangeScopesForKey(ctx context.Context, id string, scopes []string) (model.ManagementKey, error) {
+	return ms.updateKey(ctx, id, func(key *model.ManagementKey) {
+		key.Scopes = scopes
+	})
+}
+
+func (ms *ManagementKeysStorage) UseKey(ctx context.Context, id string) (model.ManagementKey, error) {
+	return ms.updateKey(ctx, id, func(key *model.ManagementKey) {
+		key.LastUsed = time.Now()
+	})
+}
+
+func (ms *ManagementKeysStorage) GeyAllKeys(ctx context.Context) ([]model.ManagementKey, error) {
+	rows, err := ms.db.QueryContext(ctx, `SELECT data FROM management_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []model.ManagementKey{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var key model.ManagementKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (ms *ManagementKeysStorage) ImportJSON(data []byte, cleanOldData bool) error {
+	keys := []model.ManagementKey{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+
+	return inTx(ms.db, func(tx *sql.Tx) error {
+		if cleanOldData {
+			if _, err := tx.Exec(`DELETE FROM management_keys`); err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			if err := putKey(tx, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ms *ManagementKeysStorage) ExportJSON(w io.Writer) error {
+	return exportRows(ms.db, w, `SELECT data FROM management_keys ORDER BY id`, scanJSON[model.ManagementKey])
+}
+
+// ExportsSnapshot reports that the export is read in a single read-only transaction.
+func (ms *ManagementKeysStorage) ExportsSnapshot() bool {
+	return true
+}
+
+// Close closes underlying database.
+func (ms *ManagementKeysStorage) Close() {
+	if err := CloseDB(ms.db); err != nil {
+		ms.logger.Error("Error closing management keys storage", logging.FieldError, err)
+	}
+}
+
+func (ms *ManagementKeysStorage) updateKey(ctx context.Context, id string, update func(key *model.ManagementKey)) (model.ManagementKey, error) {
+	key, err := ms.GetKey(ctx, id)
+	if err != nil {
+		return key, err
+	}
+
+	update(&key)
+	return key, putKey(ms.db, key)
+}
+
+func putKey(e execer, key model.ManagementKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(`INSERT INTO management_keys (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, key.ID, string(data))
+	return err
+}
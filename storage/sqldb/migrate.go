@@ -0,0 +1,89 @@
+package sqldb
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// migrations are applied in the order of the file names, each in its own transaction.
+// The applied versions are stored in the schema_migrations table, never edit a released migration, add a new one instead.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version TEXT PRIMARY KEY,
+	applied_at BIGINT NOT NULL
+)`
+
+// Migrations returns the names of the embedded migrations in the order they are applied.
+func Migrations() ([]string, error) {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range names {
+		names[i] = strings.TrimSuffix(strings.TrimPrefix(n, "migrations/"), ".sql")
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(createMigrationsTable); err != nil {
+		return fmt.Errorf("unable to create schema migrations table: %w", err)
+	}
+
+	versions, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if err := applyMigration(db, version); err != nil {
+			return fmt.Errorf("unable to apply migration %s: %w", version, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, version string) error {
+	script, err := migrations.ReadFile("migrations/" + version + ".sql")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	// Statements are executed one by one, as not every driver supports several statements in one call.
+	for _, stmt := range strings.Split(string(script), ";") {
+		if len(strings.TrimSpace(stmt)) == 0 {
+			continue
+		}
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`, version, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,55 @@
+-- Initial schema. The documents are stored as JSON in the data column,
+-- the columns used for lookups are copied out of the document.
+
+CREATE TABLE IF NOT EXISTS apps (
+	id TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	username TEXT NOT NULL DEFAULT '',
+	email TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	data TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS users_username_idx ON users (LOWER(username));
+CREATE INDEX IF NOT EXISTS users_email_idx ON users (email);
+CREATE INDEX IF NOT EXISTS users_phone_idx ON users (phone);
+
+CREATE TABLE IF NOT EXISTS user_federated_ids (
+	federated_id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_federated_ids_user_id_idx ON user_federated_ids (user_id);
+
+CREATE TABLE IF NOT EXISTS tokens (
+	token TEXT PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS blacklisted_tokens (
+	token TEXT PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS invites (
+	id TEXT PRIMARY KEY,
+	email TEXT NOT NULL,
+	archived BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	data TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS invites_email_idx ON invites (email);
+
+CREATE TABLE IF NOT EXISTS verification_codes (
+	phone TEXT PRIMARY KEY,
+	code TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS management_keys (
+	id TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
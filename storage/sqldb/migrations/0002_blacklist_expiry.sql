@@ -0,0 +1,5 @@
+-- The blacklisted tokens are stored by the token ID and removed when they expire,
+-- NULL expires_at means the token never expires.
+ALTER TABLE blacklisted_tokens ADD COLUMN expires_at BIGINT;
+
+CREATE INDEX IF NOT EXISTS blacklisted_tokens_expires_at_idx ON blacklisted_tokens (expires_at);
@@ -0,0 +1,5 @@
+-- The verification codes expire, have the limited attempts and the resend cooldown, the times are Unix seconds.
+-- The codes saved before have no expiration, so they are expired.
+ALTER TABLE verification_codes ADD COLUMN expires_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE verification_codes ADD COLUMN resend_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE verification_codes ADD COLUMN attempts_left INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,57 @@
+-- The storages of WebAuthn credentials, audit log, webhook deliveries, user sessions, login attempts and admin accounts.
+-- The times used for ordering and expiration are Unix nanoseconds.
+
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	data TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_id_idx ON webauthn_credentials (user_id);
+
+CREATE TABLE IF NOT EXISTS audit_records (
+	id TEXT PRIMARY KEY,
+	recorded_at BIGINT NOT NULL,
+	user_id TEXT NOT NULL DEFAULT '',
+	app_id TEXT NOT NULL DEFAULT '',
+	operation TEXT NOT NULL,
+	data TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_records_recorded_at_idx ON audit_records (recorded_at);
+CREATE INDEX IF NOT EXISTS audit_records_user_id_idx ON audit_records (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id TEXT PRIMARY KEY,
+	status TEXT NOT NULL,
+	next_attempt_at BIGINT NOT NULL,
+	data TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_idx ON webhook_deliveries (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	refresh_token_id TEXT NOT NULL,
+	last_refresh_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	data TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_id_idx ON user_sessions (user_id);
+CREATE INDEX IF NOT EXISTS user_sessions_refresh_token_id_idx ON user_sessions (refresh_token_id);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+	attempt_key TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL,
+	last_failed_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS admin_accounts (
+	id TEXT PRIMARY KEY,
+	login TEXT NOT NULL UNIQUE,
+	data TEXT NOT NULL
+);
@@ -0,0 +1,8 @@
+-- The sessions of the WebAuthn ceremonies in progress, the expiration time is Unix nanoseconds.
+CREATE TABLE IF NOT EXISTS webauthn_sessions (
+	id TEXT PRIMARY KEY,
+	expires_at BIGINT NOT NULL,
+	data TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_sessions_expires_at_idx ON webauthn_sessions (expires_at);
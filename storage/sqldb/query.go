@@ -0,0 +1,169 @@
+package sqldb
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// inTx runs f in the transaction, which is committed if f succeeds and rolled back otherwise.
+// The database must not be used inside f, as SQLite has the single connection only.
+func inTx(db *DB, f func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// exportRows writes the rows of the query as JSON array, scanned one by one.
+// The rows are read in the read-only repeatable read transaction, so the export is a consistent snapshot,
+// SQLite transactions are serializable anyway.
+// The rows are buffered to the temporary file and written after the transaction ends,
+// so the slow writer, e.g. the HTTP response, does not hold the connection, which is the only one for SQLite.
+func exportRows[T any](db *DB, w io.Writer, query string, scan func(row scanner) (T, error)) error {
+	f, err := os.CreateTemp("", "identifo-export-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	if err := readRows(db, bw, query, scan); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// readRows writes the rows of the query as JSON array in the read-only transaction.
+func readRows[T any](db *DB, w io.Writer, query string, scan func(row scanner) (T, error)) error {
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	aw := model.NewJSONArrayWriter(w)
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := aw.Write(item); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// scanJSON scans the single JSON data column.
+func scanJSON[T any](row scanner) (T, error) {
+	var (
+		item T
+		data []byte
+	)
+	if err := row.Scan(&data); err != nil {
+		return item, err
+	}
+	err := json.Unmarshal(data, &item)
+	return item, err
+}
+
+// placeholders numbers the "$?" placeholders of the query part, starting after the given number of the arguments.
+func placeholders(query string, after int) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(query, "$?")
+		if i < 0 {
+			b.WriteString(query)
+			return b.String()
+		}
+		after++
+		b.WriteString(query[:i+1])
+		b.WriteString(strconv.Itoa(after))
+		query = query[i+2:]
+	}
+}
+
+// limitOffset returns the pagination query part, starting after the given number of the arguments.
+// Zero limit means no limit.
+func limitOffset(args []any, skip, limit int) (string, []any) {
+	if limit <= 0 && skip <= 0 {
+		return "", args
+	}
+	if limit <= 0 {
+		// LIMIT ALL is not supported by SQLite, use the maximal value instead.
+		limit = 1 << 62
+	}
+	return placeholders(` LIMIT $? OFFSET $?`, len(args)), append(args, limit, skip)
+}
+
+// containsPattern returns the case insensitive LIKE pattern to match the values containing the string.
+func containsPattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(s))
+	return "%" + s + "%"
+}
+
+// notFoundIfNoRows returns model.ErrorNotFound if the statement has changed no rows.
+func notFoundIfNoRows(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return model.ErrorNotFound
+	}
+	return nil
+}
+
+// unixNano returns the time as Unix nanoseconds for the columns used in ordering and comparisons.
+// The zero time is out of the int64 range, it is stored as zero.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,103 @@
+package sqldb_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDatabases returns the databases to run the tests against: the new SQLite database
+// and PostgreSQL, if IDENTIFO_TEST_POSTGRES is set to its connection string.
+func testDatabases(t *testing.T) map[model.DatabaseType]model.SQLDatabaseSettings {
+	dbs := map[model.DatabaseType]model.SQLDatabaseSettings{
+		model.DBTypeSQLite: {ConnectionString: filepath.Join(t.TempDir(), "identifo.db")},
+	}
+	if pg := os.Getenv("IDENTIFO_TEST_POSTGRES"); len(pg) > 0 {
+		dbs[model.DBTypePostgres] = model.SQLDatabaseSettings{ConnectionString: pg}
+	}
+	return dbs
+}
+
+func TestMigrationsAppliedOnce(t *testing.T) {
+	settings := model.SQLDatabaseSettings{ConnectionString: filepath.Join(t.TempDir(), "identifo.db")}
+
+	versions, err := sqldb.Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, versions)
+
+	for i := 0; i < 2; i++ {
+		db, err := sqldb.InitDB(model.DBTypeSQLite, settings)
+		require.NoError(t, err)
+
+		var applied int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied))
+		assert.Equal(t, len(versions), applied)
+		require.NoError(t, sqldb.CloseDB(db))
+	}
+}
+
+func TestSharedDB(t *testing.T) {
+	settings := model.SQLDatabaseSettings{ConnectionString: filepath.Join(t.TempDir(), "identifo.db")}
+
+	db1, err := sqldb.InitDB(model.DBTypeSQLite, settings)
+	require.NoError(t, err)
+	db2, err := sqldb.InitDB(model.DBTypeSQLite, settings)
+	require.NoError(t, err)
+	assert.Same(t, db1.DB, db2.DB)
+
+	require.NoError(t, sqldb.CloseDB(db1))
+	// the connection is still used by the second storage.
+	require.NoError(t, db2.Ping())
+	require.NoError(t, sqldb.CloseDB(db2))
+}
+
+func TestUnsupportedDatabase(t *testing.T) {
+	_, err := sqldb.InitDB(model.DBTypeMongoDB, model.SQLDatabaseSettings{ConnectionString: "file:test.db"})
+	assert.ErrorIs(t, err, sqldb.ErrorUnsupportedDatabase)
+
+	_, err = sqldb.InitDB(model.DBTypeSQLite, model.SQLDatabaseSettings{})
+	assert.ErrorIs(t, err, sqldb.ErrorEmptyConnectionString)
+}
+
+// writerFunc is the writer, which calls the function on every write.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestExportReleasesConnection(t *testing.T) {
+	settings := model.SQLDatabaseSettings{ConnectionString: filepath.Join(t.TempDir(), "identifo.db")}
+	apps, err := sqldb.NewAppStorage(logging.DefaultLogger, model.DBTypeSQLite, settings)
+	require.NoError(t, err)
+	defer apps.Close()
+
+	_, err = apps.CreateApp(model.AppData{ID: "test_app", Name: "Test App", Active: true})
+	require.NoError(t, err)
+
+	// the slow writer, e.g. the backup download, does not block the single SQLite connection.
+	var out bytes.Buffer
+	require.NoError(t, apps.ExportJSON(writerFunc(func(p []byte) (int, error) {
+		done := make(chan error, 1)
+		go func() {
+			_, err := apps.AppByID("test_app")
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the database is blocked by the export")
+		}
+		return out.Write(p)
+	})))
+	assert.Contains(t, out.String(), "test_app")
+}
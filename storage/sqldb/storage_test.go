@@ -0,0 +1,405 @@
+package sqldb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenStorages(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			tokens, err := sqldb.NewTokenStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer tokens.Close()
+
+			require.NoError(t, tokens.SaveToken("token"))
+			require.NoError(t, tokens.SaveToken("token"))
+			assert.True(t, tokens.HasToken("token"))
+			require.NoError(t, tokens.DeleteToken("token"))
+			assert.False(t, tokens.HasToken("token"))
+
+			blacklist, err := sqldb.NewTokenBlacklist(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer blacklist.Close()
+
+			assert.False(t, blacklist.IsBlacklisted("jti"))
+			require.NoError(t, blacklist.Add("jti", time.Now().Add(time.Hour)))
+			require.NoError(t, blacklist.Add("jti", time.Now().Add(time.Hour)))
+			assert.True(t, blacklist.IsBlacklisted("jti"))
+			require.NoError(t, blacklist.Add("forever", time.Time{}))
+			assert.True(t, blacklist.IsBlacklisted("forever"))
+
+			// the expired token is not kept.
+			require.NoError(t, blacklist.Add("expired", time.Now().Add(-time.Minute)))
+			assert.False(t, blacklist.IsBlacklisted("expired"))
+		})
+	}
+}
+
+func TestVerificationCodeStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			codes, err := sqldb.NewVerificationCodeStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer codes.Close()
+
+			policy := model.VerificationCodePolicy{TTL: time.Minute, MaxAttempts: 2}
+			_, err = codes.CreateVerificationCode("+15550000001", "111111", policy)
+			require.NoError(t, err)
+			// the new code replaces the previous one.
+			_, err = codes.CreateVerificationCode("+15550000001", "222222", policy)
+			require.NoError(t, err)
+
+			status, err := codes.VerifyCode("+15550000001", "111111")
+			assert.Equal(t, model.ErrorVerificationCodeInvalid, err)
+			assert.Equal(t, 1, status.AttemptsLeft)
+			_, err = codes.VerifyCode("+15550000001", "222222")
+			require.NoError(t, err)
+			// the code could be used only once.
+			_, err = codes.VerifyCode("+15550000001", "222222")
+			assert.Equal(t, model.ErrorVerificationCodeExpired, err)
+
+			// the code is not accepted after all the attempts are used.
+			policy.ResendCooldown = time.Minute
+			_, err = codes.CreateVerificationCode("+15550000001", "333333", policy)
+			require.NoError(t, err)
+			for i := 0; i < 2; i++ {
+				_, err = codes.VerifyCode("+15550000001", "000000")
+				assert.Equal(t, model.ErrorVerificationCodeInvalid, err)
+			}
+			_, err = codes.VerifyCode("+15550000001", "333333")
+			assert.Equal(t, model.ErrorVerificationCodeAttemptsExceeded, err)
+
+			// the new code could not be sent until the cooldown passes.
+			status, err = codes.CreateVerificationCode("+15550000001", "444444", policy)
+			assert.Equal(t, model.ErrorVerificationCodeCooldown, err)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), status.ResendAt, 5*time.Second)
+		})
+	}
+}
+
+func TestInviteStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			invites, err := sqldb.NewInviteStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer invites.Close()
+
+			expiresAt := time.Now().Add(time.Hour)
+			require.NoError(t, invites.Save("a@example.com", "token1", "user", "app", "admin", expiresAt))
+			require.NoError(t, invites.Save("a@example.com", "token2", "user", "app", "admin", time.Now().Add(-time.Hour)))
+			require.NoError(t, invites.Save("b@example.com", "token3", "user", "app", "admin", expiresAt))
+
+			invite, err := invites.GetByEmail("a@example.com")
+			require.NoError(t, err)
+			assert.Equal(t, "token1", invite.Token)
+
+			found, err := invites.GetByID(invite.ID)
+			require.NoError(t, err)
+			assert.Equal(t, invite.Token, found.Token)
+
+			all, total, err := invites.GetAll(false, 0, 2)
+			require.NoError(t, err)
+			assert.Equal(t, 3, total)
+			assert.Len(t, all, 2)
+
+			require.NoError(t, invites.ArchiveAllByEmail("a@example.com"))
+			_, err = invites.GetByEmail("a@example.com")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+
+			found, err = invites.GetByID(invite.ID)
+			require.NoError(t, err)
+			assert.True(t, found.Archived)
+
+			all, total, err = invites.GetAll(false, 0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			require.Len(t, all, 1)
+			require.NoError(t, invites.ArchiveByID(all[0].ID))
+
+			_, total, err = invites.GetAll(false, 0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 0, total)
+			_, total, err = invites.GetAll(true, 0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 3, total)
+		})
+	}
+}
+
+func TestManagementKeysStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			ctx := context.Background()
+
+			keys, err := sqldb.NewManagementKeysStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+
+			key, err := keys.CreateKey(ctx, "key", []string{"read"})
+			require.NoError(t, err)
+
+			_, err = keys.RenameKey(ctx, key.ID, "renamed")
+			require.NoError(t, err)
+			_, err = keys.ChangeScopesForKey(ctx, key.ID, []string{"write"})
+			require.NoError(t, err)
+			_, err = keys.DisableKey(ctx, key.ID)
+			require.NoError(t, err)
+
+			found, err := keys.GetKey(ctx, key.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "renamed", found.Name)
+			assert.Equal(t, []string{"write"}, found.Scopes)
+			assert.False(t, found.Active)
+
+			_, err = keys.GetKey(ctx, "unknown")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+
+			require.NoError(t, keys.ImportJSON([]byte(`[{"id":"imported","name":"imported","active":true}]`), true))
+			all, err := keys.GeyAllKeys(ctx)
+			require.NoError(t, err)
+			require.Len(t, all, 1)
+			assert.Equal(t, "imported", all[0].ID)
+		})
+	}
+}
+
+func TestWebAuthnStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			credentials, err := sqldb.NewWebAuthnStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer credentials.Close()
+
+			now := time.Now()
+			require.NoError(t, credentials.AddCredential(model.WebAuthnCredential{ID: "second", UserID: "user", CreatedAt: now}))
+			require.NoError(t, credentials.AddCredential(model.WebAuthnCredential{ID: "first", UserID: "user", CreatedAt: now.Add(-time.Minute)}))
+			assert.ErrorIs(t, credentials.AddCredential(model.WebAuthnCredential{ID: "first", UserID: "other"}), model.ErrorWrongDataFormat)
+
+			list, err := credentials.CredentialsByUserID("user")
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, "first", list[0].ID)
+
+			list[0].SignCount = 5
+			require.NoError(t, credentials.UpdateCredential(list[0]))
+			found, err := credentials.CredentialByID("first")
+			require.NoError(t, err)
+			assert.Equal(t, uint32(5), found.SignCount)
+
+			require.NoError(t, credentials.DeleteCredential("first"))
+			_, err = credentials.CredentialByID("first")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+			assert.ErrorIs(t, credentials.DeleteCredential("first"), model.ErrorNotFound)
+			assert.ErrorIs(t, credentials.UpdateCredential(found), model.ErrorNotFound)
+
+			// the session is taken once, the expired one is not taken
+			require.NoError(t, credentials.SaveSession(model.WebAuthnSession{ID: "expired", Data: "{}", ExpiresAt: now.Add(-time.Second)}))
+			require.NoError(t, credentials.SaveSession(model.WebAuthnSession{ID: "session", Data: "{}", ExpiresAt: now.Add(time.Minute)}))
+			session, err := credentials.TakeSession("session")
+			require.NoError(t, err)
+			assert.Equal(t, "{}", session.Data)
+			_, err = credentials.TakeSession("session")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+			_, err = credentials.TakeSession("expired")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+		})
+	}
+}
+
+func TestAuditStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			audit, err := sqldb.NewAuditStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer audit.Close()
+
+			now := time.Now()
+			require.NoError(t, audit.AddRecord(model.AuditRecord{Time: now.Add(-time.Hour), UserID: "user", Operation: model.AuditOperationLogout}))
+			require.NoError(t, audit.AddRecord(model.AuditRecord{Time: now, UserID: "user", AppID: "app", Operation: model.AuditOperationLoginWithPassword}))
+			require.NoError(t, audit.AddRecord(model.AuditRecord{Time: now, UserID: "other", Operation: model.AuditOperationLoginWithPassword}))
+
+			records, total, err := audit.FetchRecords(model.AuditFilter{UserID: "user"})
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			require.Len(t, records, 2)
+			assert.Equal(t, model.AuditOperationLoginWithPassword, records[0].Operation, "newest first")
+
+			records, total, err = audit.FetchRecords(model.AuditFilter{Operation: model.AuditOperationLoginWithPassword, Limit: 1})
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Len(t, records, 1)
+
+			_, total, err = audit.FetchRecords(model.AuditFilter{From: now.Add(-time.Minute), To: now.Add(time.Minute), AppID: "app"})
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+		})
+	}
+}
+
+func TestWebhookDeliveryStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			deliveries, err := sqldb.NewWebhookDeliveryStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer deliveries.Close()
+
+			now := time.Now()
+			due, err := deliveries.AddDelivery(model.WebhookDelivery{URL: "https://example.com", Status: model.WebhookDeliveryPending, NextAttemptAt: now.Add(-time.Second)})
+			require.NoError(t, err)
+			_, err = deliveries.AddDelivery(model.WebhookDelivery{URL: "https://example.com", Status: model.WebhookDeliveryPending, NextAttemptAt: now.Add(time.Hour)})
+			require.NoError(t, err)
+
+			claimed, err := deliveries.ClaimDueDeliveries(now, time.Minute, 10)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+			assert.Equal(t, due.ID, claimed[0].ID)
+
+			// the claimed delivery is postponed for the lease
+			claimed, err = deliveries.ClaimDueDeliveries(now, time.Minute, 10)
+			require.NoError(t, err)
+			assert.Empty(t, claimed)
+
+			due.Status = model.WebhookDeliveryDead
+			require.NoError(t, deliveries.UpdateDelivery(due))
+			found, err := deliveries.DeliveryByID(due.ID)
+			require.NoError(t, err)
+			assert.Equal(t, model.WebhookDeliveryDead, found.Status)
+
+			list, total, err := deliveries.FetchDeliveries(model.WebhookDeliveryPending, 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			assert.Len(t, list, 1)
+
+			_, total, err = deliveries.FetchDeliveries("", 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+
+			// the dead delivery is deleted after the retention, the pending one is kept
+			deleted, err := deliveries.DeleteFinishedDeliveries(now.Add(-time.Minute))
+			require.NoError(t, err)
+			assert.Zero(t, deleted)
+			deleted, err = deliveries.DeleteFinishedDeliveries(now)
+			require.NoError(t, err)
+			assert.Equal(t, 1, deleted)
+			_, total, err = deliveries.FetchDeliveries("", 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+
+			_, err = deliveries.DeliveryByID("unknown")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+		})
+	}
+}
+
+func TestUserSessionStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			sessions, err := sqldb.NewUserSessionStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer sessions.Close()
+
+			now := time.Now()
+			first, err := sessions.AddSession(model.UserSession{UserID: "user", RefreshTokenID: "rt1", LastRefreshAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)})
+			require.NoError(t, err)
+			require.NotEmpty(t, first.ID)
+			_, err = sessions.AddSession(model.UserSession{UserID: "user", RefreshTokenID: "rt2", LastRefreshAt: now, ExpiresAt: now.Add(time.Hour)})
+			require.NoError(t, err)
+			_, err = sessions.AddSession(model.UserSession{UserID: "user", RefreshTokenID: "rt3", ExpiresAt: now.Add(-time.Minute)})
+			require.NoError(t, err)
+
+			list, err := sessions.UserSessions("user")
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, "rt2", list[0].RefreshTokenID, "the latest refreshed first")
+
+			first.RefreshTokenID = "rt4"
+			require.NoError(t, sessions.UpdateSession(first))
+			found, err := sessions.SessionByRefreshTokenID("rt4")
+			require.NoError(t, err)
+			assert.Equal(t, first.ID, found.ID)
+			_, err = sessions.SessionByRefreshTokenID("rt3")
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+
+			require.NoError(t, sessions.DeleteSession(first.ID))
+			_, err = sessions.SessionByID(first.ID)
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+
+			deleted, err := sessions.DeleteUserSessions("user")
+			require.NoError(t, err)
+			assert.Equal(t, 1, deleted)
+		})
+	}
+}
+
+func TestLoginAttemptStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			attempts, err := sqldb.NewLoginAttemptStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer attempts.Close()
+
+			a, err := attempts.LoginAttempts("account:user")
+			require.NoError(t, err)
+			assert.Equal(t, model.LoginAttempts{Key: "account:user"}, a)
+
+			_, err = attempts.AddFailedAttempt("account:user", time.Minute)
+			require.NoError(t, err)
+			a, err = attempts.AddFailedAttempt("account:user", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, 2, a.Failures)
+			assert.True(t, a.ExpiresAt.After(time.Now()))
+
+			// the expired counter starts over
+			_, err = attempts.AddFailedAttempt("ip:127.0.0.1", -time.Minute)
+			require.NoError(t, err)
+			a, err = attempts.AddFailedAttempt("ip:127.0.0.1", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, 1, a.Failures)
+
+			require.NoError(t, attempts.ResetLoginAttempts("account:user"))
+			a, err = attempts.LoginAttempts("account:user")
+			require.NoError(t, err)
+			assert.Zero(t, a.Failures)
+		})
+	}
+}
+
+func TestAdminAccountStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			accounts, err := sqldb.NewAdminAccountStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer accounts.Close()
+
+			admin, err := accounts.AddAdminAccount(model.AdminAccount{Login: "admin", Role: model.AdminRoleSuperadmin, Active: true})
+			require.NoError(t, err)
+			require.NotEmpty(t, admin.ID)
+			_, err = accounts.AddAdminAccount(model.AdminAccount{Login: "admin"})
+			assert.ErrorIs(t, err, model.ErrorAdminAccountExists)
+
+			// the login is not changed
+			admin.Login = "renamed"
+			admin.Role = model.AdminRoleViewer
+			require.NoError(t, accounts.UpdateAdminAccount(admin))
+			found, err := accounts.AdminAccountByLogin("admin")
+			require.NoError(t, err)
+			assert.Equal(t, model.AdminRoleViewer, found.Role)
+
+			list, err := accounts.AdminAccounts()
+			require.NoError(t, err)
+			assert.Len(t, list, 1)
+
+			require.NoError(t, accounts.DeleteAdminAccount(admin.ID))
+			_, err = accounts.AdminAccountByID(admin.ID)
+			assert.ErrorIs(t, err, model.ErrorNotFound)
+			assert.ErrorIs(t, accounts.UpdateAdminAccount(admin), model.ErrorNotFound)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package sqldb
+
+import (
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// NewTokenStorage creates a SQL token storage.
+func NewTokenStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.TokenStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// TokenStorage is a SQL token storage.
+type TokenStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// SaveToken saves token in the storage.
+func (ts *TokenStorage) SaveToken(token string) error {
+	_, err := ts.db.Exec(`INSERT INTO tokens (token) VALUES ($1) ON CONFLICT (token) DO NOTHING`, token)
+	return err
+}
+
+// HasToken returns true if the token is present in the storage.
+func (ts *TokenStorage) HasToken(token string) bool {
+	var count int
+	if err := ts.db.QueryRow(`SELECT COUNT(*) FROM tokens WHERE token = $1`, token).Scan(&count); err != nil {
+		ts.logger.Error("Error checking token", logging.FieldError, err)
+		return false
+	}
+	return count > 0
+}
+
+// DeleteToken removes token from the storage.
+func (ts *TokenStorage) DeleteToken(token string) error {
+	_, err := ts.db.Exec(`DELETE FROM tokens WHERE token = $1`, token)
+	return err
+}
+
+// Close closes underlying database.
+func (ts *TokenStorage) Close() {
+	if err := CloseDB(ts.db); err != nil {
+		ts.logger.Error("Error closing token storage", logging.FieldError, err)
+	}
+}
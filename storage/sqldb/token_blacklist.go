@@ -0,0 +1,146 @@
+package sqldb
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// blacklistSweepInterval is how often the expired tokens are removed from the blacklist.
+const blacklistSweepInterval = time.Hour
+
+// NewTokenBlacklist creates a token blacklist in SQL database.
+// The expired tokens are removed right away and then periodically, until the blacklist is closed.
+func NewTokenBlacklist(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.TokenBlacklist, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	tb := &TokenBlacklist{
+		logger: logger,
+		db:     db,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := tb.sweep(); err != nil {
+		CloseDB(db)
+		return nil, err
+	}
+	go tb.sweepPeriodically()
+	return tb, nil
+}
+
+// TokenBlacklist is a SQL token blacklist.
+// The token column keeps the token ID.
+type TokenBlacklist struct {
+	logger *slog.Logger
+	db     *DB
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Add adds token in the blacklist.
+func (tb *TokenBlacklist) Add(id string, expiresAt time.Time) error {
+	if model.IsBlacklistEntryExpired(expiresAt) {
+		return nil
+	}
+	return putBlacklistedToken(tb.db, id, expiresAt)
+}
+
+func putBlacklistedToken(e execer, id string, expiresAt time.Time) error {
+	var exp sql.NullInt64
+	if !expiresAt.IsZero() {
+		exp = sql.NullInt64{Int64: expiresAt.Unix(), Valid: true}
+	}
+	_, err := e.Exec(`INSERT INTO blacklisted_tokens (token, expires_at) VALUES ($1, $2)
+		ON CONFLICT (token) DO UPDATE SET expires_at = excluded.expires_at`, id, exp)
+	return err
+}
+
+// IsBlacklisted returns true if the token is blacklisted.
+func (tb *TokenBlacklist) IsBlacklisted(id string) bool {
+	var count int
+	// the token could expire before the next sweep.
+	if err := tb.db.QueryRow(`SELECT COUNT(*) FROM blacklisted_tokens WHERE token = $1 AND (expires_at IS NULL OR expires_at > $2)`,
+		id, time.Now().Unix()).Scan(&count); err != nil {
+		tb.logger.Error("Error checking blacklisted token", logging.FieldError, err)
+		return false
+	}
+	return count > 0
+}
+
+// sweep removes the expired tokens from the blacklist.
+// The rows of the older versions, keyed by the whole token, are replaced by the token ID.
+func (tb *TokenBlacklist) sweep() error {
+	return inTx(tb.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT token FROM blacklisted_tokens WHERE expires_at IS NULL AND token LIKE '%.%'`)
+		if err != nil {
+			return err
+		}
+		var legacy []string
+		for rows.Next() {
+			var token string
+			if err := rows.Scan(&token); err != nil {
+				rows.Close()
+				return err
+			}
+			legacy = append(legacy, token)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, token := range legacy {
+			if _, err := tx.Exec(`DELETE FROM blacklisted_tokens WHERE token = $1`, token); err != nil {
+				return err
+			}
+			id, expiresAt := model.BlacklistEntry(token)
+			if model.IsBlacklistEntryExpired(expiresAt) {
+				continue
+			}
+			if err := putBlacklistedToken(tx, id, expiresAt); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(`DELETE FROM blacklisted_tokens WHERE expires_at <= $1`, time.Now().Unix())
+		return err
+	})
+}
+
+func (tb *TokenBlacklist) sweepPeriodically() {
+	defer close(tb.done)
+
+	ticker := time.NewTicker(blacklistSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tb.stop:
+			return
+		case <-ticker.C:
+			if err := tb.sweep(); err != nil {
+				tb.logger.Error("Error removing expired tokens from blacklist", logging.FieldError, err)
+			}
+		}
+	}
+}
+
+// Close stops the sweeper and closes underlying database.
+func (tb *TokenBlacklist) Close() {
+	close(tb.stop)
+	<-tb.done
+
+	if err := CloseDB(tb.db); err != nil {
+		tb.logger.Error("Error closing token blacklist storage", logging.FieldError, err)
+	}
+}
@@ -0,0 +1,442 @@
+package sqldb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// NewUserStorage creates and inits SQL user storage.
+func NewUserStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.UserStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// UserStorage implements user storage interface for SQL databases.
+type UserStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// UserByID returns user by ID.
+func (us *UserStorage) UserByID(id string) (model.User, error) {
+	return us.userWhere(`id = $1`, id)
+}
+
+// UserByEmail returns user by their email.
+func (us *UserStorage) UserByEmail(email string) (model.User, error) {
+	if email == "" {
+		return model.User{}, model.ErrorWrongDataFormat
+	}
+	return sanitizedUser(us.userWhere(`email = $1`, strings.ToLower(email)))
+}
+
+// UserByPhone fetches user by phone number.
+func (us *UserStorage) UserByPhone(phone string) (model.User, error) {
+	if phone == "" {
+		return model.User{}, model.ErrUserNotFound
+	}
+	return sanitizedUser(us.userWhere(`phone = $1`, phone))
+}
+
+// UserByUsername returns user by name, the name is case insensitive.
+func (us *UserStorage) UserByUsername(username string) (model.User, error) {
+	if username == "" {
+		return model.User{}, model.ErrUserNotFound
+	}
+	return sanitizedUser(us.userWhere(`LOWER(username) = $1`, strings.ToLower(username)))
+}
+
+// UserByFederatedID returns user by federated ID.
+func (us *UserStorage) UserByFederatedID(provider string, id string) (model.User, error) {
+	sid := provider + ":" + id
+	return sanitizedUser(us.userWhere(
+		`id = (SELECT user_id FROM user_federated_ids WHERE federated_id = $1)`, sid))
+}
+
+// AttachDeviceToken does nothing here.
+func (us *UserStorage) AttachDeviceToken(id, token string) error {
+	// SQL implementation does not support user devices.
+	return model.ErrorNotImplemented
+}
+
+// DetachDeviceToken does nothing here.
+func (us *UserStorage) DetachDeviceToken(token string) error {
+	// SQL implementation does not support user devices.
+	return model.ErrorNotImplemented
+}
+
+// AllDeviceTokens returns no tokens, as user devices are not supported.
+func (us *UserStorage) AllDeviceTokens(userID string) ([]string, error) {
+	return nil, nil
+}
+
+// RequestScopes returns requested scopes.
+func (us *UserStorage) RequestScopes(userID string, scopes []string) ([]string, error) {
+	// We allow all scopes for SQL database, you can implement your own logic in the external service.
+	return scopes, nil
+}
+
+// Scopes returns supported scopes.
+func (us *UserStorage) Scopes() []string {
+	// We allow all scopes for SQL database, you can implement your own logic in the external service.
+	return []string{"offline", "user"}
+}
+
+// AddNewUser adds new user to the storage.
+func (us *UserStorage) AddNewUser(user model.User, password string) (model.User, error) {
+	user.Email = strings.ToLower(user.Email)
+	if len(user.ID) == 0 {
+		user.ID = xid.New().String()
+	}
+	if len(password) > 0 {
+		user.Pswd = model.PasswordHash(password)
+	}
+
+	err := inTx(us.db, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE id = $1`, user.ID).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return model.ErrorUserExists
+		}
+		return putUser(tx, user)
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// AddUserWithPassword creates new user and saves it in the database.
+func (us *UserStorage) AddUserWithPassword(user model.User, password, role string, isAnonymous bool) (model.User, error) {
+	if len(user.Username) > 0 {
+		if _, err := us.UserByUsername(user.Username); err == nil {
+			return model.User{}, model.ErrorUserExists
+		}
+	}
+	if len(user.Email) > 0 {
+		if _, err := us.UserByEmail(user.Email); err == nil {
+			return model.User{}, model.ErrorUserExists
+		}
+	}
+	if len(user.Phone) > 0 {
+		if _, err := us.UserByPhone(user.Phone); err == nil {
+			return model.User{}, model.ErrorUserExists
+		}
+	}
+
+	u := model.User{
+		ID:            xid.New().String(),
+		Active:        true,
+		Username:      user.Username,
+		FullName:      user.FullName,
+		Scopes:        user.Scopes,
+		Phone:         user.Phone,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		PhoneVerified: user.PhoneVerified,
+		AccessRole:    role,
+		Anonymous:     isAnonymous,
+		TFAInfo:       user.TFAInfo,
+	}
+
+	return us.AddNewUser(u, password)
+}
+
+// AddUserWithFederatedID adds new user with social ID.
+func (us *UserStorage) AddUserWithFederatedID(user model.User, provider string, federatedID, role string) (model.User, error) {
+	// If there is no error, it means user already exists.
+	_, err := us.UserByFederatedID(provider, federatedID)
+	if err == nil {
+		return model.User{}, model.ErrorUserExists
+	}
+	if !errors.Is(err, model.ErrUserNotFound) {
+		return model.User{}, err
+	}
+
+	user.ID = xid.New().String()
+	user.Active = true
+	user.AccessRole = role
+	user.AddFederatedId(provider, federatedID)
+
+	return us.AddNewUser(user, "")
+}
+
+// UpdateUser updates user in the storage.
+func (us *UserStorage) UpdateUser(userID string, user model.User) (model.User, error) {
+	oldUser, err := us.UserByID(userID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user.ID = userID
+	user.Email = strings.ToLower(user.Email)
+	if user.Pswd == "" {
+		user.Pswd = oldUser.Pswd
+	}
+	user.TFAInfo = user.TFAInfo.WithSecretsFrom(oldUser.TFAInfo)
+
+	if err := inTx(us.db, func(tx *sql.Tx) error {
+		return putUser(tx, user)
+	}); err != nil {
+		return model.User{}, err
+	}
+	return us.UserByID(userID)
+}
+
+// ResetPassword sets new user's password.
+func (us *UserStorage) ResetPassword(id, password string) error {
+	user, err := us.UserByID(id)
+	if err != nil {
+		return err
+	}
+
+	user.Pswd = model.PasswordHash(password)
+	return inTx(us.db, func(tx *sql.Tx) error {
+		return putUser(tx, user)
+	})
+}
+
+// CheckPassword check that password is valid for user id.
+func (us *UserStorage) CheckPassword(id, password string) error {
+	user, err := us.UserByID(id)
+	if err != nil {
+		return model.ErrUserNotFound
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(user.Pswd), []byte(password)); err != nil {
+		// return this error to hide the existence of the user.
+		return model.ErrUserNotFound
+	}
+	return nil
+}
+
+// ResetUsername sets new user's username.
+func (us *UserStorage) ResetUsername(id, username string) error {
+	user, err := us.UserByID(id)
+	if err != nil {
+		return err
+	}
+
+	user.Username = username
+	return inTx(us.db, func(tx *sql.Tx) error {
+		return putUser(tx, user)
+	})
+}
+
+// DeleteUser deletes user by ID.
+func (us *UserStorage) DeleteUser(id string) error {
+	return inTx(us.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM user_federated_ids WHERE user_id = $1`, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM users WHERE id = $1`, id)
+		return err
+	})
+}
+
+// FetchUsers fetches users which username, email or phone contains provided filterString.
+// Supports pagination.
+func (us *UserStorage) FetchUsers(filterString string, skip, limit int) ([]model.User, int, error) {
+	const where = ` WHERE LOWER(username) LIKE $1 ESCAPE '\' OR email LIKE $1 ESCAPE '\' OR phone LIKE $1 ESCAPE '\'`
+	pattern := containsPattern(filterString)
+
+	var total int
+	if err := us.db.QueryRow(`SELECT COUNT(*) FROM users`+where, pattern).Scan(&total); err != nil {
+		return []model.User{}, 0, err
+	}
+
+	page, args := limitOffset([]any{pattern}, skip, limit)
+	rows, err := us.db.Query(`SELECT data FROM users`+where+` ORDER BY id`+page, args...)
+	if err != nil {
+		return []model.User{}, 0, err
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return []model.User{}, 0, err
+		}
+		user, err := model.UserFromJSON(data)
+		if err != nil {
+			return []model.User{}, 0, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return []model.User{}, 0, err
+	}
+	return users, total, nil
+}
+
+// ImportJSON imports data from JSON.
+func (us *UserStorage) ImportJSON(data []byte, clearOldData bool) error {
+	ud := []model.User{}
+	if err := json.Unmarshal(data, &ud); err != nil {
+		return err
+	}
+
+	return inTx(us.db, func(tx *sql.Tx) error {
+		if clearOldData {
+			if _, err := tx.Exec(`DELETE FROM user_federated_ids`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM users`); err != nil {
+				return err
+			}
+		}
+		for _, u := range ud {
+			// the imported password is in plain text, as for the other storages, or the exported hash.
+			if len(u.Pswd) > 0 && !model.IsPasswordHash(u.Pswd) {
+				u.Pswd = model.PasswordHash(u.Pswd)
+			}
+			if len(u.ID) == 0 {
+				u.ID = xid.New().String()
+			}
+			u.Email = strings.ToLower(u.Email)
+			if err := putUser(tx, u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExportJSON exports all users to JSON.
+func (us *UserStorage) ExportJSON(w io.Writer) error {
+	return exportRows(us.db, w, `SELECT data FROM users ORDER BY id`, scanJSON[model.User])
+}
+
+// ExportsSnapshot reports that the export is read in a single read-only transaction.
+func (us *UserStorage) ExportsSnapshot() bool {
+	return true
+}
+
+// UpdateLoginMetadata updates user's login metadata.
+func (us *UserStorage) UpdateLoginMetadata(operation, app, userID string, scopes []string, payload map[string]any) {
+	user, err := us.UserByID(userID)
+	if err != nil {
+		us.logger.Error("Cannot get user by ID",
+			logging.FieldUserID, userID,
+			logging.FieldError, err)
+		return
+	}
+
+	user.NumOfLogins++
+	user.LatestLoginTime = time.Now().Unix()
+
+	if _, err := us.UpdateUser(userID, user); err != nil {
+		us.logger.Error("Cannot update user login info", logging.FieldError, err)
+	}
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of the user if they are still the old ones.
+// The user data is updated only if it is the same as read, so the concurrent replace changes no rows.
+func (us *UserStorage) ReplaceRecoveryCodes(userID string, old, codes []string) (bool, error) {
+	var data string
+	err := us.db.QueryRow(`SELECT data FROM users WHERE id = $1`, userID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	user := model.User{}
+	if err := json.Unmarshal([]byte(data), &user); err != nil {
+		return false, err
+	}
+	if !slices.Equal(user.TFAInfo.RecoveryCodes, old) {
+		return false, nil
+	}
+
+	user.TFAInfo.RecoveryCodes = codes
+	newData, err := json.Marshal(user)
+	if err != nil {
+		return false, err
+	}
+	res, err := us.db.Exec(`UPDATE users SET data = $1 WHERE id = $2 AND data = $3`, string(newData), userID, data)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Close closes underlying database.
+func (us *UserStorage) Close() {
+	if err := CloseDB(us.db); err != nil {
+		us.logger.Error("Error closing user storage", logging.FieldError, err)
+	}
+}
+
+func (us *UserStorage) userWhere(where string, args ...any) (model.User, error) {
+	var data []byte
+	err := us.db.QueryRow(`SELECT data FROM users WHERE `+where, args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, model.ErrUserNotFound
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+	return model.UserFromJSON(data)
+}
+
+// sanitizedUser clears the password hash of the found user.
+func sanitizedUser(user model.User, err error) (model.User, error) {
+	if err != nil {
+		return model.User{}, err
+	}
+	user.Pswd = ""
+	return user, nil
+}
+
+// putUser upserts the user and its federated IDs.
+func putUser(tx *sql.Tx, user model.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO users (id, username, email, phone, data) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET username = excluded.username, email = excluded.email, phone = excluded.phone, data = excluded.data`,
+		user.ID, user.Username, user.Email, user.Phone, string(data)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_federated_ids WHERE user_id = $1`, user.ID); err != nil {
+		return err
+	}
+	for _, fid := range user.FederatedIDs {
+		if _, err := tx.Exec(`INSERT INTO user_federated_ids (federated_id, user_id) VALUES ($1, $2)
+			ON CONFLICT (federated_id) DO UPDATE SET user_id = excluded.user_id`, fid, user.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
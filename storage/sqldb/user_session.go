@@ -0,0 +1,136 @@
+package sqldb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewUserSessionStorage creates a SQL user session storage.
+func NewUserSessionStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.UserSessionStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserSessionStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// UserSessionStorage is a SQL user session storage.
+// The expired sessions are not returned, they are removed when the sessions of any user are listed.
+type UserSessionStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// AddSession saves the new session.
+func (us *UserSessionStorage) AddSession(session model.UserSession) (model.UserSession, error) {
+	if len(session.ID) == 0 {
+		session.ID = xid.New().String()
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return model.UserSession{}, err
+	}
+
+	_, err = us.db.Exec(`INSERT INTO user_sessions (id, user_id, refresh_token_id, last_refresh_at, expires_at, data)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		session.ID, session.UserID, session.RefreshTokenID, unixNano(session.LastRefreshAt), unixNano(session.ExpiresAt), string(data))
+	if err != nil {
+		return model.UserSession{}, err
+	}
+	return session, nil
+}
+
+// UpdateSession replaces the session.
+func (us *UserSessionStorage) UpdateSession(session model.UserSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	res, err := us.db.Exec(`UPDATE user_sessions SET user_id = $1, refresh_token_id = $2, last_refresh_at = $3, expires_at = $4, data = $5
+		WHERE id = $6`,
+		session.UserID, session.RefreshTokenID, unixNano(session.LastRefreshAt), unixNano(session.ExpiresAt), string(data), session.ID)
+	return notFoundIfNoRows(res, err)
+}
+
+// SessionByID returns the session by its ID.
+func (us *UserSessionStorage) SessionByID(id string) (model.UserSession, error) {
+	return us.sessionWhere(`id = $1 AND expires_at > $2`, id, time.Now().UnixNano())
+}
+
+// SessionByRefreshTokenID returns the session by the jti of its refresh token.
+func (us *UserSessionStorage) SessionByRefreshTokenID(tokenID string) (model.UserSession, error) {
+	return us.sessionWhere(`refresh_token_id = $1 AND expires_at > $2`, tokenID, time.Now().UnixNano())
+}
+
+// UserSessions returns the sessions of the user, the latest refreshed first.
+func (us *UserSessionStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	now := time.Now().UnixNano()
+	if _, err := us.db.Exec(`DELETE FROM user_sessions WHERE expires_at <= $1`, now); err != nil {
+		return nil, err
+	}
+
+	rows, err := us.db.Query(`SELECT data FROM user_sessions WHERE user_id = $1 AND expires_at > $2 ORDER BY last_refresh_at DESC`,
+		userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []model.UserSession{}
+	for rows.Next() {
+		session, err := scanJSON[model.UserSession](rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession deletes the session.
+func (us *UserSessionStorage) DeleteSession(id string) error {
+	res, err := us.db.Exec(`DELETE FROM user_sessions WHERE id = $1`, id)
+	return notFoundIfNoRows(res, err)
+}
+
+// DeleteUserSessions deletes all sessions of the user.
+func (us *UserSessionStorage) DeleteUserSessions(userID string) (int, error) {
+	res, err := us.db.Exec(`DELETE FROM user_sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	return int(deleted), err
+}
+
+// Close closes underlying database.
+func (us *UserSessionStorage) Close() {
+	if err := CloseDB(us.db); err != nil {
+		us.logger.Error("Error closing user session storage", logging.FieldError, err)
+	}
+}
+
+func (us *UserSessionStorage) sessionWhere(where string, args ...any) (model.UserSession, error) {
+	session, err := scanJSON[model.UserSession](us.db.QueryRow(`SELECT data FROM user_sessions WHERE `+where+` LIMIT 1`, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	return session, err
+}
@@ -0,0 +1,144 @@
+package sqldb_test
+
+import (
+	"testing"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStorage(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			users, err := sqldb.NewUserStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer users.Close()
+
+			require.NoError(t, users.ImportJSON([]byte(`[]`), true))
+
+			u, err := users.AddUserWithPassword(model.User{
+				Username: "JohnDoe",
+				Email:    "John@Example.com",
+				Phone:    "+15550000001",
+			}, "password", "user", false)
+			require.NoError(t, err)
+			assert.Equal(t, "john@example.com", u.Email)
+
+			_, err = users.AddUserWithPassword(model.User{Username: "johndoe"}, "password", "user", false)
+			assert.ErrorIs(t, err, model.ErrorUserExists)
+
+			found, err := users.UserByUsername("JOHNDOE")
+			require.NoError(t, err)
+			assert.Equal(t, u.ID, found.ID)
+			assert.Empty(t, found.Pswd)
+			found, err = users.UserByEmail("john@EXAMPLE.com")
+			require.NoError(t, err)
+			assert.Equal(t, u.ID, found.ID)
+			found, err = users.UserByPhone("+15550000001")
+			require.NoError(t, err)
+			assert.Equal(t, u.ID, found.ID)
+			_, err = users.UserByPhone("+15550000002")
+			assert.ErrorIs(t, err, model.ErrUserNotFound)
+
+			require.NoError(t, users.CheckPassword(u.ID, "password"))
+			assert.ErrorIs(t, users.CheckPassword(u.ID, "wrong"), model.ErrUserNotFound)
+			require.NoError(t, users.ResetPassword(u.ID, "new_password"))
+			require.NoError(t, users.CheckPassword(u.ID, "new_password"))
+
+			// the update keeps the password hash when it is not set.
+			found.FullName = "John Doe"
+			updated, err := users.UpdateUser(u.ID, found)
+			require.NoError(t, err)
+			assert.Equal(t, "John Doe", updated.FullName)
+			require.NoError(t, users.CheckPassword(u.ID, "new_password"))
+
+			fu, err := users.AddUserWithFederatedID(model.User{Email: "fed@example.com"}, "google", "123", "user")
+			require.NoError(t, err)
+			found, err = users.UserByFederatedID("google", "123")
+			require.NoError(t, err)
+			assert.Equal(t, fu.ID, found.ID)
+			_, err = users.AddUserWithFederatedID(model.User{}, "google", "123", "user")
+			assert.ErrorIs(t, err, model.ErrorUserExists)
+
+			users.UpdateLoginMetadata("login", "app", u.ID, nil, nil)
+			found, err = users.UserByID(u.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 1, found.NumOfLogins)
+
+			fetched, total, err := users.FetchUsers("EXAMPLE", 0, 1)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Len(t, fetched, 1)
+			fetched, total, err = users.FetchUsers("", 1, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Len(t, fetched, 1)
+
+			require.NoError(t, users.DeleteUser(fu.ID))
+			_, err = users.UserByFederatedID("google", "123")
+			assert.ErrorIs(t, err, model.ErrUserNotFound)
+
+			require.NoError(t, users.ImportJSON([]byte(`[{"id":"imported","username":"imported","pswd":"secret"}]`), true))
+			_, total, err = users.FetchUsers("", 0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			require.NoError(t, users.CheckPassword("imported", "secret"))
+		})
+	}
+}
+
+func TestUserStorageRecoveryCodes(t *testing.T) {
+	for dbType, settings := range testDatabases(t) {
+		t.Run(string(dbType), func(t *testing.T) {
+			users, err := sqldb.NewUserStorage(logging.DefaultLogger, dbType, settings)
+			require.NoError(t, err)
+			defer users.Close()
+
+			require.NoError(t, users.ImportJSON([]byte(`[]`), true))
+
+			codes, err := model.NewTFARecoveryCodes()
+			require.NoError(t, err)
+			user := model.User{Username: "recovery"}
+			user.TFAInfo.SetRecoveryCodes(codes)
+			u, err := users.AddUserWithPassword(user, "password", "user", false)
+			require.NoError(t, err)
+
+			// the codes changed meanwhile are not replaced
+			replaced, err := users.ReplaceRecoveryCodes(u.ID, []string{"other"}, nil)
+			require.NoError(t, err)
+			assert.False(t, replaced)
+
+			// the code is used once by the concurrent requests
+			used := make(chan bool, 4)
+			for range cap(used) {
+				go func() {
+					_, ok, err := model.ConsumeRecoveryCode(users, u.ID, codes[0])
+					assert.NoError(t, err)
+					used <- ok
+				}()
+			}
+			count := 0
+			for range cap(used) {
+				if <-used {
+					count++
+				}
+			}
+			assert.Equal(t, 1, count)
+
+			found, err := users.UserByID(u.ID)
+			require.NoError(t, err)
+			assert.Len(t, found.TFAInfo.RecoveryCodes, model.TFARecoveryCodesCount-1)
+
+			_, ok, err := model.ConsumeRecoveryCode(users, u.ID, codes[1])
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			replaced, err = users.ReplaceRecoveryCodes("missing", nil, nil)
+			require.NoError(t, err)
+			assert.False(t, replaced)
+		})
+	}
+}
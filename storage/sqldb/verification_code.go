@@ -0,0 +1,121 @@
+package sqldb
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// NewVerificationCodeStorage creates and inits SQL verification code storage.
+func NewVerificationCodeStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.VerificationCodeStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerificationCodeStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// VerificationCodeStorage implements verification code storage interface.
+type VerificationCodeStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// verificationCodeColumns are the status columns of the code.
+const verificationCodeColumns = `expires_at, resend_at, attempts_left`
+
+// CreateVerificationCode saves the new code of the phone, replacing the previous one if the resend cooldown has passed.
+func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string, policy model.VerificationCodePolicy) (model.VerificationCodeStatus, error) {
+	now := time.Now()
+	vc := model.NewVerificationCode(phone, code, policy, now)
+
+	res, err := vcs.db.Exec(`INSERT INTO verification_codes (phone, code, expires_at, resend_at, attempts_left) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (phone) DO UPDATE SET code = excluded.code, expires_at = excluded.expires_at,
+			resend_at = excluded.resend_at, attempts_left = excluded.attempts_left
+		WHERE verification_codes.resend_at <= $6`,
+		phone, code, vc.ExpiresAt.Unix(), vc.ResendAt.Unix(), vc.AttemptsLeft, now.Unix())
+	if err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+	saved, err := res.RowsAffected()
+	if err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+	if saved > 0 {
+		return vc.Status(), nil
+	}
+
+	prev, err := scanVerificationCodeStatus(vcs.db.QueryRow(`SELECT `+verificationCodeColumns+` FROM verification_codes WHERE phone = $1`, phone))
+	if err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+	return prev, model.ErrorVerificationCodeCooldown
+}
+
+// VerifyCode checks the code of the phone, the matching code is deleted, the mismatch uses the attempt.
+// Each step is the single statement, so the code could not be used twice by the concurrent requests.
+func (vcs *VerificationCodeStorage) VerifyCode(phone, code string) (model.VerificationCodeStatus, error) {
+	now := time.Now().Unix()
+
+	status, err := scanVerificationCodeStatus(vcs.db.QueryRow(`DELETE FROM verification_codes
+		WHERE phone = $1 AND code = $2 AND expires_at > $3 AND attempts_left > 0
+		RETURNING `+verificationCodeColumns, phone, code, now))
+	if err == nil {
+		return status, nil
+	}
+	if err != sql.ErrNoRows {
+		return model.VerificationCodeStatus{}, err
+	}
+
+	status, err = scanVerificationCodeStatus(vcs.db.QueryRow(`UPDATE verification_codes SET attempts_left = attempts_left - 1
+		WHERE phone = $1 AND expires_at > $2 AND attempts_left > 0
+		RETURNING `+verificationCodeColumns, phone, now))
+	if err == nil {
+		return status, model.ErrorVerificationCodeInvalid
+	}
+	if err != sql.ErrNoRows {
+		return model.VerificationCodeStatus{}, err
+	}
+
+	// the code has no attempts left or has expired.
+	status, err = scanVerificationCodeStatus(vcs.db.QueryRow(`SELECT `+verificationCodeColumns+` FROM verification_codes WHERE phone = $1`, phone))
+	if err == sql.ErrNoRows {
+		return model.VerificationCodeStatus{}, model.ErrorVerificationCodeExpired
+	}
+	if err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+	if status.ExpiresAt.Unix() > now {
+		return status, model.ErrorVerificationCodeAttemptsExceeded
+	}
+	return status, model.ErrorVerificationCodeExpired
+}
+
+func scanVerificationCodeStatus(row scanner) (model.VerificationCodeStatus, error) {
+	var expiresAt, resendAt int64
+	var status model.VerificationCodeStatus
+	if err := row.Scan(&expiresAt, &resendAt, &status.AttemptsLeft); err != nil {
+		return model.VerificationCodeStatus{}, err
+	}
+	status.ExpiresAt = time.Unix(expiresAt, 0)
+	status.ResendAt = time.Unix(resendAt, 0)
+	return status, nil
+}
+
+// Close closes underlying database.
+func (vcs *VerificationCodeStorage) Close() {
+	if err := CloseDB(vcs.db); err != nil {
+		vcs.logger.Error("Error closing verification code storage", logging.FieldError, err)
+	}
+}
@@ -0,0 +1,143 @@
+package sqldb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// NewWebAuthnStorage creates a SQL WebAuthn credentials storage.
+func NewWebAuthnStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.WebAuthnStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// WebAuthnStorage is a SQL WebAuthn credentials storage.
+type WebAuthnStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// AddCredential adds new credential.
+func (ws *WebAuthnStorage) AddCredential(credential model.WebAuthnCredential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ws.db, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM webauthn_credentials WHERE id = $1`, credential.ID).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return model.ErrorWrongDataFormat
+		}
+
+		_, err := tx.Exec(`INSERT INTO webauthn_credentials (id, user_id, created_at, data) VALUES ($1, $2, $3, $4)`,
+			credential.ID, credential.UserID, unixNano(credential.CreatedAt), string(data))
+		return err
+	})
+}
+
+// CredentialByID returns credential by its id.
+func (ws *WebAuthnStorage) CredentialByID(id string) (model.WebAuthnCredential, error) {
+	credential, err := scanJSON[model.WebAuthnCredential](ws.db.QueryRow(`SELECT data FROM webauthn_credentials WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.WebAuthnCredential{}, model.ErrorNotFound
+	}
+	return credential, err
+}
+
+// CredentialsByUserID returns all credentials of the user, ordered by creation time.
+func (ws *WebAuthnStorage) CredentialsByUserID(userID string) ([]model.WebAuthnCredential, error) {
+	rows, err := ws.db.Query(`SELECT data FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []model.WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanJSON[model.WebAuthnCredential](rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, rows.Err()
+}
+
+// UpdateCredential updates existing credential.
+func (ws *WebAuthnStorage) UpdateCredential(credential model.WebAuthnCredential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	res, err := ws.db.Exec(`UPDATE webauthn_credentials SET user_id = $1, created_at = $2, data = $3 WHERE id = $4`,
+		credential.UserID, unixNano(credential.CreatedAt), string(data), credential.ID)
+	return notFoundIfNoRows(res, err)
+}
+
+// DeleteCredential deletes credential by its id.
+func (ws *WebAuthnStorage) DeleteCredential(id string) error {
+	res, err := ws.db.Exec(`DELETE FROM webauthn_credentials WHERE id = $1`, id)
+	return notFoundIfNoRows(res, err)
+}
+
+// SaveSession saves the session of the ceremony, the expired sessions are deleted with it.
+func (ws *WebAuthnStorage) SaveSession(session model.WebAuthnSession) error {
+	return inTx(ws.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM webauthn_sessions WHERE expires_at <= $1`, unixNano(time.Now())); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`INSERT INTO webauthn_sessions (id, expires_at, data) VALUES ($1, $2, $3)`,
+			session.ID, unixNano(session.ExpiresAt), session.Data)
+		return err
+	})
+}
+
+// TakeSession returns and deletes the session with the single statement, so it could not be taken twice.
+func (ws *WebAuthnStorage) TakeSession(id string) (model.WebAuthnSession, error) {
+	var (
+		session   model.WebAuthnSession
+		expiresAt int64
+	)
+
+	err := ws.db.QueryRow(`DELETE FROM webauthn_sessions WHERE id = $1 AND expires_at > $2
+		RETURNING id, expires_at, data`, id, unixNano(time.Now())).Scan(&session.ID, &expiresAt, &session.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.WebAuthnSession{}, model.ErrorNotFound
+	}
+	if err != nil {
+		return model.WebAuthnSession{}, err
+	}
+
+	session.ExpiresAt = time.Unix(0, expiresAt)
+	return session, nil
+}
+
+// Close closes underlying database.
+func (ws *WebAuthnStorage) Close() {
+	if err := CloseDB(ws.db); err != nil {
+		ws.logger.Error("Error closing webauthn storage", logging.FieldError, err)
+	}
+}
@@ -0,0 +1,185 @@
+package sqldb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/rs/xid"
+)
+
+// NewWebhookDeliveryStorage creates a SQL webhook delivery storage.
+func NewWebhookDeliveryStorage(
+	logger *slog.Logger,
+	dbType model.DatabaseType,
+	settings model.SQLDatabaseSettings,
+) (model.WebhookDeliveryStorage, error) {
+	db, err := InitDB(dbType, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookDeliveryStorage{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// WebhookDeliveryStorage is a SQL webhook delivery storage.
+// The status and the next attempt time columns are copied out of the stored delivery for the queue queries.
+type WebhookDeliveryStorage struct {
+	logger *slog.Logger
+	db     *DB
+}
+
+// AddDelivery queues the delivery.
+func (ws *WebhookDeliveryStorage) AddDelivery(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	delivery.ID = xid.New().String()
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	_, err = ws.db.Exec(`INSERT INTO webhook_deliveries (id, status, next_attempt_at, data) VALUES ($1, $2, $3, $4)`,
+		delivery.ID, string(delivery.Status), unixNano(delivery.NextAttemptAt), string(data))
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// ClaimDueDeliveries returns pending deliveries due at now and postpones them for the lease duration.
+// The delivery is claimed only if its next attempt time is not changed since it was read,
+// so the delivery claimed by the other server instance in the meantime is skipped.
+func (ws *WebhookDeliveryStorage) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	args := []any{string(model.WebhookDeliveryPending), unixNano(now)}
+	page, args := limitOffset(args, 0, limit)
+
+	claimed := []model.WebhookDelivery{}
+	err := inTx(ws.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT data FROM webhook_deliveries WHERE status = $1 AND next_attempt_at <= $2
+			ORDER BY next_attempt_at`+page, args...)
+		if err != nil {
+			return err
+		}
+		due := []model.WebhookDelivery{}
+		for rows.Next() {
+			delivery, err := scanJSON[model.WebhookDelivery](rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			due = append(due, delivery)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, delivery := range due {
+			dueAt := unixNano(delivery.NextAttemptAt)
+			delivery.NextAttemptAt = now.Add(lease)
+			data, err := json.Marshal(delivery)
+			if err != nil {
+				return err
+			}
+
+			res, err := tx.Exec(`UPDATE webhook_deliveries SET next_attempt_at = $1, data = $2 WHERE id = $3 AND next_attempt_at = $4`,
+				unixNano(delivery.NextAttemptAt), string(data), delivery.ID, dueAt)
+			if err := notFoundIfNoRows(res, err); errors.Is(err, model.ErrorNotFound) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			claimed = append(claimed, delivery)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// UpdateDelivery replaces the delivery.
+func (ws *WebhookDeliveryStorage) UpdateDelivery(delivery model.WebhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	res, err := ws.db.Exec(`UPDATE webhook_deliveries SET status = $1, next_attempt_at = $2, data = $3 WHERE id = $4`,
+		string(delivery.Status), unixNano(delivery.NextAttemptAt), string(data), delivery.ID)
+	return notFoundIfNoRows(res, err)
+}
+
+// DeliveryByID returns the delivery by its ID.
+func (ws *WebhookDeliveryStorage) DeliveryByID(id string) (model.WebhookDelivery, error) {
+	delivery, err := scanJSON[model.WebhookDelivery](ws.db.QueryRow(`SELECT data FROM webhook_deliveries WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.WebhookDelivery{}, model.ErrorNotFound
+	}
+	return delivery, err
+}
+
+// FetchDeliveries returns deliveries with the status, newest first.
+// The IDs are ordered by the creation time.
+func (ws *WebhookDeliveryStorage) FetchDeliveries(status model.WebhookDeliveryStatus, skip, limit int) ([]model.WebhookDelivery, int, error) {
+	where := ``
+	args := []any{}
+	if len(status) > 0 {
+		where = ` WHERE status = $1`
+		args = append(args, string(status))
+	}
+
+	var total int
+	if err := ws.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries`+where, args...).Scan(&total); err != nil {
+		return []model.WebhookDelivery{}, 0, err
+	}
+
+	page, args := limitOffset(args, skip, limit)
+	rows, err := ws.db.Query(`SELECT data FROM webhook_deliveries`+where+` ORDER BY id DESC`+page, args...)
+	if err != nil {
+		return []model.WebhookDelivery{}, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanJSON[model.WebhookDelivery](rows)
+		if err != nil {
+			return []model.WebhookDelivery{}, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return []model.WebhookDelivery{}, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// DeleteFinishedDeliveries deletes the delivered and dead deliveries finished before the time.
+func (ws *WebhookDeliveryStorage) DeleteFinishedDeliveries(before time.Time) (int, error) {
+	res, err := ws.db.Exec(`DELETE FROM webhook_deliveries WHERE status IN ($1, $2) AND next_attempt_at < $3`,
+		string(model.WebhookDeliveryDelivered), string(model.WebhookDeliveryDead), unixNano(before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Close closes underlying database.
+func (ws *WebhookDeliveryStorage) Close() {
+	if err := CloseDB(ws.db); err != nil {
+		ws.logger.Error("Error closing webhook delivery storage", logging.FieldError, err)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"github.com/madappgang/identifo/v2/storage/fs"
 	"github.com/madappgang/identifo/v2/storage/mem"
 	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/redis"
+	"github.com/madappgang/identifo/v2/storage/rest"
 	"github.com/madappgang/identifo/v2/storage/s3"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
 )
 
 var spaFileStorageExpectedFiles = []string{"index.html"}
@@ -53,6 +56,12 @@ func NewDatabaseConnectionTester(settings model.DatabaseSettings) model.Connecti
 		fallthrough
 	case model.DBTypeMem:
 		return mem.NewConnectionTester()
+	case model.DBTypeREST:
+		return rest.NewConnectionTester(settings.REST)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewConnectionTester(settings.Type, settings.SQL)
+	case model.DBTypeRedis:
+		return redis.NewConnectionTester(settings.Redis)
 	}
 	return nil
 }
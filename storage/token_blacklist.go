@@ -9,6 +9,8 @@ import (
 	"github.com/madappgang/identifo/v2/storage/dynamodb"
 	"github.com/madappgang/identifo/v2/storage/mem"
 	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/redis"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
 )
 
 // NewTokenBlacklistStorage creates new tokens blacklist storage from settings
@@ -23,6 +25,10 @@ func NewTokenBlacklistStorage(
 		return mongo.NewTokenBlacklist(logger, settings.Mongo)
 	case model.DBTypeDynamoDB:
 		return dynamodb.NewTokenBlacklist(logger, settings.Dynamo)
+	case model.DBTypeRedis:
+		return redis.NewTokenBlacklist(settings.Redis)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewTokenBlacklist(logger, settings.Type, settings.SQL)
 	case model.DBTypeFake:
 		fallthrough
 	case model.DBTypeMem:
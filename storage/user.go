@@ -11,6 +11,8 @@ import (
 	"github.com/madappgang/identifo/v2/storage/mem"
 	"github.com/madappgang/identifo/v2/storage/mongo"
 	"github.com/madappgang/identifo/v2/storage/plugin"
+	"github.com/madappgang/identifo/v2/storage/rest"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
 )
 
 // NewUserStorage creates new users storage from settings
@@ -24,6 +26,8 @@ func NewUserStorage(
 		return mongo.NewUserStorage(logger, settings.Mongo)
 	case model.DBTypeDynamoDB:
 		return dynamodb.NewUserStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewUserStorage(logger, settings.Type, settings.SQL)
 	case model.DBTypeFake:
 		fallthrough
 	case model.DBTypeMem:
@@ -32,6 +36,8 @@ func NewUserStorage(
 		return plugin.NewUserStorage(logger, settings.Plugin)
 	case model.DBTypeGRPC:
 		return grpc.NewUserStorage(settings.GRPC)
+	case model.DBTypeREST:
+		return rest.NewUserStorage(logger, settings.REST)
 	default:
 		return nil, fmt.Errorf("user storage type is not supported %s ", settings.Type)
 	}
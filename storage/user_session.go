@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/madappgang/identifo/v2/storage/dynamodb"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/redis"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+)
+
+// NewUserSessionStorage creates new user session storage from settings
+func NewUserSessionStorage(
+	logger *slog.Logger,
+	settings model.DatabaseSettings) (model.UserSessionStorage, error) {
+	switch settings.Type {
+	case model.DBTypeBoltDB:
+		return boltdb.NewUserSessionStorage(logger, settings.BoltDB)
+	case model.DBTypeMongoDB:
+		return mongo.NewUserSessionStorage(logger, settings.Mongo)
+	case model.DBTypeDynamoDB:
+		return dynamodb.NewUserSessionStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewUserSessionStorage(logger, settings.Type, settings.SQL)
+	case model.DBTypeRedis:
+		return redis.NewUserSessionStorage(settings.Redis)
+	case model.DBTypeFake:
+		fallthrough
+	case model.DBTypeMem:
+		return mem.NewUserSessionStorage()
+	default:
+		return nil, fmt.Errorf("user session storage type is not supported %s ", settings.Type)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/madappgang/identifo/v2/storage/dynamodb"
 	"github.com/madappgang/identifo/v2/storage/mem"
 	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/redis"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
 )
 
 // NewVerificationCodesStorage creates new verification codes storage from settings
@@ -22,6 +24,10 @@ func NewVerificationCodesStorage(
 		return mongo.NewVerificationCodeStorage(logger, settings.Mongo)
 	case model.DBTypeDynamoDB:
 		return dynamodb.NewVerificationCodeStorage(logger, settings.Dynamo)
+	case model.DBTypeRedis:
+		return redis.NewVerificationCodeStorage(settings.Redis)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewVerificationCodeStorage(logger, settings.Type, settings.SQL)
 	case model.DBTypeFake:
 		fallthrough
 	case model.DBTypeMem:
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/madappgang/identifo/v2/storage/dynamodb"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+)
+
+// NewWebAuthnStorage creates new WebAuthn credentials storage from settings
+func NewWebAuthnStorage(
+	logger *slog.Logger,
+	settings model.DatabaseSettings) (model.WebAuthnStorage, error) {
+	switch settings.Type {
+	case model.DBTypeBoltDB:
+		return boltdb.NewWebAuthnStorage(logger, settings.BoltDB)
+	case model.DBTypeMongoDB:
+		return mongo.NewWebAuthnStorage(logger, settings.Mongo)
+	case model.DBTypeDynamoDB:
+		return dynamodb.NewWebAuthnStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewWebAuthnStorage(logger, settings.Type, settings.SQL)
+	case model.DBTypeFake:
+		fallthrough
+	case model.DBTypeMem:
+		return mem.NewWebAuthnStorage()
+	default:
+		return nil, fmt.Errorf("webauthn storage type is not supported %s ", settings.Type)
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/storage/boltdb"
+	"github.com/madappgang/identifo/v2/storage/dynamodb"
+	"github.com/madappgang/identifo/v2/storage/mem"
+	"github.com/madappgang/identifo/v2/storage/mongo"
+	"github.com/madappgang/identifo/v2/storage/sqldb"
+)
+
+// NewWebhookDeliveryStorage creates new webhook delivery storage from settings
+func NewWebhookDeliveryStorage(
+	logger *slog.Logger,
+	settings model.DatabaseSettings) (model.WebhookDeliveryStorage, error) {
+	switch settings.Type {
+	case model.DBTypeBoltDB:
+		return boltdb.NewWebhookDeliveryStorage(logger, settings.BoltDB)
+	case model.DBTypeMongoDB:
+		return mongo.NewWebhookDeliveryStorage(logger, settings.Mongo)
+	case model.DBTypeDynamoDB:
+		return dynamodb.NewWebhookDeliveryStorage(logger, settings.Dynamo)
+	case model.DBTypePostgres, model.DBTypeSQLite:
+		return sqldb.NewWebhookDeliveryStorage(logger, settings.Type, settings.SQL)
+	case model.DBTypeFake:
+		fallthrough
+	case model.DBTypeMem:
+		return mem.NewWebhookDeliveryStorage()
+	default:
+		return nil, fmt.Errorf("webhook delivery storage type is not supported %s ", settings.Type)
+	}
+}
@@ -0,0 +1,329 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/xlzd/gotp"
+)
+
+// totpIssuer is the issuer shown by the authenticator apps for the admin accounts.
+const totpIssuer = "Identifo admin panel"
+
+type adminAccountData struct {
+	Login    string          `json:"login"`
+	Name     string          `json:"name"`
+	Role     model.AdminRole `json:"role"`
+	Password string          `json:"password"`
+}
+
+// adminAccountUpdate is the change of the admin account, the fields which are not set are not changed.
+type adminAccountUpdate struct {
+	Name      *string          `json:"name"`
+	Role      *model.AdminRole `json:"role"`
+	Active    *bool            `json:"active"`
+	Password  *string          `json:"password"`
+	ResetTOTP bool             `json:"reset_totp"`
+}
+
+type adminMe struct {
+	model.AdminAccount
+	Permissions []model.AdminPermission `json:"permissions"`
+}
+
+type adminPasswordChange struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type adminTOTPCode struct {
+	Code string `json:"code"`
+}
+
+// GetMe returns the account of the logged in admin with its permissions.
+func (ar *Router) GetMe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin, _ := adminFromContext(r.Context())
+		ar.ServeJSON(w, http.StatusOK, adminMe{
+			AdminAccount: admin.Sanitized(),
+			Permissions:  permissionsFromContext(r.Context()),
+		})
+	}
+}
+
+// ChangeMyPassword changes the password of the logged in admin, the old password is required.
+func (ar *Router) ChangeMyPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin, ok := ar.accountAdmin(w, r)
+		if !ok {
+			return
+		}
+
+		d := adminPasswordChange{}
+		if ar.mustParseJSON(w, r, &d) != nil {
+			return
+		}
+		if !admin.CheckPassword(d.OldPassword) {
+			ar.Error(w, ErrorIncorrectLogin, http.StatusBadRequest, "")
+			return
+		}
+		if err := model.StrongPswd(d.NewPassword); err != nil {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
+
+		admin.PasswordHash = model.PasswordHash(d.NewPassword)
+		ar.saveAdminAccount(w, admin)
+	}
+}
+
+// SetupMyTOTP generates new TOTP secret for the logged in admin.
+// The TOTP is enabled with EnableMyTOTP, once the authenticator app has been set up.
+func (ar *Router) SetupMyTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin, ok := ar.accountAdmin(w, r)
+		if !ok {
+			return
+		}
+		if admin.TOTP.Enabled {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, "TOTP is already enabled")
+			return
+		}
+
+		admin.TOTP.Secret = gotp.RandomSecret(16)
+		admin.UpdatedAt = time.Now()
+		if err := ar.server.Storages().AdminAccount.UpdateAdminAccount(admin); err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, map[string]string{
+			"secret":           admin.TOTP.Secret,
+			"provisioning_uri": gotp.NewDefaultTOTP(admin.TOTP.Secret).ProvisioningUri(admin.Login, totpIssuer),
+		})
+	}
+}
+
+// EnableMyTOTP enables TOTP of the logged in admin, the code from the authenticator app is required.
+func (ar *Router) EnableMyTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin, ok := ar.accountAdmin(w, r)
+		if !ok {
+			return
+		}
+
+		d := adminTOTPCode{}
+		if ar.mustParseJSON(w, r, &d) != nil {
+			return
+		}
+		if !verifyTOTP(admin.TOTP.Secret, d.Code) {
+			ar.Error(w, ErrorInvalidTOTP, http.StatusBadRequest, "")
+			return
+		}
+
+		admin.TOTP.Enabled = true
+		ar.saveAdminAccount(w, admin)
+	}
+}
+
+// DisableMyTOTP disables TOTP of the logged in admin, the current code is required.
+func (ar *Router) DisableMyTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin, ok := ar.accountAdmin(w, r)
+		if !ok {
+			return
+		}
+
+		d := adminTOTPCode{}
+		if ar.mustParseJSON(w, r, &d) != nil {
+			return
+		}
+		if !admin.TOTP.Enabled || !verifyTOTP(admin.TOTP.Secret, d.Code) {
+			ar.Error(w, ErrorInvalidTOTP, http.StatusBadRequest, "")
+			return
+		}
+
+		admin.TOTP = model.AdminTOTP{}
+		ar.saveAdminAccount(w, admin)
+	}
+}
+
+// FetchAdminAccounts returns all admin accounts.
+func (ar *Router) FetchAdminAccounts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accounts, err := ar.server.Storages().AdminAccount.AdminAccounts()
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		for i := range accounts {
+			accounts[i] = accounts[i].Sanitized()
+		}
+		ar.ServeJSON(w, http.StatusOK, accounts)
+	}
+}
+
+// GetAdminAccount returns the admin account by ID.
+func (ar *Router) GetAdminAccount() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := ar.adminAccountByID(w, getRouteVar("id", r))
+		if !ok {
+			return
+		}
+		ar.ServeJSON(w, http.StatusOK, account.Sanitized())
+	}
+}
+
+// CreateAdminAccount creates new active admin account.
+func (ar *Router) CreateAdminAccount() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := adminAccountData{}
+		if ar.mustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		login := model.NormalizeAdminLogin(d.Login)
+		if len(login) == 0 {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, "login is required")
+			return
+		}
+		if !d.Role.IsValid() {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, fmt.Sprintf("unknown role %s", d.Role))
+			return
+		}
+		if err := model.StrongPswd(d.Password); err != nil {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
+
+		now := time.Now()
+		account, err := ar.server.Storages().AdminAccount.AddAdminAccount(model.AdminAccount{
+			Login:        login,
+			Name:         d.Name,
+			Role:         d.Role,
+			Active:       true,
+			PasswordHash: model.PasswordHash(d.Password),
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		})
+		if err == model.ErrorAdminAccountExists {
+			ar.Error(w, err, http.StatusConflict, "")
+			return
+		}
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		ar.ServeJSON(w, http.StatusCreated, account.Sanitized())
+	}
+}
+
+// UpdateAdminAccount changes the admin account.
+// The admin could not change the role or deactivate the own account, so there is always someone to manage the accounts.
+func (ar *Router) UpdateAdminAccount() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := ar.adminAccountByID(w, getRouteVar("id", r))
+		if !ok {
+			return
+		}
+
+		d := adminAccountUpdate{}
+		if ar.mustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		me, _ := adminFromContext(r.Context())
+		if me.ID == account.ID && ((d.Role != nil && *d.Role != account.Role) || (d.Active != nil && !*d.Active)) {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, "the role of the own account could not be changed")
+			return
+		}
+
+		if d.Name != nil {
+			account.Name = *d.Name
+		}
+		if d.Role != nil {
+			if !d.Role.IsValid() {
+				ar.Error(w, ErrorWrongInput, http.StatusBadRequest, fmt.Sprintf("unknown role %s", *d.Role))
+				return
+			}
+			account.Role = *d.Role
+		}
+		if d.Active != nil {
+			account.Active = *d.Active
+		}
+		if d.Password != nil {
+			if err := model.StrongPswd(*d.Password); err != nil {
+				ar.Error(w, err, http.StatusBadRequest, "")
+				return
+			}
+			account.PasswordHash = model.PasswordHash(*d.Password)
+		}
+		if d.ResetTOTP {
+			account.TOTP = model.AdminTOTP{}
+		}
+
+		ar.saveAdminAccount(w, account)
+	}
+}
+
+// DeleteAdminAccount deletes the admin account, the own account could not be deleted.
+func (ar *Router) DeleteAdminAccount() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := getRouteVar("id", r)
+
+		if me, _ := adminFromContext(r.Context()); me.ID == id {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, "the own account could not be deleted")
+			return
+		}
+
+		if err := ar.server.Storages().AdminAccount.DeleteAdminAccount(id); err != nil {
+			if err == model.ErrorNotFound {
+				ar.Error(w, err, http.StatusNotFound, "")
+			} else {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+			return
+		}
+		ar.ServeJSON(w, http.StatusOK, nil)
+	}
+}
+
+// accountAdmin returns the account of the logged in admin,
+// the single sign-on admin has no account to change.
+func (ar *Router) accountAdmin(w http.ResponseWriter, r *http.Request) (model.AdminAccount, bool) {
+	admin, _ := adminFromContext(r.Context())
+	if len(admin.ID) == 0 {
+		ar.Error(w, ErrorSSOAccount, http.StatusBadRequest, "")
+		return model.AdminAccount{}, false
+	}
+	return admin, true
+}
+
+func (ar *Router) adminAccountByID(w http.ResponseWriter, id string) (model.AdminAccount, bool) {
+	account, err := ar.server.Storages().AdminAccount.AdminAccountByID(id)
+	if err == model.ErrorNotFound {
+		ar.Error(w, err, http.StatusNotFound, "")
+		return model.AdminAccount{}, false
+	}
+	if err != nil {
+		ar.Error(w, err, http.StatusInternalServerError, "")
+		return model.AdminAccount{}, false
+	}
+	return account, true
+}
+
+// saveAdminAccount saves the changed account and responds with it.
+func (ar *Router) saveAdminAccount(w http.ResponseWriter, account model.AdminAccount) {
+	account.UpdatedAt = time.Now()
+	if err := ar.server.Storages().AdminAccount.UpdateAdminAccount(account); err != nil {
+		if err == model.ErrorNotFound {
+			ar.Error(w, err, http.StatusNotFound, "")
+		} else {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+		}
+		return
+	}
+	ar.ServeJSON(w, http.StatusOK, account.Sanitized())
+}
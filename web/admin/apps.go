@@ -60,6 +60,9 @@ func (ar *Router) CreateApp() http.HandlerFunc {
 		if ar.mustParseJSON(w, r, &ad) != nil {
 			return
 		}
+		if !ar.validateAppWebhooks(w, ad) {
+			return
+		}
 
 		appSecret, err := ar.generateAppSecret(w)
 		if err != nil {
@@ -85,6 +88,9 @@ func (ar *Router) UpdateApp() http.HandlerFunc {
 		if ar.mustParseJSON(w, r, &ad) != nil {
 			return
 		}
+		if !ar.validateAppWebhooks(w, ad) {
+			return
+		}
 
 		if lenSecret := len(ad.Secret); lenSecret < 24 || lenSecret > 48 {
 			err := fmt.Errorf("incorrect appsecret string length %d, expecting 24 to 48 symbols inclusively", lenSecret)
@@ -172,6 +178,17 @@ func (ar *Router) updateAllowedOrigins() error {
 	return ar.originUpdate()
 }
 
+// validateAppWebhooks writes the error response if one of the app webhook subscriptions is invalid.
+func (ar *Router) validateAppWebhooks(w http.ResponseWriter, ad model.AppData) bool {
+	for _, s := range ad.Webhooks {
+		if err := s.Validate(); err != nil {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, fmt.Sprintf("webhook %s: %s", s.URL, err))
+			return false
+		}
+	}
+	return true
+}
+
 func isBase64(s string) bool {
 	_, err := base64.StdEncoding.DecodeString(s)
 	return err == nil
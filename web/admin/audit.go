@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/middleware"
+	"github.com/urfave/negroni"
+)
+
+const (
+	defaultAuditSkip  = 0
+	defaultAuditLimit = 50
+	maxAuditLimit     = 1000
+)
+
+// auditTarget tells which entity the route "id" variable refers to.
+type auditTarget int
+
+const (
+	auditTargetNone auditTarget = iota
+	auditTargetUser
+	auditTargetApp
+)
+
+// audited records the admin operation to the audit log after the handler has responded.
+func (ar *Router) audited(op model.AuditOperation, target auditTarget, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := negroni.NewResponseWriter(w)
+		r, admin := withRequestAdmin(r)
+		h(rw, r)
+
+		record := model.AuditRecord{
+			Source:    model.AuditSourceAdmin,
+			Operation: op,
+			Result:    model.AuditResultSuccess,
+			Status:    rw.Status(),
+			Resource:  r.URL.Path,
+			IP:        middleware.ClientIP(r, ar.server.Settings().General.TrustProxyHeaders),
+			UserAgent: r.UserAgent(),
+		}
+		if rw.Status() >= http.StatusBadRequest {
+			record.Result = model.AuditResultFailure
+		}
+		// the admin is set by the session middleware or the login, the failed login has no admin.
+		record.Actor = admin.account.Login
+
+		switch target {
+		case auditTargetUser:
+			record.UserID = getRouteVar("id", r)
+		case auditTargetApp:
+			record.AppID = getRouteVar("id", r)
+		}
+
+		ar.logger.Info("audit_record",
+			"operation", string(record.Operation),
+			"result", string(record.Result),
+			"status", record.Status,
+			"resource", record.Resource,
+			"ip", record.IP)
+
+		storage := ar.server.Storages().Audit
+		if storage == nil {
+			return
+		}
+		if err := storage.AddRecord(record); err != nil {
+			ar.logger.Error("Unable to save audit record",
+				"operation", string(op),
+				logging.FieldError, err)
+		}
+	}
+}
+
+// FetchAuditRecords fetches audit records filtered by user, app, operation and time range.
+// The time range is set with "from" and "to" query params in RFC 3339 format.
+func (ar *Router) FetchAuditRecords() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := ar.server.Storages().Audit
+		if storage == nil {
+			ar.Error(w, fmt.Errorf("audit storage is not configured"), http.StatusNotFound, "")
+			return
+		}
+
+		skip, limit, err := ar.parseSkipAndLimit(r, defaultAuditSkip, defaultAuditLimit, maxAuditLimit)
+		if err != nil {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		q := r.URL.Query()
+		filter := model.AuditFilter{
+			UserID:    q.Get("user_id"),
+			AppID:     q.Get("app_id"),
+			Operation: model.AuditOperation(q.Get("operation")),
+			Skip:      skip,
+			Limit:     limit,
+		}
+
+		if filter.From, err = parseTimeParam(q.Get("from")); err != nil {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, err.Error())
+			return
+		}
+		if filter.To, err = parseTimeParam(q.Get("to")); err != nil {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		records, total, err := storage.FetchRecords(filter)
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		searchResponse := struct {
+			Records []model.AuditRecord `json:"records"`
+			Total   int                 `json:"total"`
+		}{
+			Records: records,
+			Total:   total,
+		}
+
+		ar.ServeJSON(w, http.StatusOK, &searchResponse)
+	}
+}
+
+// parseTimeParam parses time in RFC 3339 format, empty value is zero time.
+func parseTimeParam(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, expected RFC 3339 format", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/storage/backup"
+)
+
+// Backup streams the backup archive with the apps, management keys, users and invites.
+// The archive could be restored with "identifo restore" command.
+// The storages which could not export the consistent snapshot are backed up with allowWithoutSnapshot=true only.
+func (ar *Router) Backup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storages := ar.server.Storages()
+		bs := backup.Storages{
+			App:            storages.App,
+			User:           storages.User,
+			Invite:         storages.Invite,
+			ManagementKeys: storages.ManagementKey,
+		}
+
+		allowWithoutSnapshot := false
+		if s := r.URL.Query().Get("allowWithoutSnapshot"); s != "" {
+			var err error
+			if allowWithoutSnapshot, err = strconv.ParseBool(s); err != nil {
+				ar.Error(w, ErrorWrongInput, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		if files := bs.WithoutSnapshot(); len(files) > 0 && !allowWithoutSnapshot {
+			ar.Error(w, backup.ErrorNoSnapshot, http.StatusConflict, fmt.Sprintf("%v could not be exported from the snapshot, set allowWithoutSnapshot=true to back them up anyway", files))
+			return
+		}
+
+		filename := fmt.Sprintf("identifo-backup-%s.zip", time.Now().UTC().Format("20060102-150405"))
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.WriteHeader(http.StatusOK)
+
+		// the response has been started, so the error could not be reported to the client.
+		// The archive without the manifest at the end could not be restored.
+		if _, err := backup.Write(w, bs, allowWithoutSnapshot); err != nil {
+			ar.logger.Error("Unable to write backup", logging.FieldError, err)
+		}
+	}
+}
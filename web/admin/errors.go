@@ -17,6 +17,20 @@ const (
 	ErrorIncorrectLogin = Error("Incorrect login information")
 	// ErrorNotAuthorized is for non-authorized access intents.
 	ErrorNotAuthorized = Error("Not authorized")
+	// ErrorForbidden is when the role of the admin account does not grant the permission.
+	ErrorForbidden = Error("The admin account has no permission for this action")
+	// ErrorTOTPRequired is when the admin account has TOTP enabled and the code is not provided.
+	ErrorTOTPRequired = Error("TOTP code is required")
+	// ErrorInvalidTOTP is when the TOTP code is not valid.
+	ErrorInvalidTOTP = Error("Invalid TOTP code")
+	// ErrorOIDCDisabled is when the single sign-on into the admin panel is not enabled.
+	ErrorOIDCDisabled = Error("Single sign-on is not enabled")
+	// ErrorOIDCLogin is when the single sign-on with the identity provider has failed.
+	ErrorOIDCLogin = Error("Single sign-on has failed")
+	// ErrorSSOAccount is when the action requires the admin account, and the admin has logged in with single sign-on.
+	ErrorSSOAccount = Error("The admin is managed by the identity provider")
+	// ErrorPasswordLoginDisabled is when the login with the admin account password is disabled.
+	ErrorPasswordLoginDisabled = Error("Login with password is disabled, use single sign-on")
 	// ErrorAPIRequestBodyParamsInvalid means that request params are corrupted.
 	ErrorAPIRequestBodyParamsInvalid = Error("Input data does not pass validation. Please specify valid params")
 	// ErrorAPIInviteNotFound is when invite not found.
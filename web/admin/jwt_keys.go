@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	jwt "github.com/madappgang/identifo/v2/jwt"
 	"github.com/madappgang/identifo/v2/model"
@@ -16,6 +17,7 @@ type keys struct {
 }
 
 // UploadJWTKeys is for uploading public and private keys used for signing JWTs.
+// The uploaded key becomes active, the tokens signed with the former key are still valid until it expires.
 func (ar *Router) UploadJWTKeys() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		k := keys{}
@@ -30,19 +32,11 @@ func (ar *Router) UploadJWTKeys() http.HandlerFunc {
 			return
 		}
 
-		if err := ar.server.Storages().Key.ReplaceKey([]byte(k.Private)); err != nil {
+		if err := ar.replaceActiveKey([]byte(k.Private)); err != nil {
 			ar.Error(w, err, http.StatusInternalServerError, "")
 			return
 		}
 
-		key, err := ar.server.Storages().Key.LoadPrivateKey()
-		if err != nil {
-			ar.Error(w, err, http.StatusInternalServerError, "")
-			return
-		}
-
-		ar.server.Services().Token.SetPrivateKey(key)
-
 		newkeys := keys{}
 		public := ar.server.Services().Token.PublicKey()
 		publicPEM, err := jwt.MarshalPublicKeyToPEM(public)
@@ -85,7 +79,8 @@ func (ar *Router) GetJWTKeys() http.HandlerFunc {
 	}
 }
 
-// GenerateNewSecret generate new secret key, save it and return new public key
+// GenerateNewSecret generate new secret key, save it and return new public key.
+// The former key is retired, so the issued tokens are still valid.
 func (ar *Router) GenerateNewSecret() http.HandlerFunc {
 	type payload struct {
 		Alg string `json:"alg,omitempty"`
@@ -122,19 +117,11 @@ func (ar *Router) GenerateNewSecret() http.HandlerFunc {
 			return
 		}
 
-		if err := ar.server.Storages().Key.ReplaceKey([]byte(privateKeyPEM)); err != nil {
+		if err := ar.replaceActiveKey([]byte(privateKeyPEM)); err != nil {
 			ar.Error(w, err, http.StatusInternalServerError, "")
 			return
 		}
 
-		key, err := ar.server.Storages().Key.LoadPrivateKey()
-		if err != nil {
-			ar.Error(w, err, http.StatusInternalServerError, "")
-			return
-		}
-
-		ar.server.Services().Token.SetPrivateKey(key)
-
 		newkeys := keys{}
 		public := ar.server.Services().Token.PublicKey()
 		publicPEM, err := jwt.MarshalPublicKeyToPEM(public)
@@ -148,3 +135,118 @@ func (ar *Router) GenerateNewSecret() http.HandlerFunc {
 		ar.ServeJSON(w, http.StatusOK, newkeys)
 	}
 }
+
+// replaceActiveKey makes the key active, the former key is kept in the key ring as retired.
+func (ar *Router) replaceActiveKey(keyPEM []byte) error {
+	if kr := ar.server.Services().KeyRotation; kr != nil {
+		_, err := kr.ReplaceActiveKey(keyPEM)
+		return err
+	}
+
+	// no key ring, replace the key and invalidate all issued tokens
+	if err := ar.server.Storages().Key.ReplaceKey(keyPEM); err != nil {
+		return err
+	}
+
+	key, err := ar.server.Storages().Key.LoadPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	ar.server.Services().Token.SetPrivateKey(key)
+	return nil
+}
+
+type keyRingKey struct {
+	ID        string          `json:"kid"`
+	Status    model.KeyStatus `json:"status"`
+	Algorithm string          `json:"alg"`
+	Public    string          `json:"public"`
+	CreatedAt time.Time       `json:"created_at"`
+	RetiredAt *time.Time      `json:"retired_at,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+}
+
+type keyRing struct {
+	Keys      []keyRingKey `json:"keys"`
+	RotatedAt *time.Time   `json:"rotated_at,omitempty"`
+}
+
+// GetKeyRing returns public parts of the signing keys ring: active, next and retired keys.
+func (ar *Router) GetKeyRing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kr := ar.server.Services().KeyRotation
+		if kr == nil {
+			ar.Error(w, fmt.Errorf("key rotation service is not available"), http.StatusServiceUnavailable, "")
+			return
+		}
+
+		ring, err := publicKeyRing(kr.KeyRing())
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, ring)
+	}
+}
+
+// RotateKeys promotes the next key to active and generates the new next key.
+// The former active key is retired, so the issued tokens are still valid.
+func (ar *Router) RotateKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kr := ar.server.Services().KeyRotation
+		if kr == nil {
+			ar.Error(w, fmt.Errorf("key rotation service is not available"), http.StatusServiceUnavailable, "")
+			return
+		}
+
+		rotated, err := kr.Rotate()
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ring, err := publicKeyRing(rotated)
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, ring)
+	}
+}
+
+func publicKeyRing(ring model.KeyRing) (keyRing, error) {
+	result := keyRing{Keys: []keyRingKey{}}
+	if !ring.RotatedAt.IsZero() {
+		result.RotatedAt = &ring.RotatedAt
+	}
+
+	for _, k := range ring.Keys {
+		private, _, err := jwt.LoadPrivateKeyFromPEMString(k.PrivateKey)
+		if err != nil {
+			return result, fmt.Errorf("error decoding key %s: %v", k.ID, err)
+		}
+		public, err := jwt.MarshalPublicKeyToPEM(jwt.PublicKeyFromPrivate(private))
+		if err != nil {
+			return result, err
+		}
+
+		key := keyRingKey{
+			ID:        k.ID,
+			Status:    k.Status,
+			Algorithm: k.Algorithm,
+			Public:    public,
+			CreatedAt: k.CreatedAt,
+		}
+		if !k.RetiredAt.IsZero() {
+			key.RetiredAt = &k.RetiredAt
+		}
+		if !k.ExpiresAt.IsZero() {
+			key.ExpiresAt = &k.ExpiresAt
+		}
+		result.Keys = append(result.Keys, key)
+	}
+	return result, nil
+}
@@ -4,44 +4,74 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/xlzd/gotp"
 )
 
 type adminLoginData struct {
 	Login    string `json:"email"`
 	Password string `json:"password"`
+	TOTP     string `json:"totp,omitempty"`
 }
 
-// Login logins admin with admin name and password.
+// Login logins admin with admin name and password, and the TOTP code if it is enabled for the account.
 func (ar *Router) Login() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		login, pswd, err := ar.getAdminAccountSettings()
-		if err != nil {
-			ar.Error(w, err, http.StatusBadRequest, "")
+		if oidc := ar.server.Settings().AdminPanel.OIDC; oidc.Enabled && oidc.DisablePasswordLogin {
+			ar.Error(w, ErrorPasswordLoginDisabled, http.StatusForbidden, "")
 			return
 		}
 
 		ld := adminLoginData{}
-		if err = ar.mustParseJSON(w, r, &ld); err != nil {
+		if err := ar.mustParseJSON(w, r, &ld); err != nil {
 			ar.Error(w, fmt.Errorf("unable to parse login and password: %s", err.Error()), http.StatusBadRequest, "")
 			return
 		}
 
-		if (login != ld.Login) || (pswd != ld.Password) {
+		if err := ar.bootstrapAdminAccount(); err != nil {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
+
+		admin, err := ar.server.Storages().AdminAccount.AdminAccountByLogin(model.NormalizeAdminLogin(ld.Login))
+		if err != nil && err != model.ErrorNotFound {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		if err != nil || !admin.Active || !admin.CheckPassword(ld.Password) {
 			ar.Error(w, ErrorIncorrectLogin, http.StatusBadRequest, "")
 			return
 		}
 
+		if admin.TOTP.Enabled {
+			if len(ld.TOTP) == 0 {
+				ar.Error(w, ErrorTOTPRequired, http.StatusBadRequest, "totp_required")
+				return
+			}
+			if !verifyTOTP(admin.TOTP.Secret, ld.TOTP) {
+				ar.Error(w, ErrorIncorrectLogin, http.StatusBadRequest, "")
+				return
+			}
+		}
+
 		session, err := ar.server.Services().Session.NewSession()
 		if err != nil {
 			ar.Error(w, fmt.Errorf("cannot create session: %s", err), http.StatusInternalServerError, "")
 			return
 		}
+		session.AdminID = admin.ID
 
 		if err = ar.server.Storages().Session.InsertSession(session); err != nil {
 			ar.Error(w, fmt.Errorf("cannot insert session: %s", err), http.StatusInternalServerError, "")
 			return
 		}
 
+		// the audit record is made by the admin who has logged in.
+		_, ra := withRequestAdmin(r)
+		ra.account = admin
+
 		c := &http.Cookie{
 			Name:     cookieName,
 			Value:    encode(session.ID),
@@ -54,6 +84,44 @@ func (ar *Router) Login() http.HandlerFunc {
 	}
 }
 
+// bootstrapAdminAccount creates the superadmin account with the credentials from the environment variables,
+// if there are no admin accounts yet. The variables are not used once the first account exists.
+func (ar *Router) bootstrapAdminAccount() error {
+	storage := ar.server.Storages().AdminAccount
+	accounts, err := storage.AdminAccounts()
+	if err != nil {
+		return err
+	}
+	if len(accounts) > 0 {
+		return nil
+	}
+
+	login, pswd, err := ar.getAdminAccountSettings()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	admin, err := storage.AddAdminAccount(model.AdminAccount{
+		Login:        model.NormalizeAdminLogin(login),
+		Role:         model.AdminRoleSuperadmin,
+		Active:       true,
+		PasswordHash: model.PasswordHash(pswd),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	if err == model.ErrorAdminAccountExists {
+		// created by the concurrent login.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ar.logger.Info("Superadmin account is created from the environment variables", "login", admin.Login)
+	return nil
+}
+
 func (ar *Router) getAdminAccountSettings() (string, string, error) {
 	loginEnvName := ar.server.Settings().AdminAccount.LoginEnvName
 	pswdEnvName := ar.server.Settings().AdminAccount.PasswordEnvName
@@ -70,3 +138,8 @@ func (ar *Router) getAdminAccountSettings() (string, string, error) {
 
 	return login, password, nil
 }
+
+// verifyTOTP verifies the code with the current time.
+func verifyTOTP(secret, code string) bool {
+	return len(secret) > 0 && gotp.NewDefaultTOTP(secret).Verify(code, time.Now().Unix())
+}
@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// userLockout is a state of the user account login lockout.
+type userLockout struct {
+	Enabled      bool       `json:"enabled"`
+	Failures     int        `json:"failures"`
+	LastFailedAt *time.Time `json:"last_failed_at,omitempty"`
+	Locked       bool       `json:"locked"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
+
+// GetUserLockout returns failed login attempts and lockout of the user account.
+func (ar *Router) GetUserLockout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		if _, err := ar.server.Storages().User.UserByID(userID); err != nil {
+			if err == model.ErrUserNotFound {
+				ar.Error(w, err, http.StatusNotFound, "")
+			} else {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+			return
+		}
+
+		settings := ar.server.Settings().Login.Lockout
+		storage := ar.server.Storages().LoginAttempt
+		if !settings.Enabled || storage == nil {
+			ar.ServeJSON(w, http.StatusOK, userLockout{})
+			return
+		}
+
+		attempts, err := storage.LoginAttempts(model.AccountLoginAttemptsKey(userID))
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		result := userLockout{Enabled: true, Failures: attempts.Failures}
+		if attempts.Failures > 0 {
+			result.LastFailedAt = &attempts.LastFailedAt
+		}
+		if lockedUntil := settings.AccountLockedUntil(attempts); lockedUntil.After(time.Now()) {
+			result.Locked = true
+			result.LockedUntil = &lockedUntil
+		}
+		ar.ServeJSON(w, http.StatusOK, result)
+	}
+}
+
+// ResetUserLockout clears failed login attempts and unlocks the user account.
+func (ar *Router) ResetUserLockout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		storage := ar.server.Storages().LoginAttempt
+		if storage == nil {
+			ar.ServeJSON(w, http.StatusOK, nil)
+			return
+		}
+
+		if err := storage.ResetLoginAttempts(model.AccountLoginAttemptsKey(userID)); err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.logger.Info("User login lockout reset",
+			logging.FieldUserID, userID)
+		ar.ServeJSON(w, http.StatusOK, nil)
+	}
+}
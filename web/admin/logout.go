@@ -33,6 +33,14 @@ func (ar *Router) Logout() http.HandlerFunc {
 			ar.Error(w, ErrorRequestInvalidCookie, http.StatusBadRequest, "")
 			return
 		}
+		// the audit record is made by the admin who logs out.
+		if session, err := ar.server.Storages().Session.GetSession(sessionID); err == nil {
+			if admin, _, err := ar.sessionAdmin(session); err == nil {
+				_, ra := withRequestAdmin(r)
+				ra.account = admin
+			}
+		}
+
 		if err := ar.server.Storages().Session.DeleteSession(sessionID); err != nil {
 			ar.Error(w, ErrorInternalError, http.StatusInternalServerError, "")
 			return
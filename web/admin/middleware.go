@@ -1,58 +1,123 @@
 package admin
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
 	"github.com/urfave/negroni"
 )
 
-// Session is a middleware to check if admin is logged in with valid cookie.
+type adminContextKey struct{}
+
+// requestAdmin is the admin who makes the request.
+// It is set by the session middleware, or by the login handler, so the audit log could record it.
+type requestAdmin struct {
+	account     model.AdminAccount
+	permissions []model.AdminPermission
+}
+
+// withRequestAdmin returns the request with the admin holder in the context, the existing holder is reused.
+func withRequestAdmin(r *http.Request) (*http.Request, *requestAdmin) {
+	if ra, ok := r.Context().Value(adminContextKey{}).(*requestAdmin); ok {
+		return r, ra
+	}
+	ra := &requestAdmin{}
+	return r.WithContext(context.WithValue(r.Context(), adminContextKey{}, ra)), ra
+}
+
+// adminFromContext returns the admin who makes the request.
+// The single sign-on admin has no account ID.
+func adminFromContext(ctx context.Context) (model.AdminAccount, bool) {
+	ra, ok := ctx.Value(adminContextKey{}).(*requestAdmin)
+	if !ok || len(ra.account.Login) == 0 {
+		return model.AdminAccount{}, false
+	}
+	return ra.account, true
+}
+
+// permissionsFromContext returns the permissions of the admin who makes the request.
+func permissionsFromContext(ctx context.Context) []model.AdminPermission {
+	ra, ok := ctx.Value(adminContextKey{}).(*requestAdmin)
+	if !ok {
+		return nil
+	}
+	return ra.permissions
+}
+
+// Session is a middleware to check if admin is logged in with valid cookie,
+// and the role of the admin account grants the permission.
 // If all checks succeeded, prolongs existing session.
 // If not, forces to login.
-func (ar *Router) Session() negroni.HandlerFunc {
+func (ar *Router) Session(permission model.AdminPermission) negroni.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-		if ar.isLoggedIn(w, r) {
-			sessionID, err := ar.getSessionID(r)
-			if err != nil {
-				ar.Error(w, ErrorNotAuthorized, http.StatusUnauthorized, err.Error())
-				return
-			}
-			ar.prolongSession(w, sessionID)
-			next(w, r)
+		session, admin, permissions, ok := ar.loggedInAdmin(w, r)
+		if !ok {
+			return
 		}
-	}
-}
 
-// IsLoggedIn checks if admin is logged in.
-func (ar *Router) IsLoggedIn() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if ar.isLoggedIn(w, r) {
-			ar.ServeJSON(w, http.StatusOK, nil)
+		if !model.HasAdminPermission(permissions, permission) {
+			ar.Error(w, ErrorForbidden, http.StatusForbidden, string(permission))
+			return
 		}
+
+		ar.prolongSession(w, session.ID)
+		r, ra := withRequestAdmin(r)
+		ra.account = admin
+		ra.permissions = permissions
+		next(w, r)
 	}
 }
 
-func (ar *Router) isLoggedIn(w http.ResponseWriter, r *http.Request) bool {
+// loggedInAdmin returns the session, the active admin and the admin permissions of the request.
+func (ar *Router) loggedInAdmin(w http.ResponseWriter, r *http.Request) (model.Session, model.AdminAccount, []model.AdminPermission, bool) {
 	sessionID, err := ar.getSessionID(r)
 	if err != nil {
 		ar.Error(w, ErrorNotAuthorized, http.StatusUnauthorized, err.Error())
-		return false
+		return model.Session{}, model.AdminAccount{}, nil, false
 	}
 
 	session, err := ar.server.Storages().Session.GetSession(sessionID)
 	if err != nil {
 		ar.Error(w, err, http.StatusUnauthorized, err.Error())
-		return false
+		return model.Session{}, model.AdminAccount{}, nil, false
 	}
 
 	if time.Unix(session.ExpirationTime, 0).Before(time.Now()) {
 		ar.Error(w, ErrorNotAuthorized, http.StatusUnauthorized, "")
-		return false
+		return model.Session{}, model.AdminAccount{}, nil, false
 	}
 
-	return true
+	admin, permissions, err := ar.sessionAdmin(session)
+	if err != nil {
+		ar.Error(w, ErrorNotAuthorized, http.StatusUnauthorized, "")
+		return model.Session{}, model.AdminAccount{}, nil, false
+	}
+
+	return session, admin, permissions, true
+}
+
+// sessionAdmin returns the admin of the session and the admin permissions.
+// The single sign-on admin has no account, the account is made of the session.
+func (ar *Router) sessionAdmin(session model.Session) (model.AdminAccount, []model.AdminPermission, error) {
+	if len(session.AdminID) == 0 && len(session.AdminLogin) > 0 {
+		if !ar.server.Settings().AdminPanel.OIDC.Enabled {
+			return model.AdminAccount{}, nil, ErrorOIDCDisabled
+		}
+		return model.AdminAccount{Login: session.AdminLogin, Active: true}, session.AdminPermissions, nil
+	}
+
+	// the sessions created before the admin accounts have no account, the admin should log in again.
+	admin, err := ar.server.Storages().AdminAccount.AdminAccountByID(session.AdminID)
+	if err != nil {
+		return model.AdminAccount{}, nil, err
+	}
+	if !admin.Active {
+		return model.AdminAccount{}, nil, ErrorNotAuthorized
+	}
+	return admin, admin.Role.Permissions(), nil
 }
 
 func (ar *Router) prolongSession(w http.ResponseWriter, sessionID string) {
@@ -0,0 +1,272 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/oidcauth"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcStateCookieName keeps the state of the single sign-on between the redirect to the provider and the callback.
+	oidcStateCookieName = "AdminOIDCState"
+	// oidcProviderKey is the key prefix of the admin panel provider in the provider cache.
+	oidcProviderKey = "_admin:oidc:"
+	// oidcStateMaxAge is the time for the admin to log in with the provider, in seconds.
+	oidcStateMaxAge = 600
+
+	defaultPanelURL = "/adminpanel"
+)
+
+// oidcState is the state of the single sign-on, the verifier is the PKCE code verifier.
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// LoginOptions tells the admin panel which login ways are available.
+func (ar *Router) LoginOptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := ar.server.Settings().AdminPanel.OIDC
+		ar.ServeJSON(w, http.StatusOK, map[string]bool{
+			"password": !settings.Enabled || !settings.DisablePasswordLogin,
+			"oidc":     settings.Enabled,
+		})
+	}
+}
+
+// OIDCLogin starts the single sign-on into the admin panel, it redirects the admin to the identity provider.
+func (ar *Router) OIDCLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := ar.server.Settings().AdminPanel.OIDC
+		if !settings.Enabled {
+			ar.Error(w, ErrorOIDCDisabled, http.StatusNotFound, "")
+			return
+		}
+
+		config, _, err := ar.oidcConfig(r, settings)
+		if err != nil {
+			ar.Error(w, ErrorOIDCLogin, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		st := oidcState{
+			State:    oauth2.GenerateVerifier(),
+			Nonce:    oauth2.GenerateVerifier(),
+			Verifier: oauth2.GenerateVerifier(),
+		}
+		data, err := json.Marshal(st)
+		if err != nil {
+			ar.Error(w, ErrorInternalError, http.StatusInternalServerError, err.Error())
+			return
+		}
+		ar.setOIDCStateCookie(w, encode(string(data)), oidcStateMaxAge)
+
+		authURL := config.AuthCodeURL(st.State,
+			oauth2.S256ChallengeOption(st.Verifier),
+			oidc.Nonce(st.Nonce))
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// OIDCLoginComplete completes the single sign-on: exchanges the code, maps the groups of the admin to the permissions
+// and starts the admin session.
+func (ar *Router) OIDCLoginComplete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := ar.server.Settings().AdminPanel.OIDC
+		if !settings.Enabled {
+			ar.Error(w, ErrorOIDCDisabled, http.StatusNotFound, "")
+			return
+		}
+
+		st, err := ar.oidcStateFromCookie(r)
+		// the state is single use
+		ar.setOIDCStateCookie(w, "", -1)
+		if err != nil {
+			ar.Error(w, ErrorOIDCLogin, http.StatusBadRequest, "no single sign-on state")
+			return
+		}
+
+		q := r.URL.Query()
+		if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(st.State)) != 1 {
+			ar.Error(w, ErrorOIDCLogin, http.StatusBadRequest, "state mismatch")
+			return
+		}
+		if e := q.Get("error"); len(e) > 0 {
+			ar.Error(w, ErrorOIDCLogin, http.StatusUnauthorized, e+": "+q.Get("error_description"))
+			return
+		}
+
+		config, verifier, err := ar.oidcConfig(r, settings)
+		if err != nil {
+			ar.Error(w, ErrorOIDCLogin, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		claims, _, err := oidcauth.Exchange(r.Context(), config, verifier, q.Get("code"), oauth2.VerifierOption(st.Verifier))
+		if err != nil {
+			ar.Error(w, ErrorOIDCLogin, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if nonce, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(nonce), []byte(st.Nonce)) != 1 {
+			ar.Error(w, ErrorOIDCLogin, http.StatusUnauthorized, "nonce mismatch")
+			return
+		}
+
+		login := ""
+		if values := claimStrings(claims, settings.LoginClaimField()); len(values) > 0 {
+			login = model.NormalizeAdminLogin(values[0])
+		}
+		if len(login) == 0 {
+			ar.Error(w, ErrorOIDCLogin, http.StatusUnauthorized, "no "+settings.LoginClaimField()+" claim")
+			return
+		}
+		// the provider could let the user set any unverified email.
+		if settings.LoginClaimField() == "email" && !claimTrue(claims, "email_verified") {
+			ar.Error(w, ErrorOIDCLogin, http.StatusUnauthorized, "email is not verified")
+			return
+		}
+
+		admin := model.AdminAccount{Login: login, Active: true}
+		admin.Name, _ = claims["name"].(string)
+		// the failed login is recorded with the admin, who has been authenticated by the provider.
+		_, ra := withRequestAdmin(r)
+		ra.account = admin
+
+		groups := claimStrings(claims, settings.GroupsClaimField())
+		permissions := settings.Permissions(groups)
+		if !settings.IsAllowed(login, groups) {
+			permissions = nil
+		}
+		if len(permissions) == 0 {
+			ar.logger.Warn("Admin single sign-on has no permissions",
+				"login", login,
+				"groups", groups)
+			ar.Error(w, ErrorForbidden, http.StatusForbidden, "")
+			return
+		}
+		ra.permissions = permissions
+
+		session, err := ar.server.Services().Session.NewSession()
+		if err != nil {
+			ar.Error(w, ErrorInternalError, http.StatusInternalServerError, err.Error())
+			return
+		}
+		session.AdminLogin = login
+		session.AdminPermissions = permissions
+
+		if err = ar.server.Storages().Session.InsertSession(session); err != nil {
+			ar.logger.Error("Cannot insert admin session", logging.FieldError, err)
+			ar.Error(w, ErrorInternalError, http.StatusInternalServerError, "")
+			return
+		}
+
+		c := &http.Cookie{
+			Name:     cookieName,
+			Value:    encode(session.ID),
+			Path:     "/",
+			MaxAge:   ar.server.Services().Session.SessionDurationSeconds(),
+			HttpOnly: true,
+		}
+		http.SetCookie(w, c)
+
+		panelURL := settings.PanelURL
+		if len(panelURL) == 0 {
+			panelURL = defaultPanelURL
+		}
+		http.Redirect(w, r, panelURL, http.StatusFound)
+	}
+}
+
+// oidcConfig returns the OAuth2 client of the admin panel provider.
+func (ar *Router) oidcConfig(r *http.Request, settings model.AdminPanelOIDCSettings) (oauth2.Config, *oidc.IDTokenVerifier, error) {
+	provider, verifier, err := oidcauth.Provider(r.Context(),
+		// the changed settings are discovered again
+		oidcProviderKey+settings.ProviderURL+":"+settings.ClientID,
+		settings.OIDCSettings())
+	if err != nil {
+		return oauth2.Config{}, nil, err
+	}
+
+	redirectURL := settings.RedirectURL
+	if len(redirectURL) == 0 && ar.Host != nil {
+		redirectURL = strings.TrimSuffix(ar.Host.String(), "/") + ar.PathPrefix + "/oidc/callback"
+	}
+
+	return oauth2.Config{
+		ClientID:     settings.ClientID,
+		ClientSecret: settings.ClientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     provider.Endpoint(),
+		// "openid" is a required scope for OpenID Connect flows.
+		Scopes: append([]string{oidc.ScopeOpenID}, settings.Scopes...),
+	}, verifier, nil
+}
+
+func (ar *Router) setOIDCStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    value,
+		Path:     ar.PathPrefix + "/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		// the cookie is sent with the redirect from the provider.
+		SameSite: http.SameSiteLaxMode,
+		Secure:   ar.Host != nil && ar.Host.Scheme == "https",
+	})
+}
+
+func (ar *Router) oidcStateFromCookie(r *http.Request) (oidcState, error) {
+	st := oidcState{}
+
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil {
+		return st, err
+	}
+	data, err := decode(cookie.Value)
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal([]byte(data), &st); err != nil {
+		return st, err
+	}
+	if len(st.State) == 0 {
+		return st, ErrorOIDCLogin
+	}
+	return st, nil
+}
+
+// claimStrings returns the claim as the list of strings, the claim could be a string or a list of strings.
+func claimStrings(claims map[string]any, key string) []string {
+	switch v := claims[key].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// claimTrue returns true if the claim is true, some providers send the boolean claims as strings.
+func claimTrue(claims map[string]any, key string) bool {
+	switch v := claims[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
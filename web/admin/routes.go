@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/madappgang/identifo/v2/model"
 	"github.com/urfave/negroni"
 )
 
@@ -13,108 +14,99 @@ func (ar *Router) initRoutes() {
 		panic("Empty admin router")
 	}
 
-	ar.router.Path("/me").Handler(negroni.New(
-		negroni.WrapFunc(ar.IsLoggedIn()),
-	)).Methods("GET")
+	read := model.AdminPermissionRead
+	manageUsers := model.AdminPermissionManageUsers
+	manageApps := model.AdminPermissionManageApps
+	manageServer := model.AdminPermissionManageServer
 
 	ar.router.Path("/login").Handler(negroni.New(
-		negroni.WrapFunc(ar.Login()),
+		negroni.WrapFunc(ar.audited(model.AuditOperationAdminLogin, auditTargetNone, ar.Login())),
 	)).Methods("POST")
 
+	ar.router.Path("/login/options").HandlerFunc(ar.LoginOptions()).Methods("GET")
+	ar.router.Path("/oidc/login").HandlerFunc(ar.OIDCLogin()).Methods("GET")
+	ar.router.Path("/oidc/callback").Handler(negroni.New(
+		negroni.WrapFunc(ar.audited(model.AuditOperationAdminOIDCLogin, auditTargetNone, ar.OIDCLoginComplete())),
+	)).Methods("GET")
+
 	ar.router.Path("/logout").Handler(negroni.New(
-		negroni.WrapFunc(ar.Logout()),
+		negroni.WrapFunc(ar.audited(model.AuditOperationAdminLogout, auditTargetNone, ar.Logout())),
 	)).Methods("POST")
 
-	ar.router.Path("/apps").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.FetchApps()),
-	)).Methods("GET")
-	ar.router.Path("/federated-providers").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.FederatedProvidersList()),
-	)).Methods("GET")
-	ar.router.Path("/apps").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.CreateApp()),
-	)).Methods("POST")
+	ar.router.Path("/me").Handler(ar.withSession(read, ar.GetMe())).Methods("GET")
+	ar.router.Path("/me/password").Handler(ar.withSession(read, ar.audited(model.AuditOperationAdminChangePassword, auditTargetNone, ar.ChangeMyPassword()))).Methods("POST")
+	ar.router.Path("/me/totp").Handler(ar.withSession(read, ar.SetupMyTOTP())).Methods("POST")
+	ar.router.Path("/me/totp/enable").Handler(ar.withSession(read, ar.audited(model.AuditOperationAdminEnableTOTP, auditTargetNone, ar.EnableMyTOTP()))).Methods("POST")
+	ar.router.Path("/me/totp/disable").Handler(ar.withSession(read, ar.audited(model.AuditOperationAdminDisableTOTP, auditTargetNone, ar.DisableMyTOTP()))).Methods("POST")
+
+	ar.router.Path("/admins").Handler(ar.withSession(manageServer, ar.FetchAdminAccounts())).Methods("GET")
+	ar.router.Path("/admins").Handler(ar.withSession(manageServer, ar.audited(model.AuditOperationAdminCreateAdmin, auditTargetNone, ar.CreateAdminAccount()))).Methods("POST")
+	ar.router.Path("/admins/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageServer, ar.GetAdminAccount())).Methods("GET")
+	ar.router.Path("/admins/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageServer, ar.audited(model.AuditOperationAdminUpdateAdmin, auditTargetNone, ar.UpdateAdminAccount()))).Methods("PUT")
+	ar.router.Path("/admins/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageServer, ar.audited(model.AuditOperationAdminDeleteAdmin, auditTargetNone, ar.DeleteAdminAccount()))).Methods("DELETE")
+
+	ar.router.Path("/apps").Handler(ar.withSession(read, ar.FetchApps())).Methods("GET")
+	ar.router.Path("/federated-providers").Handler(ar.withSession(read, ar.FederatedProvidersList())).Methods("GET")
+	ar.router.Path("/apps").Handler(ar.withSession(manageApps, ar.audited(model.AuditOperationAdminCreateApp, auditTargetNone, ar.CreateApp()))).Methods("POST")
 
 	apps := mux.NewRouter().PathPrefix("/apps").Subrouter()
-	ar.router.PathPrefix("/apps").Handler(negroni.New(
-		ar.Session(),
-		negroni.Wrap(apps),
-	))
-	apps.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.GetApp()).Methods("GET")
-	apps.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.UpdateApp()).Methods("PUT")
-	apps.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.DeleteApp()).Methods("DELETE")
-	apps.Path("/").HandlerFunc(ar.DeleteAllApps()).Methods("DELETE")
-
-	ar.router.Path("/users").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.FetchUsers()),
-	)).Methods("GET")
-	ar.router.Path("/users").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.CreateUser()),
-	)).Methods("POST")
+	ar.router.PathPrefix("/apps").Handler(apps)
+	apps.Path("/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(read, ar.GetApp())).Methods("GET")
+	apps.Path("/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageApps, ar.audited(model.AuditOperationAdminUpdateApp, auditTargetApp, ar.UpdateApp()))).Methods("PUT")
+	apps.Path("/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageApps, ar.audited(model.AuditOperationAdminDeleteApp, auditTargetApp, ar.DeleteApp()))).Methods("DELETE")
+	apps.Path("/").Handler(ar.withSession(manageApps, ar.audited(model.AuditOperationAdminDeleteAllApps, auditTargetNone, ar.DeleteAllApps()))).Methods("DELETE")
+
+	ar.router.Path("/users").Handler(ar.withSession(read, ar.FetchUsers())).Methods("GET")
+	ar.router.Path("/users").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminCreateUser, auditTargetNone, ar.CreateUser()))).Methods("POST")
 
 	users := mux.NewRouter().PathPrefix("/users").Subrouter()
-	ar.router.PathPrefix("/users").Handler(negroni.New(
-		ar.Session(),
-		negroni.Wrap(users),
-	))
-	users.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.GetUser()).Methods("GET")
-	users.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.UpdateUser()).Methods("PUT")
-	users.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.DeleteUser()).Methods("DELETE")
-	users.Path("/generate_new_reset_token").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.GenerateNewResetTokenUser()),
-	)).Methods("POST")
+	ar.router.PathPrefix("/users").Handler(users)
+	users.Path("/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(read, ar.GetUser())).Methods("GET")
+	users.Path("/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminUpdateUser, auditTargetUser, ar.UpdateUser()))).Methods("PUT")
+	users.Path("/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminDeleteUser, auditTargetUser, ar.DeleteUser()))).Methods("DELETE")
+	users.Path("/{id:[a-zA-Z0-9]+}/lockout").Handler(ar.withSession(read, ar.GetUserLockout())).Methods("GET")
+	users.Path("/{id:[a-zA-Z0-9]+}/lockout").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminResetUserLockout, auditTargetUser, ar.ResetUserLockout()))).Methods("DELETE")
+	users.Path("/{id:[a-zA-Z0-9]+}/sessions").Handler(ar.withSession(read, ar.GetUserSessions())).Methods("GET")
+	users.Path("/{id:[a-zA-Z0-9]+}/sessions").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminRevokeUserSessions, auditTargetUser, ar.DeleteUserSessions()))).Methods("DELETE")
+	users.Path("/{id:[a-zA-Z0-9]+}/sessions/{session_id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminRevokeUserSession, auditTargetUser, ar.DeleteUserSession()))).Methods("DELETE")
+	users.Path("/generate_new_reset_token").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminGenerateResetToken, auditTargetNone, ar.GenerateNewResetTokenUser()))).Methods("POST")
 
-	ar.router.Path("/settings").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.FetchSettings()),
-	)).Methods("GET")
+	ar.router.Path("/settings").Handler(ar.withSession(manageServer, ar.FetchSettings())).Methods("GET")
+	ar.router.Path("/settings").Handler(ar.withSession(manageServer, ar.audited(model.AuditOperationAdminUpdateSettings, auditTargetNone, ar.UpdateSettings()))).Methods("PUT")
+	ar.router.Path("/test_connection").Handler(ar.withSession(manageServer, ar.TestConnection())).Methods("POST")
 
-	ar.router.Path("/settings").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.UpdateSettings()),
-	)).Methods("PUT")
+	ar.router.Path("/generate_new_secret").Handler(ar.withSession(manageApps, ar.audited(model.AuditOperationAdminGenerateSecret, auditTargetNone, ar.GenerateNewSecret()))).Methods("POST")
 
-	ar.router.Path("/test_connection").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.TestConnection()),
-	)).Methods("POST")
+	ar.router.Path("/invites").Handler(ar.withSession(read, ar.FetchInvites())).Methods("GET")
+	ar.router.Path("/invites").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminAddInvite, auditTargetNone, ar.AddInvite()))).Methods("POST")
 
-	ar.router.Path("/generate_new_secret").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.GenerateNewSecret()),
-	)).Methods("POST")
+	ar.router.Path("/backup").Handler(ar.withSession(manageServer, ar.audited(model.AuditOperationAdminBackup, auditTargetNone, ar.Backup()))).Methods(http.MethodGet)
 
-	ar.router.Path("/invites").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.FetchInvites()),
-	)).Methods("GET")
+	ar.router.Path("/audit").Handler(ar.withSession(read, ar.FetchAuditRecords())).Methods(http.MethodGet)
 
-	ar.router.Path("/invites").Handler(negroni.New(
-		ar.Session(),
-		negroni.WrapFunc(ar.AddInvite()),
-	)).Methods("POST")
+	webhooks := mux.NewRouter().PathPrefix("/webhooks").Subrouter()
+	ar.router.PathPrefix("/webhooks").Handler(webhooks)
+	webhooks.Path("/deliveries").Handler(ar.withSession(read, ar.FetchWebhookDeliveries())).Methods(http.MethodGet)
+	webhooks.Path("/deliveries/{id:[a-zA-Z0-9]+}").Handler(ar.withSession(read, ar.GetWebhookDelivery())).Methods(http.MethodGet)
+	webhooks.Path("/deliveries/{id:[a-zA-Z0-9]+}/replay").Handler(ar.withSession(manageServer, ar.audited(model.AuditOperationAdminReplayWebhook, auditTargetNone, ar.ReplayWebhookDelivery()))).Methods(http.MethodPost)
 
 	invites := mux.NewRouter().PathPrefix("/invites").Subrouter()
-	ar.router.PathPrefix("/invites").Handler(negroni.New(
-		ar.Session(),
-		negroni.Wrap(invites),
-	))
-
-	invites.Path("{id:[a-zA-Z0-9]+}").HandlerFunc(ar.GetInviteByID()).Methods(http.MethodGet)
-	invites.Path("{id:[a-zA-Z0-9]+}").HandlerFunc(ar.ArchiveInviteByID()).Methods(http.MethodDelete)
+	ar.router.PathPrefix("/invites").Handler(invites)
+	invites.Path("{id:[a-zA-Z0-9]+}").Handler(ar.withSession(read, ar.GetInviteByID())).Methods(http.MethodGet)
+	invites.Path("{id:[a-zA-Z0-9]+}").Handler(ar.withSession(manageUsers, ar.audited(model.AuditOperationAdminArchiveInvite, auditTargetNone, ar.ArchiveInviteByID()))).Methods(http.MethodDelete)
 
 	static := mux.NewRouter().PathPrefix("/static").Subrouter()
-	ar.router.PathPrefix("/static").Handler(negroni.New(
-		ar.Session(),
-		negroni.Wrap(static),
-	))
+	ar.router.PathPrefix("/static").Handler(static)
+	static.Path("/uploads/keys").Handler(ar.withSession(manageServer, ar.audited(model.AuditOperationAdminUploadKeys, auditTargetNone, ar.UploadJWTKeys()))).Methods("POST")
+	static.Path("/keys").Handler(ar.withSession(manageServer, ar.GetJWTKeys())).Methods("GET")
+	static.Path("/keys/ring").Handler(ar.withSession(manageServer, ar.GetKeyRing())).Methods("GET")
+	static.Path("/keys/rotate").Handler(ar.withSession(manageServer, ar.audited(model.AuditOperationAdminRotateKeys, auditTargetNone, ar.RotateKeys()))).Methods("POST")
+}
 
-	static.Path("/uploads/keys").HandlerFunc(ar.UploadJWTKeys()).Methods("POST")
-	static.Path("/keys").HandlerFunc(ar.GetJWTKeys()).Methods("GET")
+// withSession protects the handler with the session, which role grants the permission.
+func (ar *Router) withSession(permission model.AdminPermission, h http.HandlerFunc) http.Handler {
+	return negroni.New(
+		ar.Session(permission),
+		negroni.WrapFunc(h),
+	)
 }
@@ -21,6 +21,7 @@ type ServerSettingsAPI struct {
 	AdminPanel     *model.AdminPanelSettings     `json:"admin_panel"`
 	LoginWebApp    *model.FileStorageSettings    `json:"login_web_app"`
 	EmailTemplates *model.FileStorageSettings    `json:"email_templates"`
+	Webhooks       *model.WebhookSettings        `json:"webhooks,omitempty"`
 }
 
 type StorageSettingsAPI struct {
@@ -30,6 +31,11 @@ type StorageSettingsAPI struct {
 	TokenBlacklist          *model.DatabaseSettings `json:"token_blacklist,omitempty"`
 	VerificationCodeStorage *model.DatabaseSettings `json:"verification_code_storage,omitempty"`
 	InviteStorage           *model.DatabaseSettings `json:"invite_storage,omitempty"`
+	WebAuthnStorage         *model.DatabaseSettings `json:"webauthn_storage,omitempty"`
+	LoginAttemptStorage     *model.DatabaseSettings `json:"login_attempt_storage,omitempty"`
+	AuditStorage            *model.DatabaseSettings `json:"audit_storage,omitempty"`
+	WebhookStorage          *model.DatabaseSettings `json:"webhook_storage,omitempty"`
+	UserSessionStorage      *model.DatabaseSettings `json:"user_session_storage,omitempty"`
 }
 
 // FetchSettings returns server settings.
@@ -119,6 +125,26 @@ func mergeSettings(settings model.ServerSettings, updatedSettings ServerSettings
 			settings.Storage.InviteStorage = *updatedSettings.Storage.InviteStorage
 			changed = true
 		}
+		if updatedSettings.Storage.WebAuthnStorage != nil {
+			settings.Storage.WebAuthnStorage = *updatedSettings.Storage.WebAuthnStorage
+			changed = true
+		}
+		if updatedSettings.Storage.LoginAttemptStorage != nil {
+			settings.Storage.LoginAttemptStorage = *updatedSettings.Storage.LoginAttemptStorage
+			changed = true
+		}
+		if updatedSettings.Storage.AuditStorage != nil {
+			settings.Storage.AuditStorage = *updatedSettings.Storage.AuditStorage
+			changed = true
+		}
+		if updatedSettings.Storage.WebhookStorage != nil {
+			settings.Storage.WebhookStorage = *updatedSettings.Storage.WebhookStorage
+			changed = true
+		}
+		if updatedSettings.Storage.UserSessionStorage != nil {
+			settings.Storage.UserSessionStorage = *updatedSettings.Storage.UserSessionStorage
+			changed = true
+		}
 	}
 
 	if updatedSettings.SessionStorage != nil {
@@ -166,6 +192,11 @@ func mergeSettings(settings model.ServerSettings, updatedSettings ServerSettings
 		changed = true
 	}
 
+	if updatedSettings.Webhooks != nil {
+		settings.Webhooks = *updatedSettings.Webhooks
+		changed = true
+	}
+
 	// we need to go section by section and check nee settings
 	return settings, changed
 }
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/services/mail"
 	"github.com/madappgang/identifo/v2/storage"
 )
 
@@ -17,7 +18,18 @@ func (ar *Router) TestConnection() http.HandlerFunc {
 			return
 		}
 
-		tester, err := storage.NewConnectionTester(tc)
+		var tester model.ConnectionTester
+		var err error
+		switch tc.Type {
+		case model.TTEmailService:
+			if tc.Email == nil {
+				ar.Error(w, fmt.Errorf("empty email service settings for testing type %s", tc.Type), http.StatusBadRequest, "")
+				return
+			}
+			tester, err = mail.NewConnectionTester(ar.logger, *tc.Email)
+		default:
+			tester, err = storage.NewConnectionTester(tc)
+		}
 		if err != nil {
 			ar.Error(w, fmt.Errorf("error creating connection tester: %v", err), http.StatusBadRequest, "")
 			return
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// GetUserSessions returns the active sessions of the user.
+func (ar *Router) GetUserSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		sessions, err := ar.server.Storages().UserSession.UserSessions(userID)
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		ar.ServeJSON(w, http.StatusOK, map[string]any{"sessions": sessions})
+	}
+}
+
+// DeleteUserSession ends the session of the user.
+func (ar *Router) DeleteUserSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+		storage := ar.server.Storages().UserSession
+
+		session, err := storage.SessionByID(getRouteVar("session_id", r))
+		if err == nil && session.UserID != userID {
+			err = model.ErrorNotFound
+		}
+		if err == nil {
+			err = storage.DeleteSession(session.ID)
+		}
+		if err != nil {
+			if errors.Is(err, model.ErrorNotFound) {
+				ar.Error(w, err, http.StatusNotFound, "")
+			} else {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+			return
+		}
+
+		ar.logger.Info("User session ended",
+			logging.FieldUserID, userID,
+			"sessionID", session.ID)
+		ar.ServeJSON(w, http.StatusOK, nil)
+	}
+}
+
+// DeleteUserSessions ends all sessions of the user.
+func (ar *Router) DeleteUserSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		deleted, err := ar.server.Storages().UserSession.DeleteUserSessions(userID)
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.logger.Info("User sessions ended",
+			logging.FieldUserID, userID,
+			"sessions", deleted)
+		ar.ServeJSON(w, http.StatusOK, map[string]int{"sessions": deleted})
+	}
+}
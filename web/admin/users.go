@@ -156,9 +156,10 @@ func (ar *Router) UpdateUser() http.HandlerFunc {
 			return
 		}
 
-		if u.TFAInfo.IsEnabled == existing.TFAInfo.IsEnabled {
-			u.TFAInfo = existing.TFAInfo
-		}
+		// admin only switches TFA, the enrolled factors are kept while it's enabled
+		isEnabled := u.TFAInfo.IsEnabled
+		u.TFAInfo = existing.TFAInfo
+		u.TFAInfo.IsEnabled = isEnabled
 
 		if !u.TFAInfo.IsEnabled {
 			u.TFAInfo = model.TFAInfo{
@@ -204,6 +205,16 @@ func (ar *Router) DeleteUser() http.HandlerFunc {
 			return
 		}
 
+		if _, err := ar.server.Storages().UserSession.DeleteUserSessions(userID); err != nil {
+			ar.logger.Error("Unable to end sessions of deleted user",
+				logging.FieldUserID, userID,
+				logging.FieldError, err)
+		}
+
+		if ws := ar.server.Services().Webhook; ws != nil {
+			ws.Emit(model.WebhookEventUserDeleted, model.AppData{}, userID, nil)
+		}
+
 		ar.logger.Info("User deleted",
 			logging.FieldUserID, userID)
 		ar.ServeJSON(w, http.StatusOK, nil)
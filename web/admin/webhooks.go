@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	defaultWebhookDeliveriesSkip  = 0
+	defaultWebhookDeliveriesLimit = 50
+	maxWebhookDeliveriesLimit     = 1000
+)
+
+// FetchWebhookDeliveries fetches webhook deliveries, newest first.
+// Use "status" query param to filter them, "dead" status returns the dead letter list.
+func (ar *Router) FetchWebhookDeliveries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := ar.server.Storages().Webhook
+		if storage == nil {
+			ar.Error(w, fmt.Errorf("webhook storage is not configured"), http.StatusNotFound, "")
+			return
+		}
+
+		skip, limit, err := ar.parseSkipAndLimit(r, defaultWebhookDeliveriesSkip, defaultWebhookDeliveriesLimit, maxWebhookDeliveriesLimit)
+		if err != nil {
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		status := model.WebhookDeliveryStatus(r.URL.Query().Get("status"))
+		switch status {
+		case "", model.WebhookDeliveryPending, model.WebhookDeliveryDelivered, model.WebhookDeliveryDead:
+		default:
+			ar.Error(w, ErrorWrongInput, http.StatusBadRequest, fmt.Sprintf("unknown delivery status %s", status))
+			return
+		}
+
+		deliveries, total, err := storage.FetchDeliveries(status, skip, limit)
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		searchResponse := struct {
+			Deliveries []model.WebhookDelivery `json:"deliveries"`
+			Total      int                     `json:"total"`
+		}{
+			Deliveries: deliveries,
+			Total:      total,
+		}
+
+		ar.ServeJSON(w, http.StatusOK, &searchResponse)
+	}
+}
+
+// GetWebhookDelivery fetches webhook delivery by ID.
+func (ar *Router) GetWebhookDelivery() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := ar.server.Storages().Webhook
+		if storage == nil {
+			ar.Error(w, fmt.Errorf("webhook storage is not configured"), http.StatusNotFound, "")
+			return
+		}
+
+		delivery, err := storage.DeliveryByID(getRouteVar("id", r))
+		if err != nil {
+			if errors.Is(err, model.ErrorNotFound) {
+				ar.Error(w, err, http.StatusNotFound, "")
+			} else {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, delivery)
+	}
+}
+
+// ReplayWebhookDelivery queues the delivery again, usually the one from the dead letter list.
+func (ar *Router) ReplayWebhookDelivery() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		service := ar.server.Services().Webhook
+		if service == nil {
+			ar.Error(w, fmt.Errorf("webhook service is not configured"), http.StatusNotFound, "")
+			return
+		}
+
+		delivery, err := service.Replay(getRouteVar("id", r))
+		if err != nil {
+			if errors.Is(err, model.ErrorNotFound) {
+				ar.Error(w, err, http.StatusNotFound, "")
+			} else {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, delivery)
+	}
+}
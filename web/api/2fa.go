@@ -14,6 +14,7 @@ import (
 	"github.com/madappgang/identifo/v2/logging"
 	"github.com/madappgang/identifo/v2/model"
 	"github.com/madappgang/identifo/v2/web/middleware"
+	"github.com/madappgang/identifo/v2/webauthn"
 	qrcode "github.com/skip2/go-qrcode"
 	"github.com/xlzd/gotp"
 )
@@ -27,16 +28,20 @@ type ResetEmailData struct {
 }
 
 // EnableTFA enables two-factor authentication for the user.
+// The type of the factor to enroll is chosen by the client, the server-wide TFA type is the default one.
 func (ar *Router) EnableTFA() http.HandlerFunc {
 	type requestBody struct {
-		Email string `json:"email"`
-		Phone string `json:"phone"`
+		Type  model.TFAType `json:"type"`
+		Email string        `json:"email"`
+		Phone string        `json:"phone"`
 	}
 
 	type tfaSecret struct {
 		AccessToken     string `json:"access_token,omitempty"`
 		ProvisioningURI string `json:"provisioning_uri,omitempty"`
 		ProvisioningQR  string `json:"provisioning_qr,omitempty"`
+		// RecoveryCodes are shown once, when the first factor is enrolled.
+		RecoveryCodes []string `json:"recovery_codes,omitempty"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -58,6 +63,15 @@ func (ar *Router) EnableTFA() http.HandlerFunc {
 			return
 		}
 
+		tfaType := d.Type
+		if tfaType == "" {
+			tfaType = ar.tfaType
+		}
+		if !ar.acceptsTFAType(app, tfaType) {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FATypeNotAccepted, tfaType)
+			return
+		}
+
 		accessTokenBytes, ok := r.Context().Value(model.TokenRawContextKey).([]byte)
 		if !ok {
 			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequestAPPIDInvalid)
@@ -77,10 +91,17 @@ func (ar *Router) EnableTFA() http.HandlerFunc {
 			return
 		}
 
-		if tfaInfo := user.TFAInfo; tfaInfo.IsEnabled && tfaInfo.Secret != "" {
+		tfa := ar.tfaInfo(user)
+		if f, ok := tfa.Factor(tfaType); ok && f.IsEnabled {
 			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FAAlreadyEnabled)
 			return
 		}
+		// the pre-auth token enrolls the first usable factor only, the next ones need the login with 2FA.
+		// 2FA switched on by admin has no such factor yet, so the user enrolls it with the pre-auth token.
+		if len(ar.tfaFactors(app, user)) > 0 && isTFAPreauth(strings.Split(tokenFromContext(r.Context()).Scopes(), " ")) {
+			ar.Error(w, locale, http.StatusForbidden, l.ErrorAPIRequest2FARequired)
+			return
+		}
 
 		tokenPayload, err := ar.getTokenPayloadForApp(app, user.ID)
 		if err != nil {
@@ -89,26 +110,42 @@ func (ar *Router) EnableTFA() http.HandlerFunc {
 		}
 
 		accessToken, _, err := ar.loginUser(user, model.AllowedScopesSet{}, app, true, tokenPayload)
+		if ar.emailNotVerifiedError(w, locale, err) {
+			return
+		}
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorTokenUnableToCreateAccessTokenError, err)
 			return
 		}
 
-		switch ar.tfaType {
+		var recoveryCodes []string
+		if len(tfa.RecoveryCodes) == 0 && !ar.tfaSingleFactor {
+			if recoveryCodes, err = model.NewTFARecoveryCodes(); err != nil {
+				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPIRequest2FAUnableToGenerateRecoveryCodesError, err)
+				return
+			}
+			tfa.SetRecoveryCodes(recoveryCodes)
+		}
+
+		switch tfaType {
 		case model.TFATypeApp:
-			// For app we just enable tfa and generate secret
-			user.TFAInfo = model.TFAInfo{
+			// For app we just enable the factor and generate secret
+			f := model.TFAFactor{
+				Type:      model.TFATypeApp,
 				IsEnabled: true,
 				Secret:    gotp.RandomSecret(16),
 			}
+			tfa.SetFactor(f)
+			user.TFAInfo = tfa
 
 			if _, err := ar.server.Storages().User.UpdateUser(userID, user); err != nil {
 				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, user.ID, err)
 				return
 			}
+			ar.emitTFAEnabled(app, user.ID, f.Type)
 
 			// Send new provisioning uri for authenticator
-			uri := gotp.NewDefaultTOTP(user.TFAInfo.Secret).ProvisioningUri(user.Username, app.Name)
+			uri := gotp.NewDefaultTOTP(f.Secret).ProvisioningUri(user.Username, app.Name)
 
 			var png []byte
 			png, err := qrcode.Encode(uri, qrcode.Medium, 256)
@@ -118,40 +155,58 @@ func (ar *Router) EnableTFA() http.HandlerFunc {
 			}
 			encoded := base64.StdEncoding.EncodeToString(png)
 
-			ar.ServeJSON(w, locale, http.StatusOK, &tfaSecret{ProvisioningURI: uri, ProvisioningQR: encoded, AccessToken: accessToken})
+			ar.ServeJSON(w, locale, http.StatusOK, &tfaSecret{ProvisioningURI: uri, ProvisioningQR: encoded, AccessToken: accessToken, RecoveryCodes: recoveryCodes})
 			return
 		case model.TFATypeSMS, model.TFATypeEmail:
-			// If 2fa is SMS or Email we set it in TFAInfo and enable it only when it will be verified
-			if ar.tfaType == model.TFATypeSMS {
+			// If the factor is SMS or Email we enroll it and enable it only when it will be verified
+			f := model.TFAFactor{
+				Type:   tfaType,
+				Secret: gotp.RandomSecret(16),
+			}
+			if tfaType == model.TFATypeSMS {
 				if d.Phone == "" {
 					ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FASetPhone)
 					return
 				}
-				user.TFAInfo = model.TFAInfo{Phone: d.Phone}
+				f.Phone = d.Phone
 			}
-			if ar.tfaType == model.TFATypeEmail {
+			if tfaType == model.TFATypeEmail {
 				if d.Email == "" {
 					ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FASetEmail)
 					return
 				}
-				user.TFAInfo = model.TFAInfo{Email: d.Email}
+				f.Email = d.Email
 			}
+			tfa.SetFactor(f)
+			user.TFAInfo = tfa
 
-			if _, err := ar.server.Storages().User.UpdateUser(userID, user); err != nil {
-				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, user.ID, err)
+			// And send OTP code for the factor, it saves the enrolled factor as well
+			if err := ar.sendOTPCode(app, user, f); err != nil {
+				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPIRequest2FAUnableToSendOtpError, err)
 				return
 			}
 
-			// And send OTP code for 2fa
-			if err := ar.sendOTPCode(app, user); err != nil {
-				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPIRequest2FAUnableToSendOtpError, err)
+			ar.ServeJSON(w, locale, http.StatusOK, &tfaSecret{AccessToken: accessToken, RecoveryCodes: recoveryCodes})
+			return
+		case model.TFATypeWebAuthn:
+			// WebAuthn uses registered credentials instead of the secret, so at least one is required
+			if !ar.hasWebAuthnCredentials(user.ID) {
+				ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnNoCredentials)
 				return
 			}
 
-			ar.ServeJSON(w, locale, http.StatusOK, &tfaSecret{AccessToken: accessToken})
+			tfa.SetFactor(model.TFAFactor{Type: model.TFATypeWebAuthn, IsEnabled: true})
+			user.TFAInfo = tfa
+			if _, err := ar.server.Storages().User.UpdateUser(userID, user); err != nil {
+				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, user.ID, err)
+				return
+			}
+			ar.emitTFAEnabled(app, user.ID, model.TFATypeWebAuthn)
+
+			ar.ServeJSON(w, locale, http.StatusOK, &tfaSecret{AccessToken: accessToken, RecoveryCodes: recoveryCodes})
 			return
 		}
-		ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPIRequest2FAUnknownType, ar.tfaType)
+		ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPIRequest2FAUnknownType, tfaType)
 	}
 }
 
@@ -196,16 +251,24 @@ func (ar *Router) ResendTFA() http.HandlerFunc {
 
 		scopes := strings.Split(token.Scopes(), " ")
 
-		authResult, resultScopes, err := ar.loginFlow(AuditOperationLoginWith2FA, app, user, scopes, nil)
+		authResult, resultScopes, err := ar.loginFlow(model.AuditOperationLoginWith2FA, app, user, scopes, nil)
+		if ar.emailNotVerifiedError(w, locale, err) {
+			return
+		}
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.APIInternalServerErrorWithError, err)
 			return
 		}
 
-		ar.server.Storages().Blocklist.Add(string(tfaToken))
+		ar.blacklistToken(string(tfaToken))
+
+		if err := ar.startSession(r, app.ID, user.ID, authResult.RefreshToken); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
 
-		ar.audit(AuditOperationLoginWith2FA,
-			user.ID, app.ID, r.UserAgent(), user.AccessRole, resultScopes.Scopes(),
+		ar.audit(model.AuditOperationLoginWith2FA, r,
+			user.ID, app.ID, user.AccessRole, resultScopes.Scopes(),
 			authResult.AccessToken, authResult.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, authResult)
@@ -213,10 +276,16 @@ func (ar *Router) ResendTFA() http.HandlerFunc {
 }
 
 // FinalizeTFA finalizes two-factor authentication.
+// The client chooses the enrolled factor to verify, the default factor of the user is verified otherwise.
+// The factor without the code is challenged: the one-time password is sent to its phone or email.
+// The recovery code is accepted instead of the factor.
 func (ar *Router) FinalizeTFA() http.HandlerFunc {
 	type requestBody struct {
-		TFACode string   `json:"tfa_code"`
-		Scopes  []string `json:"scopes"`
+		Factor       model.TFAType               `json:"factor"`
+		TFACode      string                      `json:"tfa_code"`
+		RecoveryCode string                      `json:"recovery_code"`
+		WebAuthn     *webauthn.AssertionResponse `json:"webauthn,omitempty"`
+		Scopes       []string                    `json:"scopes"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -227,7 +296,7 @@ func (ar *Router) FinalizeTFA() http.HandlerFunc {
 			return
 		}
 
-		if len(d.TFACode) == 0 {
+		if len(d.TFACode) == 0 && d.WebAuthn == nil && d.Factor == "" && len(d.RecoveryCode) == 0 {
 			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FACodeEmpty)
 			return
 		}
@@ -257,16 +326,28 @@ func (ar *Router) FinalizeTFA() http.HandlerFunc {
 			return
 		}
 
-		otpVerified, err := ar.verifyOTPCode(user, d.TFACode)
-		if err != nil {
-			ar.Error(w, locale, http.StatusForbidden, l.Error2FAVerifyFailError, err)
+		if ar.ipLoginLocked(w, r, locale) || ar.accountLoginLocked(w, locale, user.ID) {
 			return
 		}
 
-		dontNeedVerification := app.DebugTFACode != "" && d.TFACode == app.DebugTFACode
-
-		if !(otpVerified || dontNeedVerification) {
-			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIRequest2FACodeInvalid)
+		operation := model.AuditOperationLoginWith2FA
+		if len(d.RecoveryCode) > 0 {
+			// the recovery code replaces the factor, it is used once
+			used := false
+			if ar.tfaInfo(user).IsEnabled {
+				if user, used, err = model.ConsumeRecoveryCode(ar.server.Storages().User, userID, d.RecoveryCode); err != nil {
+					ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, userID, err)
+					return
+				}
+			}
+			if !used {
+				ar.loginFailed(model.AuditOperationLoginWithRecovery, r, user.ID)
+				ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIRequest2FACodeInvalid)
+				return
+			}
+			ar.loginSucceeded(user.ID)
+			operation = model.AuditOperationLoginWithRecovery
+		} else if user, ok = ar.verifyTFAFactor(w, r, locale, app, user, d.Factor, d.TFACode, d.WebAuthn); !ok {
 			return
 		}
 
@@ -279,13 +360,16 @@ func (ar *Router) FinalizeTFA() http.HandlerFunc {
 		}
 
 		accessToken, refreshToken, err := ar.loginUser(user, scopes, app, false, tokenPayload)
+		if ar.emailNotVerifiedError(w, locale, err) {
+			return
+		}
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorTokenUnableToCreateAccessTokenError, err)
 			return
 		}
 
 		// Blacklist old access token.
-		if err := ar.server.Storages().Blocklist.Add(oldAccessTokenString); err != nil {
+		if err := ar.blacklistToken(oldAccessTokenString); err != nil {
 			ar.logger.Error("Cannot blacklist old access token",
 				logging.FieldError, err)
 		}
@@ -297,50 +381,223 @@ func (ar *Router) FinalizeTFA() http.HandlerFunc {
 			User:         user,
 		}
 
-		// Enable TFA after verify if it not enabled
-		if !user.TFAInfo.IsEnabled {
-			user.TFAInfo = model.TFAInfo{
-				IsEnabled: true,
-				Secret:    gotp.RandomSecret(16),
-			}
-
-			if _, err := ar.server.Storages().User.UpdateUser(userID, user); err != nil {
-				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, user.ID, err)
-				return
-			}
-		}
-
 		ar.server.Storages().User.UpdateLoginMetadata(
-			string(AuditOperationLoginWith2FA),
+			string(operation),
 			user.ID,
 			app.ID,
 			scopes.Scopes(),
 			tokenPayload,
 		)
 
-		ar.audit(AuditOperationLoginWith2FA,
-			user.ID, app.ID, r.UserAgent(), user.AccessRole, scopes.Scopes(),
+		ar.emitWebhook(model.WebhookEventUserLogin, app, user.ID, map[string]interface{}{
+			"method": string(operation),
+		})
+
+		if err := ar.startSession(r, app.ID, user.ID, result.RefreshToken); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.audit(operation, r,
+			user.ID, app.ID, user.AccessRole, scopes.Scopes(),
 			result.AccessToken, result.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, result)
 	}
 }
 
-func (ar *Router) verifyOTPCode(user model.User, otp string) (bool, error) {
+// verifyTFAFactor verifies the code or the WebAuthn assertion of the factor, the factor being enrolled is enabled with it.
+// The factor without the code is challenged. It returns false if the response has been written.
+func (ar *Router) verifyTFAFactor(
+	w http.ResponseWriter,
+	r *http.Request,
+	locale string,
+	app model.AppData,
+	user model.User,
+	factorType model.TFAType,
+	code string,
+	assertion *webauthn.AssertionResponse,
+) (model.User, bool) {
+	if factorType == "" && assertion != nil {
+		factorType = model.TFATypeWebAuthn
+	}
+	factor, err := ar.tfaFactor(app, user, factorType)
+	if err != nil {
+		ar.tfaFactorError(w, locale, factorType, err)
+		return user, false
+	}
+
+	if len(code) == 0 && assertion == nil {
+		// the code of the factor is resent with the same timeout as the code of the login
+		sentAt := factor.HOTPExpiredAt.Add(-time.Hour * hotpLifespanHours)
+		if time.Since(sentAt) < time.Duration(ar.tfaResendTimeout)*time.Second {
+			ar.Error(w, locale, http.StatusBadRequest, l.Error2FAResendTimeout)
+			return user, false
+		}
+		if err := ar.sendOTPCode(app, user, factor); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPIRequest2FAUnableToSendOtpError, err)
+			return user, false
+		}
+		ar.ServeJSON(w, locale, http.StatusOK, map[string]model.TFAType{"tfa_factor": factor.Type})
+		return user, false
+	}
+
+	var otpVerified bool
+	if assertion != nil {
+		if factor.Type != model.TFATypeWebAuthn {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FAFactorNotEnrolled, model.TFATypeWebAuthn)
+			return user, false
+		}
+		// the ceremony session is bound to the user, so the assertion of other user's credential is rejected
+		if _, err := ar.finishWebAuthnLogin(w, r, app, webAuthnCeremonyTFA, *assertion); err != nil {
+			ar.loginFailed(model.AuditOperationLoginWith2FA, r, user.ID)
+			ar.Error(w, locale, http.StatusForbidden, l.Error2FAVerifyFailError, err)
+			return user, false
+		}
+		otpVerified = true
+	} else {
+		otpVerified, err = ar.verifyOTPCode(factor, code)
+		if err != nil {
+			ar.Error(w, locale, http.StatusForbidden, l.Error2FAVerifyFailError, err)
+			return user, false
+		}
+	}
+
+	dontNeedVerification := app.DebugTFACode != "" && len(code) > 0 && code == app.DebugTFACode
+
+	if !(otpVerified || dontNeedVerification) {
+		ar.loginFailed(model.AuditOperationLoginWith2FA, r, user.ID)
+		ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIRequest2FACodeInvalid)
+		return user, false
+	}
+	ar.loginSucceeded(user.ID)
+
+	// Enable the factor after verify if it is not enabled
+	if !factor.IsEnabled {
+		factor.IsEnabled = true
+		tfa := ar.tfaInfo(user)
+		tfa.SetFactor(factor)
+		user.TFAInfo = tfa
+
+		if _, err := ar.server.Storages().User.UpdateUser(user.ID, user); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, user.ID, err)
+			return user, false
+		}
+		ar.emitTFAEnabled(app, user.ID, factor.Type)
+	}
+
+	return user, true
+}
+
+func (ar *Router) verifyOTPCode(factor model.TFAFactor, otp string) (bool, error) {
 	result := false
-	if ar.tfaType == model.TFATypeApp {
-		totp := gotp.NewDefaultTOTP(user.TFAInfo.Secret)
+	switch factor.Type {
+	case model.TFATypeWebAuthn:
+		// WebAuthn is verified with the assertion, there are no one-time codes
+		return false, nil
+	case model.TFATypeApp:
+		totp := gotp.NewDefaultTOTP(factor.Secret)
 		result = totp.Verify(otp, time.Now().Unix())
-	} else {
-		if user.TFAInfo.HOTPExpiredAt.Before(time.Now()) {
+	default:
+		if factor.HOTPExpiredAt.Before(time.Now()) {
 			return false, errors.New(ar.ls.SD(l.ErrorOtpExpired))
 		}
-		hotp := gotp.NewDefaultHOTP(user.TFAInfo.Secret)
-		result = hotp.Verify(otp, user.TFAInfo.HOTPCounter)
+		hotp := gotp.NewDefaultHOTP(factor.Secret)
+		result = hotp.Verify(otp, factor.HOTPCounter)
 	}
 	return result, nil
 }
 
+// RegenerateTFARecoveryCodes replaces the recovery codes of the logged in user with the new set.
+// The user passes the enabled factor again, like on login: the factor without the code is challenged.
+func (ar *Router) RegenerateTFARecoveryCodes() http.HandlerFunc {
+	type requestBody struct {
+		Factor   model.TFAType               `json:"factor"`
+		TFACode  string                      `json:"tfa_code"`
+		WebAuthn *webauthn.AssertionResponse `json:"webauthn,omitempty"`
+	}
+
+	type responseData struct {
+		RecoveryCodes     []string `json:"recovery_codes"`
+		RecoveryCodesLeft int      `json:"recovery_codes_left"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		d := requestBody{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if len(app.ID) == 0 {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIAPPNoAPPInContext)
+			return
+		}
+
+		token := tokenFromContext(r.Context())
+		user, err := ar.server.Storages().User.UserByID(token.UserID())
+		if err != nil {
+			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorStorageFindUserIDError, token.UserID(), err)
+			return
+		}
+
+		if !ar.tfaInfo(user).IsEnabled {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FAPleaseEnable)
+			return
+		}
+
+		if ar.tfaSingleFactor {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FARecoveryCodesNotSupported)
+			return
+		}
+
+		if ar.ipLoginLocked(w, r, locale) || ar.accountLoginLocked(w, locale, user.ID) {
+			return
+		}
+
+		// the factor being enrolled is not accepted, it would be enabled with the code
+		factorType := d.Factor
+		if factorType == "" && d.WebAuthn != nil {
+			factorType = model.TFATypeWebAuthn
+		}
+		factor, err := ar.tfaFactor(app, user, factorType)
+		if err == nil && !factor.IsEnabled {
+			err = errPleaseEnableTFA
+		}
+		if err != nil {
+			ar.tfaFactorError(w, locale, factorType, err)
+			return
+		}
+
+		var ok bool
+		if user, ok = ar.verifyTFAFactor(w, r, locale, app, user, factor.Type, d.TFACode, d.WebAuthn); !ok {
+			return
+		}
+
+		tfa := ar.tfaInfo(user)
+		codes, err := model.NewTFARecoveryCodes()
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPIRequest2FAUnableToGenerateRecoveryCodesError, err)
+			return
+		}
+		tfa.SetRecoveryCodes(codes)
+		user.TFAInfo = tfa
+
+		if _, err := ar.server.Storages().User.UpdateUser(user.ID, user); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, user.ID, err)
+			return
+		}
+
+		ar.audit(model.AuditOperationRecoveryCodes, r,
+			user.ID, token.Audience(), "", nil,
+			"", "")
+
+		ar.ServeJSON(w, locale, http.StatusOK, responseData{RecoveryCodes: codes, RecoveryCodesLeft: len(codes)})
+	}
+}
+
 // RequestDisabledTFA requests link for disabling TFA.
 func (ar *Router) RequestDisabledTFA() http.HandlerFunc {
 	type requestBody struct {
@@ -548,8 +805,9 @@ func (ar *Router) RequestTFAReset() http.HandlerFunc {
 // check2FA checks correspondence between app's TFAstatus and user's TFAInfo,
 // and decides if we require two-factor authentication after all checks are successfully passed.
 // require2FA, enabled2FA, err
-func (ar *Router) check2FA(appTFAStatus model.TFAStatus, serverTFAType model.TFAType, user model.User) (bool, bool, error) {
-	if appTFAStatus == model.TFAStatusMandatory && !user.TFAInfo.IsEnabled {
+func (ar *Router) check2FA(app model.AppData, user model.User) (bool, bool, error) {
+	tfa := ar.tfaInfo(user)
+	if app.TFAStatus == model.TFAStatusMandatory && !tfa.IsEnabled {
 		return true, false, errPleaseEnableTFA
 	}
 
@@ -558,25 +816,141 @@ func (ar *Router) check2FA(appTFAStatus model.TFAStatus, serverTFAType model.TFA
 	// }
 
 	// Request two-factor auth if user enabled it and app supports it.
-	if user.TFAInfo.IsEnabled && appTFAStatus != model.TFAStatusDisabled {
-		if user.TFAInfo.Phone == "" && serverTFAType == model.TFATypeSMS {
-			// Server required sms tfa but user phone is empty
-			return true, false, errPleaseSetPhoneTFA
-		}
-		if user.TFAInfo.Email == "" && serverTFAType == model.TFATypeEmail {
-			// Server required email tfa but user email is empty
-			return true, false, errPleaseSetEmailTFA
-		}
-		if user.TFAInfo.Secret == "" {
-			// Then admin must have enabled TFA for this user manually.
-			// User must obtain TFA secret, i.e send EnableTFA request.
-			return true, false, errPleaseEnableTFA
+	if tfa.IsEnabled && app.TFAStatus != model.TFAStatusDisabled {
+		if _, err := ar.tfaFactor(app, user, ""); err != nil {
+			// Then admin must have enabled TFA for this user manually,
+			// or the user has no factors the app accepts.
+			// User must enroll the factor, i.e send EnableTFA request.
+			return true, false, err
 		}
 		return true, true, nil
 	}
 	return false, false, nil
 }
 
+// tfaInfo returns the two-factor authentication info of the user with the enrolled factors.
+// The factor enrolled before the users could choose it is of the server-wide type.
+func (ar *Router) tfaInfo(user model.User) model.TFAInfo {
+	return user.TFAInfo.WithFactors(ar.tfaType)
+}
+
+// acceptsTFAType returns true if the app accepts the factor of the type and the server is able to verify it.
+func (ar *Router) acceptsTFAType(app model.AppData, t model.TFAType) bool {
+	if ar.tfaSingleFactor && t != ar.tfaType {
+		return false
+	}
+	switch t {
+	case model.TFATypeApp, model.TFATypeSMS, model.TFATypeEmail:
+	case model.TFATypeWebAuthn:
+		if ar.webAuthn == nil {
+			return false
+		}
+	default:
+		return false
+	}
+	return app.AcceptsTFAType(t)
+}
+
+// tfaTypes returns the types of the factors the users of the app can enroll.
+func (ar *Router) tfaTypes(app model.AppData) []model.TFAType {
+	types := []model.TFAType{}
+	for _, t := range []model.TFAType{model.TFATypeApp, model.TFATypeSMS, model.TFATypeEmail, model.TFATypeWebAuthn} {
+		if ar.acceptsTFAType(app, t) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// tfaFactors returns the enabled factors of the user the app accepts, the default factor is the first.
+func (ar *Router) tfaFactors(app model.AppData, user model.User) []model.TFAFactor {
+	factors := []model.TFAFactor{}
+	for _, f := range ar.tfaInfo(user).EnabledFactors() {
+		if ar.acceptsTFAType(app, f.Type) && ar.tfaFactorReady(user.ID, f) == nil {
+			factors = append(factors, f)
+		}
+	}
+	return factors
+}
+
+// tfaFactorReady returns the error if the factor misses the data to challenge the user with.
+func (ar *Router) tfaFactorReady(userID string, f model.TFAFactor) error {
+	switch f.Type {
+	case model.TFATypeSMS:
+		if f.Phone == "" {
+			return errPleaseSetPhoneTFA
+		}
+	case model.TFATypeEmail:
+		if f.Email == "" {
+			return errPleaseSetEmailTFA
+		}
+	case model.TFATypeWebAuthn:
+		// WebAuthn has no secret, the user must register a credential to be able to pass it.
+		if !ar.hasWebAuthnCredentials(userID) {
+			return errPleaseEnableTFA
+		}
+		return nil
+	}
+	if f.Secret == "" {
+		return errPleaseEnableTFA
+	}
+	return nil
+}
+
+// tfaFactor returns the factor of the type to challenge the user with.
+// The empty type is the default factor of the user, or the factor being enrolled if the user has no enabled ones.
+// The factor being enrolled is verified with the code sent on enrollment, so it is returned as well.
+func (ar *Router) tfaFactor(app model.AppData, user model.User, t model.TFAType) (model.TFAFactor, error) {
+	tfa := ar.tfaInfo(user)
+	if t == "" {
+		if factors := ar.tfaFactors(app, user); len(factors) > 0 {
+			return factors[0], nil
+		}
+		for _, f := range tfa.Factors {
+			if !f.IsEnabled && ar.acceptsTFAType(app, f.Type) {
+				return f, nil
+			}
+		}
+		// the enabled factor the app accepts misses the data, e.g. the legacy SMS factor without the phone
+		for _, f := range tfa.EnabledFactors() {
+			if ar.acceptsTFAType(app, f.Type) {
+				return model.TFAFactor{}, ar.tfaFactorReady(user.ID, f)
+			}
+		}
+		return model.TFAFactor{}, errPleaseEnableTFA
+	}
+
+	if !ar.acceptsTFAType(app, t) {
+		return model.TFAFactor{}, errTFATypeNotAccepted
+	}
+	f, ok := tfa.Factor(t)
+	if !ok {
+		return model.TFAFactor{}, errTFAFactorNotEnrolled
+	}
+	if f.IsEnabled {
+		if err := ar.tfaFactorReady(user.ID, f); err != nil {
+			return model.TFAFactor{}, err
+		}
+	}
+	return f, nil
+}
+
+// tfaFactorError writes the error of choosing the factor of the type.
+func (ar *Router) tfaFactorError(w http.ResponseWriter, locale string, t model.TFAType, err error) {
+	switch err {
+	case errTFATypeNotAccepted:
+		ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FATypeNotAccepted, t)
+	case errTFAFactorNotEnrolled:
+		ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FAFactorNotEnrolled, t)
+	case errPleaseSetPhoneTFA:
+		ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FASetPhone)
+	case errPleaseSetEmailTFA:
+		ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FASetEmail)
+	default:
+		ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequest2FAPleaseEnable)
+	}
+}
+
 func (ar *Router) sendTFACodeInSMS(_ model.AppData, phone, otp string) error {
 	if phone == "" {
 		return errors.New("unable to send SMS OTP, user has no phone number")
@@ -588,8 +962,8 @@ func (ar *Router) sendTFACodeInSMS(_ model.AppData, phone, otp string) error {
 	return nil
 }
 
-func (ar *Router) sendTFACodeOnEmail(app model.AppData, user model.User, otp string) error {
-	if user.TFAInfo.Email == "" {
+func (ar *Router) sendTFACodeOnEmail(app model.AppData, user model.User, email, otp string) error {
+	if email == "" {
 		return errors.New("unable to send email OTP, user has no email")
 	}
 
@@ -602,7 +976,7 @@ func (ar *Router) sendTFACodeOnEmail(app model.AppData, user model.User, otp str
 		model.EmailTemplateTypeTFAWithCode,
 		app.GetCustomEmailTemplatePath(),
 		"One-time password",
-		user.TFAInfo.Email,
+		email,
 		model.EmailData{
 			User: user,
 			Data: emailData,
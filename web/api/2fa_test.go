@@ -0,0 +1,318 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/api"
+	"github.com/rs/cors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xlzd/gotp"
+)
+
+// testTFARequest calls the handler for the app with the token, if it is set.
+func testTFARequest(t *testing.T, h http.HandlerFunc, app model.AppData, token string, body any) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	ctx := testContext(app)
+	if len(token) > 0 {
+		parsed, err := testServer.Services().Token.Parse(token)
+		require.NoError(t, err)
+		ctx = context.WithValue(ctx, model.TokenContextKey, parsed)
+		ctx = context.WithValue(ctx, model.TokenRawContextKey, []byte(token))
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/tfa", strings.NewReader(string(data)))
+	r = r.WithContext(ctx)
+
+	rw := httptest.NewRecorder()
+	h(rw, r)
+	return rw
+}
+
+func Test_Router_TFAFactors(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith: model.LoginWith{Username: true},
+		TFAType:   model.TFATypeApp,
+		Server:    testServer,
+		Cors:      cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "tfa_factors_user", "+15550000024")
+
+	at, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testApp, false, nil)
+	require.NoError(t, err)
+	ats, err := testServer.Services().Token.String(at)
+	require.NoError(t, err)
+
+	// enroll the authenticator app, it's the server-wide default
+	rw := testTFARequest(t, router.EnableTFA(), testApp, ats, map[string]string{})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var enabled struct {
+		AccessToken     string `json:"access_token"`
+		ProvisioningURI string `json:"provisioning_uri"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &enabled))
+	uri, err := url.Parse(enabled.ProvisioningURI)
+	require.NoError(t, err)
+	totp := gotp.NewDefaultTOTP(uri.Query().Get("secret"))
+
+	rw = testTFARequest(t, router.EnableTFA(), testApp, ats, map[string]string{"type": "app"})
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+
+	// enroll SMS as the backup factor, it's enabled with the sent code
+	rw = testTFARequest(t, router.EnableTFA(), testApp, ats, map[string]string{"type": "sms", "phone": user.Phone})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &enabled))
+
+	user, err = testServer.Storages().User.UserByID(user.ID)
+	require.NoError(t, err)
+	sms, ok := user.TFAInfo.Factor(model.TFATypeSMS)
+	require.True(t, ok)
+	assert.False(t, sms.IsEnabled)
+
+	rw = testTFARequest(t, router.FinalizeTFA(), testApp, enabled.AccessToken, map[string]string{
+		"factor":   "sms",
+		"tfa_code": gotp.NewDefaultHOTP(sms.Secret).At(sms.HOTPCounter),
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	user, err = testServer.Storages().User.UserByID(user.ID)
+	require.NoError(t, err)
+	sms, _ = user.TFAInfo.Factor(model.TFATypeSMS)
+	assert.True(t, sms.IsEnabled)
+	assert.Equal(t, model.TFATypeApp, user.TFAInfo.DefaultFactor)
+
+	// the login challenges the default factor and lists the others
+	rw = testTFARequest(t, router.LoginWithPassword(), testApp, "", map[string]string{
+		"username": user.Username,
+		"password": "qwerty",
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	authResponse := api.AuthResponse{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &authResponse))
+	require.True(t, authResponse.Require2FA)
+	require.True(t, authResponse.Enabled2FA)
+	assert.Equal(t, model.TFATypeApp, authResponse.TFAFactor)
+	assert.Equal(t, []model.TFAType{model.TFATypeApp, model.TFATypeSMS}, authResponse.TFAFactors)
+	preauthToken := authResponse.AccessToken
+
+	// the client chooses SMS, the code is sent to the phone
+	rw = testTFARequest(t, router.FinalizeTFA(), testApp, preauthToken, map[string]string{"factor": "sms"})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.JSONEq(t, `{"tfa_factor": "sms"}`, rw.Body.String())
+
+	rw = testTFARequest(t, router.FinalizeTFA(), testApp, preauthToken, map[string]string{"factor": "email"})
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+
+	// the app accepting SMS only rejects the authenticator app
+	smsApp := testApp
+	smsApp.TFATypes = []model.TFAType{model.TFATypeSMS}
+	rw = testTFARequest(t, router.FinalizeTFA(), smsApp, preauthToken, map[string]string{
+		"factor":   "app",
+		"tfa_code": totp.At(time.Now().Unix()),
+	})
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+
+	rw = testTFARequest(t, router.FinalizeTFA(), testApp, preauthToken, map[string]string{
+		"tfa_code": totp.At(time.Now().Unix()),
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	authResponse = api.AuthResponse{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &authResponse))
+	assert.NotEmpty(t, authResponse.AccessToken)
+	for _, f := range authResponse.User.TFAInfo.Factors {
+		assert.Empty(t, f.Secret)
+	}
+}
+
+func Test_Router_TFARecoveryCodes(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith: model.LoginWith{Username: true},
+		TFAType:   model.TFATypeApp,
+		Server:    testServer,
+		Cors:      cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "tfa_recovery_user", "+15550000025")
+
+	at, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testApp, false, nil)
+	require.NoError(t, err)
+	ats, err := testServer.Services().Token.String(at)
+	require.NoError(t, err)
+
+	// the codes are generated with the first factor
+	rw := testTFARequest(t, router.EnableTFA(), testApp, ats, map[string]string{})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var enabled struct {
+		ProvisioningURI string   `json:"provisioning_uri"`
+		RecoveryCodes   []string `json:"recovery_codes"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &enabled))
+	require.Len(t, enabled.RecoveryCodes, model.TFARecoveryCodesCount)
+	uri, err := url.Parse(enabled.ProvisioningURI)
+	require.NoError(t, err)
+	totp := gotp.NewDefaultTOTP(uri.Query().Get("secret"))
+
+	user, err = testServer.Storages().User.UserByID(user.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, user.TFAInfo.RecoveryCodes, enabled.RecoveryCodes[0], "only the hashes are stored")
+
+	preauth, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testApp, true, nil)
+	require.NoError(t, err)
+	preauthToken, err := testServer.Services().Token.String(preauth)
+	require.NoError(t, err)
+
+	// the code is accepted instead of the factor once
+	code := strings.ToUpper(enabled.RecoveryCodes[0])
+	rw = testTFARequest(t, router.FinalizeTFA(), testApp, preauthToken, map[string]string{"recovery_code": code})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	authResponse := api.AuthResponse{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &authResponse))
+	assert.Equal(t, model.TFARecoveryCodesCount-1, authResponse.User.TFAInfo.RecoveryCodesLeft)
+	assert.Empty(t, authResponse.User.TFAInfo.RecoveryCodes)
+
+	rw = testTFARequest(t, router.FinalizeTFA(), testApp, preauthToken, map[string]string{"recovery_code": code})
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	// the pre-auth token can't enroll the next factor
+	rw = testTFARequest(t, router.EnableTFA(), testApp, preauthToken, map[string]string{"type": "sms", "phone": user.Phone})
+	require.Equal(t, http.StatusForbidden, rw.Code, rw.Body.String())
+
+	// the new codes replace the old ones once the factor is passed again
+	rw = testTFARequest(t, router.RegenerateTFARecoveryCodes(), testApp, ats, map[string]string{})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.JSONEq(t, `{"tfa_factor": "app"}`, rw.Body.String())
+
+	rw = testTFARequest(t, router.RegenerateTFARecoveryCodes(), testApp, ats, map[string]string{"tfa_code": "000000"})
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	rw = testTFARequest(t, router.RegenerateTFARecoveryCodes(), testApp, ats, map[string]string{"tfa_code": totp.At(time.Now().Unix())})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var regenerated struct {
+		RecoveryCodes     []string `json:"recovery_codes"`
+		RecoveryCodesLeft int      `json:"recovery_codes_left"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &regenerated))
+	assert.Len(t, regenerated.RecoveryCodes, model.TFARecoveryCodesCount)
+	assert.Equal(t, model.TFARecoveryCodesCount, regenerated.RecoveryCodesLeft)
+
+	rw = testTFARequest(t, router.FinalizeTFA(), testApp, preauthToken, map[string]string{"recovery_code": enabled.RecoveryCodes[1]})
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	rw = testTFARequest(t, router.GetUser(), testApp, ats, nil)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	me := model.User{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &me))
+	assert.Equal(t, model.TFARecoveryCodesCount, me.TFAInfo.RecoveryCodesLeft)
+}
+
+func Test_Router_TFAEnabledByAdmin(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith: model.LoginWith{Username: true},
+		TFAType:   model.TFATypeApp,
+		Server:    testServer,
+		Cors:      cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+
+	// admin switches 2FA on, the user has no factor yet
+	user := testOAuthUser(t, "tfa_admin_user", "+15550000031")
+	user.TFAInfo.IsEnabled = true
+	user, err = testServer.Storages().User.UpdateUser(user.ID, user)
+	require.NoError(t, err)
+
+	preauth, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testApp, true, nil)
+	require.NoError(t, err)
+	preauthToken, err := testServer.Services().Token.String(preauth)
+	require.NoError(t, err)
+
+	// the pre-auth token enrolls the first factor
+	rw := testTFARequest(t, router.EnableTFA(), testApp, preauthToken, map[string]string{})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	// and not the next one
+	rw = testTFARequest(t, router.EnableTFA(), testApp, preauthToken, map[string]string{"type": "sms", "phone": user.Phone})
+	require.Equal(t, http.StatusForbidden, rw.Code, rw.Body.String())
+}
+
+func Test_Router_TFAPreauthToken(t *testing.T) {
+	user := testOAuthUser(t, "tfa_preauth_user", "+15550000026")
+
+	preauth, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testApp, true, nil)
+	require.NoError(t, err)
+	preauthToken, err := testServer.Services().Token.String(preauth)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	request := func(mw func(http.Handler) http.Handler) int {
+		r := httptest.NewRequest(http.MethodPost, "/me", nil)
+		r = r.WithContext(testContext(testApp))
+		r.Header.Set("Authorization", "Bearer "+preauthToken)
+		rw := httptest.NewRecorder()
+		mw(ok).ServeHTTP(rw, r)
+		return rw.Code
+	}
+
+	// the token given after the password alone is not a login, e.g. for /me and passkey enrollment
+	assert.Equal(t, http.StatusUnauthorized, request(testRouter.Token(model.TokenTypeAccess, nil)))
+	assert.Equal(t, http.StatusOK, request(testRouter.Token(model.TokenTypeAccess, []string{model.TokenTypeTFAPreauth})))
+	assert.Equal(t, http.StatusOK, request(testRouter.TokenOrTFAPreauth(model.TokenTypeAccess)))
+}
+
+func Test_Router_TFASingleFactor(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith:       model.LoginWith{Username: true},
+		TFAType:         model.TFATypeApp,
+		TFASingleFactor: true,
+		Server:          testServer,
+		Cors:            cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "tfa_single_factor_user", "+15550000030")
+
+	at, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testApp, false, nil)
+	require.NoError(t, err)
+	ats, err := testServer.Services().Token.String(at)
+	require.NoError(t, err)
+
+	// the factor of the other type is not accepted
+	rw := testTFARequest(t, router.EnableTFA(), testApp, ats, map[string]string{"type": "sms", "phone": user.Phone})
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+
+	// the server-wide factor is enrolled without recovery codes
+	rw = testTFARequest(t, router.EnableTFA(), testApp, ats, map[string]string{})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var enabled struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &enabled))
+	assert.Empty(t, enabled.RecoveryCodes)
+
+	user, err = testServer.Storages().User.UserByID(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, user.TFAInfo.Factors, 1)
+	assert.Empty(t, user.TFAInfo.RecoveryCodes)
+
+	rw = testTFARequest(t, router.RegenerateTFARecoveryCodes(), testApp, ats, map[string]string{})
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+}
@@ -17,6 +17,7 @@ type appSettings struct {
 	Offline                      bool            `json:"offline"`
 	RegistrationForbidden        bool            `json:"registrationForbidden"`
 	TfaType                      string          `json:"tfaType"`
+	TfaTypes                     []model.TFAType `json:"tfaTypes"`
 	TfaStatus                    string          `json:"tfaStatus"`
 	TfaResendTimeout             int             `json:"tfaResendTimeout"`
 	LoginWith                    model.LoginWith `json:"loginWith"`
@@ -45,6 +46,7 @@ func (ar *Router) GetAppSettings() http.HandlerFunc {
 			Offline:                      app.Offline,
 			RegistrationForbidden:        app.RegistrationForbidden,
 			TfaType:                      string(ar.tfaType),
+			TfaTypes:                     ar.tfaTypes(app),
 			TfaStatus:                    string(app.TFAStatus),
 			TfaResendTimeout:             ar.tfaResendTimeout,
 			LoginWith:                    ar.SupportedLoginWays,
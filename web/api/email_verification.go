@@ -0,0 +1,192 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	l "github.com/madappgang/identifo/v2/localization"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/middleware"
+)
+
+// RequestEmailVerification sends the email verification link to the user.
+func (ar *Router) RequestEmailVerification() http.HandlerFunc {
+	type verificationRequest struct {
+		Email         string `json:"email"`
+		VerifyPageURL string `json:"verify_page_url,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		d := verificationRequest{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		if !model.EmailRegexp.MatchString(d.Email) {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIRequestBodyEmailInvalid)
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if len(app.ID) == 0 {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIAPPNoAPPInContext)
+			return
+		}
+
+		result := map[string]string{"result": "ok"}
+
+		user, err := ar.server.Storages().User.UserByEmail(d.Email)
+		if err == model.ErrUserNotFound {
+			// return ok, but there is no user
+			ar.logger.Info("Trying to verify email for the user, which is not exists. Sending back ok to user for security reason.",
+				"email", d.Email)
+			ar.ServeJSON(w, locale, http.StatusOK, result)
+			return
+		} else if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageFindUserEmailError, d.Email, err)
+			return
+		}
+
+		if user.EmailVerified {
+			ar.ServeJSON(w, locale, http.StatusOK, result)
+			return
+		}
+
+		if err := ar.sendEmailVerification(app, user, d.VerifyPageURL); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorServiceEmailSendError, err)
+			return
+		}
+
+		ar.ServeJSON(w, locale, http.StatusOK, result)
+	}
+}
+
+// VerifyEmail marks the user email as verified with the email verification token.
+func (ar *Router) VerifyEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		token := tokenFromContext(r.Context())
+		userID := token.UserID()
+
+		user, err := ar.server.Storages().User.UserByID(userID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorStorageFindUserIDError, userID, err)
+			return
+		}
+
+		// the token is not valid anymore if the user has changed the email after it has been sent
+		email, _ := token.Payload()["email"].(string)
+		if len(email) == 0 || !strings.EqualFold(email, user.Email) {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIVerifyEmailMismatch)
+			return
+		}
+
+		if !user.EmailVerified {
+			user.EmailVerified = true
+			if _, err := ar.server.Storages().User.UpdateUser(user.ID, user); err != nil {
+				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, user.ID, err)
+				return
+			}
+		}
+
+		// verification token is single use
+		if tokenBytes, ok := r.Context().Value(model.TokenRawContextKey).([]byte); ok {
+			if err := ar.blacklistToken(string(tokenBytes)); err != nil {
+				ar.logger.Error("Cannot blacklist email verification token", "error", err)
+			}
+		}
+
+		result := map[string]string{"result": "ok"}
+		ar.ServeJSON(w, locale, http.StatusOK, result)
+	}
+}
+
+// emailVerified returns false if the app requires verified email and the user email is not verified.
+func emailVerified(app model.AppData, user model.User) bool {
+	return !app.RequireVerifiedEmail || user.EmailVerified
+}
+
+// emailNotVerified writes the error and returns true if the app requires verified email and the user email is not verified.
+func (ar *Router) emailNotVerified(w http.ResponseWriter, locale string, app model.AppData, user model.User) bool {
+	if emailVerified(app, user) {
+		return false
+	}
+	ar.Error(w, locale, http.StatusForbidden, l.ErrorAPILoginEmailNotVerified)
+	return true
+}
+
+// emailNotVerifiedError writes the error and returns true if the tokens are not issued as the user email is not verified.
+func (ar *Router) emailNotVerifiedError(w http.ResponseWriter, locale string, err error) bool {
+	if !errors.Is(err, errEmailNotVerified) {
+		return false
+	}
+	ar.Error(w, locale, http.StatusForbidden, l.ErrorAPILoginEmailNotVerified)
+	return true
+}
+
+// sendEmailVerification sends the email with verification link to the user.
+func (ar *Router) sendEmailVerification(app model.AppData, user model.User, verifyPageURL string) error {
+	token, err := ar.server.Services().Token.NewEmailVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return fmt.Errorf("unable to create email verification token: %w", err)
+	}
+
+	tokenString, err := ar.server.Services().Token.String(token)
+	if err != nil {
+		return fmt.Errorf("unable to create email verification token: %w", err)
+	}
+
+	query := fmt.Sprintf("appId=%s&token=%s", app.ID, tokenString)
+	u := &url.URL{
+		Scheme:   ar.Host.Scheme,
+		Host:     ar.Host.Host,
+		RawQuery: query,
+	}
+
+	verifyPath := model.DefaultLoginWebAppSettings.ConfirmEmailURL
+
+	// if app requested custom verification page, use it.
+	if len(verifyPageURL) > 0 {
+		verifyPath = verifyPageURL
+	} else if app.LoginAppSettings != nil && len(app.LoginAppSettings.ConfirmEmailURL) > 0 {
+		// rewrite path for app, if app has specific web app login settings
+		verifyPath = app.LoginAppSettings.ConfirmEmailURL
+	}
+
+	verifyPathURL, err := url.Parse(verifyPath)
+	if err != nil {
+		return fmt.Errorf("invalid email verification URL (%s) for app (%s): %w", verifyPath, app.ID, err)
+	}
+
+	// app settings could rewrite host or just path, if path is absolute - it rewrites host as well
+	if verifyPathURL.IsAbs() {
+		u.Scheme = verifyPathURL.Scheme
+		u.Host = verifyPathURL.Host
+	}
+
+	u.Path = verifyPathURL.Path
+
+	uu := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+
+	return ar.server.Services().Email.SendTemplateEmail(
+		model.EmailTemplateTypeVerifyEmail,
+		app.GetCustomEmailTemplatePath(),
+		"Verify Email",
+		user.Email,
+		model.EmailData{
+			User: user,
+			Data: ResetEmailData{
+				User:  user,
+				Token: tokenString,
+				URL:   u.String(),
+				Host:  uu.String(),
+			},
+		},
+	)
+}
@@ -0,0 +1,107 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/api"
+	"github.com/rs/cors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testVerifyEmail(t *testing.T, router *api.Router, userID, email string) *httptest.ResponseRecorder {
+	ts := testServer.Services().Token
+	token, err := ts.NewEmailVerificationToken(userID, email)
+	require.NoError(t, err)
+	tokenString, err := ts.String(token)
+	require.NoError(t, err)
+
+	ctx := testContext(testApp)
+	ctx = context.WithValue(ctx, model.TokenContextKey, token)
+	ctx = context.WithValue(ctx, model.TokenRawContextKey, []byte(tokenString))
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/verify_email", nil)
+	r = r.WithContext(ctx)
+
+	rw := httptest.NewRecorder()
+	router.VerifyEmail()(rw, r)
+	return rw
+}
+
+func Test_Router_EmailVerification(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith: model.LoginWith{Username: true},
+		Server:    testServer,
+		Host:      &url.URL{Scheme: "http", Host: "localhost:8081"},
+		Cors:      cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "verify_email_user", "+15550000007")
+	require.False(t, user.EmailVerified)
+
+	app := testApp
+	app.RequireVerifiedEmail = true
+
+	login := func() *httptest.ResponseRecorder {
+		data, err := json.Marshal(map[string]string{"username": user.Username, "password": "qwerty"})
+		require.NoError(t, err)
+
+		r := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(string(data)))
+		r = r.WithContext(testContext(app))
+
+		rw := httptest.NewRecorder()
+		router.LoginWithPassword()(rw, r)
+		return rw
+	}
+
+	// the tokens issued before the app has required verified email are not refreshed
+	tokens := testSessionLogin(t, router, user.Username)
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/token", strings.NewReader(`{"scopes":["offline"]}`))
+	r = r.WithContext(testContext(app))
+	r.Header.Set("Authorization", "Bearer "+tokens.RefreshToken)
+	rw := httptest.NewRecorder()
+	router.Token(model.TokenTypeRefresh, nil)(router.RefreshTokens()).ServeHTTP(rw, r)
+	require.Equal(t, http.StatusForbidden, rw.Code, rw.Body.String())
+
+	// nor the 2FA token is issued
+	rw = testTFARequest(t, router.EnableTFA(), app, tokens.AccessToken, map[string]string{"type": "app"})
+	require.Equal(t, http.StatusForbidden, rw.Code, rw.Body.String())
+
+	rw = login()
+	require.Equal(t, http.StatusForbidden, rw.Code, rw.Body.String())
+
+	// the link is sent with the mock email service
+	r = httptest.NewRequest(http.MethodPost, "/auth/request_email_verification",
+		strings.NewReader(`{"email":"`+user.Email+`"}`))
+	r = r.WithContext(testContext(app))
+	rw = httptest.NewRecorder()
+	router.RequestEmailVerification()(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	// the token issued for the old email is rejected
+	rw = testVerifyEmail(t, router, user.ID, "old@example.com")
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+
+	rw = testVerifyEmail(t, router, user.ID, strings.ToUpper(user.Email))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	user, err = testServer.Storages().User.UserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, user.EmailVerified)
+
+	rw = login()
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	claims := model.NewUserInfoClaims(user, []string{model.OIDCScopeEmail})
+	require.NotNil(t, claims.EmailVerified)
+	assert.True(t, *claims.EmailVerified)
+}
@@ -203,7 +203,10 @@ func (ar *Router) FederatedLoginComplete() http.HandlerFunc {
 			return
 		}
 
-		authResult, resultScopes, err := ar.loginFlow(AuditOperationFederatedLogin, app, user, fsess.Scopes, nil)
+		authResult, resultScopes, err := ar.loginFlow(model.AuditOperationFederatedLogin, app, user, fsess.Scopes, nil)
+		if ar.emailNotVerifiedError(w, locale, err) {
+			return
+		}
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorFederatedLoginError, err)
 			return
@@ -212,8 +215,13 @@ func (ar *Router) FederatedLoginComplete() http.HandlerFunc {
 		authResult.CallbackUrl = fsess.CallbackUrl
 		authResult.Scopes = fsess.Scopes
 
-		ar.audit(AuditOperationFederatedLogin,
-			user.ID, app.ID, r.UserAgent(), user.AccessRole, resultScopes.Scopes(),
+		if err := ar.startSession(r, app.ID, user.ID, authResult.RefreshToken); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.audit(model.AuditOperationFederatedLogin, r,
+			user.ID, app.ID, user.AccessRole, resultScopes.Scopes(),
 			authResult.AccessToken, authResult.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, authResult)
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"sync"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	l "github.com/madappgang/identifo/v2/localization"
@@ -13,65 +12,22 @@ import (
 	"github.com/madappgang/identifo/v2/model"
 	"github.com/madappgang/identifo/v2/web/authorization"
 	"github.com/madappgang/identifo/v2/web/middleware"
+	"github.com/madappgang/identifo/v2/web/oidcauth"
 	"golang.org/x/oauth2"
 )
 
 const SessionNameOIDC = "_federated_oidc_session"
 
-type oidcInfo struct {
-	provider *oidc.Provider
-	verifier *oidc.IDTokenVerifier
-}
-
-var (
-	oidcProviderCache     = map[string]oidcInfo{}
-	oidcProviderCacheLock = sync.RWMutex{}
-)
-
 func getCachedOIDCProvider(ctx context.Context, app model.AppData) (*oidc.Provider, *oidc.IDTokenVerifier, error) {
 	err := app.OIDCSettings.IsValid()
 	if err != nil {
 		return nil, nil, fmt.Errorf("OIDC not configured for app %s: %w", app.ID, err)
 	}
 
-	key := app.ID + ":oidc"
-
-	oidcProviderCacheLock.RLock()
-	oi, ok := oidcProviderCache[key]
-	oidcProviderCacheLock.RUnlock()
-
-	if ok {
-		return oi.provider, oi.verifier, nil
-	}
-
-	oidcProviderCacheLock.Lock()
-	defer oidcProviderCacheLock.Unlock()
-
-	oi, ok = oidcProviderCache[key]
-	if ok {
-		return oi.provider, oi.verifier, nil
-	}
-
-	if app.OIDCSettings.Issuer != "" {
-		ctx = oidc.InsecureIssuerURLContext(ctx, app.OIDCSettings.Issuer)
-	}
-
-	provider, err := oidc.NewProvider(ctx, app.OIDCSettings.ProviderURL)
+	provider, verifier, err := oidcauth.Provider(ctx, app.ID+":oidc", app.OIDCSettings)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create OIDC provider %s: %w", app.ID, err)
 	}
-
-	verifier := provider.Verifier(&oidc.Config{
-		ClientID: app.OIDCSettings.ClientID,
-	})
-
-	oi = oidcInfo{
-		provider: provider,
-		verifier: verifier,
-	}
-
-	oidcProviderCache[key] = oi
-
 	return provider, verifier, nil
 }
 
@@ -236,7 +192,10 @@ func (ar *Router) OIDCLoginComplete(useSession bool) http.HandlerFunc {
 		// map OIDC scopes to Identifo scopes
 		requestedScopes = mapScopes(app.OIDCSettings.ScopeMapping, requestedScopes)
 
-		authResult, resultScopes, err := ar.loginFlow(AuditOperationOIDCLogin, app, user, requestedScopes, nil)
+		authResult, resultScopes, err := ar.loginFlow(model.AuditOperationOIDCLogin, app, user, requestedScopes, nil)
+		if ar.emailNotVerifiedError(w, locale, err) {
+			return
+		}
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorFederatedLoginError, err)
 			return
@@ -249,8 +208,13 @@ func (ar *Router) OIDCLoginComplete(useSession bool) http.HandlerFunc {
 		authResult.Scopes = resultScopes.Scopes()
 		authResult.ProviderData = *providerData
 
-		ar.audit(AuditOperationOIDCLogin,
-			user.ID, app.ID, r.UserAgent(), user.AccessRole, resultScopes.Scopes(),
+		if err := ar.startSession(r, app.ID, user.ID, authResult.RefreshToken); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.audit(model.AuditOperationOIDCLogin, r,
+			user.ID, app.ID, user.AccessRole, resultScopes.Scopes(),
 			authResult.AccessToken, authResult.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, authResult)
@@ -347,9 +311,16 @@ func (ar *Router) completeOIDCAuth(
 		oauth2Config.RedirectURL = fsess.RedirectUrl
 	}
 
-	oauth2Token, err := oauth2Config.Exchange(ctx, authCode)
-	if err != nil {
+	claims, oauth2Token, err := oidcauth.Exchange(ctx, oauth2Config, verifier, authCode)
+	switch {
+	case errors.Is(err, oidcauth.ErrExchange):
 		return nil, fsess, nil, "", NewLocalizedError(http.StatusBadRequest, locale, l.ErrorFederatedExchangeError, err)
+	case errors.Is(err, oidcauth.ErrIDTokenMissing):
+		return nil, fsess, nil, "", NewLocalizedError(http.StatusBadRequest, locale, l.ErrorFederatedIDtokenMissing)
+	case errors.Is(err, oidcauth.ErrIDTokenInvalid):
+		return nil, fsess, nil, "", NewLocalizedError(http.StatusBadRequest, locale, l.ErrorFederatedIDtokenInvalid, err)
+	case err != nil:
+		return nil, fsess, nil, "", NewLocalizedError(http.StatusBadRequest, locale, l.ErrorFederatedClaimsError, err)
 	}
 
 	providerScopeVal := oauth2Token.Extra("scope")
@@ -361,24 +332,6 @@ func (ar *Router) completeOIDCAuth(
 			"scopeValue", fmt.Sprintf("%+v", providerScopeVal))
 	}
 
-	// Extract the ID Token from OAuth2 token.
-	rawIDToken, ok := oauth2Token.Extra("id_token").(string)
-	if !ok {
-		return nil, fsess, nil, "", NewLocalizedError(http.StatusBadRequest, locale, l.ErrorFederatedIDtokenMissing)
-	}
-
-	// Parse and verify ID Token payload.
-	idToken, err := verifier.Verify(ctx, rawIDToken)
-	if err != nil {
-		return nil, fsess, nil, "", NewLocalizedError(http.StatusBadRequest, locale, l.ErrorFederatedIDtokenInvalid, err)
-	}
-
-	// Extract custom claims
-	var claims map[string]interface{}
-	if err := idToken.Claims(&claims); err != nil {
-		return nil, fsess, nil, "", NewLocalizedError(http.StatusBadRequest, locale, l.ErrorFederatedClaimsError, err)
-	}
-
 	providerData := &providerData{
 		AccessToken:  oauth2Token.AccessToken,
 		RefreshToken: oauth2Token.RefreshToken,
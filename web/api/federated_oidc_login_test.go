@@ -46,7 +46,13 @@ var testServerSettings = model.DefaultServerSettings
 
 func (tc testConfig) LoadServerSettings(validate bool) (model.ServerSettings, []error) {
 	testServerSettings.KeyStorage.Local.Path = "../../jwt/test_artifacts/private.pem"
+	testServerSettings.EmailTemplates = model.FileStorageSettings{
+		Type:  model.FileStorageTypeLocal,
+		Local: model.FileStorageLocal{Path: "../../static/email_templates"},
+	}
 	testServerSettings.Login.LoginWith.FederatedOIDC = true
+	// creates login attempt storage, lockout is enabled in settings of the routers which test it
+	testServerSettings.Login.Lockout.Enabled = true
 	return testServerSettings, nil
 }
 
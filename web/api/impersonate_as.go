@@ -68,7 +68,10 @@ func (ar *Router) ImpersonateAs() http.HandlerFunc {
 			"impersonated_by": adminUser.ID,
 		}
 
-		authResult, resultScopes, err := ar.loginFlow(AuditOperationImpersonatedAs, app, user, nil, ap)
+		authResult, resultScopes, err := ar.loginFlow(model.AuditOperationImpersonatedAs, app, user, nil, ap)
+		if ar.emailNotVerifiedError(w, locale, err) {
+			return
+		}
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPILoginError, err)
 			return
@@ -77,8 +80,8 @@ func (ar *Router) ImpersonateAs() http.HandlerFunc {
 		// do not allow refresh for impersonated user
 		authResult.RefreshToken = ""
 
-		ar.audit(AuditOperationImpersonatedAs,
-			userID, app.ID, r.UserAgent(), user.AccessRole, resultScopes.Scopes(),
+		ar.audit(model.AuditOperationImpersonatedAs, r,
+			userID, app.ID, user.AccessRole, resultScopes.Scopes(),
 			authResult.AccessToken, authResult.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, authResult)
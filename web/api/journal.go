@@ -1,53 +1,91 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/madappgang/identifo/v2/logging"
 	"github.com/madappgang/identifo/v2/model"
-)
-
-type AuditOperation string
-
-const (
-	AuditOperationLoginWithPassword AuditOperation = "login_with_password"
-	AuditOperationLoginWithPhone    AuditOperation = "login_with_phone"
-	AuditOperationLoginWith2FA      AuditOperation = "login_with_2fa"
-	AuditOperationRefreshToken      AuditOperation = "refresh_token"
-	AuditOperationOIDCLogin         AuditOperation = "oidc_login"
-	AuditOperationFederatedLogin    AuditOperation = "federated_login"
-	AuditOperationRegistration      AuditOperation = "registration"
-	AuditOperationLogout            AuditOperation = "logout"
-	AuditOperationImpersonatedAs    AuditOperation = "impersonated_as"
+	"github.com/madappgang/identifo/v2/web/middleware"
 )
 
 func (ar *Router) audit(
-	op AuditOperation,
-	userID, appID, device, accessRole string,
+	op model.AuditOperation,
+	r *http.Request,
+	userID, appID, accessRole string,
 	scopes []string,
 	accessToken, refreshToken string,
 ) {
-	iss := ar.server.Services().Token.Issuer()
-
 	auditSettings := ar.server.Settings().Audit
 
-	accessToken = maskToken(accessToken, auditSettings.TokenRecording)
-	refreshToken = maskToken(refreshToken, auditSettings.TokenRecording)
+	ar.writeAuditRecord(model.AuditRecord{
+		Source:       model.AuditSourceAPI,
+		Operation:    op,
+		Result:       model.AuditResultSuccess,
+		UserID:       userID,
+		AppID:        appID,
+		IP:           ar.clientIP(r),
+		UserAgent:    r.UserAgent(),
+		Issuer:       ar.server.Services().Token.Issuer(),
+		AccessRole:   accessRole,
+		Scopes:       scopes,
+		AccessToken:  maskToken(accessToken, auditSettings.TokenRecording),
+		RefreshToken: maskToken(refreshToken, auditSettings.TokenRecording),
+	})
+}
+
+// clientIP returns IP address of the client, from the proxy headers if they are trusted.
+func (ar *Router) clientIP(r *http.Request) string {
+	return middleware.ClientIP(r, ar.trustProxyHeaders)
+}
 
-	// TODO: Create an interface for the audit log
-	// Implement it for logging to stdout, a database, or a remote service
+// auditFailure records failed operation.
+func (ar *Router) auditFailure(op model.AuditOperation, r *http.Request, userID, appID, reason string) {
+	ar.writeAuditRecord(model.AuditRecord{
+		Source:    model.AuditSourceAPI,
+		Operation: op,
+		Result:    model.AuditResultFailure,
+		Error:     reason,
+		UserID:    userID,
+		AppID:     appID,
+		IP:        ar.clientIP(r),
+		UserAgent: r.UserAgent(),
+		Issuer:    ar.server.Services().Token.Issuer(),
+	})
+}
+
+// writeAuditRecord writes the record to the log and to the audit storage.
+// The operation is not failed if the record can't be saved.
+func (ar *Router) writeAuditRecord(record model.AuditRecord) {
 	ar.logger.Info("audit_record",
-		"operation", string(op),
-		logging.FieldUserID, userID,
-		logging.FieldAppID, appID,
-		"device", device,
-		"issuer", iss,
-		"accessRole", accessRole,
-		"scopes", scopes,
-		"accessToken", accessToken,
-		"refreshToken", refreshToken,
+		"operation", string(record.Operation),
+		"result", string(record.Result),
+		logging.FieldUserID, record.UserID,
+		logging.FieldAppID, record.AppID,
+		"ip", record.IP,
+		"device", record.UserAgent,
+		"issuer", record.Issuer,
+		"accessRole", record.AccessRole,
+		"scopes", record.Scopes,
+		"accessToken", record.AccessToken,
+		"refreshToken", record.RefreshToken,
 	)
+
+	storage := ar.server.Storages().Audit
+	if storage == nil {
+		return
+	}
+	if err := storage.AddRecord(record); err != nil {
+		ar.logger.Error("Unable to save audit record",
+			"operation", string(record.Operation),
+			logging.FieldError, err)
+	}
 }
 
 func maskToken(token string, tokenRecording model.TokenRecording) string {
+	if len(token) == 0 {
+		return ""
+	}
+
 	switch tokenRecording {
 	case model.TokenRecordingNone:
 		return "<redacted>"
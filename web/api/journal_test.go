@@ -0,0 +1,38 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Router_Audit_Login(t *testing.T) {
+	router := testLockoutRouter(t, model.LoginLockoutSettings{})
+	user := testOAuthUser(t, "audit_user", "+15550000008")
+
+	rw := testPasswordLogin(t, router, "198.51.100.10", user.Username, "wrong_password")
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	rw = testPasswordLogin(t, router, "198.51.100.10", user.Username, "qwerty")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	records, total, err := testServer.Storages().Audit.FetchRecords(model.AuditFilter{
+		UserID:    user.ID,
+		Operation: model.AuditOperationLoginWithPassword,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+
+	// newest first
+	assert.Equal(t, model.AuditResultSuccess, records[0].Result)
+	assert.Equal(t, model.AuditResultFailure, records[1].Result)
+	for _, r := range records {
+		assert.Equal(t, model.AuditSourceAPI, r.Source)
+		assert.Equal(t, testApp.ID, r.AppID)
+		assert.NotEmpty(t, r.IP)
+		assert.NotEmpty(t, r.Time)
+	}
+}
@@ -20,6 +20,11 @@ var (
 	errPleaseEnableTFA   = fmt.Errorf("please enable two-factor authentication to be able to use this app")
 	errPleaseSetPhoneTFA = fmt.Errorf("please set phone for two-factor authentication to be able to use this app")
 	errPleaseSetEmailTFA = fmt.Errorf("please set email for two-factor authentication to be able to use this app")
+
+	errTFATypeNotAccepted   = fmt.Errorf("two-factor authentication type is not accepted by the app")
+	errTFAFactorNotEnrolled = fmt.Errorf("two-factor authentication factor is not enrolled")
+
+	errEmailNotVerified = fmt.Errorf("email address is not verified")
 )
 
 type SendTFAEmailData struct {
@@ -35,14 +40,19 @@ const (
 
 // AuthResponse is a response with successful auth data.
 type AuthResponse struct {
-	AccessToken  string       `json:"access_token,omitempty" bson:"access_token,omitempty"`
-	RefreshToken string       `json:"refresh_token,omitempty" bson:"refresh_token,omitempty"`
-	User         model.User   `json:"user,omitempty" bson:"user,omitempty"`
-	Require2FA   bool         `json:"require_2fa" bson:"require_2fa"`
-	Enabled2FA   bool         `json:"enabled_2fa" bson:"enabled_2fa"`
-	CallbackUrl  string       `json:"callback_url,omitempty" bson:"callback_url,omitempty"`
-	Scopes       []string     `json:"scopes,omitempty" bson:"scopes,omitempty"`
-	ProviderData providerData `json:"provider_data,omitempty" bson:"provider_data,omitempty"`
+	AccessToken  string     `json:"access_token,omitempty" bson:"access_token,omitempty"`
+	RefreshToken string     `json:"refresh_token,omitempty" bson:"refresh_token,omitempty"`
+	User         model.User `json:"user,omitempty" bson:"user,omitempty"`
+	Require2FA   bool       `json:"require_2fa" bson:"require_2fa"`
+	Enabled2FA   bool       `json:"enabled_2fa" bson:"enabled_2fa"`
+	// TFAFactors are the types of the factors the user can choose to pass 2FA with, TFAFactor is the challenged one.
+	TFAFactors   []model.TFAType `json:"tfa_factors,omitempty" bson:"tfa_factors,omitempty"`
+	TFAFactor    model.TFAType   `json:"tfa_factor,omitempty" bson:"tfa_factor,omitempty"`
+	CallbackUrl  string          `json:"callback_url,omitempty" bson:"callback_url,omitempty"`
+	Scopes       []string        `json:"scopes,omitempty" bson:"scopes,omitempty"`
+	ProviderData providerData    `json:"provider_data,omitempty" bson:"provider_data,omitempty"`
+	// RequireEmailVerification is set when the user has to verify the email before logging in.
+	RequireEmailVerification bool `json:"require_email_verification,omitempty" bson:"require_email_verification,omitempty"`
 }
 
 type providerData struct {
@@ -144,26 +154,45 @@ func (ar *Router) LoginWithPassword() http.HandlerFunc {
 			return
 		}
 
+		if ar.ipLoginLocked(w, r, locale) {
+			return
+		}
+
 		user := model.User{}
+		identifier := ""
 
 		if len(ld.Email) > 0 {
+			identifier = ld.Email
 			user, err = ar.server.Storages().User.UserByEmail(ld.Email)
 		} else if len(ld.Phone) > 0 {
+			identifier = ld.Phone
 			user, err = ar.server.Storages().User.UserByPhone(ld.Phone)
 		} else if len(ld.Username) > 0 {
+			identifier = ld.Username
 			user, err = ar.server.Storages().User.UserByUsername(ld.Username)
 		}
 
 		if err != nil {
+			// the unknown identifier is locked the same way as the account, to hide the existence of the user.
+			if ar.identifierLoginLocked(w, locale, identifier) {
+				return
+			}
+			ar.unknownLoginFailed(model.AuditOperationLoginWithPassword, r, identifier)
 			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIRequestIncorrectLoginOrPassword)
 			return
 		}
 
+		if ar.accountLoginLocked(w, locale, user.ID) {
+			return
+		}
+
 		if err = ar.server.Storages().User.CheckPassword(user.ID, ld.Password); err != nil {
+			ar.loginFailed(model.AuditOperationLoginWithPassword, r, user.ID)
 			// return this error to hide the existence of the user.
 			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIRequestIncorrectLoginOrPassword)
 			return
 		}
+		ar.loginSucceeded(user.ID)
 
 		app := middleware.AppFromContext(r.Context())
 		if len(app.ID) == 0 {
@@ -183,38 +212,49 @@ func (ar *Router) LoginWithPassword() http.HandlerFunc {
 			return
 		}
 
-		authResult, resultScopes, err := ar.loginFlow(AuditOperationLoginWithPassword, app, user, ld.Scopes, nil)
+		if ar.emailNotVerified(w, locale, app, user) {
+			return
+		}
+
+		authResult, resultScopes, err := ar.loginFlow(model.AuditOperationLoginWithPassword, app, user, ld.Scopes, nil)
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPILoginError, err)
 			return
 		}
 
-		ar.audit(AuditOperationLoginWithPassword,
-			user.ID, app.ID, r.UserAgent(), user.AccessRole, resultScopes.Scopes(),
+		if err := ar.startSession(r, app.ID, user.ID, authResult.RefreshToken); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.audit(model.AuditOperationLoginWithPassword, r,
+			user.ID, app.ID, user.AccessRole, resultScopes.Scopes(),
 			authResult.AccessToken, authResult.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, authResult)
 	}
 }
 
-func (ar *Router) sendOTPCode(app model.AppData, user model.User) error {
-	// we don't need to send any code for FTA Type App, it uses TOTP and generated on client side with the app
-	if ar.tfaType != model.TFATypeApp {
+func (ar *Router) sendOTPCode(app model.AppData, user model.User, factor model.TFAFactor) error {
+	// we don't need to send any code for FTA Type App, it uses TOTP and generated on client side with the app,
+	// and for WebAuthn, which is verified with the authenticator assertion
+	if factor.Type != model.TFATypeApp && factor.Type != model.TFATypeWebAuthn {
 
 		// increment hotp code seed
-		otp := gotp.NewDefaultHOTP(user.TFAInfo.Secret).At(user.TFAInfo.HOTPCounter + 1)
-		tfa := user.TFAInfo
-		tfa.HOTPCounter++
-		tfa.HOTPExpiredAt = time.Now().Add(time.Hour * hotpLifespanHours)
+		otp := gotp.NewDefaultHOTP(factor.Secret).At(factor.HOTPCounter + 1)
+		factor.HOTPCounter++
+		factor.HOTPExpiredAt = time.Now().Add(time.Hour * hotpLifespanHours)
+		tfa := ar.tfaInfo(user)
+		tfa.SetFactor(factor)
 		user.TFAInfo = tfa
 		if _, err := ar.server.Storages().User.UpdateUser(user.ID, user); err != nil {
 			return err
 		}
-		switch ar.tfaType {
+		switch factor.Type {
 		case model.TFATypeSMS:
-			return ar.sendTFACodeInSMS(app, user.TFAInfo.Phone, otp)
+			return ar.sendTFACodeInSMS(app, factor.Phone, otp)
 		case model.TFATypeEmail:
-			return ar.sendTFACodeOnEmail(app, user, otp)
+			return ar.sendTFACodeOnEmail(app, user, factor.Email, otp)
 		}
 
 	}
@@ -311,6 +351,7 @@ func (ar *Router) getTokenPayloadService(app model.AppData) (model.TokenPayloadP
 
 // loginUser creates and returns access token for a user.
 // createRefreshToken boolean param tells if we should issue refresh token as well.
+// The tokens are not issued if the app requires verified email and the user has not verified it.
 func (ar *Router) loginUser(
 	user model.User,
 	scopes model.AllowedScopesSet,
@@ -318,6 +359,10 @@ func (ar *Router) loginUser(
 	require2FA bool,
 	tokenPayload map[string]interface{},
 ) (string, string, error) {
+	if !emailVerified(app, user) {
+		return "", "", errEmailNotVerified
+	}
+
 	token, err := ar.server.Services().Token.NewAccessToken(user, scopes, app, require2FA, tokenPayload)
 	if err != nil {
 		return "", "", err
@@ -350,7 +395,7 @@ func (ar *Router) loginUser(
 }
 
 func (ar *Router) loginFlow(
-	operation AuditOperation,
+	operation model.AuditOperation,
 	app model.AppData,
 	user model.User,
 	requestedScopes []string,
@@ -366,7 +411,7 @@ func (ar *Router) loginFlow(
 	scopes := model.AllowedScopes(requestedScopes, user.Scopes, app.Offline)
 
 	// Check if we should require user to authenticate with 2FA.
-	require2FA, enabled2FA, err := ar.check2FA(app.TFAStatus, ar.tfaType, user)
+	require2FA, enabled2FA, err := ar.check2FA(app, user)
 	if !require2FA && enabled2FA && err != nil {
 		return AuthResponse{}, model.AllowedScopesSet{}, err
 	}
@@ -397,7 +442,17 @@ func (ar *Router) loginFlow(
 	}
 
 	if require2FA && enabled2FA {
-		if err := ar.sendOTPCode(app, user); err != nil {
+		// the default factor is challenged, the client can choose the other one at /auth/tfa/login
+		factors := ar.tfaFactors(app, user)
+		for _, f := range factors {
+			result.TFAFactors = append(result.TFAFactors, f.Type)
+		}
+		factor, err := ar.tfaFactor(app, user, "")
+		if err != nil {
+			return AuthResponse{}, model.AllowedScopesSet{}, err
+		}
+		result.TFAFactor = factor.Type
+		if err := ar.sendOTPCode(app, user, factor); err != nil {
 			return AuthResponse{}, model.AllowedScopesSet{}, err
 		}
 	} else {
@@ -407,6 +462,13 @@ func (ar *Router) loginFlow(
 			user.ID,
 			scopes.Scopes(),
 			tokenPayload)
+
+		// registration has its own event and impersonation is not a login of the user
+		if operation != model.AuditOperationRegistration && operation != model.AuditOperationImpersonatedAs {
+			ar.emitWebhook(model.WebhookEventUserLogin, app, user.ID, map[string]interface{}{
+				"method": string(operation),
+			})
+		}
 	}
 
 	user = user.Sanitized()
@@ -463,6 +525,9 @@ func (ar *Router) GetImpersonateToken() http.HandlerFunc {
 		}
 
 		impersonateToken, err := ar.getImpersonateAccessToken(user, ld.Scopes, app)
+		if ar.emailNotVerifiedError(w, locale, err) {
+			return
+		}
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPILoginError, err)
 			return
@@ -474,6 +539,10 @@ func (ar *Router) GetImpersonateToken() http.HandlerFunc {
 
 // getImpersonateAccessToken creates and returns access token for a user.
 func (ar *Router) getImpersonateAccessToken(user model.User, requestedScopes []string, app model.AppData) (string, error) {
+	if !emailVerified(app, user) {
+		return "", errEmailNotVerified
+	}
+
 	tokenPayload, err := ar.getTokenPayloadForApp(app, user.ID)
 	if err != nil {
 		return "", err
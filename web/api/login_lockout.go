@@ -0,0 +1,132 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	l "github.com/madappgang/identifo/v2/localization"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/middleware"
+)
+
+// loginAttempts returns failed login attempts storage, or nil if login lockout is disabled.
+func (ar *Router) loginAttempts() model.LoginAttemptStorage {
+	if !ar.lockout.Enabled {
+		return nil
+	}
+	return ar.server.Storages().LoginAttempt
+}
+
+// ipLoginLocked serves the error and returns true if the login from the client IP address is locked.
+func (ar *Router) ipLoginLocked(w http.ResponseWriter, r *http.Request, locale string) bool {
+	ip := ar.clientIP(r)
+	return ar.loginLocked(w, locale, model.IPLoginAttemptsKey(ip), ar.lockout.IPLockedUntil)
+}
+
+// accountLoginLocked serves the error and returns true if the login to the user account is locked.
+func (ar *Router) accountLoginLocked(w http.ResponseWriter, locale, userID string) bool {
+	return ar.loginLocked(w, locale, model.AccountLoginAttemptsKey(userID), ar.lockout.AccountLockedUntil)
+}
+
+// identifierLoginLocked serves the error and returns true if the login with the unknown identifier is locked.
+// The failures with the unknown identifier are counted like the account ones, so the lockout does not reveal
+// whether the account exists.
+func (ar *Router) identifierLoginLocked(w http.ResponseWriter, locale, identifier string) bool {
+	return ar.loginLocked(w, locale, model.IdentifierLoginAttemptsKey(identifier), ar.lockout.AccountLockedUntil)
+}
+
+func (ar *Router) loginLocked(
+	w http.ResponseWriter,
+	locale, key string,
+	lockedUntil func(model.LoginAttempts) time.Time,
+) bool {
+	storage := ar.loginAttempts()
+	if storage == nil {
+		return false
+	}
+
+	attempts, err := storage.LoginAttempts(key)
+	if err != nil {
+		ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageLoginAttemptsError, err)
+		return true
+	}
+
+	retryAfter := time.Until(lockedUntil(attempts)).Round(time.Second)
+	if retryAfter <= 0 {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	ar.Error(w, locale, http.StatusTooManyRequests, l.ErrorAPILoginLocked, retryAfter)
+	return true
+}
+
+// loginFailed records failed login attempt to the audit log and counts it
+// for the client IP address and for the user account, if it is known.
+func (ar *Router) loginFailed(op model.AuditOperation, r *http.Request, userID string) {
+	ar.auditFailure(op, r, userID, middleware.AppFromContext(r.Context()).ID, "invalid credentials")
+
+	accountKey := ""
+	if len(userID) > 0 {
+		accountKey = model.AccountLoginAttemptsKey(userID)
+	}
+	ar.countLoginFailure(r, accountKey)
+}
+
+// unknownLoginFailed records failed login attempt with the identifier of the unknown user,
+// the attempt is counted for the client IP address and for the identifier.
+func (ar *Router) unknownLoginFailed(op model.AuditOperation, r *http.Request, identifier string) {
+	ar.auditFailure(op, r, "", middleware.AppFromContext(r.Context()).ID, "invalid credentials")
+	ar.countLoginFailure(r, model.IdentifierLoginAttemptsKey(identifier))
+}
+
+// countLoginFailure counts failed login attempt for the client IP address and for the account key, if it is set.
+func (ar *Router) countLoginFailure(r *http.Request, accountKey string) {
+	storage := ar.loginAttempts()
+	if storage == nil {
+		return
+	}
+
+	window := ar.lockout.FailureWindowDuration()
+
+	ip := ar.clientIP(r)
+	if _, err := storage.AddFailedAttempt(model.IPLoginAttemptsKey(ip), window); err != nil {
+		ar.logger.Error("Unable to count failed login attempt",
+			"ip", ip,
+			logging.FieldError, err)
+	}
+
+	if len(accountKey) == 0 {
+		return
+	}
+
+	attempts, err := storage.AddFailedAttempt(accountKey, window)
+	if err != nil {
+		ar.logger.Error("Unable to count failed login attempt",
+			"key", accountKey,
+			logging.FieldError, err)
+		return
+	}
+	if attempts.Failures == ar.lockout.MaxAccountFailures {
+		ar.logger.Warn("Account login is locked after failed attempts",
+			"key", accountKey,
+			"failures", attempts.Failures)
+	}
+}
+
+// loginSucceeded resets failed login attempts of the user account.
+// Failures from the client IP address are kept, so it could not try many accounts in a row.
+func (ar *Router) loginSucceeded(userID string) {
+	storage := ar.loginAttempts()
+	if storage == nil {
+		return
+	}
+
+	if err := storage.ResetLoginAttempts(model.AccountLoginAttemptsKey(userID)); err != nil {
+		ar.logger.Error("Unable to reset failed login attempts",
+			logging.FieldUserID, userID,
+			logging.FieldError, err)
+	}
+}
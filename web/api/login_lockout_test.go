@@ -0,0 +1,134 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/api"
+	"github.com/rs/cors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLockoutRouter(t *testing.T, lockout model.LoginLockoutSettings) *api.Router {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith:         model.LoginWith{Username: true},
+		Lockout:           lockout,
+		TrustProxyHeaders: true,
+		Server:            testServer,
+		Cors:              cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+	return router
+}
+
+func testPasswordLogin(t *testing.T, router *api.Router, ip, username, password string) *httptest.ResponseRecorder {
+	data, err := json.Marshal(map[string]string{"username": username, "password": password})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(string(data)))
+	r = r.WithContext(testContext(testApp))
+	r.Header.Set("X-Real-IP", ip)
+
+	rw := httptest.NewRecorder()
+	router.LoginWithPassword()(rw, r)
+	return rw
+}
+
+func Test_Router_LoginLockout_Account(t *testing.T) {
+	router := testLockoutRouter(t, model.LoginLockoutSettings{
+		Enabled:            true,
+		MaxAccountFailures: 3,
+		MaxIPFailures:      100,
+		LockoutDuration:    60,
+		MaxLockoutDuration: 3600,
+		FailureWindow:      3600,
+	})
+	user := testOAuthUser(t, "lockout_user", "+15550000005")
+	ip := "198.51.100.1"
+
+	for i := 0; i < 3; i++ {
+		rw := testPasswordLogin(t, router, ip, user.Username, "wrong_password")
+		require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+	}
+
+	// the correct password is not checked for the locked account
+	rw := testPasswordLogin(t, router, ip, user.Username, "qwerty")
+	require.Equal(t, http.StatusTooManyRequests, rw.Code, rw.Body.String())
+	assert.Equal(t, "60", rw.Header().Get("Retry-After"))
+
+	// the account is locked for other addresses too
+	rw = testPasswordLogin(t, router, "198.51.100.2", user.Username, "qwerty")
+	require.Equal(t, http.StatusTooManyRequests, rw.Code, rw.Body.String())
+
+	// unlock the account, like the admin does
+	key := model.AccountLoginAttemptsKey(user.ID)
+	require.NoError(t, testServer.Storages().LoginAttempt.ResetLoginAttempts(key))
+
+	rw = testPasswordLogin(t, router, ip, user.Username, "wrong_password")
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	rw = testPasswordLogin(t, router, ip, user.Username, "qwerty")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	// successful login resets the account failures
+	attempts, err := testServer.Storages().LoginAttempt.LoginAttempts(key)
+	require.NoError(t, err)
+	assert.Zero(t, attempts.Failures)
+}
+
+func Test_Router_LoginLockout_UnknownUser(t *testing.T) {
+	router := testLockoutRouter(t, model.LoginLockoutSettings{
+		Enabled:            true,
+		MaxAccountFailures: 3,
+		MaxIPFailures:      100,
+		LockoutDuration:    60,
+		MaxLockoutDuration: 3600,
+		FailureWindow:      3600,
+	})
+	ip := "198.51.100.20"
+
+	for i := 0; i < 3; i++ {
+		rw := testPasswordLogin(t, router, ip, "lockout_unknown_user", "qwerty")
+		require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+	}
+
+	// the unknown user is locked like the existing account, so the response does not reveal the account existence
+	rw := testPasswordLogin(t, router, ip, "lockout_unknown_user", "qwerty")
+	require.Equal(t, http.StatusTooManyRequests, rw.Code, rw.Body.String())
+	assert.Equal(t, "60", rw.Header().Get("Retry-After"))
+
+	// other identifiers are not locked
+	rw = testPasswordLogin(t, router, ip, "lockout_unknown_user2", "qwerty")
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+}
+
+func Test_Router_LoginLockout_IP(t *testing.T) {
+	router := testLockoutRouter(t, model.LoginLockoutSettings{
+		Enabled:            true,
+		MaxAccountFailures: 100,
+		MaxIPFailures:      2,
+		LockoutDuration:    60,
+		MaxLockoutDuration: 3600,
+		FailureWindow:      3600,
+	})
+	user := testOAuthUser(t, "lockout_ip_user", "+15550000006")
+	ip := "198.51.100.10"
+
+	// unknown users are counted for the address
+	for _, username := range []string{"unknown_user1", "unknown_user2"} {
+		rw := testPasswordLogin(t, router, ip, username, "qwerty")
+		require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+	}
+
+	rw := testPasswordLogin(t, router, ip, user.Username, "qwerty")
+	require.Equal(t, http.StatusTooManyRequests, rw.Code, rw.Body.String())
+
+	// other addresses are not locked
+	rw = testPasswordLogin(t, router, "198.51.100.11", user.Username, "qwerty")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+}
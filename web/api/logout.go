@@ -34,7 +34,7 @@ func (ar *Router) Logout() http.HandlerFunc {
 		accessTokenString := string(accessTokenBytes)
 
 		// Blacklist current access token.
-		if err := ar.server.Storages().Blocklist.Add(accessTokenString); err != nil {
+		if err := ar.blacklistToken(accessTokenString); err != nil {
 			ar.logger.Error("Cannot blacklist access token",
 				logging.FieldError, err)
 		}
@@ -64,8 +64,8 @@ func (ar *Router) Logout() http.HandlerFunc {
 			}
 		}
 
-		ar.audit(AuditOperationLogout,
-			accessToken.Subject(), accessToken.Audience(), r.UserAgent(), "", nil,
+		ar.audit(model.AuditOperationLogout, r,
+			accessToken.Subject(), accessToken.Audience(), "", nil,
 			accessTokenString, d.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, result)
@@ -104,8 +104,10 @@ func (ar *Router) revokeRefreshToken(refreshTokenString, accessTokenString strin
 		return fmt.Errorf("cannot delete refresh token: %s", err)
 	}
 
-	if err := ar.server.Storages().Blocklist.Add(refreshTokenString); err != nil {
+	if err := ar.blacklistToken(refreshTokenString); err != nil {
 		return fmt.Errorf("cannot blacklist refresh token: %s", err)
 	}
+
+	ar.endSession(refreshTokenString)
 	return nil
 }
@@ -0,0 +1,618 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwtValidator "github.com/madappgang/identifo/v2/jwt/validator"
+	l "github.com/madappgang/identifo/v2/localization"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+const (
+	SessionNameOAuth = "_oauth_session"
+
+	oauthAuthorizeCompletePath = "/oauth/authorize/complete"
+)
+
+// OAuth 2.0 error codes, as referenced at
+// https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+const (
+	oauthErrorInvalidRequest          = "invalid_request"
+	oauthErrorInvalidClient           = "invalid_client"
+	oauthErrorInvalidGrant            = "invalid_grant"
+	oauthErrorUnauthorizedClient      = "unauthorized_client"
+	oauthErrorInvalidScope            = "invalid_scope"
+	oauthErrorUnsupportedGrantType    = "unsupported_grant_type"
+	oauthErrorUnsupportedResponseType = "unsupported_response_type"
+	oauthErrorAccessDenied            = "access_denied"
+	oauthErrorServerError             = "server_error"
+)
+
+var (
+	errOAuthCodeChallengeRequired = errors.New("code_challenge is required")
+	errOAuthCodeChallengeMethod   = errors.New("code_challenge_method is not supported, only S256 is allowed")
+	errOAuthInvalidCodeVerifier   = errors.New("code_verifier does not match code_challenge")
+	errOAuthRedirectURIMismatch   = errors.New("redirect_uri does not match the authorization request")
+)
+
+// OAuthTokenResponse is a successful response of the token endpoint.
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func oauthSessionKey(appID string) string {
+	return "_oauth:" + appID
+}
+
+// OAuthAuthorize handles OAuth 2.0 authorization request.
+// Only authorization code flow with PKCE (S256) is supported.
+// The user is redirected to the login web app to authenticate,
+// the login web app calls back to OAuthAuthorizeComplete.
+func (ar *Router) OAuthAuthorize() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+		q := r.URL.Query()
+
+		clientID := q.Get("client_id")
+		app, err := ar.server.Storages().App.ActiveAppByID(clientID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorOauthClientInvalid, clientID, err)
+			return
+		}
+
+		// we must not redirect to the unregistered redirect uri, even with error
+		redirectURI := q.Get("redirect_uri")
+		if len(redirectURI) == 0 || !model.SliceContains(app.RedirectURLs, redirectURI) {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorOauthRedirectUriInvalid, redirectURI)
+			return
+		}
+
+		state := q.Get("state")
+
+		// service apps have no users, they use client credentials grant only
+		if app.Type == model.Service {
+			oauthRedirectError(w, r, redirectURI, state, oauthErrorUnauthorizedClient, "service app could not use authorization code grant")
+			return
+		}
+
+		if q.Get("response_type") != model.OAuthResponseTypeCode {
+			oauthRedirectError(w, r, redirectURI, state, oauthErrorUnsupportedResponseType, "only code response type is supported")
+			return
+		}
+
+		codeChallenge := q.Get("code_challenge")
+		if len(codeChallenge) == 0 {
+			oauthRedirectError(w, r, redirectURI, state, oauthErrorInvalidRequest, errOAuthCodeChallengeRequired.Error())
+			return
+		}
+
+		if q.Get("code_challenge_method") != model.CodeChallengeMethodS256 {
+			oauthRedirectError(w, r, redirectURI, state, oauthErrorInvalidRequest, errOAuthCodeChallengeMethod.Error())
+			return
+		}
+
+		areq := model.OAuthAuthorizationRequest{
+			AppID:               app.ID,
+			RedirectURI:         redirectURI,
+			State:               state,
+			Nonce:               q.Get("nonce"),
+			Scopes:              parseScopes(q.Get("scope"), " "),
+			CodeChallenge:       codeChallenge,
+			CodeChallengeMethod: model.CodeChallengeMethodS256,
+		}
+
+		err = storeInSession(SessionNameOAuth, oauthSessionKey(app.ID), areq.Marshal(), r, w)
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorOauthSessionError, err)
+			return
+		}
+
+		loginURL, err := ar.oauthLoginURL(r, app, areq.Scopes)
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorOauthRequestInvalid, err)
+			return
+		}
+
+		http.Redirect(w, r, loginURL, http.StatusFound)
+	}
+}
+
+// OAuthAuthorizeComplete is called by the login web app after the user has logged in.
+// It issues authorization code and redirects the user back to the client.
+func (ar *Router) OAuthAuthorizeComplete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+		q := r.URL.Query()
+
+		appID := q.Get(QueryKeyAppID)
+		app, err := ar.server.Storages().App.ActiveAppByID(appID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorOauthClientInvalid, appID, err)
+			return
+		}
+
+		sessionValue, err := ar.getFromSession(SessionNameOAuth, oauthSessionKey(app.ID), r)
+		if err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorOauthSessionError, err)
+			return
+		}
+
+		areq, err := model.UnmarshalOAuthAuthorizationRequest(sessionValue)
+		if err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorOauthSessionError, err)
+			return
+		}
+
+		if areq.AppID != app.ID || !model.SliceContains(app.RedirectURLs, areq.RedirectURI) {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorOauthRedirectUriInvalid, areq.RedirectURI)
+			return
+		}
+
+		user, loginToken, err := ar.oauthLoggedInUser(app, q.Get("token"), q.Get("refresh_token"))
+		if err != nil {
+			ar.logger.Warn("oauth authorization denied",
+				logging.FieldAppID, app.ID,
+				logging.FieldError, err)
+			oauthRedirectError(w, r, areq.RedirectURI, areq.State, oauthErrorAccessDenied, err.Error())
+			return
+		}
+
+		scopes := model.AllowedScopesWithOIDC(areq.Scopes, user.Scopes, app.Offline)
+
+		payload := map[string]interface{}{
+			"redirect_uri":          areq.RedirectURI,
+			"code_challenge":        areq.CodeChallenge,
+			"code_challenge_method": areq.CodeChallengeMethod,
+			"auth_time":             loginToken.IssuedAt().Unix(),
+		}
+		if len(areq.Nonce) > 0 {
+			payload["nonce"] = areq.Nonce
+		}
+
+		code, err := ar.server.Services().Token.NewAuthorizationCodeToken(user, scopes, app, payload)
+		if err != nil {
+			oauthRedirectError(w, r, areq.RedirectURI, areq.State, oauthErrorServerError, err.Error())
+			return
+		}
+
+		codeString, err := ar.server.Services().Token.String(code)
+		if err != nil {
+			oauthRedirectError(w, r, areq.RedirectURI, areq.State, oauthErrorServerError, err.Error())
+			return
+		}
+
+		params := url.Values{}
+		params.Set("code", codeString)
+		if len(areq.State) > 0 {
+			params.Set("state", areq.State)
+		}
+
+		http.Redirect(w, r, appendQuery(areq.RedirectURI, params), http.StatusFound)
+	}
+}
+
+// oauthLoggedInUser checks the tokens issued by the login web app and returns the logged in user with the login token.
+// The tokens have been passed in URL, so they are revoked right away.
+func (ar *Router) oauthLoggedInUser(app model.AppData, accessToken, refreshToken string) (model.User, model.Token, error) {
+	if len(accessToken) == 0 {
+		return model.User{}, nil, errors.New("user has not logged in")
+	}
+
+	v := jwtValidator.NewValidator(
+		[]string{app.ID},
+		[]string{ar.server.Services().Token.Issuer()},
+		[]string{},
+		[]string{model.TokenTypeAccess},
+	)
+
+	token, err := ar.server.Services().Token.Parse(accessToken)
+	if err != nil {
+		return model.User{}, nil, err
+	}
+
+	if err := v.Validate(token); err != nil {
+		return model.User{}, nil, err
+	}
+
+	if ar.isTokenBlacklisted(accessToken) {
+		return model.User{}, nil, model.ErrTokenInvalid
+	}
+
+	if strings.Contains(token.Scopes(), model.TokenTypeTFAPreauth) {
+		return model.User{}, nil, errors.New("two-factor authentication is not completed")
+	}
+
+	if err := ar.blacklistToken(accessToken); err != nil {
+		return model.User{}, nil, err
+	}
+
+	if len(refreshToken) > 0 {
+		if err := ar.server.Storages().Token.DeleteToken(refreshToken); err != nil {
+			ar.logger.Error("Failed to delete refresh token issued for OAuth authorization",
+				logging.FieldError, err)
+		}
+		ar.endSession(refreshToken)
+	}
+
+	user, err := ar.server.Storages().User.UserByID(token.UserID())
+	if err != nil {
+		return model.User{}, nil, err
+	}
+
+	if !user.Active {
+		return model.User{}, nil, errors.New("user is inactive")
+	}
+
+	return user, token, nil
+}
+
+// OAuthToken is an OAuth 2.0 token endpoint.
+func (ar *Router) OAuthToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, err.Error())
+			return
+		}
+
+		app, authenticated, ok := ar.oauthClient(w, r)
+		if !ok {
+			return
+		}
+
+		switch grantType := r.PostForm.Get("grant_type"); grantType {
+		case model.OAuthGrantTypeAuthorizationCode:
+			ar.exchangeAuthorizationCode(w, r, app)
+		case model.OAuthGrantTypeClientCredentials:
+			if !authenticated {
+				ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "client secret is required")
+				return
+			}
+			ar.exchangeClientCredentials(w, r, app)
+		default:
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorUnsupportedGrantType, fmt.Sprintf("grant type %q is not supported", grantType))
+		}
+	}
+}
+
+// oauthClient authenticates the client with HTTP Basic auth or with form params.
+// Public clients could omit the secret, as they are protected with PKCE, confidential clients must authenticate.
+// Returns the app and whether the client has been authenticated with the secret.
+func (ar *Router) oauthClient(w http.ResponseWriter, r *http.Request) (model.AppData, bool, bool) {
+	clientID, clientSecret, basicAuth := r.BasicAuth()
+	if !basicAuth {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	app, err := ar.server.Storages().App.ActiveAppByID(clientID)
+	if err != nil {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "unknown client")
+		return model.AppData{}, false, false
+	}
+
+	// the client authentication method "none" is allowed for public clients only.
+	if len(clientSecret) == 0 {
+		if !app.IsPublicClient() {
+			ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "client secret is required")
+			return model.AppData{}, false, false
+		}
+		return app, false, true
+	}
+
+	if len(app.Secret) == 0 || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(app.Secret)) != 1 {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "invalid client secret")
+		return model.AppData{}, false, false
+	}
+
+	return app, true, true
+}
+
+func (ar *Router) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, app model.AppData) {
+	if app.Type == model.Service {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorUnauthorizedClient, "service app could not use authorization code grant")
+		return
+	}
+
+	codeString := r.PostForm.Get("code")
+
+	v := jwtValidator.NewValidator(
+		[]string{app.ID},
+		[]string{ar.server.Services().Token.Issuer()},
+		[]string{},
+		[]string{model.TokenTypeAuthorizationCode},
+	)
+
+	code, err := ar.server.Services().Token.Parse(codeString)
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+
+	if err := v.Validate(code); err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+
+	// authorization code is single use
+	if ar.isTokenBlacklisted(codeString) {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "authorization code has been used")
+		return
+	}
+
+	if err := ar.blacklistToken(codeString); err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	payload := code.Payload()
+
+	if redirectURI, _ := payload["redirect_uri"].(string); redirectURI != r.PostForm.Get("redirect_uri") {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, errOAuthRedirectURIMismatch.Error())
+		return
+	}
+
+	challenge, _ := payload["code_challenge"].(string)
+	if err := verifyCodeChallenge(challenge, r.PostForm.Get("code_verifier")); err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+
+	user, err := ar.server.Storages().User.UserByID(code.UserID())
+	if err != nil || !user.Active {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "user is not found or inactive")
+		return
+	}
+	if !emailVerified(app, user) {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, errEmailNotVerified.Error())
+		return
+	}
+
+	scopes := model.AllowedScopesWithOIDC(strings.Fields(code.Scopes()), user.Scopes, app.Offline)
+
+	tokenPayload, err := ar.getTokenPayloadForApp(app, user.ID)
+	if err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	accessToken, refreshToken, err := ar.loginUser(user, scopes, app, false, tokenPayload)
+	if err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	idToken := ""
+	if scopes.Contains(model.OIDCScopeOpenID) {
+		nonce, _ := payload["nonce"].(string)
+		authTime, _ := payload["auth_time"].(float64)
+
+		idToken, err = ar.newIDToken(user, scopes, app, nonce, int64(authTime), accessToken)
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+			return
+		}
+	}
+
+	if err := ar.startSession(r, app.ID, user.ID, refreshToken); err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	ar.audit(model.AuditOperationOAuthAuthorizationCode, r,
+		user.ID, app.ID, user.AccessRole, scopes.Scopes(),
+		accessToken, refreshToken)
+
+	ar.serveOAuthToken(w, accessToken, refreshToken, idToken, scopes)
+}
+
+// exchangeClientCredentials issues access token for service app.
+// Requested scopes are limited by the app scopes, all app scopes are granted if none requested.
+func (ar *Router) exchangeClientCredentials(w http.ResponseWriter, r *http.Request, app model.AppData) {
+	if app.Type != model.Service {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorUnauthorizedClient, "only service apps could use client credentials grant")
+		return
+	}
+
+	requestedScopes := parseScopes(r.PostForm.Get("scope"), " ")
+	if len(requestedScopes) == 0 {
+		requestedScopes = app.Scopes
+	}
+
+	scopes := model.AllowedScopes(requestedScopes, app.Scopes, false)
+	if len(requestedScopes) > 0 && len(scopes.Scopes()) == 0 {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidScope, "requested scopes are not allowed for the app")
+		return
+	}
+
+	token, err := ar.server.Services().Token.NewServiceToken(app, scopes)
+	if err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	accessToken, err := ar.server.Services().Token.String(token)
+	if err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	ar.audit(model.AuditOperationClientCredentials, r,
+		"", app.ID, "", scopes.Scopes(),
+		accessToken, "")
+
+	ar.serveOAuthToken(w, accessToken, "", "", scopes)
+}
+
+func (ar *Router) newIDToken(
+	user model.User,
+	scopes model.AllowedScopesSet,
+	app model.AppData,
+	nonce string,
+	authTime int64,
+	accessToken string,
+) (string, error) {
+	token, err := ar.server.Services().Token.NewIDToken(user, scopes, app, nonce, authTime, accessToken)
+	if err != nil {
+		return "", err
+	}
+
+	return ar.server.Services().Token.String(token)
+}
+
+func (ar *Router) serveOAuthToken(w http.ResponseWriter, accessToken, refreshToken, idToken string, scopes model.AllowedScopesSet) {
+	resp := OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+		Scope:        scopes.String(),
+	}
+
+	if token, err := ar.server.Services().Token.Parse(accessToken); err == nil {
+		resp.ExpiresIn = int64(time.Until(token.ExpiresAt()).Seconds())
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	ar.ServeJSON(w, "", http.StatusOK, resp)
+}
+
+// oauthError writes error response in the format required by RFC 6749.
+func (ar *Router) oauthError(w http.ResponseWriter, status int, code, description string) {
+	ar.logger.Warn("oauth error",
+		"error", code,
+		"description", description,
+		"status", status)
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="identifo"`)
+	}
+
+	ar.ServeJSON(w, "", status, oauthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
+
+// oauthRedirectError redirects user back to the client with error.
+func oauthRedirectError(w http.ResponseWriter, r *http.Request, redirectURI, state, code, description string) {
+	params := url.Values{}
+	params.Set("error", code)
+	params.Set("error_description", description)
+	if len(state) > 0 {
+		params.Set("state", state)
+	}
+
+	http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+}
+
+// oauthLoginURL builds the login web app URL, which calls back OAuthAuthorizeComplete after login.
+func (ar *Router) oauthLoginURL(r *http.Request, app model.AppData, scopes []string) (string, error) {
+	host := ar.hostURL(r)
+
+	u := &url.URL{
+		Scheme: host.Scheme,
+		Host:   host.Host,
+		Path:   model.DefaultLoginWebAppSettings.LoginURL,
+	}
+
+	// rewrite path for app, if app has specific web app login settings
+	if app.LoginAppSettings != nil && len(app.LoginAppSettings.LoginURL) > 0 {
+		appSpecificURL, err := url.Parse(app.LoginAppSettings.LoginURL)
+		if err != nil {
+			return "", err
+		}
+
+		// app settings could rewrite host or just path, if path is absolute - it rewrites host as well
+		if appSpecificURL.IsAbs() {
+			u.Scheme = appSpecificURL.Scheme
+			u.Host = appSpecificURL.Host
+		}
+
+		u.Path = appSpecificURL.Path
+	}
+
+	callback := &url.URL{
+		Scheme:   host.Scheme,
+		Host:     host.Host,
+		Path:     oauthAuthorizeCompletePath,
+		RawQuery: url.Values{QueryKeyAppID: []string{app.ID}}.Encode(),
+	}
+
+	q := url.Values{}
+	q.Set(QueryKeyAppID, app.ID)
+	q.Set("callbackUrl", callback.String())
+	if len(scopes) > 0 {
+		q.Set("scopes", strings.Join(scopes, ","))
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// hostURL returns public host of the server, falls back to the request host if it is not configured.
+func (ar *Router) hostURL(r *http.Request) *url.URL {
+	if ar.Host != nil && len(ar.Host.Host) > 0 {
+		return &url.URL{Scheme: ar.Host.Scheme, Host: ar.Host.Host}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return &url.URL{Scheme: scheme, Host: r.Host}
+}
+
+// verifyCodeChallenge checks PKCE code verifier against S256 code challenge.
+func verifyCodeChallenge(challenge, verifier string) error {
+	// https://datatracker.ietf.org/doc/html/rfc7636#section-4.1
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return errOAuthInvalidCodeVerifier
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) != 1 {
+		return errOAuthInvalidCodeVerifier
+	}
+
+	return nil
+}
+
+func appendQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"net/http"
+
+	jwtValidator "github.com/madappgang/identifo/v2/jwt/validator"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// IntrospectionResponse is a token introspection response, as referenced at
+// https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// introspectableTokenTypes are token types, which could be checked by resource servers.
+var introspectableTokenTypes = []string{
+	model.TokenTypeAccess,
+	model.TokenTypeRefresh,
+	model.TokenTypeService,
+}
+
+// OAuthIntrospect is an OAuth 2.0 token introspection endpoint (RFC 7662).
+// The client must authenticate with its secret. Service apps could introspect tokens of any app,
+// other apps only the tokens issued for them.
+func (ar *Router) OAuthIntrospect() http.HandlerFunc {
+	inactive := IntrospectionResponse{Active: false}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, err.Error())
+			return
+		}
+
+		app, authenticated, ok := ar.oauthClient(w, r)
+		if !ok {
+			return
+		}
+		if !authenticated {
+			ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "client secret is required")
+			return
+		}
+
+		tokenString := r.PostForm.Get("token")
+		if len(tokenString) == 0 {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "token is required")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		token, active, err := ar.activeToken(tokenString)
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+			return
+		}
+		if !active {
+			ar.ServeJSON(w, "", http.StatusOK, inactive)
+			return
+		}
+
+		if app.Type != model.Service && token.Audience() != app.ID {
+			ar.ServeJSON(w, "", http.StatusOK, inactive)
+			return
+		}
+
+		ar.ServeJSON(w, "", http.StatusOK, IntrospectionResponse{
+			Active:    true,
+			Scope:     token.Scopes(),
+			ClientID:  token.Audience(),
+			TokenType: token.Type(),
+			Exp:       token.ExpiresAt().Unix(),
+			Iat:       token.IssuedAt().Unix(),
+			Sub:       token.Subject(),
+			Aud:       token.Audience(),
+			Iss:       token.Issuer(),
+			Jti:       token.ID(),
+		})
+	}
+}
+
+// activeToken checks if the token is valid, is not blacklisted, and for refresh token, is not revoked.
+// The storage error is returned, as the token state is unknown.
+func (ar *Router) activeToken(tokenString string) (model.Token, bool, error) {
+	token, err := ar.server.Services().Token.Parse(tokenString)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	v := jwtValidator.NewValidator(
+		[]string{},
+		[]string{ar.server.Services().Token.Issuer()},
+		[]string{},
+		introspectableTokenTypes,
+	)
+	if err := v.Validate(token); err != nil {
+		return nil, false, nil
+	}
+
+	if ar.isTokenBlacklisted(tokenString) {
+		return nil, false, nil
+	}
+
+	if token.Type() == model.TokenTypeRefresh && !ar.server.Storages().Token.HasToken(tokenString) {
+		return nil, false, nil
+	}
+
+	ended, err := ar.sessionEnded(token)
+	if err != nil || ended {
+		return nil, false, err
+	}
+
+	return token, true, nil
+}
+
+// OAuthRevoke is an OAuth 2.0 token revocation endpoint (RFC 7009).
+// Both access and refresh tokens could be revoked, the token must be issued for the client.
+// Invalid tokens do not cause an error response, as required by RFC 7009.
+func (ar *Router) OAuthRevoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, err.Error())
+			return
+		}
+
+		app, authenticated, ok := ar.oauthClient(w, r)
+		if !ok {
+			return
+		}
+		if !authenticated {
+			ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "client secret is required")
+			return
+		}
+
+		tokenString := r.PostForm.Get("token")
+		if len(tokenString) == 0 {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "token is required")
+			return
+		}
+
+		token, active, err := ar.activeToken(tokenString)
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+			return
+		}
+		if !active {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if token.Audience() != app.ID {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorUnauthorizedClient, "the token was not issued to the client")
+			return
+		}
+
+		if token.Type() == model.TokenTypeRefresh {
+			if err := ar.server.Storages().Token.DeleteToken(tokenString); err != nil {
+				ar.logger.Error("Cannot delete refresh token",
+					logging.FieldError, err)
+			}
+			ar.endSession(tokenString)
+		}
+
+		if err := ar.blacklistToken(tokenString); err != nil {
+			ar.oauthError(w, http.StatusServiceUnavailable, oauthErrorServerError, err.Error())
+			return
+		}
+
+		ar.audit(model.AuditOperationRevokeToken, r,
+			token.Subject(), app.ID, "", nil,
+			"", "")
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
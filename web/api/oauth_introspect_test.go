@@ -0,0 +1,119 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOAuthFormRequest(h http.HandlerFunc, clientID, secret string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/oauth", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(clientID) > 0 {
+		r.SetBasicAuth(clientID, secret)
+	}
+
+	rw := httptest.NewRecorder()
+	h(rw, r)
+	return rw
+}
+
+func testIntrospect(t *testing.T, clientID, secret, token string) map[string]any {
+	rw := testOAuthFormRequest(testRouter.OAuthIntrospect(), clientID, secret, url.Values{"token": []string{token}})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	return resp
+}
+
+func Test_Router_OAuth_IntrospectRevoke(t *testing.T) {
+	app := testOAuthApp
+	app.ID = "test_introspect_app"
+	app.Secret = "introspect_secret"
+	_, err := testServer.Storages().App.CreateApp(app)
+	require.NoError(t, err)
+
+	resourceServer := model.AppData{
+		ID:     "test_resource_server",
+		Secret: "resource_secret",
+		Active: true,
+		Type:   model.Service,
+	}
+	_, err = testServer.Storages().App.CreateApp(resourceServer)
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "oauth_introspect", "+15550000003")
+	ts := testServer.Services().Token
+
+	scopes := model.AllowedScopes([]string{"chat", "offline"}, user.Scopes, true)
+
+	at, err := ts.NewAccessToken(user, scopes, app, false, nil)
+	require.NoError(t, err)
+	ats, err := ts.String(at)
+	require.NoError(t, err)
+
+	rt, err := ts.NewRefreshToken(user, scopes, app)
+	require.NoError(t, err)
+	rts, err := ts.String(rt)
+	require.NoError(t, err)
+	_, err = testServer.Storages().UserSession.AddSession(model.UserSession{
+		UserID:         user.ID,
+		AppID:          app.ID,
+		RefreshTokenID: rt.ID(),
+		ExpiresAt:      rt.ExpiresAt(),
+	})
+	require.NoError(t, err)
+
+	// authentication is required
+	rw := testOAuthFormRequest(testRouter.OAuthIntrospect(), "", "", url.Values{"token": []string{ats}})
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	resp := testIntrospect(t, resourceServer.ID, resourceServer.Secret, ats)
+	assert.Equal(t, true, resp["active"])
+	assert.Equal(t, "chat offline", resp["scope"])
+	assert.Equal(t, app.ID, resp["client_id"])
+	assert.Equal(t, user.ID, resp["sub"])
+	assert.NotEmpty(t, resp["exp"])
+
+	resp = testIntrospect(t, app.ID, app.Secret, rts)
+	assert.Equal(t, true, resp["active"])
+
+	// other non service app could not see the token
+	other := app
+	other.ID = "test_introspect_other_app"
+	_, err = testServer.Storages().App.CreateApp(other)
+	require.NoError(t, err)
+
+	resp = testIntrospect(t, other.ID, other.Secret, ats)
+	assert.Equal(t, false, resp["active"])
+
+	// and could not revoke it
+	rw = testOAuthFormRequest(testRouter.OAuthRevoke(), other.ID, other.Secret, url.Values{"token": []string{ats}})
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+
+	// revoke both tokens
+	rw = testOAuthFormRequest(testRouter.OAuthRevoke(), app.ID, app.Secret, url.Values{"token": []string{ats}})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	rw = testOAuthFormRequest(testRouter.OAuthRevoke(), app.ID, app.Secret, url.Values{"token": []string{rts}})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	resp = testIntrospect(t, resourceServer.ID, resourceServer.Secret, ats)
+	assert.Equal(t, false, resp["active"])
+	assert.Len(t, resp, 1, "inactive response should not have other fields")
+
+	resp = testIntrospect(t, resourceServer.ID, resourceServer.Secret, rts)
+	assert.Equal(t, false, resp["active"])
+
+	// revoking invalid token is not an error
+	rw = testOAuthFormRequest(testRouter.OAuthRevoke(), app.ID, app.Secret, url.Values{"token": []string{"invalid"}})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+}
@@ -0,0 +1,355 @@
+package api_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testOAuthApp = model.AppData{
+	ID:           "test_oauth_app",
+	Active:       true,
+	Offline:      true,
+	Type:         model.Web,
+	RedirectURLs: []string{"http://localhost:3000/callback"},
+}
+
+const testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUfU1p1r_wW1gFWFOEjXk"
+
+func testCodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// testOAuthAuthorize runs the authorization request and login callback, returns the redirect to the client.
+func testOAuthAuthorize(t *testing.T, user model.User, query url.Values) *url.URL {
+	r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	rw := httptest.NewRecorder()
+
+	testRouter.OAuthAuthorize()(rw, r)
+	require.Equal(t, http.StatusFound, rw.Code, rw.Body.String())
+
+	loginURL, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/web", loginURL.Path)
+	assert.Equal(t, testOAuthApp.ID, loginURL.Query().Get("appId"))
+
+	// login web app calls back with the access token
+	scopes := model.AllowedScopes([]string{"offline", "chat"}, user.Scopes, true)
+	at, err := testServer.Services().Token.NewAccessToken(user, scopes, testOAuthApp, false, nil)
+	require.NoError(t, err)
+	ats, err := testServer.Services().Token.String(at)
+	require.NoError(t, err)
+
+	callbackURL, err := url.Parse(loginURL.Query().Get("callbackUrl"))
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth/authorize/complete", callbackURL.Path)
+
+	cq := callbackURL.Query()
+	cq.Set("token", ats)
+	callbackURL.RawQuery = cq.Encode()
+
+	r = httptest.NewRequest(http.MethodGet, callbackURL.String(), nil)
+	r.Header.Set("Cookie", rw.Header().Get("Set-Cookie"))
+	crw := httptest.NewRecorder()
+
+	testRouter.OAuthAuthorizeComplete()(crw, r)
+	require.Equal(t, http.StatusFound, crw.Code, crw.Body.String())
+
+	redirect, err := url.Parse(crw.Header().Get("Location"))
+	require.NoError(t, err)
+
+	return redirect
+}
+
+func testOAuthToken(form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+
+	testRouter.OAuthToken()(rw, r)
+	return rw
+}
+
+func testOAuthUser(t *testing.T, username, phone string) model.User {
+	user, err := testServer.Storages().User.AddUserWithPassword(model.User{
+		Username: username,
+		Email:    username + "@example.com",
+		Phone:    phone,
+		Scopes:   []string{"chat"},
+		Active:   true,
+	}, "qwerty", "user", false)
+	require.NoError(t, err)
+
+	return user
+}
+
+func Test_Router_OAuth_AuthorizationCode(t *testing.T) {
+	_, err := testServer.Storages().App.CreateApp(testOAuthApp)
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "oauth_code", "+15550000001")
+
+	query := url.Values{
+		"response_type":         []string{"code"},
+		"client_id":             []string{testOAuthApp.ID},
+		"redirect_uri":          []string{testOAuthApp.RedirectURLs[0]},
+		"scope":                 []string{"offline chat admin"},
+		"state":                 []string{"xyz"},
+		"code_challenge":        []string{testCodeChallenge(testCodeVerifier)},
+		"code_challenge_method": []string{"S256"},
+	}
+
+	redirect := testOAuthAuthorize(t, user, query)
+	assert.Equal(t, "localhost:3000", redirect.Host)
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+
+	code := redirect.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	form := url.Values{
+		"grant_type":    []string{"authorization_code"},
+		"client_id":     []string{testOAuthApp.ID},
+		"code":          []string{code},
+		"redirect_uri":  []string{testOAuthApp.RedirectURLs[0]},
+		"code_verifier": []string{"wrong-verifier-wrong-verifier-wrong-verifier"},
+	}
+
+	// wrong verifier burns the code
+	rw := testOAuthToken(form)
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+
+	redirect = testOAuthAuthorize(t, user, query)
+	form.Set("code", redirect.Query().Get("code"))
+	form.Set("code_verifier", testCodeVerifier)
+
+	rw = testOAuthToken(form)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+
+	c := claimsFromResponse(t, rw.Body.Bytes())
+	assert.Equal(t, user.ID, c["sub"])
+	assert.Equal(t, testOAuthApp.ID, c["aud"])
+	assert.Equal(t, "chat offline", c["scopes"])
+
+	c = refreshClaimsFromResponse(t, rw.Body.Bytes())
+	assert.Equal(t, user.ID, c["sub"])
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	assert.Equal(t, "Bearer", resp["token_type"])
+	assert.Equal(t, "chat offline", resp["scope"])
+
+	// code is single use
+	rw = testOAuthToken(form)
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_grant", resp["error"])
+}
+
+func Test_Router_OAuth_ConfidentialClient(t *testing.T) {
+	_, err := testServer.Storages().App.CreateApp(testOAuthApp)
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "oauth_confidential", "+15550000029")
+	query := url.Values{
+		"response_type":         []string{"code"},
+		"client_id":             []string{testOAuthApp.ID},
+		"redirect_uri":          []string{testOAuthApp.RedirectURLs[0]},
+		"code_challenge":        []string{testCodeChallenge(testCodeVerifier)},
+		"code_challenge_method": []string{"S256"},
+	}
+	form := url.Values{
+		"grant_type":    []string{"authorization_code"},
+		"client_id":     []string{testOAuthApp.ID},
+		"redirect_uri":  []string{testOAuthApp.RedirectURLs[0]},
+		"code_verifier": []string{testCodeVerifier},
+	}
+
+	// the app with the secret is the confidential client
+	confidential := testOAuthApp
+	confidential.Secret = "confidential_secret"
+	_, err = testServer.Storages().App.UpdateApp(confidential.ID, confidential)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := testServer.Storages().App.UpdateApp(testOAuthApp.ID, testOAuthApp)
+		require.NoError(t, err)
+	})
+
+	form.Set("code", testOAuthAuthorize(t, user, query).Query().Get("code"))
+	rw := testOAuthToken(form)
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_client", resp["error"])
+
+	form.Set("code", testOAuthAuthorize(t, user, query).Query().Get("code"))
+	form.Set("client_secret", confidential.Secret)
+	rw = testOAuthToken(form)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+}
+
+func Test_Router_OAuth_Authorize_InvalidRequest(t *testing.T) {
+	_, err := testServer.Storages().App.CreateApp(testOAuthApp)
+	require.NoError(t, err)
+
+	// unregistered redirect uri must not be redirected to
+	query := url.Values{
+		"response_type":         []string{"code"},
+		"client_id":             []string{testApp.ID},
+		"redirect_uri":          []string{"http://evil.example.com"},
+		"code_challenge":        []string{testCodeChallenge(testCodeVerifier)},
+		"code_challenge_method": []string{"S256"},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	rw := httptest.NewRecorder()
+	testRouter.OAuthAuthorize()(rw, r)
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	// plain PKCE is not allowed
+	query.Set("client_id", testOAuthApp.ID)
+	query.Set("redirect_uri", testOAuthApp.RedirectURLs[0])
+	query.Set("code_challenge_method", "plain")
+
+	r = httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	rw = httptest.NewRecorder()
+	testRouter.OAuthAuthorize()(rw, r)
+	require.Equal(t, http.StatusFound, rw.Code)
+
+	redirect, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "invalid_request", redirect.Query().Get("error"))
+}
+
+func Test_Router_OAuth_OpenIDConnect(t *testing.T) {
+	_, err := testServer.Storages().App.CreateApp(testOAuthApp)
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "oauth_oidc", "+15550000002")
+
+	query := url.Values{
+		"response_type":         []string{"code"},
+		"client_id":             []string{testOAuthApp.ID},
+		"redirect_uri":          []string{testOAuthApp.RedirectURLs[0]},
+		"scope":                 []string{"openid email"},
+		"nonce":                 []string{"n-0S6_WzA2Mj"},
+		"code_challenge":        []string{testCodeChallenge(testCodeVerifier)},
+		"code_challenge_method": []string{"S256"},
+	}
+
+	redirect := testOAuthAuthorize(t, user, query)
+
+	rw := testOAuthToken(url.Values{
+		"grant_type":    []string{"authorization_code"},
+		"client_id":     []string{testOAuthApp.ID},
+		"code":          []string{redirect.Query().Get("code")},
+		"redirect_uri":  []string{testOAuthApp.RedirectURLs[0]},
+		"code_verifier": []string{testCodeVerifier},
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	c := claimsFromJSONResponse(t, "id_token", rw.Body.Bytes())
+	assert.Equal(t, user.ID, c["sub"])
+	assert.Equal(t, testOAuthApp.ID, c["aud"])
+	assert.Equal(t, "n-0S6_WzA2Mj", c["nonce"])
+	assert.Equal(t, user.Email, c["email"])
+	assert.NotEmpty(t, c["at_hash"])
+	assert.NotEmpty(t, c["auth_time"])
+	assert.Empty(t, c["phone_number"], "phone scope is not granted")
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+
+	// userinfo returns claims for granted scopes
+	r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	r.Header.Set("Authorization", "Bearer "+resp["access_token"].(string))
+	rw = httptest.NewRecorder()
+	testRouter.UserInfo()(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var info map[string]any
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &info))
+	assert.Equal(t, user.ID, info["sub"])
+	assert.Equal(t, user.Email, info["email"])
+	assert.Nil(t, info["phone_number"])
+	assert.Nil(t, info["preferred_username"])
+
+	// access token without openid scope is not allowed
+	at, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testOAuthApp, false, nil)
+	require.NoError(t, err)
+	ats, err := testServer.Services().Token.String(at)
+	require.NoError(t, err)
+
+	r = httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	r.Header.Set("Authorization", "Bearer "+ats)
+	rw = httptest.NewRecorder()
+	testRouter.UserInfo()(rw, r)
+	require.Equal(t, http.StatusForbidden, rw.Code, rw.Body.String())
+}
+
+func Test_Router_OAuth_ClientCredentials(t *testing.T) {
+	serviceApp := model.AppData{
+		ID:     "test_service_app",
+		Secret: "service_secret",
+		Active: true,
+		Type:   model.Service,
+		Scopes: []string{"billing", "reports"},
+	}
+	_, err := testServer.Storages().App.CreateApp(serviceApp)
+	require.NoError(t, err)
+
+	form := url.Values{
+		"grant_type": []string{"client_credentials"},
+		"scope":      []string{"billing admin"},
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth(serviceApp.ID, serviceApp.Secret)
+	rw := httptest.NewRecorder()
+	testRouter.OAuthToken()(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	c := claimsFromResponse(t, rw.Body.Bytes())
+	assert.Empty(t, c["sub"])
+	assert.Equal(t, serviceApp.ID, c["aud"])
+	assert.Equal(t, model.TokenTypeService, c["type"])
+	assert.Equal(t, "billing", c["scopes"])
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	assert.Nil(t, resp["refresh_token"])
+
+	// secret is required
+	form.Set("client_id", serviceApp.ID)
+	rw = testOAuthToken(form)
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	form.Set("client_secret", "wrong")
+	rw = testOAuthToken(form)
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	// only service apps are allowed
+	webApp := testOAuthApp
+	webApp.ID = "test_oauth_app_with_secret"
+	webApp.Secret = "web_secret"
+	_, err = testServer.Storages().App.CreateApp(webApp)
+	require.NoError(t, err)
+
+	form.Set("client_id", webApp.ID)
+	form.Set("client_secret", webApp.Secret)
+	rw = testOAuthToken(form)
+	require.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	assert.Equal(t, "unauthorized_client", resp["error"])
+}
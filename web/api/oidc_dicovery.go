@@ -6,16 +6,29 @@ import (
 	"encoding/base64"
 	"math/big"
 	"net/http"
+
+	"github.com/madappgang/identifo/v2/model"
 )
 
 // OIDCConfiguration describes OIDC configuration.
 // Additional info: https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
-// Identifo is not an OIDC provider, that's why we only provide the information for token validation.
 type OIDCConfiguration struct {
-	Issuer                 string   `json:"issuer"`
-	JwksURI                string   `json:"jwks_uri"`
-	ScopesSupported        []string `json:"scopes_supported"`
-	SupportedIDSigningAlgs []string `json:"id_token_signing_alg_values_supported"`
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	SupportedIDSigningAlgs            []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
 }
 
 type JWK struct {
@@ -55,11 +68,32 @@ func (ar *Router) OIDCConfiguration() http.HandlerFunc {
 		locale := r.Header.Get("Accept-Language")
 
 		if ar.oidcConfiguration == nil {
+			issuer := ar.server.Services().Token.Issuer()
+
+			scopes := append([]string{}, model.OIDCScopes...)
+			for _, s := range ar.server.Settings().General.SupportedScopes {
+				if !model.SliceContains(scopes, s) {
+					scopes = append(scopes, s)
+				}
+			}
+
 			ar.oidcConfiguration = &OIDCConfiguration{
-				Issuer:                 ar.server.Services().Token.Issuer(),
-				JwksURI:                ar.server.Services().Token.Issuer() + "/.well-known/jwks.json",
-				ScopesSupported:        ar.server.Settings().General.SupportedScopes,
-				SupportedIDSigningAlgs: []string{ar.server.Services().Token.Algorithm()},
+				Issuer:                            issuer,
+				AuthorizationEndpoint:             issuer + "/oauth/authorize",
+				TokenEndpoint:                     issuer + "/oauth/token",
+				UserInfoEndpoint:                  issuer + "/userinfo",
+				IntrospectionEndpoint:             issuer + "/oauth/introspect",
+				RevocationEndpoint:                issuer + "/oauth/revoke",
+				JwksURI:                           issuer + "/.well-known/jwks.json",
+				ScopesSupported:                   scopes,
+				ResponseTypesSupported:            []string{model.OAuthResponseTypeCode},
+				ResponseModesSupported:            []string{"query"},
+				GrantTypesSupported:               []string{model.OAuthGrantTypeAuthorizationCode, model.OAuthGrantTypeClientCredentials},
+				SubjectTypesSupported:             []string{"public"},
+				SupportedIDSigningAlgs:            []string{ar.server.Services().Token.Algorithm()},
+				TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+				ClaimsSupported:                   model.OIDCClaimsSupported,
+				CodeChallengeMethodsSupported:     []string{model.CodeChallengeMethodS256},
 			}
 		}
 		ar.ServeJSON(w, locale, http.StatusOK, ar.oidcConfiguration)
@@ -74,23 +108,19 @@ func (ar *Router) OIDCConfiguration() http.HandlerFunc {
 // At the most basic level, the JWKS is a set of keys containing the public keys that should
 // be used to verify any JWT issued by the authorization server.
 // This endpoint exposes a JWKS endpoint for each tenant, which can be found at https://YOUR_IDENTIFO_DOMAIN/.well-known/jwks.json.
-// Currently Identifo only supports a single JWK for signing,
-// however it is important to assume this endpoint technically could contain multiple JWKs.
+// All non-expired keys of the signing keys ring are published: the active key, the next key,
+// which will be used after the rotation, and the retired keys to verify the tokens signed with them.
 func (ar *Router) OIDCJwks() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		locale := r.Header.Get("Accept-Language")
 
-		if ar.jwk != nil {
-			// A JSON object that represents a set of JWKs. The JSON object MUST have a keys member, which is an array of JWKs.
-			result := map[string]interface{}{"keys": []interface{}{ar.jwk}}
-			ar.ServeJSON(w, locale, http.StatusOK, result)
-			return
+		keys := []interface{}{}
+		for _, k := range ar.server.Services().Token.PublicKeys() {
+			keys = append(keys, CreateJWK(k.Algorithm, k.ID, k.Key))
 		}
 
-		ts := ar.server.Services().Token
-		ar.jwk = CreateJWK(ts.Algorithm(), ts.KeyID(), ts.PublicKey())
-
-		result := map[string]interface{}{"keys": []interface{}{ar.jwk}}
+		// A JSON object that represents a set of JWKs. The JSON object MUST have a keys member, which is an array of JWKs.
+		result := map[string]interface{}{"keys": keys}
 		ar.ServeJSON(w, locale, http.StatusOK, result)
 	}
 }
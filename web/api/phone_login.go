@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"strconv"
+	"time"
 
 	l "github.com/madappgang/identifo/v2/localization"
 	"github.com/madappgang/identifo/v2/logging"
@@ -15,6 +17,9 @@ import (
 	"github.com/madappgang/identifo/v2/web/middleware"
 )
 
+// AttemptsLeftHeader is the header with the number of attempts left to enter the verification code.
+const AttemptsLeftHeader = "X-Identifo-Attempts-Left"
+
 const (
 	phoneVerificationCodeLength = 6
 	smsVerificationCode         = "%v is your SMS verification code!"
@@ -42,8 +47,6 @@ func (ar *Router) RequestVerificationCode() http.HandlerFunc {
 			return
 		}
 
-		//// TODO: add limiter here. Check frequency of requests.
-		// TODO: rate limiter is a function of network infrastructure. Better to use AWS WAF or similar solution.
 		_, err := ar.server.Storages().User.UserByPhone(authData.PhoneNumber)
 		if err == model.ErrUserNotFound {
 			if !ar.server.Settings().Login.AllowRegisterMissing {
@@ -55,8 +58,17 @@ func (ar *Router) RequestVerificationCode() http.HandlerFunc {
 			return
 		}
 
+		// the code could not be resent until the cooldown passes, the attempts of the new code are counted from scratch.
 		code := randStringBytes(phoneVerificationCodeLength)
-		if err := ar.server.Storages().Verification.CreateVerificationCode(authData.PhoneNumber, code); err != nil {
+		policy := ar.server.Settings().Login.PhoneCode.Policy()
+		status, err := ar.server.Storages().Verification.CreateVerificationCode(authData.PhoneNumber, code, policy)
+		if err == model.ErrorVerificationCodeCooldown {
+			resendIn := secondsUntil(status.ResendAt)
+			w.Header().Set("Retry-After", strconv.FormatInt(resendIn, 10))
+			ar.Error(w, locale, http.StatusTooManyRequests, l.ErrorAPIVerificationCodeCooldown, time.Duration(resendIn)*time.Second)
+			return
+		}
+		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageVerificationCreateError, err)
 			return
 		}
@@ -65,7 +77,13 @@ func (ar *Router) RequestVerificationCode() http.HandlerFunc {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorServiceSmsSendError, err)
 			return
 		}
-		result := map[string]string{"result": "ok", "message": "SMS code is sent"}
+		result := map[string]any{
+			"result":        "ok",
+			"message":       "SMS code is sent",
+			"expires_in":    secondsUntil(status.ExpiresAt),
+			"resend_in":     secondsUntil(status.ResendAt),
+			"attempts_left": status.AttemptsLeft,
+		}
 		ar.ServeJSON(w, locale, http.StatusOK, result)
 	}
 }
@@ -94,18 +112,31 @@ func (ar *Router) PhoneLogin() http.HandlerFunc {
 			return
 		}
 
+		if ar.ipLoginLocked(w, r, locale) {
+			return
+		}
+
+		// the user is fetched before the code is verified to check the account lockout,
+		// missing user is registered after the verification if it is allowed
+		user, err := ar.server.Storages().User.UserByPhone(authData.PhoneNumber)
+		if err != nil && err != model.ErrUserNotFound {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageFindUserPhoneError, err)
+			return
+		}
+		if err == nil && ar.accountLoginLocked(w, locale, user.ID) {
+			return
+		}
+		if err == model.ErrUserNotFound && ar.identifierLoginLocked(w, locale, authData.PhoneNumber) {
+			return
+		}
+
 		needVerification := app.DebugTFACode == "" || authData.Code != app.DebugTFACode
 		if needVerification { // check verification code
-			if exists, err := ar.server.Storages().Verification.IsVerificationCodeFound(authData.PhoneNumber, authData.Code); err != nil {
-				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageVerificationFindError, err)
-				return
-			} else if !exists {
-				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPILoginCodeInvalid)
+			if !ar.verifyPhoneCode(w, r, locale, user.ID, authData) {
 				return
 			}
 		}
 
-		user, err := ar.server.Storages().User.UserByPhone(authData.PhoneNumber)
 		if err == model.ErrUserNotFound {
 			if !ar.server.Settings().Login.AllowRegisterMissing {
 				ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIAPPRegistrationForbidden)
@@ -114,16 +145,32 @@ func (ar *Router) PhoneLogin() http.HandlerFunc {
 
 			// Generate random password for feature reset if needed
 			user, err = ar.server.Storages().User.AddUserWithPassword(
-				model.User{Phone: authData.PhoneNumber, Scopes: model.SliceIntersect(app.Scopes, authData.Scopes)},
+				model.User{Phone: authData.PhoneNumber, PhoneVerified: needVerification, Scopes: model.SliceIntersect(app.Scopes, authData.Scopes)},
 				model.RandomPassword(15),
 				app.NewUserDefaultRole,
 				false)
+			if err == nil {
+				ar.emitWebhook(model.WebhookEventUserRegistered, app, user.ID, map[string]interface{}{
+					"phone": user.Phone,
+				})
+			}
 		}
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageFindUserPhoneError, err)
 			return
 		}
 
+		ar.loginSucceeded(user.ID)
+
+		// the code has been sent to the phone, so the user owns it
+		if needVerification && !user.PhoneVerified {
+			user.PhoneVerified = true
+			if user, err = ar.server.Storages().User.UpdateUser(user.ID, user); err != nil {
+				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUpdateUserError, user.ID, err)
+				return
+			}
+		}
+
 		if !needVerification {
 			ar.logger.Warn("Debug TFA code is used to login",
 				logging.FieldUserID, user.ID)
@@ -141,6 +188,10 @@ func (ar *Router) PhoneLogin() http.HandlerFunc {
 			return
 		}
 
+		if ar.emailNotVerified(w, locale, app, user) {
+			return
+		}
+
 		// if app requires scope, we need to check user has at leas one scope
 		if len(app.Scopes) > 0 && len(model.SliceIntersect(app.Scopes, user.Scopes)) == 0 {
 			ar.Error(w, locale, http.StatusForbidden, l.ErrorAPPLoginNoScope)
@@ -170,21 +221,70 @@ func (ar *Router) PhoneLogin() http.HandlerFunc {
 		}
 
 		ar.server.Storages().User.UpdateLoginMetadata(
-			string(AuditOperationLoginWithPhone),
+			string(model.AuditOperationLoginWithPhone),
 			app.ID,
 			user.ID,
 			scopes.Scopes(),
 			tokenPayload,
 		)
 
-		ar.audit(AuditOperationLoginWithPhone,
-			user.ID, app.ID, r.UserAgent(), user.AccessRole, scopes.Scopes(),
+		ar.emitWebhook(model.WebhookEventUserLogin, app, user.ID, map[string]interface{}{
+			"method": string(model.AuditOperationLoginWithPhone),
+		})
+
+		if err := ar.startSession(r, app.ID, user.ID, result.RefreshToken); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.audit(model.AuditOperationLoginWithPhone, r,
+			user.ID, app.ID, user.AccessRole, scopes.Scopes(),
 			result.AccessToken, result.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, result)
 	}
 }
 
+// verifyPhoneCode checks the verification code of the phone, serves the error and returns false if the code is not valid.
+// The attempts left are returned in AttemptsLeftHeader.
+func (ar *Router) verifyPhoneCode(w http.ResponseWriter, r *http.Request, locale, userID string, authData PhoneLogin) bool {
+	status, err := ar.server.Storages().Verification.VerifyCode(authData.PhoneNumber, authData.Code)
+	failed := func() {
+		if len(userID) == 0 {
+			ar.unknownLoginFailed(model.AuditOperationLoginWithPhone, r, authData.PhoneNumber)
+			return
+		}
+		ar.loginFailed(model.AuditOperationLoginWithPhone, r, userID)
+	}
+	switch err {
+	case nil:
+		return true
+	case model.ErrorVerificationCodeInvalid:
+		failed()
+		w.Header().Set(AttemptsLeftHeader, strconv.Itoa(status.AttemptsLeft))
+		ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPILoginCodeInvalidAttempts, status.AttemptsLeft)
+	case model.ErrorVerificationCodeAttemptsExceeded:
+		failed()
+		w.Header().Set(AttemptsLeftHeader, "0")
+		ar.Error(w, locale, http.StatusTooManyRequests, l.ErrorAPIVerificationCodeAttemptsExceeded)
+	case model.ErrorVerificationCodeExpired:
+		failed()
+		ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIVerificationCodeInvalid)
+	default:
+		ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageVerificationFindError, err)
+	}
+	return false
+}
+
+// secondsUntil returns the seconds left until the time, rounded up, or zero if the time has passed.
+func secondsUntil(t time.Time) int64 {
+	left := time.Until(t)
+	if left <= 0 {
+		return 0
+	}
+	return int64((left + time.Second - 1) / time.Second)
+}
+
 // Generate user code
 func randStringBytes(n int) string {
 	b := make([]byte, n)
@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/api"
+	"github.com/rs/cors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPhoneRequest(t *testing.T, h http.HandlerFunc, body map[string]string) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/phone_login", strings.NewReader(string(data)))
+	r = r.WithContext(testContext(testApp))
+
+	rw := httptest.NewRecorder()
+	h(rw, r)
+	return rw
+}
+
+func Test_Router_PhoneCodeLimits(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith: model.LoginWith{Phone: true},
+		Server:    testServer,
+		Cors:      cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+
+	user := testOAuthUser(t, "phone_code_user", "+15550000023")
+	policy := testServer.Settings().Login.PhoneCode
+
+	rw := testPhoneRequest(t, router.RequestVerificationCode(), map[string]string{"phone_number": user.Phone})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var sent struct {
+		ExpiresIn    int64 `json:"expires_in"`
+		ResendIn     int64 `json:"resend_in"`
+		AttemptsLeft int   `json:"attempts_left"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &sent))
+	assert.Equal(t, policy.TTL, sent.ExpiresIn)
+	assert.Equal(t, policy.ResendCooldown, sent.ResendIn)
+	assert.Equal(t, policy.MaxAttempts, sent.AttemptsLeft)
+
+	// the code could not be resent until the cooldown passes.
+	rw = testPhoneRequest(t, router.RequestVerificationCode(), map[string]string{"phone_number": user.Phone})
+	require.Equal(t, http.StatusTooManyRequests, rw.Code, rw.Body.String())
+	assert.NotEmpty(t, rw.Header().Get("Retry-After"))
+
+	for i := policy.MaxAttempts - 1; i >= 0; i-- {
+		rw = testPhoneRequest(t, router.PhoneLogin(), map[string]string{"phone_number": user.Phone, "code": "wrong"})
+		require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+		assert.Equal(t, strconv.Itoa(i), rw.Header().Get(api.AttemptsLeftHeader))
+	}
+
+	rw = testPhoneRequest(t, router.PhoneLogin(), map[string]string{"phone_number": user.Phone, "code": "wrong"})
+	require.Equal(t, http.StatusTooManyRequests, rw.Code, rw.Body.String())
+	assert.Equal(t, "0", rw.Header().Get(api.AttemptsLeftHeader))
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -48,6 +49,16 @@ func (ar *Router) RefreshTokens() http.HandlerFunc {
 			return
 		}
 
+		// the refresh token of the user who logged in before the app required verified email is not refreshed
+		user, err := ar.server.Storages().User.UserByID(oldRefreshToken.Subject())
+		if err != nil {
+			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorStorageFindUserIDError, oldRefreshToken.Subject(), err)
+			return
+		}
+		if ar.emailNotVerified(w, locale, app, user) {
+			return
+		}
+
 		tokenPayload, err := ar.getTokenPayloadForApp(app, oldRefreshToken.Subject())
 		if err != nil {
 			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIAPPUnableToTokenPayloadForAPPError, app.ID, err)
@@ -74,12 +85,17 @@ func (ar *Router) RefreshTokens() http.HandlerFunc {
 		}
 		oldRefreshTokenString := string(oldRefreshTokenBytes)
 
-		newRefreshTokenString, err := ar.issueNewRefreshToken(oldRefreshTokenString, rd.Scopes, app)
+		newRefreshTokenString, err := ar.issueNewRefreshToken(oldRefreshToken, rd.Scopes, app)
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorTokenUnableToCreateRefreshTokenError, err)
 			return
 		}
 
+		if err := ar.rotateSession(r, app.ID, oldRefreshToken, newRefreshTokenString); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
 		// Invalidate old refresh token - delete it from token storage and add to blacklist.
 		ar.invalidateOldRefreshToken(oldRefreshTokenString)
 
@@ -91,22 +107,23 @@ func (ar *Router) RefreshTokens() http.HandlerFunc {
 		resultScopes := strings.Split(accessToken.Scopes(), " ")
 
 		ar.server.Storages().User.UpdateLoginMetadata(
-			string(AuditOperationRefreshToken),
+			string(model.AuditOperationRefreshToken),
 			app.ID,
 			oldRefreshToken.Subject(),
 			resultScopes,
 			tokenPayload)
 
-		ar.audit(AuditOperationRefreshToken,
-			oldRefreshToken.Subject(), app.ID, r.UserAgent(), "", resultScopes,
+		ar.audit(model.AuditOperationRefreshToken, r,
+			oldRefreshToken.Subject(), app.ID, "", resultScopes,
 			result.AccessToken, result.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, result)
 	}
 }
 
+// issueNewRefreshToken issues the refresh token, which replaces the old one in its token family.
 func (ar *Router) issueNewRefreshToken(
-	oldRefreshTokenString string,
+	oldRefreshToken model.Token,
 	requestedScopes []string,
 	app model.AppData,
 ) (string, error) {
@@ -114,19 +131,14 @@ func (ar *Router) issueNewRefreshToken(
 		return "", nil
 	}
 
-	userID, err := ar.getTokenSubject(oldRefreshTokenString)
-	if err != nil {
-		return "", err
-	}
-
-	user, err := ar.server.Storages().User.UserByID(userID)
+	user, err := ar.server.Storages().User.UserByID(oldRefreshToken.Subject())
 	if err != nil {
 		return "", err
 	}
 
 	scopes := model.AllowedScopes(requestedScopes, user.Scopes, app.Offline)
 
-	refreshToken, err := ar.server.Services().Token.NewRefreshToken(user, scopes, app)
+	refreshToken, err := ar.server.Services().Token.RotateRefreshToken(oldRefreshToken, user, scopes, app)
 	if err != nil {
 		return "", err
 	}
@@ -145,10 +157,85 @@ func (ar *Router) invalidateOldRefreshToken(oldRefreshTokenString string) {
 			logging.FieldError, err)
 	}
 
-	if err := ar.server.Storages().Blocklist.Add(oldRefreshTokenString); err != nil {
+	if err := ar.blacklistToken(oldRefreshTokenString); err != nil {
 		ar.logger.Error("Cannot blacklist old refresh token",
 			logging.FieldError, err)
 	}
 
 	ar.logger.Info("Old refresh token successfully invalidated")
 }
+
+// revokeReusedRefreshToken revokes the token family, if the refresh token is presented again after the rotation.
+// The family is the session of the token, ending it makes the latest refresh token of the family unusable,
+// whoever holds it: the attacker or the user.
+func (ar *Router) revokeReusedRefreshToken(r *http.Request, app model.AppData, token model.Token) {
+	if token.Type() != model.TokenTypeRefresh || len(token.FamilyID()) == 0 {
+		return
+	}
+
+	storage := ar.server.Storages().UserSession
+	session, err := storage.SessionByID(token.FamilyID())
+	if err != nil || session.RefreshTokenID == token.ID() {
+		return
+	}
+
+	if err := storage.DeleteSession(session.ID); err != nil && !errors.Is(err, model.ErrorNotFound) {
+		ar.logger.Error("Unable to revoke refresh token family",
+			logging.FieldUserID, session.UserID,
+			logging.FieldError, err)
+		return
+	}
+
+	ar.logger.Warn("Rotated refresh token has been reused, token family revoked",
+		logging.FieldUserID, session.UserID,
+		logging.FieldAppID, app.ID,
+		"familyID", session.ID)
+
+	ar.auditFailure(model.AuditOperationRefreshTokenReuse, r,
+		session.UserID, app.ID, "rotated refresh token has been reused, token family revoked")
+
+	if ar.server.Settings().Login.NotifyTokenReuse {
+		if err := ar.sendTokenReuseEmail(app, session); err != nil {
+			ar.logger.Error("Unable to notify user about refresh token reuse",
+				logging.FieldUserID, session.UserID,
+				logging.FieldError, err)
+		}
+	}
+}
+
+// TokenReuseEmailData is the data of the email about the revoked token family.
+type TokenReuseEmailData struct {
+	AppName   string
+	UserAgent string
+	IP        string
+}
+
+func (ar *Router) sendTokenReuseEmail(app model.AppData, session model.UserSession) error {
+	user, err := ar.server.Storages().User.UserByID(session.UserID)
+	if err != nil {
+		return err
+	}
+	if len(user.Email) == 0 {
+		return nil
+	}
+
+	appName := app.Name
+	if len(appName) == 0 {
+		appName = app.ID
+	}
+
+	return ar.server.Services().Email.SendTemplateEmail(
+		model.EmailTemplateTypeTokenReuse,
+		app.GetCustomEmailTemplatePath(),
+		"Your session has been signed out",
+		user.Email,
+		model.EmailData{
+			User: user,
+			Data: TokenReuseEmailData{
+				AppName:   appName,
+				UserAgent: session.UserAgent,
+				IP:        session.IP,
+			},
+		},
+	)
+}
@@ -2,6 +2,7 @@ package api_test
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -70,3 +71,38 @@ func TestRefreshTokens(t *testing.T) {
 	assert.Equal(t, "test_app", c["aud"])
 	assert.Equal(t, "chat offline", c["scopes"])
 }
+
+func TestRefreshTokenReuse(t *testing.T) {
+	router := testLockoutRouter(t, model.LoginLockoutSettings{})
+	user := testOAuthUser(t, "rt_reuse", "+15550000010")
+
+	login := testSessionLogin(t, router, "rt_reuse")
+
+	rw := testRefresh(login.RefreshToken)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	rotated := testSessionTokens{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &rotated))
+
+	// the rotated token keeps the family
+	oldToken, err := testServer.Services().Token.Parse(login.RefreshToken)
+	require.NoError(t, err)
+	newToken, err := testServer.Services().Token.Parse(rotated.RefreshToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, oldToken.FamilyID())
+	assert.Equal(t, oldToken.FamilyID(), newToken.FamilyID())
+
+	// the replay of the rotated token revokes the family
+	rw = testRefresh(login.RefreshToken)
+	assert.Equal(t, http.StatusBadRequest, rw.Code, rw.Body.String())
+
+	rw = testRefresh(rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	records, _, err := testServer.Storages().Audit.FetchRecords(model.AuditFilter{
+		UserID:    user.ID,
+		Operation: model.AuditOperationRefreshTokenReuse,
+	})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, model.AuditResultFailure, records[0].Result)
+}
@@ -123,6 +123,7 @@ func (ar *Router) RegisterWithPassword() http.HandlerFunc {
 		}
 
 		userRole := app.NewUserDefaultRole
+		inviteAccepted := false
 
 		if rd.Invite != "" {
 			parsedInviteToken, err := ar.server.Services().Token.Parse(rd.Invite)
@@ -143,6 +144,9 @@ func (ar *Router) RegisterWithPassword() http.HandlerFunc {
 				return
 			}
 			userRole = role
+			// the invite has been sent to the email
+			um.EmailVerified = true
+			inviteAccepted = true
 		}
 
 		user, err := ar.server.Storages().User.AddUserWithPassword(um, rd.Password, userRole, rd.Anonymous)
@@ -154,6 +158,18 @@ func (ar *Router) RegisterWithPassword() http.HandlerFunc {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserCreateError, err)
 			return
 		}
+
+		ar.emitWebhook(model.WebhookEventUserRegistered, app, user.ID, map[string]interface{}{
+			"username": user.Username,
+			"email":    user.Email,
+			"phone":    user.Phone,
+		})
+		if inviteAccepted {
+			ar.emitWebhook(model.WebhookEventInviteAccepted, app, user.ID, map[string]interface{}{
+				"email": user.Email,
+				"role":  userRole,
+			})
+		}
 		// if err = ar.server.Services().Email.SendTemplateEmail(
 		// 	model.EmailTemplateTypeResetPassword,
 		// 	app.GetCustomEmailTemplatePath(),
@@ -172,17 +188,37 @@ func (ar *Router) RegisterWithPassword() http.HandlerFunc {
 		// 	)
 		// 	return
 
+		// The user can't login until the email is verified, send the verification link instead.
+		if app.RequireVerifiedEmail && !user.EmailVerified {
+			if len(user.Email) == 0 {
+				ar.Error(w, locale, http.StatusForbidden, l.ErrorAPILoginEmailNotVerified)
+				return
+			}
+			if err := ar.sendEmailVerification(app, user, ""); err != nil {
+				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorServiceEmailSendError, err)
+				return
+			}
+			authResult := AuthResponse{User: user.Sanitized(), RequireEmailVerification: true}
+			ar.ServeJSON(w, locale, http.StatusOK, authResult)
+			return
+		}
+
 		// Do login flow.
 		authResult, resultScopes, err := ar.loginFlow(
-			AuditOperationRegistration,
+			model.AuditOperationRegistration,
 			app, user, rd.Scopes, nil)
 		if err != nil {
 			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPILoginError, err)
 			return
 		}
 
-		ar.audit(AuditOperationRegistration,
-			user.ID, app.ID, r.UserAgent(), user.AccessRole, resultScopes.Scopes(),
+		if err := ar.startSession(r, app.ID, user.ID, authResult.RefreshToken); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.audit(model.AuditOperationRegistration, r,
+			user.ID, app.ID, user.AccessRole, resultScopes.Scopes(),
 			authResult.AccessToken, authResult.RefreshToken)
 
 		ar.ServeJSON(w, locale, http.StatusOK, authResult)
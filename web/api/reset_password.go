@@ -55,11 +55,13 @@ func (ar *Router) RequestResetPassword() http.HandlerFunc {
 			return
 		}
 
-		_, enabled2FA, _ := ar.check2FA(app.TFAStatus, ar.tfaType, user)
+		_, enabled2FA, _ := ar.check2FA(app, user)
+		factor, _ := ar.tfaFactor(app, user, "")
 
-		if enabled2FA && ar.tfaType != model.TFATypeEmail {
+		// the reset link is sent by email, so the email factor is passed with it
+		if enabled2FA && factor.Type != model.TFATypeEmail {
 			if d.TFACode != "" {
-				otpVerified, err := ar.verifyOTPCode(user, d.TFACode)
+				otpVerified, err := ar.verifyOTPCode(factor, d.TFACode)
 				if err != nil {
 					ar.Error(w, locale, http.StatusForbidden, l.Error2FAVerifyFailError, err)
 					return
@@ -72,7 +74,7 @@ func (ar *Router) RequestResetPassword() http.HandlerFunc {
 					return
 				}
 			} else {
-				if err := ar.sendOTPCode(app, user); err != nil {
+				if err := ar.sendOTPCode(app, user, factor); err != nil {
 					ar.Error(w, locale, http.StatusInternalServerError, l.ErrorServiceOtpSendError, err)
 					return
 				}
@@ -15,6 +15,7 @@ import (
 	"github.com/madappgang/identifo/v2/logging"
 	"github.com/madappgang/identifo/v2/model"
 	"github.com/madappgang/identifo/v2/web/authorization"
+	"github.com/madappgang/identifo/v2/webauthn"
 	"github.com/rs/cors"
 )
 
@@ -26,11 +27,14 @@ type Router struct {
 	router             *mux.Router
 	tfaType            model.TFAType
 	tfaResendTimeout   int
+	tfaSingleFactor    bool
 	oidcConfiguration  *OIDCConfiguration
-	jwk                *JWK
 	Authorizer         *authorization.Authorizer
 	Host               *url.URL
 	SupportedLoginWays model.LoginWith
+	webAuthn           *webauthn.WebAuthn
+	lockout            model.LoginLockoutSettings
+	trustProxyHeaders  bool
 
 	tokenPayloadServices     map[string]model.TokenPayloadProvider
 	tokenPayloadServicesLock sync.RWMutex
@@ -45,9 +49,16 @@ type RouterSettings struct {
 	Host             *url.URL
 	TFAType          model.TFAType
 	TFAResendTimeout int
-	LoginWith        model.LoginWith
-	Cors             *cors.Cors
-	Locale           string
+	// TFASingleFactor limits the users to one factor of the server-wide type without recovery codes,
+	// for the user storages which could not keep more, as the plugin storage.
+	TFASingleFactor bool
+	LoginWith       model.LoginWith
+	WebAuthn        model.WebAuthnSettings
+	Lockout         model.LoginLockoutSettings
+	// TrustProxyHeaders makes client IP address to be taken from the proxy headers.
+	TrustProxyHeaders bool
+	Cors              *cors.Cors
+	Locale            string
 }
 
 // NewRouter creates and inits new router.
@@ -64,12 +75,22 @@ func NewRouter(settings RouterSettings) (*Router, error) {
 		Host:                 settings.Host,
 		tfaType:              settings.TFAType,
 		tfaResendTimeout:     settings.TFAResendTimeout,
+		tfaSingleFactor:      settings.TFASingleFactor,
 		SupportedLoginWays:   settings.LoginWith,
+		lockout:              settings.Lockout,
+		trustProxyHeaders:    settings.TrustProxyHeaders,
 		cors:                 settings.Cors,
 		ls:                   l,
 		tokenPayloadServices: map[string]model.TokenPayloadProvider{},
 	}
 
+	if settings.LoginWith.WebAuthn || settings.TFAType == model.TFATypeWebAuthn {
+		ar.webAuthn, err = newRelyingParty(settings)
+		if err != nil {
+			return nil, fmt.Errorf("unable to init WebAuthn: %w", err)
+		}
+	}
+
 	ar.logger = logging.NewLogger(
 		settings.LoggerSettings.Format,
 		settings.LoggerSettings.API.Level).
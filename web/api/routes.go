@@ -54,6 +54,14 @@ func (ar *Router) initRoutes(
 	oidcMiddlewares := ar.buildOIDCMiddleware(baseMiddleware)
 	oidcCfg := ar.buildOIDCRoutes(oidcMiddlewares)
 	ar.router.PathPrefix("/.well-known").Handler(oidcCfg)
+
+	// oauth 2.0 authorization server
+	oauth := ar.buildOAuthRoutes(oidcMiddlewares)
+	ar.router.PathPrefix("/oauth").Handler(oauth)
+
+	// oidc userinfo, the app is taken from the access token
+	userInfo := with(oidcMiddlewares, negroni.WrapFunc(ar.UserInfo()))
+	ar.router.Path("/userinfo").Handler(userInfo).Methods(http.MethodGet, http.MethodPost)
 }
 
 func buildBaseMiddleware(
@@ -75,8 +83,16 @@ func buildBaseMiddleware(
 			"auth/token",
 			"auth/request_reset_password",
 			"auth/reset_password",
+			"auth/request_email_verification",
+			"auth/verify_email",
 			"me/logout",
 			"me/impersonate_as",
+			"oauth/authorize/complete",
+			"oauth/token",
+			"oauth/introspect",
+			"oauth/revoke",
+			"auth/webauthn/register/finish",
+			"auth/webauthn/login/finish",
 		}
 	}
 
@@ -141,6 +157,10 @@ func (ar *Router) buildAuthRoutes(middlewares *negroni.Negroni) http.Handler {
 	auth.Path("/reset_password").Handler(
 		ar.Token(model.TokenTypeReset, nil)(ar.ResetPassword()),
 	).Methods(http.MethodPost)
+	auth.Path("/request_email_verification").HandlerFunc(ar.RequestEmailVerification()).Methods(http.MethodPost)
+	auth.Path("/verify_email").Handler(
+		ar.Token(model.TokenTypeVerifyEmail, nil)(ar.VerifyEmail()),
+	).Methods(http.MethodPost)
 
 	auth.Path("/app_settings").HandlerFunc(ar.GetAppSettings()).Methods(http.MethodGet)
 
@@ -154,7 +174,7 @@ func (ar *Router) buildAuthRoutes(middlewares *negroni.Negroni) http.Handler {
 		ar.GetImpersonateToken()).Methods(http.MethodPost)
 
 	auth.Path("/tfa/enable").Handler(
-		ar.Token(model.TokenTypeAccess, nil)(ar.EnableTFA()),
+		ar.TokenOrTFAPreauth(model.TokenTypeAccess)(ar.EnableTFA()),
 	).Methods(http.MethodPut)
 	auth.Path("/tfa/disable").Handler(
 		ar.RequestDisabledTFA(),
@@ -166,9 +186,22 @@ func (ar *Router) buildAuthRoutes(middlewares *negroni.Negroni) http.Handler {
 		ar.Token(model.TokenTypeAccess, []string{model.TokenTypeTFAPreauth})(ar.ResendTFA()),
 	).Methods(http.MethodPost)
 	auth.Path("/tfa/reset").Handler(
-		ar.Token(model.TokenTypeAccess, nil)(ar.RequestTFAReset()),
+		ar.TokenOrTFAPreauth(model.TokenTypeAccess)(ar.RequestTFAReset()),
 	).Methods(http.MethodPut)
 
+	auth.Path("/tfa/webauthn").Handler(
+		ar.Token(model.TokenTypeAccess, []string{model.TokenTypeTFAPreauth})(ar.WebAuthnTFABegin()),
+	).Methods(http.MethodPost)
+
+	auth.Path("/webauthn/register/begin").Handler(
+		ar.Token(model.TokenTypeAccess, nil)(ar.WebAuthnRegisterBegin()),
+	).Methods(http.MethodPost)
+	auth.Path("/webauthn/register/finish").Handler(
+		ar.Token(model.TokenTypeAccess, nil)(ar.WebAuthnRegisterFinish()),
+	).Methods(http.MethodPost)
+	auth.Path("/webauthn/login/begin").HandlerFunc(ar.WebAuthnLoginBegin()).Methods(http.MethodPost)
+	auth.Path("/webauthn/login/finish").HandlerFunc(ar.WebAuthnLoginFinish()).Methods(http.MethodPost)
+
 	auth.Path("/federated").HandlerFunc(ar.FederatedLogin()).Methods(http.MethodPost)
 	auth.Path("/federated").HandlerFunc(ar.FederatedLogin()).Methods(http.MethodGet)
 
@@ -187,7 +220,14 @@ func (ar *Router) buildMeRoutes(middleware *negroni.Negroni) http.Handler {
 	me.Path("").HandlerFunc(ar.GetUser()).Methods(http.MethodGet)
 	me.Path("").HandlerFunc(ar.UpdateUser()).Methods(http.MethodPut)
 	me.Path("/logout").HandlerFunc(ar.Logout()).Methods(http.MethodPost)
+	me.Path("/logout_all").HandlerFunc(ar.LogoutAll()).Methods(http.MethodPost)
+	me.Path("/sessions").HandlerFunc(ar.GetSessions()).Methods(http.MethodGet)
+	me.Path("/sessions/{id}").HandlerFunc(ar.DeleteSession()).Methods(http.MethodDelete)
+	me.Path("/tfa/recovery_codes").HandlerFunc(ar.RegenerateTFARecoveryCodes()).Methods(http.MethodPost)
+	me.Path("/tfa/webauthn").HandlerFunc(ar.WebAuthnTFABegin()).Methods(http.MethodPost)
 	me.Path("/impersonate_as").HandlerFunc(ar.ImpersonateAs()).Methods(http.MethodPost)
+	me.Path("/webauthn/credentials").HandlerFunc(ar.GetWebAuthnCredentials()).Methods(http.MethodGet)
+	me.Path("/webauthn/credentials/{id}").HandlerFunc(ar.DeleteWebAuthnCredential()).Methods(http.MethodDelete)
 
 	return with(middleware,
 		ar.SignatureHandler(),
@@ -209,6 +249,20 @@ func (ar *Router) buildOIDCRoutes(middleware *negroni.Negroni) http.Handler {
 	return with(middleware, negroni.Wrap(oidc))
 }
 
+// buildOAuthRoutes creates OAuth 2.0 endpoints.
+// The client is identified by client_id param, so there is no app middleware here.
+func (ar *Router) buildOAuthRoutes(middleware *negroni.Negroni) http.Handler {
+	oauth := mux.NewRouter().PathPrefix("/oauth").Subrouter()
+
+	oauth.Path("/authorize").HandlerFunc(ar.OAuthAuthorize()).Methods(http.MethodGet)
+	oauth.Path("/authorize/complete").HandlerFunc(ar.OAuthAuthorizeComplete()).Methods(http.MethodGet)
+	oauth.Path("/token").HandlerFunc(ar.OAuthToken()).Methods(http.MethodPost)
+	oauth.Path("/introspect").HandlerFunc(ar.OAuthIntrospect()).Methods(http.MethodPost)
+	oauth.Path("/revoke").HandlerFunc(ar.OAuthRevoke()).Methods(http.MethodPost)
+
+	return with(middleware, negroni.Wrap(oauth))
+}
+
 func (ar *Router) buildOIDCMiddleware(base *negroni.Negroni) *negroni.Negroni {
 	wellKnownHandlers := []negroni.Handler{ar.ConfigCheck()}
 
@@ -19,7 +19,18 @@ const (
 )
 
 // Token middleware extracts token and validates it.
+// The 2FA pre-auth token is accepted only if the scopes require it.
 func (ar *Router) Token(tokenType string, scopes []string) mux.MiddlewareFunc {
+	return ar.token(tokenType, scopes, false)
+}
+
+// TokenOrTFAPreauth is the Token middleware accepting the 2FA pre-auth token as well,
+// for the requests the user makes before passing 2FA, like enrolling the first factor.
+func (ar *Router) TokenOrTFAPreauth(tokenType string) mux.MiddlewareFunc {
+	return ar.token(tokenType, nil, true)
+}
+
+func (ar *Router) token(tokenType string, scopes []string, allowTFAPreauth bool) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 			locale := r.Header.Get("Accept-Language")
@@ -53,17 +64,33 @@ func (ar *Router) Token(tokenType string, scopes []string) mux.MiddlewareFunc {
 				return
 			}
 
-			if blacklisted := ar.server.Storages().Blocklist.IsBlacklisted(tokenString); blacklisted {
+			if blacklisted := ar.isTokenBlacklisted(tokenString); blacklisted {
+				ar.revokeReusedRefreshToken(r, app, token)
 				ar.Error(rw, locale, http.StatusBadRequest, l.ErrorTokenBlocked)
 				return
 			}
 
+			ended, err := ar.sessionEnded(token)
+			if err != nil {
+				ar.Error(rw, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+				return
+			}
+			if ended {
+				ar.revokeReusedRefreshToken(r, app, token)
+				ar.Error(rw, locale, http.StatusUnauthorized, l.ErrorSessionRevoked)
+				return
+			}
+
+			ts := strings.Split(token.Scopes(), " ")
 			if len(scopes) > 0 {
-				ts := strings.Split(token.Scopes(), " ")
 				if len(model.SliceIntersect(ts, scopes)) == 0 {
 					ar.Error(rw, locale, http.StatusUnauthorized, l.ErrorAPPLoginNoScope)
 					return
 				}
+			} else if !allowTFAPreauth && isTFAPreauth(ts) {
+				// the pre-auth token is given after the password alone, it's not a login
+				ar.Error(rw, locale, http.StatusUnauthorized, l.ErrorAPIRequest2FARequired)
+				return
 			}
 
 			ctx := context.WithValue(r.Context(), model.TokenContextKey, token)
@@ -78,3 +105,24 @@ func (ar *Router) Token(tokenType string, scopes []string) mux.MiddlewareFunc {
 func tokenFromContext(ctx context.Context) model.Token {
 	return ctx.Value(model.TokenContextKey).(model.Token)
 }
+
+// blacklistToken blacklists the token by its ID until the token expires.
+func (ar *Router) blacklistToken(tokenString string) error {
+	return ar.server.Storages().Blocklist.Add(model.BlacklistEntry(tokenString))
+}
+
+// isTokenBlacklisted returns true if the token is blacklisted.
+func (ar *Router) isTokenBlacklisted(tokenString string) bool {
+	id, _ := model.BlacklistEntry(tokenString)
+	return ar.server.Storages().Blocklist.IsBlacklisted(id)
+}
+
+// isTFAPreauth returns true if the token scopes are of the token waiting for two-factor authentication.
+func isTFAPreauth(scopes []string) bool {
+	for _, s := range scopes {
+		if s == model.TokenTypeTFAPreauth {
+			return true
+		}
+	}
+	return false
+}
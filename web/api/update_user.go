@@ -7,6 +7,7 @@ import (
 
 	l "github.com/madappgang/identifo/v2/localization"
 	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/middleware"
 )
 
 // UpdateUser allows to change user login and password.
@@ -85,12 +86,17 @@ func (ar *Router) UpdateUser() http.HandlerFunc {
 			user = user.Deanonimized()
 		}
 
+		oldEmail := user.Email
+
+		// new email and phone have to be verified again
 		if d.updateEmail {
 			user.Email = d.NewEmail
+			user.EmailVerified = false
 		}
 
 		if d.updatePhone {
 			user.Phone = d.NewPhone
+			user.PhoneVerified = false
 		}
 
 		if d.updateUsername || d.updateEmail || d.updatePhone {
@@ -100,6 +106,13 @@ func (ar *Router) UpdateUser() http.HandlerFunc {
 			}
 		}
 
+		if d.updateEmail {
+			ar.emitWebhook(model.WebhookEventUserEmailChanged, middleware.AppFromContext(r.Context()), userID, map[string]interface{}{
+				"old_email": oldEmail,
+				"new_email": user.Email,
+			})
+		}
+
 		// Prepare response.
 		updatedFields := []string{}
 		if d.updateUsername {
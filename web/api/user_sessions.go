@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+	l "github.com/madappgang/identifo/v2/localization"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// refreshTokenClaims returns the claims of the refresh token issued by the server.
+// The signature is not verified, the token is expected to come from the token service or to be validated already.
+func refreshTokenClaims(refreshToken string) (*model.Claims, bool) {
+	claims := &model.Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(refreshToken, claims); err != nil {
+		return claims, false
+	}
+	return claims, len(claims.Id) > 0 && claims.ExpiresAt > 0
+}
+
+// startSession registers the new login session of the user, when the refresh token has been issued.
+// The login fails if the session can't be saved, as the refresh token without the session could not be used.
+func (ar *Router) startSession(r *http.Request, appID, userID, refreshToken string) error {
+	if len(refreshToken) == 0 {
+		return nil
+	}
+
+	claims, ok := refreshTokenClaims(refreshToken)
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	_, err := ar.server.Storages().UserSession.AddSession(model.UserSession{
+		ID:             claims.FamilyID,
+		UserID:         userID,
+		AppID:          appID,
+		UserAgent:      r.UserAgent(),
+		IP:             ar.clientIP(r),
+		CreatedAt:      now,
+		LastRefreshAt:  now,
+		RefreshTokenID: claims.Id,
+		ExpiresAt:      time.Unix(claims.ExpiresAt, 0),
+	})
+	return err
+}
+
+// rotateSession moves the session of the old refresh token to the new one.
+// The session ends if the new refresh token has not been requested.
+// The refresh token issued before the session registry has no session, it is started for the new token,
+// the error is returned then, as the new token could not be used without the session.
+func (ar *Router) rotateSession(r *http.Request, appID string, oldRefreshToken model.Token, newRefreshToken string) error {
+	if len(oldRefreshToken.ID()) == 0 {
+		return ar.startSession(r, appID, oldRefreshToken.Subject(), newRefreshToken)
+	}
+
+	storage := ar.server.Storages().UserSession
+	session, err := storage.SessionByRefreshTokenID(oldRefreshToken.ID())
+	if err != nil {
+		return nil
+	}
+
+	claims, ok := refreshTokenClaims(newRefreshToken)
+	if !ok {
+		err = storage.DeleteSession(session.ID)
+	} else {
+		session.RefreshTokenID = claims.Id
+		session.ExpiresAt = time.Unix(claims.ExpiresAt, 0)
+		session.LastRefreshAt = time.Now()
+		session.UserAgent = r.UserAgent()
+		session.IP = ar.clientIP(r)
+		err = storage.UpdateSession(session)
+	}
+	if err != nil && !errors.Is(err, model.ErrorNotFound) {
+		ar.logger.Error("Unable to rotate user session",
+			logging.FieldUserID, session.UserID,
+			logging.FieldError, err)
+	}
+	return nil
+}
+
+// endSession ends the session of the revoked refresh token.
+func (ar *Router) endSession(refreshToken string) {
+	claims, ok := refreshTokenClaims(refreshToken)
+	if !ok {
+		return
+	}
+
+	storage := ar.server.Storages().UserSession
+	session, err := storage.SessionByRefreshTokenID(claims.Id)
+	if err != nil {
+		return
+	}
+	if err := storage.DeleteSession(session.ID); err != nil && !errors.Is(err, model.ErrorNotFound) {
+		ar.logger.Error("Unable to end user session",
+			logging.FieldUserID, session.UserID,
+			logging.FieldError, err)
+	}
+}
+
+// sessionEnded checks if the session of the refresh token has been ended.
+// Refresh tokens issued before the session registry have no jti and are not tracked.
+// The storage error is returned, so the token is not treated as revoked when the storage is unavailable.
+func (ar *Router) sessionEnded(token model.Token) (bool, error) {
+	if token.Type() != model.TokenTypeRefresh || len(token.ID()) == 0 {
+		return false, nil
+	}
+	_, err := ar.server.Storages().UserSession.SessionByRefreshTokenID(token.ID())
+	if errors.Is(err, model.ErrorNotFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+// GetSessions returns the active sessions of the logged in user.
+func (ar *Router) GetSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		userID := tokenFromContext(r.Context()).UserID()
+		sessions, err := ar.server.Storages().UserSession.UserSessions(userID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.ServeJSON(w, locale, http.StatusOK, map[string]any{"sessions": sessions})
+	}
+}
+
+// DeleteSession ends the session of the logged in user, the refresh token of the session could not be used any more.
+func (ar *Router) DeleteSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		token := tokenFromContext(r.Context())
+		storage := ar.server.Storages().UserSession
+
+		session, err := storage.SessionByID(mux.Vars(r)["id"])
+		if err != nil || session.UserID != token.UserID() {
+			ar.Error(w, locale, http.StatusNotFound, l.ErrorSessionNotFound)
+			return
+		}
+
+		if err := storage.DeleteSession(session.ID); err != nil {
+			if errors.Is(err, model.ErrorNotFound) {
+				ar.Error(w, locale, http.StatusNotFound, l.ErrorSessionNotFound)
+				return
+			}
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.audit(model.AuditOperationRevokeSession, r,
+			token.UserID(), session.AppID, "", nil,
+			"", "")
+
+		ar.ServeJSON(w, locale, http.StatusOK, map[string]string{"result": "ok"})
+	}
+}
+
+// LogoutAll ends all sessions of the logged in user and deactivates the current access token.
+func (ar *Router) LogoutAll() http.HandlerFunc {
+	type responseData struct {
+		Result   string `json:"result"`
+		Sessions int    `json:"sessions"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		locale := r.Header.Get("Accept-Language")
+
+		accessToken := tokenFromContext(ctx)
+
+		deleted, err := ar.server.Storages().UserSession.DeleteUserSessions(accessToken.UserID())
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		accessTokenString := ""
+		if accessTokenBytes, ok := ctx.Value(model.TokenRawContextKey).([]byte); ok {
+			accessTokenString = string(accessTokenBytes)
+			if err := ar.blacklistToken(accessTokenString); err != nil {
+				ar.logger.Error("Cannot blacklist access token",
+					logging.FieldError, err)
+			}
+		}
+
+		ar.audit(model.AuditOperationLogoutAll, r,
+			accessToken.UserID(), accessToken.Audience(), "", nil,
+			accessTokenString, "")
+
+		ar.ServeJSON(w, locale, http.StatusOK, responseData{Result: "ok", Sessions: deleted})
+	}
+}
@@ -0,0 +1,188 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/api"
+	"github.com/rs/cors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSessionTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func testSessionLogin(t *testing.T, router *api.Router, username string) testSessionTokens {
+	data, err := json.Marshal(map[string]any{
+		"username": username,
+		"password": "qwerty",
+		"scopes":   []string{model.OfflineScope},
+	})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(string(data)))
+	r = r.WithContext(testContext(testApp))
+	r.Header.Set("User-Agent", "session-test")
+
+	rw := httptest.NewRecorder()
+	router.LoginWithPassword()(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	tokens := testSessionTokens{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &tokens))
+	require.NotEmpty(t, tokens.RefreshToken)
+	return tokens
+}
+
+func testSessionRequest(method, path, token, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r = r.WithContext(testContext(testApp))
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func testRefresh(refreshToken string) *httptest.ResponseRecorder {
+	r := testSessionRequest(http.MethodPost, "/auth/token", refreshToken, `{"scopes":["offline"]}`)
+	rw := httptest.NewRecorder()
+	testRouter.Token(model.TokenTypeRefresh, nil)(testRouter.RefreshTokens()).ServeHTTP(rw, r)
+	return rw
+}
+
+func Test_Router_UserSessions(t *testing.T) {
+	router := testLockoutRouter(t, model.LoginLockoutSettings{})
+	testOAuthUser(t, "session_user", "+15550000009")
+
+	first := testSessionLogin(t, router, "session_user")
+	second := testSessionLogin(t, router, "session_user")
+
+	// both logins are listed
+	rw := httptest.NewRecorder()
+	testRouter.Token(model.TokenTypeAccess, nil)(testRouter.GetSessions()).
+		ServeHTTP(rw, testSessionRequest(http.MethodGet, "/me/sessions", first.AccessToken, ""))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var list struct {
+		Sessions []model.UserSession `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &list))
+	require.Len(t, list.Sessions, 2)
+	assert.Equal(t, "session-test", list.Sessions[0].UserAgent)
+
+	// refresh rotates the session to the new refresh token
+	rw = testRefresh(first.RefreshToken)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	rotated := testSessionTokens{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &rotated))
+	require.NotEmpty(t, rotated.RefreshToken)
+
+	sessions, err := testServer.Storages().UserSession.UserSessions(list.Sessions[0].UserID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	// logout everywhere ends both sessions
+	rw = httptest.NewRecorder()
+	testRouter.Token(model.TokenTypeAccess, nil)(testRouter.LogoutAll()).
+		ServeHTTP(rw, testSessionRequest(http.MethodPost, "/me/logout_all", rotated.AccessToken, ""))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.JSONEq(t, `{"result":"ok","sessions":2}`, rw.Body.String())
+
+	rw = testRefresh(rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+	rw = testRefresh(second.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+}
+
+func Test_Router_UserSessions_LegacyToken(t *testing.T) {
+	user := testOAuthUser(t, "session_legacy_user", "+15550000032")
+
+	// the refresh token issued before the session registry has no jti and family
+	token, err := testServer.Services().Token.NewRefreshToken(user, model.AllowedScopes([]string{model.OfflineScope}, nil, true), testApp)
+	require.NoError(t, err)
+	jwToken := token.(*model.JWToken)
+	claims := jwToken.Claims()
+	claims.Id, claims.FamilyID = "", ""
+	jwToken.JWT.Raw = ""
+	legacy, err := testServer.Services().Token.String(token)
+	require.NoError(t, err)
+
+	rw := testRefresh(legacy)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	rotated := testSessionTokens{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &rotated))
+
+	// the session is started for the new token, so it is refreshed again
+	sessions, err := testServer.Storages().UserSession.UserSessions(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	rw = testRefresh(rotated.RefreshToken)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+}
+
+// failingSessionStorage is the user session storage, which is unavailable.
+type failingSessionStorage struct {
+	model.UserSessionStorage
+}
+
+var errSessionStorageDown = errors.New("session storage is down")
+
+func (failingSessionStorage) AddSession(model.UserSession) (model.UserSession, error) {
+	return model.UserSession{}, errSessionStorageDown
+}
+
+func (failingSessionStorage) SessionByRefreshTokenID(string) (model.UserSession, error) {
+	return model.UserSession{}, errSessionStorageDown
+}
+
+// failingSessionServer is the test server with the unavailable user session storage.
+type failingSessionServer struct {
+	model.Server
+}
+
+func (s failingSessionServer) Storages() model.ServerStorageCollection {
+	storages := s.Server.Storages()
+	storages.UserSession = failingSessionStorage{storages.UserSession}
+	return storages
+}
+
+func Test_Router_UserSessions_StorageError(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith: model.LoginWith{Username: true},
+		Server:    failingSessionServer{testServer},
+		Cors:      cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+	testOAuthUser(t, "session_storage_user", "+15550000028")
+
+	// the login fails if the session could not be saved
+	data, err := json.Marshal(map[string]any{
+		"username": "session_storage_user",
+		"password": "qwerty",
+		"scopes":   []string{model.OfflineScope},
+	})
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(string(data)))
+	r = r.WithContext(testContext(testApp))
+	rw := httptest.NewRecorder()
+	router.LoginWithPassword()(rw, r)
+	require.Equal(t, http.StatusInternalServerError, rw.Code, rw.Body.String())
+
+	// the session is not treated as ended when the storage is unavailable
+	tokens := testSessionLogin(t, testLockoutRouter(t, model.LoginLockoutSettings{}), "session_storage_user")
+	r = testSessionRequest(http.MethodPost, "/auth/token", tokens.RefreshToken, `{"scopes":["offline"]}`)
+	rw = httptest.NewRecorder()
+	router.Token(model.TokenTypeRefresh, nil)(router.RefreshTokens()).ServeHTTP(rw, r)
+	require.Equal(t, http.StatusInternalServerError, rw.Code, rw.Body.String())
+
+	// the refresh token is not revoked
+	rw = testRefresh(tokens.RefreshToken)
+	assert.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+}
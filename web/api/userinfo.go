@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/madappgang/identifo/v2/jwt"
+	jwtValidator "github.com/madappgang/identifo/v2/jwt/validator"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// UserInfoResponse is an OpenID Connect userinfo response.
+type UserInfoResponse struct {
+	Subject string `json:"sub"`
+	model.UserInfoClaims
+}
+
+// UserInfo is an OpenID Connect userinfo endpoint.
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
+// The access token must be granted with openid scope, returned claims depend on other granted scopes.
+func (ar *Router) UserInfo() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := ar.bearerAccessToken(r)
+		if err != nil {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", err.Error())
+			return
+		}
+
+		scopes := strings.Fields(token.Scopes())
+		if !model.SliceContains(scopes, model.OIDCScopeOpenID) {
+			ar.bearerError(w, http.StatusForbidden, "insufficient_scope", "openid scope is required")
+			return
+		}
+
+		user, err := ar.server.Storages().User.UserByID(token.UserID())
+		if err != nil || !user.Active {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", "user is not found or inactive")
+			return
+		}
+
+		resp := UserInfoResponse{
+			Subject:        user.ID,
+			UserInfoClaims: model.NewUserInfoClaims(user, scopes),
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		ar.ServeJSON(w, "", http.StatusOK, resp)
+	}
+}
+
+// bearerAccessToken extracts and validates access token from Authorization header.
+// The token audience is used as the app, so the request does not need app id.
+func (ar *Router) bearerAccessToken(r *http.Request) (model.Token, error) {
+	tokenBytes := jwt.ExtractTokenFromBearerHeader(r.Header.Get(TokenHeaderKey))
+	if tokenBytes == nil {
+		return nil, errors.New("missing bearer token")
+	}
+	tokenString := string(tokenBytes)
+
+	token, err := ar.server.Services().Token.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := ar.server.Storages().App.ActiveAppByID(token.Audience())
+	if err != nil {
+		return nil, err
+	}
+
+	v := jwtValidator.NewValidator(
+		[]string{app.ID},
+		[]string{ar.server.Services().Token.Issuer()},
+		[]string{},
+		[]string{model.TokenTypeAccess},
+	)
+	if err := v.Validate(token); err != nil {
+		return nil, err
+	}
+
+	if ar.isTokenBlacklisted(tokenString) {
+		return nil, model.ErrTokenInvalid
+	}
+
+	return token, nil
+}
+
+// bearerError writes error response as described in RFC 6750.
+func (ar *Router) bearerError(w http.ResponseWriter, status int, code, description string) {
+	ar.logger.Warn("bearer token error",
+		"error", code,
+		"description", description,
+		"status", status)
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=%q, error_description=%q", code, description))
+	ar.ServeJSON(w, "", status, oauthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
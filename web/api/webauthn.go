@@ -0,0 +1,472 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	l "github.com/madappgang/identifo/v2/localization"
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/authorization"
+	"github.com/madappgang/identifo/v2/web/middleware"
+	"github.com/madappgang/identifo/v2/webauthn"
+)
+
+// SessionNameWebAuthn is the key used to keep the ids of WebAuthn ceremonies in the session store.
+// The ceremonies themselves are kept in the WebAuthn storage.
+const SessionNameWebAuthn = "_webauthn_session"
+
+// WebAuthn ceremonies, each one has its own session value, so they do not interfere.
+const (
+	webAuthnCeremonyRegister = "register"
+	webAuthnCeremonyLogin    = "login"
+	webAuthnCeremonyTFA      = "tfa"
+)
+
+var errWebAuthnCredentialOwner = errors.New("credential does not belong to the user")
+
+func webAuthnSessionKey(appID, ceremony string) string {
+	return appID + ":" + ceremony
+}
+
+// newRelyingParty creates WebAuthn relying party from the router settings.
+func newRelyingParty(settings RouterSettings) (*webauthn.WebAuthn, error) {
+	host := ""
+	if settings.Host != nil {
+		host = settings.Host.String()
+	}
+
+	config, err := webauthn.RPConfig(host, settings.Server.Settings().General.Issuer, settings.WebAuthn)
+	if err != nil {
+		return nil, err
+	}
+	return webauthn.New(config)
+}
+
+// WebAuthnRegisterBegin returns options to create new WebAuthn credential for the logged in user.
+func (ar *Router) WebAuthnRegisterBegin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		if ar.webAuthn == nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnDisabled)
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if len(app.ID) == 0 {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIAPPNoAPPInContext)
+			return
+		}
+
+		userID := tokenFromContext(r.Context()).UserID()
+		user, err := ar.server.Storages().User.UserByID(userID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIUserNotFoundError, err)
+			return
+		}
+
+		credentials, err := ar.server.Storages().WebAuthn.CredentialsByUserID(user.ID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageWebauthnError, err)
+			return
+		}
+
+		options, session, err := ar.webAuthn.BeginRegistration(user, credentials)
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorWebauthnRegistrationError, err)
+			return
+		}
+
+		if err := ar.saveWebAuthnSession(w, r, app.ID, webAuthnCeremonyRegister, session); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorWebauthnSessionError, err)
+			return
+		}
+
+		ar.ServeJSON(w, locale, http.StatusOK, options)
+	}
+}
+
+// WebAuthnRegisterFinish verifies and saves new WebAuthn credential of the logged in user.
+func (ar *Router) WebAuthnRegisterFinish() http.HandlerFunc {
+	type requestBody struct {
+		Name       string                        `json:"name"`
+		Credential webauthn.RegistrationResponse `json:"credential"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		if ar.webAuthn == nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnDisabled)
+			return
+		}
+
+		d := requestBody{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if len(app.ID) == 0 {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIAPPNoAPPInContext)
+			return
+		}
+
+		session, err := ar.takeWebAuthnSession(w, r, app.ID, webAuthnCeremonyRegister)
+		if err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnSessionError, err)
+			return
+		}
+
+		if string(session.UserID) != tokenFromContext(r.Context()).UserID() {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnSessionError, errWebAuthnCredentialOwner)
+			return
+		}
+
+		credential, err := ar.webAuthn.FinishRegistration(session, d.Credential)
+		if err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnRegistrationError, err)
+			return
+		}
+		credential.Name = d.Name
+
+		if err := ar.server.Storages().WebAuthn.AddCredential(credential); err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorStorageWebauthnError, err)
+			return
+		}
+
+		ar.ServeJSON(w, locale, http.StatusOK, credential.Sanitized())
+	}
+}
+
+// WebAuthnLoginBegin returns options to get an assertion for passkey login.
+// If the login is specified, only the credentials of the user are allowed,
+// otherwise the user is chosen on the device from the discoverable credentials.
+func (ar *Router) WebAuthnLoginBegin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		if ar.webAuthn == nil || !ar.SupportedLoginWays.WebAuthn {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnDisabled)
+			return
+		}
+
+		d := login{}
+		if r.ContentLength != 0 && ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		if err := ar.checkSupportedWays(d); err != nil {
+			ar.Error(w, locale, http.StatusBadRequest, l.APIAPPUsernameLoginNotSupported)
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if len(app.ID) == 0 {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIAPPNoAPPInContext)
+			return
+		}
+
+		var (
+			user        model.User
+			credentials []model.WebAuthnCredential
+			err         error
+		)
+
+		if len(d.Email) > 0 {
+			user, err = ar.server.Storages().User.UserByEmail(d.Email)
+		} else if len(d.Phone) > 0 {
+			user, err = ar.server.Storages().User.UserByPhone(d.Phone)
+		} else if len(d.Username) > 0 {
+			user, err = ar.server.Storages().User.UserByUsername(d.Username)
+		}
+		if err != nil {
+			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIRequestIncorrectLoginOrPassword)
+			return
+		}
+
+		if len(user.ID) > 0 {
+			credentials, err = ar.server.Storages().WebAuthn.CredentialsByUserID(user.ID)
+			if err != nil {
+				ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageWebauthnError, err)
+				return
+			}
+			if len(credentials) == 0 {
+				// return this error to hide the existence of the user.
+				ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIRequestIncorrectLoginOrPassword)
+				return
+			}
+		}
+
+		ar.beginWebAuthnLogin(w, r, app, webAuthnCeremonyLogin, user.ID, credentials)
+	}
+}
+
+// WebAuthnLoginFinish verifies the assertion and logs the credential owner in.
+func (ar *Router) WebAuthnLoginFinish() http.HandlerFunc {
+	type requestBody struct {
+		Credential webauthn.AssertionResponse `json:"credential"`
+		Scopes     []string                   `json:"scopes,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		if ar.webAuthn == nil || !ar.SupportedLoginWays.WebAuthn {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnDisabled)
+			return
+		}
+
+		d := requestBody{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if len(app.ID) == 0 {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIAPPNoAPPInContext)
+			return
+		}
+
+		credential, err := ar.finishWebAuthnLogin(w, r, app, webAuthnCeremonyLogin, d.Credential)
+		if err != nil {
+			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorWebauthnLoginError, err)
+			return
+		}
+
+		user, err := ar.server.Storages().User.UserByID(credential.UserID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusUnauthorized, l.ErrorAPIRequestIncorrectLoginOrPassword)
+			return
+		}
+
+		// Authorize user if the app requires authorization.
+		azi := authorization.AuthzInfo{
+			App:         app,
+			UserRole:    user.AccessRole,
+			ResourceURI: r.RequestURI,
+			Method:      r.Method,
+		}
+		if err := ar.Authorizer.Authorize(azi); err != nil {
+			ar.Error(w, locale, http.StatusForbidden, l.APIAccessDenied)
+			return
+		}
+
+		if ar.emailNotVerified(w, locale, app, user) {
+			return
+		}
+
+		authResult, resultScopes, err := ar.loginFlow(model.AuditOperationLoginWithWebAuthn, app, user, d.Scopes, nil)
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorAPILoginError, err)
+			return
+		}
+
+		if err := ar.startSession(r, app.ID, user.ID, authResult.RefreshToken); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageUserSessionError, err)
+			return
+		}
+
+		ar.audit(model.AuditOperationLoginWithWebAuthn, r,
+			user.ID, app.ID, user.AccessRole, resultScopes.Scopes(),
+			authResult.AccessToken, authResult.RefreshToken)
+
+		ar.ServeJSON(w, locale, http.StatusOK, authResult)
+	}
+}
+
+// WebAuthnTFABegin returns options to get an assertion for WebAuthn two-factor authentication.
+// The assertion is sent to /auth/tfa/login to finalize the login.
+func (ar *Router) WebAuthnTFABegin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		app := middleware.AppFromContext(r.Context())
+		if len(app.ID) == 0 {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorAPIAPPNoAPPInContext)
+			return
+		}
+
+		if !ar.acceptsTFAType(app, model.TFATypeWebAuthn) {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnDisabled)
+			return
+		}
+
+		userID := tokenFromContext(r.Context()).UserID()
+		credentials, err := ar.server.Storages().WebAuthn.CredentialsByUserID(userID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageWebauthnError, err)
+			return
+		}
+		if len(credentials) == 0 {
+			ar.Error(w, locale, http.StatusBadRequest, l.ErrorWebauthnNoCredentials)
+			return
+		}
+
+		ar.beginWebAuthnLogin(w, r, app, webAuthnCeremonyTFA, userID, credentials)
+	}
+}
+
+// GetWebAuthnCredentials returns WebAuthn credentials of the logged in user.
+func (ar *Router) GetWebAuthnCredentials() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		userID := tokenFromContext(r.Context()).UserID()
+		credentials, err := ar.server.Storages().WebAuthn.CredentialsByUserID(userID)
+		if err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageWebauthnError, err)
+			return
+		}
+
+		for i := range credentials {
+			credentials[i] = credentials[i].Sanitized()
+		}
+		ar.ServeJSON(w, locale, http.StatusOK, map[string]any{"credentials": credentials})
+	}
+}
+
+// DeleteWebAuthnCredential deletes WebAuthn credential of the logged in user.
+func (ar *Router) DeleteWebAuthnCredential() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.Header.Get("Accept-Language")
+
+		userID := tokenFromContext(r.Context()).UserID()
+		credential, err := ar.server.Storages().WebAuthn.CredentialByID(mux.Vars(r)["id"])
+		if err != nil || credential.UserID != userID {
+			ar.Error(w, locale, http.StatusNotFound, l.ErrorWebauthnCredentialNotFound)
+			return
+		}
+
+		if err := ar.server.Storages().WebAuthn.DeleteCredential(credential.ID); err != nil {
+			ar.Error(w, locale, http.StatusInternalServerError, l.ErrorStorageWebauthnError, err)
+			return
+		}
+
+		ar.ServeJSON(w, locale, http.StatusOK, map[string]string{"result": "ok"})
+	}
+}
+
+func (ar *Router) beginWebAuthnLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	app model.AppData,
+	ceremony string,
+	userID string,
+	credentials []model.WebAuthnCredential,
+) {
+	locale := r.Header.Get("Accept-Language")
+
+	options, session, err := ar.webAuthn.BeginLogin(userID, credentials)
+	if err != nil {
+		ar.Error(w, locale, http.StatusInternalServerError, l.ErrorWebauthnLoginError, err)
+		return
+	}
+
+	if err := ar.saveWebAuthnSession(w, r, app.ID, ceremony, session); err != nil {
+		ar.Error(w, locale, http.StatusInternalServerError, l.ErrorWebauthnSessionError, err)
+		return
+	}
+
+	ar.ServeJSON(w, locale, http.StatusOK, options)
+}
+
+// finishWebAuthnLogin verifies the assertion against the ceremony session,
+// and saves the updated signature counter of the credential.
+// The credential must belong to the session user, or to the user of the discoverable credential.
+func (ar *Router) finishWebAuthnLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	app model.AppData,
+	ceremony string,
+	resp webauthn.AssertionResponse,
+) (model.WebAuthnCredential, error) {
+	session, err := ar.takeWebAuthnSession(w, r, app.ID, ceremony)
+	if err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+
+	credential, err := ar.webAuthn.FinishLogin(session, resp, ar.server.Storages().WebAuthn.CredentialsByUserID)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCount) {
+			ar.logger.Warn("WebAuthn signature counter has not increased, the authenticator may be cloned",
+				logging.FieldUserID, credential.UserID,
+				"credential_id", credential.ID)
+		}
+		return model.WebAuthnCredential{}, err
+	}
+
+	if err := ar.server.Storages().WebAuthn.UpdateCredential(credential); err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+	return credential, nil
+}
+
+// saveWebAuthnSession saves the ceremony session to the storage, the client session keeps its random id only.
+func (ar *Router) saveWebAuthnSession(w http.ResponseWriter, r *http.Request, appID, ceremony string, session webauthn.Session) error {
+	id := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	stored := model.WebAuthnSession{
+		ID:        base64.RawURLEncoding.EncodeToString(id),
+		Data:      string(data),
+		ExpiresAt: session.Expires,
+	}
+	if err := ar.server.Storages().WebAuthn.SaveSession(stored); err != nil {
+		return err
+	}
+	return storeInSession(SessionNameWebAuthn, webAuthnSessionKey(appID, ceremony), stored.ID, r, w)
+}
+
+// takeWebAuthnSession takes the ceremony session from the storage, so its challenge is used once,
+// whether the ceremony succeeds or not.
+func (ar *Router) takeWebAuthnSession(w http.ResponseWriter, r *http.Request, appID, ceremony string) (webauthn.Session, error) {
+	id, err := ar.getFromSession(SessionNameWebAuthn, webAuthnSessionKey(appID, ceremony), r)
+	if err != nil {
+		return webauthn.Session{}, err
+	}
+	ar.clearWebAuthnSession(w, r, appID, ceremony)
+
+	stored, err := ar.server.Storages().WebAuthn.TakeSession(id)
+	if err != nil {
+		return webauthn.Session{}, err
+	}
+
+	session := webauthn.Session{}
+	err = json.Unmarshal([]byte(stored.Data), &session)
+	return session, err
+}
+
+// clearWebAuthnSession removes the ceremony id from the client session.
+func (ar *Router) clearWebAuthnSession(w http.ResponseWriter, r *http.Request, appID, ceremony string) {
+	session, err := Store.Get(r, SessionNameWebAuthn)
+	if err != nil {
+		return
+	}
+	delete(session.Values, webAuthnSessionKey(appID, ceremony))
+	if err := session.Save(r, w); err != nil {
+		ar.logger.Warn("Unable to clear WebAuthn session", logging.FieldError, err)
+	}
+}
+
+// hasWebAuthnCredentials returns true if the user has registered at least one WebAuthn credential.
+func (ar *Router) hasWebAuthnCredentials(userID string) bool {
+	if ar.server.Storages().WebAuthn == nil {
+		return false
+	}
+	credentials, err := ar.server.Storages().WebAuthn.CredentialsByUserID(userID)
+	return err == nil && len(credentials) > 0
+}
@@ -0,0 +1,181 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/web/api"
+	"github.com/madappgang/identifo/v2/webauthn"
+	"github.com/madappgang/identifo/v2/webauthn/webauthntest"
+	"github.com/rs/cors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWebAuthnOrigin = "https://localhost"
+
+func testWebAuthnRouter(t *testing.T) *api.Router {
+	router, err := api.NewRouter(api.RouterSettings{
+		LoginWith: model.LoginWith{Username: true, WebAuthn: true},
+		TFAType:   model.TFATypeWebAuthn,
+		WebAuthn: model.WebAuthnSettings{
+			RPID:    "localhost",
+			Origins: []string{testWebAuthnOrigin},
+		},
+		Server: testServer,
+		Cors:   cors.New(model.DefaultCors),
+	})
+	require.NoError(t, err)
+	return router
+}
+
+// testWebAuthnRequest calls the handler with the token and the session cookie, if they are set.
+func testWebAuthnRequest(t *testing.T, h http.HandlerFunc, token, cookie string, body any) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	ctx := testContext(testApp)
+	if len(token) > 0 {
+		parsed, err := testServer.Services().Token.Parse(token)
+		require.NoError(t, err)
+		ctx = context.WithValue(ctx, model.TokenContextKey, parsed)
+		ctx = context.WithValue(ctx, model.TokenRawContextKey, []byte(token))
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/webauthn", strings.NewReader(string(data)))
+	r = r.WithContext(ctx)
+	if len(cookie) > 0 {
+		r.Header.Set("Cookie", cookie)
+	}
+
+	rw := httptest.NewRecorder()
+	h(rw, r)
+	return rw
+}
+
+func Test_Router_WebAuthn(t *testing.T) {
+	router := testWebAuthnRouter(t)
+	authenticator := webauthntest.New("localhost", testWebAuthnOrigin)
+	user := testOAuthUser(t, "webauthn_user", "+15550000004")
+
+	at, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testApp, false, nil)
+	require.NoError(t, err)
+	ats, err := testServer.Services().Token.String(at)
+	require.NoError(t, err)
+
+	// register passkey
+	rw := testWebAuthnRequest(t, router.WebAuthnRegisterBegin(), ats, "", nil)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	creationOptions := webauthn.CredentialCreationOptions{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &creationOptions))
+	assert.Equal(t, "localhost", creationOptions.Response.RelyingParty.ID)
+
+	registration, err := authenticator.Create(creationOptions)
+	require.NoError(t, err)
+
+	rw = testWebAuthnRequest(t, router.WebAuthnRegisterFinish(), ats, rw.Header().Get("Set-Cookie"), map[string]any{
+		"name":       "laptop",
+		"credential": registration,
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	credentials, err := testServer.Storages().WebAuthn.CredentialsByUserID(user.ID)
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+	assert.Equal(t, "laptop", credentials[0].Name)
+
+	// login with passkey
+	rw = testWebAuthnRequest(t, router.WebAuthnLoginBegin(), "", "", map[string]string{"username": user.Username})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	cookie := rw.Header().Get("Set-Cookie")
+
+	requestOptions := webauthn.CredentialRequestOptions{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &requestOptions))
+	require.Len(t, requestOptions.Response.AllowedCredentials, 1)
+
+	assertion, err := authenticator.Get(requestOptions)
+	require.NoError(t, err)
+
+	rw = testWebAuthnRequest(t, router.WebAuthnLoginFinish(), "", cookie, map[string]any{"credential": assertion})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	c := claimsFromResponse(t, rw.Body.Bytes())
+	assert.Equal(t, user.ID, c["sub"])
+
+	// the assertion can not be replayed
+	rw = testWebAuthnRequest(t, router.WebAuthnLoginFinish(), "", cookie, map[string]any{"credential": assertion})
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	// enable WebAuthn as the second factor
+	rw = testWebAuthnRequest(t, router.EnableTFA(), ats, "", map[string]string{})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	user, err = testServer.Storages().User.UserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, user.TFAInfo.IsEnabled)
+
+	// the passkey login requires the second factor now, the discoverable credential is used
+	rw = testWebAuthnRequest(t, router.WebAuthnLoginBegin(), "", "", nil)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	requestOptions = webauthn.CredentialRequestOptions{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &requestOptions))
+	assert.Empty(t, requestOptions.Response.AllowedCredentials)
+
+	assertion, err = authenticator.Get(requestOptions)
+	require.NoError(t, err)
+	rw = testWebAuthnRequest(t, router.WebAuthnLoginFinish(), "", rw.Header().Get("Set-Cookie"), map[string]any{"credential": assertion})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	authResponse := api.AuthResponse{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &authResponse))
+	require.True(t, authResponse.Require2FA)
+	require.True(t, authResponse.Enabled2FA)
+	preauthToken := authResponse.AccessToken
+
+	rw = testWebAuthnRequest(t, router.WebAuthnTFABegin(), preauthToken, "", nil)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	cookie = rw.Header().Get("Set-Cookie")
+	requestOptions = webauthn.CredentialRequestOptions{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &requestOptions))
+
+	// one-time codes are not accepted for WebAuthn second factor
+	rw = testWebAuthnRequest(t, router.FinalizeTFA(), preauthToken, cookie, map[string]string{"tfa_code": "123456"})
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	assertion, err = authenticator.Get(requestOptions)
+	require.NoError(t, err)
+	rw = testWebAuthnRequest(t, router.FinalizeTFA(), preauthToken, cookie, map[string]any{"webauthn": assertion})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	authResponse = api.AuthResponse{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &authResponse))
+	assert.NotEmpty(t, authResponse.AccessToken)
+	assert.False(t, authResponse.Require2FA)
+}
+
+func Test_Router_WebAuthnRegisterPreauth(t *testing.T) {
+	router := testWebAuthnRouter(t)
+	user := testOAuthUser(t, "webauthn_preauth_user", "+15550000027")
+
+	preauth, err := testServer.Services().Token.NewAccessToken(user, model.AllowedScopes(nil, nil, false), testApp, true, nil)
+	require.NoError(t, err)
+	preauthToken, err := testServer.Services().Token.String(preauth)
+	require.NoError(t, err)
+
+	// the passkey is not enrolled with the token given after the password alone
+	r := httptest.NewRequest(http.MethodPost, "/auth/webauthn/register/begin", nil)
+	r = r.WithContext(testContext(testApp))
+	r.Header.Set("Authorization", "Bearer "+preauthToken)
+	rw := httptest.NewRecorder()
+	router.Token(model.TokenTypeAccess, nil)(router.WebAuthnRegisterBegin()).ServeHTTP(rw, r)
+	require.Equal(t, http.StatusUnauthorized, rw.Code, rw.Body.String())
+
+	credentials, err := testServer.Storages().WebAuthn.CredentialsByUserID(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, credentials)
+}
@@ -0,0 +1,16 @@
+package api
+
+import "github.com/madappgang/identifo/v2/model"
+
+// emitWebhook queues the event for the server-wide and app webhook subscriptions.
+func (ar *Router) emitWebhook(event model.WebhookEvent, app model.AppData, userID string, data map[string]interface{}) {
+	if ws := ar.server.Services().Webhook; ws != nil {
+		ws.Emit(event, app, userID, data)
+	}
+}
+
+func (ar *Router) emitTFAEnabled(app model.AppData, userID string, tfaType model.TFAType) {
+	ar.emitWebhook(model.WebhookEventUserTFAEnabled, app, userID, map[string]interface{}{
+		"tfa_type": string(tfaType),
+	})
+}
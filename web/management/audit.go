@@ -0,0 +1,50 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/madappgang/identifo/v2/logging"
+	"github.com/madappgang/identifo/v2/model"
+	imiddleware "github.com/madappgang/identifo/v2/web/middleware"
+	"github.com/urfave/negroni"
+)
+
+// audited records the management API operation to the audit log after the handler has responded.
+// The management key ID is recorded as the actor.
+func (ar *Router) audited(op model.AuditOperation, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := negroni.NewResponseWriter(w)
+		h(rw, r)
+
+		record := model.AuditRecord{
+			Source:    model.AuditSourceManagement,
+			Operation: op,
+			Result:    model.AuditResultSuccess,
+			Status:    rw.Status(),
+			Resource:  r.URL.Path,
+			Actor:     r.Header.Get(KeyIDHeaderKey),
+			IP:        imiddleware.ClientIP(r, ar.server.Settings().General.TrustProxyHeaders),
+			UserAgent: r.UserAgent(),
+		}
+		if rw.Status() >= http.StatusBadRequest {
+			record.Result = model.AuditResultFailure
+		}
+
+		ar.logger.Info("audit_record",
+			"operation", string(record.Operation),
+			"result", string(record.Result),
+			"status", record.Status,
+			"actor", record.Actor,
+			"ip", record.IP)
+
+		storage := ar.server.Storages().Audit
+		if storage == nil {
+			return
+		}
+		if err := storage.AddRecord(record); err != nil {
+			ar.logger.Error("Unable to save audit record",
+				"operation", string(op),
+				logging.FieldError, err)
+		}
+	}
+}
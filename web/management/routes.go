@@ -33,7 +33,7 @@ func (ar *Router) initRoutes(loggerSettings model.LoggerSettings) {
 	ar.router.Get("/test", ar.test)
 	// token endpoints
 	ar.router.Route("/token", func(r chi.Router) {
-		r.Post("/invite", ar.getInviteToken)
-		r.Post("/reset_password", ar.getResetPasswordToken)
+		r.Post("/invite", ar.audited(model.AuditOperationManagementInviteToken, ar.getInviteToken))
+		r.Post("/reset_password", ar.audited(model.AuditOperationManagementResetPasswordToken, ar.getResetPasswordToken))
 	})
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns IP address of the client.
+// Proxy headers could be set by the client, so they are used only if trustProxyHeaders is set.
+// The last X-Forwarded-For address is used, because it is the one appended by the trusted proxy.
+func ClientIP(r *http.Request, trustProxyHeaders bool) string {
+	if trustProxyHeaders {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); len(ip) > 0 {
+			return ip
+		}
+		if xff := r.Header.Get("X-Forwarded-For"); len(xff) > 0 {
+			addrs := strings.Split(xff, ",")
+			if ip := strings.TrimSpace(addrs[len(addrs)-1]); len(ip) > 0 {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,111 @@
+// Package oidcauth holds the OpenID Connect code flow plumbing,
+// shared by the federated login of the users and the single sign-on into the admin panel.
+package oidcauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/madappgang/identifo/v2/model"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrExchange is when the authorization code could not be exchanged for the token.
+	ErrExchange = errors.New("unable to exchange the authorization code")
+	// ErrIDTokenMissing is when the token response has no ID token.
+	ErrIDTokenMissing = errors.New("no id_token in the token response")
+	// ErrIDTokenInvalid is when the ID token could not be verified.
+	ErrIDTokenInvalid = errors.New("invalid id_token")
+	// ErrClaims is when the claims of the ID token could not be parsed.
+	ErrClaims = errors.New("unable to parse id_token claims")
+)
+
+type providerInfo struct {
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+var (
+	providerCache     = map[string]providerInfo{}
+	providerCacheLock = sync.RWMutex{}
+)
+
+// Provider returns the provider and the ID token verifier, discovered once and cached by the key.
+func Provider(ctx context.Context, key string, settings model.OIDCSettings) (*oidc.Provider, *oidc.IDTokenVerifier, error) {
+	providerCacheLock.RLock()
+	pi, ok := providerCache[key]
+	providerCacheLock.RUnlock()
+
+	if ok {
+		return pi.provider, pi.verifier, nil
+	}
+
+	providerCacheLock.Lock()
+	defer providerCacheLock.Unlock()
+
+	pi, ok = providerCache[key]
+	if ok {
+		return pi.provider, pi.verifier, nil
+	}
+
+	if settings.Issuer != "" {
+		ctx = oidc.InsecureIssuerURLContext(ctx, settings.Issuer)
+	}
+
+	provider, err := oidc.NewProvider(ctx, settings.ProviderURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	verifier := provider.Verifier(&oidc.Config{
+		ClientID: settings.ClientID,
+	})
+
+	pi = providerInfo{
+		provider: provider,
+		verifier: verifier,
+	}
+
+	providerCache[key] = pi
+
+	return provider, verifier, nil
+}
+
+// Exchange exchanges the authorization code for the token and returns the verified claims of the ID token.
+// The errors wrap ErrExchange, ErrIDTokenMissing, ErrIDTokenInvalid or ErrClaims.
+func Exchange(
+	ctx context.Context,
+	config oauth2.Config,
+	verifier *oidc.IDTokenVerifier,
+	code string,
+	opts ...oauth2.AuthCodeOption,
+) (map[string]any, *oauth2.Token, error) {
+	token, err := config.Exchange(ctx, code, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+
+	// Extract the ID Token from OAuth2 token.
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, token, ErrIDTokenMissing
+	}
+
+	// Parse and verify ID Token payload.
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, token, fmt.Errorf("%w: %w", ErrIDTokenInvalid, err)
+	}
+
+	// Extract custom claims
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, token, fmt.Errorf("%w: %w", ErrClaims, err)
+	}
+
+	return claims, token, nil
+}
@@ -57,8 +57,14 @@ func NewRootRouter(settings RouterSetting) (model.Router, error) {
 		LoginWith:        settings.Server.Settings().Login.LoginWith,
 		TFAType:          settings.Server.Settings().Login.TFAType,
 		TFAResendTimeout: settings.Server.Settings().Login.TFAResendTimeout,
-		Cors:             apiCors,
-		Locale:           settings.Locale,
+		// the plugin protocol keeps the single factor of the user
+		TFASingleFactor: settings.Server.Settings().Storage.UserStorage.Type == model.DBTypePlugin ||
+			settings.Server.Settings().Storage.UserStorage.Type == model.DBTypeGRPC,
+		WebAuthn:          settings.Server.Settings().Login.WebAuthn,
+		Lockout:           settings.Server.Settings().Login.Lockout,
+		TrustProxyHeaders: settings.Server.Settings().General.TrustProxyHeaders,
+		Cors:              apiCors,
+		Locale:            settings.Locale,
 	}
 
 	apiRouter, err := api.NewRouter(apiSettings)
@@ -151,6 +151,7 @@ const ApplicationGeneralSettingsForm = (props) => {
             <Option value="android" title="Android Client (Mobile)" />
             <Option value="ios" title="iOS Client (Mobile)" />
             <Option value="desktop" title="Desktop Client (Desktop)" />
+            <Option value="service" title="Machine to Machine (Service)" />
           </Select>
         </Field>
       )}
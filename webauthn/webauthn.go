@@ -0,0 +1,351 @@
+// Package webauthn adapts the go-webauthn relying party (https://github.com/go-webauthn/webauthn)
+// to Identifo users and stored credentials.
+//
+// Attestation is not required, credentials are registered with "none" attestation conveyance.
+// The attestation statements are verified by the library, the authenticator model is not checked against metadata.
+package webauthn
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	gowebauthn "github.com/go-webauthn/webauthn/webauthn"
+	"github.com/madappgang/identifo/v2/model"
+)
+
+// User verification requirements.
+const (
+	UserVerificationRequired    = string(protocol.VerificationRequired)
+	UserVerificationPreferred   = string(protocol.VerificationPreferred)
+	UserVerificationDiscouraged = string(protocol.VerificationDiscouraged)
+)
+
+const defaultTimeout = 5 * time.Minute
+
+// ErrSignCount is returned if the signature counter has not increased since the last login.
+var ErrSignCount = errors.New("webauthn: signature counter did not increase, the authenticator may be cloned")
+
+type (
+	// CredentialCreationOptions are options for navigator.credentials.create().
+	CredentialCreationOptions = protocol.CredentialCreation
+	// CredentialRequestOptions are options for navigator.credentials.get().
+	CredentialRequestOptions = protocol.CredentialAssertion
+	// RegistrationResponse is a result of navigator.credentials.create().
+	RegistrationResponse = protocol.CredentialCreationResponse
+	// AssertionResponse is a result of navigator.credentials.get().
+	AssertionResponse = protocol.CredentialAssertionResponse
+	// Session keeps the ceremony data, the challenge first of all, between its begin and finish steps.
+	// It must be kept on the server side and used once.
+	Session = gowebauthn.SessionData
+)
+
+// Config is a relying party configuration.
+type Config struct {
+	// RPID is a relying party identifier, the effective domain of the origins.
+	RPID string
+	// RPName is a human-palatable relying party name.
+	RPName string
+	// Origins are allowed origins of the clients, like https://login.example.com.
+	Origins []string
+	// UserVerification is a user verification requirement, preferred by default.
+	UserVerification string
+	// Timeout is a ceremony timeout, five minutes by default.
+	Timeout time.Duration
+}
+
+// WebAuthn is a relying party, which performs registration and authentication ceremonies.
+type WebAuthn struct {
+	rp *gowebauthn.WebAuthn
+}
+
+// New creates new relying party.
+func New(config Config) (*WebAuthn, error) {
+	if len(config.RPID) == 0 {
+		return nil, errors.New("webauthn: relying party id is empty")
+	}
+	if len(config.Origins) == 0 {
+		return nil, errors.New("webauthn: no allowed origins")
+	}
+	if len(config.RPName) == 0 {
+		config.RPName = config.RPID
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	switch config.UserVerification {
+	case "":
+		config.UserVerification = UserVerificationPreferred
+	case UserVerificationRequired, UserVerificationPreferred, UserVerificationDiscouraged:
+	default:
+		return nil, fmt.Errorf("webauthn: unknown user verification requirement %s", config.UserVerification)
+	}
+
+	timeout := gowebauthn.TimeoutConfig{Enforce: true, Timeout: config.Timeout, TimeoutUVD: config.Timeout}
+	rp, err := gowebauthn.New(&gowebauthn.Config{
+		RPID:                  config.RPID,
+		RPDisplayName:         config.RPName,
+		RPOrigins:             config.Origins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.UserVerificationRequirement(config.UserVerification),
+		},
+		Timeouts: gowebauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthn{rp: rp}, nil
+}
+
+// BeginRegistration returns options to create a new credential for the user.
+// The user existing credentials are excluded, so the same authenticator is not registered twice.
+func (w *WebAuthn) BeginRegistration(user model.User, existing []model.WebAuthnCredential) (CredentialCreationOptions, Session, error) {
+	name := user.Username
+	if len(name) == 0 {
+		name = user.Email
+	}
+	if len(name) == 0 {
+		name = user.Phone
+	}
+	displayName := user.FullName
+	if len(displayName) == 0 {
+		displayName = name
+	}
+
+	u := webAuthnUser{id: user.ID, name: name, displayName: displayName}
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(existing))
+	for _, c := range existing {
+		credential, err := libraryCredential(c, false)
+		if err != nil {
+			return CredentialCreationOptions{}, Session{}, err
+		}
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	options, session, err := w.rp.BeginRegistration(u, gowebauthn.WithExclusions(exclusions))
+	if err != nil {
+		return CredentialCreationOptions{}, Session{}, err
+	}
+	return *options, *session, nil
+}
+
+// FinishRegistration verifies the new credential and returns it to be stored for the session user.
+func (w *WebAuthn) FinishRegistration(session Session, resp RegistrationResponse) (model.WebAuthnCredential, error) {
+	parsed, err := resp.Parse()
+	if err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+
+	c, err := w.rp.CreateCredential(webAuthnUser{id: string(session.UserID)}, session, parsed)
+	if err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+
+	key := webauthncose.PublicKeyData{}
+	if err := webauthncbor.Unmarshal(c.PublicKey, &key); err != nil {
+		return model.WebAuthnCredential{}, fmt.Errorf("webauthn: invalid public key: %w", err)
+	}
+
+	transports := make([]string, 0, len(c.Transport))
+	for _, t := range c.Transport {
+		transports = append(transports, string(t))
+	}
+
+	now := time.Now()
+	return model.WebAuthnCredential{
+		ID:             protocol.URLEncodedBase64(c.ID).String(),
+		UserID:         string(session.UserID),
+		PublicKey:      c.PublicKey,
+		Algorithm:      key.Algorithm,
+		SignCount:      c.Authenticator.SignCount,
+		AAGUID:         protocol.URLEncodedBase64(c.Authenticator.AAGUID).String(),
+		Transports:     transports,
+		BackupEligible: &c.Flags.BackupEligible,
+		BackupState:    c.Flags.BackupState,
+		CreatedAt:      now,
+		LastUsedAt:     now,
+	}, nil
+}
+
+// BeginLogin returns options to get an assertion for one of the credentials of the user.
+// With no user the user is not known yet and a discoverable credential (passkey) is requested.
+func (w *WebAuthn) BeginLogin(userID string, credentials []model.WebAuthnCredential) (CredentialRequestOptions, Session, error) {
+	var (
+		options *CredentialRequestOptions
+		session *Session
+		err     error
+	)
+
+	if len(userID) == 0 {
+		options, session, err = w.rp.BeginDiscoverableLogin()
+	} else {
+		u, uerr := newWebAuthnUser(userID, credentials, false)
+		if uerr != nil {
+			return CredentialRequestOptions{}, Session{}, uerr
+		}
+		options, session, err = w.rp.BeginLogin(u)
+	}
+	if err != nil {
+		return CredentialRequestOptions{}, Session{}, err
+	}
+	return *options, *session, nil
+}
+
+// FinishLogin verifies the assertion signed with one of the credentials of the session user,
+// or of the user the discoverable credential belongs to. The credentials are loaded with the given function.
+// It returns the credential with updated signature counter and last usage time to be stored.
+func (w *WebAuthn) FinishLogin(
+	session Session,
+	resp AssertionResponse,
+	credentials func(userID string) ([]model.WebAuthnCredential, error),
+) (model.WebAuthnCredential, error) {
+	parsed, err := resp.Parse()
+	if err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+	backupEligible := parsed.Response.AuthenticatorData.Flags.HasBackupEligible()
+
+	var stored []model.WebAuthnCredential
+	load := func(userID string) (gowebauthn.User, error) {
+		var lerr error
+		if stored, lerr = credentials(userID); lerr != nil {
+			return nil, lerr
+		}
+		return newWebAuthnUser(userID, stored, backupEligible)
+	}
+
+	var c *gowebauthn.Credential
+	if len(session.UserID) == 0 {
+		_, c, err = w.rp.ValidatePasskeyLogin(func(_, userHandle []byte) (gowebauthn.User, error) {
+			return load(string(userHandle))
+		}, session, parsed)
+	} else {
+		var u gowebauthn.User
+		if u, err = load(string(session.UserID)); err != nil {
+			return model.WebAuthnCredential{}, err
+		}
+		c, err = w.rp.ValidateLogin(u, session, parsed)
+	}
+	if err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+
+	id := protocol.URLEncodedBase64(c.ID).String()
+	for _, credential := range stored {
+		if credential.ID != id {
+			continue
+		}
+		if c.Authenticator.CloneWarning {
+			return credential, ErrSignCount
+		}
+
+		credential.SignCount = c.Authenticator.SignCount
+		credential.BackupEligible = &c.Flags.BackupEligible
+		credential.BackupState = c.Flags.BackupState
+		credential.LastUsedAt = time.Now()
+		return credential, nil
+	}
+	return model.WebAuthnCredential{}, errors.New("webauthn: credential is not found")
+}
+
+// RPConfig builds relying party configuration from the server host and login settings.
+// The relying party id defaults to the host name and the allowed origin to the host origin.
+func RPConfig(host string, issuer string, settings model.WebAuthnSettings) (Config, error) {
+	config := Config{
+		RPID:             settings.RPID,
+		RPName:           settings.RPName,
+		Origins:          settings.Origins,
+		UserVerification: settings.UserVerification,
+	}
+	if len(config.RPName) == 0 {
+		config.RPName = issuer
+	}
+
+	if len(config.RPID) == 0 || len(config.Origins) == 0 {
+		u, err := url.Parse(host)
+		if err != nil {
+			return config, err
+		}
+		if len(config.RPID) == 0 {
+			config.RPID = u.Hostname()
+		}
+		if len(config.Origins) == 0 {
+			config.Origins = []string{u.Scheme + "://" + u.Host}
+		}
+	}
+	return config, nil
+}
+
+// webAuthnUser is the user with the credentials as the relying party sees it, the user handle is the user id.
+type webAuthnUser struct {
+	id          string
+	name        string
+	displayName string
+	credentials []gowebauthn.Credential
+}
+
+func newWebAuthnUser(userID string, credentials []model.WebAuthnCredential, backupEligible bool) (webAuthnUser, error) {
+	u := webAuthnUser{id: userID}
+	for _, c := range credentials {
+		credential, err := libraryCredential(c, backupEligible)
+		if err != nil {
+			return u, err
+		}
+		u.credentials = append(u.credentials, credential)
+	}
+	return u, nil
+}
+
+func (u webAuthnUser) WebAuthnID() []byte                           { return []byte(u.id) }
+func (u webAuthnUser) WebAuthnName() string                         { return u.name }
+func (u webAuthnUser) WebAuthnDisplayName() string                  { return u.displayName }
+func (u webAuthnUser) WebAuthnCredentials() []gowebauthn.Credential { return u.credentials }
+
+// libraryCredential converts the stored credential to the library one.
+// The credentials stored before the backup eligibility was kept take it from the assertion.
+func libraryCredential(c model.WebAuthnCredential, backupEligible bool) (gowebauthn.Credential, error) {
+	id, err := decode(c.ID)
+	if err != nil {
+		return gowebauthn.Credential{}, fmt.Errorf("webauthn: invalid credential id: %w", err)
+	}
+	aaguid, err := decode(c.AAGUID)
+	if err != nil {
+		return gowebauthn.Credential{}, fmt.Errorf("webauthn: invalid credential aaguid: %w", err)
+	}
+	if c.BackupEligible != nil {
+		backupEligible = *c.BackupEligible
+	}
+
+	transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+	for _, t := range c.Transports {
+		transports = append(transports, protocol.AuthenticatorTransport(t))
+	}
+
+	return gowebauthn.Credential{
+		ID:        id,
+		PublicKey: c.PublicKey,
+		Transport: transports,
+		Flags: gowebauthn.CredentialFlags{
+			BackupEligible: backupEligible,
+			BackupState:    c.BackupState,
+		},
+		Authenticator: gowebauthn.Authenticator{
+			AAGUID:    aaguid,
+			SignCount: c.SignCount,
+		},
+	}, nil
+}
+
+// decode decodes base64url value, with or without padding.
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
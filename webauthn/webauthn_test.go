@@ -0,0 +1,302 @@
+package webauthn_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/madappgang/identifo/v2/model"
+	"github.com/madappgang/identifo/v2/webauthn"
+	"github.com/madappgang/identifo/v2/webauthn/webauthntest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://login.example.com"
+)
+
+var testUser = model.User{ID: "user1", Username: "username", FullName: "Test User"}
+
+func newRelyingParty(t *testing.T, userVerification string) *webauthn.WebAuthn {
+	rp, err := webauthn.New(webauthn.Config{
+		RPID:             testRPID,
+		Origins:          []string{testOrigin},
+		UserVerification: userVerification,
+	})
+	require.NoError(t, err)
+	return rp
+}
+
+func register(t *testing.T, rp *webauthn.WebAuthn, a *webauthntest.Authenticator) model.WebAuthnCredential {
+	options, session, err := rp.BeginRegistration(testUser, nil)
+	require.NoError(t, err)
+
+	resp, err := a.Create(options)
+	require.NoError(t, err)
+
+	credential, err := rp.FinishRegistration(session, resp)
+	require.NoError(t, err)
+	return credential
+}
+
+// stored returns the function to load the credentials of the user from the given ones.
+func stored(credentials ...model.WebAuthnCredential) func(string) ([]model.WebAuthnCredential, error) {
+	return func(userID string) ([]model.WebAuthnCredential, error) {
+		result := []model.WebAuthnCredential{}
+		for _, c := range credentials {
+			if c.UserID == userID {
+				result = append(result, c)
+			}
+		}
+		return result, nil
+	}
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	rp := newRelyingParty(t, "")
+	a := webauthntest.New(testRPID, testOrigin)
+
+	options, session, err := rp.BeginRegistration(testUser, nil)
+	require.NoError(t, err)
+	assert.Equal(t, testRPID, options.Response.RelyingParty.ID)
+	assert.Equal(t, testRPID, options.Response.RelyingParty.Name)
+	assert.Equal(t, "username", options.Response.User.Name)
+	assert.Equal(t, "Test User", options.Response.User.DisplayName)
+	assert.Equal(t, protocol.PreferNoAttestation, options.Response.Attestation)
+	assert.NotEmpty(t, options.Response.Parameters)
+
+	// session survives the round trip through the storage
+	data, err := json.Marshal(session)
+	require.NoError(t, err)
+	session = webauthn.Session{}
+	require.NoError(t, json.Unmarshal(data, &session))
+
+	resp, err := a.Create(options)
+	require.NoError(t, err)
+
+	credential, err := rp.FinishRegistration(session, resp)
+	require.NoError(t, err)
+	assert.Equal(t, resp.ID, credential.ID)
+	assert.Equal(t, testUser.ID, credential.UserID)
+	assert.Equal(t, int64(webauthncose.AlgES256), credential.Algorithm)
+	assert.Equal(t, []string{"internal"}, credential.Transports)
+	require.NotNil(t, credential.BackupEligible)
+	assert.False(t, *credential.BackupEligible)
+
+	// the registered credential is excluded on the next registration
+	options, _, err = rp.BeginRegistration(testUser, []model.WebAuthnCredential{credential})
+	require.NoError(t, err)
+	require.Len(t, options.Response.CredentialExcludeList, 1)
+	assert.Equal(t, credential.ID, options.Response.CredentialExcludeList[0].CredentialID.String())
+
+	loginOptions, loginSession, err := rp.BeginLogin(testUser.ID, []model.WebAuthnCredential{credential})
+	require.NoError(t, err)
+	assert.Equal(t, testRPID, loginOptions.Response.RelyingPartyID)
+	require.Len(t, loginOptions.Response.AllowedCredentials, 1)
+
+	assertion, err := a.Get(loginOptions)
+	require.NoError(t, err)
+
+	updated, err := rp.FinishLogin(loginSession, assertion, stored(credential))
+	require.NoError(t, err)
+	assert.Equal(t, a.Counter, updated.SignCount)
+
+	// replayed assertion has the same counter
+	_, err = rp.FinishLogin(loginSession, assertion, stored(updated))
+	assert.ErrorIs(t, err, webauthn.ErrSignCount)
+}
+
+func TestDiscoverableLogin(t *testing.T) {
+	rp := newRelyingParty(t, "")
+	a := webauthntest.New(testRPID, testOrigin)
+	credential := register(t, rp, a)
+
+	options, session, err := rp.BeginLogin("", nil)
+	require.NoError(t, err)
+	assert.Empty(t, options.Response.AllowedCredentials)
+
+	assertion, err := a.Get(options)
+	require.NoError(t, err)
+
+	updated, err := rp.FinishLogin(session, assertion, stored(credential))
+	require.NoError(t, err)
+	assert.Equal(t, testUser.ID, updated.UserID)
+}
+
+func TestLoginBackupEligibility(t *testing.T) {
+	rp := newRelyingParty(t, "")
+	a := webauthntest.New(testRPID, testOrigin)
+	a.BackupEligible = true
+	credential := register(t, rp, a)
+	require.NotNil(t, credential.BackupEligible)
+	assert.True(t, *credential.BackupEligible)
+
+	login := func(credential model.WebAuthnCredential) (model.WebAuthnCredential, error) {
+		options, session, err := rp.BeginLogin(testUser.ID, []model.WebAuthnCredential{credential})
+		require.NoError(t, err)
+		assertion, err := a.Get(options)
+		require.NoError(t, err)
+		return rp.FinishLogin(session, assertion, stored(credential))
+	}
+
+	// the credentials registered before the flag was stored take it from the assertion
+	legacy := credential
+	legacy.BackupEligible = nil
+	updated, err := login(legacy)
+	require.NoError(t, err)
+	require.NotNil(t, updated.BackupEligible)
+	assert.True(t, *updated.BackupEligible)
+
+	// the flag never changes
+	a.BackupEligible = false
+	_, err = login(updated)
+	assert.Error(t, err)
+}
+
+func TestLoginRejected(t *testing.T) {
+	rp := newRelyingParty(t, webauthn.UserVerificationRequired)
+	a := webauthntest.New(testRPID, testOrigin)
+	credential := register(t, rp, a)
+
+	assertion := func(a *webauthntest.Authenticator) (webauthn.Session, webauthn.AssertionResponse) {
+		options, session, err := rp.BeginLogin(testUser.ID, []model.WebAuthnCredential{credential})
+		require.NoError(t, err)
+		resp, err := a.Get(options)
+		require.NoError(t, err)
+		return session, resp
+	}
+
+	t.Run("wrong origin", func(t *testing.T) {
+		a.Origin = "https://evil.example.com"
+		defer func() { a.Origin = testOrigin }()
+
+		session, resp := assertion(a)
+		_, err := rp.FinishLogin(session, resp, stored(credential))
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong relying party", func(t *testing.T) {
+		a.RPID = "evil.example.com"
+		defer func() { a.RPID = testRPID }()
+
+		session, resp := assertion(a)
+		_, err := rp.FinishLogin(session, resp, stored(credential))
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		_, resp := assertion(a)
+		_, otherSession, err := rp.BeginLogin(testUser.ID, []model.WebAuthnCredential{credential})
+		require.NoError(t, err)
+
+		_, err = rp.FinishLogin(otherSession, resp, stored(credential))
+		assert.Error(t, err)
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		session, resp := assertion(a)
+		session.Expires = time.Now().Add(-time.Second)
+
+		_, err := rp.FinishLogin(session, resp, stored(credential))
+		assert.Error(t, err)
+	})
+
+	t.Run("user not verified", func(t *testing.T) {
+		a.UserVerified = false
+		defer func() { a.UserVerified = true }()
+
+		session, resp := assertion(a)
+		_, err := rp.FinishLogin(session, resp, stored(credential))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		session, resp := assertion(a)
+		other := webauthntest.New(testRPID, testOrigin)
+		otherCredential := register(t, rp, other)
+		credential := credential
+		credential.PublicKey = otherCredential.PublicKey
+
+		_, err := rp.FinishLogin(session, resp, stored(credential))
+		assert.Error(t, err)
+	})
+
+	t.Run("other user credential", func(t *testing.T) {
+		other := webauthntest.New(testRPID, testOrigin)
+		otherCredential := register(t, rp, other)
+		otherCredential.UserID = "user2"
+
+		options, session, err := rp.BeginLogin(testUser.ID, []model.WebAuthnCredential{credential})
+		require.NoError(t, err)
+		options.Response.AllowedCredentials = nil
+		resp, err := other.Get(options)
+		require.NoError(t, err)
+
+		_, err = rp.FinishLogin(session, resp, stored(credential, otherCredential))
+		assert.Error(t, err)
+	})
+}
+
+func TestRegistrationRejected(t *testing.T) {
+	rp := newRelyingParty(t, "")
+	a := webauthntest.New(testRPID, testOrigin)
+
+	options, session, err := rp.BeginRegistration(testUser, nil)
+	require.NoError(t, err)
+	resp, err := a.Create(options)
+	require.NoError(t, err)
+
+	// the client data of registration is not accepted for login
+	loginResp := webauthn.AssertionResponse{}
+	loginResp.ID, loginResp.RawID, loginResp.Type = resp.ID, resp.RawID, resp.Type
+	loginResp.AssertionResponse.ClientDataJSON = resp.AttestationResponse.ClientDataJSON
+	_, err = rp.FinishLogin(session, loginResp, stored())
+	assert.Error(t, err)
+
+	// the session of other ceremony
+	_, otherSession, err := rp.BeginRegistration(testUser, nil)
+	require.NoError(t, err)
+	_, err = rp.FinishRegistration(otherSession, resp)
+	assert.Error(t, err)
+}
+
+func TestRegistrationAttestation(t *testing.T) {
+	rp := newRelyingParty(t, "")
+	a := webauthntest.New(testRPID, testOrigin)
+
+	// packed self attestation is verified with the credential key
+	a.AttestationFormat = "packed"
+	register(t, rp, a)
+
+	// the statements which are not valid for the format are rejected
+	a.AttestationFormat = "fido-u2f"
+	options, session, err := rp.BeginRegistration(testUser, nil)
+	require.NoError(t, err)
+	resp, err := a.Create(options)
+	require.NoError(t, err)
+	_, err = rp.FinishRegistration(session, resp)
+	assert.Error(t, err)
+}
+
+func TestRPConfig(t *testing.T) {
+	config, err := webauthn.RPConfig("https://login.example.com:8443/", "Identifo", model.WebAuthnSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, "login.example.com", config.RPID)
+	assert.Equal(t, "Identifo", config.RPName)
+	assert.Equal(t, []string{"https://login.example.com:8443"}, config.Origins)
+
+	config, err = webauthn.RPConfig("https://login.example.com", "Identifo", model.WebAuthnSettings{
+		RPID:    "example.com",
+		Origins: []string{"https://example.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", config.RPID)
+	assert.Equal(t, []string{"https://example.com"}, config.Origins)
+
+	_, err = webauthn.New(webauthn.Config{RPID: "example.com"})
+	assert.Error(t, err)
+}
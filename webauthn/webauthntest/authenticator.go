@@ -0,0 +1,234 @@
+// Package webauthntest provides a virtual WebAuthn authenticator for tests.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/madappgang/identifo/v2/webauthn"
+)
+
+// Authenticator is a virtual authenticator with ES256 credentials, which acts as a client as well.
+type Authenticator struct {
+	RPID   string
+	Origin string
+	// UserVerified sets user verification flag of authenticator data.
+	UserVerified bool
+	// Counter is a signature counter, it is incremented on every assertion if not zero.
+	Counter uint32
+	// BackupEligible sets backup eligibility flag of authenticator data, like synced passkeys do.
+	BackupEligible bool
+	// AttestationFormat is the format of the attestation statement, "none" if empty.
+	// "packed" is the self attestation, other formats come with the empty statement.
+	AttestationFormat string
+
+	credentials map[string]credential
+}
+
+type attestationObject struct {
+	Format    string         `cbor:"fmt"`
+	Statement map[string]any `cbor:"attStmt"`
+	AuthData  []byte         `cbor:"authData"`
+}
+
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+}
+
+// New creates new virtual authenticator for the relying party.
+func New(rpID, origin string) *Authenticator {
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		UserVerified: true,
+		Counter:      1,
+		credentials:  map[string]credential{},
+	}
+}
+
+// Create creates new credential for the options, like navigator.credentials.create() does.
+func (a *Authenticator) Create(options webauthn.CredentialCreationOptions) (webauthn.RegistrationResponse, error) {
+	resp := webauthn.RegistrationResponse{}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return resp, err
+	}
+
+	userHandle, err := userHandle(options.Response.User.ID)
+	if err != nil {
+		return resp, err
+	}
+
+	c := credential{id: make([]byte, 16), key: key, userHandle: userHandle}
+	if _, err := rand.Read(c.id); err != nil {
+		return resp, err
+	}
+	id := encode(c.id)
+	a.credentials[id] = c
+
+	clientData, err := a.clientData("webauthn.create", options.Response.Challenge.String())
+	if err != nil {
+		return resp, err
+	}
+
+	coseKey, err := COSEKey(&key.PublicKey)
+	if err != nil {
+		return resp, err
+	}
+
+	authData := a.authenticatorData(0x40)
+	authData = append(authData, make([]byte, 16)...) // zero aaguid
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(c.id)))
+	authData = append(authData, c.id...)
+	authData = append(authData, coseKey...)
+
+	object := attestationObject{Format: "none", Statement: map[string]any{}, AuthData: authData}
+	switch a.AttestationFormat {
+	case "", "none":
+	case "packed":
+		clientDataHash := sha256.Sum256(clientData)
+		digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			return resp, err
+		}
+		object.Format = a.AttestationFormat
+		object.Statement = map[string]any{"alg": int64(webauthncose.AlgES256), "sig": sig}
+	default:
+		object.Format = a.AttestationFormat
+	}
+
+	attestation, err := webauthncbor.Marshal(object)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.ID = id
+	resp.RawID = c.id
+	resp.Type = "public-key"
+	resp.AttestationResponse.ClientDataJSON = clientData
+	resp.AttestationResponse.AttestationObject = attestation
+	resp.AttestationResponse.Transports = []string{"internal"}
+	return resp, nil
+}
+
+// Get returns an assertion for the options, like navigator.credentials.get() does.
+// If options allow no credentials, any discoverable credential is used.
+func (a *Authenticator) Get(options webauthn.CredentialRequestOptions) (webauthn.AssertionResponse, error) {
+	resp := webauthn.AssertionResponse{}
+
+	var (
+		c  credential
+		ok bool
+	)
+	if len(options.Response.AllowedCredentials) == 0 {
+		for _, c = range a.credentials {
+			ok = true
+			break
+		}
+	}
+	for _, allowed := range options.Response.AllowedCredentials {
+		if c, ok = a.credentials[allowed.CredentialID.String()]; ok {
+			break
+		}
+	}
+	if !ok {
+		return resp, fmt.Errorf("no credentials available")
+	}
+
+	if a.Counter > 0 {
+		a.Counter++
+	}
+
+	clientData, err := a.clientData("webauthn.get", options.Response.Challenge.String())
+	if err != nil {
+		return resp, err
+	}
+	authData := a.authenticatorData(0)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return resp, err
+	}
+
+	resp.ID = encode(c.id)
+	resp.RawID = c.id
+	resp.Type = "public-key"
+	resp.AssertionResponse.ClientDataJSON = clientData
+	resp.AssertionResponse.AuthenticatorData = authData
+	resp.AssertionResponse.Signature = signature
+	resp.AssertionResponse.UserHandle = c.userHandle
+	return resp, nil
+}
+
+func (a *Authenticator) clientData(typ, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags |= 0x01 // user present
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	if a.BackupEligible {
+		flags |= 0x08
+	}
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, a.Counter)
+}
+
+// COSEKey encodes ES256 public key as COSE_Key.
+func COSEKey(key *ecdsa.PublicKey) ([]byte, error) {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	return webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: x,
+		YCoord: y,
+	})
+}
+
+// userHandle returns the user handle of the options, which is base64url encoded after the JSON round trip.
+func userHandle(id any) ([]byte, error) {
+	switch v := id.(type) {
+	case protocol.URLEncodedBase64:
+		return v, nil
+	case string:
+		return base64.RawURLEncoding.DecodeString(v)
+	default:
+		return nil, fmt.Errorf("unexpected user handle type %T", id)
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}